@@ -0,0 +1,397 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// auditLogPages limits the number of pages of audit log entries an access
+// report reads.
+const auditLogPages = 20
+
+// An accessReport describes who can change a zone, and who did recently:
+// the members of the zone's account whose roles or policies cover it, the
+// credentials' API tokens granted permissions on it, and the actors of the
+// account's audit log entries for it.
+type accessReport struct {
+	Generated time.Time      `json:"generated"`
+	Zone      string         `json:"zone"`
+	ZoneID    string         `json:"zone_id"`
+	Account   string         `json:"account"`
+	Since     time.Time      `json:"since"`
+	Members   []accessMember `json:"members"`
+	Tokens    []accessToken  `json:"tokens"`
+	Actors    []accessActor  `json:"actors"`
+	Notes     []string       `json:"notes,omitempty"`
+}
+
+type accessMember struct {
+	Email       string   `json:"email"`
+	Name        string   `json:"name,omitempty"`
+	Status      string   `json:"status"`
+	TwoFactor   bool     `json:"two_factor"`
+	Scope       string   `json:"scope"` // account or zone
+	Permissions []string `json:"permissions"`
+}
+
+type accessToken struct {
+	Name        string     `json:"name"`
+	ID          string     `json:"id"`
+	Status      string     `json:"status"`
+	Scope       string     `json:"scope"` // all accounts, account or zone
+	Permissions []string   `json:"permissions"`
+	ExpiresOn   *time.Time `json:"expires_on,omitempty"`
+}
+
+type accessActor struct {
+	Actor   string    `json:"actor"`
+	Type    string    `json:"type"`
+	Actions int       `json:"actions"`
+	Failed  int       `json:"failed"`
+	Last    time.Time `json:"last"`
+	IPs     []string  `json:"ips"`
+}
+
+// takeAccessReport collects the access report of a zone. Sections the
+// credentials are not permitted to read are left empty, with a note.
+func takeAccessReport(api *cloudflare.API, zone string, since time.Duration) (accessReport, error) {
+	now := time.Now().UTC()
+	rep := accessReport{
+		Generated: now,
+		Zone:      zone,
+		Since:     now.Add(-since),
+		Members:   []accessMember{},
+		Tokens:    []accessToken{},
+		Actors:    []accessActor{},
+	}
+
+	zoneID, err := api.ZoneIDByName(zone)
+	if err != nil {
+		return rep, zoneError(err)
+	}
+	z, err := api.ZoneDetails(commandCtx, zoneID)
+	if err != nil {
+		return rep, err
+	}
+	rep.ZoneID, rep.Account = z.ID, z.Account.Name
+	accountID := z.Account.ID
+
+	note := func(what string, err error) {
+		rep.Notes = append(rep.Notes, fmt.Sprintf("could not read %s: %v", what, err))
+	}
+
+	if members, err := accountMembers(api, accountID); err != nil {
+		note("account members", err)
+	} else {
+		for _, m := range members {
+			if am, ok := memberAccess(m, accountID, zoneID); ok {
+				rep.Members = append(rep.Members, am)
+			}
+		}
+	}
+
+	if tokens, err := api.APITokens(commandCtx); err != nil {
+		note("API tokens", err)
+	} else {
+		for _, t := range tokens {
+			if at, ok := tokenAccess(t, accountID, zoneID); ok {
+				rep.Tokens = append(rep.Tokens, at)
+			}
+		}
+	}
+
+	if logs, err := zoneAuditLogs(api, accountID, zone, rep.Since); err != nil {
+		note("audit logs", err)
+	} else {
+		rep.Actors = auditActors(logs)
+	}
+
+	if commandCtx.Err() != nil {
+		return rep, errInterrupted
+	}
+	return rep, nil
+}
+
+// accountMembers returns every member of an account.
+func accountMembers(api *cloudflare.API, accountID string) ([]cloudflare.AccountMember, error) {
+	var all []cloudflare.AccountMember
+	for page := 1; ; page++ {
+		members, info, err := api.AccountMembers(commandCtx, accountID,
+			cloudflare.PaginationOptions{Page: page, PerPage: 50})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, members...)
+		if len(members) == 0 || page >= info.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+// memberAccess describes a member's access to a zone. A member's roles
+// apply to every zone of the account; policies apply to the zones of
+// their resource groups.
+func memberAccess(m cloudflare.AccountMember, accountID, zoneID string) (accessMember, bool) {
+	am := accessMember{
+		Email:       m.User.Email,
+		Name:        strings.TrimSpace(m.User.FirstName + " " + m.User.LastName),
+		Status:      m.Status,
+		TwoFactor:   m.User.TwoFactorAuthenticationEnabled,
+		Permissions: []string{},
+	}
+	for _, r := range m.Roles {
+		am.Scope = "account"
+		am.Permissions = append(am.Permissions, r.Name)
+	}
+	for _, p := range m.Policies {
+		if p.Access != "allow" {
+			continue
+		}
+		for _, g := range p.ResourceGroups {
+			scope := resourceScope(g.Scope, accountID, zoneID)
+			if scope == "" {
+				continue
+			}
+			if am.Scope != "account" {
+				am.Scope = scope
+			}
+			for _, pg := range p.PermissionGroups {
+				am.Permissions = append(am.Permissions, pg.Name)
+			}
+		}
+	}
+	slices.Sort(am.Permissions)
+	am.Permissions = slices.Compact(am.Permissions)
+	return am, am.Scope != ""
+}
+
+// resourceScope returns whether a policy's resource group covers the whole
+// account or the zone, or the empty string if it covers neither.
+func resourceScope(s cloudflare.Scope, accountID, zoneID string) string {
+	zoneKey := "com.cloudflare.api.account.zone." + zoneID
+	if s.Key == zoneKey {
+		return "zone"
+	}
+	if s.Key != "com.cloudflare.api.account."+accountID {
+		return ""
+	}
+	scope := ""
+	for _, o := range s.ScopeObjects {
+		switch o.Key {
+		case "*", "com.cloudflare.api.account.zone.*":
+			return "account"
+		case zoneKey:
+			scope = "zone"
+		}
+	}
+	return scope
+}
+
+// tokenAccess describes an API token's access to a zone: the permissions
+// its allowing policies grant on the zone, less those its denying policies
+// withhold.
+func tokenAccess(t cloudflare.APIToken, accountID, zoneID string) (accessToken, bool) {
+	at := accessToken{
+		Name:        t.Name,
+		ID:          t.ID,
+		Status:      t.Status,
+		ExpiresOn:   t.ExpiresOn,
+		Permissions: []string{},
+	}
+	denied := make(map[string]bool)
+	for _, p := range t.Policies {
+		scope := tokenResourceScope(p.Resources, accountID, zoneID)
+		if scope == "" {
+			continue
+		}
+		for _, g := range p.PermissionGroups {
+			if p.Effect == "deny" {
+				denied[g.Name] = true
+			} else {
+				at.Permissions = append(at.Permissions, g.Name)
+			}
+		}
+		if p.Effect != "deny" && (at.Scope == "" || scopeRank[scope] > scopeRank[at.Scope]) {
+			at.Scope = scope
+		}
+	}
+	at.Permissions = slices.DeleteFunc(at.Permissions, func(name string) bool { return denied[name] })
+	slices.Sort(at.Permissions)
+	at.Permissions = slices.Compact(at.Permissions)
+	return at, len(at.Permissions) > 0
+}
+
+var scopeRank = map[string]int{"zone": 1, "account": 2, "all accounts": 3}
+
+// tokenResourceScope returns the scope of a token policy's resources that
+// covers the zone: "zone", "account" or "all accounts", or the empty string
+// if they do not cover it.
+func tokenResourceScope(resources map[string]any, accountID, zoneID string) string {
+	zoneKey := "com.cloudflare.api.account.zone." + zoneID
+	scope := ""
+	for k, v := range resources {
+		s := ""
+		switch k {
+		case zoneKey:
+			s = "zone"
+		case "com.cloudflare.api.account.zone.*", "com.cloudflare.api.account.*":
+			s = "all accounts"
+		case "com.cloudflare.api.account." + accountID:
+			// Account resources list the account's zones they cover.
+			nested, _ := v.(map[string]any)
+			if _, ok := nested["com.cloudflare.api.account.zone.*"]; ok {
+				s = "account"
+			} else if _, ok := nested[zoneKey]; ok {
+				s = "zone"
+			}
+		}
+		if s != "" && (scope == "" || scopeRank[s] > scopeRank[scope]) {
+			scope = s
+		}
+	}
+	return scope
+}
+
+// zoneAuditLogs returns the account's audit log entries for a zone since a
+// time, newest first.
+func zoneAuditLogs(api *cloudflare.API, accountID, zone string, since time.Time) ([]cloudflare.AuditLog, error) {
+	const perPage = 100
+	var all []cloudflare.AuditLog
+	for page := 1; page <= auditLogPages; page++ {
+		resp, err := api.GetOrganizationAuditLogs(commandCtx, accountID, cloudflare.AuditLogFilter{
+			ZoneName:  zone,
+			Since:     since.Format(time.RFC3339),
+			Direction: "desc",
+			PerPage:   perPage,
+			Page:      page,
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, resp.Result...)
+		if len(resp.Result) < perPage {
+			break
+		}
+	}
+	return all, nil
+}
+
+// auditActors summarizes the actors of audit log entries, most recently
+// active first.
+func auditActors(logs []cloudflare.AuditLog) []accessActor {
+	byActor := make(map[string]*accessActor)
+	for _, l := range logs {
+		name := l.Actor.Email
+		if name == "" {
+			name = l.Actor.ID
+		}
+		a, ok := byActor[name]
+		if !ok {
+			a = &accessActor{Actor: name, Type: l.Actor.Type, IPs: []string{}}
+			byActor[name] = a
+		}
+		a.Actions++
+		if !l.Action.Result {
+			a.Failed++
+		}
+		if l.When.After(a.Last) {
+			a.Last = l.When
+		}
+		if l.Actor.IP != "" && !slices.Contains(a.IPs, l.Actor.IP) {
+			a.IPs = append(a.IPs, l.Actor.IP)
+		}
+	}
+
+	actors := []accessActor{}
+	for _, a := range byActor {
+		sort.Strings(a.IPs)
+		actors = append(actors, *a)
+	}
+	sort.Slice(actors, func(i, j int) bool {
+		if !actors[i].Last.Equal(actors[j].Last) {
+			return actors[i].Last.After(actors[j].Last)
+		}
+		return actors[i].Actor < actors[j].Actor
+	})
+	return actors
+}
+
+// writeAccessReport writes an access report as JSON, as CSV with a row for
+// each member, token and actor, or as an HTML page.
+func writeAccessReport(w io.Writer, rep accessReport, format string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"zone", "kind", "who", "scope", "permissions", "status", "actions", "last"})
+		for _, m := range rep.Members {
+			cw.Write([]string{rep.Zone, "member", m.Email, m.Scope, strings.Join(m.Permissions, "; "), m.Status, "", ""})
+		}
+		for _, t := range rep.Tokens {
+			cw.Write([]string{rep.Zone, "token", t.Name, t.Scope, strings.Join(t.Permissions, "; "), t.Status, "", ""})
+		}
+		for _, a := range rep.Actors {
+			cw.Write([]string{rep.Zone, "actor", a.Actor, "", "", a.Type, strconv.Itoa(a.Actions), a.Last.Format(time.RFC3339)})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "html":
+		return accessTemplate.Execute(w, rep)
+
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+}
+
+var accessTemplate = template.Must(template.New("access").Funcs(template.FuncMap{
+	"join": strings.Join,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Access to {{.Zone}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+</style>
+</head>
+<body>
+<h1>Access to {{.Zone}}</h1>
+<p>Account: {{.Account}} &middot; Generated {{.Generated.Format "2006-01-02 15:04:05 UTC"}}</p>
+{{range .Notes}}<p><em>Note: {{.}}</em></p>
+{{end}}
+<h2>Members</h2>
+{{if .Members}}<table>
+<tr><th>Email</th><th>Name</th><th>Status</th><th>2FA</th><th>Scope</th><th>Roles and permissions</th></tr>
+{{range .Members}}<tr><td>{{.Email}}</td><td>{{.Name}}</td><td>{{.Status}}</td><td>{{if .TwoFactor}}yes{{else}}no{{end}}</td><td>{{.Scope}}</td><td>{{join .Permissions ", "}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+<h2>API tokens</h2>
+{{if .Tokens}}<table>
+<tr><th>Name</th><th>Status</th><th>Scope</th><th>Permissions</th><th>Expires</th></tr>
+{{range .Tokens}}<tr><td>{{.Name}}</td><td>{{.Status}}</td><td>{{.Scope}}</td><td>{{join .Permissions ", "}}</td><td>{{if .ExpiresOn}}{{.ExpiresOn.Format "2006-01-02"}}{{else}}never{{end}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+<h2>Activity since {{.Since.Format "2006-01-02"}}</h2>
+{{if .Actors}}<table>
+<tr><th>Actor</th><th>Type</th><th>Actions</th><th>Failed</th><th>Last</th><th>IP addresses</th></tr>
+{{range .Actors}}<tr><td>{{.Actor}}</td><td>{{.Type}}</td><td>{{.Actions}}</td><td>{{.Failed}}</td><td>{{.Last.Format "2006-01-02 15:04:05 UTC"}}</td><td>{{join .IPs ", "}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+</body>
+</html>
+`))
@@ -0,0 +1,237 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// credentialSource describes where getAPI found the credentials in use:
+// a profile, the environment, the system keyring or a prompt.
+var credentialSource string
+
+func cmdAccount(c *cmd.Command, args []string) error {
+	if len(args) > 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0 || args[0] == "list":
+		return listAccounts(api)
+	case args[0] == "verify":
+		return verifyCredentials(api)
+	case args[0] == "permissions":
+		return showPermissions(api)
+	default:
+		return usageError(c)
+	}
+}
+
+// allAccounts returns every account the credentials can access.
+func allAccounts(api *cloudflare.API) ([]cloudflare.Account, error) {
+	var all []cloudflare.Account
+	for page := 1; ; page++ {
+		accounts, info, err := api.Accounts(commandCtx, cloudflare.AccountsListParams{
+			PaginationOptions: cloudflare.PaginationOptions{Page: page, PerPage: 50},
+		})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, accounts...)
+		if len(accounts) == 0 || page >= info.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+func listAccounts(api *cloudflare.API) error {
+	accounts, err := allAccounts(api)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(accounts)
+	}
+
+	if len(accounts) == 0 {
+		printf("No accounts available.\n")
+		return nil
+	}
+	width := 0
+	for _, a := range accounts {
+		width = max(width, len(a.Name))
+	}
+	for _, a := range accounts {
+		fmt.Fprintf(output, "%-*s %s %s\n", width, a.Name, a.ID, a.Type)
+	}
+	return nil
+}
+
+// verifyCredentials checks the credentials in use, describing them and why
+// they were rejected, if they were.
+func verifyCredentials(api *cloudflare.API) error {
+	if credentialSource != "" {
+		fmt.Fprintf(output, "Credentials from: %s\n", credentialSource)
+	}
+
+	if api.APIToken == "" {
+		fmt.Fprintf(output, "Credential type:  global API key of %s\n", api.APIEmail)
+		user, err := api.UserDetails(commandCtx)
+		if err != nil {
+			return authError(fmt.Errorf("credentials rejected: %v", err))
+		}
+		fmt.Fprintf(output, "User:             %s (%s)\n", user.Email, user.ID)
+		printf("Credentials are valid.\n")
+		return nil
+	}
+
+	fmt.Fprintf(output, "Credential type:  API token\n")
+	v, err := api.VerifyAPIToken(commandCtx)
+	if err != nil {
+		return authError(fmt.Errorf("token rejected: %v", err))
+	}
+	fmt.Fprintf(output, "Token ID:         %s\n", v.ID)
+	fmt.Fprintf(output, "Status:           %s\n", v.Status)
+	if !v.NotBefore.IsZero() {
+		fmt.Fprintf(output, "Valid from:       %s\n", v.NotBefore.Local().Format("2006-01-02 15:04"))
+	}
+	if !v.ExpiresOn.IsZero() {
+		fmt.Fprintf(output, "Expires:          %s\n", v.ExpiresOn.Local().Format("2006-01-02 15:04"))
+	}
+	if v.Status != "active" {
+		return authError(fmt.Errorf("token is %s", v.Status))
+	}
+	printf("Credentials are valid.\n")
+	return nil
+}
+
+// showPermissions displays the permissions of the API token in use and the
+// resources they apply to, with account and zone identifiers replaced by
+// names where the token can read them.
+func showPermissions(api *cloudflare.API) error {
+	if api.APIToken == "" {
+		printf("A global API key has every permission of user %s.\n", api.APIEmail)
+		return nil
+	}
+
+	v, err := api.VerifyAPIToken(commandCtx)
+	if err != nil {
+		return authError(fmt.Errorf("token rejected: %v", err))
+	}
+	token, err := api.GetAPIToken(commandCtx, v.ID)
+	if err != nil {
+		printf("The token's permissions could not be read; reading them requires the \"API Tokens Read\" permission.\n")
+		return showAccessible(api)
+	}
+
+	names := resourceNames(api)
+	for _, p := range token.Policies {
+		var groups []string
+		for _, g := range p.PermissionGroups {
+			groups = append(groups, g.Name)
+		}
+		sort.Strings(groups)
+		fmt.Fprintf(output, "%s %s\n", p.Effect, strings.Join(groups, ", "))
+		for _, r := range describeResources(p.Resources, names) {
+			fmt.Fprintf(output, "    %s\n", r)
+		}
+	}
+	if token.Condition != nil && token.Condition.RequestIP != nil {
+		if ips := token.Condition.RequestIP.In; len(ips) > 0 {
+			printf("Only from: %s\n", strings.Join(ips, ", "))
+		}
+		if ips := token.Condition.RequestIP.NotIn; len(ips) > 0 {
+			printf("Not from: %s\n", strings.Join(ips, ", "))
+		}
+	}
+	return nil
+}
+
+// showAccessible displays the accounts and zones the credentials can
+// access, when their permissions cannot be read.
+func showAccessible(api *cloudflare.API) error {
+	accounts, err := allAccounts(api)
+	if err != nil {
+		return err
+	}
+	zones, err := api.ListZones(commandCtx)
+	if err != nil {
+		return err
+	}
+
+	printf("Accessible accounts:\n")
+	for _, a := range accounts {
+		fmt.Fprintf(output, "    %s (%s)\n", a.Name, a.ID)
+	}
+	printf("Accessible zones:\n")
+	for _, z := range zones {
+		fmt.Fprintf(output, "    %s (%s)\n", z.Name, z.ID)
+	}
+	return nil
+}
+
+// resourceNames maps the resource keys of accessible accounts and zones to
+// their names.
+func resourceNames(api *cloudflare.API) map[string]string {
+	names := make(map[string]string)
+	if accounts, err := allAccounts(api); err == nil {
+		for _, a := range accounts {
+			names["com.cloudflare.api.account."+a.ID] = "account " + a.Name
+		}
+	}
+	if zones, err := api.ListZones(commandCtx); err == nil {
+		for _, z := range zones {
+			names["com.cloudflare.api.account.zone."+z.ID] = "zone " + z.Name
+		}
+	}
+	names["com.cloudflare.api.account.*"] = "all accounts"
+	names["com.cloudflare.api.account.zone.*"] = "all zones"
+	return names
+}
+
+// describeResources describes the resources of a token policy, one per
+// line, with nested resources indented under the account they belong to.
+func describeResources(resources map[string]any, names map[string]string) []string {
+	name := func(key string) string {
+		if n, ok := names[key]; ok {
+			return n
+		}
+		if strings.HasPrefix(key, "com.cloudflare.api.user.") {
+			return "user " + strings.TrimPrefix(key, "com.cloudflare.api.user.")
+		}
+		return key
+	}
+
+	var keys []string
+	for k := range resources {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	var lines []string
+	for _, k := range keys {
+		lines = append(lines, name(k))
+		if nested, ok := resources[k].(map[string]any); ok {
+			for _, n := range describeResources(nested, names) {
+				lines = append(lines, "    "+n)
+			}
+		}
+	}
+	return lines
+}
@@ -0,0 +1,504 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// Zone analytics are only available through Cloudflare's GraphQL API. Its
+// hourly HTTP datasets cover the last few days, so longer ranges are
+// summarized from the daily datasets instead.
+const (
+	analyticsHourlyLimit = 72 * time.Hour
+	defaultAnalyticsTop  = 10
+)
+
+// Defaults of analytics compare. A count changing by the threshold percentage
+// or more, or the cache ratio changing by cacheRatioAnomaly percentage
+// points or more, is flagged as an anomaly.
+const (
+	defaultCompareThreshold = 50
+	cacheRatioAnomaly       = 10
+)
+
+// analyticsSummary holds a zone's traffic over a time range.
+type analyticsSummary struct {
+	Zone           string         `json:"zone"`
+	Since          time.Time      `json:"since"`
+	Until          time.Time      `json:"until"`
+	Requests       int64          `json:"requests"`
+	CachedRequests int64          `json:"cached_requests"`
+	Bytes          int64          `json:"bytes"`
+	CachedBytes    int64          `json:"cached_bytes"`
+	Threats        int64          `json:"threats"`
+	DNSQueries     int64          `json:"dns_queries"`
+	TopQueryNames  []queryNameSum `json:"top_query_names"`
+}
+
+// queryNameSum is the number of DNS queries for a name.
+type queryNameSum struct {
+	Name    string `json:"name"`
+	Queries int64  `json:"queries"`
+}
+
+var analyticsFlags = registerFlags("analytics", flagSpec{"since": true, "until": true, "top": true})
+
+func cmdAnalytics(c *cmd.Command, args []string) error {
+	if len(args) > 0 && args[0] == "compare" {
+		return cmdAnalyticsCompare(c, args[1:])
+	}
+
+	flags, args, err := parseFlags(args, analyticsFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	since, err := parseAge(flags.get("since", "24h"))
+	if err != nil {
+		return argError(err)
+	}
+	until, err := parseAge(flags.get("until", "0s"))
+	if err != nil {
+		return argError(err)
+	}
+	if until >= since {
+		return argError(errors.New("--until must be more recent than --since"))
+	}
+	top, err := strconv.Atoi(flags.get("top", strconv.Itoa(defaultAnalyticsTop)))
+	if err != nil || top < 0 {
+		return argError(fmt.Errorf("invalid number of query names %q", flags.get("top", "")))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	s, err := zoneAnalytics(api, zoneID, now.Add(-since), now.Add(-until), top)
+	if err != nil {
+		return err
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(s)
+	}
+	displayAnalytics(s)
+	return nil
+}
+
+// parseAge parses how long ago a time was, as a duration such as 90m or
+// 12h, or a number of days such as 7d.
+func parseAge(s string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(s, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("invalid time %q", s)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid time %q", s)
+	}
+	return d, nil
+}
+
+// analyticsQuery returns the GraphQL query summarizing a zone's traffic
+// over a range of a length.
+func analyticsQuery(length time.Duration) string {
+	dataset := `httpRequests1hGroups(limit: 10000, filter: {datetime_geq: $since, datetime_lt: $until})`
+	if length > analyticsHourlyLimit {
+		dataset = `httpRequests1dGroups(limit: 10000, filter: {date_geq: $sinceDate, date_leq: $untilDate})`
+	}
+	return `query ($zone: string, $since: Time, $until: Time, $sinceDate: Date, $untilDate: Date, $top: uint64) {
+  viewer {
+    zones(filter: {zoneTag: $zone}) {
+      http: ` + dataset + ` {
+        sum { requests cachedRequests bytes cachedBytes threats }
+      }
+      dns: dnsAnalyticsAdaptiveGroups(limit: 1, filter: {datetime_geq: $since, datetime_lt: $until}) {
+        count
+      }
+      names: dnsAnalyticsAdaptiveGroups(limit: $top, filter: {datetime_geq: $since, datetime_lt: $until}, orderBy: [count_DESC]) {
+        count
+        dimensions { queryName }
+      }
+    }
+  }
+}`
+}
+
+// zoneAnalytics summarizes a zone's traffic between two times.
+func zoneAnalytics(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	since, until time.Time, top int) (analyticsSummary, error) {
+
+	s := analyticsSummary{Zone: activeZoneName, Since: since, Until: until}
+	vars := map[string]any{
+		"zone":      zoneID.Identifier,
+		"since":     since.Format(time.RFC3339),
+		"until":     until.Format(time.RFC3339),
+		"sinceDate": since.Format(time.DateOnly),
+		"untilDate": until.Format(time.DateOnly),
+		"top":       max(top, 1),
+	}
+
+	var data struct {
+		Viewer struct {
+			Zones []struct {
+				HTTP []struct {
+					Sum struct {
+						Requests       int64 `json:"requests"`
+						CachedRequests int64 `json:"cachedRequests"`
+						Bytes          int64 `json:"bytes"`
+						CachedBytes    int64 `json:"cachedBytes"`
+						Threats        int64 `json:"threats"`
+					} `json:"sum"`
+				} `json:"http"`
+				DNS []struct {
+					Count int64 `json:"count"`
+				} `json:"dns"`
+				Names []struct {
+					Count      int64 `json:"count"`
+					Dimensions struct {
+						QueryName string `json:"queryName"`
+					} `json:"dimensions"`
+				} `json:"names"`
+			} `json:"zones"`
+		} `json:"viewer"`
+	}
+	if err := graphQL(api, analyticsQuery(until.Sub(since)), vars, &data); err != nil {
+		return s, err
+	}
+
+	for _, z := range data.Viewer.Zones {
+		for _, g := range z.HTTP {
+			s.Requests += g.Sum.Requests
+			s.CachedRequests += g.Sum.CachedRequests
+			s.Bytes += g.Sum.Bytes
+			s.CachedBytes += g.Sum.CachedBytes
+			s.Threats += g.Sum.Threats
+		}
+		for _, g := range z.DNS {
+			s.DNSQueries += g.Count
+		}
+		for _, g := range z.Names {
+			if len(s.TopQueryNames) < top {
+				s.TopQueryNames = append(s.TopQueryNames, queryNameSum{g.Dimensions.QueryName, g.Count})
+			}
+		}
+	}
+	return s, nil
+}
+
+// graphQL runs a query against Cloudflare's GraphQL API, decoding its data
+// into out. The API's responses lack the envelope of its REST responses, so
+// the request is made directly rather than with api.Raw.
+func graphQL(api *cloudflare.API, query string, vars map[string]any, out any) error {
+	body, err := json.Marshal(map[string]any{"query": query, "variables": vars})
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(commandCtx, http.MethodPost, api.BaseURL+"/graphql", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", api.UserAgent)
+	if api.APIToken != "" {
+		req.Header.Set("Authorization", "Bearer "+api.APIToken)
+	} else {
+		req.Header.Set("X-Auth-Email", api.APIEmail)
+		req.Header.Set("X-Auth-Key", api.APIKey)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("GraphQL API returned %s", resp.Status)
+	}
+
+	var result struct {
+		Data   json.RawMessage `json:"data"`
+		Errors []struct {
+			Message string `json:"message"`
+		} `json:"errors"`
+	}
+	if err := json.Unmarshal(b, &result); err != nil {
+		return err
+	}
+	if len(result.Errors) > 0 {
+		var msgs []string
+		for _, e := range result.Errors {
+			msgs = append(msgs, e.Message)
+		}
+		return errors.New(strings.Join(msgs, "; "))
+	}
+	return json.Unmarshal(result.Data, out)
+}
+
+func displayAnalytics(s analyticsSummary) {
+	printf("Analytics for zone %s from %s to %s\n", s.Zone,
+		s.Since.Local().Format("2006-01-02 15:04"), s.Until.Local().Format("2006-01-02 15:04"))
+	fmt.Fprintln(output)
+	printf("Requests:     %d (%s cached)\n", s.Requests, percent(s.CachedRequests, s.Requests))
+	printf("Bandwidth:    %s (%s cached)\n", formatBytes(s.Bytes), percent(s.CachedBytes, s.Bytes))
+	printf("Threats:      %d\n", s.Threats)
+	printf("DNS queries:  %d\n", s.DNSQueries)
+
+	if len(s.TopQueryNames) == 0 {
+		return
+	}
+	fmt.Fprintln(output)
+	printf("Top query names:\n")
+	width := len(strconv.FormatInt(s.TopQueryNames[0].Queries, 10))
+	for _, n := range s.TopQueryNames {
+		fmt.Fprintf(output, "  %*d  %s\n", width, n.Queries, n.Name)
+	}
+}
+
+// percent formats a part of a whole as a percentage.
+func percent(part, whole int64) string {
+	if whole == 0 {
+		return "0.0%"
+	}
+	return fmt.Sprintf("%.1f%%", 100*float64(part)/float64(whole))
+}
+
+// formatBytes formats a number of bytes using binary units.
+func formatBytes(n int64) string {
+	const units = "KMGTPE"
+	if n < 1024 {
+		return fmt.Sprintf("%d B", n)
+	}
+	f, i := float64(n)/1024, 0
+	for f >= 1024 && i < len(units)-1 {
+		f /= 1024
+		i++
+	}
+	return fmt.Sprintf("%.1f %ciB", f, units[i])
+}
+
+// An analyticsComparison compares a zone's traffic over a period with its
+// traffic over an earlier period of the same length. Changes counts the
+// record changes cf made in the zone during the later period.
+type analyticsComparison struct {
+	Zone       string           `json:"zone"`
+	Previous   analyticsSummary `json:"previous"`
+	Current    analyticsSummary `json:"current"`
+	Deltas     []analyticsDelta `json:"deltas"`
+	Changes    int              `json:"record_changes"`
+	LastChange *time.Time       `json:"last_record_change,omitempty"`
+}
+
+// An analyticsDelta is the change of one measure between two periods: a
+// percentage for counts, and percentage points for the cache ratio.
+type analyticsDelta struct {
+	Measure   string  `json:"measure"`
+	Previous  string  `json:"previous"`
+	Current   string  `json:"current"`
+	Change    float64 `json:"change"`
+	Unit      string  `json:"unit"` // % or pts
+	Anomalous bool    `json:"anomalous"`
+}
+
+var analyticsCompareFlags = registerFlags("analytics compare", mergeFlags(zoneFlags,
+	flagSpec{"period": true, "offset": true, "threshold": true}))
+
+// cmdAnalyticsCompare compares the traffic of zones over the last period
+// with their traffic over the same length of time offset earlier, such as
+// this week with last week.
+func cmdAnalyticsCompare(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, analyticsCompareFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	period, err := parseAge(flags.get("period", "7d"))
+	if err != nil || period == 0 {
+		return argError(fmt.Errorf("invalid period %q", flags.get("period", "")))
+	}
+	offset, err := parseAge(flags.get("offset", flags.get("period", "7d")))
+	if err != nil || offset == 0 {
+		return argError(fmt.Errorf("invalid offset %q", flags.get("offset", "")))
+	}
+	threshold, err := strconv.ParseFloat(flags.get("threshold", strconv.Itoa(defaultCompareThreshold)), 64)
+	if err != nil || threshold <= 0 {
+		return argError(fmt.Errorf("invalid threshold %q", flags.get("threshold", "")))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+	changes, err := journalChanges()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC().Truncate(time.Minute)
+	var comparisons []analyticsComparison
+	for _, z := range zones {
+		current, err := zoneAnalytics(api, z.id, now.Add(-period), now, 0)
+		if err != nil {
+			return fmt.Errorf("%s: %v", z.name, err)
+		}
+		previous, err := zoneAnalytics(api, z.id, now.Add(-offset-period), now.Add(-offset), 0)
+		if err != nil {
+			return fmt.Errorf("%s: %v", z.name, err)
+		}
+		current.Zone, previous.Zone = z.name, z.name
+		cmp := compareAnalytics(previous, current, threshold)
+		for _, e := range changes {
+			if e.ZoneID == z.id.Identifier && !e.Time.Before(current.Since) {
+				cmp.Changes++
+				t := e.Time
+				cmp.LastChange = &t
+			}
+		}
+		comparisons = append(comparisons, cmp)
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(comparisons)
+	}
+	for i, cmp := range comparisons {
+		if i > 0 {
+			fmt.Fprintln(output)
+		}
+		displayComparison(cmp, period, offset)
+	}
+	return nil
+}
+
+// compareAnalytics compares a zone's traffic over two periods, flagging
+// the measures that changed by the threshold percentage or more.
+func compareAnalytics(previous, current analyticsSummary, threshold float64) analyticsComparison {
+	cmp := analyticsComparison{Zone: current.Zone, Previous: previous, Current: current}
+	count := func(measure string, prev, cur int64, format func(int64) string) {
+		d := analyticsDelta{Measure: measure, Previous: format(prev), Current: format(cur), Unit: "%"}
+		switch {
+		case prev != 0:
+			d.Change = 100 * float64(cur-prev) / float64(prev)
+			d.Anomalous = math.Abs(d.Change) >= threshold
+		case cur != 0:
+			d.Change = 100
+			d.Anomalous = true
+		}
+		cmp.Deltas = append(cmp.Deltas, d)
+	}
+	formatCount := func(n int64) string { return strconv.FormatInt(n, 10) }
+	count("Requests", previous.Requests, current.Requests, formatCount)
+	count("Bandwidth", previous.Bytes, current.Bytes, formatBytes)
+	count("Threats", previous.Threats, current.Threats, formatCount)
+
+	ratio := func(s analyticsSummary) float64 {
+		if s.Requests == 0 {
+			return 0
+		}
+		return 100 * float64(s.CachedRequests) / float64(s.Requests)
+	}
+	d := analyticsDelta{
+		Measure:  "Cache ratio",
+		Previous: percent(previous.CachedRequests, previous.Requests),
+		Current:  percent(current.CachedRequests, current.Requests),
+		Change:   ratio(current) - ratio(previous),
+		Unit:     "pts",
+	}
+	d.Anomalous = previous.Requests != 0 && current.Requests != 0 && math.Abs(d.Change) >= cacheRatioAnomaly
+	cmp.Deltas = append(cmp.Deltas, d)
+	return cmp
+}
+
+// journalChanges returns the record changes in the journal.
+func journalChanges() ([]journalEntry, error) {
+	var entries []journalEntry
+	err := withStateLock(func() error {
+		path, err := statePath("journal")
+		if err != nil {
+			return err
+		}
+		entries, err = readJournal(path)
+		return err
+	})
+	return entries, err
+}
+
+func displayComparison(cmp analyticsComparison, period, offset time.Duration) {
+	printf("Zone %s: the last %s compared with %s earlier\n", cmp.Zone, formatAge(period), formatAge(offset))
+	fmt.Fprintln(output)
+	fmt.Fprintf(output, "%-12s %14s %14s %12s\n", tr("Measure"), tr("Previous"), tr("Current"), tr("Change"))
+	anomalies := 0
+	for _, d := range cmp.Deltas {
+		flag := ""
+		if d.Anomalous {
+			flag = " !"
+			anomalies++
+		}
+		change := fmt.Sprintf("%+.1f%%", d.Change)
+		if d.Unit == "pts" {
+			change = fmt.Sprintf("%+.1f pts", d.Change)
+		}
+		fmt.Fprintf(output, "%-12s %14s %14s %12s%s\n", tr(d.Measure), d.Previous, d.Current, change, flag)
+	}
+	if anomalies == 0 {
+		return
+	}
+	fmt.Fprintln(output)
+	if cmp.Changes == 0 {
+		printf("No record changes were made with cf in this period.\n")
+		return
+	}
+	printf("%d record change(s) were made with cf in this period, the last at %s; see \"undo --list\".\n",
+		cmp.Changes, cmp.LastChange.Local().Format("2006-01-02 15:04"))
+}
+
+// formatAge formats a duration parsed by parseAge, in days if it is a
+// whole number of them.
+func formatAge(d time.Duration) string {
+	if d%(24*time.Hour) == 0 {
+		return fmt.Sprintf("%dd", d/(24*time.Hour))
+	}
+	return d.String()
+}
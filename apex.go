@@ -0,0 +1,62 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/beevik/cmd"
+)
+
+var apexFlags = registerFlags("apex", flagSpec{"www": false})
+
+func cmdApex(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, apexFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || len(args) > 2 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	// The apex records are set in the order A, AAAA, www, skipping an
+	// address that was not given.
+	zone := activeZoneName
+	type change struct{ recType, name, content string }
+	changes := []change{{"A", zone, args[0]}}
+	if len(args) == 2 {
+		changes = append(changes, change{"AAAA", zone, args[1]})
+	}
+	if flags.has("www") {
+		changes = append(changes, change{"CNAME", "www." + zone, zone})
+	}
+	for _, ch := range changes {
+		if err := validateRecord(ch.recType, ch.name, ch.content); err != nil {
+			return argError(err)
+		}
+	}
+
+	proxied := true
+	for _, ch := range changes {
+		changed, err := upsertRecord(api, zoneID, ch.recType, ch.name, ch.content, 0, recordMeta{proxied: &proxied})
+		if err != nil {
+			return err
+		}
+		if changed {
+			printf("Set %s record %s to %s.\n", ch.recType, ch.name, ch.content)
+		} else {
+			printf("%s record %s is already %s.\n", ch.recType, ch.name, ch.content)
+		}
+	}
+	return nil
+}
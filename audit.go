@@ -0,0 +1,136 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// auditLogPath is the file to which every change made through cf is
+// logged, one JSON object per line. Logging is disabled if it is empty.
+var auditLogPath string
+
+// An auditEntry is a line of the audit log. Each record change attempted
+// by a command is logged, followed by the command's exit status.
+type auditEntry struct {
+	Time    time.Time             `json:"time"`
+	Command string                `json:"command,omitempty"`
+	Zone    string                `json:"zone,omitempty"`
+	Action  string                `json:"action"` // create, update, delete or exit
+	Before  *cloudflare.DNSRecord `json:"before,omitempty"`
+	After   *cloudflare.DNSRecord `json:"after,omitempty"`
+	Error   string                `json:"error,omitempty"`
+	Status  *int                  `json:"exit_status,omitempty"`
+}
+
+var audit struct {
+	mu      sync.Mutex
+	command string // the command being processed
+	changes int    // the number of changes it has logged
+}
+
+// auditChange logs an attempted record change. Failures to write the log
+// are reported but do not fail the change.
+func auditChange(zoneID, action string, before, after *cloudflare.DNSRecord, err error) {
+	if auditLogPath == "" {
+		return
+	}
+	e := auditEntry{
+		Zone:   zoneName(zoneID),
+		Action: action,
+		Before: before,
+		After:  after,
+	}
+	if err != nil {
+		e.Error = err.Error()
+	}
+
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	audit.changes++
+	writeAudit(e)
+}
+
+// auditCommand runs a command, logging its exit status if it attempted any
+// record changes.
+func auditCommand(line string, fn func() error) error {
+	audit.mu.Lock()
+	audit.command, audit.changes = line, 0
+	audit.mu.Unlock()
+
+	err := fn()
+
+	audit.mu.Lock()
+	defer audit.mu.Unlock()
+	if auditLogPath != "" && audit.changes > 0 {
+		status := exitCode(err)
+		writeAudit(auditEntry{Action: "exit", Status: &status})
+	}
+	audit.command, audit.changes = "", 0
+	return err
+}
+
+// writeAudit appends an entry to the audit log. The audit mutex must be
+// held.
+func writeAudit(e auditEntry) {
+	e.Time = time.Now().UTC()
+	e.Command = audit.command
+	line, err := json.Marshal(e)
+	if err != nil {
+		printf("Unable to write the audit log: %v\n", err)
+		return
+	}
+
+	f, err := os.OpenFile(auditLogPath, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+	if err == nil {
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+	}
+	if err != nil {
+		printf("Unable to write the audit log: %v\n", err)
+	}
+}
+
+// zoneName returns the name of a zone, or its ID if the name cannot be
+// found.
+func zoneName(zoneID string) string {
+	name, err := lookupZoneName(commandCtx, zoneID)
+	if err != nil {
+		return zoneID
+	}
+	return name
+}
+
+// zoneNames caches the names of zones looked up by ID.
+var zoneNames sync.Map
+
+// lookupZoneName returns the name of the zone with an ID.
+func lookupZoneName(ctx context.Context, zoneID string) (string, error) {
+	if activeZoneIdentifier != nil && activeZoneIdentifier.Identifier == zoneID {
+		return activeZoneName, nil
+	}
+	if name, ok := zoneNames.Load(zoneID); ok {
+		return name.(string), nil
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return "", err
+	}
+	zone, err := api.ZoneDetails(ctx, zoneID)
+	if err != nil {
+		return "", err
+	}
+	zoneNames.Store(zoneID, zone.Name)
+	return zone.Name, nil
+}
@@ -0,0 +1,144 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// backupTimeFormat is the UTC timestamp in the names of backup files, which
+// sorts in chronological order.
+const backupTimeFormat = "20060102T150405Z"
+
+var backupFlags = registerFlags("backup", flagSpec{"dir": true, "keep": true, "settings": false})
+
+func cmdBackup(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, backupFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 || !flags.has("dir") {
+		return usageError(c)
+	}
+
+	keep := 0
+	if flags.has("keep") {
+		keep, err = strconv.Atoi(flags.get("keep", ""))
+		if err != nil || keep < 1 {
+			return argError(fmt.Errorf("invalid number of backups to keep %q", flags.get("keep", "")))
+		}
+	}
+
+	dir := flags.get("dir", "")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := api.ListZones(commandCtx)
+	if err != nil {
+		return err
+	}
+
+	stamp := time.Now().UTC().Format(backupTimeFormat)
+	failed := 0
+	for _, z := range zones {
+		if commandCtx.Err() != nil {
+			return errInterrupted
+		}
+
+		path := filepath.Join(dir, z.Name+"-"+stamp+".zone")
+		zoneFile, err := api.ExportDNSRecords(commandCtx, cloudflare.ZoneIdentifier(z.ID),
+			cloudflare.ExportDNSRecordsParams{})
+		if err == nil {
+			err = writeStateFile(path, []byte(zoneFile))
+		}
+		if err != nil {
+			printf("Error backing up zone %s: %v\n", z.Name, err)
+			failed++
+			continue
+		}
+		printf("Backed up zone %s to %s.\n", z.Name, path)
+
+		if flags.has("settings") {
+			path := filepath.Join(dir, z.Name+"-"+stamp+settingsSuffix)
+			snapshot := takeSettingsSnapshot(api, z.Name, z.ID)
+			for _, n := range snapshot.Notes {
+				printf("Warning: %s.\n", n)
+			}
+			data, err := json.MarshalIndent(snapshot, "", "  ")
+			if err == nil {
+				err = writeStateFile(path, append(data, '\n'))
+			}
+			if err != nil {
+				printf("Error backing up the settings of zone %s: %v\n", z.Name, err)
+				failed++
+				continue
+			}
+			printf("Backed up the settings of zone %s to %s.\n", z.Name, path)
+		}
+
+		if keep > 0 {
+			err := pruneBackups(dir, z.Name, ".zone", keep)
+			if err == nil {
+				err = pruneBackups(dir, z.Name, settingsSuffix, keep)
+			}
+			if err != nil {
+				printf("Error removing old backups of zone %s: %v\n", z.Name, err)
+				failed++
+			}
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d zone(s) could not be backed up", failed, len(zones))
+	}
+	return nil
+}
+
+// pruneBackups removes all but the newest keep backups of a zone with a
+// suffix from a directory.
+func pruneBackups(dir, zone, suffix string, keep int) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	var backups []string
+	for _, e := range entries {
+		stamp, ok := strings.CutPrefix(e.Name(), zone+"-")
+		if !ok || e.IsDir() {
+			continue
+		}
+		stamp, ok = strings.CutSuffix(stamp, suffix)
+		if _, err := time.Parse(backupTimeFormat, stamp); !ok || err != nil {
+			continue
+		}
+		backups = append(backups, e.Name())
+	}
+
+	sort.Strings(backups)
+	for len(backups) > keep {
+		if err := os.Remove(filepath.Join(dir, backups[0])); err != nil {
+			return err
+		}
+		backups = backups[1:]
+	}
+	return nil
+}
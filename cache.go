@@ -0,0 +1,288 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A cacheTransport caches the responses to successful API GET requests, so
+// that a command listing the same records twice, such as import when it
+// plans its changes and again when it applies them, requests them once.
+// Cached responses are reused without a request only by the command that
+// received them; later commands revalidate them with If-None-Match when
+// the API provided an ETag. Zone lookups and record lists are an
+// exception: they are reused without a request for cacheTTL after they
+// were received, and with the disk_cache setting, they are kept in the
+// state directory for later cf processes. Any other request may change
+// what the API returns, so it empties the cache.
+type cacheTransport struct {
+	base http.RoundTripper
+
+	mu      sync.Mutex
+	entries map[string]*cacheEntry
+	writes  uint64 // requests other than GET sent so far
+}
+
+type cacheEntry struct {
+	generation uint64
+	fetched    time.Time
+	etag       string
+	header     http.Header
+	body       []byte
+}
+
+// defaultCacheTTL is how long zone lookups and record lists stay fresh in
+// interactive sessions.
+const defaultCacheTTL = 30 * time.Second
+
+// cacheTTL is how long zone lookups and record lists are reused without
+// asking the API. It is set by "set cache" and the cache_ttl setting.
+var cacheTTL time.Duration
+
+// listPath matches the paths of zone lookups and record lists, whose
+// responses are kept for cacheTTL.
+var listPath = regexp.MustCompile(`/zones(/[^/]+/dns_records)?$`)
+
+var (
+	cacheMu         sync.Mutex
+	cacheGeneration uint64
+	cacheRefreshed  time.Time // responses fetched earlier are not fresh
+)
+
+// forgetResponses makes the responses cached so far stale, so that the API
+// is asked for them again, other than zone lookups and record lists still
+// within cacheTTL. It is called as each command starts.
+func forgetResponses() {
+	cacheMu.Lock()
+	cacheGeneration++
+	cacheMu.Unlock()
+}
+
+// refreshResponses makes every response cached so far stale, including
+// zone lookups and record lists. It is called by refresh, and by commands
+// that poll the API.
+func refreshResponses() {
+	cacheMu.Lock()
+	cacheGeneration++
+	cacheRefreshed = time.Now()
+	cacheMu.Unlock()
+}
+
+func currentGeneration() (uint64, time.Time) {
+	cacheMu.Lock()
+	defer cacheMu.Unlock()
+	return cacheGeneration, cacheRefreshed
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet {
+		t.forget()
+		defer t.forget()
+		return t.base.RoundTrip(req)
+	}
+
+	key := req.URL.String()
+	list := listPath.MatchString(req.URL.Path)
+	gen, refreshed := currentGeneration()
+	t.mu.Lock()
+	e, writes := t.entries[key], t.writes
+	t.mu.Unlock()
+	if e == nil && list && cfg.DiskCache {
+		e = readCachedResponse(req)
+	}
+
+	fresh := e != nil && e.fetched.After(refreshed) &&
+		(e.generation == gen || (list && time.Since(e.fetched) < cacheTTL))
+	if fresh {
+		return e.response(req), nil
+	}
+	if e != nil && e.etag != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("If-None-Match", e.etag)
+	}
+
+	resp, err := t.base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch {
+	case resp.StatusCode == http.StatusNotModified && e != nil:
+		resp.Body.Close()
+		e = &cacheEntry{gen, time.Now(), e.etag, e.header, e.body}
+		t.store(req, key, list, e, writes)
+		return e.response(req), nil
+
+	case resp.StatusCode == http.StatusOK:
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		e = &cacheEntry{gen, time.Now(), resp.Header.Get("ETag"), resp.Header.Clone(), body}
+		t.store(req, key, list, e, writes)
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+	return resp, nil
+}
+
+// forget empties the cache, before and after a request that may change
+// what the API returns.
+func (t *cacheTransport) forget() {
+	t.mu.Lock()
+	t.entries = nil
+	t.writes++
+	t.mu.Unlock()
+	if cfg.DiskCache {
+		removeCachedResponses()
+	}
+}
+
+// store caches the response to a GET request, unless a request changing
+// what the API returns was sent since the GET request was.
+func (t *cacheTransport) store(req *http.Request, key string, list bool, e *cacheEntry, writes uint64) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.writes != writes {
+		return
+	}
+	if t.entries == nil {
+		t.entries = make(map[string]*cacheEntry)
+	}
+	t.entries[key] = e
+	if list && cfg.DiskCache {
+		writeCachedResponse(req, e)
+	}
+}
+
+// response returns a copy of the cached response to a request.
+func (e *cacheEntry) response(req *http.Request) *http.Response {
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        e.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(e.body)),
+		ContentLength: int64(len(e.body)),
+		Request:       req,
+	}
+}
+
+// A diskCacheEntry is a response kept in the state directory.
+type diskCacheEntry struct {
+	URL     string      `json:"url"`
+	Fetched time.Time   `json:"fetched"`
+	ETag    string      `json:"etag,omitempty"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+}
+
+// cachedResponsePath returns the path of the file keeping the response to a
+// request. Files are named after the request's URL and credentials, so
+// that one profile is never shown the records another profile read.
+func cachedResponsePath(req *http.Request) (string, error) {
+	h := sha256.New()
+	for _, name := range []string{"Authorization", "X-Auth-Email", "X-Auth-Key"} {
+		fmt.Fprintf(h, "%s\x00", req.Header.Get(name))
+	}
+	io.WriteString(h, req.URL.String())
+	return statePath("cache", hex.EncodeToString(h.Sum(nil))+".json")
+}
+
+// readCachedResponse returns the response to a request kept in the state
+// directory, or nil. Unreadable files are ignored.
+func readCachedResponse(req *http.Request) *cacheEntry {
+	path, err := cachedResponsePath(req)
+	if err != nil {
+		return nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var d diskCacheEntry
+	if json.Unmarshal(data, &d) != nil || d.URL != req.URL.String() {
+		return nil
+	}
+	return &cacheEntry{fetched: d.Fetched, etag: d.ETag, header: d.Header, body: d.Body}
+}
+
+// writeCachedResponse keeps the response to a request in the state
+// directory. Failures leave the response cached in memory only.
+func writeCachedResponse(req *http.Request, e *cacheEntry) {
+	path, err := cachedResponsePath(req)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(diskCacheEntry{req.URL.String(), e.fetched, e.etag, e.header, e.body})
+	if err != nil {
+		return
+	}
+	writeStateFile(path, data)
+}
+
+// removeCachedResponses removes the responses kept in the state directory.
+func removeCachedResponses() {
+	if dir, err := stateDir(); err == nil {
+		os.RemoveAll(filepath.Join(dir, "cache"))
+	}
+}
+
+// parseCacheTTL parses a cache lifetime, or "off".
+func parseCacheTTL(s string) (time.Duration, error) {
+	if s == "off" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, argError(fmt.Errorf("invalid cache lifetime %q", s))
+	}
+	return d, nil
+}
+
+// cmdRefresh discards the cached responses, so that the next commands ask
+// the API for the zone and its records again, and looks up the ID of the
+// active zone again.
+func cmdRefresh(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	refreshResponses()
+	removeCachedResponses()
+
+	if activeZoneIdentifier != nil {
+		api, err := getAPI()
+		if err != nil {
+			return err
+		}
+		zoneID, err := recordBackend(api).ZoneIDByName(activeZoneName)
+		if err != nil {
+			return zoneError(err)
+		}
+		activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
+	}
+
+	printf("Cached zones and records discarded.\n")
+	return nil
+}
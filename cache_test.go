@@ -0,0 +1,117 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var requests []string
+	version := 1
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.Header.Get("If-None-Match"))
+		if r.Method != http.MethodGet {
+			version++
+			return
+		}
+		etag := `"v` + string(rune('0'+version)) + `"`
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		io.WriteString(w, etag)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &cacheTransport{base: http.DefaultTransport}}
+	get := func() string {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	forgetResponses()
+	got := []string{get(), get()}
+	forgetResponses()
+	got = append(got, get())
+	resp, err := client.Post(server.URL, "text/plain", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	got = append(got, get())
+
+	want := []string{`"v1"`, `"v1"`, `"v1"`, `"v2"`}
+	wantRequests := []string{"GET ", `GET "v1"`, "POST ", "GET "}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("response %d = %s, want %s", i, got[i], want[i])
+		}
+	}
+	if len(requests) != len(wantRequests) {
+		t.Fatalf("requests = %q, want %q", requests, wantRequests)
+	}
+	for i := range wantRequests {
+		if requests[i] != wantRequests[i] {
+			t.Errorf("request %d = %q, want %q", i, requests[i], wantRequests[i])
+		}
+	}
+}
+
+func TestCacheTTL(t *testing.T) {
+	t.Setenv("CLOUDFLARE_STATE_DIR", t.TempDir())
+	savedTTL, savedCfg := cacheTTL, cfg
+	defer func() { cacheTTL, cfg = savedTTL, savedCfg }()
+	cacheTTL, cfg = time.Minute, &config{DiskCache: true}
+
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		io.WriteString(w, r.URL.Path)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: &cacheTransport{base: http.DefaultTransport}}
+	get := func(path string) {
+		resp, err := client.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != path {
+			t.Errorf("response = %s, want %s", body, path)
+		}
+	}
+
+	records, settings := "/zones/z1/dns_records", "/zones/z1/settings"
+	get(records)
+	get(settings)
+	forgetResponses()
+	get(records)
+	get(settings)
+
+	// A new process reads the records kept on disk.
+	client.Transport = &cacheTransport{base: http.DefaultTransport}
+	get(records)
+	refreshResponses()
+	get(records)
+
+	want := []string{"GET " + records, "GET " + settings, "GET " + settings, "GET " + records}
+	if !slices.Equal(requests, want) {
+		t.Errorf("requests = %q, want %q", requests, want)
+	}
+}
@@ -0,0 +1,80 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// planRanks orders the plans by the features they include, keyed by the
+// legacy plan IDs reported in zone details.
+var planRanks = map[string]int{
+	"free":       0,
+	"pro":        1,
+	"business":   2,
+	"enterprise": 3,
+}
+
+// planNames describes the plans including those of a rank and above.
+var planNames = map[string]string{
+	"pro":        "a Pro, Business or Enterprise",
+	"business":   "a Business or Enterprise",
+	"enterprise": "an Enterprise",
+}
+
+// A capability is an API feature offered only on some plans. Commands check
+// the zone's plan for it before calling the API, whose errors for features
+// a plan lacks don't say which plan is needed.
+type capability struct {
+	feature string
+	plan    string // the least plan offering the feature
+}
+
+var (
+	capPurgeByTag    = capability{"Purging the cache by tag", "enterprise"}
+	capPurgeByHost   = capability{"Purging the cache by hostname", "enterprise"}
+	capPurgeByPrefix = capability{"Purging the cache by prefix", "enterprise"}
+	capRegexRules    = capability{"Regular expression matching in rules", "business"}
+	capFoundationDNS = capability{"Foundation DNS", "enterprise"}
+	capZoneNS        = capability{"Zone custom nameservers", "business"}
+	capCustomCerts   = capability{"Custom SSL certificates", "business"}
+)
+
+// capabilities lists the capabilities displayed by limits.
+var capabilities = []capability{
+	capPurgeByTag, capPurgeByHost, capPurgeByPrefix,
+	capRegexRules, capFoundationDNS, capZoneNS, capCustomCerts,
+}
+
+// A capabilityError reports a feature missing from a zone's plan.
+type capabilityError struct {
+	capability
+	zone, zonePlan string
+}
+
+func (e *capabilityError) Error() string {
+	return fmt.Sprintf("%s requires %s plan; zone %s has the %s plan",
+		e.feature, planNames[e.plan], e.zone, e.zonePlan)
+}
+
+// hasCapability reports whether a zone's plan, given by its legacy ID,
+// offers a feature. Unknown plans, such as those of partner accounts, are
+// assumed to.
+func hasCapability(plan string, c capability) bool {
+	rank, ok := planRanks[plan]
+	return !ok || rank >= planRanks[c.plan]
+}
+
+// requireCapability returns an error if the plan of a zone lacks a feature.
+// If the plan cannot be read, the API is left to decide.
+func requireCapability(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, c capability) error {
+	zone, err := api.ZoneDetails(commandCtx, zoneID.Identifier)
+	if err != nil || hasCapability(zone.Plan.LegacyID, c) {
+		return nil
+	}
+	return &capabilityError{c, zone.Name, zone.Plan.Name}
+}
@@ -0,0 +1,413 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+func init() {
+	catalogs["de"] = map[string]string{
+		"y":   "j",
+		"yes": "ja",
+
+		"%s [y/N] n (standard input is not a terminal)\n": "%s [j/N] n (Standardeingabe ist kein Terminal)\n",
+
+		"  %s: no answer (%v)\n":                                                                       "  %s: keine Antwort (%v)\n",
+		"%d %s records named %s exist:\n":                                                              "Es gibt %d %s-Einträge namens %s:\n",
+		"%d alias(es), %d chain(s), %d with problems.\n":                                               "%d Alias(e), %d Kette(n), %d mit Problemen.\n",
+		"%d change(s) applied.\n":                                                                      "%d Änderung(en) angewendet.\n",
+		"%d change(s) remain in the retry queue.\n":                                                    "%d Änderung(en) verbleiben in der Wiederholungswarteschlange.\n",
+		"%d failed change(s) saved; run \"retry run\" to reattempt them.\n":                            "%d fehlgeschlagene Änderung(en) gespeichert; mit \"retry run\" erneut versuchen.\n",
+		"%d of %d proxied hostname(s) are not covered by an edge certificate:\n":                       "%d von %d Proxy-Hostnamen sind nicht durch ein Edge-Zertifikat abgedeckt:\n",
+		"%d record change(s) were made with cf in this period, the last at %s; see \"undo --list\".\n": "In diesem Zeitraum wurden mit cf %d Einträge geändert, zuletzt am %s; siehe \"undo --list\".\n",
+		"%d record(s) added, %d record(s) removed.\n":                                                  "%d Eintrag/Einträge hinzugefügt, %d Eintrag/Einträge entfernt.\n",
+		"%d record(s) deleted.\n":                                                                      "%d Eintrag/Einträge gelöscht.\n",
+		"%d record(s) in %d group(s)\n":                                                                "%d Eintrag/Einträge in %d Gruppe(n)\n",
+		"%d record(s) in %d zone(s) changed from %s to %s.\n":                                          "%d Eintrag/Einträge in %d Zone(n) von %s auf %s geändert.\n",
+		"%d record(s)\n":         "%d Eintrag/Einträge\n",
+		"%d warning(s) found.\n": "%d Warnung(en) gefunden.\n",
+		"%d worker(s) received no requests in the last %s: %s\n":        "%d Worker erhielten in den letzten %s keine Anfragen: %s\n",
+		"%s [y/N] y (confirmations are off)\n":                          "%s [j/N] j (Rückfragen sind ausgeschaltet)\n",
+		"%s record %s already exists.\n":                                "%s-Eintrag %s existiert bereits.\n",
+		"%s record %s changed in the zone while it was being edited.\n": "%s-Eintrag %s wurde in der Zone geändert, während er bearbeitet wurde.\n",
+		"%s record %s is already %s.\n":                                 "%s-Eintrag %s ist bereits %s.\n",
+		"%s records named %s are already protected.\n":                  "%s-Einträge namens %s sind bereits geschützt.\n",
+		"%s: %d change(s) in zone %s\n":                                 "%s: %d Änderung(en) in Zone %s\n",
+		"%s: error listing records: %v\n":                               "%s: Fehler beim Auflisten der Einträge: %v\n",
+		"%s: no answer (%v)\n":                                          "%s: keine Antwort (%v)\n",
+		"%s: not visible\n":                                             "%s: nicht sichtbar\n",
+		"%s: visible\n":                                                 "%s: sichtbar\n",
+		"(deleted)":                                                     "(gelöscht)",
+		"(external)":                                                    "(extern)",
+		"(loop)":                                                        "(Schleife)",
+		"(no records)":                                                  "(keine Einträge)",
+		"(proxied)":                                                     "(über Proxy)",
+		"(proxied, TTL %s)":                                             "(über Proxy, TTL %s)",
+		"[Enter/s/q] ":                                                  "[Eingabe/s/q] ",
+		"[k]eep the zone's record, [a]pply yours, [s]kip or [e]dit? ": "[k] Eintrag der Zone behalten, [a] Ihren anwenden, [s] überspringen oder [e] bearbeiten? ",
+		"\n1. Credentials. cf uses an API token from a profile, the\nCLOUDFLARE_API_TOKEN environment variable or the system keyring, and\nasks for one if there is none. \"account verify\" checks it.\n": "\n1. Zugangsdaten. cf verwendet ein API-Token aus einem Profil, der\nUmgebungsvariable CLOUDFLARE_API_TOKEN oder dem Schlüsselbund des\nSystems und fragt nach einem, wenn keines vorhanden ist. \"account\nverify\" prüft es.\n",
+		"\n2. Zones. Every domain on Cloudflare is a zone. \"zones\" lists the zones\nthe credentials can access.\n":                                                                                       "\n2. Zonen. Jede Domain bei Cloudflare ist eine Zone. \"zones\" listet die\nZonen auf, auf die die Zugangsdaten zugreifen können.\n",
+		"\n3. The active zone. Record commands work on the active zone, selected\nwith \"zone <name>\". Choose a zone where a temporary test record does no\nharm.\n":                                      "\n3. Die aktive Zone. Befehle für Einträge wirken auf die aktive Zone, die\nmit \"zone <Name>\" ausgewählt wird. Wählen Sie eine Zone, in der ein\nvorübergehender Testeintrag keinen Schaden anrichtet.\n",
+		"\n4. Listing records. \"list\" displays the zone's records; \"list TXT\" would\ndisplay only its TXT records.\n":                                                                                  "\n4. Einträge auflisten. \"list\" zeigt die Einträge der Zone an; \"list TXT\"\nwürde nur ihre TXT-Einträge anzeigen.\n",
+		"\n5. Creating a record. \"txt\" creates a TXT record, or updates the record\nwith the name. The test record's name is new, so no existing record\nchanges; the tutorial deletes it at the end.\n": "\n5. Einen Eintrag erstellen. \"txt\" erstellt einen TXT-Eintrag oder\naktualisiert den Eintrag mit dem Namen. Der Name des Testeintrags ist\nneu, daher ändert sich kein vorhandener Eintrag; das Tutorial löscht ihn\nam Ende.\n",
+		"\n6. Inspecting a record. \"show\" displays every field of the records with\na type and name.\n":                                                                                                  "\n6. Einen Eintrag untersuchen. \"show\" zeigt alle Felder der Einträge mit\neinem Typ und Namen an.\n",
+		"\n7. Deleting a record. \"delete\" lists the records it would delete and\nasks first, unless --force is given.\n":                                                                                 "\n7. Einen Eintrag löschen. \"delete\" listet die Einträge auf, die gelöscht\nwürden, und fragt zuerst nach, sofern --force nicht angegeben ist.\n",
+		"\nDeleting the test record %s.\n": "\nLösche den Testeintrag %s.\n",
+		"\nThat's all. \"help\" lists every command, and \"help <command>\" describes\none. \"undo\" reverts the last change made by cf.\n":                        "\nDas war alles. \"help\" listet alle Befehle auf, und \"help <Befehl>\"\nbeschreibt einen. \"undo\" macht die letzte Änderung von cf rückgängig.\n",
+		"A conflicting record already exists. \"get <type> <name>\" shows it; use \"update\" to change it, or \"upsert\" to create or update records in one step.": "Ein widersprechender Eintrag existiert bereits. \"get <type> <name>\" zeigt ihn an; ändern Sie ihn mit \"update\", oder erstellen oder aktualisieren Sie Einträge in einem Schritt mit \"upsert\".",
+		"A global API key has every permission of user %s.\n":              "Ein globaler API-Schlüssel hat alle Berechtigungen des Benutzers %s.\n",
+		"Accessible accounts:\n":                                           "Zugängliche Konten:\n",
+		"Accessible zones:\n":                                              "Zugängliche Zonen:\n",
+		"Active profile set to %s.\n":                                      "Aktives Profil ist jetzt %s.\n",
+		"Active zone cleared.\n":                                           "Aktive Zone zurückgesetzt.\n",
+		"Active zone set to %v.\n":                                         "Aktive Zone ist jetzt %v.\n",
+		"Add this secret to your authenticator app:\n":                     "Fügen Sie dieses Geheimnis Ihrer Authenticator-App hinzu:\n",
+		"Added custom hostname %s.\n":                                      "Benutzerdefinierten Hostnamen %s hinzugefügt.\n",
+		"Added route %s for %s to worker %s.\n":                            "Route %s für %s zu Worker %s hinzugefügt.\n",
+		"Adopt %s %s from external-dns owner %s?":                          "%s %s vom external-dns-Eigentümer %s übernehmen?",
+		"Adopted %s %s.\n":                                                 "%s %s übernommen.\n",
+		"AI crawlers:        %s\n":                                         "KI-Crawler:         %s\n",
+		"All %d proxied hostname(s) are covered by an edge certificate.\n": "Alle %d Proxy-Hostnamen sind durch ein Edge-Zertifikat abgedeckt.\n",
+		"Algorithm:        %s\n":                                           "Algorithmus:            %s\n",
+		"also serves %s, which is not configured; it may be cached":        "liefert auch %s, das nicht konfiguriert ist; womöglich zwischengespeichert",
+		"Analytics for zone %s from %s to %s\n":                            "Analysen für Zone %s von %s bis %s\n",
+		"Applied %s of %s record %s.\n":                                    "%s des %s-Eintrags %s ausgeführt.\n",
+		"Apply %d change(s)?":                                              "%d Änderung(en) anwenden?",
+		"Assigned nameservers:\n":                                          "Zugewiesene Nameserver:\n",
+		"Backed up the settings of zone %s to %s.\n":                       "Einstellungen der Zone %s in %s gesichert.\n",
+		"Backed up zone %s to %s.\n":                                       "Zone %s in %s gesichert.\n",
+		"Bandwidth":                                                        "Bandbreite",
+		"Bandwidth:    %s (%s cached)\n":                                   "Bandbreite:   %s (%s aus dem Cache)\n",
+		"Cache lifetime set to %s.\n":                                      "Cache-Lebensdauer auf %s gesetzt.\n",
+		"Cache not purged.\n":                                              "Cache nicht geleert.\n",
+		"Cache ratio":                                                      "Cache-Anteil",
+		"Cached zones and records discarded.\n":                            "Zwischengespeicherte Zonen und Einträge verworfen.\n",
+		"Certificate %s has priority %d.\n":                                "Zertifikat %s hat die Priorität %d.\n",
+		"Certificate not deleted.\n":                                       "Zertifikat nicht gelöscht.\n",
+		"Certificate not revoked.\n":                                       "Zertifikat nicht widerrufen.\n",
+		"cf is up to date.\n":                                              "cf ist auf dem neuesten Stand.\n",
+		"Change %d record(s)?":                                             "%d Eintrag/Einträge ändern?",
+		"Change the workers.dev subdomain from %s to %s? Workers will no longer be reachable at %s.workers.dev.": "Die workers.dev-Subdomain von %s in %s ändern? Worker sind dann nicht mehr unter %s.workers.dev erreichbar.",
+		"Change": "Änderung",
+		"Check the zone's name in the list above, then start the tutorial again.\n": "Prüfen Sie den Namen der Zone in der obigen Liste und starten Sie das\nTutorial dann erneut.\n",
+		"Cloudflare addresses, as expected for a proxied record":                    "Cloudflare-Adressen, wie für einen Eintrag über Proxy erwartet",
+		"Cloudflare found %d record(s) by scanning the domain.\n":                   "Cloudflare hat beim Durchsuchen der Domain %d Eintrag/Einträge gefunden.\n",
+		"Cloudflare is limiting the rate of requests. Wait a few minutes, or lower CLOUDFLARE_RATE_LIMIT; changes that failed in a bulk run can be reattempted with \"retry\".": "Cloudflare begrenzt die Anfragerate. Warten Sie einige Minuten, oder senken Sie CLOUDFLARE_RATE_LIMIT; bei einem Massenlauf fehlgeschlagene Änderungen können mit \"retry\" wiederholt werden.",
+		"CNAME chain %s of %d hops; point it at %s directly": "CNAME-Kette %s mit %d Schritten; lassen Sie ihn direkt auf %s verweisen",
+		"CNAME loop %s":                                             "CNAME-Schleife %s",
+		"Color set to %s.\n":                                        "Farbe auf %s gesetzt.\n",
+		"Command ambiguous.\n":                                      "Befehl nicht eindeutig.\n",
+		"Command not found.\n":                                      "Befehl nicht gefunden.\n",
+		"Comment:   %s\n":                                           "Kommentar:   %s\n",
+		"Concurrency set to %d.\n":                                  "Parallelität auf %d gesetzt.\n",
+		"Confirmations %s.\n":                                       "Rückfragen %s.\n",
+		"Content:   %s\n":                                           "Inhalt:      %s\n",
+		"Copied %s record %s.\n":                                    "%s-Eintrag %s kopiert.\n",
+		"Crawler hints:      %s\n":                                  "Crawler-Hinweise:   %s\n",
+		"Crawler settings updated.\n":                               "Crawler-Einstellungen aktualisiert.\n",
+		"Create %d record(s)?":                                      "%d Eintrag/Einträge erstellen?",
+		"Created %s record %s.\n":                                   "%s-Eintrag %s erstellt.\n",
+		"Created:   %s\n":                                           "Erstellt:    %s\n",
+		"Credentials are valid.\n":                                  "Die Zugangsdaten sind gültig.\n",
+		"Credentials stored.\n":                                     "Zugangsdaten gespeichert.\n",
+		"Current":                                                   "Aktuell",
+		"Custom hostname not deleted.\n":                            "Benutzerdefinierter Hostname nicht gelöscht.\n",
+		"Custom rules:\n":                                           "Benutzerdefinierte Regeln:\n",
+		"Default TTL set to %s.\n":                                  "Standard-TTL auf %s gesetzt.\n",
+		"Delegated subdomains:\n":                                   "Delegierte Subdomains:\n",
+		"Delete %d record(s)?":                                      "%d Eintrag/Einträge löschen?",
+		"Delete %s record %s (%s)?":                                 "%s-Eintrag %s (%s) löschen?",
+		"Delete custom certificate %s for %s?":                      "Eigenes Zertifikat %s für %s löschen?",
+		"Delete custom hostname %s?":                                "Benutzerdefinierten Hostnamen %s löschen?",
+		"Delete email routing rule %s?":                             "E-Mail-Weiterleitungsregel %s löschen?",
+		"Delete route %s to worker %s?":                             "Route %s zu Worker %s löschen?",
+		"Delete zone %s and all of its records?":                    "Zone %s und alle ihre Einträge löschen?",
+		"Deleted %s record %s (%s).\n":                              "%s-Eintrag %s (%s) gelöscht.\n",
+		"Deleted %s record %s.\n":                                   "%s-Eintrag %s gelöscht.\n",
+		"Deleted custom certificate %s for %s.\n":                   "Eigenes Zertifikat %s für %s gelöscht.\n",
+		"Deleted custom hostname %s.\n":                             "Benutzerdefinierten Hostnamen %s gelöscht.\n",
+		"Deleted email routing rule %s.\n":                          "E-Mail-Weiterleitungsregel %s gelöscht.\n",
+		"Deleted route %s.\n":                                       "Route %s gelöscht.\n",
+		"Deleted SRV record %s (%s port %d).\n":                     "SRV-Eintrag %s (%s, Port %d) gelöscht.\n",
+		"Deleting from zone %s no longer requires a TOTP code.\n":   "Das Löschen aus Zone %s erfordert keinen TOTP-Code mehr.\n",
+		"Deleting from zone %s now requires a TOTP code.\n":         "Das Löschen aus Zone %s erfordert jetzt einen TOTP-Code.\n",
+		"Deployed worker %s with %d module(s) and %d binding(s).\n": "Worker %s mit %d Modul(en) und %d Bindung(en) bereitgestellt.\n",
+		"differs":                               "abweichend",
+		"Digest type:      %s\n":                "Digest-Typ:             %s\n",
+		"Digest:           %s\n":                "Digest:                 %s\n",
+		"Disable email routing for zone %s?":    "E-Mail-Weiterleitung für die Zone %s deaktivieren?",
+		"Disable origin %s (%s) of pool %s?":    "Ursprung %s (%s) des Pools %s deaktivieren?",
+		"disabled":                              "deaktiviert",
+		"Discard %d queued change(s)?":          "%d wartende Änderung(en) verwerfen?",
+		"Discarded %d queued change(s).\n":      "%d wartende Änderung(en) verworfen.\n",
+		"DNS queries:  %d\n":                    "DNS-Abfragen: %d\n",
+		"DNS record added.\n":                   "DNS-Eintrag hinzugefügt.\n",
+		"DNS record is being served.\n":         "DNS-Eintrag wird ausgeliefert.\n",
+		"DNS record updated.\n":                 "DNS-Eintrag aktualisiert.\n",
+		"DNS records are already up to date.\n": "DNS-Einträge sind bereits aktuell.\n",
+		"DNS records: %d\n":                     "DNS-Einträge: %d\n",
+		"DNS settings updated.\n":               "DNS-Einstellungen aktualisiert.\n",
+		"DNSSEC disabled.\n":                    "DNSSEC deaktiviert.\n",
+		"DNSSEC enabled. Add the following DS record at the registrar:\n": "DNSSEC aktiviert. Fügen Sie beim Registrar den folgenden DS-Eintrag hinzu:\n",
+		"DNSSEC not disabled.\n":                                           "DNSSEC nicht deaktiviert.\n",
+		"DNSSEC: %s\n":                                                     "DNSSEC: %s\n",
+		"Downloading %s...\n":                                              "%s wird heruntergeladen...\n",
+		"Dry-run mode %s.\n":                                               "Probelauf-Modus %s.\n",
+		"DS record:        %s\n":                                           "DS-Eintrag:             %s\n",
+		"Edit again?":                                                      "Erneut bearbeiten?",
+		"Email routing disabled.\n":                                        "E-Mail-Weiterleitung deaktiviert.\n",
+		"Email routing enabled.\n":                                         "E-Mail-Weiterleitung aktiviert.\n",
+		"Email routing left enabled.\n":                                    "E-Mail-Weiterleitung bleibt aktiviert.\n",
+		"Email routing: %s (%s)\n":                                         "E-Mail-Weiterleitung: %s (%s)\n",
+		"Email to %s is forwarded to %s.\n":                                "E-Mails an %s werden an %s weitergeleitet.\n",
+		"enabled":                                                          "aktiviert",
+		"Enter cloudflare account email: ":                                 "E-Mail-Adresse des Cloudflare-Kontos eingeben: ",
+		"Enter cloudflare API key: ":                                       "Cloudflare-API-Schlüssel eingeben: ",
+		"Enter the code it displays: ":                                     "Geben Sie den angezeigten Code ein: ",
+		"Enter zone name: ":                                                "Zonenname eingeben: ",
+		"Error backing up the settings of zone %s: %v\n":                   "Fehler beim Sichern der Einstellungen der Zone %s: %v\n",
+		"Error backing up zone %s: %v\n":                                   "Fehler beim Sichern der Zone %s: %v\n",
+		"Error changing %s record %s: %v\n":                                "Fehler beim Ändern des %s-Eintrags %s: %v\n",
+		"Error copying %s record %s: %v\n":                                 "Fehler beim Kopieren des %s-Eintrags %s: %v\n",
+		"Error creating %s record %s: %v\n":                                "Fehler beim Erstellen des %s-Eintrags %s: %v\n",
+		"Error deleting %s: %v\n":                                          "Fehler beim Löschen von %s: %v\n",
+		"Error pulling zone %s: %v\n":                                      "Fehler beim Speichern der Zone %s: %v\n",
+		"Error removing old backups of zone %s: %v\n":                      "Fehler beim Entfernen alter Sicherungen der Zone %s: %v\n",
+		"Error removing the secret from the system keyring: %v\n":          "Fehler beim Entfernen des Geheimnisses aus dem Systemschlüsselbund: %v\n",
+		"Error renaming %s: %v\n":                                          "Fehler beim Umbenennen von %s: %v\n",
+		"Error requesting an activation check: %v\n":                       "Fehler beim Anfordern einer Aktivierungsprüfung: %v\n",
+		"Error retrying %s of %s record %s: %v\n":                          "Fehler beim erneuten Versuch (%s) des %s-Eintrags %s: %v\n",
+		"Error saving session: %v\n":                                       "Fehler beim Speichern der Sitzung: %v\n",
+		"Error setting %s: %v\n":                                           "Fehler beim Setzen von %s: %v\n",
+		"Error updating %s record %s: %v\n":                                "Fehler beim Aktualisieren des %s-Eintrags %s: %v\n",
+		"Error updating %s: %v\n":                                          "Fehler beim Aktualisieren von %s: %v\n",
+		"Error: %v\n":                                                      "Fehler: %v\n",
+		"Error: --fail-fast and --continue-on-error cannot be combined.\n": "Fehler: --fail-fast und --continue-on-error können nicht kombiniert werden.\n",
+		"Error: --shadow-only requires --shadow.\n":                        "Fehler: --shadow-only erfordert --shadow.\n",
+		"Errors":                           "Fehler",
+		"exposes the origin of proxied %s": "verrät den Ursprung von %s hinter dem Proxy",
+		"Fail-fast %s.\n":                  "Abbruch beim ersten Fehler %s.\n",
+		"Firewall rule added.\n":           "Firewall-Regel hinzugefügt.\n",
+		"Firewall rule deleted.\n":         "Firewall-Regel gelöscht.\n",
+		"Fix the credentials, then start the tutorial again.\n": "Korrigieren Sie die Zugangsdaten und starten Sie das Tutorial dann erneut.\n",
+		"Foundation DNS:      %s\n":                             "Foundation DNS:      %s\n",
+		"Hint: %s\n":                                            "Hinweis: %s\n",
+		"Host providing %s: ":                                   "Host für %s: ",
+		"ID:        %s\n":                                       "ID:          %s\n",
+		"Interrupted.\n":                                        "Abgebrochen.\n",
+		"IP access rules:\n":                                    "IP-Zugriffsregeln:\n",
+		"Issued origin certificate %s for %s, expiring %s.\n":   "Ursprungszertifikat %s für %s ausgestellt, läuft am %s ab.\n",
+		"Kept %s record %s as it is in the zone.\n":             "%s-Eintrag %s wurde so belassen, wie er in der Zone ist.\n",
+		"Key tag:          %d\n":                                "Schlüssel-Tag:          %d\n",
+		"Locked:    %s\n":                                       "Gesperrt:    %s\n",
+		"Managed robots.txt: %s\n":                              "Verwaltete robots.txt: %s\n",
+		"matches":                                               "stimmt überein",
+		"Measure":                                               "Messgröße",
+		"Messages are delivered once %s is verified using the email sent to it.\n": "Nachrichten werden zugestellt, sobald %s über die daran gesendete E-Mail bestätigt wurde.\n",
+		"Modified":                                              "Geändert",
+		"Modified:  %s\n":                                       "Geändert:    %s\n",
+		"Name:      %s\n":                                       "Name:        %s\n",
+		"Nameservers before Cloudflare:\n":                      "Nameserver vor Cloudflare:\n",
+		"No accounts available.\n":                              "Keine Konten verfügbar.\n",
+		"no answer":                                             "keine Antwort",
+		"No changes applied.\n":                                 "Keine Änderungen angewendet.\n",
+		"No changes discarded.\n":                               "Keine Änderungen verworfen.\n",
+		"No changes retried.\n":                                 "Keine Änderungen erneut versucht.\n",
+		"No CNAME records found.\n":                             "Keine CNAME-Einträge gefunden.\n",
+		"No custom certificates found.\n":                       "Keine eigenen Zertifikate gefunden.\n",
+		"No custom hostnames found.\n":                          "Keine benutzerdefinierten Hostnamen gefunden.\n",
+		"No email routing rules defined.\n":                     "Keine E-Mail-Weiterleitungsregeln definiert.\n",
+		"No firewall rules defined.\n":                          "Keine Firewall-Regeln definiert.\n",
+		"No health monitors found.\n":                           "Keine Zustandsmonitore gefunden.\n",
+		"No history of %s records named %s.\n":                  "Keine Historie von %s-Einträgen namens %s.\n",
+		"No load balancer pools found.\n":                       "Keine Load-Balancer-Pools gefunden.\n",
+		"No load balancers found.\n":                            "Keine Load Balancer gefunden.\n",
+		"No origin certificates found.\n":                       "Keine Ursprungszertifikate gefunden.\n",
+		"No previous command.\n":                                "Kein vorheriger Befehl.\n",
+		"No problems found.\n":                                  "Keine Probleme gefunden.\n",
+		"No profiles defined in %s.\n":                          "Keine Profile in %s definiert.\n",
+		"No record changes were made with cf in this period.\n": "In diesem Zeitraum wurden mit cf keine Einträge geändert.\n",
+		"No records are owned by external-dns.\n":               "Keine Einträge gehören external-dns.\n",
+		"No records are protected.\n":                           "Keine Einträge sind geschützt.\n",
+		"No records changed.\n":                                 "Keine Einträge geändert.\n",
+		"No records deleted.\n":                                 "Keine Einträge gelöscht.\n",
+		"No records need changing.\n":                           "Keine Einträge müssen geändert werden.\n",
+		"No records on page %d; there are %d record(s).\n":      "Keine Einträge auf Seite %d; es gibt %d Eintrag/Einträge.\n",
+		"No records point at %s.\n":                             "Keine Einträge verweisen auf %s.\n",
+		"No records to propose.\n":                              "Keine Einträge vorzuschlagen.\n",
+		"No records updated.\n":                                 "Keine Einträge aktualisiert.\n",
+		"No redirect rules defined.\n":                          "Keine Weiterleitungsregeln definiert.\n",
+		"No rules deleted.\n":                                   "Keine Regeln gelöscht.\n",
+		"No tunnels found.\n":                                   "Keine Tunnel gefunden.\n",
+		"No worker routes found.\n":                             "Keine Worker-Routen gefunden.\n",
+		"No workers found.\n":                                   "Keine Worker gefunden.\n",
+		"No zones require a TOTP code.\n":                       "Keine Zone erfordert einen TOTP-Code.\n",
+		"no":                                                    "nein",
+		"Not from: %s\n":                                        "Nicht von: %s\n",
+		"not served yet; resolvers may cache the missing record for up to the zone's negative TTL": "noch nicht ausgeliefert; Resolver speichern den fehlenden Eintrag womöglich bis zur negativen TTL der Zone",
+		"not served":                                    "nicht ausgeliefert",
+		"Not waiting for a proxied record.\n":           "Kein Warten auf einen Proxy-Eintrag.\n",
+		"Nothing redone.\n":                             "Nichts wiederhergestellt.\n",
+		"Nothing undone.\n":                             "Nichts rückgängig gemacht.\n",
+		"Offboarding report for zone %s\n":              "Auszugsbericht für Zone %s\n",
+		"Only from: %s\n":                               "Nur von: %s\n",
+		"Origin %s of pool %s is already %s.\n":         "Ursprung %s des Pools %s ist bereits %s.\n",
+		"Origin %s of pool %s is now %s.\n":             "Ursprung %s des Pools %s ist jetzt %s.\n",
+		"Origin not disabled.\n":                        "Ursprung nicht deaktiviert.\n",
+		"Orphaned external-dns marker %s (owner %s).\n": "Verwaister external-dns-Marker %s (Eigentümer %s).\n",
+		"Output format set to %s.\n":                    "Ausgabeformat auf %s gesetzt.\n",
+		"Outside Cloudflare these hostnames will resolve directly to their origins:\n": "Außerhalb von Cloudflare werden diese Hostnamen direkt zu ihren Ursprungsservern aufgelöst:\n",
+		"Owner set to %s.\n":             "Eigentümer auf %s gesetzt.\n",
+		"Ownership tracking disabled.\n": "Eigentümerverfolgung deaktiviert.\n",
+		"Page rules have no DNS equivalent and must be rebuilt elsewhere:\n": "Seitenregeln haben keine DNS-Entsprechung und müssen anderswo neu erstellt werden:\n",
+		"Page rules: %d\n":                           "Seitenregeln: %d\n",
+		"Page size set to %d.\n":                     "Seitengröße auf %d gesetzt.\n",
+		"Plan: %s\n":                                 "Tarif: %s\n",
+		"Point %s at %s port %d instead?":            "Stattdessen %s auf %s, Port %d verweisen lassen?",
+		"points at %s, which has no records":         "verweist auf %s, das keine Einträge hat",
+		"Previous":                                   "Vorher",
+		"Priority:  %s\n":                            "Priorität:   %s\n",
+		"Protected %s records named %s.\n":           "%s-Einträge namens %s geschützt.\n",
+		"Proxied hostnames: %d\n":                    "Hostnamen über Proxy: %d\n",
+		"Proxied:   %s\n":                            "Proxy:       %s\n",
+		"Public key:       %s\n":                     "Öffentlicher Schlüssel: %s\n",
+		"Public resolvers:\n":                        "Öffentliche Resolver:\n",
+		"Pulled zone %s to %s.\n":                    "Zone %s in %s gespeichert.\n",
+		"Purge all cached content of zone %s?":       "Gesamten Cache der Zone %s leeren?",
+		"Purged %s.\n":                               "%s aus dem Cache entfernt.\n",
+		"Purged all cached content.\n":               "Gesamter Cache geleert.\n",
+		"Records not adopted.\n":                     "Einträge nicht übernommen.\n",
+		"Redaction %s.\n":                            "Schwärzung %s.\n",
+		"Redid %s.\n":                                "%s wiederhergestellt.\n",
+		"Redirect rule added.\n":                     "Weiterleitungsregel hinzugefügt.\n",
+		"Redirect rule deleted.\n":                   "Weiterleitungsregel gelöscht.\n",
+		"Redo %s?":                                   "%s wiederherstellen?",
+		"Released %s %s to external-dns owner %s.\n": "%s %s an external-dns-Eigentümer %s übergeben.\n",
+		"Remove the DS record at the registrar and wait for it to expire before\nchanging nameservers, or validating resolvers will fail to resolve the zone.\n": "Entfernen Sie den DS-Eintrag beim Registrar und warten Sie, bis er abläuft,\nbevor Sie die Nameserver ändern, sonst können validierende Resolver die Zone nicht auflösen.\n",
+		"Remove the DS record at the registrar before disabling DNSSEC. Disable DNSSEC?":                                                                         "Entfernen Sie den DS-Eintrag beim Registrar, bevor Sie DNSSEC deaktivieren. DNSSEC deaktivieren?",
+		"Remove the lock?": "Sperre aufheben?",
+		"Removed the protection of %s records named %s.\n": "Schutz der %s-Einträge namens %s aufgehoben.\n",
+		"Renamed %s record %s to %s.\n":                    "%s-Eintrag %s in %s umbenannt.\n",
+		"Replace them with the new policy?":                "Durch die neue Richtlinie ersetzen?",
+		"Replacing the SRV record(s) of %s:\n":             "Die SRV-Einträge von %s werden ersetzt:\n",
+		"Request failed (%s); retrying in %s.\n":           "Anfrage fehlgeschlagen (%s); neuer Versuch in %s.\n",
+		"Request timeout set to %s.\n":                     "Zeitlimit für Anfragen auf %s gesetzt.\n",
+		"Requests":                                         "Anfragen",
+		"Requests:     %d (%s cached)\n":                   "Anfragen:     %d (%s aus dem Cache)\n",
+		"Resolvers set to %s.\n":                           "Resolver auf %s gesetzt.\n",
+		"Retry %d change(s)?":                              "%d Änderung(en) erneut versuchen?",
+		"Revoke origin certificate %s for %s?":             "Ursprungszertifikat %s für %s widerrufen?",
+		"Revoked origin certificate %s for %s.\n":          "Ursprungszertifikat %s für %s widerrufen.\n",
+		"Route not deleted.\n":                             "Route nicht gelöscht.\n",
+		"Run \"cf self-update\" to install it.\n":          "Mit \"cf self-update\" installieren.\n",
+		"Script": "Skript",
+		"served, but not configured in Cloudflare; another provider may still be authoritative":       "ausgeliefert, aber nicht in Cloudflare konfiguriert; womöglich ist noch ein anderer Anbieter zuständig",
+		"serves %s, not Cloudflare's addresses; the record was recently proxied, or is cached":        "liefert %s statt Cloudflares Adressen; der Eintrag läuft erst seit Kurzem über den Proxy oder ist zwischengespeichert",
+		"serves Cloudflare's addresses; the record was recently unproxied, or is cached for up to %s": "liefert Cloudflares Adressen; der Proxy wurde erst kürzlich abgeschaltet, oder der Eintrag ist bis zu %s zwischengespeichert",
+		"Session state exported to %s.\n":                    "Sitzungszustand nach %s exportiert.\n",
+		"Set %s record %s to %s.\n":                          "%s-Eintrag %s auf %s gesetzt.\n",
+		"Set %s to %v.\n":                                    "%s auf %v gesetzt.\n",
+		"Set the following nameservers at your registrar:\n": "Tragen Sie bei Ihrem Registrar folgende Nameserver ein:\n",
+		"Set the SPF policy of %s to %s.\n":                  "SPF-Richtlinie von %s auf %s gesetzt.\n",
+		"Set the workers.dev subdomain to %s.\n":             "Die workers.dev-Subdomain ist jetzt %s.\n",
+		"Set TTL of %s record %s to %s.\n":                   "TTL des %s-Eintrags %s auf %s gesetzt.\n",
+		"Setting %s updated.\n":                              "Einstellung %s aktualisiert.\n",
+		"Shadow writes disabled.\n":                          "Schattenschreiben deaktiviert.\n",
+		"Shadow-only mode %s.\n":                             "Nur-Schatten-Modus %s.\n",
+		"Showing records %d-%d of %d.\n":                     "Einträge %d-%d von %d.\n",
+		"Skipped %s record %s.\n":                            "%s-Eintrag %s übersprungen.\n",
+		"Skipping %s, which is not in zone %s.\n":            "%s wird übersprungen, da es nicht in Zone %s liegt.\n",
+		"Skipping TXT record %s, whose %d characters exceed Cloudflare's limit of %d.\n": "TXT-Eintrag %s wird übersprungen, da seine %d Zeichen das Limit von Cloudflare von %d überschreiten.\n",
+		"SRV record %s already points at %s port %d.\n":                                  "Der SRV-Eintrag %s verweist bereits auf %s, Port %d.\n",
+		"SRV record %s points at %s port %d.\n":                                          "Der SRV-Eintrag %s verweist auf %s, Port %d.\n",
+		"Staging zone set to %s.\n":                                                      "Staging-Zone auf %s gesetzt.\n",
+		"stale; resolvers may cache the old value for up to %s":                          "veraltet; Resolver speichern den alten Wert womöglich bis zu %s",
+		"Status code: %d\n":                                                              "Statuscode: %d\n",
+		"Status:           %s\n":                                                         "Status:                 %s\n",
+		"Store these credentials in the system keyring?":                                 "Diese Zugangsdaten im Schlüsselbund des Systems speichern?",
+		"Stored credentials removed.\n":                                                  "Gespeicherte Zugangsdaten entfernt.\n",
+		"Subdomain not changed.\n":                                                       "Subdomain nicht geändert.\n",
+		"Tags:      %s\n":                                                                "Tags:        %s\n",
+		"The credentials lack permission for this request. \"account permissions\" lists the permissions of the API token in use.":                "Den Zugangsdaten fehlt die Berechtigung für diese Anfrage. \"account permissions\" listet die Berechtigungen des verwendeten API-Tokens auf.",
+		"The credentials were rejected. Check them with \"account verify\", or remove the stored credentials with \"logout\" and enter new ones.": "Die Zugangsdaten wurden abgelehnt. Prüfen Sie sie mit \"account verify\", oder entfernen Sie die gespeicherten Zugangsdaten mit \"logout\" und geben Sie neue ein.",
+		"The expression is valid.\n":                                                                                     "Der Ausdruck ist gültig.\n",
+		"The following changes will be made:\n":                                                                          "Die folgenden Änderungen werden vorgenommen:\n",
+		"The following records will be changed from %s to %s:\n":                                                         "Die folgenden Einträge werden von %s auf %s geändert:\n",
+		"The following records will be changed:\n":                                                                       "Die folgenden Einträge werden geändert:\n",
+		"The following records will be deleted:\n":                                                                       "Die folgenden Einträge werden gelöscht:\n",
+		"The hostname's owner must create these records, or serve these files:\n":                                        "Der Inhaber des Hostnamens muss diese Einträge anlegen oder diese Dateien bereitstellen:\n",
+		"The record no longer exists. \"refresh\" discards the cached records.":                                          "Der Eintrag existiert nicht mehr. \"refresh\" verwirft die zwischengespeicherten Einträge.",
+		"The registrar delegates the zone to Cloudflare.\n":                                                              "Der Registrar delegiert die Zone an Cloudflare.\n",
+		"The retry queue is empty.\n":                                                                                    "Die Wiederholungswarteschlange ist leer.\n",
+		"The template contains no records.\n":                                                                            "Die Vorlage enthält keine Einträge.\n",
+		"The token's permissions could not be read; reading them requires the \"API Tokens Read\" permission.\n":         "Die Berechtigungen des Tokens konnten nicht gelesen werden; dazu ist die Berechtigung \"API Tokens Read\" erforderlich.\n",
+		"The workers.dev subdomain is already %s.\n":                                                                     "Die workers.dev-Subdomain ist bereits %s.\n",
+		"The zone already exists. \"zone <domain>\" selects it, and \"zone onboard <domain>\" continues its onboarding.": "Die Zone existiert bereits. \"zone <domain>\" wählt sie aus, und \"zone onboard <domain>\" setzt ihr Onboarding fort.",
+		"The zone is not yet delegated to the assigned nameservers.\n":                                                   "Die Zone ist noch nicht an die zugewiesenen Nameserver delegiert.\n",
+		"The zone matches the snapshot.\n":                                                                               "Die Zone stimmt mit dem Schnappschuss überein.\n",
+		"The zone or object was not found. \"zones\" lists the zones the credentials can access, and \"refresh\" discards the cached zones.": "Die Zone oder das Objekt wurde nicht gefunden. \"zones\" listet die Zonen auf, auf die die Zugangsdaten zugreifen können, und \"refresh\" verwirft die zwischengespeicherten Zonen.",
+		"There are no changes to redo.\n": "Es gibt keine Änderungen zum Wiederherstellen.\n",
+		"There are no changes to undo.\n": "Es gibt keine Änderungen zum Rückgängigmachen.\n",
+		"This tutorial runs a few cf commands with you. Each command is shown\nbefore it runs; press Enter to run it, s to skip it, or q to quit.\n": "Dieses Tutorial führt einige cf-Befehle mit Ihnen aus. Jeder Befehl wird\nvor der Ausführung angezeigt; drücken Sie Eingabe, um ihn auszuführen,\ns, um ihn zu überspringen, oder q, um aufzuhören.\n",
+		"Threats":                      "Bedrohungen",
+		"Threats:      %d\n":           "Bedrohungen:  %d\n",
+		"Time remaining: %d seconds\n": "Verbleibende Zeit: %d Sekunden\n",
+		"timed out after %s":           "Zeitüberschreitung nach %s",
+		"Top query names:\n":           "Häufigste abgefragte Namen:\n",
+		"TOTP code for zone %s: ":      "TOTP-Code für Zone %s: ",
+		"TTL:       %s\n":              "TTL:         %s\n",
+		"Tutorial ended. Enter \"tutorial\" to start it again.\n": "Tutorial beendet. Geben Sie \"tutorial\" ein, um es erneut zu starten.\n",
+		"Type:      %s\n": "Typ:         %s\n",
+		"Unable to record the change in the journal: %v\n":                              "Die Änderung konnte nicht im Journal festgehalten werden: %v\n",
+		"Unable to record the change in the record history: %v\n":                       "Die Änderung konnte nicht in der Eintragshistorie vermerkt werden: %v\n",
+		"Unable to save failed changes for retry: %v\n":                                 "Fehlgeschlagene Änderungen konnten nicht gespeichert werden: %v\n",
+		"Unable to write the audit log: %v\n":                                           "Das Audit-Protokoll konnte nicht geschrieben werden: %v\n",
+		"Unable to write the run summary: %v\n":                                         "Die Laufzusammenfassung konnte nicht geschrieben werden: %v\n",
+		"Undid %s.\n":                                                                   "%s rückgängig gemacht.\n",
+		"Undo %s?":                                                                      "%s rückgängig machen?",
+		"Update which record? [1-%d] ":                                                  "Welchen Eintrag aktualisieren? [1-%d] ",
+		"Updated %s record %s.\n":                                                       "%s-Eintrag %s aktualisiert.\n",
+		"Updated cf from %s to %s.\n":                                                   "cf von %s auf %s aktualisiert.\n",
+		"Uploaded certificate %s for %s, expiring %s.\n":                                "Zertifikat %s für %s hochgeladen, läuft am %s ab.\n",
+		"Version %s is available: %s\n":                                                 "Version %s ist verfügbar: %s\n",
+		"Waiting for %d resolver(s) to serve the records...\n":                          "Warte, bis %d Resolver die Einträge liefern...\n",
+		"Waiting for %s to serve the new record...\n":                                   "Warten, bis %s den neuen Eintrag ausliefern...\n",
+		"Waiting for zone %s to become active (Ctrl-C to stop)...\n":                    "Warte, bis Zone %s aktiv wird (Strg-C zum Abbrechen)...\n",
+		"Waiting is not supported for %s records.\n":                                    "Warten wird für %s-Einträge nicht unterstützt.\n",
+		"Warning: %d %s records named %s exist; only the first is updated.\n":           "Warnung: Es gibt %d %s-Einträge namens %s; nur der erste wird aktualisiert.\n",
+		"Warning: %s record %s: %s.\n":                                                  "Warnung: %s-Eintrag %s: %s.\n",
+		"Warning: after hook %q failed: %v\n":                                           "Warnung: Der Hook %q nach dem Befehl ist fehlgeschlagen: %v\n",
+		"Warning: alias %s record %s becomes a CNAME record.\n":                         "Warnung: Der Alias-%s-Eintrag %s wird zu einem CNAME-Eintrag.\n",
+		"Warning: origin %s is the last enabled origin of pool %s.\n":                   "Warnung: Ursprung %s ist der letzte aktivierte Ursprung des Pools %s.\n",
+		"Warning: the credentials cannot list zones; using the zones selected by ID.\n": "Warnung: Die Zugangsdaten können keine Zonen auflisten; die per ID gewählten Zonen werden verwendet.\n",
+		"Warning: the routing policy of %s record %s is not migrated.\n":                "Warnung: Die Routing-Richtlinie des %s-Eintrags %s wird nicht übernommen.\n",
+		"Warning: unable to renew the lock of zone %s: %v\n":                            "Warnung: Die Sperre der Zone %s konnte nicht verlängert werden: %v\n",
+		"Warning: unable to unlock zone %s: %v\n":                                       "Warnung: Die Sperre der Zone %s konnte nicht aufgehoben werden: %v\n",
+		"Watching %d record(s) in zone %s every %s.\n":                                  "Überwache %d Einträge in Zone %s alle %s.\n",
+		"Without a zone, the rest of the tutorial is skipped.\n":                        "Ohne Zone wird der Rest des Tutorials übersprungen.\n",
+		"Without the test record, the rest of the tutorial is skipped.\n":               "Ohne den Testeintrag wird der Rest des Tutorials übersprungen.\n",
+		"Wrote access report of zone %s to %s.\n":                                       "Zugriffsbericht der Zone %s nach %s geschrieben.\n",
+		"Wrote egress report of zone %s to %s.\n":                                       "Egress-Bericht der Zone %s nach %s geschrieben.\n",
+		"Wrote inventory of %d zone(s) to %s.\n":                                        "Inventar von %d Zone(n) nach %s geschrieben.\n",
+		"Wrote the certificate to %s and its private key to %s.\n":                      "Zertifikat nach %s und privater Schlüssel nach %s geschrieben.\n",
+		"yours:": "Ihrer:",
+		"Zone %s already exists (ID %s); continuing its onboarding.\n": "Zone %s existiert bereits (ID %s); ihr Onboarding wird fortgesetzt.\n",
+		"Zone %s already requires a TOTP code.\n":                      "Zone %s erfordert bereits einen TOTP-Code.\n",
+		"Zone %s created (ID %s).\n":                                   "Zone %s erstellt (ID %s).\n",
+		"Zone %s deleted.\n":                                           "Zone %s gelöscht.\n",
+		"Zone %s has %d SPF policies, which receivers reject; all but one will be deleted:\n": "Zone %s hat %d SPF-Richtlinien, die Empfänger ablehnen; alle bis auf eine werden gelöscht:\n",
+		"Zone %s is %s.\n":                                "Zone %s ist %s.\n",
+		"Zone %s is active.\n":                            "Zone %s ist aktiv.\n",
+		"Zone %s is locked by %s until %s.\n":             "Die Zone %s ist bis %[3]s von %[2]s gesperrt.\n",
+		"Zone %s is not locked.\n":                        "Die Zone %s ist nicht gesperrt.\n",
+		"Zone %s matches the baseline of %s.\n":           "Zone %s stimmt mit der Grundlinie vom %s überein.\n",
+		"Zone %s unlocked.\n":                             "Sperre der Zone %s aufgehoben.\n",
+		"Zone %s: the last %s compared with %s earlier\n": "Zone %s: die letzten %s verglichen mit %s früher\n",
+		"Zone file written to %s.\n":                      "Zonendatei nach %s geschrieben.\n",
+		"Zone file:\n":                                    "Zonendatei:\n",
+		"Zone ID:   %s\n":                                 "Zonen-ID:    %s\n",
+		"Zone not deleted.\n":                             "Zone nicht gelöscht.\n",
+		"Zone to use: ":                                   "Zu verwendende Zone: ",
+		"zone:":                                           "Zone:",
+		"Zones %s and %s have the same records.\n":        "Die Zonen %s und %s haben dieselben Einträge.\n",
+	}
+}
@@ -0,0 +1,135 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func cmdCert(c *cmd.Command, args []string) error {
+	if len(args) == 0 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "coverage":
+		if len(args) == 1 {
+			return cmdCertCoverage()
+		}
+	case "create":
+		return cmdCertCreate(c, args[1:])
+	case "list":
+		if len(args) == 1 {
+			return cmdCertList()
+		}
+	case "revoke":
+		return cmdCertRevoke(c, args[1:])
+	}
+	return usageError(c)
+}
+
+// cmdCertCoverage reports the proxied hostnames in the active zone that are
+// not covered by any active edge certificate. Visitors to such hostnames
+// are served a certificate that does not match.
+func cmdCertCoverage() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	sans, err := edgeCertificateHosts(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var uncovered []string
+	for _, r := range recs {
+		if r.Proxied == nil || !*r.Proxied || seen[r.Name] {
+			continue
+		}
+		seen[r.Name] = true
+		if !hostCovered(r.Name, sans) {
+			uncovered = append(uncovered, r.Name)
+		}
+	}
+	sort.Strings(uncovered)
+
+	if len(uncovered) == 0 {
+		printf("All %d proxied hostname(s) are covered by an edge certificate.\n", len(seen))
+		return nil
+	}
+
+	printf("%d of %d proxied hostname(s) are not covered by an edge certificate:\n", len(uncovered), len(seen))
+	for _, name := range uncovered {
+		fmt.Fprintf(output, "    %s\n", name)
+	}
+	return nil
+}
+
+// edgeCertificateHosts returns the hostnames covered by the zone's active
+// certificate packs and custom certificates.
+func edgeCertificateHosts(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) ([]string, error) {
+	ctx := commandCtx
+
+	packs, err := api.ListCertificatePacks(ctx, zoneID.Identifier)
+	if err != nil {
+		return nil, err
+	}
+
+	var hosts []string
+	for _, p := range packs {
+		if p.Status == "active" {
+			hosts = append(hosts, p.Hosts...)
+		}
+	}
+
+	custom, err := api.ListSSL(ctx, zoneID.Identifier)
+	if err != nil {
+		return nil, err
+	}
+	for _, cert := range custom {
+		if cert.Status == "active" {
+			hosts = append(hosts, cert.Hosts...)
+		}
+	}
+	return hosts, nil
+}
+
+// hostCovered reports whether a certificate with the given subject
+// alternative names is valid for host. A wildcard name covers exactly one
+// additional label.
+func hostCovered(host string, sans []string) bool {
+	host = cflib.NormalizeName(host)
+	for _, san := range sans {
+		san = cflib.NormalizeName(san)
+		if san == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(san, "*"); ok && strings.HasSuffix(host, suffix) {
+			label := strings.TrimSuffix(host, suffix)
+			if label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -8,13 +8,19 @@ package main
 
 import (
 	"bufio"
-	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net/http"
 	"os"
+	"sort"
+	"strconv"
 	"strings"
 	"syscall"
+	"time"
 
+	"github.com/beevik/cf/cflib"
 	"github.com/beevik/cmd"
 	cloudflare "github.com/cloudflare/cloudflare-go"
 	"golang.org/x/term"
@@ -24,7 +30,9 @@ var (
 	interactive          bool
 	activeAPI            *cloudflare.API
 	activeZoneIdentifier *cloudflare.ResourceContainer
+	activeZoneName       string
 	cmds                 *cmd.Tree
+	stdin                = bufio.NewReader(os.Stdin)
 )
 
 func init() {
@@ -33,49 +41,151 @@ func init() {
 	})
 
 	root.AddCommand(cmd.CommandDescriptor{
-		Name:        "help",
-		Description: "Display help for a command.",
-		Usage:       "help [<command>]",
-		Data:        cmdHelp,
+		Name: "help",
+		Description: "Display help for a command. With --json, or in JSON " +
+			"output mode, the leading flags and every command, or only " +
+			"the given command, are described as JSON: their usage, " +
+			"description, shortcuts, subcommands and flags.",
+		Usage: "help [--json] [<command>]",
+		Data:  cmdHelp,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
-		Name:        "list",
-		Brief:       "List all DNS records",
-		Description: "List all DNS records in the currently active zone.",
-		Usage:       "list [<type>]",
-		Data:        cmdListDomains,
+		Name:  "list",
+		Brief: "List all DNS records",
+		Description: "List all DNS records in the currently active zone. " +
+			"Use --zone to list the records of another zone, or " +
+			"--all-zones to list the records of every zone in the account. " +
+			"When an owner is set, --owned lists only the records marked " +
+			"as owned by it. --tag lists only the records with a tag, " +
+			"either name:value or just its name, and --long adds the " +
+			"records' tags and comments to the listing. All records are " +
+			"listed, however many pages the API returns them in; --limit " +
+			"lists only the first n records, and --page the requested " +
+			"page of n records (or of the session's page size). " +
+			"--where lists only the records matching a filter, such as " +
+			"'type=A && content~\"^10\\.\" && ttl<300', which compares " +
+			"the fields type, name, content, comment, zone, ttl, priority, " +
+			"proxied and tag with =, !=, <, <=, > and >=, or with the " +
+			"regular expression operators ~ and !~, combined with &&, || " +
+			"and ! and grouped with parentheses. " +
+			"--group-by lists the records under a header for each tag, " +
+			"comment prefix (the comment's first word) or type, with a " +
+			"count of the records in each group. --sort orders the " +
+			"records by name, type, TTL or content. On a terminal the " +
+			"listing has column headers and is colored, with record types " +
+			"colored by type, TTLs dimmed and the names of proxied records " +
+			"highlighted; set NO_COLOR to disable the colors.",
+		Usage: "list [--zone <name>|--all-zones] [--owned] [--tag <tag>] [--where <filter>] [--long] " +
+			"[--limit <n>] [--page <n>] [--group-by tag|comment-prefix|type] " +
+			"[--sort name|type|ttl|content] [<type>]",
+		Data: cmdListDomains,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "search",
+		Brief: "Search for DNS records",
+		Description: "List the DNS records whose names match a pattern. " +
+			"Patterns containing the wildcards *, ? or [] must match the " +
+			"whole name; other patterns match any part of it. Use " +
+			"--content to match record content instead of or in addition " +
+			"to the name, --type to restrict the record type, and --regex " +
+			"to treat patterns as regular expressions. Use --zone or " +
+			"--all-zones to search zones other than the active zone.",
+		Usage: "search [--zone <name>|--all-zones] [--type <type>] " +
+			"[--content <pattern>] [--regex] [<pattern>]",
+		Data: cmdSearch,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
 		Name:  "ip4",
 		Brief: "Add or modify an IPv4 Address (type A) record",
 		Description: "Add or modify an IPv4 address (type A) DNS record " +
-			"in the currently active zone.",
-		Usage: "ip4 <name> <address>",
-		Data:  cmdIP4,
+			"in the currently active zone. The optional TTL is " +
+			"given in seconds, or \"auto\" to let Cloudflare choose. With --wait, the command waits until " +
+			"Cloudflare's nameservers and the public resolvers (see \"verify\") " +
+			"serve the new value, for at most " +
+			"--wait-timeout (default 2m). --comment sets the record's " +
+			"comment, and --tag sets its tags, separated by commas. " +
+			"--dns-only turns the record's proxy off; new records in the " +
+			"zones of the proxied_zones setting are otherwise proxied. " +
+			"If several records have the name, they are listed and, in " +
+			"interactive mode, the one to update is asked for; --id " +
+			"updates the record with an ID, and --all replaces them all " +
+			"with a single record. --append adds another record to a round-robin set, " +
+			"and --replace-all atomically replaces the whole set with " +
+			"records for a comma-separated list of addresses.",
+		Usage: "ip4 [--wait [--wait-timeout <duration>]] [--comment <text>] [--tag <tags>] [--dns-only] " +
+			"[--all|--id <id>|--append|--replace-all] <name> <address>[,<address>...] [<ttl>]",
+		Data: cmdIP4,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
 		Name:  "ip6",
 		Brief: "Add or modify an IPv6 Address (type AAAA) record",
 		Description: "Add or modify an IPv6 address (type AAAA) DNS record " +
-			"in the currently active zone.",
-		Usage: "ip6 <name> <address>",
-		Data:  cmdIP6,
+			"in the currently active zone. The optional TTL is " +
+			"given in seconds, or \"auto\" to let Cloudflare choose. With --wait, the command waits until " +
+			"Cloudflare's nameservers and the public resolvers (see \"verify\") " +
+			"serve the new value, for at most " +
+			"--wait-timeout (default 2m). --comment sets the record's " +
+			"comment, and --tag sets its tags, separated by commas. " +
+			"--dns-only turns the record's proxy off; new records in the " +
+			"zones of the proxied_zones setting are otherwise proxied. " +
+			"If several records have the name, they are listed and, in " +
+			"interactive mode, the one to update is asked for; --id " +
+			"updates the record with an ID, and --all replaces them all " +
+			"with a single record. --append adds another record to a round-robin set, " +
+			"and --replace-all atomically replaces the whole set with " +
+			"records for a comma-separated list of addresses.",
+		Usage: "ip6 [--wait [--wait-timeout <duration>]] [--comment <text>] [--tag <tags>] [--dns-only] " +
+			"[--all|--id <id>|--append|--replace-all] <name> <address>[,<address>...] [<ttl>]",
+		Data: cmdIP6,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "apex",
+		Brief: "Point the zone apex at an address",
+		Description: "Set the A record, and the AAAA record if an IPv6 " +
+			"address is given, of the currently active zone's apex, with " +
+			"Cloudflare's proxy turned on. --www also points a proxied " +
+			"CNAME record for www at the apex. An existing AAAA record is " +
+			"left alone if no IPv6 address is given.",
+		Usage: "apex [--www] <ipv4> [<ipv6>]",
+		Data:  cmdApex,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
 		Name:  "cname",
 		Brief: "Add or modify a CNAME record",
 		Description: "Add or modify a CNAME DNS record " +
-			"in the currently active zone.",
-		Usage: "cname <name> <address>",
-		Data:  cmdCNAME,
+			"in the currently active zone. The optional TTL is " +
+			"given in seconds, or \"auto\" to let Cloudflare choose. With --wait, the command waits until " +
+			"Cloudflare's nameservers and the public resolvers (see \"verify\") " +
+			"serve the new value, for at most " +
+			"--wait-timeout (default 2m). --comment sets the record's " +
+			"comment, and --tag sets its tags, separated by commas. " +
+			"--dns-only turns the record's proxy off; new records in the " +
+			"zones of the proxied_zones setting are otherwise proxied. " +
+			"If several records have the name, they are listed and, in " +
+			"interactive mode, the one to update is asked for; --id " +
+			"updates the record with an ID, and --all replaces them all " +
+			"with a single record.",
+		Usage: "cname [--wait [--wait-timeout <duration>]] [--comment <text>] [--tag <tags>] [--dns-only] " +
+			"[--all|--id <id>] <name> <address> [<ttl>]",
+		Data: cmdCNAME,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
 		Name:  "txt",
 		Brief: "Add or modify a text (type TXT) record",
 		Description: "Add or modify a text (type TXT) DNS record " +
-			"in the currently active zone.",
-		Usage: "txt <name> <address>",
-		Data:  cmdTXT,
+			"in the currently active zone. The optional TTL is " +
+			"given in seconds, or \"auto\" to let Cloudflare choose. With --wait, the command waits until " +
+			"Cloudflare's nameservers and the public resolvers (see \"verify\") " +
+			"serve the new value, for at most " +
+			"--wait-timeout (default 2m). --comment sets the record's " +
+			"comment, and --tag sets its tags, separated by commas. " +
+			"If several records have the name, they are listed and, in " +
+			"interactive mode, the one to update is asked for; --id " +
+			"updates the record with an ID, and --all replaces them all " +
+			"with a single record.",
+		Usage: "txt [--wait [--wait-timeout <duration>]] [--comment <text>] [--tag <tags>] " +
+			"[--all|--id <id>] <name> <address> [<ttl>]",
+		Data: cmdTXT,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
 		Name:  "add",
@@ -85,8 +195,16 @@ func init() {
 			"allowed DNS record types (A, AAAA, CNAME, etc.). If the " +
 			"content string has spaces, it must be enclosed in quotes. " +
 			"This command always adds a new record if it succeeds, even if " +
-			"there is already another record with the same name and type.",
-		Usage: "add <type> <name> \"<content>\"",
+			"there is already another record with the same name and type. " +
+			"The optional TTL is given in seconds, or \"auto\" to let " +
+			"Cloudflare choose. With --wait, the command waits until " +
+			"Cloudflare's nameservers and the public resolvers (see \"verify\") " +
+			"serve the new value, for at most " +
+			"--wait-timeout (default 2m). --comment sets the record's " +
+			"comment, and --tag sets its tags, separated by commas. " +
+			"--dns-only turns the record's proxy off; new records in the " +
+			"zones of the proxied_zones setting are otherwise proxied.",
+		Usage: "add [--wait [--wait-timeout <duration>]] [--comment <text>] [--tag <tags>] [--dns-only] <type> <name> \"<content>\" [<ttl>]",
 		Data:  cmdAdd,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
@@ -94,16 +212,1173 @@ func init() {
 		Brief: "Delete DNS record(s)",
 		Description: "Delete all DNS records matching the requested type " +
 			"and name in the currently active zone. The type must be one " +
-			"of the allowed DNS record types (A, AAAA, CNAME, etc.).",
-		Usage: "delete <type> <name>",
-		Data:  cmdDelete,
+			"of the allowed DNS record types (A, AAAA, CNAME, etc.). The " +
+			"name may contain the wildcards *, ? and [] to delete many " +
+			"records at once; use --exact to match a wildcard record's " +
+			"name literally. Use --content to delete only records whose " +
+			"content matches a pattern, which must match the whole " +
+			"content unless it contains wildcards. Use --zone to delete from another " +
+			"zone, or --all-zones to delete matching records from every " +
+			"zone in the account. The records are listed and confirmation " +
+			"is requested before they are deleted, unless --force (or -y) " +
+			"is given. When an owner is set, --owned deletes only the " +
+			"records marked as owned by it. --id deletes the single " +
+			"record with the requested ID from the active zone.",
+		Usage: "delete [--zone <name>|--all-zones] [--force] [--exact] " +
+			"[--owned] [--content <pattern>] <type> <name> | " +
+			"delete [--force] --id <record-id>",
+		Data: cmdDelete,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "update",
+		Brief: "Change the content of a DNS record by ID",
+		Description: "Change the content of the DNS record with the " +
+			"requested ID in the currently active zone, which targets one " +
+			"record even when several share a type and name. The optional " +
+			"TTL is given in seconds, or \"auto\" to let Cloudflare " +
+			"choose. --comment sets the record's comment, and --tag sets " +
+			"its tags, separated by commas.",
+		Usage: "update --id <record-id> [--comment <text>] [--tag <tags>] \"<content>\" [<ttl>]",
+		Data:  cmdUpdate,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "ttl",
+		Brief: "Change the TTL of DNS record(s)",
+		Description: "Change the time-to-live of all DNS records matching " +
+			"the requested type and name in the currently active zone. " +
+			"The TTL is given in seconds (between 30 and 86400), or " +
+			"\"auto\" to let Cloudflare choose.",
+		Usage: "ttl <type> <name> <seconds>",
+		Data:  cmdTTL,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "comment",
+		Brief: "Set the comments of DNS record(s)",
+		Description: "Set, or with \"append\" extend, the comment of every " +
+			"DNS record in the currently active zone matching the optional " +
+			"type and name. A type of * matches all types, and the name may " +
+			"contain the wildcards *, ? and [...]. The records to change are " +
+			"listed for confirmation, which --force (or -y) skips.",
+		Usage: "comment set|append [--force] <text> [<type> [<name>]]",
+		Data:  cmdComment,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "tag",
+		Brief: "Add or remove a tag on DNS record(s)",
+		Description: "Add a tag to, or remove a tag from, every DNS record " +
+			"in the currently active zone matching the optional type and " +
+			"name. A type of * matches all types, and the name may contain " +
+			"the wildcards *, ? and [...]. Tags have the form name:value. " +
+			"The records to change are listed for confirmation, which " +
+			"--force (or -y) skips.",
+		Usage: "tag add|remove [--force] <tag> [<type> [<name>]]",
+		Data:  cmdTag,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "edit",
+		Brief: "Edit DNS records in a text editor",
+		Description: "Open the DNS records of the currently active zone " +
+			"matching the optional type and name in the editor named by " +
+			"$VISUAL or $EDITOR, one record per line. A type of * matches " +
+			"all types, and the name may contain the wildcards *, ? and " +
+			"[...]. When the editor exits, changed lines update their " +
+			"records, deleted lines delete them, and lines added with the " +
+			"ID - create new records. The changes are listed for " +
+			"confirmation, which --force (or -y) skips. Records with " +
+			"structured data or a priority, such as SRV, CAA and MX " +
+			"records, are edited with --json, which presents the records " +
+			"as a JSON array instead; such records hold their value in " +
+			"\"data\" rather than \"content\", a TTL of 1 is automatic, " +
+			"and records added without an \"id\" are created. For each " +
+			"edited record changed in the zone while the editor was open, " +
+			"edit asks whether to keep the zone's record, apply the edit, " +
+			"skip the record or edit it again; --force applies the edits, " +
+			"and outside interactive mode such records are skipped.",
+		Usage: "edit [--json] [--force] [<type> [<name>]]",
+		Data:  cmdEdit,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "completion",
+		Brief: "Generate a shell completion script",
+		Description: "Output a script providing tab completion of cf's " +
+			"commands, subcommands, flags and record types in bash, zsh " +
+			"or fish. The script is generated from the commands " +
+			"themselves, so regenerate it after upgrading cf. For " +
+			"example, add 'source <(cf completion bash)' to ~/.bashrc, " +
+			"or run 'cf completion fish > " +
+			"~/.config/fish/completions/cf.fish'.",
+		Usage: "completion bash|zsh|fish",
+		Data:  cmdCompletion,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "copy",
+		Brief: "Copy DNS record(s) to another zone",
+		Description: "Copy all DNS records matching the requested type and " +
+			"name, or with --all every record, from the currently active " +
+			"zone to another zone in the account. Record names, and the " +
+			"targets of CNAME, MX and similar records, are moved from the " +
+			"active zone to the target zone. Records already present in " +
+			"the target zone are skipped.",
+		Usage: "copy <type> <name> <target-zone> | copy --all <target-zone>",
+		Data:  cmdCopy,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "migrate",
+		Brief: "Migrate records from another DNS provider",
+		Description: "Create the records of a zone hosted by another DNS " +
+			"provider in the currently active zone. \"route53\" reads the " +
+			"JSON output of \"aws route53 list-resource-record-sets\", " +
+			"\"digitalocean\" (or \"zonefile\") reads an exported zone " +
+			"file, and \"axfr\" transfers the zone from an authoritative " +
+			"server that allows zone transfers. The zone's SOA and apex NS " +
+			"records, records outside the zone, and records already " +
+			"present are skipped. Route53 alias records become CNAME " +
+			"records, which Cloudflare flattens at the zone apex. The " +
+			"records to create are listed and must be confirmed unless " +
+			"--force is given.",
+		Usage: "migrate [--force] route53|digitalocean|zonefile <file> | migrate [--force] axfr <server>[:<port>]",
+		Data:  cmdMigrate,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "account",
+		Brief: "List accounts and check credentials",
+		Description: "\"account\" (or \"account list\") lists the " +
+			"accounts the credentials can access. \"account verify\" " +
+			"checks the credentials in use, showing where they were found " +
+			"(a profile, the environment, the system keyring or a prompt), " +
+			"their type, and for an API token its status and expiry; it " +
+			"exits with status 3 if they are rejected. \"account " +
+			"permissions\" lists the permissions of the API token in use " +
+			"and the accounts and zones they apply to. Reading them " +
+			"requires the token's \"API Tokens Read\" permission; without " +
+			"it, the accounts and zones the token can access are listed " +
+			"instead.",
+		Usage: "account [list|verify|permissions]",
+		Data:  cmdAccount,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "report",
+		Brief: "Produce inventory, access and egress reports",
+		Description: "Produce a report. \"report inventory\" covers " +
+			"every zone the credentials can access, listing each zone's " +
+			"plan, status and DNSSEC state, and each of its DNS records " +
+			"with its proxy status. \"report access\" reports who can " +
+			"and did change a zone, by default the active zone: the " +
+			"account members whose roles or policies cover it, the API " +
+			"tokens of the credentials' user granted permissions on it, " +
+			"and the actors of its audit log entries since --since " +
+			"(default 30d). Sections the credentials may not read are " +
+			"noted and left empty. \"report egress\" maps the origins " +
+			"of the proxied records of a zone, by default the active zone, " +
+			"to the networks announcing their addresses, and summarizes " +
+			"the origins by provider, marking the members of the Bandwidth " +
+			"Alliance, whose egress to Cloudflare costs less or nothing. " +
+			"Proxied CNAME records are resolved first. --format selects " +
+			"JSON (the default), CSV with a row for each record, member, " +
+			"token, actor or origin address, or an HTML page, and " +
+			"--output writes the report to a file instead of displaying it.",
+		Usage: "report inventory [--format json|csv|html] [--output <file>] | " +
+			"report access [--since <age>] [--format json|csv|html] [--output <file>] [<zone>] | " +
+			"report egress [--format json|csv|html] [--output <file>] [<zone>]",
+		Data: cmdReport,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "rlookup",
+		Brief: "Find records pointing at an address or host",
+		Description: "List the records in the currently active zone that " +
+			"point at a target: the A and AAAA records holding an address, " +
+			"or any address in a network given in CIDR notation such as " +
+			"203.0.113.0/24, and the CNAME records naming a hostname. Use " +
+			"--zone to search another zone, or --all-zones to search every " +
+			"zone in the account, for example to find everything still " +
+			"pointing at a server before decommissioning it.",
+		Usage: "rlookup [--zone <name>|--all-zones] <address|network|hostname>",
+		Data:  cmdRlookup,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "replace-ip",
+		Brief: "Point records at a new address",
+		Description: "Change every A or AAAA record whose content is the " +
+			"old address to the new address, in the currently active zone " +
+			"or, with --all-zones, in every zone. The records to change " +
+			"are listed and must be confirmed unless --force is given. " +
+			"Use the global --dry-run flag to see the planned changes " +
+			"without making them.",
+		Usage: "replace-ip [--all-zones] [--force] <old-ip> <new-ip>",
+		Data:  cmdReplaceIP,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "rename",
+		Brief: "Rename DNS record(s)",
+		Description: "Change the name of all DNS records matching the " +
+			"requested type and name in the currently active zone. The " +
+			"records are updated in place, so their content, TTL, proxy " +
+			"status and priority are preserved.",
+		Usage: "rename <type> <oldname> <newname>",
+		Data:  cmdRename,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "zone",
+		Brief: "Set, create or delete a zone",
+		Description: "Set the active zone used by all future commands. " +
+			"\"zone create\" adds a new zone to the account and displays " +
+			"the nameservers to configure at the registrar; --jumpstart " +
+			"scans for existing DNS records, and --account selects the " +
+			"account when the credentials have access to more than one. " +
+			"\"zone delete\" removes a zone and all of its records after " +
+			"asking for confirmation, which --force (or -y) skips. " +
+			"\"zone offboard\" reports what is needed to move the active " +
+			"zone to another DNS provider: its DNSSEC state, the proxied " +
+			"hostnames whose origins will be exposed, the page rules that " +
+			"have no DNS equivalent, and a zone file of all records, which " +
+			"--export writes to a file instead. \"zone compare\" displays " +
+			"the differences between the records of two zones, ignoring " +
+			"the zone names, to verify that mirrored or migrated zones " +
+			"are in sync. \"zone clear\" clears the active zone, so " +
+			"that the next interactive session does not resume it. " +
+			"\"zone onboard\" adds a domain in one step: it creates the " +
+			"zone, imports its records with a zone transfer from the " +
+			"--axfr server or else by letting Cloudflare scan for " +
+			"common records, enables Always Use HTTPS and a minimum TLS " +
+			"version of 1.2 unless --no-settings is given, displays the " +
+			"nameservers to set at the registrar, and polls every " +
+			"--interval (default 1m) until the zone is active, for at " +
+			"most --wait-timeout (default 24h), unless --no-wait is " +
+			"given. Run again, it continues with the existing zone. " +
+			"\"zone pull\" saves a replica of the records, settings and " +
+			"rules of the active zone, the --zone zone or all zones in the " +
+			"state directory, which list, get, search, lint and graph read " +
+			"when cf is started with --offline. \"zone unlock\" removes " +
+			"the lock a process left on the active zone when zone_locks " +
+			"is set in the configuration file, after asking for " +
+			"confirmation, which --force (or -y) skips. \"zone set-id\" " +
+			"makes the zone with an ID the active zone without looking up " +
+			"its name, for API tokens that cannot list zones; the name is " +
+			"read from the zone's details when it is not given.",
+		Usage: "zone <name> | zone create [--account <id>] [--jumpstart] <name> | " +
+			"zone delete [--force] <name> | zone offboard [--export <file>] | " +
+			"zone compare <zone> <zone> | zone clear | " +
+			"zone onboard [--account <id>] [--axfr <server>] [--no-settings] [--force] " +
+			"[--no-wait] [--interval <duration>] [--wait-timeout <duration>] <domain> | " +
+			"zone pull [--zone <name>|--all-zones] | zone unlock [--force] | zone set-id <id> [<name>]",
+		Data: cmdSetZone,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "crawlers",
+		Brief: "View or change crawler settings",
+		Description: "View or change the crawler settings of the currently " +
+			"active zone. Without arguments, the current settings are " +
+			"displayed. Use \"hints on|off\" to toggle crawler hints, " +
+			"\"ai block|allow\" to block or allow known AI crawlers, and " +
+			"\"robots on|off\" to toggle Cloudflare's managed robots.txt.",
+		Usage: "crawlers [hints|ai|robots <value>]",
+		Data:  cmdCrawlers,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "ddns",
+		Brief: "Keep a record updated with this machine's public IP",
+		Description: "Periodically detect this machine's public IP address " +
+			"and keep the A (and, with -6, AAAA) record of the given name " +
+			"in the currently active zone pointing at it. The address is " +
+			"detected using an HTTP service that echoes the caller's " +
+			"address (--service) or read from a network interface " +
+			"(--interface). --source lists other sources, separated by " +
+			"commas and tried in order: an http(s) URL, interface:<name>, " +
+			"nat-pmp:<gateway>, upnp or command:<command>. Without these " +
+			"options, the ip_sources of the configuration file are used. " +
+			"The check is repeated every interval " +
+			"(default 5m) until the program is interrupted, unless --once " +
+			"is given. Without a name, the records listed under \"ddns\" " +
+			"in the configuration file, which may belong to several zones, " +
+			"are all kept pointing at the address. A hangup signal " +
+			"(SIGHUP) makes the running command reload the configuration " +
+			"file and credentials.",
+		Usage: "ddns [-4] [-6] [--interval <duration>] [--service <url>] " +
+			"[--interface <name>] [--source <sources>] [--once] [<name>]",
+		Data: cmdDDNS,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "serve",
+		Brief: "Accept dynamic DNS updates from routers and devices",
+		Description: "Listen at the given address, such as :8245, for " +
+			"updates sent with the DynDNS2 protocol to /nic/update by " +
+			"routers, NAS devices and other dynamic DNS clients, and " +
+			"point their A or AAAA records at the addresses they report. " +
+			"Each client authenticates with a username and password " +
+			"listed under \"ddns_clients\" in the configuration file, " +
+			"together with the zone and names of the records it may " +
+			"update. The endpoint serves plain HTTP; expose it through a " +
+			"TLS-terminating proxy when clients reach it over an untrusted " +
+			"network. A hangup signal (SIGHUP) makes the running command " +
+			"reload the configuration file and credentials.",
+		Usage: "serve --ddns-endpoint <address>",
+		Data:  cmdServe,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "failover",
+		Brief: "Fail records over to standby addresses",
+		Description: "Check the health of the primary address of each " +
+			"record listed under \"failover\" in the configuration file " +
+			"every interval (default 30s), pointing the record at its " +
+			"standby address after down_after failed checks in a row " +
+			"(default 3) and back after up_after passed checks in a row " +
+			"(default 5). Each change is logged and sent to the record's " +
+			"notify URL or command. The checks continue until the program " +
+			"is interrupted, unless --once is given.",
+		Usage: "failover [--interval <duration>] [--once]",
+		Data:  cmdFailover,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "pin",
+		Brief: "Keep pinned records at their expected values",
+		Description: "Check each record listed under \"pinned\" in the " +
+			"configuration file every interval (default 5m), and " +
+			"re-apply its expected content, and its TTL and proxy status " +
+			"when given, whenever something else has changed it. Each " +
+			"correction is logged and sent to the record's notify URL or " +
+			"command. The checks continue until the program is " +
+			"interrupted, unless --once is given.",
+		Usage: "pin [--interval <duration>] [--once]",
+		Data:  cmdPin,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:        "zones",
+		Brief:       "List all zones",
+		Description: "List the name, ID, plan and status of every zone in the account.",
+		Usage:       "zones",
+		Data:        cmdZones,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "trace",
+		Brief: "Trace how a request would be handled",
+		Description: "Use Cloudflare Trace to show which rules, page rules, " +
+			"workers and cache settings would apply to a request for the " +
+			"given URL in the currently active zone. Only matching steps " +
+			"are displayed unless --all is given.",
+		Usage: "trace [--method <method>] [--all] <url>",
+		Data:  cmdTrace,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "limits",
+		Brief: "Display zone plan limits and usage",
+		Description: "Display how many DNS records, page rules and custom " +
+			"firewall rules the currently active zone's plan allows, " +
+			"alongside how many are in use. Record and custom rule limits " +
+			"are the documented defaults for the zone's plan. The features " +
+			"offered only on some plans, which commands check for before " +
+			"using them, are listed with whether the plan includes them.",
+		Usage: "limits",
+		Data:  cmdLimits,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "profile",
+		Brief: "List or select configuration profiles",
+		Description: "Without arguments, list the profiles defined in the " +
+			"configuration file, marking the active profile with an " +
+			"asterisk. With a name, make that profile active, replacing " +
+			"the current credentials and active zone.",
+		Usage: "profile [<name>]",
+		Data:  cmdProfile,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "logout",
+		Brief: "Remove credentials stored in the system keyring",
+		Description: "Remove the credentials of the active profile from the " +
+			"system keyring and forget the credentials used by the " +
+			"current session.",
+		Usage: "logout",
+		Data:  cmdLogout,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "set",
+		Brief: "View or change session settings",
+		Description: "Display the current session settings, or change one. " +
+			"\"set dry-run on\" makes all commands display the API requests " +
+			"that would change records or zones instead of sending them; " +
+			"the same mode may be enabled at startup with --dry-run. " +
+			"\"set page-size\" sets the number of records requested per " +
+			"page when listing large zones, as does --page-size at startup. " +
+			"\"set owner\" sets the owner ID recorded in the ownership " +
+			"marker (a companion TXT record) of every record cf creates, " +
+			"and prevents cf from changing records marked as owned by " +
+			"another owner; \"set owner off\" disables ownership tracking. " +
+			"A profile's \"owner\" setting selects an owner at startup. " +
+			"\"set timeout\" limits how long each API request may take " +
+			"(default 30s), as does --timeout at startup; \"off\" removes " +
+			"the limit. \"set concurrency\" sets the number of records " +
+			"bulk commands change at once (default 5), as does " +
+			"--concurrency at startup. \"set resolvers\" sets the " +
+			"comma-separated public resolvers queried by verify and " +
+			"--wait, as does --resolvers at startup. \"set output\" " +
+			"selects text or JSON output, as does a profile's \"output\" " +
+			"setting. \"set ttl\" sets the TTL of records created " +
+			"without one (default auto). \"set confirm off\" answers yes " +
+			"to every confirmation question, as if each command had been " +
+			"given --force. \"set color\" colors output always (on), " +
+			"never (off) or only on a terminal when NO_COLOR is not set " +
+			"(auto, the default). \"set redact on\" masks IP addresses, " +
+			"tokens and TXT record secrets in all output, showing only " +
+			"their beginnings, as does --redact at startup. \"set " +
+			"fail-fast on\" ends a script or interactive session at the " +
+			"first failing command, as does --fail-fast at startup; it is " +
+			"on in scripts unless --continue-on-error is given. \"set " +
+			"cache\" sets how long zone lookups and record lists are " +
+			"reused without asking the API again (30s in interactive " +
+			"sessions, otherwise off), as does the cache_ttl setting of " +
+			"the configuration file. \"set shadow\" names a staging zone " +
+			"in which every record change is also made, before it is made " +
+			"in the zone it was meant for, as does --shadow at startup; " +
+			"\"set shadow-only on\" makes changes only in the staging " +
+			"zone, as does --shadow-only.",
+		Usage: "set [dry-run on|off] | set [page-size <n>] | set [owner <id>|off] | " +
+			"set [timeout <duration>|off] | set [concurrency <n>] | set [resolvers <list>] | " +
+			"set [output text|json] | set [ttl <ttl>] | set [confirm on|off] | set [color auto|on|off] | " +
+			"set [redact on|off] | set [fail-fast on|off] | set [cache <duration>|off] | " +
+			"set [shadow <zone>|off] | set [shadow-only on|off]",
+		Data: cmdSet,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "state",
+		Brief: "Export or import the session context",
+		Description: "\"state export\" writes the active profile and zone " +
+			"and the values of the settings changed by \"set\", such as " +
+			"dry-run mode, output format and TTL, as JSON to a file or " +
+			"to standard output. \"state import\" restores them from such " +
+			"a file, so that a teammate can reproduce the session context " +
+			"a runbook refers to. Credentials are not exported; the " +
+			"profile must exist in the importing user's configuration " +
+			"file.",
+		Usage: "state export [<file>] | state import <file>",
+		Data:  cmdState,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "refresh",
+		Brief: "Discard cached zones and records",
+		Description: "Discard the zone lookups and record lists cached " +
+			"for the session's cache lifetime (see \"set cache\"), and " +
+			"those kept in the state directory with the disk_cache " +
+			"setting, so that the next commands see changes made outside " +
+			"cf, and look up the active zone again.",
+		Usage: "refresh",
+		Data:  cmdRefresh,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "exists",
+		Brief: "Test whether a DNS record exists",
+		Description: "Test whether any DNS record matching the requested " +
+			"type and name exists in the currently active zone. Nothing " +
+			"is displayed; in non-interactive mode the exit status is 0 " +
+			"if a record exists and 1 otherwise.",
+		Usage: "exists <type> <name>",
+		Data:  cmdExists,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "get",
+		Brief: "Display a single field of a DNS record",
+		Description: "Display one field of the DNS record matching the " +
+			"requested type and name in the currently active zone, with " +
+			"no other output. The field is chosen with --field and may be " +
+			"id, type, name, content, ttl, proxied, priority or comment; " +
+			"the default is content. It is an error for more or " +
+			"fewer than one record to match. With --compare, the " +
+			"records configured in Cloudflare are displayed beside the " +
+			"answers of the public resolvers (see \"verify\"), and each " +
+			"difference is flagged with its likely cause: a proxied " +
+			"record served with its origin's address, an unproxied one " +
+			"still served with Cloudflare's, a value cached by a " +
+			"resolver, or a record served by another provider. The " +
+			"command then fails if any resolver disagrees.",
+		Usage: "get [--field <field>] <type> <name> | get --compare <type> <name>",
+		Data:  cmdGet,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "show",
+		Brief: "Display every field of DNS records",
+		Description: "Display every field of the DNS records matching the " +
+			"requested type and name in the currently active zone: ID, " +
+			"content, TTL, proxy status, priority, lock status, comment, " +
+			"tags, creation and modification times, and zone ID. With " +
+			"--id, the record with the requested ID is displayed instead.",
+		Usage: "show <type> <name> | show --id <record-id>",
+		Data:  cmdShow,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "cert",
+		Brief: "Check edge certificates and manage origin certificates",
+		Description: "\"cert coverage\" lists the proxied hostnames in the " +
+			"currently active zone that are not covered by any active edge " +
+			"certificate. Visitors to these hostnames are served a " +
+			"certificate that does not match, which commonly happens with " +
+			"subdomains more than one level deep, since universal " +
+			"certificates only cover the zone and its first-level " +
+			"subdomains. \"cert create\" has Cloudflare's Origin CA issue " +
+			"a certificate for hostnames in the active zone, which origin " +
+			"servers present to Cloudflare with Full (strict) SSL. The " +
+			"private key is generated locally, an RSA key unless --type " +
+			"ecc is given, and written with the certificate to <prefix>.key " +
+			"and <prefix>.pem, where the prefix is --out or the first " +
+			"hostname. Existing files are kept unless --force is given. " +
+			"--validity sets the validity in days: 7, 30, 90, 365, 730, " +
+			"1095 or 5475 (the default). \"cert list\" lists the zone's " +
+			"origin certificates, and \"cert revoke\" revokes one by its " +
+			"ID after asking for confirmation, unless --force is given.",
+		Usage: "cert coverage | cert create [--validity <days>] [--type rsa|ecc] [--out <prefix>] [--force] <hostname> ... | " +
+			"cert list | cert revoke [--force] <id>",
+		Data: cmdCert,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "ssl",
+		Brief: "Manage custom SSL certificates",
+		Description: "Manage the custom certificates of the currently " +
+			"active zone, available on Business and Enterprise plans. " +
+			"\"ssl upload-cert\" uploads a PEM-encoded certificate, " +
+			"optionally followed by its chain, and its private key, after " +
+			"checking that they match and that the certificate has not " +
+			"expired. --bundle-method selects how Cloudflare completes the " +
+			"chain: ubiquitous (the default), optimal or force, which uses " +
+			"the chain as uploaded. --type selects sni_custom (the default) " +
+			"or legacy_custom. \"ssl list-custom\" lists the certificates " +
+			"in order of priority, \"ssl priority\" sets the priorities of " +
+			"certificates, given as pairs of IDs and priorities, which " +
+			"decide between certificates covering the same hostnames, and " +
+			"\"ssl delete-custom\" deletes a certificate after asking for " +
+			"confirmation, unless --force is given.",
+		Usage: "ssl upload-cert [--bundle-method ubiquitous|optimal|force] [--type sni_custom|legacy_custom] <cert.pem> <key.pem> | " +
+			"ssl list-custom | ssl priority <id> <priority> [<id> <priority> ...] | ssl delete-custom [--force] <id>",
+		Data: cmdSSL,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "dns",
+		Brief: "View or change DNS settings",
+		Description: "View or change the DNS settings of the currently " +
+			"active zone, or with --account, the defaults applied to new " +
+			"zones in an account. Without a setting, the current settings " +
+			"are displayed. The settings are foundation-dns, " +
+			"multi-provider and secondary-overrides, which take on or " +
+			"off; nameservers, which takes standard, account, tenant or " +
+			"zone; and ns-ttl, which takes a TTL in seconds.",
+		Usage: "dns [--account <id>] [<setting> <value>]",
+		Data:  cmdDNSSettings,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "purge",
+		Brief: "Purge cached content",
+		Description: "Purge content from Cloudflare's cache for the " +
+			"currently active zone. \"purge all\" purges everything after " +
+			"asking for confirmation, which --force (or -y) skips. The url, " +
+			"tag, host and prefix forms purge only the listed URLs, cache " +
+			"tags, hostnames or URL prefixes. Purging by tag, host or " +
+			"prefix requires an Enterprise plan, and is refused on other " +
+			"plans without calling the API.",
+		Usage: "purge [--force] all | purge url|tag|host|prefix <value>...",
+		Data:  cmdPurge,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "version",
+		Brief: "Display the version and check for updates",
+		Description: "Display the version of cf and check whether a newer " +
+			"release is available on GitHub.",
+		Usage: "version",
+		Data:  cmdVersion,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "self-update",
+		Brief: "Update cf to the latest release",
+		Description: "Download the latest release of cf for this operating " +
+			"system and architecture from GitHub, verify it against the " +
+			"SHA256 checksums published with the release, and replace the " +
+			"running executable with it.",
+		Usage: "self-update",
+		Data:  cmdSelfUpdate,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "settings",
+		Brief: "View or change zone settings",
+		Description: "View or change the settings of the currently active " +
+			"zone, such as ssl, always_use_https, development_mode or " +
+			"minify. \"settings list\" displays every setting, \"settings " +
+			"get\" displays one, and \"settings set\" changes one. Values " +
+			"that are JSON objects, such as minify's, are given as JSON: " +
+			"settings set minify '{\"css\":\"on\",\"js\":\"on\",\"html\":\"off\"}'.",
+		Usage: "settings list | settings get <name> | settings set <name> <value>",
+		Data:  cmdSettings,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "devmode",
+		Brief: "View or toggle development mode",
+		Description: "Display whether development mode is enabled for the " +
+			"currently active zone, or turn it on or off. Development mode " +
+			"bypasses Cloudflare's cache and turns itself off after three " +
+			"hours.",
+		Usage: "devmode [on|off]",
+		Data:  cmdDevMode,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "redirect",
+		Brief: "Manage redirect rules",
+		Description: "Manage the redirect rules of the currently active " +
+			"zone. \"redirect list\" displays the rules, \"redirect add\" " +
+			"adds a rule redirecting URLs matching a pattern such as " +
+			"'old.example.com/*' to a target such as " +
+			"'https://new.example.com/$1', where $1, $2, ... are replaced " +
+			"by the text matched by the pattern's wildcards. The status " +
+			"code may be 301 (the default), 302, 307 or 308. \"redirect " +
+			"delete\" removes a rule by its number in the list or its ID.",
+		Usage: "redirect list | redirect add <pattern> <target> [<status>] | " +
+			"redirect delete <number|id>",
+		Data: cmdRedirect,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "firewall",
+		Brief: "Manage firewall rules",
+		Description: "Manage the firewall rules of the currently active " +
+			"zone. \"firewall list\" displays the zone's IP access rules " +
+			"and custom rules. \"firewall block\", \"challenge\", " +
+			"\"js-challenge\", \"managed-challenge\" and \"allow\" add an " +
+			"IP access rule applying to an IP address or range, a " +
+			"two-letter country code or an autonomous system number, as " +
+			"in \"firewall block ip 203.0.113.7\" or \"firewall challenge " +
+			"country CN\". \"firewall add\" adds a custom rule applying " +
+			"an action (block, challenge, js-challenge, " +
+			"managed-challenge or log) to requests matching a rules " +
+			"language expression, which is checked as \"expr test\" " +
+			"does before it is submitted, and which is read from a file " +
+			"with --file if it contains strings or lists. --note attaches " +
+			"a note to a new rule. \"firewall delete\" removes an IP " +
+			"access rule by its ID or value, or a custom rule by its ID.",
+		Usage: "firewall list | firewall <action> ip|country|asn <value> [--note <text>] | " +
+			"firewall add <action> <expression> [--note <text>] | " +
+			"firewall add <action> --file <path> [--note <text>] | firewall delete <id|value>",
+		Data: cmdFirewall,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "expr",
+		Brief: "Check a rule expression",
+		Description: "Check a Cloudflare rules language expression, as " +
+			"used by WAF, redirect and cache rules, without submitting " +
+			"it. The syntax, the field and function names, and the types " +
+			"of the values compared are checked, and the position of the " +
+			"first problem found is displayed. Since the command line " +
+			"removes double quotes and expands $ references, an " +
+			"expression containing strings or lists is read from a file " +
+			"with --file.",
+		Usage: "expr test <expression> | expr test --file <path>",
+		Data:  cmdExpr,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "email",
+		Brief: "Manage email routing",
+		Description: "Manage Cloudflare Email Routing for the currently " +
+			"active zone. \"email status\" displays whether routing is " +
+			"enabled. \"email enable\" enables it and creates any of the " +
+			"MX and TXT records it requires that the zone lacks, and " +
+			"\"email disable\" disables it after asking for confirmation, " +
+			"which --force (or -y) skips. \"email list\" displays the " +
+			"routing rules, and \"email add\" adds a rule forwarding an " +
+			"address of the zone, given in full or as the part before the " +
+			"@, to a destination address, which receives a verification " +
+			"email if the account has not used it before. \"email " +
+			"delete\" removes the rule for an address, or with a tag.",
+		Usage: "email status | email enable | email disable [--force] | email list | " +
+			"email add <alias> <destination> | email delete [--force] <alias|tag>",
+		Data: cmdEmail,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "mail",
+		Brief: "Set and check SPF, DKIM and DMARC records",
+		Description: "Manage the mail authentication records of the " +
+			"currently active zone. \"mail spf set\" validates an SPF " +
+			"policy and sets it as the zone's only one, updating the " +
+			"existing policy or creating it; if the zone has several, the " +
+			"extra ones are deleted after asking for confirmation, which " +
+			"--force (or -y) skips. \"mail dmarc set\" creates or updates " +
+			"the _dmarc record, keeping the tags of an existing policy not " +
+			"given as options; --policy is required when there is none. " +
+			"\"mail check\" checks the zone's SPF policies, DKIM keys and " +
+			"DMARC policy for syntax errors and common mistakes, such as " +
+			"several SPF policies, +all, or more than 10 DNS lookups, " +
+			"following includes. The command fails if an error is found.",
+		Usage: "mail spf set [--force] <policy> | " +
+			"mail dmarc set [--policy none|quarantine|reject] [--subdomain-policy <policy>] " +
+			"[--pct <n>] [--rua <uri>] [--ruf <uri>] | mail check",
+		Data: cmdMail,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "srv",
+		Brief: "Create the SRV records of common services",
+		Description: "\"srv wizard\" creates the SRV record of a common " +
+			"service in the currently active zone, named with the " +
+			"service's labels, such as _sip._tls or _minecraft._tcp, so " +
+			"that only the host and port need to be known. They are " +
+			"asked for when not given with --host and --port and standard " +
+			"input is a terminal; the port defaults to the service's " +
+			"usual one. The record belongs to the zone apex, or to the " +
+			"domain given with --name. An existing SRV record of the name " +
+			"is updated, after asking for confirmation, which --force (or " +
+			"-y) skips. \"srv services\" lists the known services with " +
+			"their labels and usual ports.",
+		Usage: "srv wizard [--host <host>] [--port <port>] [--name <domain>] [--force] <service> | " +
+			"srv services",
+		Data: cmdSRV,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "worker",
+		Brief: "Deploy, route and report on Workers",
+		Description: "\"worker list\" lists the Worker scripts of the " +
+			"active zone's account. \"worker deploy\" uploads a Worker, " +
+			"replacing any script of the same name. The source is a " +
+			"single script, named after its file, or a wrangler.json or " +
+			"wrangler.jsonc configuration file, or a directory holding " +
+			"one, for a bundle of modules. A bundle uploads its main " +
+			"module with the .js, .mjs, .cjs, .wasm, .txt, .html and .bin " +
+			"files of its base_dir, which defaults to the main module's " +
+			"directory, and its compatibility date and flags, vars, KV " +
+			"namespace, R2 bucket, D1 database, service and Durable " +
+			"Object bindings. --name overrides the configured name. " +
+			"Bundles are uploaded as they are, without building. " +
+			"\"worker routes\" lists the routes of the active zone, " +
+			"which send the requests matching a pattern, such as " +
+			"api.example.com/*, to a Worker; \"worker routes add\" adds " +
+			"one and \"worker routes delete\" deletes the route with a " +
+			"pattern or ID. \"worker subdomain\" displays the account's " +
+			"workers.dev subdomain, or changes it after confirmation. " +
+			"\"worker usage\" displays the requests, errors and median " +
+			"and 99th percentile CPU time of each script over the last " +
+			"--since (default 7d), and names the scripts that received " +
+			"no requests, which may be unused; --unused lists only " +
+			"those.",
+		Usage: "worker list | worker deploy [--name <script>] <file|dir> | " +
+			"worker routes list | worker routes add <pattern> <script> | " +
+			"worker routes delete [--force] <pattern|id> | " +
+			"worker subdomain [--force] [<name>] | " +
+			"worker usage [--since <time>] [--unused]",
+		Data: cmdWorker,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "tutorial",
+		Brief: "Walk through the basic commands",
+		Description: "\"tutorial\" runs a few commands with you in " +
+			"interactive mode: it checks the credentials, lists the zones, " +
+			"selects a zone, lists its records, and creates, displays and " +
+			"deletes a temporary TXT record. Each command is shown before " +
+			"it runs; press Enter to run it, s to skip it, or q to quit. " +
+			"The test record is deleted when the tutorial ends.",
+		Usage: "tutorial",
+		Data:  cmdTutorial,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "template",
+		Brief: "Create records from a template",
+		Description: "\"template apply\" expands a record template and " +
+			"creates or updates the records it describes in the currently " +
+			"active zone, as \"upsert\" does. A template is a Go text " +
+			"template producing one record per line, either as TYPE NAME " +
+			"CONTENT [TTL] [proxied|unproxied] or as a line of JSON. Its " +
+			"parameters are given as key=value arguments and used as " +
+			"{{.key}}; {{.Zone}} is the active zone. Record names outside " +
+			"the zone, such as \"www\" or \"@\", are relative to it. " +
+			"Besides Go's template functions, \"split\" splits a list, as " +
+			"in {{range split .ips \",\"}}, and \"default\" supplies a " +
+			"value for an empty parameter. \"template show\" displays the " +
+			"records without changing them.",
+		Usage: "template apply|show <file> [<key>=<value> ...]",
+		Data:  cmdTemplate,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "nameservers",
+		Brief: "Show nameservers and delegate subdomains",
+		Description: "\"nameservers\" (or \"nameservers status\") " +
+			"displays the nameservers Cloudflare assigned to the currently " +
+			"active zone, the nameservers the public resolvers (see " +
+			"\"verify\") find the zone delegated to, and whether the " +
+			"registrar delegates it to the assigned nameservers yet, " +
+			"followed by the subdomains the zone delegates with NS records. " +
+			"\"nameservers delegate\" delegates a subdomain to a " +
+			"comma-separated list of nameservers, replacing its NS records " +
+			"in a single atomic change. The optional TTL is given in " +
+			"seconds, or \"auto\" to let Cloudflare choose. --comment sets " +
+			"the records' comment, and --tag sets their tags, separated by " +
+			"commas. Single NS records may also be added with \"add NS\".",
+		Usage: "nameservers [status] | nameservers delegate [--comment <text>] [--tag <tags>] " +
+			"<name> <nameserver>[,<nameserver>...] [<ttl>]",
+		Data: cmdNameservers,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "dnssec",
+		Brief: "Manage DNSSEC",
+		Description: "Display, enable or disable DNSSEC for the currently " +
+			"active zone. \"dnssec status\" and \"dnssec enable\" " +
+			"display the DS record details (key tag, algorithm and digest) " +
+			"to configure at the registrar. \"dnssec disable\" asks for " +
+			"confirmation, which --force (or -y) skips.",
+		Usage: "dnssec status | dnssec enable | dnssec disable [--force]",
+		Data:  cmdDNSSEC,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "external-dns",
+		Brief: "Inspect, adopt or release external-dns records",
+		Description: "Work with the records in the currently active zone " +
+			"that external-dns manages, as recorded by its ownership TXT " +
+			"records. \"external-dns list\" displays the owned records " +
+			"with their owner and source resource, optionally only those " +
+			"of one --owner, along with markers whose records no longer " +
+			"exist. \"external-dns adopt\" makes cf the owner of records " +
+			"by replacing external-dns's marker with cf's own, and requires " +
+			"an owner to be set (see \"set owner\"). \"external-dns " +
+			"release\" hands records to an external-dns owner.",
+		Usage: "external-dns list [--owner <id>] | " +
+			"external-dns adopt [--force] <type> <name> | " +
+			"external-dns release --owner <id> <type> <name>",
+		Data: cmdExternalDNS,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "tunnel",
+		Brief: "Route hostnames to Cloudflare Tunnels",
+		Description: "\"tunnel list\" displays the name, ID, status and " +
+			"number of connections of each Cloudflare Tunnel in the " +
+			"currently active zone's account. \"tunnel route\" creates " +
+			"or updates the proxied CNAME record pointing a hostname in " +
+			"the active zone at a tunnel, given by its name or ID.",
+		Usage: "tunnel list | tunnel route <hostname> <tunnel>",
+		Data:  cmdTunnel,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "lb",
+		Brief: "Manage load balancer pools and origins",
+		Description: "\"lb list\" displays the load balancers of the " +
+			"currently active zone and the pools they steer traffic to. " +
+			"\"lb pools\" and \"lb monitors\" display the load balancer " +
+			"pools, with their origins, and the health monitors of the " +
+			"zone's account. \"lb health\" displays how many of the data " +
+			"centers checking each origin of a pool, or of every pool, " +
+			"find it healthy, and why the others do not. \"lb origin " +
+			"enable\" and \"lb origin disable\" put an origin, given by " +
+			"its name or address, into or out of rotation. Disabling an " +
+			"origin asks for confirmation, which --force (or -y) skips.",
+		Usage: "lb list | lb pools | lb monitors | lb health [<pool>] | " +
+			"lb origin enable|disable [--force] <pool> <origin>",
+		Data: cmdLB,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "k8s",
+		Brief: "Propose records for Kubernetes services",
+		Description: "Read the Ingress and LoadBalancer Service resources " +
+			"of a Kubernetes cluster with kubectl and propose the DNS " +
+			"records pointing their hostnames at their load balancers, " +
+			"for clusters that do not run external-dns. Service hostnames " +
+			"are read from the external-dns.alpha.kubernetes.io/hostname " +
+			"annotation. Only hostnames in the currently active zone are " +
+			"considered. --context selects the kubeconfig context, " +
+			"--namespace limits the scan to one namespace, and --target " +
+			"points every hostname at the given address or hostname " +
+			"instead. --on-conflict treats hostnames whose records hold " +
+			"other content as by the import command. With --apply, the " +
+			"proposed changes are made after confirmation, which --force " +
+			"(or -y) skips.",
+		Usage: "k8s scan [--context <name>] [--namespace <ns>] " +
+			"[--target <address>] [--on-conflict <strategy>] [--apply [--force]]",
+		Data: cmdK8s,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "docker",
+		Brief: "Point container hostnames at this machine",
+		Description: "Inspect the running Docker containers for a " +
+			"hostname label (cf.hostname by default, or --label) and keep " +
+			"the A (and, with -6, AAAA) records of the listed hostnames in " +
+			"the currently active zone pointing at this machine's public " +
+			"IP address. A label may list several hostnames separated by " +
+			"commas. The address is detected as by the ddns command, using " +
+			"--service, --interface or --source. The containers are inspected every " +
+			"interval (default 5m) until the program is interrupted, " +
+			"unless --once is given.",
+		Usage: "docker sync [-4] [-6] [--label <label>] [--interval <duration>] " +
+			"[--service <url>] [--interface <name>] [--source <sources>] [--once]",
+		Data: cmdDocker,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "verify-token",
+		Brief: "Add a domain verification record",
+		Description: "Add the record a service asks for to verify the " +
+			"ownership of the currently active zone, given the service and " +
+			"the token it provides. The token may be given with or without " +
+			"the prefix shown in the service's instructions. Most services " +
+			"use a TXT record at the zone's apex, which is added alongside " +
+			"the apex's other TXT records and replaces only an earlier " +
+			"token of the same service; others use a CNAME record named " +
+			"after the token. \"verify-token list\" displays the known " +
+			"services and the records they use. With --wait, the command " +
+			"waits until the record is served, for at most --wait-timeout " +
+			"(default 2m).",
+		Usage: "verify-token [--wait [--wait-timeout <duration>]] <provider> <token> | verify-token list",
+		Data:  cmdVerifyToken,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "upsert",
+		Brief: "Create or update DNS records",
+		Description: "Converge DNS records of the currently active zone: " +
+			"the first record with each type and name is updated to hold " +
+			"the content, TTL and proxy status given, or a record is " +
+			"created if none exists. With \"-\", records are read from " +
+			"standard input, one per line, either as TYPE NAME CONTENT " +
+			"[TTL] [proxied|unproxied], with content containing spaces " +
+			"in double quotes, or as a JSON object with type, name, " +
+			"content, ttl and proxied fields. Blank lines and lines " +
+			"starting with # are ignored. All lines are checked before " +
+			"any change is made, and the changes are then made " +
+			"concurrently.",
+		Usage: "upsert <type> <name> <content> [<ttl>] [proxied|unproxied] | upsert -",
+		Data:  cmdUpsert,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "hostname",
+		Brief: "Manage custom hostnames of a SaaS zone",
+		Description: "Manage the custom hostnames of the currently active " +
+			"zone, the hostnames of a SaaS provider's customers that the " +
+			"zone serves. \"hostname add\" adds one, served by the " +
+			"zone's fallback origin or by the origin server given with " +
+			"--origin, and shows the records or files with which its " +
+			"owner proves ownership and validates its certificate by the " +
+			"--validation method: txt (the default), http or email. " +
+			"\"hostname show\" shows a hostname's status and the " +
+			"validation still outstanding.",
+		Usage: "hostname list | hostname add [--origin <server>] [--validation txt|http|email] <hostname> | " +
+			"hostname show <hostname> | hostname delete [--force] <hostname>",
+		Data: cmdHostname,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "import",
+		Brief: "Create records for a reverse proxy's hostnames",
+		Description: "Read the hostnames served by a reverse proxy from its " +
+			"configuration file and create the records pointing them at " +
+			"the origin given by --target, an IP address (for A or AAAA " +
+			"records) or a hostname (for CNAME records). --from selects " +
+			"the file format: caddyfile for a Caddyfile's site addresses, " +
+			"or traefik for the Host rules of a Traefik configuration in " +
+			"YAML, TOML or container labels. Only hostnames in the " +
+			"currently active zone are imported. --on-conflict selects " +
+			"what happens to a hostname whose record already holds other " +
+			"content: overwrite (the default) replaces the content, merge " +
+			"adds another record alongside the existing one (except for " +
+			"CNAME records, which are skipped), skip leaves the record " +
+			"alone, and fail makes no changes at all. The changes are " +
+			"listed and confirmation is requested before they are made, " +
+			"unless --force (or -y) is given.",
+		Usage: "import --from caddyfile|traefik --target <origin> " +
+			"[--on-conflict overwrite|merge|skip|fail] [--force] <file>",
+		Data: cmdImport,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "protect",
+		Brief: "Protect records from deletion and changes",
+		Description: "Manage the list of protected records, kept in the " +
+			"configuration file. \"protect add\" protects the records of " +
+			"a type and name in the currently active zone, such as its MX " +
+			"records or apex address, and \"protect remove\" removes the " +
+			"protection. A type of * protects records of every type. The " +
+			"delete, edit, import and k8s commands refuse to delete or " +
+			"change protected records unless --force (or -y) is given. " +
+			"Without arguments, the protected records are listed.",
+		Usage: "protect [list] | protect add|remove <type> <name>",
+		Data:  cmdProtect,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "totp",
+		Brief: "Require a TOTP code to delete from a zone",
+		Description: "Manage the zones, listed in the configuration file, " +
+			"from which nothing is deleted until a time-based one-time " +
+			"password (TOTP) is entered, as a second factor beside the API " +
+			"credentials. \"totp enable\" generates a secret for the " +
+			"currently active zone, displays it for an authenticator app " +
+			"and stores it in the system keyring once a code from the app " +
+			"is entered. \"totp disable\" requires a code and removes the " +
+			"requirement. A command deleting from such a zone asks for a " +
+			"code once, or reads it from CLOUDFLARE_TOTP_CODE when standard " +
+			"input is not a terminal. Without arguments, the zones requiring " +
+			"a code are listed.",
+		Usage: "totp [list] | totp enable|disable",
+		Data:  cmdTOTP,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "lint",
+		Brief: "Check DNS records for problems",
+		Description: "Check the DNS records of the active zone, or of the " +
+			"zones selected with --zone or --all-zones, for problems. " +
+			"--exposure lists the unproxied records revealing the origin " +
+			"address or hostname of a proxied record, either by sharing its " +
+			"content or, for SPF policies, by listing its address. --ttl " +
+			"lists the records whose TTLs violate the TTL policies of the " +
+			"configuration file. --cnames lists the CNAME records starting " +
+			"loops or chains of aliases, or pointing at names with no " +
+			"records. Without options, all checks are run. The command " +
+			"fails if any problem is found.",
+		Usage: "lint [--zone <name>|--all-zones] [--cnames] [--exposure] [--ttl]",
+		Data:  cmdLint,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "graph",
+		Brief: "Show which names point at which",
+		Description: "Display the graph of the CNAME records of the " +
+			"currently active zone as chains of aliases, each followed to " +
+			"the name it finally points at, noting names outside the zone, " +
+			"names with no records and loops. --format dot or --format " +
+			"mermaid writes the graph in the Graphviz DOT or Mermaid " +
+			"language instead, for drawing.",
+		Usage: "graph [--format text|dot|mermaid]",
+		Data:  cmdGraph,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
-		Name:        "zone",
-		Brief:       "Set active zone",
-		Description: "Set the active zone used by all future commands.",
-		Usage:       "zone <name>",
-		Data:        cmdSetZone,
+		Name:  "analytics",
+		Brief: "Display traffic analytics",
+		Description: "Display a summary of the traffic of the currently " +
+			"active zone: the number of HTTP requests and the share " +
+			"served from cache, the bandwidth used, the threats " +
+			"blocked, the number of DNS queries, and the names most " +
+			"often queried. --since and --until select the time range " +
+			"as durations before now, such as 90m, 12h or 7d, and " +
+			"default to the last 24 hours. --top sets how many query " +
+			"names are displayed. In JSON output mode, the summary is " +
+			"displayed as JSON. \"analytics compare\" compares the " +
+			"traffic of the last --period (default 7d) with that of " +
+			"the period --offset earlier (default the period's " +
+			"length), showing the change " +
+			"in requests, bandwidth, threats and cache ratio. Changes " +
+			"of --threshold percent (default 50) or more, or cache " +
+			"ratio changes of 10 points or more, are marked with \"!\", " +
+			"together with the record changes made with cf since the " +
+			"period began. --zone and --all-zones select other zones.",
+		Usage: "analytics [--since <time>] [--until <time>] [--top <n>] | " +
+			"analytics compare [--period <time>] [--offset <time>] " +
+			"[--threshold <percent>] [--zone <name>|--all-zones]",
+		Data: cmdAnalytics,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "backup",
+		Brief: "Back up every zone to zone files",
+		Description: "Export the records of every zone in the account to " +
+			"zone files in a directory, named after the zone and the " +
+			"time of the backup in UTC, such as " +
+			"example.com-20240301T101502Z.zone. With --settings, the " +
+			"zone's settings, page rules and zone-level rulesets are " +
+			"also saved, to a file such as " +
+			"example.com-20240301T101502Z.settings.json. With --keep, " +
+			"only the newest n backups of each zone are kept. The zone " +
+			"files can be compared with a zone by \"diff\", and the " +
+			"settings files by \"drift\". The command is suitable for " +
+			"running from cron.",
+		Usage: "backup --dir <path> [--settings] [--keep <n>]",
+		Data:  cmdBackup,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "diff",
+		Brief: "Compare the zone with a snapshot",
+		Description: "Compare the records of the currently active zone " +
+			"with a snapshot of them, either a zone file such as the one " +
+			"written by \"zone offboard --export\" or the JSON output of " +
+			"\"list\". Records added since the snapshot are marked +, " +
+			"removed records -, and records whose content, TTL or proxy " +
+			"setting changed ~. Nothing is modified. The command fails if " +
+			"any differences are found.",
+		Usage: "diff <file>",
+		Data:  cmdDiff,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "drift",
+		Brief: "Compare zone settings with a baseline",
+		Description: "Compare the settings, page rules and zone-level " +
+			"ruleset rules of a zone with a baseline saved by \"backup " +
+			"--settings\", reporting settings and rules added since the " +
+			"baseline with +, removed ones with -, and changed ones with " +
+			"~. The zone is the one named in the baseline. Parts of the " +
+			"configuration the credentials cannot read are not compared. " +
+			"Nothing is modified. The command fails if any differences " +
+			"are found.",
+		Usage: "drift <file>",
+		Data:  cmdDrift,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "watch",
+		Brief: "Watch the zone for changes",
+		Description: "Poll the records of the currently active zone every " +
+			"interval (default 30s), and display the records added, " +
+			"removed or modified since the previous poll, as \"diff\" " +
+			"does, whoever made the changes. The zone is watched until the " +
+			"program is interrupted.",
+		Usage: "watch [--interval <duration>]",
+		Data:  cmdWatch,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "verify",
+		Brief: "Check that public resolvers serve a DNS record",
+		Description: "Query the public resolvers (1.1.1.1 and 8.8.8.8 by " +
+			"default) for the A, AAAA, CNAME, TXT or NS records with the " +
+			"requested name. Without expected content, the values each " +
+			"resolver returns are displayed. With expected content, the " +
+			"command fails unless every resolver serves it; --wait polls " +
+			"until they do, for at most --wait-timeout (default 2m). " +
+			"Resolvers may cache a record's old value for its TTL. " +
+			"\"set resolvers\", or --resolvers at startup, selects other " +
+			"resolvers, separated by commas. --global instead checks one or " +
+			"more names from DNS-over-HTTPS resolvers in several regions at " +
+			"once, comparing their answers with the records configured in " +
+			"Cloudflare, and displays a matrix of resolver and name, as " +
+			"during a global cutover.",
+		Usage: "verify [--wait [--wait-timeout <duration>]] <type> <name> [<content>] | " +
+			"verify --global [--wait [--wait-timeout <duration>]] <type> <name> ...",
+		Data: cmdVerify,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "history",
+		Brief: "Show how a DNS record changed over time",
+		Description: "Display the changes made to the records of a type " +
+			"and name in the currently active zone, oldest first, from the " +
+			"record history kept in the state directory. Every change made " +
+			"with cf is recorded, as are the changes made elsewhere that " +
+			"\"watch\" sees. --since limits the changes to those made " +
+			"within a period such as 6h or 30d.",
+		Usage: "history [--since <age>] <type> <name>",
+		Data:  cmdHistory,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "undo",
+		Brief: "Undo a recent change to a DNS record",
+		Description: "Revert the most recent change made to a DNS record, " +
+			"or with a number n the nth most recent, using the local " +
+			"journal of record changes. A created record is deleted, an " +
+			"updated record is restored to its previous state, and a " +
+			"deleted record is recreated. A record changed since is left " +
+			"alone unless --force (or -y) is given, which also skips the " +
+			"confirmation. --list shows the most recent changes with " +
+			"their numbers. In interactive mode, undo without a number " +
+			"steps back through the changes made during the session, " +
+			"which \"redo\" makes again, as in an editor; making another " +
+			"change discards the changes that could be redone.",
+		Usage: "undo [--list] | undo [--force] [<n>]",
+		Data:  cmdUndo,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "redo",
+		Brief: "Redo a change undone during the session",
+		Description: "Make again the change most recently undone with " +
+			"\"undo\" during the interactive session, unless another " +
+			"change was made since. A record changed since is left alone " +
+			"unless --force (or -y) is given, which also skips the " +
+			"confirmation.",
+		Usage: "redo [--force]",
+		Data:  cmdRedo,
+	})
+	root.AddCommand(cmd.CommandDescriptor{
+		Name:  "retry",
+		Brief: "Reattempt changes that failed during a bulk run",
+		Description: "When changes made by a bulk command such as delete, " +
+			"ttl, tag or import fail for a transient reason, such as a " +
+			"rate limit, server error, timeout or Ctrl-C, they are saved " +
+			"to a retry queue. \"retry list\" shows the queued changes, " +
+			"\"retry run\" reattempts them, keeping those that fail again " +
+			"for a transient reason, and \"retry clear\" discards them. " +
+			"Running and clearing ask for confirmation, which --force (or " +
+			"-y) skips.",
+		Usage: "retry list|run|clear [--force]",
+		Data:  cmdRetry,
 	})
 	root.AddCommand(cmd.CommandDescriptor{
 		Name:  "quit",
@@ -115,39 +1390,271 @@ func init() {
 	root.AddShortcut("?", "help")
 	root.AddShortcut("l", "list")
 	root.AddShortcut("ip", "ip4")
+	root.AddShortcut("workers", "worker")
+	addPlugins(root)
 	cmds = root
 }
 
+// leadingFlags are the flags accepted before the command.
+var leadingFlags = flagSpec{
+	"profile":           true,
+	"zone":              true,
+	"json":              false,
+	"script":            true,
+	"continue-on-error": false,
+	"fail-fast":         false,
+	"fresh":             false,
+	"dry-run":           false,
+	"shadow":            true,
+	"shadow-only":       false,
+	"summary":           true,
+	"page-size":         true,
+	"rpc":               false,
+	"timeout":           true,
+	"retries":           true,
+	"retry-delay":       true,
+	"concurrency":       true,
+	"resolvers":         true,
+	"override-freeze":   false,
+	"audit-log":         true,
+	"verbose":           false,
+	"v":                 false,
+	"debug":             false,
+	"redact":            false,
+	"offline":           false,
+}
+
 func main() {
-	args := os.Args[1:]
-	interactive = len(args) == 0
+	flags, args, err := parseLeadingFlags(os.Args[1:], leadingFlags)
+	if err != nil {
+		printf("Error: %v\n", err)
+		exit(exitUsage)
+	}
+
+	dryRun = flags.has("dry-run")
+	offline = flags.has("offline")
+	switch {
+	case flags.has("debug"):
+		logLevel = logBodies
+	case flags.has("verbose") || flags.has("v"):
+		logLevel = logRequests
+	}
+	overrideFreeze = flags.has("override-freeze")
+	if flags.has("redact") {
+		if err := startRedacting(); err != nil {
+			printf("Error: %v\n", err)
+			os.Exit(exitFailure)
+		}
+	}
+	if flags.has("page-size") {
+		if pageSize, err = parsePageSize(flags.get("page-size", "")); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+
+	if flags.has("timeout") {
+		if requestTimeout, err = parseTimeout(flags.get("timeout", "")); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+
+	if flags.has("retries") {
+		if retries, err = parseRetries(flags.get("retries", "")); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+	if flags.has("retry-delay") {
+		if retryDelay, err = parseRetryDelay(flags.get("retry-delay", "")); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+
+	if flags.has("concurrency") {
+		if sched.workers, err = parseWorkers(flags.get("concurrency", "")); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+
+	if flags.has("resolvers") {
+		if resolvers, err = parseResolvers(flags.get("resolvers", "")); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+
+	rpc := flags.has("rpc")
+	script := flags.get("script", "")
+	if script == "" && len(args) == 0 && !rpc && !term.IsTerminal(int(os.Stdin.Fd())) {
+		script = "-"
+	}
+	interactive = len(args) == 0 && script == "" && !rpc
+	if flags.has("fail-fast") && flags.has("continue-on-error") {
+		printf("Error: --fail-fast and --continue-on-error cannot be combined.\n")
+		exit(exitUsage)
+	}
+	failFast = flags.has("fail-fast") || (script != "" && !flags.has("continue-on-error"))
 
+	if err := loadConfig(); err != nil {
+		printf("Error: %v\n", err)
+		exit(exitFailure)
+	}
+	setLocale(cfg.Locale)
 	if interactive {
-		runInteractive()
-	} else {
-		processCmd(fixupArgs(args))
+		cacheTTL = defaultCacheTTL
+	}
+	if cfg.CacheTTL != "" {
+		if cacheTTL, err = parseCacheTTL(cfg.CacheTTL); err != nil {
+			printf("Error: %v\n", err)
+			exit(exitFailure)
+		}
+	}
+	auditLogPath = flags.get("audit-log", cfg.AuditLog)
+
+	// Interactive sessions resume the profile, zone and output preferences
+	// of the previous one, unless started with --fresh.
+	var saved savedSession
+	if interactive && !flags.has("fresh") {
+		if saved, err = loadSession(); err != nil {
+			printf("Error: %v\n", err)
+		}
+	}
+	profileName := flags.get("profile", os.Getenv("CLOUDFLARE_PROFILE"))
+	if _, ok := cfg.Profiles[saved.Profile]; ok && profileName == "" {
+		profileName = saved.Profile
+	}
+	if err := selectProfile(profileName); err != nil {
+		printf("Error: %v\n", err)
+		exit(exitFailure)
+	}
+	resumeSession(saved, flags)
+	zoneFlag = flags.get("zone", "")
+	shadowZone = flags.get("shadow", "")
+	shadowOnly = flags.has("shadow-only")
+	if shadowOnly && shadowZone == "" {
+		printf("Error: --shadow-only requires --shadow.\n")
+		exit(exitUsage)
+	}
+	if flags.has("json") {
+		outputFormat = "json"
+	}
+	summaryFile = flags.get("summary", "")
+
+	switch {
+	case rpc:
+		code := runRPC()
+		finishSummaryFile("rpc", code)
+		exit(code)
+	case interactive:
+		code := runInteractive()
+		stopRedacting()
+		if err := saveSession(); err != nil {
+			printf("Error saving session: %v\n", err)
+		}
+		if code != exitSuccess {
+			exit(code)
+		}
+	case script != "":
+		code := runScript(script)
+		finishSummaryFile("script", code)
+		exit(code)
+	default:
+		code := exitCode(processArgs(args))
+		finishSummaryFile(args[0], code)
+		exit(code)
 	}
 }
 
-func runInteractive() {
+// runInteractive reads and runs commands until quit or the end of input.
+// A line beginning with !! repeats the previous command line, followed by
+// the rest of the line. With fail-fast set, the session ends at the first
+// failing command, and its exit code is returned.
+func runInteractive() int {
+	activeConsole = newConsole()
 	for {
-		line, err := readString("cf> ")
+		line, err := readCommand("cf> ")
 		if err != nil {
-			break
+			return exitSuccess
+		}
+
+		if rest, ok := strings.CutPrefix(strings.TrimSpace(line), "!!"); ok {
+			if lastResult.line == "" {
+				printf("No previous command.\n")
+				continue
+			}
+			line = lastResult.line + rest
+			fmt.Fprintln(output, line)
 		}
 
 		err = processCmd(line)
+		switch {
+		case err == errQuit:
+			return exitSuccess
+		case err != nil && failFast:
+			return lastResult.status
+		}
+	}
+}
+
+// runScript executes each line of a script file through the command tree,
+// reporting the result of every command. A filename of "-" reads the script
+// from standard input. Execution stops at the first failing command while
+// fail-fast is set. The exit code of the first failure is returned.
+func runScript(filename string) int {
+	var r io.Reader = os.Stdin
+	if filename != "-" {
+		file, err := os.Open(filename)
 		if err != nil {
+			printf("Error: %v\n", err)
+			return exitFailure
+		}
+		defer file.Close()
+		r = file
+	}
+
+	code := exitSuccess
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		err := processCmd(line)
+		if err == errQuit {
 			break
 		}
+		if err != nil {
+			fmt.Fprintf(output, "[line %d] FAILED: %s\n", lineNum, line)
+			if code == exitSuccess {
+				code = lastResult.status
+			}
+			if failFast {
+				break
+			}
+			continue
+		}
+		fmt.Fprintf(output, "[line %d] ok: %s\n", lineNum, line)
+	}
+
+	if err := scanner.Err(); err != nil {
+		printf("Error: %v\n", err)
+		return exitFailure
 	}
+	return code
 }
 
-func fixupArgs(args []string) string {
+// formatArgs joins the arguments of a command line into a line for the
+// audit log, quoting those that are empty or hold spaces.
+func formatArgs(args []string) string {
 	newArgs := []string{}
 
 	for _, a := range args {
-		if strings.ContainsAny(a, " \t") {
+		if a == "" || strings.ContainsAny(a, " \t") {
 			a = "\"" + a + "\""
 		}
 		newArgs = append(newArgs, a)
@@ -156,393 +1663,1731 @@ func fixupArgs(args []string) string {
 	return strings.Join(newArgs, " ")
 }
 
+// expandArgs replaces $VAR and ${VAR} references in command arguments with
+// the values of the corresponding environment variables, and $? with the
+// exit status of the previous command line. A literal dollar sign may be
+// written as $$. Referencing an undefined variable is an error.
+func expandArgs(args []string) ([]string, error) {
+	var undefined string
+	expanded := make([]string, len(args))
+	for i, a := range args {
+		expanded[i] = os.Expand(a, func(name string) string {
+			switch name {
+			case "$":
+				return "$"
+			case "?":
+				return strconv.Itoa(lastResult.status)
+			}
+			v, ok := os.LookupEnv(name)
+			if !ok && undefined == "" {
+				undefined = name
+			}
+			return v
+		})
+	}
+	if undefined != "" {
+		return nil, argError(fmt.Errorf("environment variable %s is not defined", undefined))
+	}
+	return expanded, nil
+}
+
+// A cmdResult is the outcome of a command line: the command it ran, if
+// any, the error the command returned, and the corresponding exit status.
+type cmdResult struct {
+	line   string
+	cmd    *cmd.Command
+	err    error
+	status int
+}
+
+// lastResult is the result of the most recent non-blank command line. $?
+// expands to its status, and !! repeats its line.
+var lastResult cmdResult
+
+// processCmd runs a command line, reports its failure, and returns the
+// error of its command.
 func processCmd(line string) error {
-	var err error
-	var args []string
-	var n cmd.Node
-	if line != "" {
-		n, args, err = cmds.Lookup(line)
-		switch {
-		case err == cmd.ErrNotFound:
-			fmt.Println("Command not found.")
-			return nil
-		case err == cmd.ErrAmbiguous:
-			fmt.Println("Command ambiguous.")
-			return nil
-		case err != nil:
-			fmt.Printf("Error: %v\n", err)
-			return nil
+	return processResult(runCmd(line))
+}
+
+// processArgs runs a command line already split into arguments, such as
+// the arguments of a non-interactive invocation, reports its failure, and
+// returns the error of its command.
+func processArgs(argv []string) error {
+	return processResult(runArgs(argv))
+}
+
+func processResult(r cmdResult) error {
+	reportResult(r)
+	if r.cmd != nil && !interactive {
+		printRunSummary()
+	}
+	if r.line != "" {
+		lastResult = r
+	}
+	return r.err
+}
+
+// runCmd runs a command line without reporting its result.
+func runCmd(line string) cmdResult {
+	if line == "" {
+		return cmdResult{}
+	}
+	n, args, err := cmds.Lookup(line)
+	return runNode(line, n, args, err)
+}
+
+// runArgs runs a command line already split into arguments without
+// reporting its result. Unlike a line, whose arguments are split again at
+// spaces and double quotes, the arguments reach the command unchanged.
+func runArgs(argv []string) cmdResult {
+	if len(argv) == 0 {
+		return cmdResult{}
+	}
+	n, args, err := lookupArgs(argv)
+	return runNode(formatArgs(argv), n, args, err)
+}
+
+// lookupArgs finds the command named by the leading arguments of a command
+// line, returning it and the arguments that follow its name.
+func lookupArgs(argv []string) (cmd.Node, []string, error) {
+	t := cmds
+	for i, a := range argv {
+		// Command names hold no spaces or quotes, which Lookup would split.
+		if a == "" || strings.ContainsAny(a, " \t\"") {
+			return nil, nil, cmd.ErrNotFound
+		}
+		n, _, err := t.Lookup(a)
+		if err != nil {
+			return nil, nil, err
+		}
+		subtree, ok := n.(*cmd.Tree)
+		if !ok || i == len(argv)-1 {
+			return n, argv[i+1:], nil
 		}
+		t = subtree
+	}
+	return nil, nil, cmd.ErrNotFound
+}
+
+// runNode runs the node found for a command line, or reports the error of
+// looking it up.
+func runNode(line string, n cmd.Node, args []string, err error) (r cmdResult) {
+	r.line = line
+	defer func() { r.status = exitCode(r.err) }()
+
+	switch {
+	case err == cmd.ErrNotFound:
+		r.err = errCommandNotFound
+		return r
+	case err == cmd.ErrAmbiguous:
+		r.err = errCommandAmbiguous
+		return r
+	case err != nil:
+		r.err = err
+		return r
+	}
+
+	c, ok := n.(*cmd.Command)
+	if !ok {
+		return r
+	}
+	if args, r.err = expandArgs(args); r.err != nil {
+		return r
+	}
+
+	var flags flagValues
+	if args, flags, r.err = takeCommandFlags(c, args); r.err != nil {
+		return r
+	}
+	if flags.has("override-freeze") && !overrideFreeze {
+		overrideFreeze = true
+		defer func() { overrideFreeze = false }()
+	}
+	if flags.has("dry-run") && !dryRun {
+		dryRun = true
+		defer func() { dryRun = false }()
+	}
+	if flags.has("json") && outputFormat != "json" {
+		saved := outputFormat
+		outputFormat = "json"
+		defer func() { outputFormat = saved }()
+	}
+	zone := flags.get("zone", "")
+
+	r.cmd = c
+	startRun()
+	handler := c.Data.(func(cmd *cmd.Command, args []string) error)
+	run := func() error {
+		return runWithHooks(c.Name, args, func() error { return handler(c, args) })
+	}
+	if zone != "" {
+		inZone := run
+		run = func() error { return withZone(zone, inZone) }
+	}
+	r.err = auditCommand(line, func() error { return runInterruptible(run) })
+	var frozen *freezeError
+	var denied *totpError
+	switch {
+	case errors.As(r.err, &frozen):
+		r.err = frozen
+	case errors.As(r.err, &denied):
+		r.err = authError(denied)
+	}
+	return r
+}
+
+// reportResult displays the failure of a command line. Usage errors have
+// been reported by the command itself.
+func reportResult(r cmdResult) {
+	switch {
+	case r.err == errCommandNotFound:
+		printf("Command not found.\n")
+	case r.err == errCommandAmbiguous:
+		printf("Command ambiguous.\n")
+	case r.err == errInterrupted:
+		printf("Interrupted.\n")
+	case r.err != nil && r.err != errQuit && r.err != errUsage && r.err != errNotExist:
+		printf("Error: %v\n", r.err)
+		if hint := errorHint(r.err); hint != "" {
+			printf("Hint: %s\n", tr(hint))
+		}
+	}
+}
+
+func cmdQuit(c *cmd.Command, args []string) error {
+	return errQuit
+}
+
+var helpCommandFlags = registerFlags("help", flagSpec{"json": false})
+
+func cmdHelp(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, helpCommandFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return usageError(c)
+	}
+	if flags.has("json") || outputFormat == "json" {
+		return helpJSON(args)
+	}
+
+	if len(args) == 0 {
+		c.Parent().DisplayHelp(output)
+	} else {
+		n, _, err := cmds.Lookup(args[0])
+		switch {
+		case err == cmd.ErrNotFound:
+			printf("Command not found.\n")
+			return errUsage
+		case err == cmd.ErrAmbiguous:
+			printf("Command ambiguous.\n")
+			return errUsage
+		case err != nil:
+			return err
+		}
+		if cc, ok := n.(*cmd.Command); ok {
+			cc.DisplayHelp(output)
+		}
+	}
+	return nil
+}
+
+// helpJSON writes the command tree as JSON, limited to the command named in
+// args if there is one, which may be a shortcut or an unambiguous prefix.
+func helpJSON(args []string) error {
+	var names []string
+	for _, arg := range args {
+		c, _, err := cmds.LookupCommand(arg)
+		switch {
+		case err == cmd.ErrNotFound:
+			printf("Command not found.\n")
+			return errUsage
+		case err == cmd.ErrAmbiguous:
+			printf("Command ambiguous.\n")
+			return errUsage
+		case err != nil:
+			return err
+		}
+		names = append(names, c.Name)
+	}
+	return writeHelpJSON(output, names)
+}
+
+func cmdSetZone(c *cmd.Command, args []string) error {
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "create":
+		return cmdZoneCreate(c, args[1:])
+	case "delete":
+		return cmdZoneDelete(c, args[1:])
+	case "offboard":
+		return cmdZoneOffboard(c, args[1:])
+	case "compare":
+		return cmdZoneCompare(c, args[1:])
+	case "onboard":
+		return cmdZoneOnboard(c, args[1:])
+	case "pull":
+		return cmdZonePull(c, args[1:])
+	case "unlock":
+		return cmdZoneUnlock(c, args[1:])
+	case "set-id":
+		return cmdZoneSetID(c, args[1:])
+	case "clear":
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		activeZoneIdentifier, activeZoneName, resumedZone = nil, "", ""
+		printf("Active zone cleared.\n")
+		return nil
+	}
+
+	return activateZone(args[0])
+}
+
+// activateZone makes the named zone the active zone.
+func activateZone(name string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := recordBackend(api).ZoneIDByName(name)
+	if err != nil {
+		return zoneError(err)
+	}
+
+	activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
+	activeZoneName = name
+	printf("Active zone set to %v.\n", name)
+	return nil
+}
+
+var listFlags = registerFlags("list", mergeFlags(zoneFlags, flagSpec{
+	"long":     false,
+	"owned":    false,
+	"tag":      true,
+	"limit":    true,
+	"page":     true,
+	"group-by": true,
+	"sort":     true,
+	"where":    true,
+}))
+
+func cmdListDomains(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, listFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return usageError(c)
+	}
+
+	var where recordFilter
+	if flags.has("where") {
+		if where, err = parseWhere(flags.get("where", "")); err != nil {
+			return argError(fmt.Errorf("invalid --where filter: %v", err))
+		}
+	}
+
+	limit, page, err := parseWindow(flags)
+	if err != nil {
+		return err
+	}
+
+	groupBy := flags.get("group-by", "")
+	if _, ok := recordGroupings[groupBy]; groupBy != "" && !ok {
+		return argError(fmt.Errorf("cannot group by %q", groupBy))
+	}
+
+	sortBy := flags.get("sort", "")
+	if _, ok := recordSorts[sortBy]; sortBy != "" && !ok {
+		return argError(fmt.Errorf("cannot sort by %q", sortBy))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	recType := ""
+	if len(args) > 0 {
+		recType = strings.ToUpper(args[0])
+	}
+
+	recs, err := listZoneRecords(api, zones, cloudflare.ListDNSRecordsParams{Type: recType})
+	if err != nil {
+		return err
+	}
+
+	if flags.has("owned") {
+		if ownerID == "" {
+			return argError(errors.New("--owned requires an owner to be set"))
+		}
+		owners, err := loadOwners(api, zones)
+		if err != nil {
+			return err
+		}
+		var owned []zoneRecord
+		for _, r := range recs {
+			if owners.owner(r) == ownerID {
+				owned = append(owned, r)
+			}
+		}
+		recs = owned
+	}
+
+	if flags.has("tag") {
+		tag := flags.get("tag", "")
+		var tagged []zoneRecord
+		for _, r := range recs {
+			if hasTag(r.DNSRecord, tag) {
+				tagged = append(tagged, r)
+			}
+		}
+		recs = tagged
+	}
+
+	if where != nil {
+		var matched []zoneRecord
+		for _, r := range recs {
+			if where(r) {
+				matched = append(matched, r)
+			}
+		}
+		recs = matched
+	}
+
+	if sortBy != "" {
+		sortRecords(recs, sortBy)
+	}
+
+	total := len(recs)
+	recs = window(recs, limit, page)
+	if groupBy != "" {
+		displayRecordGroups(recs, groupBy, len(zones) > 1, flags.has("long"))
+	} else {
+		displayRecords(recs, len(zones) > 1, flags.has("long"))
+	}
+	if interactive && len(recs) < total && outputFormat != "json" {
+		first := (page - 1) * limit
+		if len(recs) == 0 {
+			printf("No records on page %d; there are %d record(s).\n", page, total)
+		} else {
+			printf("Showing records %d-%d of %d.\n", first+1, first+len(recs), total)
+		}
+	}
+	return nil
+}
+
+// A zoneRecord is a DNS record along with the name and identifier of its
+// zone.
+type zoneRecord struct {
+	zone   string
+	zoneID *cloudflare.ResourceContainer
+	cloudflare.DNSRecord
+}
+
+// listZoneRecords returns the DNS records matching params in each of the
+// zones.
+func listZoneRecords(api *cloudflare.API, zones []zoneTarget,
+	params cloudflare.ListDNSRecordsParams) ([]zoneRecord, error) {
+
+	var recs []zoneRecord
+	for _, z := range zones {
+		zrecs, err := listRecords(api, z.id, params)
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range zrecs {
+			recs = append(recs, zoneRecord{z.name, z.id, r})
+		}
+	}
+	return recs, nil
+}
+
+// displayRecords prints DNS records as a table, or as JSON if that output
+// format is selected. The zone column is included only if showZone is true,
+// and the tags and comment columns only if long is true.
+func displayRecords(recs []zoneRecord, showZone, long bool) {
+	if outputFormat == "json" {
+		out := make([]cloudflare.DNSRecord, 0, len(recs))
+		for _, rec := range recs {
+			out = append(out, rec.DNSRecord)
+		}
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+		return
+	}
+
+	// Column headers are shown only on a terminal, so that piped output
+	// remains one record per line.
+	headers := len(recs) > 0 && stdoutIsTerminal()
+	color := useColor()
+
+	widthZone := 0
+	widthType := 0
+	widthName := 0
+	widthTTL := 0
+	widthContent := 0
+	widthTags := 0
+	if headers {
+		widthZone, widthType, widthName = len("ZONE"), len("TYPE"), len("NAME")
+		widthTTL, widthContent, widthTags = len("TTL"), len("CONTENT"), len("TAGS")
+	}
+	for _, rec := range recs {
+		if len(rec.zone) > widthZone {
+			widthZone = len(rec.zone)
+		}
+		if len(rec.Name) > widthName {
+			widthName = len(rec.Name)
+		}
+		if len(rec.Type) > widthType {
+			widthType = len(rec.Type)
+		}
+		if len(formatTTL(rec.TTL)) > widthTTL {
+			widthTTL = len(formatTTL(rec.TTL))
+		}
+		if len(rec.Content) > widthContent {
+			widthContent = len(rec.Content)
+		}
+		if len(formatTags(rec.Tags)) > widthTags {
+			widthTags = len(formatTags(rec.Tags))
+		}
+	}
+
+	// Colors are added after padding, so that the escape codes do not
+	// upset the alignment of the columns.
+	paint := func(code, s string) string {
+		if !color || code == "" {
+			return s
+		}
+		return code + s + colorReset
+	}
+
+	if headers {
+		var h string
+		if showZone {
+			h += fmt.Sprintf("%-*s ", widthZone, "ZONE")
+		}
+		h += fmt.Sprintf("%-*s %-*s %*s ", widthType, "TYPE", widthName, "NAME", widthTTL, "TTL")
+		if long {
+			h += fmt.Sprintf("%-*s %-*s %s", widthContent, "CONTENT", widthTags, "TAGS", "COMMENT")
+		} else {
+			h += "CONTENT"
+		}
+		fmt.Fprintln(output, paint(colorBold, h))
+	}
+
+	for _, rec := range recs {
+		if showZone {
+			fmt.Fprintf(output, "%-*s ", widthZone, rec.zone)
+		}
+		name := fmt.Sprintf("%-*s", widthName, rec.Name)
+		if isProxied(rec.DNSRecord) {
+			name = paint(colorProxied, name)
+		}
+		fmt.Fprintf(output, "%s %s %s ",
+			paint(recordTypeColors[rec.Type], fmt.Sprintf("%-*s", widthType, rec.Type)), name,
+			paint(colorDim, fmt.Sprintf("%*s", widthTTL, formatTTL(rec.TTL))))
+		if long {
+			fmt.Fprintf(output, "%-*s %-*s %s\n", widthContent, rec.Content,
+				widthTags, formatTags(rec.Tags), rec.Comment)
+		} else {
+			fmt.Fprintf(output, "%s\n", rec.Content)
+		}
+	}
+}
+
+// recordTypeColors holds the colors in which the types of records are
+// listed. Types without a color are listed plainly.
+var recordTypeColors = map[string]string{
+	"A":     colorCyan,
+	"AAAA":  colorCyan,
+	"CNAME": colorBlue,
+	"MX":    colorMagenta,
+	"TXT":   colorYellow,
+	"NS":    colorGreen,
+	"SRV":   colorMagenta,
+	"CAA":   colorRed,
+}
+
+// recordSorts holds the orderings accepted by "list --sort". Each compares
+// two records, falling back to the order of their names.
+var recordSorts = map[string]func(a, b zoneRecord) int{
+	"name": func(a, b zoneRecord) int { return 0 },
+	"type": func(a, b zoneRecord) int { return strings.Compare(a.Type, b.Type) },
+	"ttl": func(a, b zoneRecord) int {
+		return effectiveTTL(a.TTL) - effectiveTTL(b.TTL)
+	},
+	"content": func(a, b zoneRecord) int { return strings.Compare(a.Content, b.Content) },
+}
+
+// sortRecords sorts records in one of the orderings of recordSorts.
+func sortRecords(recs []zoneRecord, by string) {
+	compare := recordSorts[by]
+	sort.SliceStable(recs, func(i, j int) bool {
+		if c := compare(recs[i], recs[j]); c != 0 {
+			return c < 0
+		}
+		return cflib.NormalizeName(recs[i].Name) < cflib.NormalizeName(recs[j].Name)
+	})
+}
+
+// formatTags returns a record's tags as a comma-separated list, or "-" if
+// it has none.
+func formatTags(tags []string) string {
+	if len(tags) == 0 {
+		return "-"
+	}
+	return strings.Join(tags, ",")
+}
+
+var ip4Flags = registerFlags("ip4", mergeFlags(waitFlags, metaFlags, proxyFlags, roundRobinFlags, targetFlags))
+
+func cmdIP4(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, ip4Flags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return usageError(c)
+	}
+
+	ttl, err := optionalTTL(args, 2)
+	if err != nil {
+		return err
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+
+	target, err := parseTarget(flags)
+	if err != nil {
+		return err
+	}
+
+	mode, err := parseRoundRobin(flags)
+	if err != nil {
+		return err
+	}
+	if mode != "" && (target.all || target.id != "") {
+		return argError(fmt.Errorf("--%s cannot be used with --all or --id", mode))
+	}
+
+	name := args[0]
+	if mode != "" {
+		return setRoundRobin(mode, "A", name, args[1], ttl, parseMeta(flags), wait)
+	}
+	addr := args[1]
+	if err := validateRecord("A", name, addr); err != nil {
+		return argError(err)
+	}
+	return addOrUpdateRecord("A", name, addr, ttl, parseMeta(flags), target, wait)
+}
+
+var ip6Flags = registerFlags("ip6", mergeFlags(waitFlags, metaFlags, proxyFlags, roundRobinFlags, targetFlags))
+
+func cmdIP6(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, ip6Flags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return usageError(c)
+	}
+
+	ttl, err := optionalTTL(args, 2)
+	if err != nil {
+		return err
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+
+	target, err := parseTarget(flags)
+	if err != nil {
+		return err
+	}
+
+	mode, err := parseRoundRobin(flags)
+	if err != nil {
+		return err
+	}
+	if mode != "" && (target.all || target.id != "") {
+		return argError(fmt.Errorf("--%s cannot be used with --all or --id", mode))
+	}
+
+	name := args[0]
+	if mode != "" {
+		return setRoundRobin(mode, "AAAA", name, args[1], ttl, parseMeta(flags), wait)
+	}
+	if err := validateRecord("AAAA", name, args[1]); err != nil {
+		return argError(err)
+	}
+	addr := cflib.CanonicalIP(args[1])
+	return addOrUpdateRecord("AAAA", name, addr, ttl, parseMeta(flags), target, wait)
+}
+
+var cnameFlags = registerFlags("cname", mergeFlags(waitFlags, metaFlags, proxyFlags, targetFlags))
+
+func cmdCNAME(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, cnameFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return usageError(c)
+	}
+
+	ttl, err := optionalTTL(args, 2)
+	if err != nil {
+		return err
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+
+	target, err := parseTarget(flags)
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	addr := args[1]
+	if err := validateRecord("CNAME", name, addr); err != nil {
+		return argError(err)
+	}
+	return addOrUpdateRecord("CNAME", name, addr, ttl, parseMeta(flags), target, wait)
+}
+
+var txtFlags = registerFlags("txt", mergeFlags(waitFlags, metaFlags, targetFlags))
+
+func cmdTXT(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, txtFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 3 {
+		return usageError(c)
+	}
+
+	ttl, err := optionalTTL(args, 2)
+	if err != nil {
+		return err
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+
+	target, err := parseTarget(flags)
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	content := args[1]
+	if err := validateRecord("TXT", name, content); err != nil {
+		return argError(err)
+	}
+	return addOrUpdateRecord("TXT", name, content, ttl, parseMeta(flags), target, wait)
+}
+
+var addFlags = registerFlags("add", mergeFlags(waitFlags, metaFlags, proxyFlags))
+
+func cmdAdd(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, addFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 3 || len(args) > 4 {
+		return usageError(c)
+	}
+
+	ttl, err := optionalTTL(args, 3)
+	if err != nil {
+		return err
+	}
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+
+	recType := strings.ToUpper(args[0])
+	name := args[1]
+	content := args[2]
+	if err := validateRecord(recType, name, content); err != nil {
+		return argError(err)
+	}
+	if recType == "AAAA" {
+		content = cflib.CanonicalIP(content)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	if err := validateApex(recType, name, activeZoneName); err != nil {
+		return argError(err)
+	}
+
+	if err := checkOwner(api, zoneID, recType, name); err != nil {
+		return err
+	}
+
+	meta := parseMeta(flags)
+	params := cloudflare.CreateDNSRecordParams{
+		Type:    recType,
+		Name:    name,
+		Content: content,
+		TTL:     ttl,
+		Tags:    meta.tags,
+		Proxied: meta.proxied,
+	}
+	if meta.comment != nil {
+		params.Comment = *meta.comment
+	}
+	_, err = recordBackend(api).CreateDNSRecord(commandCtx, zoneID.Identifier, params)
+	if err != nil {
+		return err
+	}
+	if err := markOwned(api, zoneID, recType, name); err != nil {
+		return err
+	}
+
+	printf("DNS record added.\n")
+	if wait > 0 {
+		return waitForRecord(api, zoneID, recType, name, content, wait)
+	}
+	return nil
+}
+
+var deleteFlags = registerFlags("delete", mergeFlags(zoneFlags, forceFlags, flagSpec{
+	"content": true,
+	"exact":   false,
+	"id":      true,
+	"owned":   false,
+}))
+
+func cmdDelete(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, deleteFlags)
+	if err != nil {
+		return err
+	}
+	if flags.has("id") {
+		if len(args) != 0 {
+			return usageError(c)
+		}
+		return deleteRecordByID(flags.get("id", ""), flags.force())
+	}
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	recType := strings.ToUpper(args[0])
+	name := args[1]
+
+	if len(recType) < 1 {
+		return errors.New("must provide valid DNS record type")
+	}
+
+	params := cloudflare.ListDNSRecordsParams{
+		Type: recType,
+	}
+
+	var matchName, matchContent matcher
+	if !flags.has("exact") && strings.ContainsAny(name, "*?[") {
+		if matchName, err = newMatcher(name, false); err != nil {
+			return err
+		}
+	} else {
+		params.Name = name
+	}
+
+	// Like the name, the content must match as a whole unless it holds
+	// wildcards, so that 10.0.0.1 does not also delete 10.0.0.10.
+	content, exactContent := flags.get("content", ""), false
+	if flags.has("content") {
+		if strings.ContainsAny(content, "*?[") {
+			if matchContent, err = newMatcher(content, false); err != nil {
+				return err
+			}
+		} else {
+			exactContent = true
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	all, err := listZoneRecords(api, zones, params)
+	if err != nil {
+		return err
+	}
+
+	var owners ownerIndex
+	if ownerID != "" {
+		if owners, err = loadOwners(api, zones); err != nil {
+			return err
+		}
+	} else if flags.has("owned") {
+		return argError(errors.New("--owned requires an owner to be set"))
+	}
+
+	var recs []zoneRecord
+	var ops []operation
+	for _, r := range all {
+		if matchName != nil && !matchName(r.Name) {
+			continue
+		}
+		if matchContent != nil && !matchContent(r.Content) {
+			continue
+		}
+		if exactContent && !cflib.ContentEqual(r.Type, r.Content, content) {
+			continue
+		}
+		if flags.has("owned") && owners.owner(r) != ownerID {
+			continue
+		}
+
+		zoneID, recordID := r.zoneID, r.ID
+		recs = append(recs, r)
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, recordID),
+			fn: func() error {
+				return newClient(api, zoneID).Delete(deleteCtx(flags.force()), recordID)
+			},
+			change: deleteChange(zoneID.Identifier, r.zone, r.DNSRecord),
+		})
+	}
+
+	if len(recs) < 1 {
+		return errNoMatch
+	}
+
+	if !flags.force() {
+		var protected []string
+		for _, r := range recs {
+			if isProtected(r.zone, r.Type, r.Name) {
+				protected = append(protected, r.Type+" "+r.Name)
+			}
+		}
+		if err := protectedError("delete", protected); err != nil {
+			return err
+		}
+
+		printf("The following records will be deleted:\n")
+		for _, r := range recs {
+			fmt.Fprintf(output, "    %s %s %s %s (ID %s)\n", r.zone, r.Type, r.Name, r.Content, r.ID)
+		}
+		if !confirm(sprintf("Delete %d record(s)?", len(recs))) {
+			printf("No records deleted.\n")
+			return nil
+		}
+	}
+
+	failed := 0
+	deleted := make(map[string]bool)
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := recs[i]
+		if err != nil {
+			printf("Error deleting %s: %v\n", r.Name, err)
+			failed++
+			continue
+		}
+		deleted[r.ID] = true
+		printf("Deleted %s record %s.\n", r.Type, r.Name)
+	}
+	queueRetries(ops, errs)
+
+	if owners != nil {
+		if err := releaseDeleted(api, owners, all, deleted); err != nil {
+			return err
+		}
+	}
+
+	printf("%d record(s) deleted.\n", len(deleted))
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be deleted", failed, len(recs))
+	}
+	return nil
+}
+
+// deleteRecordByID deletes the record with the requested ID from the
+// active zone.
+func deleteRecordByID(id string, force bool) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	d, err := getRecordDetails(api, zoneID, id)
+	if err != nil {
+		return err
+	}
+	r := d.DNSRecord
+
+	if !force && isProtected(activeZoneName, r.Type, r.Name) {
+		return protectedError("delete", []string{r.Type + " " + r.Name})
+	}
+	if !force && !confirm(sprintf("Delete %s record %s (%s)?", r.Type, r.Name, r.Content)) {
+		printf("No records deleted.\n")
+		return nil
+	}
+
+	if err := newClient(api, zoneID).Delete(deleteCtx(force), r.ID); err != nil {
+		return err
+	}
+	printf("Deleted %s record %s.\n", r.Type, r.Name)
+
+	if ownerID != "" {
+		remaining, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: r.Type, Name: r.Name})
+		if err != nil {
+			return err
+		}
+		if len(remaining) == 0 {
+			return unmarkOwned(api, zoneID, r.Type, r.Name)
+		}
+	}
+	return nil
+}
+
+var updateFlags = registerFlags("update", mergeFlags(metaFlags, flagSpec{"id": true}))
+
+func cmdUpdate(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, updateFlags)
+	if err != nil {
+		return err
+	}
+	if !flags.has("id") || len(args) < 1 || len(args) > 2 {
+		return usageError(c)
+	}
+
+	ttl, err := optionalTTL(args, 1)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	d, err := getRecordDetails(api, zoneID, flags.get("id", ""))
+	if err != nil {
+		return err
+	}
+	r := d.DNSRecord
+
+	if err := checkOwner(api, zoneID, r.Type, r.Name); err != nil {
+		return err
 	}
 
-	if n == nil {
-		return nil
+	content := args[0]
+	if r.Type == "AAAA" {
+		content = cflib.CanonicalIP(content)
 	}
-	if c, ok := n.(*cmd.Command); ok {
-		handler := c.Data.(func(cmd *cmd.Command, args []string) error)
-		return handler(c, args)
+	if err := updateRecord(api, zoneID, r, content, ttl, parseMeta(flags)); err != nil {
+		return err
 	}
-	return nil
-}
 
-func cmdQuit(c *cmd.Command, args []string) error {
-	return errors.New("exiting program")
+	printf("DNS record updated.\n")
+	return nil
 }
 
-func cmdHelp(c *cmd.Command, args []string) error {
-	if len(args) == 0 {
-		c.Parent().DisplayHelp(os.Stdout)
-	} else {
-		n, _, err := cmds.Lookup(args[0])
-		switch {
-		case err == cmd.ErrNotFound:
-			fmt.Println("Command not found.")
-			return nil
-		case err == cmd.ErrAmbiguous:
-			fmt.Println("Command ambiguous.")
-			return nil
-		case err != nil:
-			fmt.Printf("Error: %v\n", err)
-			return nil
-		}
-		if cc, ok := n.(*cmd.Command); ok {
-			cc.DisplayHelp(os.Stdout)
-		}
+func cmdRename(c *cmd.Command, args []string) error {
+	if len(args) != 3 {
+		return usageError(c)
 	}
-	return nil
-}
 
-func cmdSetZone(c *cmd.Command, args []string) error {
-	if len(args) < 1 {
-		c.DisplayUsage(os.Stdout)
-		return nil
+	api, err := getAPI()
+	if err != nil {
+		return err
 	}
 
-	api := getAPI()
-	if api == nil {
-		return nil
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
 	}
 
-	zoneID, err := api.ZoneIDByName(args[0])
+	newName := args[2]
+	params := cloudflare.ListDNSRecordsParams{
+		Type: strings.ToUpper(args[0]),
+		Name: args[1],
+	}
+	recs, err := listRecords(api, zoneID, params)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return nil
+		return err
+	}
+	if len(recs) < 1 {
+		return errNoMatch
 	}
 
-	activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
-	fmt.Printf("Active zone set to %v.\n", args[0])
+	var ops []operation
+	for _, r := range recs {
+		params := cloudflare.UpdateDNSRecordParams{
+			Type:     r.Type,
+			Name:     newName,
+			Content:  r.Content,
+			Data:     r.Data,
+			ID:       r.ID,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+		}
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.ID),
+			fn: func() error {
+				_, err := recordBackend(api).UpdateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: updateChange(zoneID.Identifier, activeZoneName, params),
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := recs[i]
+		if err != nil {
+			printf("Error renaming %s: %v\n", r.Name, err)
+			failed++
+			continue
+		}
+		printf("Renamed %s record %s to %s.\n", r.Type, r.Name, newName)
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be renamed", failed, len(recs))
+	}
 	return nil
 }
 
-func cmdListDomains(c *cmd.Command, args []string) error {
-	zoneID := getZoneIdentifier()
-	if zoneID == nil {
-		return nil
+func cmdTTL(c *cmd.Command, args []string) error {
+	if len(args) != 3 {
+		return usageError(c)
 	}
 
-	api := getAPI()
-	if api == nil {
-		return nil
+	ttl, err := parseTTL(args[2])
+	if err != nil {
+		return argError(err)
 	}
 
-	recType := ""
-	if len(args) > 0 {
-		recType = strings.ToUpper(args[0])
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
 	}
 
 	params := cloudflare.ListDNSRecordsParams{
-		Type: recType,
+		Type: strings.ToUpper(args[0]),
+		Name: args[1],
 	}
-	recs, _, err := api.ListDNSRecords(context.Background(), zoneID, params)
+	recs, err := listRecords(api, zoneID, params)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return nil
+		return err
+	}
+	if len(recs) < 1 {
+		return errNoMatch
 	}
 
-	widthType := 0
-	widthName := 0
-	for _, rec := range recs {
-		if len(rec.Name) > widthName {
-			widthName = len(rec.Name)
-		}
-		if len(rec.Type) > widthType {
-			widthType = len(rec.Type)
+	var ops []operation
+	for _, r := range recs {
+		params := cloudflare.UpdateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			Data:     r.Data,
+			ID:       r.ID,
+			TTL:      ttl,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
 		}
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.ID),
+			fn: func() error {
+				_, err := recordBackend(api).UpdateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: updateChange(zoneID.Identifier, activeZoneName, params),
+		})
 	}
 
-	for _, rec := range recs {
-		fmt.Printf("%-*s %-*s %s\n", widthType, rec.Type, widthName, rec.Name, rec.Content)
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := recs[i]
+		if err != nil {
+			printf("Error updating %s: %v\n", r.Name, err)
+			failed++
+			continue
+		}
+		printf("Set TTL of %s record %s to %s.\n", r.Type, r.Name, formatTTL(ttl))
 	}
+	queueRetries(ops, errs)
 
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be updated", failed, len(recs))
+	}
 	return nil
 }
 
-func cmdIP4(c *cmd.Command, args []string) error {
-	if len(args) != 2 {
-		c.DisplayUsage(os.Stdout)
-		return nil
+func addOrUpdateRecord(recType, name, content string, ttl int, meta recordMeta,
+	target recordTarget, wait time.Duration) error {
+
+	api, err := getAPI()
+	if err != nil {
+		return err
 	}
 
-	name := args[0]
-	addr := args[1]
-	addOrUpdateRecord("A", name, addr)
-	return nil
-}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	if err := validateApex(recType, name, activeZoneName); err != nil {
+		return argError(err)
+	}
 
-func cmdIP6(c *cmd.Command, args []string) error {
-	if len(args) != 2 {
-		c.DisplayUsage(os.Stdout)
-		return nil
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: recType, Name: name})
+	if err != nil {
+		return err
 	}
 
-	name := args[0]
-	addr := args[1]
-	addOrUpdateRecord("AAAA", name, addr)
+	var r *cloudflare.DNSRecord
+	switch {
+	case target.id != "":
+		for i := range recs {
+			if recs[i].ID == target.id {
+				r = &recs[i]
+			}
+		}
+		if r == nil {
+			return argError(fmt.Errorf("no %s record %s has ID %s", recType, name, target.id))
+		}
+	case len(recs) > 1 && target.all:
+		if err := replaceRecords(api, zoneID, recs, content, ttl, meta); err != nil {
+			return err
+		}
+	case len(recs) > 1:
+		if r, err = chooseRecord(recType, name, recs); err != nil || r == nil {
+			return err
+		}
+	default:
+		if _, err := upsertRecord(api, zoneID, recType, name, content, ttl, meta); err != nil {
+			return err
+		}
+	}
+	if r != nil {
+		if err := checkOwner(api, zoneID, recType, name); err != nil {
+			return err
+		}
+		if err := updateRecord(api, zoneID, *r, content, ttl, meta); err != nil {
+			return err
+		}
+	}
+
+	printf("DNS record updated.\n")
+	if wait > 0 {
+		return waitForRecord(api, zoneID, recType, name, content, wait)
+	}
 	return nil
 }
 
-func cmdCNAME(c *cmd.Command, args []string) error {
-	if len(args) != 2 {
-		c.DisplayUsage(os.Stdout)
-		return nil
-	}
+// targetFlags select the record ip4, ip6, cname and txt update when
+// several records share the requested type and name.
+var targetFlags = flagSpec{
+	"all": false,
+	"id":  true,
+}
 
-	name := args[0]
-	addr := args[1]
-	addOrUpdateRecord("CNAME", name, addr)
-	return nil
+// A recordTarget selects the record to update among several records with
+// the same type and name: the one with an ID, or, if all is true, all of
+// them, which are replaced with a single record.
+type recordTarget struct {
+	id  string
+	all bool
 }
 
-func cmdTXT(c *cmd.Command, args []string) error {
-	if len(args) != 2 {
-		c.DisplayUsage(os.Stdout)
-		return nil
+func parseTarget(flags flagValues) (recordTarget, error) {
+	t := recordTarget{id: flags.get("id", ""), all: flags.has("all")}
+	if t.all && t.id != "" {
+		return t, argError(errors.New("--all and --id cannot be used together"))
 	}
-
-	name := args[0]
-	content := args[1]
-	addOrUpdateRecord("TXT", name, content)
-	return nil
+	return t, nil
 }
 
-func cmdAdd(c *cmd.Command, args []string) error {
-	if len(args) != 3 {
-		c.DisplayUsage(os.Stdout)
-		return nil
+// chooseRecord asks which of several records with the same type and name
+// to update. It returns nil if no record was chosen. Outside interactive
+// mode, it lists the records and returns an error asking for --id or
+// --all instead.
+func chooseRecord(recType, name string, recs []cloudflare.DNSRecord) (*cloudflare.DNSRecord, error) {
+	printf("%d %s records named %s exist:\n", len(recs), recType, name)
+	for i, r := range recs {
+		fmt.Fprintf(output, "%3d) %s  %s  %s\n", i+1, r.ID, formatTTL(r.TTL), r.Content)
 	}
 
-	api := getAPI()
-	if api == nil {
-		return nil
+	if !interactive || !term.IsTerminal(int(os.Stdin.Fd())) {
+		return nil, argError(errors.New("use --id to update one of them, or --all to replace them all"))
 	}
 
-	zoneID := getZoneIdentifier()
-	if zoneID == nil {
-		return nil
+	answer, err := readString(sprintf("Update which record? [1-%d] ", len(recs)))
+	if err != nil {
+		fmt.Fprintln(output)
 	}
+	n, err := strconv.Atoi(strings.TrimSpace(answer))
+	if err != nil || n < 1 || n > len(recs) {
+		printf("No records updated.\n")
+		return nil, nil
+	}
+	return &recs[n-1], nil
+}
 
-	recType := args[0]
-	name := args[1]
-	content := args[2]
+// replaceRecords replaces several records with the same type and name with
+// a single record holding content. A record already holding it is kept,
+// and the others are deleted.
+func replaceRecords(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	recs []cloudflare.DNSRecord, content string, ttl int, meta recordMeta) error {
 
-	params := cloudflare.CreateDNSRecordParams{
-		Type:    recType,
-		Name:    name,
-		Content: content,
-		TTL:     1,
+	if err := checkOwner(api, zoneID, recs[0].Type, recs[0].Name); err != nil {
+		return err
 	}
-	_, err := api.CreateDNSRecord(context.Background(), zoneID, params)
-	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return nil
+	if isProtected(activeZoneName, recs[0].Type, recs[0].Name) {
+		return fmt.Errorf("refusing to replace protected %s records named %s; use --id to update one of them",
+			recs[0].Type, recs[0].Name)
 	}
 
-	fmt.Println("DNS record added.")
+	keep := 0
+	for i, r := range recs {
+		if cflib.ContentEqual(r.Type, r.Content, content) {
+			keep = i
+			break
+		}
+	}
+	if err := updateRecord(api, zoneID, recs[keep], content, ttl, meta); err != nil {
+		return err
+	}
+
+	client := newClient(api, zoneID)
+	for i, r := range recs {
+		if i == keep {
+			continue
+		}
+		if err := client.Delete(commandCtx, r.ID); err != nil {
+			return err
+		}
+		printf("Deleted %s record %s (%s).\n", r.Type, r.Name, r.Content)
+	}
 	return nil
 }
 
-func cmdDelete(c *cmd.Command, args []string) error {
-	if len(args) != 2 {
-		c.DisplayUsage(os.Stdout)
-		return nil
-	}
+// updateRecord updates an existing record to hold content. A ttl of 0
+// keeps the record's TTL, as does a nil comment, tag list or proxy setting
+// in meta.
+func updateRecord(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	r cloudflare.DNSRecord, content string, ttl int, meta recordMeta) error {
 
-	api := getAPI()
-	if api == nil {
-		return nil
+	if ttl == 0 {
+		ttl = r.TTL
+	}
+	tags := r.Tags
+	if meta.tags != nil {
+		tags = meta.tags
+	}
+	proxied := r.Proxied
+	if meta.proxied != nil {
+		proxied = meta.proxied
 	}
 
-	zoneID := getZoneIdentifier()
-	if zoneID == nil {
-		return nil
+	params := cloudflare.UpdateDNSRecordParams{
+		ID:       r.ID,
+		Type:     r.Type,
+		Name:     r.Name,
+		Content:  content,
+		TTL:      ttl,
+		Proxied:  proxied,
+		Priority: r.Priority,
+		Comment:  meta.comment,
+		Tags:     tags,
 	}
+	_, err := recordBackend(api).UpdateDNSRecord(commandCtx, zoneID.Identifier, params)
+	return err
+}
 
-	recType := args[0]
-	name := args[1]
+// upsertRecord updates the first record matching the type and name so that
+// it holds the requested content, or creates a new record if none exists.
+// A ttl of 0 leaves the TTL of an existing record unchanged, as does a nil
+// comment or tag list in meta. It returns true if a record was created or
+// modified.
+func upsertRecord(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	recType, name, content string, ttl int, meta recordMeta) (bool, error) {
 
-	if len(recType) < 1 {
-		fmt.Printf("Must provide valid DNS record type.")
-		return nil
+	if err := checkOwner(api, zoneID, recType, name); err != nil {
+		return false, err
 	}
 
-	params := cloudflare.ListDNSRecordsParams{
-		Type: recType,
-		Name: name,
+	rec := cflib.Record{
+		Type:    recType,
+		Name:    name,
+		Content: content,
+		TTL:     ttl,
+		Comment: meta.comment,
+		Tags:    meta.tags,
+		Proxied: meta.proxied,
+	}
+	change, err := newClient(api, zoneID).Upsert(commandCtx, rec)
+	if err != nil || change != cflib.Created {
+		return change != cflib.Unchanged, err
+	}
+	return true, markOwned(api, zoneID, recType, name)
+}
+
+// ttlAuto is the TTL value Cloudflare interprets as "automatic".
+const ttlAuto = cflib.TTLAuto
+
+// parseTTL converts a TTL argument into a number of seconds. The string
+// "auto" maps to Cloudflare's automatic TTL.
+func parseTTL(s string) (int, error) {
+	if strings.EqualFold(s, "auto") {
+		return ttlAuto, nil
 	}
-	recs, _, err := api.ListDNSRecords(context.Background(), zoneID, params)
+
+	ttl, err := strconv.Atoi(s)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return nil
+		return 0, fmt.Errorf("invalid TTL %q", s)
 	}
-	if len(recs) < 1 {
-		fmt.Println("No matching record(s) found.")
-		return nil
+	if ttl != ttlAuto && (ttl < 30 || ttl > 86400) {
+		return 0, fmt.Errorf("TTL must be between 30 and 86400 seconds, or auto")
 	}
+	return ttl, nil
+}
 
-	for _, r := range recs {
-		err := api.DeleteDNSRecord(context.Background(), zoneID, r.ID)
-		if err != nil {
-			fmt.Printf("Error deleting %s: %v\n", r.Name, err)
-			continue
-		}
-		fmt.Printf("Deleted %s record %s.\n", r.Type, r.Name)
+// optionalTTL parses the TTL argument at position i if it is present. It
+// returns 0 if the argument is absent.
+func optionalTTL(args []string, i int) (int, error) {
+	if len(args) <= i {
+		return 0, nil
 	}
 
-	return nil
+	ttl, err := parseTTL(args[i])
+	if err != nil {
+		return 0, argError(err)
+	}
+	return ttl, nil
 }
 
-func addOrUpdateRecord(recType, name, content string) {
-	api := getAPI()
-	if api == nil {
-		return
+func formatTTL(ttl int) string {
+	if ttl == ttlAuto {
+		return "auto"
 	}
+	return strconv.Itoa(ttl)
+}
 
-	zoneID := getZoneIdentifier()
-	if zoneID == nil {
-		return
+// confirm asks the user a yes or no question and returns true if the
+// answer is yes.
+func confirm(question string) bool {
+	question = tr(question)
+	if !confirmations {
+		printf("%s [y/N] y (confirmations are off)\n", question)
+		return true
 	}
-
-	zoneIdentifier := zoneID
-	params := cloudflare.ListDNSRecordsParams{
-		Type: recType,
-		Name: name,
-	}
-	recs, _, err := api.ListDNSRecords(context.Background(), zoneIdentifier, params)
-	if err == nil && len(recs) > 0 {
-		r := recs[0]
-		if r.Content != content {
-			params := cloudflare.UpdateDNSRecordParams{
-				Type:    r.Type,
-				Name:    name,
-				Content: content,
-				ID:      r.ID,
-				TTL:     r.TTL,
-			}
-			_, err = api.UpdateDNSRecord(context.Background(), zoneIdentifier, params)
-		}
-	} else {
-		params := cloudflare.CreateDNSRecordParams{
-			Type:      recType,
-			Name:      name,
-			Content:   content,
-			TTL:       1,
-			Proxiable: false,
-		}
-		_, err = api.CreateDNSRecord(context.Background(), zoneIdentifier, params)
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		printf("%s [y/N] n (standard input is not a terminal)\n", question)
+		return false
 	}
 
+	answer, err := readString(question + " [y/N] ")
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return
+		fmt.Fprintln(output)
+		return false
 	}
-
-	fmt.Println("DNS record updated.")
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes" || answer == tr("y") || answer == tr("yes")
 }
 
 func readString(prompt string) (string, error) {
-	fmt.Print(prompt)
+	if activeConsole != nil {
+		return activeConsole.readLine(prompt)
+	}
 
-	reader := bufio.NewReader(os.Stdin)
-	text, err := reader.ReadString('\n')
+	fmt.Fprint(output, prompt)
+
+	text, err := stdin.ReadString('\n')
 	if err != nil {
 		return "", err
 	}
 	return strings.TrimRight(text, "\r\n"), nil
 }
 
+// readCommand reads a command line from standard input. A line ending in a
+// backslash is joined with the line that follows it. A line ending in a
+// heredoc marker such as <<EOF collects all following lines up to a line
+// containing only the marker, and passes them to the command as a single
+// quoted argument with line breaks preserved.
+func readCommand(prompt string) (string, error) {
+	line, err := readString(prompt)
+	if err != nil {
+		return "", err
+	}
+
+	for strings.HasSuffix(line, "\\") {
+		more, err := readString("> ")
+		if err != nil {
+			return "", err
+		}
+		line = line[:len(line)-1] + more
+	}
+
+	if activeConsole != nil {
+		activeConsole.addHistory(line)
+	}
+
+	i := strings.LastIndex(line, "<<")
+	if i < 0 {
+		return line, nil
+	}
+	marker := strings.TrimSpace(line[i+2:])
+	if marker == "" || strings.ContainsAny(marker, " \t\"") {
+		return line, nil
+	}
+
+	var body []string
+	for {
+		more, err := readString("> ")
+		if err != nil {
+			return "", err
+		}
+		if strings.TrimSpace(more) == marker {
+			break
+		}
+		body = append(body, more)
+	}
+
+	content := strings.Join(body, "\n")
+	if strings.Contains(content, "\"") {
+		printf("Error: heredoc content may not contain double quotes.\n")
+		return "", nil
+	}
+	return line[:i] + "\"" + content + "\"", nil
+}
+
 func readHiddenString(prompt string) (string, error) {
-	fmt.Print(prompt)
+	fmt.Fprint(output, prompt)
 
 	bytes, err := term.ReadPassword(int(syscall.Stdin))
 	if err != nil {
 		return "", err
 	}
-	fmt.Println()
+	fmt.Fprintln(output)
 
 	return string(bytes), nil
 }
 
-func getAPI() *cloudflare.API {
+func getAPI() (*cloudflare.API, error) {
 	if activeAPI != nil {
-		return activeAPI
+		return activeAPI, nil
 	}
 
-	var err error
-	email := os.Getenv("CLOUDFLARE_EMAIL")
-	if email == "" {
-		if interactive {
-			email, _ = readString("Enter cloudflare account email: ")
-		} else {
-			fmt.Println("CLOUDFLARE_EMAIL not set.")
-			return nil
+	// Offline, no credentials are needed, as no requests are made.
+	if offline {
+		var err error
+		activeAPI, err = cloudflare.NewWithAPIToken("offline",
+			cloudflare.HTTPClient(&http.Client{Transport: offlineTransport{}}))
+		return activeAPI, err
+	}
+
+	// Credentials in the environment override those of the active profile.
+	credentialSource = ""
+	var p profile
+	if activeProfile != nil {
+		p = *activeProfile
+		credentialSource = "profile " + activeProfileName
+	}
+	if v := os.Getenv("CLOUDFLARE_EMAIL"); v != "" {
+		p.Email = v
+		credentialSource = "environment"
+	}
+	if v := os.Getenv("CLOUDFLARE_KEY"); v != "" {
+		p.Key, p.Token = v, ""
+		credentialSource = "environment"
+	}
+	if v := os.Getenv("CLOUDFLARE_API_TOKEN"); v != "" {
+		p.Token = v
+		credentialSource = "environment"
+	}
+
+	if p.Token == "" && (p.Email == "" || p.Key == "") {
+		if creds, ok := loadKeyringCredentials(); ok {
+			p.Email, p.Key, p.Token = creds.Email, creds.Key, creds.Token
+			credentialSource = "system keyring"
 		}
 	}
 
-	key := os.Getenv("CLOUDFLARE_KEY")
-	if key == "" {
-		if interactive {
-			key, _ = readHiddenString("Enter cloudflare API key: ")
-		} else {
-			fmt.Println("CLOUDFLARE_KEY not set.")
-			return nil
+	prompted := false
+	if p.Token == "" {
+		if p.Email == "" {
+			if interactive {
+				p.Email, _ = readString(tr("Enter cloudflare account email: "))
+				prompted = true
+			} else {
+				return nil, authError(errors.New("CLOUDFLARE_EMAIL not set"))
+			}
+		}
+
+		if p.Key == "" {
+			if interactive {
+				p.Key, _ = readHiddenString(tr("Enter cloudflare API key: "))
+				prompted = true
+			} else {
+				return nil, authError(errors.New("CLOUDFLARE_KEY not set"))
+			}
 		}
 	}
 
-	activeAPI, err = cloudflare.New(key, email)
+	var err error
+	rateLimit := defaultRateLimit
+	if v := os.Getenv("CLOUDFLARE_RATE_LIMIT"); v != "" {
+		rateLimit, err = strconv.ParseFloat(v, 64)
+		if err != nil || rateLimit <= 0 {
+			return nil, errors.New("CLOUDFLARE_RATE_LIMIT must be a positive number")
+		}
+	}
+
+	// Failed requests are retried by retryTransport, which unlike the
+	// client library honors Retry-After headers.
+	opts := []cloudflare.Option{
+		cloudflare.UsingRateLimit(rateLimit),
+		cloudflare.UsingRetryPolicy(0, 0, 0),
+		cloudflare.HTTPClient(&http.Client{
+			Transport: &freezeTransport{
+				base: &totpTransport{
+					base: &cacheTransport{
+						base: &retryTransport{
+							base: &dryRunTransport{
+								base: &logTransport{base: http.DefaultTransport},
+							},
+						},
+					},
+				},
+			},
+		}),
+	}
+	if p.Token != "" {
+		activeAPI, err = cloudflare.NewWithAPIToken(p.Token, opts...)
+	} else {
+		activeAPI, err = cloudflare.New(p.Key, p.Email, opts...)
+	}
 	if err != nil {
-		fmt.Printf("Error: %v", err)
-		return nil
+		return nil, authError(err)
 	}
 
-	return activeAPI
+	if prompted {
+		credentialSource = "prompt"
+		offerKeyringStorage(keyringCredentials{Email: p.Email, Key: p.Key})
+	}
+
+	return activeAPI, nil
 }
 
-func getZoneIdentifier() *cloudflare.ResourceContainer {
+// zoneFlag is the zone given with the leading --zone flag. It takes
+// precedence over CLOUDFLARE_ZONE and the profile's zone.
+var zoneFlag string
+
+func getZoneIdentifier() (*cloudflare.ResourceContainer, error) {
 	if activeZoneIdentifier != nil {
-		return activeZoneIdentifier
+		return activeZoneIdentifier, nil
 	}
 
-	api := getAPI()
-	if api == nil {
-		return nil
+	api, err := getAPI()
+	if err != nil {
+		return nil, err
 	}
 
-	var err error
-	zoneName := os.Getenv("CLOUDFLARE_ZONE")
+	zoneName := zoneFlag
+	if zoneName == "" {
+		zoneName = os.Getenv("CLOUDFLARE_ZONE")
+	}
+	if zoneName == "" {
+		zoneName = resumedZone
+	}
+	if zoneName == "" && activeProfile != nil {
+		zoneName = activeProfile.Zone
+	}
 	if zoneName == "" && interactive {
-		zoneName, _ = readString("Enter zone name: ")
+		zoneName, _ = readString(tr("Enter zone name: "))
 	}
 	if zoneName == "" {
-		fmt.Println("CLOUDFLARE_ZONE not set.")
-		return nil
+		return nil, zoneError(errors.New("CLOUDFLARE_ZONE not set"))
 	}
 
-	zoneID, err := api.ZoneIDByName(zoneName)
+	// A zone ID given along with the zone name is used without looking
+	// up the name, which credentials that cannot list zones are unable to.
+	switch {
+	case zoneName == os.Getenv("CLOUDFLARE_ZONE") && os.Getenv("CLOUDFLARE_ZONE_ID") != "":
+		rememberZoneID(zoneName, os.Getenv("CLOUDFLARE_ZONE_ID"))
+	case activeProfile != nil && zoneName == activeProfile.Zone && activeProfile.ZoneID != "":
+		rememberZoneID(zoneName, activeProfile.ZoneID)
+	}
+
+	zoneID, err := recordBackend(api).ZoneIDByName(zoneName)
 	if err != nil {
-		fmt.Printf("Error: %v\n", err)
-		return nil
+		// A resumed zone that no longer exists is not tried again.
+		if zoneName == resumedZone {
+			resumedZone = ""
+		}
+		return nil, zoneError(err)
 	}
 
 	activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
-	return activeZoneIdentifier
+	activeZoneName = zoneName
+	return activeZoneIdentifier, nil
 }
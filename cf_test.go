@@ -0,0 +1,3003 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"maps"
+	"math"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// useMemoryBackend makes the zone example.com, held in memory, the active
+// zone for the duration of a test.
+func useMemoryBackend(t *testing.T) *cflib.MemoryBackend {
+	t.Helper()
+
+	b := cflib.NewMemoryBackend("example.com")
+	zoneID, err := b.ZoneIDByName("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Setenv("CLOUDFLARE_STATE_DIR", t.TempDir())
+	backend = b
+	activeAPI = &cloudflare.API{}
+	activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
+	activeZoneName = "example.com"
+	interactive = false
+	t.Cleanup(func() {
+		backend = nil
+		activeAPI = nil
+		activeZoneIdentifier = nil
+		activeZoneName = ""
+	})
+	return b
+}
+
+// summarize returns the type, name and content of each record.
+func summarize(recs []cloudflare.DNSRecord) []string {
+	var s []string
+	for _, r := range recs {
+		s = append(s, r.Type+" "+r.Name+" "+r.Content)
+	}
+	return s
+}
+
+func checkRecords(t *testing.T, b *cflib.MemoryBackend, want ...string) {
+	t.Helper()
+	if got := summarize(b.Records()); !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+}
+
+func TestAddOrUpdateRecord(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	if err := addOrUpdateRecord("A", "www.example.com", "10.0.0.1", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+	if ttl := b.Records()[0].TTL; ttl != ttlAuto {
+		t.Errorf("TTL of new record = %d, want %d", ttl, ttlAuto)
+	}
+
+	if err := addOrUpdateRecord("A", "WWW.example.com.", "10.0.0.2", 300, recordMeta{}, recordTarget{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.2")
+
+	// A TTL of 0 keeps the existing TTL.
+	if err := addOrUpdateRecord("A", "www.example.com", "10.0.0.3", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	if ttl := b.Records()[0].TTL; ttl != 300 {
+		t.Errorf("TTL of updated record = %d, want 300", ttl)
+	}
+
+	if err := addOrUpdateRecord("AAAA", "www.example.com", "2001:db8::1", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.3", "AAAA www.example.com 2001:db8::1")
+}
+
+func TestUpsertRecordUnchanged(t *testing.T) {
+	useMemoryBackend(t)
+
+	changed, err := upsertRecord(activeAPI, activeZoneIdentifier, "AAAA", "www.example.com", "2001:db8::1", 0, recordMeta{})
+	if err != nil || !changed {
+		t.Fatalf("first upsert = %v, %v; want true, nil", changed, err)
+	}
+	changed, err = upsertRecord(activeAPI, activeZoneIdentifier, "AAAA", "www.example.com", "2001:DB8:0::1", 0, recordMeta{})
+	if err != nil || changed {
+		t.Fatalf("equivalent upsert = %v, %v; want false, nil", changed, err)
+	}
+
+	comment := "web server"
+	changed, err = upsertRecord(activeAPI, activeZoneIdentifier, "AAAA", "www.example.com", "2001:db8::1", 0, recordMeta{comment: &comment})
+	if err != nil || !changed {
+		t.Fatalf("upsert with comment = %v, %v; want true, nil", changed, err)
+	}
+}
+
+func TestIPv4MappedAAAA(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	// An IPv4-mapped address stays in its IPv6 form, as AAAA content must.
+	if got := cflib.CanonicalIP("::FFFF:192.0.2.1"); got != "::ffff:192.0.2.1" {
+		t.Errorf("CanonicalIP = %q", got)
+	}
+	for _, line := range []string{
+		"ip6 www.example.com ::FFFF:192.0.2.1",
+		"add AAAA api.example.com 0:0:0:0:0:ffff:c000:202",
+	} {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	checkRecords(t, b, "AAAA api.example.com ::ffff:192.0.2.2", "AAAA www.example.com ::ffff:192.0.2.1")
+
+	// It shares the origin of its IPv4 address.
+	if a, aaaa := originKey("A", "192.0.2.1"), originKey("AAAA", "::ffff:192.0.2.1"); a != aaaa {
+		t.Errorf("origin keys %q and %q differ", a, aaaa)
+	}
+}
+
+func TestDeleteMatching(t *testing.T) {
+	b := useMemoryBackend(t)
+	for _, r := range []struct{ recType, name, content string }{
+		{"A", "www.example.com", "10.0.0.1"},
+		{"A", "api.example.com", "10.0.0.2"},
+		{"A", "mail.example.com", "10.0.1.1"},
+		{"AAAA", "www.example.com", "2001:db8::1"},
+		{"TXT", "www.example.com", "hello"},
+	} {
+		if err := addOrUpdateRecord(r.recType, r.name, r.content, 0, recordMeta{}, recordTarget{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := processCmd("delete A www.example.com --force"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A api.example.com 10.0.0.2",
+		"A mail.example.com 10.0.1.1",
+		"AAAA www.example.com 2001:db8::1",
+		"TXT www.example.com hello")
+
+	if out := captureOutput(t, "delete A *.example.com --content 10.0.0.* --force"); !strings.Contains(out, "\n1 record(s) deleted.\n") {
+		t.Errorf("delete output = %q", out)
+	}
+	checkRecords(t, b,
+		"A mail.example.com 10.0.1.1",
+		"AAAA www.example.com 2001:db8::1",
+		"TXT www.example.com hello")
+
+	if err := processCmd("delete CNAME www.example.com --force"); err != errNoMatch {
+		t.Errorf("deleting a missing record returned %v, want %v", err, errNoMatch)
+	}
+
+	// Without --force, deletions require a confirmation that cannot be
+	// given when standard input is not a terminal.
+	if err := processCmd("delete TXT www.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A mail.example.com 10.0.1.1",
+		"AAAA www.example.com 2001:db8::1",
+		"TXT www.example.com hello")
+
+	// Content without wildcards matches the whole content, so addresses
+	// merely containing it survive.
+	for _, r := range []struct{ name, content string }{
+		{"near.example.com", "10.0.1.10"},
+		{"far.example.com", "110.0.1.1"},
+	} {
+		if err := addOrUpdateRecord("A", r.name, r.content, 0, recordMeta{}, recordTarget{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := processCmd("delete A * --content 10.0.1.1 --force"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A far.example.com 110.0.1.1",
+		"A near.example.com 10.0.1.10",
+		"AAAA www.example.com 2001:db8::1",
+		"TXT www.example.com hello")
+}
+
+func TestFormatArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{nil, ""},
+		{[]string{"list"}, "list"},
+		{[]string{"txt", "example.com", "v=spf1 -all"}, `txt example.com "v=spf1 -all"`},
+		{[]string{"txt", "example.com", "a\tb"}, "txt example.com \"a\tb\""},
+		{[]string{"delete", "A", "*.example.com"}, "delete A *.example.com"},
+		{[]string{"comment", "A", "www.example.com", ""}, `comment A www.example.com ""`},
+	}
+	for _, test := range tests {
+		if got := formatArgs(test.args); got != test.want {
+			t.Errorf("formatArgs(%q) = %q, want %q", test.args, got, test.want)
+		}
+	}
+}
+
+func TestProcessArgs(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	// Arguments reach the command as the shell split them, quotes included.
+	for _, argv := range [][]string{
+		{"txt", "example.com", `v=DKIM1; n="two words"`},
+		{"ip", "www.example.com", "10.0.0.1"},
+	} {
+		if err := processArgs(argv); err != nil {
+			t.Fatalf("%q: %v", argv, err)
+		}
+	}
+	checkRecords(t, b,
+		`TXT example.com v=DKIM1; n="two words"`,
+		"A www.example.com 10.0.0.1")
+
+	for _, argv := range [][]string{{"no-such-command"}, {"two words"}, {""}} {
+		if err := processArgs(argv); err != errCommandNotFound {
+			t.Errorf("%q returned %v, want %v", argv, err, errCommandNotFound)
+		}
+	}
+}
+
+func TestCaddyfileHosts(t *testing.T) {
+	data := `{
+	email admin@example.com
+}
+
+(common) {
+	encode gzip
+}
+
+example.com, www.example.com {
+	import common
+	reverse_proxy localhost:8080
+}
+
+https://api.example.com:8443/v1 {
+	respond "ok" # api.example.com is not repeated
+}
+
+localhost {
+	respond "local"
+}
+`
+	want := []string{"example.com", "www.example.com", "api.example.com"}
+	if got := caddyfileHosts(data); !slices.Equal(got, want) {
+		t.Errorf("caddyfileHosts = %q, want %q", got, want)
+	}
+}
+
+func TestTraefikHosts(t *testing.T) {
+	data := "http:\n" +
+		"  routers:\n" +
+		"    web:\n" +
+		"      rule: \"Host(`www.example.com`, `example.com`) && PathPrefix(`/`)\"\n" +
+		"    db:\n" +
+		"      rule: \"HostSNI(`db.example.com`)\"\n" +
+		"labels:\n" +
+		"  - traefik.frontend.rule=Host:old.example.com,legacy.example.com\n"
+	want := []string{"www.example.com", "example.com", "db.example.com", "old.example.com", "legacy.example.com"}
+	if got := traefikHosts(data); !slices.Equal(got, want) {
+		t.Errorf("traefikHosts = %q, want %q", got, want)
+	}
+}
+
+func TestImportRoundTrip(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	filename := filepath.Join(t.TempDir(), "Caddyfile")
+	data := "www.example.com, api.example.com {\n\treverse_proxy app:80\n}\n\nother.org {\n}\n"
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	line := "import --from caddyfile --target 203.0.113.7 --force " + filename
+	if err := processCmd(line); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 203.0.113.7", "A www.example.com 203.0.113.7")
+
+	// The records read back from the zone propose no further changes.
+	proposals := []proposal{
+		{recType: "A", name: "api.example.com", content: "203.0.113.7"},
+		{recType: "A", name: "www.example.com", content: "203.0.113.7"},
+	}
+	changes, err := planProposals(activeAPI, activeZoneIdentifier, proposals, conflictOverwrite)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if changes != 0 {
+		t.Errorf("planProposals after import = %d change(s), want 0", changes)
+	}
+
+	// Importing again with a new target updates the existing records.
+	line = "import --from caddyfile --target 203.0.113.8 --force " + filename
+	if err := processCmd(line); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 203.0.113.8", "A www.example.com 203.0.113.8")
+}
+
+func TestImportConflicts(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	filename := filepath.Join(t.TempDir(), "Caddyfile")
+	data := "www.example.com, api.example.com {\n\treverse_proxy app:80\n}\n"
+	if err := os.WriteFile(filename, []byte(data), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("ip4 www.example.com 10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	importWith := func(strategy string) error {
+		return processCmd("import --from caddyfile --target 203.0.113.7 --force --on-conflict " +
+			strategy + " " + filename)
+	}
+
+	if err := importWith("fail"); err == nil {
+		t.Error("import --on-conflict fail succeeded with a conflict")
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+
+	if err := importWith("skip"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 203.0.113.7", "A www.example.com 10.0.0.1")
+
+	if err := importWith("merge"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 203.0.113.7", "A www.example.com 10.0.0.1",
+		"A www.example.com 203.0.113.7")
+
+	// Merged records are not added again.
+	if err := importWith("merge"); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(b.Records()); n != 3 {
+		t.Errorf("%d records after repeated merge, want 3", n)
+	}
+
+	if err := importWith("bogus"); err == nil {
+		t.Error("import accepted an invalid conflict strategy")
+	}
+}
+
+func TestCommentAndTag(t *testing.T) {
+	b := useMemoryBackend(t)
+	for _, name := range []string{"www.example.com", "api.example.com", "mail.example.com"} {
+		if err := addOrUpdateRecord("A", name, "10.0.0.1", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := addOrUpdateRecord("TXT", "www.example.com", "hello", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+		t.Fatal(err)
+	}
+
+	steps := []string{
+		"comment set --force web A",
+		"comment append --force team:ops * *.example.com",
+		"tag add --force owner:web A [wa]*.example.com",
+		"tag add --force owner:web A www.example.com",
+		"tag remove --force owner:web * api.example.com",
+	}
+	for _, line := range steps {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+
+	var got []string
+	for _, r := range b.Records() {
+		got = append(got, r.Type+" "+r.Name+" "+r.Comment+" "+formatTags(r.Tags))
+	}
+	want := []string{
+		"A api.example.com web team:ops -",
+		"A mail.example.com web team:ops -",
+		"A www.example.com web team:ops owner:web",
+		"TXT www.example.com team:ops -",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+
+	if err := processCmd("tag add --force x CNAME"); err != errNoMatch {
+		t.Errorf("tagging missing records returned %v, want %v", err, errNoMatch)
+	}
+}
+
+// An updateRecorder is a memory backend that records the parameters of
+// the updates it is asked to make.
+type updateRecorder struct {
+	*cflib.MemoryBackend
+	mu      sync.Mutex
+	updates []cloudflare.UpdateDNSRecordParams
+}
+
+func (b *updateRecorder) UpdateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	b.mu.Lock()
+	b.updates = append(b.updates, params)
+	b.mu.Unlock()
+	return b.MemoryBackend.UpdateDNSRecord(ctx, zoneID, params)
+}
+
+func TestTTL(t *testing.T) {
+	b := &updateRecorder{MemoryBackend: useMemoryBackend(t)}
+	backend = b
+	if err := addOrUpdateRecord("A", "www.example.com", "10.0.0.1", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+		t.Fatal(err)
+	}
+	priority := uint16(10)
+	_, err := b.CreateDNSRecord(context.Background(), activeZoneIdentifier.Identifier, cloudflare.CreateDNSRecordParams{
+		Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: &priority, TTL: ttlAuto,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, line := range []string{"ttl A www.example.com 300", "ttl MX example.com 600"} {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	for _, r := range b.Records() {
+		if want := map[string]int{"A": 300, "MX": 600}[r.Type]; r.TTL != want {
+			t.Errorf("TTL of %s record = %d, want %d", r.Type, r.TTL, want)
+		}
+	}
+
+	// The update of the MX record carries its priority, which the API
+	// requires.
+	if len(b.updates) != 2 {
+		t.Fatalf("%d updates, want 2", len(b.updates))
+	}
+	if p := b.updates[1].Priority; p == nil || *p != priority {
+		t.Errorf("priority of MX update = %v, want %d", p, priority)
+	}
+}
+
+func TestWindow(t *testing.T) {
+	recs := make([]zoneRecord, 5)
+	for i := range recs {
+		recs[i].Name = string(rune('a' + i))
+	}
+	tests := []struct {
+		limit, page int
+		want        string
+	}{
+		{0, 1, "abcde"},
+		{2, 1, "ab"},
+		{2, 3, "e"},
+		{2, 4, ""},
+		{10, 1, "abcde"},
+	}
+	for _, test := range tests {
+		got := ""
+		for _, r := range window(recs, test.limit, test.page) {
+			got += r.Name
+		}
+		if got != test.want {
+			t.Errorf("window(%d, %d) = %q, want %q", test.limit, test.page, got, test.want)
+		}
+	}
+}
+
+func TestGroupRecords(t *testing.T) {
+	rec := func(name, comment string, tags ...string) zoneRecord {
+		return zoneRecord{DNSRecord: cloudflare.DNSRecord{Type: "A", Name: name, Comment: comment, Tags: tags}}
+	}
+	recs := []zoneRecord{
+		rec("a.example.com", "billing: api", "team:web", "env:prod"),
+		rec("b.example.com", "billing - cron", "team:api"),
+		rec("c.example.com", ""),
+	}
+
+	tests := []struct {
+		groupBy string
+		want    []string
+	}{
+		{"tag", []string{"env:prod a", "team:api b", "team:web a", "(none) c"}},
+		{"comment-prefix", []string{"billing ab", "(none) c"}},
+		{"type", []string{"A abc"}},
+	}
+	for _, test := range tests {
+		keys, groups := groupRecords(recs, test.groupBy)
+		var got []string
+		for _, k := range keys {
+			names := ""
+			for _, r := range groups[k] {
+				names += r.Name[:1]
+			}
+			got = append(got, k+" "+names)
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("groupRecords(%s) = %q, want %q", test.groupBy, got, test.want)
+		}
+	}
+}
+
+func TestSortRecords(t *testing.T) {
+	rec := func(typ, name, content string, ttl int) zoneRecord {
+		return zoneRecord{DNSRecord: cloudflare.DNSRecord{Type: typ, Name: name, Content: content, TTL: ttl}}
+	}
+
+	tests := []struct {
+		by   string
+		want string
+	}{
+		{"name", "abcd"},
+		{"type", "bacd"},
+		{"ttl", "cabd"},
+		{"content", "bacd"},
+	}
+	for _, test := range tests {
+		recs := []zoneRecord{
+			rec("MX", "c.example.com", "mail.example.com", 60),
+			rec("A", "b.example.com", "192.0.2.2", 3600),
+			rec("TXT", "d.example.com", "v=spf1 -all", 3600),
+			rec("MX", "a.example.com", "mail.example.com", 1),
+		}
+		sortRecords(recs, test.by)
+		got := ""
+		for _, r := range recs {
+			got += r.Name[:1]
+		}
+		if got != test.want {
+			t.Errorf("sortRecords(%s) = %s, want %s", test.by, got, test.want)
+		}
+	}
+}
+
+func TestRetryQueue(t *testing.T) {
+	b := useMemoryBackend(t)
+	t.Setenv("CLOUDFLARE_STATE_DIR", t.TempDir())
+
+	zoneID := activeZoneIdentifier.Identifier
+	ops := []operation{
+		{change: createChange(zoneID, "example.com", cloudflare.CreateDNSRecordParams{
+			Type: "A", Name: "www.example.com", Content: "10.0.0.1", TTL: ttlAuto,
+		})},
+		{change: createChange(zoneID, "example.com", cloudflare.CreateDNSRecordParams{
+			Type: "A", Name: "bad.example.com", Content: "not-an-address", TTL: ttlAuto,
+		})},
+	}
+	queueRetries(ops, []error{errInterrupted, errors.New("invalid content")})
+
+	var queue []queuedChange
+	err := withStateLock(func() (err error) {
+		queue, _, err = readRetryQueue()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 1 || queue[0].Record.Name != "www.example.com" {
+		t.Fatalf("queue = %+v, want only the change to www.example.com", queue)
+	}
+
+	if err := retryRun(true); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+
+	err = withStateLock(func() (err error) {
+		queue, _, err = readRetryQueue()
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(queue) != 0 {
+		t.Errorf("queue has %d change(s) after a successful run", len(queue))
+	}
+}
+
+func TestParseResolvers(t *testing.T) {
+	got, err := parseResolvers("9.9.9.9, dns.example.net,2001:db8::53")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"9.9.9.9", "dns.example.net", "2001:db8::53"}; !slices.Equal(got, want) {
+		t.Errorf("resolvers = %q, want %q", got, want)
+	}
+	for _, s := range []string{"", "1.1.1.1,", "dns example"} {
+		if _, err := parseResolvers(s); err == nil {
+			t.Errorf("parseResolvers(%q) succeeded", s)
+		}
+	}
+}
+
+func TestEdit(t *testing.T) {
+	b := useMemoryBackend(t)
+	for _, name := range []string{"www.example.com", "api.example.com", "mail.example.com"} {
+		if err := addOrUpdateRecord("A", name, "10.0.0.1", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := runEditor
+	defer func() { runEditor = saved }()
+	runEditor = func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var lines []string
+		for _, line := range strings.Split(string(data), "\n") {
+			switch {
+			case strings.Contains(line, "mail.example.com"):
+				continue
+			case strings.Contains(line, "www.example.com"):
+				line = strings.Replace(line, "10.0.0.1", "10.0.0.2", 1)
+			}
+			lines = append(lines, line)
+		}
+		lines = append(lines, "- TXT www.example.com auto off hello world")
+		return os.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600)
+	}
+
+	if err := processCmd("edit --force A"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A api.example.com 10.0.0.1",
+		"A www.example.com 10.0.0.2",
+		"TXT www.example.com hello world",
+	)
+}
+
+func TestEditJSON(t *testing.T) {
+	b := useMemoryBackend(t)
+	ctx := context.Background()
+	prio := uint16(10)
+	for _, params := range []cloudflare.CreateDNSRecordParams{
+		{Type: "MX", Name: "example.com", Content: "mail.example.com", Priority: &prio},
+		{Type: "SRV", Name: "_sip._tcp.example.com", Data: map[string]any{
+			"priority": 10, "weight": 5, "port": 5060, "target": "sip.example.com",
+		}},
+	} {
+		if _, err := b.CreateDNSRecord(ctx, activeZoneIdentifier.Identifier, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	saved := runEditor
+	defer func() { runEditor = saved }()
+	runEditor = func(path string) error {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		var recs []editJSONRecord
+		if err := json.Unmarshal(data, &recs); err != nil {
+			return err
+		}
+		for i := range recs {
+			switch recs[i].Type {
+			case "MX":
+				p := uint16(20)
+				recs[i].Priority = &p
+			case "SRV":
+				recs[i].Data["port"] = 5061
+			}
+		}
+		data, _ = json.Marshal(recs)
+		return os.WriteFile(path, data, 0600)
+	}
+
+	if err := processCmd("edit --json --force"); err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range b.Records() {
+		switch r.Type {
+		case "MX":
+			if r.Priority == nil || *r.Priority != 20 {
+				t.Errorf("MX priority = %v, want 20", r.Priority)
+			}
+		case "SRV":
+			if port := dataMap(r.Data)["port"]; port != float64(5061) {
+				t.Errorf("SRV port = %v, want 5061", port)
+			}
+		}
+	}
+
+	if _, err := parseEditJSON(`[{"type": "MX", "name": "example.com", "content": "mail.example.com"}]`); err == nil {
+		t.Error("parseEditJSON accepted an MX record without a priority")
+	}
+}
+
+func TestEditConflicts(t *testing.T) {
+	b := useMemoryBackend(t)
+	for _, name := range []string{"www.example.com", "api.example.com", "mail.example.com"} {
+		if err := addOrUpdateRecord("A", name, "10.0.0.1", 0, recordMeta{}, recordTarget{}, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
+	ids := make(map[string]string)
+	for _, r := range b.Records() {
+		ids[r.Name] = r.ID
+	}
+
+	// While the editor is open, www is changed and mail deleted in the zone.
+	saved := runEditor
+	defer func() { runEditor = saved }()
+	runEditor = func(path string) error {
+		ctx, zoneID := context.Background(), activeZoneIdentifier.Identifier
+		if _, err := b.UpdateDNSRecord(ctx, zoneID, cloudflare.UpdateDNSRecordParams{
+			ID: ids["www.example.com"], Type: "A", Name: "www.example.com", Content: "10.0.0.9",
+		}); err != nil {
+			return err
+		}
+		if err := b.DeleteDNSRecord(ctx, zoneID, ids["mail.example.com"]); err != nil {
+			return err
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		edited := strings.NewReplacer(
+			"www.example.com  auto off 10.0.0.1", "www.example.com  auto off 10.0.0.2",
+			"api.example.com  auto off 10.0.0.1", "api.example.com  auto off 10.0.0.4",
+			"mail.example.com auto off 10.0.0.1", "mail.example.com auto off 10.0.0.3",
+		).Replace(string(data))
+		return os.WriteFile(path, []byte(edited), 0600)
+	}
+
+	// With --force, the edits are applied over the zone's changes.
+	if err := processCmd("edit --force A"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A api.example.com 10.0.0.4",
+		"A mail.example.com 10.0.0.3",
+		"A www.example.com 10.0.0.2",
+	)
+
+	// Otherwise, outside interactive mode, conflicting records are skipped.
+	orig := b.Records()
+	live := slices.Clone(orig)
+	live[2].Content = "10.0.0.9"
+	upd := orig[0]
+	upd.Content = "10.0.0.5"
+	plan := editPlan{updates: []cloudflare.DNSRecord{upd}, deletes: []cloudflare.DNSRecord{orig[2]}}
+	conflicts := findEditConflicts(orig, live, plan)
+	if len(conflicts) != 1 || conflicts[0].orig.ID != orig[2].ID || conflicts[0].edited != nil {
+		t.Fatalf("conflicts = %+v", conflicts)
+	}
+	resolved, skipped, err := resolveEditConflicts(plan, conflicts, false, false)
+	if err != nil || skipped != 1 || len(resolved.updates) != 1 || len(resolved.deletes) != 0 {
+		t.Errorf("resolved = %+v, %d skipped, %v", resolved, skipped, err)
+	}
+}
+
+func TestUndo(t *testing.T) {
+	b := useMemoryBackend(t)
+	steps := []string{
+		"ip4 www.example.com 10.0.0.1",
+		"ip4 www.example.com 10.0.0.2",
+		"ip4 api.example.com 10.0.0.3",
+		"delete --force A api.example.com",
+	}
+	for _, line := range steps {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.2")
+
+	if err := processCmd("undo --force"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 10.0.0.3", "A www.example.com 10.0.0.2")
+	if err := processCmd("undo --force 2"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 10.0.0.3", "A www.example.com 10.0.0.1")
+
+	// The restored record of api.example.com has a new ID, which undoing
+	// its creation must find.
+	if err := processCmd("undo --force 1"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+}
+
+func TestRecordHistory(t *testing.T) {
+	b := useMemoryBackend(t)
+	steps := []string{
+		"ip4 www.example.com 10.0.0.1",
+		"ip4 www.example.com 10.0.0.2",
+		"ip4 api.example.com 10.0.0.3",
+		"delete --force A www.example.com",
+		"history A www.example.com",
+		"history --since 1h A @",
+	}
+	for _, line := range steps {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+	if err := processCmd("history --since soon A www.example.com"); err == nil {
+		t.Error("history accepted an invalid time")
+	}
+
+	// A change seen by watch is recorded unless cf made it.
+	api := b.Records()[0]
+	changed := api
+	changed.Content = "10.0.0.4"
+	recordObservedChanges(activeZoneIdentifier.Identifier, []recordDiff{
+		{Old: nil, New: &api},
+		{Old: &api, New: &changed},
+	})
+
+	path, err := statePath("record-history")
+	if err != nil {
+		t.Fatal(err)
+	}
+	entries, err := readHistory(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, strings.Join([]string{e.Source, e.Action, e.Name, e.Previous, e.Content}, " "))
+	}
+	want := []string{
+		"cf create www.example.com  10.0.0.1",
+		"cf update www.example.com 10.0.0.1 10.0.0.2",
+		"cf create api.example.com  10.0.0.3",
+		"cf delete www.example.com  10.0.0.2",
+		"watch update api.example.com 10.0.0.3 10.0.0.4",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("history = %q, want %q", got, want)
+	}
+}
+
+func TestAuditLog(t *testing.T) {
+	useMemoryBackend(t)
+	auditLogPath = filepath.Join(t.TempDir(), "audit.log")
+	defer func() { auditLogPath = "" }()
+
+	steps := []string{
+		"ip4 www.example.com 10.0.0.1",
+		"ip4 www.example.com 10.0.0.2",
+		"list",
+	}
+	for _, line := range steps {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+
+	data, err := os.ReadFile(auditLogPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, line := range strings.Split(strings.TrimSpace(string(data)), "\n") {
+		var e auditEntry
+		if err := json.Unmarshal([]byte(line), &e); err != nil {
+			t.Fatal(err)
+		}
+		s := e.Command + ": " + e.Action
+		switch {
+		case e.Status != nil:
+			s += fmt.Sprintf(" %d", *e.Status)
+		case e.After != nil:
+			s += " " + e.Zone + " " + e.After.Content
+		}
+		got = append(got, s)
+	}
+
+	want := []string{
+		"ip4 www.example.com 10.0.0.1: create example.com 10.0.0.1",
+		"ip4 www.example.com 10.0.0.1: exit 0",
+		"ip4 www.example.com 10.0.0.2: update example.com 10.0.0.2",
+		"ip4 www.example.com 10.0.0.2: exit 0",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("audit log:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestApex(t *testing.T) {
+	b := useMemoryBackend(t)
+	if err := processCmd("ip4 example.com 10.0.0.9"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processCmd("apex --www 10.0.0.1 2001:db8::1"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A example.com 10.0.0.1",
+		"AAAA example.com 2001:db8::1",
+		"CNAME www.example.com example.com")
+	for _, r := range b.Records() {
+		if !isProxied(r) {
+			t.Errorf("%s record %s is not proxied", r.Type, r.Name)
+		}
+	}
+
+	if err := processCmd("apex 2001:db8::1"); err == nil {
+		t.Error("apex accepted an IPv6 address for the A record")
+	}
+}
+
+func TestDiffRecords(t *testing.T) {
+	const zoneFile = `$ORIGIN example.com.
+$TTL 3600
+@	IN	SOA	ns1.example.com. admin.example.com. (
+		2024030101 ; serial
+		7200 3600 1209600 3600 )
+@	86400	IN	NS	ada.ns.cloudflare.com.
+www	1	IN	A	10.0.0.1 ; cf_tags=cf-proxied:true
+api	300	IN	A	10.0.0.3 ; cf_tags=cf-proxied:false
+	300	IN	A	10.0.0.4
+@	IN	MX	10 mail
+@	IN	TXT	"v=spf1 " "-all ; not a comment"
+`
+	snapshot, err := parseZoneFile(strings.NewReader(zoneFile), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshot) != 6 {
+		t.Fatalf("parsed %d records, want 6", len(snapshot))
+	}
+
+	prio := uint16(10)
+	on, off := true, false
+	live := []cloudflare.DNSRecord{
+		{Type: "A", Name: "www.example.com", Content: "10.0.0.2", TTL: 1, Proxied: &on},
+		{Type: "A", Name: "api.example.com", Content: "10.0.0.3", TTL: 300, Proxied: &on},
+		{Type: "MX", Name: "example.com", Content: "mail.example.com", TTL: 3600, Priority: &prio},
+		{Type: "TXT", Name: "example.com", Content: "v=spf1 -all ; not a comment", TTL: 3600},
+		{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1", TTL: 1, Proxied: &off},
+	}
+
+	var got []string
+	for _, d := range diffRecords(snapshot[1:], live) {
+		line, _ := formatDiff(d)
+		got = append(got, line)
+	}
+	want := []string{
+		"~ A api.example.com 10.0.0.3 (proxied off -> on)",
+		"- A api.example.com 10.0.0.4",
+		"~ A www.example.com 10.0.0.1 -> 10.0.0.2",
+		"+ AAAA www.example.com 2001:db8::1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diff:\n%s\nwant:\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{
+		"example.com-20240301T000000Z.zone",
+		"example.com-20240302T000000Z.zone",
+		"example.com-20240303T000000Z.zone",
+		"example.com-notes.zone",
+		"example.org-20240301T000000Z.zone",
+	}
+	for _, f := range files {
+		if err := os.WriteFile(filepath.Join(dir, f), nil, 0o600); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := pruneBackups(dir, "example.com", ".zone", 2); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, e := range entries {
+		got = append(got, e.Name())
+	}
+	want := []string{
+		"example.com-20240302T000000Z.zone",
+		"example.com-20240303T000000Z.zone",
+		"example.com-notes.zone",
+		"example.org-20240301T000000Z.zone",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("files = %q, want %q", got, want)
+	}
+}
+
+func TestDDNSTargets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+	useMemoryBackend(t)
+	b := cflib.NewMemoryBackend("example.com", "example.org")
+	backend = b
+
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{DDNS: []ddnsZone{
+		{Zone: "example.com", Names: []string{"home.example.com", "vpn.example.com"}},
+		{Zone: "example.org", Names: []string{"example.org"}},
+	}}
+
+	if err := processCmd(`ddns --once --source "command:echo 203.0.113.9"`); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"A example.org 203.0.113.9",
+		"A home.example.com 203.0.113.9",
+		"A vpn.example.com 203.0.113.9")
+}
+
+func TestSetTunnelRoute(t *testing.T) {
+	b := useMemoryBackend(t)
+	const id = "c1744f8b-faa1-48a4-9e5c-02ac921467fa"
+	if err := setTunnelRoute(activeAPI, activeZoneIdentifier, "app.example.com", id); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "CNAME app.example.com "+id+".cfargotunnel.com")
+	if !isProxied(b.Records()[0]) {
+		t.Error("tunnel route is not proxied")
+	}
+}
+
+func TestFailover(t *testing.T) {
+	b := useMemoryBackend(t)
+	f := failoverRecord{
+		Zone:    "example.com",
+		Name:    "www.example.com",
+		Primary: "10.0.0.1",
+		Standby: "10.0.0.2",
+		Check:   "tcp:127.0.0.1:1",
+		UpAfter: 2,
+	}
+	if err := f.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	results := []bool{false, false, true, false, false, false, true, true}
+	var got []string
+	for _, healthy := range results {
+		addr := f.observe(healthy)
+		if addr == "" {
+			continue
+		}
+		got = append(got, addr)
+		if err := failOver(activeAPI, activeZoneIdentifier, &f, addr); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if want := []string{"10.0.0.2", "10.0.0.1"}; !slices.Equal(got, want) {
+		t.Errorf("failovers = %q, want %q", got, want)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+}
+
+func TestPinnedRecords(t *testing.T) {
+	b := useMemoryBackend(t)
+	zoneID := activeZoneIdentifier.Identifier
+	drift := filepath.Join(t.TempDir(), "drift")
+	p := pinnedRecord{
+		Zone:    "example.com",
+		Type:    "mx",
+		Name:    "example.com",
+		Content: "mail.example.com",
+		TTL:     3600,
+		Notify:  "echo $CF_PIN_DRIFT >> " + drift,
+	}
+	if err := p.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcile := func() {
+		t.Helper()
+		if err := reconcilePin(activeAPI, activeZoneIdentifier, &p); err != nil {
+			t.Fatal(err)
+		}
+		checkRecords(t, b, "MX example.com mail.example.com")
+		if ttl := b.Records()[0].TTL; ttl != 3600 {
+			t.Errorf("TTL = %d, want 3600", ttl)
+		}
+	}
+	change := func(params cloudflare.UpdateDNSRecordParams) {
+		t.Helper()
+		params.ID = b.Records()[0].ID
+		if _, err := b.UpdateDNSRecord(context.Background(), zoneID, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reconcile()
+	change(cloudflare.UpdateDNSRecordParams{Content: "rogue.example.net"})
+	reconcile()
+	change(cloudflare.UpdateDNSRecordParams{TTL: 300})
+	reconcile()
+	reconcile()
+
+	data, err := os.ReadFile(drift)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "record is missing\ncontent is rogue.example.net\nTTL is 300\n"
+	if string(data) != want {
+		t.Errorf("notifications = %q, want %q", data, want)
+	}
+}
+
+func TestMissingRecords(t *testing.T) {
+	p1, p2 := uint16(13), uint16(86)
+	required := []cloudflare.DNSRecord{
+		{Type: "MX", Name: "example.com", Content: "route1.mx.cloudflare.net", Priority: &p1},
+		{Type: "MX", Name: "example.com", Content: "route2.mx.cloudflare.net", Priority: &p2},
+		{Type: "TXT", Name: "example.com", Content: `"v=spf1 include:_spf.mx.cloudflare.net ~all"`},
+	}
+	existing := []cloudflare.DNSRecord{
+		{Type: "MX", Name: "example.com", Content: "route1.mx.cloudflare.net.", Priority: &p1},
+		{Type: "MX", Name: "example.com", Content: "route2.mx.cloudflare.net", Priority: &p1},
+		{Type: "TXT", Name: "example.com", Content: "v=spf1 include:_spf.mx.cloudflare.net ~all"},
+	}
+	missing := missingRecords(required, existing)
+	if len(missing) != 1 || missing[0].Content != "route2.mx.cloudflare.net" {
+		t.Errorf("missing = %v, want the second MX record", summarize(missing))
+	}
+}
+
+func TestAccessRule(t *testing.T) {
+	cases := []struct {
+		action, kind, value string
+		mode, target, want  string
+	}{
+		{"block", "ip", "203.0.113.7", "block", "ip", "203.0.113.7"},
+		{"block", "ip", "2001:db8::7", "block", "ip6", "2001:db8::7"},
+		{"allow", "ip", "198.51.100.9/24", "whitelist", "ip_range", "198.51.100.0/24"},
+		{"challenge", "country", "cn", "challenge", "country", "CN"},
+		{"managed-challenge", "asn", "as64496", "managed_challenge", "asn", "AS64496"},
+	}
+	for _, c := range cases {
+		r, err := accessRule(c.action, c.kind, c.value)
+		if err != nil {
+			t.Errorf("accessRule(%s %s %s): %v", c.action, c.kind, c.value, err)
+			continue
+		}
+		if r.Mode != c.mode || r.Configuration.Target != c.target || r.Configuration.Value != c.want {
+			t.Errorf("accessRule(%s %s %s) = %s %s %s", c.action, c.kind, c.value,
+				r.Mode, r.Configuration.Target, r.Configuration.Value)
+		}
+	}
+
+	for _, bad := range [][2]string{{"ip", "203.0.113"}, {"country", "China"}, {"asn", "ASx"}, {"host", "a"}} {
+		if _, err := accessRule("block", bad[0], bad[1]); err == nil {
+			t.Errorf("accessRule(block %s %s) succeeded", bad[0], bad[1])
+		}
+	}
+}
+
+func TestZoneCompare(t *testing.T) {
+	useMemoryBackend(t)
+	b := cflib.NewMemoryBackend("example.com", "example.org")
+	backend = b
+
+	add := func(zone, recType, name, content string) {
+		zoneID, _ := b.ZoneIDByName(zone)
+		_, err := b.CreateDNSRecord(context.Background(), zoneID, cloudflare.CreateDNSRecordParams{
+			Type: recType, Name: name, Content: content, TTL: 1,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	add("example.com", "A", "example.com", "203.0.113.1")
+	add("example.com", "CNAME", "www.example.com", "example.com")
+	add("example.com", "A", "old.example.com", "203.0.113.2")
+	add("example.org", "A", "example.org", "203.0.113.1")
+	add("example.org", "CNAME", "www.example.org", "example.org")
+	add("example.org", "A", "new.example.org", "203.0.113.3")
+
+	var com, org []cloudflare.DNSRecord
+	for _, r := range b.Records() {
+		if inZone(r.Name, "example.com") {
+			com = append(com, r)
+		} else {
+			org = append(org, r)
+		}
+	}
+	var got []string
+	for _, d := range diffRecords(relativeRecords(com, "example.com"), relativeRecords(org, "example.org")) {
+		line, _ := formatDiff(d)
+		got = append(got, line)
+	}
+	want := []string{"+ A new 203.0.113.3", "- A old 203.0.113.2"}
+	if !slices.Equal(got, want) {
+		t.Errorf("got %q, want %q", got, want)
+	}
+
+	err := processCmd("zone compare example.com example.org")
+	if err == nil || err.Error() != "2 difference(s) found" {
+		t.Errorf("zone compare: %v", err)
+	}
+}
+
+func TestZoneAnalytics(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/graphql" || r.Header.Get("Authorization") != "Bearer token" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, `{"data": {"viewer": {"zones": [{
+			"http": [
+				{"sum": {"requests": 100, "cachedRequests": 40, "bytes": 2048, "cachedBytes": 1024, "threats": 2}},
+				{"sum": {"requests": 50, "cachedRequests": 20, "bytes": 1024, "cachedBytes": 0, "threats": 1}}],
+			"dns": [{"count": 75}],
+			"names": [
+				{"count": 60, "dimensions": {"queryName": "example.com"}},
+				{"count": 15, "dimensions": {"queryName": "www.example.com"}}]
+		}]}}, "errors": null}`)
+	}))
+	defer srv.Close()
+
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	s, err := zoneAnalytics(api, cloudflare.ZoneIdentifier("zone"), now.Add(-time.Hour), now, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Requests != 150 || s.CachedRequests != 60 || s.Bytes != 3072 || s.Threats != 3 || s.DNSQueries != 75 {
+		t.Errorf("unexpected summary %+v", s)
+	}
+	if len(s.TopQueryNames) != 1 || s.TopQueryNames[0] != (queryNameSum{"example.com", 60}) {
+		t.Errorf("unexpected top query names %v", s.TopQueryNames)
+	}
+	if got := formatBytes(s.Bytes); got != "3.0 KiB" {
+		t.Errorf("formatBytes(%d) = %s", s.Bytes, got)
+	}
+	if d, err := parseAge("7d"); err != nil || d != 7*24*time.Hour {
+		t.Errorf("parseAge(7d) = %v, %v", d, err)
+	}
+}
+
+func TestCompareAnalytics(t *testing.T) {
+	previous := analyticsSummary{Requests: 1000, CachedRequests: 800, Bytes: 4096, Threats: 10}
+	current := analyticsSummary{Zone: "example.com", Requests: 1100, CachedRequests: 660, Bytes: 4096, Threats: 40}
+	cmp := compareAnalytics(previous, current, 50)
+
+	want := []struct {
+		measure   string
+		change    float64
+		anomalous bool
+	}{
+		{"Requests", 10, false},
+		{"Bandwidth", 0, false},
+		{"Threats", 300, true},
+		{"Cache ratio", -20, true},
+	}
+	if cmp.Zone != "example.com" || len(cmp.Deltas) != len(want) {
+		t.Fatalf("unexpected comparison %+v", cmp)
+	}
+	for i, w := range want {
+		d := cmp.Deltas[i]
+		if d.Measure != w.measure || math.Abs(d.Change-w.change) > 0.001 || d.Anomalous != w.anomalous {
+			t.Errorf("delta %d = %+v, want %+v", i, d, w)
+		}
+	}
+	if got := formatAge(7 * 24 * time.Hour); got != "7d" {
+		t.Errorf("formatAge(7d) = %s", got)
+	}
+
+	// Traffic appearing where there was none is an anomaly, but the cache
+	// ratio of a zone without requests is not compared.
+	cmp = compareAnalytics(analyticsSummary{}, current, 50)
+	if d := cmp.Deltas[0]; d.Change != 100 || !d.Anomalous {
+		t.Errorf("requests delta from zero = %+v", d)
+	}
+	if d := cmp.Deltas[3]; d.Anomalous {
+		t.Errorf("cache ratio delta from zero = %+v", d)
+	}
+}
+
+func TestOriginCert(t *testing.T) {
+	useMemoryBackend(t)
+	var revoked string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/certificates":
+			var req cloudflare.CreateOriginCertificateParams
+			json.NewDecoder(r.Body).Decode(&req)
+			block, _ := pem.Decode([]byte(req.CSR))
+			if block == nil {
+				http.Error(w, "no CSR", http.StatusBadRequest)
+				return
+			}
+			csr, err := x509.ParseCertificateRequest(block.Bytes)
+			if err != nil || !slices.Equal(csr.DNSNames, req.Hostnames) ||
+				req.RequestType != "origin-ecc" || req.RequestValidity != 90 {
+				http.Error(w, "bad request", http.StatusBadRequest)
+				return
+			}
+			fmt.Fprint(w, `{"success": true, "result": {"id": "42", "certificate": "CERT",
+				"hostnames": ["www.example.com", "*.example.com"], "expires_on": "2025-01-01 00:00:00 +0000 UTC"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == "/certificates/42":
+			fmt.Fprint(w, `{"success": true, "result": {"id": "42", "hostnames": ["www.example.com"],
+				"expires_on": "2025-01-01 00:00:00 +0000 UTC"}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == "/certificates/42":
+			revoked = "42"
+			fmt.Fprint(w, `{"success": true, "result": {"id": "42"}}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	prefix := filepath.Join(t.TempDir(), "www")
+	if err := processCmd("cert create --validity 90 --type ecc --out " + prefix + " www.example.com *.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := os.ReadFile(prefix + ".pem"); err != nil || string(data) != "CERT" {
+		t.Errorf("certificate file holds %q, %v", data, err)
+	}
+	data, err := os.ReadFile(prefix + ".key")
+	if block, _ := pem.Decode(data); err != nil || block == nil || block.Type != "PRIVATE KEY" {
+		t.Errorf("key file holds %q, %v", data, err)
+	}
+
+	// Existing files are kept, and hostnames must be in the active zone.
+	if err := processCmd("cert create --validity 90 --type ecc --out " + prefix + " www.example.com"); err == nil {
+		t.Error("cert create overwrote existing files")
+	}
+	if err := processCmd("cert create www.example.org"); err == nil {
+		t.Error("cert create accepted a hostname outside the zone")
+	}
+	if err := processCmd("cert create --validity 60 www.example.com"); err == nil {
+		t.Error("cert create accepted a validity of 60 days")
+	}
+
+	if err := processCmd("cert revoke --force 42"); err != nil || revoked != "42" {
+		t.Errorf("cert revoke: %v, revoked %q", err, revoked)
+	}
+}
+
+// selfSignedPEM returns a PEM-encoded self-signed certificate for a host,
+// expiring at a time, and its private key.
+func selfSignedPEM(t *testing.T, host string, notAfter time.Time) (certPEM, keyPEM []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: host},
+		DNSNames:     []string{host},
+		NotBefore:    notAfter.Add(-48 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+}
+
+func TestWorkerRoutes(t *testing.T) {
+	useMemoryBackend(t)
+	routes := map[string]cloudflare.WorkerRoute{}
+	prefix := "/zones/" + activeZoneIdentifier.Identifier + "/workers/routes"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == prefix:
+			var list []cloudflare.WorkerRoute
+			for _, route := range routes {
+				list = append(list, route)
+			}
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "result": list})
+		case r.Method == http.MethodPost && r.URL.Path == prefix:
+			var route cloudflare.WorkerRoute
+			json.NewDecoder(r.Body).Decode(&route)
+			route.ID = fmt.Sprintf("r%d", len(routes)+1)
+			routes[route.ID] = route
+			json.NewEncoder(w).Encode(map[string]any{"success": true, "result": route})
+		case r.Method == http.MethodDelete && strings.HasPrefix(r.URL.Path, prefix+"/"):
+			delete(routes, strings.TrimPrefix(r.URL.Path, prefix+"/"))
+			fmt.Fprint(w, `{"success": true, "result": {}}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	if err := processCmd("worker routes add api.example.org/* api"); err == nil {
+		t.Error("worker routes add accepted a pattern outside the zone")
+	}
+	for _, c := range []string{
+		"workers routes add api.example.com/* api",
+		"worker routes add *.example.com/static/* assets",
+		"worker routes list",
+		"worker routes delete --force api.example.com/*",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+	if len(routes) != 1 || routes["r2"].Pattern != "*.example.com/static/*" || routes["r2"].ScriptName != "assets" {
+		t.Errorf("routes = %v", routes)
+	}
+	if err := processCmd("worker routes delete --force api.example.com/*"); err == nil {
+		t.Error("worker routes delete succeeded for a missing route")
+	}
+}
+
+func TestPropagationMatrix(t *testing.T) {
+	useMemoryBackend(t)
+	for _, line := range []string{"ip4 www.example.com 10.0.0.1", "ip4 api.example.com 10.0.0.2"} {
+		if err := processCmd(line); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The stale resolver still serves the old address of www.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if r.Header.Get("Accept") != "application/dns-json" || q.Get("type") != "A" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		addr := map[string]string{"www.example.com": "10.0.0.1", "api.example.com": "10.0.0.2"}[q.Get("name")]
+		if q.Get("edns_client_subnet") == "192.0.2.0/24" && q.Get("name") == "www.example.com" {
+			addr = "10.0.0.9"
+		}
+		fmt.Fprintf(w, `{"Status": 0, "Answer": [{"name": %q, "type": 1, "TTL": 300, "data": %q}]}`,
+			q.Get("name")+".", addr)
+	}))
+	defer srv.Close()
+	saved := vantagePoints
+	defer func() { vantagePoints = saved }()
+	vantagePoints = []vantagePoint{{"Fresh", srv.URL, ""}, {"Stale", srv.URL, "192.0.2.0/24"}}
+
+	cells := propagationMatrix("A", []string{"www.example.com", "api.example.com"},
+		map[string][]cloudflare.DNSRecord{
+			"www.example.com": {{Type: "A", Name: "www.example.com", Content: "10.0.0.1"}},
+			"api.example.com": {{Type: "A", Name: "api.example.com", Content: "10.0.0.2"}},
+		})
+	var got []string
+	for _, c := range cells {
+		got = append(got, fmt.Sprintf("%s %s %v %v", c.Vantage, c.Name, c.Values, c.Match))
+	}
+	want := []string{
+		"Fresh www.example.com [10.0.0.1] true",
+		"Fresh api.example.com [10.0.0.2] true",
+		"Stale www.example.com [10.0.0.9] false",
+		"Stale api.example.com [10.0.0.2] true",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("matrix = %q, want %q", got, want)
+	}
+
+	if err := processCmd("verify --global A api.example.com"); err != nil {
+		t.Errorf("verify --global: %v", err)
+	}
+	if err := processCmd("verify --global A www.example.com api.example.com"); err == nil {
+		t.Error("verify --global succeeded with a stale resolver")
+	}
+	if got := unquoteTXT(`"v=spf1 " "-all"`); got != "v=spf1 -all" {
+		t.Errorf("unquoteTXT = %q", got)
+	}
+}
+
+func TestCheckPublicAnswer(t *testing.T) {
+	proxied, direct := true, false
+	a := func(content string, p *bool) cloudflare.DNSRecord {
+		return cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: content, TTL: 300, Proxied: p}
+	}
+	noAnswer := errors.New("no such host")
+	tests := []struct {
+		recs   []cloudflare.DNSRecord
+		values []string
+		err    error
+		match  bool
+		note   string
+	}{
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &direct)}, []string{"203.0.113.7"}, nil, true, "matches"},
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &direct)}, []string{"203.0.113.6"}, nil, false, "stale"},
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &direct)}, []string{"104.21.3.4"}, nil, false, "unproxied"},
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &direct)}, []string{"203.0.113.7", "203.0.113.8"}, nil, false, "also serves"},
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &proxied)}, []string{"104.21.3.4", "172.67.1.2"}, nil, true, "proxied"},
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &proxied)}, []string{"203.0.113.7"}, nil, false, "not Cloudflare's"},
+		{[]cloudflare.DNSRecord{a("203.0.113.7", &direct)}, nil, noAnswer, false, "not served yet"},
+		{nil, []string{"198.51.100.1"}, nil, false, "another provider"},
+		{nil, nil, noAnswer, true, "not served"},
+	}
+	for i, tt := range tests {
+		match, note := checkPublicAnswer("A", tt.recs, tt.values, tt.err)
+		if match != tt.match || !strings.Contains(note, tt.note) {
+			t.Errorf("case %d: got %t %q, want %t and a note containing %q", i, match, note, tt.match, tt.note)
+		}
+	}
+	if !isCloudflareAddress("2606:4700::6810:84e5") || isCloudflareAddress("2001:db8::1") {
+		t.Error("isCloudflareAddress misclassified an IPv6 address")
+	}
+}
+
+func TestWorkerUsage(t *testing.T) {
+	useMemoryBackend(t)
+	subdomain := "old"
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/zones/"+activeZoneIdentifier.Identifier:
+			fmt.Fprint(w, `{"success": true, "result": {"id": "z", "account": {"id": "acct"}}}`)
+		case r.URL.Path == "/accounts/acct/workers/scripts":
+			fmt.Fprint(w, `{"success": true, "result": [
+				{"id": "api", "modified_on": "2026-09-30T10:00:00Z"},
+				{"id": "stale", "modified_on": "2025-03-02T10:00:00Z"}]}`)
+		case r.URL.Path == "/accounts/acct/workers/subdomain" && r.Method == http.MethodPut:
+			var body cloudflare.WorkersSubdomain
+			json.NewDecoder(r.Body).Decode(&body)
+			subdomain = body.Name
+			fmt.Fprintf(w, `{"success": true, "result": {"name": %q}}`, subdomain)
+		case r.URL.Path == "/accounts/acct/workers/subdomain":
+			fmt.Fprintf(w, `{"success": true, "result": {"name": %q}}`, subdomain)
+		case r.URL.Path == "/graphql":
+			fmt.Fprint(w, `{"data": {"viewer": {"accounts": [{"workersInvocationsAdaptive": [
+				{"sum": {"requests": 1200, "errors": 3}, "quantiles": {"cpuTimeP50": 850, "cpuTimeP99": 4200},
+				 "dimensions": {"scriptName": "api"}}]}]}}, "errors": null}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	usage, err := accountWorkerUsage(api, "acct", time.Now().Add(-time.Hour), time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u := usage["api"]; u.Requests != 1200 || u.Errors != 3 || u.CPUP99 != 4200 {
+		t.Errorf("api usage = %+v", u)
+	}
+	if got := formatCPUTime(4200); got != "4.2ms" {
+		t.Errorf("formatCPUTime(4200) = %s", got)
+	}
+
+	for _, c := range []string{"worker usage", "worker usage --since 30d --unused", "worker subdomain",
+		"worker subdomain --force new.workers.dev"} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+	if subdomain != "new" {
+		t.Errorf("subdomain = %s, want new", subdomain)
+	}
+	if err := processCmd("worker subdomain a.b"); err == nil {
+		t.Error("worker subdomain accepted a dotted name")
+	}
+}
+
+func TestZoneOnboard(t *testing.T) {
+	useMemoryBackend(t)
+	backend = nil
+	var created, checked bool
+	var settings []string
+	polls := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/zones" && r.Method == http.MethodPost:
+			created = true
+			fmt.Fprint(w, `{"success": true, "result": {"id": "onb", "name": "example.org", "status": "pending",
+				"name_servers": ["ada.ns.cloudflare.com", "bob.ns.cloudflare.com"]}}`)
+		case r.URL.Path == "/zones":
+			fmt.Fprint(w, `{"success": true, "result": [], "result_info": {"page": 1, "total_pages": 1}}`)
+		case r.URL.Path == "/accounts":
+			fmt.Fprint(w, `{"success": true, "result": [{"id": "acct"}], "result_info": {"page": 1, "total_pages": 1}}`)
+		case r.URL.Path == "/zones/onb/dns_records":
+			fmt.Fprint(w, `{"success": true, "result": [{"id": "r1", "type": "A", "name": "example.org", "content": "192.0.2.1"}],
+				"result_info": {"page": 1, "total_pages": 1}}`)
+		case strings.HasPrefix(r.URL.Path, "/zones/onb/settings/") && r.Method == http.MethodPatch:
+			settings = append(settings, strings.TrimPrefix(r.URL.Path, "/zones/onb/settings/"))
+			fmt.Fprint(w, `{"success": true, "result": {}}`)
+		case r.URL.Path == "/zones/onb/activation_check" && r.Method == http.MethodPut:
+			checked = true
+			fmt.Fprint(w, `{"success": true, "result": {"id": "onb"}}`)
+		case r.URL.Path == "/zones/onb":
+			status := "pending"
+			if polls++; polls > 2 {
+				status = "active"
+			}
+			fmt.Fprintf(w, `{"success": true, "result": {"id": "onb", "name": "example.org", "status": %q}}`, status)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	if err := processCmd("zone onboard --interval 1ms example.org"); err != nil {
+		t.Fatal(err)
+	}
+	if !created || !checked || polls != 3 {
+		t.Errorf("created = %v, checked = %v, polls = %d", created, checked, polls)
+	}
+	if want := []string{"always_use_https", "min_tls_version"}; !slices.Equal(settings, want) {
+		t.Errorf("settings = %v, want %v", settings, want)
+	}
+	if activeZoneName != "example.org" || activeZoneIdentifier.Identifier != "onb" {
+		t.Errorf("active zone = %s (%s)", activeZoneName, activeZoneIdentifier.Identifier)
+	}
+
+	if err := processCmd("zone onboard --interval soon example.org"); err == nil {
+		t.Error("zone onboard accepted an invalid interval")
+	}
+}
+
+func TestCustomHostname(t *testing.T) {
+	useMemoryBackend(t)
+	var created cloudflare.CustomHostname
+	var deleted string
+	prefix := "/zones/" + activeZoneIdentifier.Identifier + "/custom_hostnames"
+	result := `{"id": "h1", "hostname": "shop.customer.net", "status": "pending",
+		"ownership_verification": {"type": "txt", "name": "_cf-custom-hostname.shop.customer.net", "value": "v1"},
+		"ssl": {"status": "pending_validation", "validation_records": [
+			{"txt_name": "_acme-challenge.shop.customer.net", "txt_value": "v2"}]}}`
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == prefix:
+			json.NewDecoder(r.Body).Decode(&created)
+			fmt.Fprintf(w, `{"success": true, "result": %s}`, result)
+		case r.Method == http.MethodGet && r.URL.Path == prefix:
+			fmt.Fprintf(w, `{"success": true, "result": [%s], "result_info": {"page": 1, "total_pages": 1}}`, result)
+		case r.Method == http.MethodDelete && r.URL.Path == prefix+"/h1":
+			deleted = "h1"
+			fmt.Fprint(w, `{"success": true, "result": {"id": "h1"}}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	if err := processCmd("hostname add shop.example.com"); err == nil {
+		t.Error("hostname add accepted a hostname in the zone")
+	}
+	if err := processCmd("hostname add --validation dns shop.customer.net"); err == nil {
+		t.Error("hostname add accepted an invalid validation method")
+	}
+	if err := processCmd("hostname add --origin app.example.com --validation http shop.customer.net"); err != nil {
+		t.Fatal(err)
+	}
+	if created.Hostname != "shop.customer.net" || created.CustomOriginServer != "app.example.com" ||
+		created.SSL == nil || created.SSL.Method != "http" {
+		t.Errorf("created %+v", created)
+	}
+
+	for _, c := range []string{"hostname list", "hostname show shop.customer.net", "hostname delete --force shop.customer.net"} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+	if deleted != "h1" {
+		t.Error("hostname delete did not delete the hostname")
+	}
+	if err := processCmd("hostname show other.customer.net"); err == nil {
+		t.Error("hostname show succeeded for a missing hostname")
+	}
+}
+
+func TestCustomSSL(t *testing.T) {
+	useMemoryBackend(t)
+	plan := "business"
+	var uploaded cloudflare.ZoneCustomSSLOptions
+	var deleted string
+	zoneID := activeZoneIdentifier.Identifier
+	prefix := "/zones/" + zoneID
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == prefix:
+			fmt.Fprintf(w, `{"success": true, "result": {"id": %q, "name": "example.com",
+				"plan": {"legacy_id": %q, "name": "Plan"}}}`, zoneID, plan)
+		case r.Method == http.MethodPost && r.URL.Path == prefix+"/custom_certificates":
+			json.NewDecoder(r.Body).Decode(&uploaded)
+			fmt.Fprint(w, `{"success": true, "result": {"id": "c1"}}`)
+		case r.Method == http.MethodGet && r.URL.Path == prefix+"/custom_certificates/c1":
+			fmt.Fprint(w, `{"success": true, "result": {"id": "c1", "hosts": ["www.example.com"]}}`)
+		case r.Method == http.MethodDelete && r.URL.Path == prefix+"/custom_certificates/c1":
+			deleted = "c1"
+			fmt.Fprint(w, `{"success": true, "result": {"id": "c1"}}`)
+		default:
+			http.Error(w, "not found", http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	dir := t.TempDir()
+	write := func(name string, data []byte) string {
+		p := filepath.Join(dir, name)
+		if err := os.WriteFile(p, data, 0o600); err != nil {
+			t.Fatal(err)
+		}
+		return p
+	}
+	certPEM, keyPEM := selfSignedPEM(t, "www.example.com", time.Now().Add(24*time.Hour))
+	oldPEM, _ := selfSignedPEM(t, "www.example.com", time.Now().Add(-time.Hour))
+	certFile, keyFile, oldFile := write("cert.pem", certPEM), write("key.pem", keyPEM), write("old.pem", oldPEM)
+
+	if _, err := checkKeyPair(oldPEM, keyPEM); err == nil {
+		t.Error("checkKeyPair accepted a mismatched key")
+	}
+	if err := processCmd("ssl upload-cert " + oldFile + " " + keyFile); err == nil {
+		t.Error("ssl upload-cert accepted a mismatched key")
+	}
+	if err := processCmd("ssl upload-cert --bundle-method optimal " + certFile + " " + keyFile); err != nil {
+		t.Fatal(err)
+	}
+	if uploaded.BundleMethod != "optimal" || uploaded.Certificate != string(certPEM) {
+		t.Errorf("uploaded %+v", uploaded)
+	}
+
+	plan = "pro"
+	var capErr *capabilityError
+	if err := processCmd("ssl upload-cert " + certFile + " " + keyFile); !errors.As(err, &capErr) {
+		t.Errorf("upload on a Pro plan returned %v", err)
+	}
+
+	if err := processCmd("ssl delete-custom --force c1"); err != nil || deleted != "c1" {
+		t.Errorf("ssl delete-custom: %v, deleted %q", err, deleted)
+	}
+}
+
+func TestWriteInventory(t *testing.T) {
+	inv := inventory{Zones: []inventoryZone{
+		{Name: "example.com", Plan: "Free Website", Status: "active", DNSSEC: "active", Records: []inventoryRecord{
+			{Type: "A", Name: "www.example.com", Content: "203.0.113.7", TTL: 1, Proxied: true},
+			{Type: "TXT", Name: "example.com", Content: `"<v=spf1 -all>"`, TTL: 300, Tags: []string{"a:b"}},
+		}},
+		{Name: "example.org", Plan: "Pro Website", Status: "pending", DNSSEC: "disabled"},
+	}}
+
+	var b strings.Builder
+	if err := writeInventory(&b, inv, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	want := `zone,plan,status,dnssec,type,name,content,ttl,proxied,comment,tags
+example.com,Free Website,active,active,A,www.example.com,203.0.113.7,1,true,,
+example.com,Free Website,active,active,TXT,example.com,"""<v=spf1 -all>""",300,false,,a:b
+example.org,Pro Website,pending,disabled,,,,,,,
+`
+	if b.String() != want {
+		t.Errorf("got CSV:\n%s\nwant:\n%s", b.String(), want)
+	}
+
+	b.Reset()
+	if err := writeInventory(&b, inv, "html"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(b.String(), "&lt;v=spf1 -all&gt;") || !strings.Contains(b.String(), "<h2>example.org</h2>") {
+		t.Errorf("unexpected HTML:\n%s", b.String())
+	}
+}
+
+func TestPlugins(t *testing.T) {
+	useMemoryBackend(t)
+	if runtime.GOOS == "windows" {
+		t.Skip("external commands are shell scripts")
+	}
+	dir := t.TempDir()
+	out := filepath.Join(dir, "out")
+	script := "#!/bin/sh\necho \"$CLOUDFLARE_ZONE $CF_OUTPUT $*\" > " + out + "\nexit 3\n"
+	if err := os.WriteFile(filepath.Join(dir, "cf-hello"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	// Executables named like cf's own commands are not offered.
+	if err := os.WriteFile(filepath.Join(dir, "cf-list"), []byte(script), 0755); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("PATH", dir)
+
+	var ran []string
+	cflib.RegisterCommand(cflib.Command{
+		Name:  "acme",
+		Usage: "acme <arg>",
+		Run: func(s cflib.Session, args []string) error {
+			name, _, err := s.Zone()
+			ran = append(append(ran, name), args...)
+			return err
+		},
+	})
+
+	saved := cmds
+	defer func() { cmds = saved }()
+	root := cmd.NewTree(cmd.TreeDescriptor{Name: "Test"})
+	root.AddCommand(cmd.CommandDescriptor{Name: "list", Usage: "list", Data: cmdListDomains})
+	addPlugins(root)
+	cmds = root
+
+	if names := len(root.Commands()); names != 3 {
+		t.Errorf("%d commands, want list, acme and hello", names)
+	}
+	if err := processCmd("acme one"); err != nil || !slices.Equal(ran, []string{"example.com", "one"}) {
+		t.Errorf("acme ran with %v, %v", ran, err)
+	}
+	err := processCmd("hello a b")
+	if exitCode(err) != 3 {
+		t.Errorf("hello returned %v, want exit status 3", err)
+	}
+	if data, err := os.ReadFile(out); err != nil || string(data) != "example.com text a b\n" {
+		t.Errorf("hello wrote %q, %v", data, err)
+	}
+}
+
+func TestErrorHint(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/zones/z1/dns_records":
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprint(w, `{"success": false, "errors": [{"code": 81057, "message": "Record already exists."}]}`)
+		case "/zones/z1":
+			w.WriteHeader(http.StatusForbidden)
+			fmt.Fprint(w, `{"success": false, "errors": [{"code": 9109, "message": "Invalid access token"}]}`)
+		default:
+			w.WriteHeader(http.StatusTeapot)
+			fmt.Fprint(w, `{"success": false, "errors": [{"code": 1234, "message": "Something else"}]}`)
+		}
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL), cloudflare.UsingRetryPolicy(0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = api.CreateDNSRecord(context.Background(), cloudflare.ZoneIdentifier("z1"),
+		cloudflare.CreateDNSRecordParams{Type: "A", Name: "www", Content: "192.0.2.1"})
+	if hint := errorHint(err); !strings.Contains(hint, "upsert") {
+		t.Errorf("existing record hint = %q", hint)
+	}
+	_, err = api.ZoneDetails(context.Background(), "z1")
+	if hint := errorHint(fmt.Errorf("zone: %w", err)); !strings.Contains(hint, "account verify") {
+		t.Errorf("invalid token hint = %q", hint)
+	}
+	_, err = api.ZoneDetails(context.Background(), "z2")
+	if hint := errorHint(err); hint != "" {
+		t.Errorf("unknown error hint = %q", hint)
+	}
+	if hint := errorHint(&cloudflare.Error{StatusCode: http.StatusTooManyRequests}); hint != rateLimitHint {
+		t.Errorf("rate limit hint = %q", hint)
+	}
+	if hint := errorHint(errNoMatch); hint != "" {
+		t.Errorf("errNoMatch hint = %q", hint)
+	}
+}
+
+func TestHelpJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := writeHelpJSON(&buf, nil); err != nil {
+		t.Fatal(err)
+	}
+	var help struct {
+		Flags    []helpFlag    `json:"leading_flags"`
+		Commands []helpCommand `json:"commands"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &help); err != nil {
+		t.Fatal(err)
+	}
+	if len(help.Commands) != len(cmds.Commands()) {
+		t.Errorf("%d commands described, want %d", len(help.Commands), len(cmds.Commands()))
+	}
+	if !slices.Contains(help.Flags, helpFlag{Name: "zone", Value: true}) {
+		t.Errorf("leading flags %v lack --zone", help.Flags)
+	}
+
+	i := slices.IndexFunc(help.Commands, func(c helpCommand) bool { return c.Name == "ip4" })
+	if i < 0 {
+		t.Fatal("ip4 command missing")
+	}
+	ip4 := help.Commands[i]
+	if !slices.Contains(ip4.Shortcuts, "ip") || ip4.Usage == "" || ip4.Description == "" {
+		t.Errorf("unexpected ip4 help %+v", ip4)
+	}
+	if !slices.Contains(ip4.Flags, helpFlag{Name: "comment", Value: true}) ||
+		!slices.Contains(ip4.Flags, helpFlag{Name: "dns-only", Value: false}) {
+		t.Errorf("ip4 flags = %v", ip4.Flags)
+	}
+
+	buf.Reset()
+	if err := writeHelpJSON(&buf, []string{"zone"}); err != nil {
+		t.Fatal(err)
+	}
+	help.Commands = nil
+	if err := json.Unmarshal(buf.Bytes(), &help); err != nil {
+		t.Fatal(err)
+	}
+	if len(help.Commands) != 1 || !slices.Contains(help.Commands[0].Subcommands, "clear") {
+		t.Errorf("zone help = %+v", help.Commands)
+	}
+}
+
+func TestCompletionCommands(t *testing.T) {
+	var zone, add, list *completionCommand
+	commands := completionCommands()
+	for i := range commands {
+		switch commands[i].name {
+		case "zone":
+			zone = &commands[i]
+		case "add":
+			add = &commands[i]
+		case "list":
+			list = &commands[i]
+		}
+	}
+	if zone == nil || add == nil || list == nil {
+		t.Fatal("zone, add or list command missing")
+	}
+	if !slices.Equal(zone.subs, []string{"create", "delete", "offboard", "compare", "clear", "onboard", "pull", "unlock", "set-id"}) {
+		t.Errorf("zone subcommands = %v", zone.subs)
+	}
+	if !slices.Contains(zone.valueFlags, "export") || slices.Contains(zone.valueFlags, "force") {
+		t.Errorf("zone value flags = %v", zone.valueFlags)
+	}
+	if !slices.Contains(list.valueFlags, "sort") || !slices.Contains(list.valueFlags, "group-by") {
+		t.Errorf("list value flags = %v", list.valueFlags)
+	}
+	if !add.typed {
+		t.Error("add does not complete record types")
+	}
+
+	var b strings.Builder
+	writeBashCompletion(&b, commands)
+	if !strings.Contains(b.String(), `zone) flags="--account --jumpstart --force --export --axfr --no-settings --no-wait --interval --wait-timeout --zone --all-zones"`) {
+		t.Errorf("unexpected bash completion:\n%s", b.String())
+	}
+}
+
+func TestUpsertStdin(t *testing.T) {
+	b := useMemoryBackend(t)
+	if err := processCmd("add A www.example.com 203.0.113.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	saved := stdin
+	defer func() { stdin = saved }()
+	stdin = bufio.NewReader(strings.NewReader(`# records
+A www.example.com 203.0.113.7 300 proxied
+TXT example.com "v=spf1 -all"
+
+{"type": "aaaa", "name": "www.example.com", "content": "2001:db8::7", "proxied": false}
+`))
+	if err := processCmd("upsert -"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"TXT example.com v=spf1 -all",
+		"A www.example.com 203.0.113.7",
+		"AAAA www.example.com 2001:db8::7")
+	for _, r := range b.Records() {
+		if r.Type == "A" && (r.TTL != 300 || !isProxied(r)) {
+			t.Errorf("A record has TTL %d, proxied %v", r.TTL, isProxied(r))
+		}
+	}
+
+	// A bad line anywhere leaves the zone unchanged.
+	stdin = bufio.NewReader(strings.NewReader("A api.example.com 203.0.113.8\nA bad.example.com 203.0.113.9 5\n"))
+	if err := processCmd("upsert -"); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("upsert of bad line: %v", err)
+	}
+	if len(b.Records()) != 3 {
+		t.Errorf("zone changed by failed upsert: %v", summarize(b.Records()))
+	}
+}
+
+func TestTutorial(t *testing.T) {
+	b := useMemoryBackend(t)
+	if err := processCmd("tutorial"); err == nil {
+		t.Error("tutorial ran in non-interactive mode")
+	}
+
+	interactive = true
+	defer func() { interactive = false }()
+	saved := stdin
+	defer func() { stdin = saved }()
+
+	// Skip the account and zone steps, keeping the active zone, create the
+	// test record, skip showing it and quit before deleting it.
+	stdin = bufio.NewReader(strings.NewReader("s\ns\n\ns\ns\n\nx\ns\nq\n"))
+	if err := processCmd("tutorial"); err != nil {
+		t.Fatal(err)
+	}
+	if recs := b.Records(); len(recs) != 0 {
+		t.Errorf("test record left behind: %v", summarize(recs))
+	}
+}
+
+func TestReplaceIP(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	for _, c := range []string{
+		"ip4 www.example.com 10.0.0.1",
+		"ip4 api.example.com 10.0.0.1",
+		"ip4 mail.example.com 10.0.0.3",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := processCmd("replace-ip 10.0.0.1 2001:db8::1"); err == nil {
+		t.Error("replace-ip succeeded with addresses of different families")
+	}
+	if err := processCmd("replace-ip 10.0.0.9 10.0.0.2"); err != errNoMatch {
+		t.Errorf("replace-ip without matches returned %v, want errNoMatch", err)
+	}
+
+	if err := processCmd("replace-ip --force 10.0.0.1 10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 10.0.0.2", "A mail.example.com 10.0.0.3",
+		"A www.example.com 10.0.0.2")
+}
+
+func TestShadowWrites(t *testing.T) {
+	useMemoryBackend(t)
+	b := cflib.NewMemoryBackend("example.com", "staging.example.net")
+	zoneID, err := b.ZoneIDByName("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	backend = b
+	activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
+	t.Cleanup(func() { shadowZone, shadowOnly = "", false })
+
+	if err := processCmd("set shadow-only on"); err == nil {
+		t.Error("set shadow-only on succeeded without a staging zone")
+	}
+	for _, c := range []string{
+		"set shadow staging.example.net",
+		"ip4 www.example.com 10.0.0.1",
+		"ip4 www.example.com 10.0.0.2",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.2", "A www.staging.example.net 10.0.0.2")
+
+	for _, c := range []string{
+		"set shadow-only on",
+		"ip4 www.example.com 10.0.0.3",
+		"ip4 api.example.com 10.0.0.4",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	checkRecords(t, b, "A api.staging.example.net 10.0.0.4", "A www.example.com 10.0.0.2",
+		"A www.staging.example.net 10.0.0.3")
+
+	if err := processCmd("delete --force A www.example.com"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.staging.example.net 10.0.0.4", "A www.example.com 10.0.0.2")
+}
+
+func TestPointsAt(t *testing.T) {
+	recs := []cloudflare.DNSRecord{
+		{Type: "A", Name: "www.example.com", Content: "203.0.113.10"},
+		{Type: "A", Name: "api.example.com", Content: "203.0.113.11"},
+		{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::a"},
+		{Type: "CNAME", Name: "shop.example.com", Content: "www.example.com"},
+		{Type: "TXT", Name: "example.com", Content: "203.0.113.10"},
+	}
+	tests := []struct {
+		target string
+		want   []string
+	}{
+		{"203.0.113.10", []string{"A www.example.com"}},
+		{"203.0.113.0/24", []string{"A www.example.com", "A api.example.com"}},
+		{"2001:db8:0::A", []string{"AAAA www.example.com"}},
+		{"WWW.example.com.", []string{"CNAME shop.example.com"}},
+		{"198.51.100.1", nil},
+	}
+	for _, test := range tests {
+		points, err := pointsAt(test.target)
+		if err != nil {
+			t.Fatalf("%s: %v", test.target, err)
+		}
+		var got []string
+		for _, r := range recs {
+			if points(r) {
+				got = append(got, r.Type+" "+r.Name)
+			}
+		}
+		if !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.target, got, test.want)
+		}
+	}
+	if _, err := pointsAt("not a host"); err == nil {
+		t.Error("pointsAt accepted an invalid target")
+	}
+}
+
+func TestVerifyToken(t *testing.T) {
+	b := useMemoryBackend(t)
+	for _, c := range []string{
+		"txt example.com v=spf1",
+		"verify-token google abc123",
+		"verify-token Google google-site-verification=def456",
+		"verify-token bing 0123abcd",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+
+	// The second Google token replaces the first, leaving the SPF record.
+	got := summarize(b.Records())
+	sort.Strings(got)
+	want := []string{
+		"CNAME 0123abcd.example.com verify.bing.com",
+		"TXT example.com google-site-verification=def456",
+		"TXT example.com v=spf1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+
+	if err := processCmd("verify-token myspace abc"); err == nil {
+		t.Error("verify-token accepted an unknown provider")
+	}
+}
+
+func TestSetDefaultTTL(t *testing.T) {
+	b := useMemoryBackend(t)
+	defer func() { defaultTTL = ttlAuto }()
+
+	if err := processCmd("set ttl 600"); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []string{"ip4 www.example.com 10.0.0.1", "add A api.example.com 10.0.0.2"} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+	for _, r := range b.Records() {
+		if r.TTL != 600 {
+			t.Errorf("%s record %s has TTL %d, want 600", r.Type, r.Name, r.TTL)
+		}
+	}
+
+	if err := processCmd("set ttl 5"); err == nil {
+		t.Error("set ttl accepted an invalid TTL")
+	}
+	if err := processCmd("set color sometimes"); err == nil {
+		t.Error("set color accepted an invalid mode")
+	}
+}
+
+func TestSessionResume(t *testing.T) {
+	useMemoryBackend(t)
+	backend = cflib.NewMemoryBackend("example.com", "example.net")
+	defer func() { defaultTTL, colorMode, outputFormat, resumedZone = ttlAuto, "auto", "text", "" }()
+
+	if saved, err := loadSession(); err != nil || saved != (savedSession{}) {
+		t.Fatalf("loadSession without a saved session = %+v, %v", saved, err)
+	}
+	activeZoneName = "example.net"
+	for _, c := range []string{"set ttl 600", "set output json", "set color off"} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+	if err := saveSession(); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultTTL, colorMode, outputFormat = ttlAuto, "auto", "text"
+	activeZoneIdentifier, activeZoneName = nil, ""
+	saved, err := loadSession()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumeSession(saved, flagValues{})
+	if defaultTTL != 600 || colorMode != "off" || outputFormat != "json" || resumedZone != "example.net" {
+		t.Errorf("resumed ttl %d, color %s, output %s, zone %s", defaultTTL, colorMode, outputFormat, resumedZone)
+	}
+	if _, err := getZoneIdentifier(); err != nil || activeZoneName != "example.net" {
+		t.Errorf("active zone %q, %v; want example.net", activeZoneName, err)
+	}
+
+	// The --json flag takes precedence over the saved output format, and
+	// zone clear forgets the resumed zone.
+	outputFormat = "text"
+	resumeSession(savedSession{Output: "text"}, flagValues{"json": ""})
+	if outputFormat != "text" {
+		t.Errorf("output format %s after --json", outputFormat)
+	}
+	if err := processCmd("zone clear"); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveSession(); err != nil {
+		t.Fatal(err)
+	}
+	if saved, err := loadSession(); err != nil || saved.Zone != "" {
+		t.Errorf("saved zone %q after zone clear, %v", saved.Zone, err)
+	}
+}
+
+func TestStateExportImport(t *testing.T) {
+	useMemoryBackend(t)
+	backend = cflib.NewMemoryBackend("example.com", "example.net")
+	defer func() { defaultTTL, dryRun, outputFormat = ttlAuto, false, "text" }()
+
+	activeZoneName = "example.net"
+	for _, c := range []string{"set ttl 600", "set output json", "set dry-run on"} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+	path := filepath.Join(t.TempDir(), "state.json")
+	if err := processCmd("state export " + path); err != nil {
+		t.Fatal(err)
+	}
+
+	defaultTTL, dryRun, outputFormat = ttlAuto, false, "text"
+	activeZoneIdentifier, activeZoneName = nil, ""
+	if err := processCmd("state import " + path); err != nil {
+		t.Fatal(err)
+	}
+	if defaultTTL != 600 || !dryRun || outputFormat != "json" || activeZoneName != "example.net" {
+		t.Errorf("imported ttl %d, dry-run %v, output %s, zone %s", defaultTTL, dryRun, outputFormat, activeZoneName)
+	}
+
+	if err := os.WriteFile(path, []byte(`{"settings": {"dryrun": "off"}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	err := processCmd("state import " + path)
+	if err == nil || !strings.Contains(err.Error(), `did you mean dry-run`) {
+		t.Errorf("import of an unknown setting: %v", err)
+	}
+}
+
+func TestUpdateAmongSeveral(t *testing.T) {
+	b := useMemoryBackend(t)
+	for _, c := range []string{"add A www.example.com 10.0.0.1", "add A www.example.com 10.0.0.2"} {
+		if err := processCmd(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Outside interactive mode, the record to update must be selected.
+	if err := processCmd("ip4 www.example.com 10.0.0.3"); err == nil {
+		t.Error("ip4 updated one of several records without --id or --all")
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1", "A www.example.com 10.0.0.2")
+
+	var id string
+	for _, r := range b.Records() {
+		if r.Content == "10.0.0.2" {
+			id = r.ID
+		}
+	}
+	if err := processCmd("ip4 --id " + id + " www.example.com 10.0.0.3"); err != nil {
+		t.Fatal(err)
+	}
+	got := summarize(b.Records())
+	sort.Strings(got)
+	if want := []string{"A www.example.com 10.0.0.1", "A www.example.com 10.0.0.3"}; !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+
+	if err := processCmd("ip4 --all www.example.com 10.0.0.3"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.3")
+}
+
+func TestPollRecords(t *testing.T) {
+	useMemoryBackend(t)
+	if err := processCmd("ip4 www.example.com 10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	recs, diffs, err := pollRecords(activeAPI, activeZoneIdentifier, nil)
+	if err != nil || len(diffs) != 1 || diffs[0].Old != nil {
+		t.Fatalf("first poll: %d diff(s), %v", len(diffs), err)
+	}
+
+	for _, c := range []string{"ip4 www.example.com 10.0.0.2", "txt example.com hello"} {
+		if err := processCmd(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	_, diffs, err = pollRecords(activeAPI, activeZoneIdentifier, recs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, d := range diffs {
+		line, _ := formatDiff(d)
+		got = append(got, line[:1])
+	}
+	sort.Strings(got)
+	if want := []string{"+", "~"}; !slices.Equal(got, want) {
+		t.Errorf("second poll = %q, want %q", got, want)
+	}
+}
+
+func TestDelegateSubdomainNames(t *testing.T) {
+	useMemoryBackend(t)
+	for _, c := range []string{
+		"nameservers delegate example.com ns1.other.net",
+		"nameservers delegate @ ns1.other.net",
+		"nameservers delegate dev.example.org ns1.other.net",
+	} {
+		if err := processCmd(c); err == nil {
+			t.Errorf("%s: no error", c)
+		}
+	}
+}
+
+func TestSessionUndoRedo(t *testing.T) {
+	b := useMemoryBackend(t)
+	interactive, session = true, sessionHistory{}
+	defer func() { interactive, session = false, sessionHistory{} }()
+
+	steps := []struct {
+		line string
+		want []string
+	}{
+		{"ip4 www.example.com 10.0.0.1", []string{"A www.example.com 10.0.0.1"}},
+		{"ip4 www.example.com 10.0.0.2", []string{"A www.example.com 10.0.0.2"}},
+		{"delete --force A www.example.com", nil},
+		{"undo --force", []string{"A www.example.com 10.0.0.2"}},
+		{"undo --force", []string{"A www.example.com 10.0.0.1"}},
+		{"redo --force", []string{"A www.example.com 10.0.0.2"}},
+		{"redo --force", nil},
+		{"undo --force", []string{"A www.example.com 10.0.0.2"}},
+		{"ip4 api.example.com 10.0.0.3", []string{"A api.example.com 10.0.0.3", "A www.example.com 10.0.0.2"}},
+		{"redo --force", []string{"A api.example.com 10.0.0.3", "A www.example.com 10.0.0.2"}},
+		{"undo --force", []string{"A www.example.com 10.0.0.2"}},
+	}
+	for _, s := range steps {
+		if err := processCmd(s.line); err != nil {
+			t.Fatalf("%s: %v", s.line, err)
+		}
+		if got := summarize(b.Records()); !slices.Equal(got, s.want) {
+			t.Fatalf("after %s: records = %q, want %q", s.line, got, s.want)
+		}
+	}
+}
+
+func TestHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hooks are shell commands")
+	}
+	b := useMemoryBackend(t)
+	out := filepath.Join(t.TempDir(), "changes")
+	defer func(hooks []hook) { cfg.Hooks = hooks }(cfg.Hooks)
+	cfg.Hooks = []hook{
+		{Commands: []string{"ip4"}, When: "before", Run: `case "$CF_HOOK_ARGS" in *blocked*) exit 1;; esac`},
+		{When: "after", Run: `echo "$CF_HOOK_COMMAND $CF_HOOK_STATUS $CF_HOOK_CHANGES" >> ` + out},
+	}
+
+	if err := processCmd("ip4 blocked.example.com 10.0.0.1"); err == nil {
+		t.Error("before hook did not refuse the command")
+	}
+	if err := processCmd("ip4 www.example.com 10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := "ip4 ok create A www.example.com 10.0.0.1\n"; string(data) != want {
+		t.Errorf("after hook wrote %q, want %q", data, want)
+	}
+}
+
+func TestTemplateApply(t *testing.T) {
+	b := useMemoryBackend(t)
+	file := filepath.Join(t.TempDir(), "env.tmpl")
+	tmpl := `# Standard records
+TXT @ "v=spf1 -all"
+A {{.env}}.app {{.ip}} 300
+{{range split .aliases ","}}CNAME {{.}}.{{$.env}} {{$.env}}.app.{{$.Zone}}
+{{end}}`
+	if err := os.WriteFile(file, []byte(tmpl), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := processCmd("template apply " + file + " env=staging ip=10.0.0.1 aliases=api,admin"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"CNAME admin.staging.example.com staging.app.example.com",
+		"CNAME api.staging.example.com staging.app.example.com",
+		"TXT example.com v=spf1 -all",
+		"A staging.app.example.com 10.0.0.1")
+
+	if err := processCmd("template apply " + file + " env=staging"); err == nil {
+		t.Error("template with missing parameters applied")
+	}
+}
+
+func TestRedactText(t *testing.T) {
+	tests := []struct{ in, want string }{
+		{"A www.example.com 203.0.113.45", "A www.example.com 203.0.x.x"},
+		{"AAAA www.example.com 2001:db8::1", "AAAA www.example.com 2001:db8:x"},
+		{"AAAA localhost ::1", "AAAA localhost ::x"},
+		{"google-site-verification=rXOxyZounnZasA8Z7oaD3c14JdjS9aKSWvsR1EbUSIQ", "google-site-verification=rXOx…"},
+		{"v=DKIM1; k=rsa; p=MIGfMA0GCSqGSIb3DQEBAQUAA4GNADCBiQKBgQC", "v=DKIM1; k=rsa; p=MIGf…"},
+		{"token: Yl2Nnbd8Ut3_uDNyIWjhTExkWAVGe7D8X5PymeIz", "token: Yl2N…"},
+		{"v=spf1 include:_spf.google.com ~all", "v=spf1 include:_spf.google.com ~all"},
+		{"2024-03-01 10:15:02 372e67954025e0ba6aaa6d586b9e0b59", "2024-03-01 10:15:02 372e67954025e0ba6aaa6d586b9e0b59"},
+		{"version 1.2.3.4567", "version 1.2.3.4567"},
+	}
+	for _, test := range tests {
+		if got := redactText(test.in); got != test.want {
+			t.Errorf("redactText(%q) = %q, want %q", test.in, got, test.want)
+		}
+	}
+}
+
+func TestZoneOverride(t *testing.T) {
+	useMemoryBackend(t)
+	b := cflib.NewMemoryBackend("example.com", "example.org")
+	backend = b
+	comID, _ := b.ZoneIDByName("example.com")
+	orgID, _ := b.ZoneIDByName("example.org")
+	activeZoneIdentifier = cloudflare.ZoneIdentifier(comID)
+
+	if err := processCmd("ip4 --zone example.org www.example.org 10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("ip4 --zone=example.net www.example.net 10.0.0.2"); err == nil {
+		t.Error("unknown zone accepted")
+	}
+	if activeZoneName != "example.com" || activeZoneIdentifier.Identifier != comID {
+		t.Errorf("active zone changed to %s", activeZoneName)
+	}
+
+	recs, _ := b.ListDNSRecords(context.Background(), orgID, cloudflare.ListDNSRecordsParams{})
+	if got, want := summarize(recs), []string{"A www.example.org 10.0.0.1"}; !slices.Equal(got, want) {
+		t.Errorf("example.org records = %q, want %q", got, want)
+	}
+	recs, _ = b.ListDNSRecords(context.Background(), comID, cloudflare.ListDNSRecordsParams{})
+	if len(recs) != 0 {
+		t.Errorf("example.com records = %q, want none", summarize(recs))
+	}
+}
+
+func TestCommandFlags(t *testing.T) {
+	useMemoryBackend(t)
+
+	// --dry-run and --json apply to the command they follow alone.
+	if err := processCmd("ip4 www.example.com 10.0.0.1 --dry-run"); err != nil || dryRun {
+		t.Errorf("ip4 --dry-run: %v, dry-run mode %v afterward", err, dryRun)
+	}
+	if err := processCmd("list --json"); err != nil || outputFormat != "text" {
+		t.Errorf("list --json: %v, output format %s afterward", err, outputFormat)
+	}
+
+	// Commands registering a command flag handle it themselves.
+	c := cmds.Commands()[0]
+	for _, cc := range cmds.Commands() {
+		if cc.Name == "edit" {
+			c = cc
+		}
+	}
+	args, flags, err := takeCommandFlags(c, []string{"--json", "--dry-run", "A"})
+	if err != nil || !slices.Equal(args, []string{"--json", "A"}) || !flags.has("dry-run") || flags.has("json") {
+		t.Errorf("edit arguments %q, flags %v, %v", args, flags, err)
+	}
+	if _, _, err := takeCommandFlags(c, []string{"--dry-run=yes"}); err == nil {
+		t.Error("--dry-run accepted a value")
+	}
+
+	// Flags are taken among the positional arguments too, but not after
+	// "--", so that arguments which look like flags reach the command.
+	for _, cc := range cmds.Commands() {
+		if cc.Name == "txt" {
+			c = cc
+		}
+	}
+	args, flags, err = takeCommandFlags(c, []string{"--comment", "spf", "--dry-run", "example.com", "--json", "--", "--zone"})
+	if err != nil || !slices.Equal(args, []string{"--comment", "spf", "example.com", "--", "--zone"}) ||
+		!flags.has("dry-run") || !flags.has("json") || flags.has("zone") {
+		t.Errorf("txt arguments %q, flags %v, %v", args, flags, err)
+	}
+	if err := processCmd("txt -- example.com --json"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, backend.(*cflib.MemoryBackend), "TXT example.com --json", "A www.example.com 10.0.0.1")
+
+	// The flags of one subcommand are not those of the others, and a
+	// registered flag's value is kept with it.
+	for _, test := range []struct {
+		command   string
+		args      []string
+		wantArgs  []string
+		wantFlags []string
+	}{
+		{"zone", []string{"unlock", "--zone", "example.org"}, []string{"unlock"}, []string{"zone"}},
+		{"zone", []string{"pull", "--zone", "example.org"}, []string{"pull", "--zone", "example.org"}, nil},
+		{"analytics", []string{"--zone", "example.org"}, []string{}, []string{"zone"}},
+		{"delete", []string{"--id", "--json"}, []string{"--id", "--json"}, nil},
+		{"mail", []string{"spf", "set", "--json", "-all"}, []string{"spf", "set", "-all"}, []string{"json"}},
+		{"rename", []string{"--", "A", "old", "new"}, []string{"A", "old", "new"}, nil},
+	} {
+		for _, cc := range cmds.Commands() {
+			if cc.Name == test.command {
+				c = cc
+			}
+		}
+		args, flags, err := takeCommandFlags(c, test.args)
+		var names []string
+		for name := range flags {
+			names = append(names, name)
+		}
+		if err != nil || !slices.Equal(args, test.wantArgs) || !slices.Equal(names, test.wantFlags) {
+			t.Errorf("%s %q: arguments %q, flags %v, %v", test.command, test.args, args, flags, err)
+		}
+	}
+	if err := processCmd("zone unlock --zone example.com"); err != nil {
+		t.Errorf("zone unlock --zone: %v", err)
+	}
+}
+
+func TestAccessReport(t *testing.T) {
+	const account, zone = "acct1", "zone1"
+
+	member := cloudflare.AccountMember{
+		User:   cloudflare.AccountMemberUserDetails{Email: "dns@example.com"},
+		Status: "accepted",
+		Policies: []cloudflare.Policy{{
+			Access:           "allow",
+			PermissionGroups: []cloudflare.PermissionGroup{{Name: "DNS Write"}},
+			ResourceGroups: []cloudflare.ResourceGroup{{Scope: cloudflare.Scope{
+				Key:          "com.cloudflare.api.account." + account,
+				ScopeObjects: []cloudflare.ScopeObject{{Key: "com.cloudflare.api.account.zone." + zone}},
+			}}},
+		}},
+	}
+	if am, ok := memberAccess(member, account, zone); !ok || am.Scope != "zone" || !slices.Equal(am.Permissions, []string{"DNS Write"}) {
+		t.Errorf("memberAccess = %+v, %v", am, ok)
+	}
+	if _, ok := memberAccess(member, account, "zone2"); ok {
+		t.Error("member has access to another zone")
+	}
+
+	token := cloudflare.APIToken{
+		Name: "ci",
+		Policies: []cloudflare.APITokenPolicies{
+			{
+				Effect: "allow",
+				Resources: map[string]any{"com.cloudflare.api.account." + account: map[string]any{
+					"com.cloudflare.api.account.zone.*": "*",
+				}},
+				PermissionGroups: []cloudflare.APITokenPermissionGroups{{Name: "DNS Write"}, {Name: "Zone Read"}},
+			},
+			{
+				Effect:           "deny",
+				Resources:        map[string]any{"com.cloudflare.api.account.zone." + zone: "*"},
+				PermissionGroups: []cloudflare.APITokenPermissionGroups{{Name: "DNS Write"}},
+			},
+		},
+	}
+	if at, ok := tokenAccess(token, account, zone); !ok || at.Scope != "account" || !slices.Equal(at.Permissions, []string{"Zone Read"}) {
+		t.Errorf("tokenAccess = %+v, %v", at, ok)
+	}
+
+	now := time.Now()
+	logs := []cloudflare.AuditLog{
+		{Actor: cloudflare.AuditLogActor{Email: "a@example.com", IP: "10.0.0.1"}, Action: cloudflare.AuditLogAction{Result: true}, When: now.Add(-time.Hour)},
+		{Actor: cloudflare.AuditLogActor{Email: "b@example.com", IP: "10.0.0.2"}, Action: cloudflare.AuditLogAction{Result: false}, When: now.Add(-2 * time.Hour)},
+		{Actor: cloudflare.AuditLogActor{Email: "a@example.com", IP: "10.0.0.3"}, Action: cloudflare.AuditLogAction{Result: true}, When: now.Add(-3 * time.Hour)},
+	}
+	actors := auditActors(logs)
+	if len(actors) != 2 || actors[0].Actor != "a@example.com" || actors[0].Actions != 2 ||
+		len(actors[0].IPs) != 2 || actors[1].Failed != 1 {
+		t.Errorf("auditActors = %+v", actors)
+	}
+}
+
+func TestDescribeResources(t *testing.T) {
+	names := map[string]string{
+		"com.cloudflare.api.account.a1":      "account Example",
+		"com.cloudflare.api.account.zone.z1": "zone example.com",
+	}
+	resources := map[string]any{
+		"com.cloudflare.api.account.a1": map[string]any{
+			"com.cloudflare.api.account.zone.z1": "*",
+			"com.cloudflare.api.account.zone.z2": "*",
+		},
+		"com.cloudflare.api.user.u1": "*",
+	}
+	got := describeResources(resources, names)
+	want := []string{
+		"account Example",
+		"    zone example.com",
+		"    com.cloudflare.api.account.zone.z2",
+		"user u1",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("describeResources = %q, want %q", got, want)
+	}
+}
+
+func TestSummaryFile(t *testing.T) {
+	useMemoryBackend(t)
+	runSummary.total, runSummary.written = runTotals{}, false
+	t.Cleanup(func() { summaryFile = "" })
+
+	for _, c := range []string{
+		"upsert A www.example.com 10.0.0.1",
+		"upsert A www.example.com 10.0.0.2",
+		"upsert A api.example.com 10.0.0.3",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatal(err)
+		}
+	}
+	noteRunFailure()
+
+	dir := t.TempDir()
+	summaryFile = filepath.Join(dir, "cf.prom")
+	finishSummaryFile("script", exitFailure)
+	data, err := os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		`cf_run_records{command="script",action="created"} 2`,
+		`cf_run_records{command="script",action="updated"} 1`,
+		`cf_run_failed{command="script"} 1`,
+		`cf_run_success{command="script"} 0`,
+		"# TYPE cf_run_api_calls gauge\n",
+	} {
+		if !strings.Contains(string(data), want) {
+			t.Errorf("summary lacks %q:\n%s", want, data)
+		}
+	}
+
+	summaryFile = filepath.Join(dir, "cf.json")
+	finishSummaryFile("script", exitSuccess)
+	if _, err := os.Stat(summaryFile); err == nil {
+		t.Error("finishSummaryFile rewrote a summary already written")
+	}
+	writeSummaryFile("ddns", exitSuccess)
+	var summary struct {
+		Command string `json:"command"`
+		Success bool   `json:"success"`
+		Created int    `json:"created"`
+	}
+	data, err = os.ReadFile(summaryFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(data, &summary); err != nil {
+		t.Fatal(err)
+	}
+	if summary.Command != "ddns" || !summary.Success || summary.Created != 2 {
+		t.Errorf("summary = %+v", summary)
+	}
+}
+
+func TestRunSummary(t *testing.T) {
+	useMemoryBackend(t)
+
+	if err := processCmd("upsert A www.example.com 10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("upsert A www.example.com 10.0.0.2"); err != nil {
+		t.Fatal(err)
+	}
+	run := currentRun()
+	if !runSummary.bulk || runSummary.created != 0 || runSummary.updated != 1 || runSummary.failed != 0 {
+		t.Errorf("summary = created %d updated %d failed %d", runSummary.created, runSummary.updated, runSummary.failed)
+	}
+
+	var entries []journalEntry
+	withStateLock(func() error {
+		path, err := statePath("journal")
+		if err != nil {
+			return err
+		}
+		entries, err = readJournal(path)
+		return err
+	})
+	if len(entries) != 2 || entries[0].Run == run || entries[1].Run != run {
+		t.Errorf("journal runs = %+v, want the last with run %s", entries, run)
+	}
+}
+
+func TestSummarizePoolHealth(t *testing.T) {
+	origin := func(addr string, healthy bool, reason string) map[string]cloudflare.LoadBalancerOriginHealth {
+		return map[string]cloudflare.LoadBalancerOriginHealth{
+			addr: {Healthy: healthy, FailureReason: reason},
+		}
+	}
+	h := cloudflare.LoadBalancerPoolHealth{
+		PopHealth: map[string]cloudflare.LoadBalancerPoolPopHealth{
+			"WNAM": {Origins: []map[string]cloudflare.LoadBalancerOriginHealth{
+				origin("10.0.0.1", true, ""),
+				origin("10.0.0.2", false, "HTTP timeout occurred"),
+			}},
+			"WEU": {Origins: []map[string]cloudflare.LoadBalancerOriginHealth{
+				origin("10.0.0.1", true, ""),
+				origin("10.0.0.2", false, "HTTP timeout occurred"),
+			}},
+			"SAF": {Origins: []map[string]cloudflare.LoadBalancerOriginHealth{
+				origin("10.0.0.1", false, "TCP connection failed"),
+				origin("10.0.0.2", true, ""),
+			}},
+		},
+	}
+
+	got := summarizePoolHealth(h)
+	if o := got["10.0.0.1"]; o == nil || o.Healthy != 2 || o.Checked != 3 ||
+		!slices.Equal(o.Failures, []string{"TCP connection failed"}) {
+		t.Errorf("10.0.0.1 = %+v", o)
+	}
+	if o := got["10.0.0.2"]; o == nil || o.Healthy != 1 || o.Checked != 3 ||
+		!slices.Equal(o.Failures, []string{"HTTP timeout occurred"}) {
+		t.Errorf("10.0.0.2 = %+v", o)
+	}
+}
+
+func TestProxyPolicy(t *testing.T) {
+	b := useMemoryBackend(t)
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{ProxiedZones: []string{"example.com"}}
+
+	for _, line := range []string{
+		"ip4 www.example.com 10.0.0.1",
+		"ip4 --dns-only mail.example.com 10.0.0.2",
+		"add CNAME app.example.com www.example.com",
+		"txt www.example.com hello",
+	} {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+
+	proxied := make(map[string]bool)
+	for _, r := range b.Records() {
+		proxied[r.Type+" "+r.Name] = isProxied(r)
+	}
+	want := map[string]bool{
+		"A www.example.com":     true,
+		"A mail.example.com":    false,
+		"CNAME app.example.com": true,
+		"TXT www.example.com":   false,
+	}
+	if !maps.Equal(proxied, want) {
+		t.Errorf("proxied = %v, want %v", proxied, want)
+	}
+}
+
+func TestProtect(t *testing.T) {
+	b := useMemoryBackend(t)
+	t.Setenv("CLOUDFLARE_CONFIG", filepath.Join(t.TempDir(), "config.json"))
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{}
+
+	for _, line := range []string{
+		"ip4 example.com 10.0.0.1",
+		"ip4 www.example.com 10.0.0.2",
+		"protect add A @",
+	} {
+		if err := processCmd(line); err != nil {
+			t.Fatalf("%s: %v", line, err)
+		}
+	}
+
+	// The protection is saved in the configuration file.
+	cfg = &config{}
+	if err := loadConfig(); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Protected) != 1 || cfg.Protected[0].Name != "example.com" {
+		t.Fatalf("protected = %+v", cfg.Protected)
+	}
+
+	if err := processCmd("delete A *"); err == nil {
+		t.Error("delete of a protected record succeeded")
+	}
+	checkRecords(t, b, "A example.com 10.0.0.1", "A www.example.com 10.0.0.2")
+
+	// RPC requests are refused as well, unless forced.
+	resp := handleRPC([]byte(`{"id":1,"method":"delete","params":{"type":"A","name":"example.com"}}`))
+	if resp.Error == nil {
+		t.Errorf("RPC delete of a protected record returned %v", resp.Result)
+	}
+	checkRecords(t, b, "A example.com 10.0.0.1", "A www.example.com 10.0.0.2")
+
+	// Replacing several protected records with one deletes nothing.
+	if _, err := b.CreateDNSRecord(context.Background(), activeZoneIdentifier.Identifier,
+		cloudflare.CreateDNSRecordParams{Type: "A", Name: "example.com", Content: "10.0.0.3"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("ip4 --all example.com 10.0.0.4"); err == nil {
+		t.Error("ip4 --all replaced protected records")
+	}
+	checkRecords(t, b, "A example.com 10.0.0.1", "A example.com 10.0.0.3", "A www.example.com 10.0.0.2")
+
+	resp = handleRPC([]byte(`{"id":2,"method":"delete","params":{"type":"A","name":"example.com","force":true}}`))
+	if resp.Error != nil {
+		t.Fatalf("forced RPC delete: %s", resp.Error.Message)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.2")
+
+	if err := processCmd("delete --force A *"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b)
+
+	if err := processCmd("protect remove A example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if len(cfg.Protected) != 0 {
+		t.Errorf("protected after removal = %+v", cfg.Protected)
+	}
+}
+
+func TestDiffSettings(t *testing.T) {
+	on := true
+	rule := func(id, expr string) cloudflare.RulesetRule {
+		return cloudflare.RulesetRule{ID: id, Action: "block", Expression: expr, Enabled: &on}
+	}
+	baseline := settingsSnapshot{
+		Zone:      "example.com",
+		Settings:  map[string]any{"ssl": "full", "min_tls_version": "1.2", "http3": "on"},
+		PageRules: []cloudflare.PageRule{},
+		Rulesets: map[string][]cloudflare.RulesetRule{
+			"http_request_firewall_custom": {rule("r1", `ip.src eq 10.0.0.1`), rule("r2", `http.host eq "a"`)},
+		},
+	}
+
+	// The baseline is compared as read back from its file.
+	data, err := json.Marshal(baseline)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved settingsSnapshot
+	if err := json.Unmarshal(data, &saved); err != nil {
+		t.Fatal(err)
+	}
+
+	live := settingsSnapshot{
+		Zone:     "example.com",
+		Settings: map[string]any{"ssl": "flexible", "min_tls_version": "1.2", "http3": "on", "0rtt": "on"},
+		Rulesets: map[string][]cloudflare.RulesetRule{
+			"http_request_firewall_custom": {rule("r1", `ip.src eq 10.0.0.1`)},
+		},
+	}
+	var got []string
+	for _, d := range diffSettings(saved, live) {
+		got = append(got, d.Key+" "+d.Old+" "+d.New)
+	}
+	want := []string{
+		"rule http_request_firewall_custom/r2 " +
+			`{"id":"r2","action":"block","expression":"http.host eq \"a\"","enabled":true} `,
+		"setting 0rtt  on",
+		"setting ssl full flexible",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("diffSettings = %q, want %q", got, want)
+	}
+}
+
+func TestCmdResult(t *testing.T) {
+	b := useMemoryBackend(t)
+	defer func() { lastResult, failFast = cmdResult{}, false }()
+
+	if err := processCmd("frobnicate"); err != errCommandNotFound {
+		t.Errorf("unknown command error = %v, want %v", err, errCommandNotFound)
+	}
+	if lastResult.status != exitUsage || lastResult.cmd != nil {
+		t.Errorf("unknown command result = %+v", lastResult)
+	}
+	if err := processCmd(`comment set A www.example.com "status $?"`); err != errNoMatch {
+		t.Errorf("comment error = %v, want %v", err, errNoMatch)
+	}
+	if lastResult.status != exitFailure || lastResult.cmd == nil || lastResult.cmd.Name != "comment" {
+		t.Errorf("comment result = %+v", lastResult)
+	}
+
+	script := filepath.Join(t.TempDir(), "script")
+	lines := "ip4 www.example.com 10.0.0.1\nfrobnicate\nip4 api.example.com 10.0.0.2\n"
+	if err := os.WriteFile(script, []byte(lines), 0600); err != nil {
+		t.Fatal(err)
+	}
+	failFast = true
+	if code := runScript(script); code != exitUsage {
+		t.Errorf("fail-fast script exit code = %d, want %d", code, exitUsage)
+	}
+	checkRecords(t, b, "A www.example.com 10.0.0.1")
+
+	failFast = false
+	if code := runScript(script); code != exitUsage {
+		t.Errorf("script exit code = %d, want %d", code, exitUsage)
+	}
+	checkRecords(t, b, "A api.example.com 10.0.0.2", "A www.example.com 10.0.0.1")
+
+	processCmd("frobnicate")
+	args, err := expandArgs([]string{"exit=$?", "$$?"})
+	if err != nil || !slices.Equal(args, []string{"exit=2", "$?"}) {
+		t.Errorf("expandArgs = %q, %v", args, err)
+	}
+}
+
+func TestCapability(t *testing.T) {
+	if !hasCapability("business", capRegexRules) || hasCapability("pro", capRegexRules) {
+		t.Error("regex rules should require a Business plan")
+	}
+	if !hasCapability("partners_ent", capPurgeByTag) {
+		t.Error("unknown plans should be assumed to have every capability")
+	}
+
+	err := &capabilityError{capPurgeByTag, "example.com", "Free Website"}
+	want := "Purging the cache by tag requires an Enterprise plan; zone example.com has the Free Website plan"
+	if err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,132 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cflib
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/url"
+	"strconv"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A Backend performs the zone lookups and DNS record operations of the
+// Cloudflare API on behalf of a Client. NewAPIBackend returns a backend
+// using the real API, and NewMemoryBackend one holding records in memory
+// for tests.
+type Backend interface {
+	// ZoneIDByName returns the ID of the zone with the requested name.
+	ZoneIDByName(name string) (string, error)
+
+	// ListDNSRecords returns all records in a zone matching the type, name
+	// and content of params, requesting params.PerPage records at a time.
+	ListDNSRecords(ctx context.Context, zoneID string, params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, error)
+
+	GetDNSRecord(ctx context.Context, zoneID, id string) (cloudflare.DNSRecord, error)
+	CreateDNSRecord(ctx context.Context, zoneID string, params cloudflare.CreateDNSRecordParams) (cloudflare.DNSRecord, error)
+	UpdateDNSRecord(ctx context.Context, zoneID string, params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error)
+	DeleteDNSRecord(ctx context.Context, zoneID, id string) error
+}
+
+type apiBackend struct {
+	api *cloudflare.API
+}
+
+// NewAPIBackend returns a backend performing its operations through the
+// Cloudflare API.
+func NewAPIBackend(api *cloudflare.API) Backend {
+	return apiBackend{api}
+}
+
+func (b apiBackend) ZoneIDByName(name string) (string, error) {
+	return b.api.ZoneIDByName(name)
+}
+
+// ListDNSRecords follows pages by cursor when the API provides one, and by
+// page number otherwise. Records are deduplicated by ID, so a record
+// shifted onto a later page by a concurrent edit is not listed twice.
+func (b apiBackend) ListDNSRecords(ctx context.Context, zoneID string,
+	params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, error) {
+
+	q := url.Values{}
+	if params.Type != "" {
+		q.Set("type", params.Type)
+	}
+	if params.Name != "" {
+		q.Set("name", params.Name)
+	}
+	if params.Content != "" {
+		q.Set("content", params.Content)
+	}
+	perPage := params.PerPage
+	if perPage == 0 {
+		perPage = DefaultPageSize
+	}
+	q.Set("per_page", strconv.Itoa(perPage))
+
+	var recs []cloudflare.DNSRecord
+	seen := make(map[string]bool)
+	cursor := ""
+	for page := 1; ; page++ {
+		if cursor != "" {
+			q.Set("cursor", cursor)
+			q.Del("page")
+		} else {
+			q.Set("page", strconv.Itoa(page))
+		}
+
+		r, err := b.api.Raw(ctx, http.MethodGet,
+			"/zones/"+zoneID+"/dns_records?"+q.Encode(), nil, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		var batch []cloudflare.DNSRecord
+		if err := json.Unmarshal(r.Result, &batch); err != nil {
+			return nil, err
+		}
+		for _, rec := range batch {
+			if !seen[rec.ID] {
+				seen[rec.ID] = true
+				recs = append(recs, rec)
+			}
+		}
+
+		info := r.ResultInfo
+		if info == nil || len(batch) == 0 {
+			break
+		}
+		if next := info.Cursors.After; next != "" {
+			cursor = next
+			continue
+		}
+		if cursor != "" || !info.HasMorePages() {
+			break
+		}
+	}
+	return recs, nil
+}
+
+func (b apiBackend) GetDNSRecord(ctx context.Context, zoneID, id string) (cloudflare.DNSRecord, error) {
+	return b.api.GetDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), id)
+}
+
+func (b apiBackend) CreateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.CreateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	return b.api.CreateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), params)
+}
+
+func (b apiBackend) UpdateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	return b.api.UpdateDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), params)
+}
+
+func (b apiBackend) DeleteDNSRecord(ctx context.Context, zoneID, id string) error {
+	return b.api.DeleteDNSRecord(ctx, cloudflare.ZoneIdentifier(zoneID), id)
+}
@@ -0,0 +1,287 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+// Package cflib manages the DNS records of a Cloudflare zone. It holds the
+// logic behind the cf command-line tool, so that other Go programs can list,
+// update and synchronize records without the interactive shell.
+package cflib
+
+import (
+	"context"
+	"slices"
+	"strings"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// TTLAuto is the TTL value Cloudflare interprets as "automatic".
+const TTLAuto = 1
+
+// DefaultPageSize is the default number of DNS records requested per page.
+const DefaultPageSize = 100
+
+// A Client manages the DNS records of one zone.
+type Client struct {
+	Backend Backend
+	ZoneID  string
+
+	// PageSize is the number of records requested per page when listing
+	// records. Zero selects DefaultPageSize.
+	PageSize int
+
+	// DefaultTTL is the TTL of records created without one. Zero selects
+	// TTLAuto.
+	DefaultTTL int
+
+	// Warnf, if not nil, is called to report conditions that do not prevent
+	// an operation from succeeding.
+	Warnf func(format string, args ...any)
+}
+
+// New returns a client managing the records of the zone with the requested
+// ID through the Cloudflare API.
+func New(api *cloudflare.API, zoneID string) *Client {
+	return NewWithBackend(NewAPIBackend(api), zoneID)
+}
+
+// NewWithBackend returns a client managing the records of the zone with the
+// requested ID through a backend.
+func NewWithBackend(b Backend, zoneID string) *Client {
+	return &Client{Backend: b, ZoneID: zoneID}
+}
+
+// A Record describes the desired state of a DNS record. A TTL of 0 leaves
+// the TTL of an existing record unchanged, and a nil Comment, Tags or
+// Proxied leaves the record's existing comment, tags or proxy setting
+// unchanged.
+type Record struct {
+	Type    string
+	Name    string
+	Content string
+	TTL     int
+	Comment *string
+	Tags    []string
+	Proxied *bool
+}
+
+// A Change describes the effect of an Upsert.
+type Change int
+
+const (
+	Unchanged Change = iota
+	Created
+	Updated
+)
+
+func (c *Client) warnf(format string, args ...any) {
+	if c.Warnf != nil {
+		c.Warnf(format, args...)
+	}
+}
+
+// ListRecords returns all DNS records in the zone matching the type, name
+// and content of params.
+func (c *Client) ListRecords(ctx context.Context, params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, error) {
+	if params.PerPage == 0 {
+		params.PerPage = c.PageSize
+	}
+	if params.PerPage == 0 {
+		params.PerPage = DefaultPageSize
+	}
+	return c.Backend.ListDNSRecords(ctx, c.ZoneID, params)
+}
+
+// Upsert updates the first record matching the type and name of rec so
+// that it holds the requested content, or creates a new record if none
+// exists.
+func (c *Client) Upsert(ctx context.Context, rec Record) (Change, error) {
+	params := cloudflare.ListDNSRecordsParams{
+		Type: rec.Type,
+		Name: rec.Name,
+	}
+	recs, err := c.ListRecords(ctx, params)
+	if err != nil {
+		return Unchanged, err
+	}
+
+	if len(recs) > 1 {
+		c.warnf("Warning: %d %s records named %s exist; only the first is updated.\n",
+			len(recs), rec.Type, rec.Name)
+	}
+
+	ttl := rec.TTL
+	if len(recs) > 0 {
+		r := recs[0]
+		if ttl == 0 {
+			ttl = r.TTL
+		}
+		if ContentEqual(rec.Type, r.Content, rec.Content) && NormalizeTTL(r.TTL) == NormalizeTTL(ttl) &&
+			!metaChanges(rec, r) {
+			return Unchanged, nil
+		}
+		tags := r.Tags
+		if rec.Tags != nil {
+			tags = rec.Tags
+		}
+		params := cloudflare.UpdateDNSRecordParams{
+			Type:    r.Type,
+			Name:    rec.Name,
+			Content: rec.Content,
+			ID:      r.ID,
+			TTL:     ttl,
+			Proxied: rec.Proxied,
+			Comment: rec.Comment,
+			Tags:    tags,
+		}
+		if _, err := c.Backend.UpdateDNSRecord(ctx, c.ZoneID, params); err != nil {
+			return Unchanged, err
+		}
+		return Updated, nil
+	}
+
+	if ttl == 0 {
+		ttl = c.DefaultTTL
+	}
+	if ttl == 0 {
+		ttl = TTLAuto
+	}
+	createParams := cloudflare.CreateDNSRecordParams{
+		Type:      rec.Type,
+		Name:      rec.Name,
+		Content:   rec.Content,
+		TTL:       ttl,
+		Proxied:   rec.Proxied,
+		Proxiable: false,
+		Tags:      rec.Tags,
+	}
+	if rec.Comment != nil {
+		createParams.Comment = *rec.Comment
+	}
+	if _, err := c.Backend.CreateDNSRecord(ctx, c.ZoneID, createParams); err != nil {
+		return Unchanged, err
+	}
+	return Created, nil
+}
+
+// metaChanges reports whether applying the comment, tags and proxy
+// setting of rec would modify the record r.
+func metaChanges(rec Record, r cloudflare.DNSRecord) bool {
+	if rec.Comment != nil && *rec.Comment != r.Comment {
+		return true
+	}
+	if rec.Proxied != nil && *rec.Proxied != (r.Proxied != nil && *r.Proxied) {
+		return true
+	}
+	if rec.Tags != nil && !sameTags(rec.Tags, r.Tags) {
+		return true
+	}
+	return false
+}
+
+func sameTags(a, b []string) bool {
+	a, b = slices.Clone(a), slices.Clone(b)
+	slices.Sort(a)
+	slices.Sort(b)
+	return slices.Equal(a, b)
+}
+
+// Delete deletes the record with the requested ID.
+func (c *Client) Delete(ctx context.Context, id string) error {
+	return c.Backend.DeleteDNSRecord(ctx, c.ZoneID, id)
+}
+
+// A Plan lists the changes that would bring a zone's records to a desired
+// state.
+type Plan struct {
+	// Create holds the desired records missing from the zone.
+	Create []Record
+
+	// Delete holds the zone's records that share a type and name with a
+	// desired record but are not themselves desired.
+	Delete []cloudflare.DNSRecord
+
+	// Unchanged is the number of desired records already in the zone.
+	Unchanged int
+}
+
+// Diff compares a desired set of records with the records of the zone.
+// Only the types and names of the desired records are considered, so
+// records of other names are left alone.
+func (c *Client) Diff(ctx context.Context, desired []Record) (Plan, error) {
+	var plan Plan
+	live, err := c.ListRecords(ctx, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return plan, err
+	}
+
+	covered := make(map[string]bool)
+	matched := make([]bool, len(live))
+	for _, d := range desired {
+		covered[strings.ToUpper(d.Type)+" "+NormalizeName(d.Name)] = true
+		want := cloudflare.DNSRecord{Type: d.Type, Name: d.Name, Content: d.Content, TTL: d.TTL}
+
+		found := false
+		for i, l := range live {
+			if !matched[i] && RecordsEqual(want, l) {
+				matched[i], found = true, true
+				break
+			}
+		}
+		if found {
+			plan.Unchanged++
+		} else {
+			plan.Create = append(plan.Create, d)
+		}
+	}
+
+	for i, l := range live {
+		if !matched[i] && covered[strings.ToUpper(l.Type)+" "+NormalizeName(l.Name)] {
+			plan.Delete = append(plan.Delete, l)
+		}
+	}
+	return plan, nil
+}
+
+// SyncZone brings the zone's records of the desired types and names to the
+// desired state. Missing records are created, and, if prune is true,
+// records that are not desired are deleted. It returns the plan that was
+// applied.
+func (c *Client) SyncZone(ctx context.Context, desired []Record, prune bool) (Plan, error) {
+	plan, err := c.Diff(ctx, desired)
+	if err != nil {
+		return plan, err
+	}
+	if !prune {
+		plan.Delete = nil
+	}
+
+	for _, r := range plan.Delete {
+		if err := c.Delete(ctx, r.ID); err != nil {
+			return plan, err
+		}
+	}
+	for _, d := range plan.Create {
+		ttl := d.TTL
+		if ttl == 0 {
+			ttl = c.DefaultTTL
+		}
+		if ttl == 0 {
+			ttl = TTLAuto
+		}
+		params := cloudflare.CreateDNSRecordParams{
+			Type:    d.Type,
+			Name:    d.Name,
+			Content: d.Content,
+			TTL:     ttl,
+			Tags:    d.Tags,
+		}
+		if d.Comment != nil {
+			params.Comment = *d.Comment
+		}
+		if _, err := c.Backend.CreateDNSRecord(ctx, c.ZoneID, params); err != nil {
+			return plan, err
+		}
+	}
+	return plan, nil
+}
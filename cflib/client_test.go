@@ -0,0 +1,147 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cflib
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func newTestClient(t *testing.T, recs ...Record) (*Client, *MemoryBackend) {
+	t.Helper()
+	b := NewMemoryBackend("example.com")
+	zoneID, err := b.ZoneIDByName("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := NewWithBackend(b, zoneID)
+	for _, r := range recs {
+		params := cloudflare.CreateDNSRecordParams{Type: r.Type, Name: r.Name, Content: r.Content, TTL: r.TTL}
+		if _, err := b.CreateDNSRecord(context.Background(), zoneID, params); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return c, b
+}
+
+func summarize(recs []cloudflare.DNSRecord) []string {
+	var s []string
+	for _, r := range recs {
+		s = append(s, r.Type+" "+r.Name+" "+r.Content)
+	}
+	return s
+}
+
+func TestUpsert(t *testing.T) {
+	c, b := newTestClient(t)
+	ctx := context.Background()
+
+	tests := []struct {
+		rec  Record
+		want Change
+	}{
+		{Record{Type: "A", Name: "www.example.com", Content: "10.0.0.1"}, Created},
+		{Record{Type: "A", Name: "www.example.com", Content: "10.0.0.1"}, Unchanged},
+		{Record{Type: "A", Name: "WWW.EXAMPLE.COM.", Content: "10.0.0.1", TTL: 1}, Unchanged},
+		{Record{Type: "A", Name: "www.example.com", Content: "10.0.0.2"}, Updated},
+		{Record{Type: "A", Name: "www.example.com", Content: "10.0.0.2", TTL: 300}, Updated},
+		{Record{Type: "A", Name: "www.example.com", Content: "10.0.0.2", Tags: []string{"web"}}, Updated},
+		{Record{Type: "A", Name: "www.example.com", Content: "10.0.0.2"}, Unchanged},
+	}
+	for i, test := range tests {
+		got, err := c.Upsert(ctx, test.rec)
+		if err != nil {
+			t.Fatalf("upsert %d: %v", i, err)
+		}
+		if got != test.want {
+			t.Errorf("upsert %d = %v, want %v", i, got, test.want)
+		}
+	}
+
+	recs := b.Records()
+	if len(recs) != 1 || recs[0].TTL != 300 || !slices.Equal(recs[0].Tags, []string{"web"}) {
+		t.Errorf("records = %+v", recs)
+	}
+}
+
+func TestSyncZone(t *testing.T) {
+	c, b := newTestClient(t,
+		Record{Type: "A", Name: "www.example.com", Content: "10.0.0.1"},
+		Record{Type: "A", Name: "www.example.com", Content: "10.0.0.2"},
+		Record{Type: "MX", Name: "example.com", Content: "mail.example.com"},
+	)
+	ctx := context.Background()
+
+	desired := []Record{
+		{Type: "A", Name: "www.example.com", Content: "10.0.0.1"},
+		{Type: "A", Name: "www.example.com", Content: "10.0.0.3"},
+		{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1"},
+	}
+
+	plan, err := c.Diff(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Unchanged != 1 || len(plan.Create) != 2 || len(plan.Delete) != 1 {
+		t.Fatalf("plan = %+v", plan)
+	}
+
+	if _, err := c.SyncZone(ctx, desired, false); err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"MX example.com mail.example.com",
+		"A www.example.com 10.0.0.1",
+		"A www.example.com 10.0.0.2",
+		"A www.example.com 10.0.0.3",
+		"AAAA www.example.com 2001:db8::1",
+	}
+	if got := summarize(b.Records()); !slices.Equal(got, want) {
+		t.Errorf("records after sync = %q, want %q", got, want)
+	}
+
+	if _, err := c.SyncZone(ctx, desired, true); err != nil {
+		t.Fatal(err)
+	}
+	want = slices.Delete(want, 2, 3)
+	if got := summarize(b.Records()); !slices.Equal(got, want) {
+		t.Errorf("records after pruning sync = %q, want %q", got, want)
+	}
+
+	plan, err = c.Diff(ctx, desired)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if plan.Unchanged != 3 || len(plan.Create) != 0 || len(plan.Delete) != 0 {
+		t.Errorf("plan after sync = %+v", plan)
+	}
+}
+
+func TestRegisterCommand(t *testing.T) {
+	defer func(saved []Command) { commands = saved }(commands)
+
+	RegisterCommand(Command{Name: "acme-deploy", Run: func(Session, []string) error { return nil }})
+	if got := RegisteredCommands(); len(got) != 1 || got[0].Name != "acme-deploy" {
+		t.Errorf("registered commands = %v", got)
+	}
+
+	for _, c := range []Command{
+		{Name: "acme-deploy", Run: func(Session, []string) error { return nil }},
+		{Name: "Bad Name", Run: func(Session, []string) error { return nil }},
+		{Name: "no-run"},
+	} {
+		func() {
+			defer func() {
+				if recover() == nil {
+					t.Errorf("RegisterCommand(%q) did not panic", c.Name)
+				}
+			}()
+			RegisterCommand(c)
+		}()
+	}
+}
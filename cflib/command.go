@@ -0,0 +1,86 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cflib
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sync"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A Command is a command added to the cf tool by another Go package, which
+// registers it with RegisterCommand from an init function. A build of cf
+// importing the package offers the command alongside its own.
+type Command struct {
+	Name        string // command name, such as "acme-deploy"
+	Brief       string // brief description shown in the command list
+	Description string // long description shown by help
+	Usage       string // usage hint text
+
+	// Run performs the command with the arguments following its name.
+	Run func(s Session, args []string) error
+}
+
+// A Session gives a registered command access to the state of the cf
+// session running it. The API client and zone are resolved when first
+// requested, as they are for cf's own commands, so commands that do not
+// need them never ask for credentials or a zone.
+type Session interface {
+	// Context returns the context of the running command, which is
+	// canceled when the command is interrupted.
+	Context() context.Context
+
+	// API returns the session's authenticated API client.
+	API() (*cloudflare.API, error)
+
+	// Zone returns the name and ID of the active zone.
+	Zone() (name, id string, err error)
+
+	// Client returns a client managing the records of the active zone.
+	// Its changes honor cf's dry-run mode and are recorded in its change
+	// journal, so that they may be undone.
+	Client() (*Client, error)
+
+	// Interactive reports whether the command runs at the cf> prompt.
+	Interactive() bool
+}
+
+var (
+	commandsMu  sync.Mutex
+	commands    []Command
+	commandName = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+// RegisterCommand adds a command to the cf tool. It panics if the command
+// has an invalid name, no Run function, or the name of a command already
+// registered. A command named like one of cf's own is ignored.
+func RegisterCommand(c Command) {
+	if !commandName.MatchString(c.Name) {
+		panic(fmt.Sprintf("cflib: invalid command name %q", c.Name))
+	}
+	if c.Run == nil {
+		panic(fmt.Sprintf("cflib: command %s has no Run function", c.Name))
+	}
+
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	for _, r := range commands {
+		if r.Name == c.Name {
+			panic(fmt.Sprintf("cflib: command %s registered twice", c.Name))
+		}
+	}
+	commands = append(commands, c)
+}
+
+// RegisteredCommands returns the commands added with RegisterCommand, in
+// the order they were registered.
+func RegisteredCommands() []Command {
+	commandsMu.Lock()
+	defer commandsMu.Unlock()
+	return append([]Command(nil), commands...)
+}
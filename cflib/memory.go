@@ -0,0 +1,226 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cflib
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A MemoryBackend is a backend holding the records of its zones in memory.
+// It behaves like the Cloudflare API closely enough to test code using a
+// Client without network access.
+type MemoryBackend struct {
+	mu      sync.Mutex
+	zones   map[string]string       // name -> ID
+	records map[string]memoryRecord // ID -> record
+	nextID  int
+}
+
+type memoryRecord struct {
+	zoneID string
+	cloudflare.DNSRecord
+}
+
+// NewMemoryBackend returns an in-memory backend holding the named zones,
+// each initially empty.
+func NewMemoryBackend(zones ...string) *MemoryBackend {
+	b := &MemoryBackend{
+		zones:   make(map[string]string),
+		records: make(map[string]memoryRecord),
+	}
+	for _, z := range zones {
+		b.zones[NormalizeName(z)] = b.newID()
+	}
+	return b
+}
+
+func (b *MemoryBackend) newID() string {
+	b.nextID++
+	return fmt.Sprintf("%032x", b.nextID)
+}
+
+// Records returns all records held by the backend, sorted by name and
+// type.
+func (b *MemoryBackend) Records() []cloudflare.DNSRecord {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	recs := make([]cloudflare.DNSRecord, 0, len(b.records))
+	for _, r := range b.records {
+		recs = append(recs, r.DNSRecord)
+	}
+	sortRecords(recs)
+	return recs
+}
+
+func sortRecords(recs []cloudflare.DNSRecord) {
+	sort.Slice(recs, func(i, j int) bool {
+		a, b := recs[i], recs[j]
+		switch {
+		case a.Name != b.Name:
+			return a.Name < b.Name
+		case a.Type != b.Type:
+			return a.Type < b.Type
+		default:
+			return a.ID < b.ID
+		}
+	})
+}
+
+func (b *MemoryBackend) checkZone(zoneID string) error {
+	for _, id := range b.zones {
+		if id == zoneID {
+			return nil
+		}
+	}
+	return notFound("Invalid zone identifier")
+}
+
+// notFound returns an error resembling the API's response to a request for
+// a resource that does not exist.
+func notFound(msg string) error {
+	return &cloudflare.Error{
+		StatusCode:    http.StatusNotFound,
+		Errors:        []cloudflare.ResponseInfo{{Code: 81044, Message: msg}},
+		ErrorCodes:    []int{81044},
+		ErrorMessages: []string{msg},
+	}
+}
+
+func (b *MemoryBackend) ZoneIDByName(name string) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if id, ok := b.zones[NormalizeName(name)]; ok {
+		return id, nil
+	}
+	return "", errors.New("zone could not be found")
+}
+
+func (b *MemoryBackend) ListDNSRecords(ctx context.Context, zoneID string,
+	params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.checkZone(zoneID); err != nil {
+		return nil, err
+	}
+
+	var recs []cloudflare.DNSRecord
+	for _, r := range b.records {
+		switch {
+		case r.zoneID != zoneID:
+		case params.Type != "" && !strings.EqualFold(r.Type, params.Type):
+		case params.Name != "" && NormalizeName(r.Name) != NormalizeName(params.Name):
+		case params.Content != "" && r.Content != params.Content:
+		default:
+			recs = append(recs, r.DNSRecord)
+		}
+	}
+	sortRecords(recs)
+	return recs, nil
+}
+
+func (b *MemoryBackend) GetDNSRecord(ctx context.Context, zoneID, id string) (cloudflare.DNSRecord, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, ok := b.records[id]
+	if !ok || m.zoneID != zoneID {
+		return cloudflare.DNSRecord{}, notFound("Record does not exist.")
+	}
+	return m.DNSRecord, nil
+}
+
+func (b *MemoryBackend) CreateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.CreateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err := b.checkZone(zoneID); err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+
+	r := cloudflare.DNSRecord{
+		ID:       b.newID(),
+		Type:     strings.ToUpper(params.Type),
+		Name:     NormalizeName(params.Name),
+		Content:  params.Content,
+		TTL:      params.TTL,
+		Proxied:  params.Proxied,
+		Priority: params.Priority,
+		Data:     params.Data,
+		Comment:  params.Comment,
+		Tags:     params.Tags,
+	}
+	if r.TTL == 0 {
+		r.TTL = TTLAuto
+	}
+	b.records[r.ID] = memoryRecord{zoneID, r}
+	return r, nil
+}
+
+func (b *MemoryBackend) UpdateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	m, ok := b.records[params.ID]
+	if !ok || m.zoneID != zoneID {
+		return cloudflare.DNSRecord{}, notFound("Record does not exist.")
+	}
+	r := m.DNSRecord
+	if params.Type != "" {
+		r.Type = strings.ToUpper(params.Type)
+	}
+	if params.Name != "" {
+		r.Name = NormalizeName(params.Name)
+	}
+	if params.Content != "" {
+		r.Content = params.Content
+	}
+	if params.TTL != 0 {
+		r.TTL = params.TTL
+	}
+	if params.Proxied != nil {
+		r.Proxied = params.Proxied
+	}
+	if params.Priority != nil {
+		r.Priority = params.Priority
+	}
+	if params.Data != nil {
+		r.Data = params.Data
+	}
+	if params.Comment != nil {
+		r.Comment = *params.Comment
+	}
+	if params.Tags != nil {
+		r.Tags = params.Tags
+	}
+	b.records[r.ID] = memoryRecord{zoneID, r}
+	return r, nil
+}
+
+func (b *MemoryBackend) DeleteDNSRecord(ctx context.Context, zoneID, id string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if m, ok := b.records[id]; !ok || m.zoneID != zoneID {
+		return notFound("Record does not exist.")
+	}
+	delete(b.records, id)
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package cflib
+
+import (
+	"net/netip"
+	"strings"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// The functions in this file reduce DNS record fields to a canonical form,
+// so that records which are semantically identical compare as equal even
+// when they are written differently.
+
+// NormalizeName returns the canonical form of a DNS name: lowercase and
+// without a trailing dot.
+func NormalizeName(name string) string {
+	return strings.TrimSuffix(strings.ToLower(strings.TrimSpace(name)), ".")
+}
+
+// NormalizeContent returns the canonical form of a record's content for
+// the given record type.
+func NormalizeContent(recType, content string) string {
+	content = strings.TrimSpace(content)
+	switch strings.ToUpper(recType) {
+	case "CNAME", "NS", "MX", "PTR", "DNAME":
+		return NormalizeName(content)
+	case "A", "AAAA":
+		return CanonicalIP(content)
+	case "TXT", "SPF":
+		return NormalizeTXT(content)
+	default:
+		return content
+	}
+}
+
+// NormalizeTXT canonicalizes the quoting of TXT record content. Content
+// consisting of one or more quoted character-strings, such as
+// "v=spf1 " "-all", is reduced to the unquoted concatenation of the
+// strings, with their escapes decoded. Unquoted content is returned
+// unchanged.
+func NormalizeTXT(content string) string {
+	if !strings.HasPrefix(content, `"`) {
+		return content
+	}
+
+	var b strings.Builder
+	s := content
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " \t")
+		if len(s) == 0 {
+			break
+		}
+		if s[0] != '"' {
+			return content
+		}
+
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if c, ok := decimalEscape(s[i:]); ok {
+					b.WriteByte(c)
+					i += 2
+					continue
+				}
+			}
+			b.WriteByte(s[i])
+		}
+		if i >= len(s) {
+			return content
+		}
+		s = s[i+1:]
+	}
+	return b.String()
+}
+
+// decimalEscape decodes the three decimal digits following a backslash in
+// a zone file character-string, such as \059 for a semicolon.
+func decimalEscape(s string) (byte, bool) {
+	if len(s) < 3 {
+		return 0, false
+	}
+	n := 0
+	for _, c := range []byte(s[:3]) {
+		if c < '0' || c > '9' {
+			return 0, false
+		}
+		n = n*10 + int(c-'0')
+	}
+	if n > 255 {
+		return 0, false
+	}
+	return byte(n), true
+}
+
+// CanonicalIP returns the canonical text form of an IP address, as
+// described for IPv6 addresses by RFC 5952: lowercase hexadecimal, leading
+// zeros suppressed and the longest run of zero fields compressed to "::".
+// IPv4-mapped IPv6 addresses keep their IPv6 form, so that they remain
+// valid AAAA content. Strings that are not valid IP addresses are returned
+// unchanged.
+func CanonicalIP(s string) string {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return s
+	}
+	return addr.String()
+}
+
+// NormalizeTTL returns the canonical TTL value, treating 0 (unspecified)
+// the same as automatic.
+func NormalizeTTL(ttl int) int {
+	if ttl == 0 {
+		return TTLAuto
+	}
+	return ttl
+}
+
+// ContentEqual reports whether two content strings of the given record
+// type are semantically identical.
+func ContentEqual(recType, a, b string) bool {
+	return NormalizeContent(recType, a) == NormalizeContent(recType, b)
+}
+
+// RecordsEqual reports whether two records have the same type, name,
+// content and TTL once normalized.
+func RecordsEqual(a, b cloudflare.DNSRecord) bool {
+	return strings.EqualFold(a.Type, b.Type) &&
+		NormalizeName(a.Name) == NormalizeName(b.Name) &&
+		ContentEqual(a.Type, a.Content, b.Content) &&
+		NormalizeTTL(a.TTL) == NormalizeTTL(b.TTL)
+}
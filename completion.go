@@ -0,0 +1,290 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cmd"
+)
+
+// A completionCommand describes what the shell completion scripts offer
+// for a command: its flags, the subcommands it accepts as its first
+// argument, and whether its first argument is a record type. They are
+// taken from the command's usage text, so that the scripts follow the
+// commands as they change. Whether a flag takes a value comes from the
+// flags the command registers, or else from the usage text writing it
+// as --flag <value>.
+type completionCommand struct {
+	name       string
+	brief      string
+	flags      []string
+	valueFlags []string // flags taking a value
+	subs       []string
+	typed      bool
+}
+
+var (
+	usageFlag       = regexp.MustCompile(`--([a-z][a-z0-9-]*)( <[^>]*>)?`)
+	usageSubcommand = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
+)
+
+func cmdCompletion(c *cmd.Command, args []string) error {
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "bash":
+		writeBashCompletion(output, completionCommands())
+	case "zsh":
+		// Zsh runs bash completion functions through bashcompinit.
+		fmt.Fprintln(output, "#compdef cf")
+		fmt.Fprintln(output, "autoload -U +X bashcompinit && bashcompinit")
+		writeBashCompletion(output, completionCommands())
+	case "fish":
+		writeFishCompletion(output, completionCommands())
+	default:
+		return usageError(c)
+	}
+	return nil
+}
+
+// completionCommands returns the completion details of every command.
+func completionCommands() []completionCommand {
+	var out []completionCommand
+	for _, c := range cmds.Commands() {
+		cc := completionCommand{name: c.Name, brief: c.Brief, typed: typedCommands[c.Name]}
+		seen := make(map[string]bool)
+		for _, m := range usageFlag.FindAllStringSubmatch(c.Usage, -1) {
+			if !seen[m[1]] {
+				seen[m[1]] = true
+				cc.flags = append(cc.flags, m[1])
+				if value, ok := registeredFlag(c.Name, m[1]); value || !ok && m[2] != "" {
+					cc.valueFlags = append(cc.valueFlags, m[1])
+				}
+			}
+		}
+		for _, alt := range strings.Split(c.Usage, " | ") {
+			f := strings.Fields(alt)
+			if len(f) > 1 && f[0] == c.Name && usageSubcommand.MatchString(f[1]) && !seen[f[1]] {
+				seen[f[1]] = true
+				cc.subs = append(cc.subs, f[1])
+			}
+		}
+		out = append(out, cc)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].name < out[j].name })
+	return out
+}
+
+// A helpCommand is a command as described by "help --json", for tools that
+// wrap, document or complete cf.
+type helpCommand struct {
+	Name        string     `json:"name"`
+	Brief       string     `json:"brief,omitempty"`
+	Description string     `json:"description"`
+	Usage       string     `json:"usage"`
+	Shortcuts   []string   `json:"shortcuts,omitempty"`
+	Subcommands []string   `json:"subcommands,omitempty"`
+	Flags       []helpFlag `json:"flags,omitempty"`
+	RecordType  bool       `json:"record_type_argument,omitempty"`
+}
+
+// A helpFlag is a flag of a command, and whether it takes a value.
+type helpFlag struct {
+	Name  string `json:"name"`
+	Value bool   `json:"value"`
+}
+
+// writeHelpJSON writes the command tree as JSON: the leading flags and
+// every command, or only the named commands if any are given.
+func writeHelpJSON(w io.Writer, names []string) error {
+	var help struct {
+		Version  string        `json:"version"`
+		Flags    []helpFlag    `json:"leading_flags"`
+		Commands []helpCommand `json:"commands"`
+	}
+	help.Version = buildVersion()
+	flags, valueFlags := leadingFlagNames()
+	help.Flags = helpFlags(flags, valueFlags)
+
+	details := make(map[string]completionCommand)
+	for _, cc := range completionCommands() {
+		details[cc.name] = cc
+	}
+	for _, c := range cmds.Commands() {
+		if len(names) > 0 && !isOneOf(c.Name, names) {
+			continue
+		}
+		cc := details[c.Name]
+		help.Commands = append(help.Commands, helpCommand{
+			Name:        c.Name,
+			Brief:       c.Brief,
+			Description: c.Description,
+			Usage:       c.Usage,
+			Shortcuts:   c.Shortcuts(),
+			Subcommands: cc.subs,
+			Flags:       helpFlags(cc.flags, cc.valueFlags),
+			RecordType:  cc.typed,
+		})
+	}
+	sort.Slice(help.Commands, func(i, j int) bool { return help.Commands[i].Name < help.Commands[j].Name })
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(help)
+}
+
+func helpFlags(flags, valueFlags []string) []helpFlag {
+	var out []helpFlag
+	for _, f := range flags {
+		out = append(out, helpFlag{Name: f, Value: isOneOf(f, valueFlags)})
+	}
+	return out
+}
+
+// leadingFlagNames returns the names of the leading flags, and of those
+// taking a value.
+func leadingFlagNames() (flags, valueFlags []string) {
+	for name, takesValue := range leadingFlags {
+		flags = append(flags, name)
+		if takesValue {
+			valueFlags = append(valueFlags, name)
+		}
+	}
+	sort.Strings(flags)
+	sort.Strings(valueFlags)
+	return flags, valueFlags
+}
+
+func dashed(flags []string) string {
+	var s []string
+	for _, f := range flags {
+		s = append(s, "--"+f)
+	}
+	return strings.Join(s, " ")
+}
+
+func writeBashCompletion(w io.Writer, commands []completionCommand) {
+	flags, valueFlags := leadingFlagNames()
+	var names []string
+	for _, c := range commands {
+		names = append(names, c.name)
+	}
+
+	fmt.Fprintf(w, `# bash completion for cf, generated by "cf completion bash".
+
+_cf_spec() {
+	flags= values= subs= typed=
+	case $1 in
+	"") flags=%q values=%q ;;
+`, dashed(flags), strings.Join(valueFlags, " "))
+	for _, c := range commands {
+		typed := ""
+		if c.typed {
+			typed = "1"
+		}
+		fmt.Fprintf(w, "\t%s) flags=%q values=%q subs=%q typed=%q ;;\n",
+			c.name, dashed(c.flags), strings.Join(c.valueFlags, " "), strings.Join(c.subs, " "), typed)
+	}
+	fmt.Fprintf(w, `	esac
+}
+
+_cf() {
+	local cur=${COMP_WORDS[COMP_CWORD]}
+	local i w f cmd= nargs=0 skip= flags values subs typed
+	for ((i = 1; i < COMP_CWORD; i++)); do
+		w=${COMP_WORDS[i]}
+		if [[ -n $skip ]]; then
+			skip=
+			continue
+		fi
+		case $w in
+		--*=*) ;;
+		-*)
+			_cf_spec "$cmd"
+			f=${w#-}
+			f=${f#-}
+			[[ " $values " == *" $f "* ]] && skip=1
+			;;
+		*)
+			if [[ -z $cmd ]]; then cmd=$w; else ((nargs++)); fi
+			;;
+		esac
+	done
+
+	_cf_spec "$cmd"
+	if [[ -n $skip ]]; then
+		COMPREPLY=($(compgen -f -- "$cur"))
+	elif [[ $cur == -* ]]; then
+		COMPREPLY=($(compgen -W "$flags" -- "$cur"))
+	elif [[ -z $cmd || ($cmd == help && $nargs == 0) ]]; then
+		COMPREPLY=($(compgen -W %q -- "$cur"))
+	elif [[ $nargs == 0 && -n $typed ]]; then
+		COMPREPLY=($(compgen -W %q -- "$cur"))
+	elif [[ $nargs == 0 && -n $subs ]]; then
+		COMPREPLY=($(compgen -W "$subs" -- "$cur"))
+	fi
+}
+
+complete -F _cf cf
+`, strings.Join(names, " "), strings.Join(recordTypes, " "))
+}
+
+func writeFishCompletion(w io.Writer, commands []completionCommand) {
+	quote := func(s string) string {
+		return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+	}
+	flagLine := func(cond, flag string, takesValue bool) {
+		r := ""
+		if takesValue {
+			r = " -r -F"
+		}
+		fmt.Fprintf(w, "complete -c cf -n %s -l %s%s\n", quote(cond), flag, r)
+	}
+
+	fmt.Fprintln(w, `# fish completion for cf, generated by "cf completion fish".`)
+	fmt.Fprintln(w)
+	fmt.Fprintln(w, "complete -c cf -f")
+
+	flags, _ := leadingFlagNames()
+	for _, f := range flags {
+		flagLine("__fish_use_subcommand", f, leadingFlags[f])
+	}
+
+	for _, c := range commands {
+		fmt.Fprintf(w, "complete -c cf -n __fish_use_subcommand -a %s -d %s\n", c.name, quote(c.brief))
+	}
+
+	for _, c := range commands {
+		seen := "__fish_seen_subcommand_from " + c.name
+		first := seen + "; and test (count (commandline -opc)) -eq 2"
+		values := make(map[string]bool)
+		for _, f := range c.valueFlags {
+			values[f] = true
+		}
+		for _, f := range c.flags {
+			flagLine(seen, f, values[f])
+		}
+		switch {
+		case c.typed:
+			fmt.Fprintf(w, "complete -c cf -n %s -a %s\n", quote(first), quote(strings.Join(recordTypes, " ")))
+		case c.name == "help":
+			var names []string
+			for _, c := range commands {
+				names = append(names, c.name)
+			}
+			fmt.Fprintf(w, "complete -c cf -n %s -a %s\n", quote(first), quote(strings.Join(names, " ")))
+		case len(c.subs) > 0:
+			fmt.Fprintf(w, "complete -c cf -n %s -a %s\n", quote(first), quote(strings.Join(c.subs, " ")))
+		}
+	}
+}
@@ -0,0 +1,253 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/beevik/cmd"
+)
+
+// A profile is a named set of credentials and preferences stored in the
+// configuration file.
+type profile struct {
+	Email  string `json:"email,omitempty"`
+	Key    string `json:"key,omitempty"`
+	Token  string `json:"token,omitempty"`
+	Zone   string `json:"zone,omitempty"`
+	ZoneID string `json:"zone_id,omitempty"`
+	Output string `json:"output,omitempty"`
+	Owner  string `json:"owner,omitempty"`
+}
+
+// config holds the contents of the configuration file.
+type config struct {
+	DefaultProfile string              `json:"default_profile,omitempty"`
+	Locale         string              `json:"locale,omitempty"`
+	Profiles       map[string]*profile `json:"profiles,omitempty"`
+	Freezes        []freezeWindow      `json:"freezes,omitempty"`
+	AuditLog       string              `json:"audit_log,omitempty"`
+	IPSources      []string            `json:"ip_sources,omitempty"`
+	DDNS           []ddnsZone          `json:"ddns,omitempty"`
+	DDNSClients    []ddnsClient        `json:"ddns_clients,omitempty"`
+	Failover       []failoverRecord    `json:"failover,omitempty"`
+	Pinned         []pinnedRecord      `json:"pinned,omitempty"`
+	TTLPolicies    []ttlPolicy         `json:"ttl_policies,omitempty"`
+	ProxiedZones   []string            `json:"proxied_zones,omitempty"`
+	Protected      []protectedRecord   `json:"protected,omitempty"`
+	TOTPZones      []string            `json:"totp_zones,omitempty"`
+	Hooks          []hook              `json:"hooks,omitempty"`
+	CacheTTL       string              `json:"cache_ttl,omitempty"`
+	DiskCache      bool                `json:"disk_cache,omitempty"`
+	ZoneLocks      bool                `json:"zone_locks,omitempty"`
+}
+
+var (
+	cfg               = &config{}
+	activeProfile     *profile
+	activeProfileName string
+	outputFormat      = "text"
+)
+
+// configPath returns the location of the configuration file. It may be
+// overridden with the CLOUDFLARE_CONFIG environment variable.
+func configPath() string {
+	if p := os.Getenv("CLOUDFLARE_CONFIG"); p != "" {
+		return p
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "cf", "config.json")
+}
+
+// loadConfig reads the configuration file. A missing file is not an error
+// and results in an empty configuration.
+func loadConfig() error {
+	path := configPath()
+	if path == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	var c config
+	doc, err := decodeJSON(data, &c)
+	if err != nil {
+		return fmt.Errorf("%s: %v", path, err)
+	}
+	for i := range c.Freezes {
+		if err := c.Freezes[i].parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("freezes[%d]", i), "%v", err))
+		}
+	}
+	for i, spec := range c.IPSources {
+		if _, err := parseIPSource(spec); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("ip_sources[%d]", i), "%v", err))
+		}
+	}
+	for i := range c.Failover {
+		if err := c.Failover[i].parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("failover[%d]", i), "%v", err))
+		}
+	}
+	for i := range c.Pinned {
+		if err := c.Pinned[i].parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("pinned[%d]", i), "%v", err))
+		}
+	}
+	for i := range c.TTLPolicies {
+		if err := c.TTLPolicies[i].parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("ttl_policies[%d]", i), "%v", err))
+		}
+	}
+	for i := range c.Hooks {
+		if err := c.Hooks[i].parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("hooks[%d]", i), "%v", err))
+		}
+	}
+	for i := range c.DDNSClients {
+		if err := c.DDNSClients[i].parse(); err != nil {
+			return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("ddns_clients[%d]", i), "%v", err))
+		}
+	}
+	for i, z := range c.DDNS {
+		for j, name := range z.Names {
+			if !inZone(name, z.Zone) {
+				return fmt.Errorf("%s: %v", path, doc.errorf(fmt.Sprintf("ddns[%d].names[%d]", i, j),
+					"record %s is not in zone %s", name, z.Zone))
+			}
+		}
+	}
+	cfg = &c
+	return nil
+}
+
+// selectProfile makes the named profile active. An empty name selects the
+// configuration's default profile, if any. Any existing API connection and
+// active zone are discarded.
+func selectProfile(name string) error {
+	if name == "" {
+		name = cfg.DefaultProfile
+		if name == "" {
+			return nil
+		}
+	}
+
+	p, ok := cfg.Profiles[name]
+	if !ok {
+		return fmt.Errorf("profile %q not found", name)
+	}
+
+	switch p.Output {
+	case "":
+		outputFormat = "text"
+	case "text", "json":
+		outputFormat = p.Output
+	default:
+		return fmt.Errorf("profile %q has invalid output format %q", name, p.Output)
+	}
+
+	switch {
+	case p.ZoneID != "" && !zoneIDPattern.MatchString(p.ZoneID):
+		return fmt.Errorf("profile %q has invalid zone_id %q", name, p.ZoneID)
+	case p.ZoneID != "" && p.Zone == "":
+		return fmt.Errorf("profile %q has a zone_id but no zone", name)
+	}
+
+	if p.Owner != "" {
+		if err := validateOwner(p.Owner); err != nil {
+			return fmt.Errorf("profile %q has invalid owner %q", name, p.Owner)
+		}
+	}
+
+	activeProfile = p
+	activeProfileName = name
+	ownerID = p.Owner
+	activeAPI = nil
+	activeZoneIdentifier = nil
+	activeZoneName = ""
+	resumedZone = ""
+	return nil
+}
+
+// reloadConfig rereads the configuration file and reselects the active
+// profile, so that the next API connection uses the current credentials.
+// The zone is resolved again as at startup. If the configuration cannot be
+// loaded, the previous configuration remains active.
+func reloadConfig() error {
+	prevCfg, prevProfile, prevName := cfg, activeProfile, activeProfileName
+	prevOwner, prevOutput := ownerID, outputFormat
+	prevAPI, prevZoneID, prevZone := activeAPI, activeZoneIdentifier, activeZoneName
+	restore := func() {
+		cfg, activeProfile, activeProfileName = prevCfg, prevProfile, prevName
+		ownerID, outputFormat = prevOwner, prevOutput
+		activeAPI, activeZoneIdentifier, activeZoneName = prevAPI, prevZoneID, prevZone
+	}
+
+	cfg = &config{}
+	if err := loadConfig(); err != nil {
+		restore()
+		return err
+	}
+	activeAPI, activeZoneIdentifier, activeZoneName = nil, nil, ""
+	if err := selectProfile(prevName); err != nil {
+		restore()
+		return err
+	}
+	if _, err := getAPI(); err != nil {
+		restore()
+		return err
+	}
+	if _, err := getZoneIdentifier(); err != nil {
+		restore()
+		return err
+	}
+	return nil
+}
+
+func cmdProfile(c *cmd.Command, args []string) error {
+	switch len(args) {
+	case 0:
+		if len(cfg.Profiles) == 0 {
+			printf("No profiles defined in %s.\n", configPath())
+			return nil
+		}
+		var names []string
+		for name := range cfg.Profiles {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			marker := " "
+			if name == activeProfileName {
+				marker = "*"
+			}
+			fmt.Fprintf(output, "%s %s\n", marker, name)
+		}
+
+	case 1:
+		if err := selectProfile(args[0]); err != nil {
+			return err
+		}
+		printf("Active profile set to %s.\n", args[0])
+
+	default:
+		return usageError(c)
+	}
+	return nil
+}
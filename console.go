@@ -0,0 +1,286 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/term"
+)
+
+// maxHistory is the number of command lines recalled from earlier sessions.
+// It matches the size of the terminal's history buffer.
+const maxHistory = 100
+
+// completionTTL is how long the record names of a zone are cached for tab
+// completion.
+const completionTTL = 30 * time.Second
+
+// recordTypes are the DNS record types offered by tab completion.
+var recordTypes = []string{
+	"A", "AAAA", "CAA", "CERT", "CNAME", "DNSKEY", "DS", "HTTPS", "LOC", "MX",
+	"NAPTR", "NS", "PTR", "SMIMEA", "SRV", "SSHFP", "SVCB", "TLSA", "TXT", "URI",
+}
+
+// typedCommands are the commands whose first argument is a record type and
+// whose second argument is a record name.
+var typedCommands = map[string]bool{
+	"add": true, "delete": true, "exists": true, "get": true, "list": true, "rename": true, "show": true, "ttl": true,
+}
+
+// namedCommands are the commands whose first argument is a record name.
+var namedCommands = map[string]bool{
+	"cname": true, "ddns": true, "ip4": true, "ip6": true, "txt": true,
+}
+
+// A console reads lines from an interactive terminal, providing line
+// editing, a command history that persists across sessions, and tab
+// completion.
+type console struct {
+	fd      int
+	io      *consoleIO
+	term    *term.Terminal
+	history string
+
+	names        []string
+	namesZone    string
+	namesExpires time.Time
+}
+
+// consoleIO is the reader and writer used by a console's terminal. Its
+// reader and writer are swapped while the history is being loaded.
+type consoleIO struct {
+	r io.Reader
+	w io.Writer
+}
+
+func (c *consoleIO) Read(p []byte) (int, error)  { return c.r.Read(p) }
+func (c *consoleIO) Write(p []byte) (int, error) { return c.w.Write(p) }
+
+// activeConsole is the console used for interactive input, or nil if
+// standard input is not a terminal.
+var activeConsole *console
+
+// newConsole creates a console for standard input and loads the history
+// file. It returns nil if standard input is not a terminal.
+func newConsole() *console {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return nil
+	}
+
+	c := &console{
+		fd: fd,
+		io: &consoleIO{r: os.Stdin, w: os.Stdout},
+	}
+	c.term = term.NewTerminal(c.io, "")
+	c.term.AutoCompleteCallback = c.autocomplete
+
+	if path, err := statePath("history"); err == nil {
+		if home, err := os.UserHomeDir(); err == nil {
+			migrateStateFile(filepath.Join(home, ".cf_history"), path)
+		}
+		c.history = path
+		c.loadHistory()
+	}
+	return c
+}
+
+// loadHistory replays the most recent lines of the history file through
+// the terminal, which is the only way to add entries to its history.
+func (c *console) loadHistory() {
+	file, err := os.Open(c.history)
+	if err != nil {
+		return
+	}
+	defer file.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	if len(lines) > maxHistory {
+		lines = lines[len(lines)-maxHistory:]
+	}
+
+	c.io.r = strings.NewReader(strings.Join(lines, "\r") + "\r")
+	c.io.w = io.Discard
+	for range lines {
+		if _, err := c.term.ReadLine(); err != nil {
+			break
+		}
+	}
+	c.io.r, c.io.w = os.Stdin, os.Stdout
+}
+
+// addHistory appends a command line to the history file.
+func (c *console) addHistory(line string) {
+	if c.history == "" || strings.TrimSpace(line) == "" {
+		return
+	}
+	withStateLock(func() error {
+		file, err := os.OpenFile(c.history, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+		_, err = file.WriteString(strings.ReplaceAll(line, "\n", " ") + "\n")
+		return err
+	})
+}
+
+// readLine displays the prompt and reads an edited line of input.
+func (c *console) readLine(prompt string) (string, error) {
+	state, err := term.MakeRaw(c.fd)
+	if err != nil {
+		return "", err
+	}
+	defer term.Restore(c.fd, state)
+
+	if w, h, err := term.GetSize(c.fd); err == nil && w > 0 {
+		c.term.SetSize(w, h)
+	}
+
+	c.term.SetPrompt(prompt)
+	line, err := c.term.ReadLine()
+	if err == term.ErrPasteIndicator {
+		err = nil
+	}
+	return line, err
+}
+
+// autocomplete is the terminal's key press callback. On a tab key press, it
+// completes the word under the cursor.
+func (c *console) autocomplete(line string, pos int, key rune) (string, int, bool) {
+	if key != '\t' {
+		return "", 0, false
+	}
+
+	head := line[:pos]
+	start := strings.LastIndexAny(head, " \t") + 1
+	word := head[start:]
+	fields := strings.Fields(head[:start])
+
+	var candidates []string
+	if len(fields) == 0 {
+		candidates = cmds.Autocomplete(word)
+	} else {
+		candidates = filterPrefix(c.argumentCandidates(fields), word)
+	}
+
+	switch len(candidates) {
+	case 0:
+		return "", 0, false
+	case 1:
+		completion := candidates[0] + " "
+		return head[:start] + completion + line[pos:], start + len(completion), true
+	}
+
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(word) {
+		return head[:start] + prefix + line[pos:], start + len(prefix), true
+	}
+
+	sort.Strings(candidates)
+	c.term.Write([]byte(strings.Join(candidates, "  ") + "\n"))
+	return "", 0, false
+}
+
+// argumentCandidates returns the possible values of the next argument of a
+// partially entered command.
+func (c *console) argumentCandidates(fields []string) []string {
+	n, _, err := cmds.Lookup(fields[0])
+	if err != nil {
+		return nil
+	}
+	cm, ok := n.(*cmd.Command)
+	if !ok {
+		return nil
+	}
+
+	var args []string
+	for _, f := range fields[1:] {
+		if !strings.HasPrefix(f, "-") {
+			args = append(args, f)
+		}
+	}
+
+	switch {
+	case cm.Name == "help" && len(args) == 0:
+		return cmds.Autocomplete("")
+	case typedCommands[cm.Name] && len(args) == 0:
+		return recordTypes
+	case typedCommands[cm.Name] && len(args) == 1:
+		return c.recordNames()
+	case namedCommands[cm.Name] && len(args) == 0:
+		return c.recordNames()
+	}
+	return nil
+}
+
+// recordNames returns the names of the records in the active zone. Names
+// are only available once credentials and a zone have been established, so
+// completion never prompts for input.
+func (c *console) recordNames() []string {
+	if activeAPI == nil || activeZoneIdentifier == nil {
+		return nil
+	}
+	zoneID := activeZoneIdentifier.Identifier
+	if c.namesZone == zoneID && time.Now().Before(c.namesExpires) {
+		return c.names
+	}
+
+	recs, err := listRecords(activeAPI, activeZoneIdentifier, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return nil
+	}
+
+	seen := make(map[string]bool)
+	c.names = c.names[:0]
+	for _, r := range recs {
+		if !seen[r.Name] {
+			seen[r.Name] = true
+			c.names = append(c.names, r.Name)
+		}
+	}
+	c.namesZone = zoneID
+	c.namesExpires = time.Now().Add(completionTTL)
+	return c.names
+}
+
+// filterPrefix returns the candidates beginning with prefix, ignoring case.
+func filterPrefix(candidates []string, prefix string) []string {
+	var matches []string
+	for _, s := range candidates {
+		if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+			matches = append(matches, s)
+		}
+	}
+	return matches
+}
+
+// commonPrefix returns the longest prefix shared by all of the strings.
+func commonPrefix(s []string) string {
+	prefix := s[0]
+	for _, t := range s[1:] {
+		i := 0
+		for i < len(prefix) && i < len(t) && prefix[i] == t[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+	return prefix
+}
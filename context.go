@@ -0,0 +1,91 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"time"
+)
+
+// defaultRequestTimeout is the default time limit of a single API request.
+const defaultRequestTimeout = 30 * time.Second
+
+// requestTimeout is the time limit of a single API request. Zero means no
+// limit.
+var requestTimeout = defaultRequestTimeout
+
+// commandCtx is the context of the command being processed. It is canceled
+// when the user presses Ctrl-C, abandoning the command's requests in
+// flight.
+var commandCtx = context.Background()
+
+// parseTimeout validates a request timeout argument, which is a duration
+// such as 30s, or "off" to disable the limit.
+func parseTimeout(s string) (time.Duration, error) {
+	if s == "off" {
+		return 0, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, argError(fmt.Errorf("invalid timeout %q", s))
+	}
+	return d, nil
+}
+
+// formatTimeout formats a request timeout for display.
+func formatTimeout(d time.Duration) string {
+	if d == 0 {
+		return "off"
+	}
+	return d.String()
+}
+
+// runInterruptible runs a command handler with commandCtx set to a context
+// canceled by Ctrl-C. An interrupted handler returns errInterrupted, so
+// that interactive mode returns to the prompt instead of exiting.
+func runInterruptible(fn func() error) error {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	defer signal.Stop(interrupt)
+
+	go func() {
+		select {
+		case <-interrupt:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	commandCtx = ctx
+	defer func() { commandCtx = context.Background() }()
+	forgetResponses()
+
+	err := fn()
+	if err != nil && ctx.Err() != nil {
+		return errInterrupted
+	}
+	return err
+}
+
+// sleep pauses the running command for a duration. It returns
+// errInterrupted if the command is interrupted first. The API is asked
+// again for the responses cached before the pause.
+func sleep(d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		refreshResponses()
+		return nil
+	case <-commandCtx.Done():
+		return errInterrupted
+	}
+}
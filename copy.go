@@ -0,0 +1,144 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var copyFlags = registerFlags("copy", flagSpec{"all": false})
+
+func cmdCopy(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, copyFlags)
+	if err != nil {
+		return err
+	}
+	if (flags.has("all") && len(args) != 1) || (!flags.has("all") && len(args) != 3) {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	srcZone := activeZoneName
+
+	targetZone := args[len(args)-1]
+	targetID, err := api.ZoneIDByName(targetZone)
+	if err != nil {
+		return zoneError(err)
+	}
+	target := cloudflare.ZoneIdentifier(targetID)
+
+	params := cloudflare.ListDNSRecordsParams{}
+	if !flags.has("all") {
+		params.Type = strings.ToUpper(args[0])
+		params.Name = args[1]
+	}
+	recs, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+	if len(recs) < 1 {
+		return errNoMatch
+	}
+
+	existing, err := listRecords(api, target, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+
+	var copies []cloudflare.DNSRecord
+	var ops []operation
+	for _, r := range recs {
+		r.Name = rezoneName(r.Name, srcZone, targetZone)
+		switch r.Type {
+		case "CNAME", "MX", "NS", "PTR", "DNAME":
+			r.Content = rezoneName(r.Content, srcZone, targetZone)
+		}
+
+		// The zone apex's nameservers are assigned by Cloudflare.
+		if r.Type == "NS" && cflib.NormalizeName(r.Name) == cflib.NormalizeName(targetZone) {
+			continue
+		}
+		if containsRecord(existing, r) {
+			printf("%s record %s already exists.\n", r.Type, r.Name)
+			continue
+		}
+
+		params := cloudflare.CreateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			Data:     r.Data,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Comment:  r.Comment,
+		}
+		copies = append(copies, r)
+		ops = append(ops, operation{
+			key: recordKey(targetID, r.Name),
+			fn: func() error {
+				_, err := recordBackend(api).CreateDNSRecord(commandCtx, target.Identifier, params)
+				return err
+			},
+			change: createChange(target.Identifier, targetZone, params),
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := copies[i]
+		if err != nil {
+			printf("Error copying %s record %s: %v\n", r.Type, r.Name, err)
+			failed++
+			continue
+		}
+		printf("Copied %s record %s.\n", r.Type, r.Name)
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be copied", failed, len(copies))
+	}
+	return nil
+}
+
+// rezoneName moves a name in the source zone to the corresponding name in
+// the target zone. Names outside the source zone are returned unchanged.
+func rezoneName(name, srcZone, targetZone string) string {
+	n := cflib.NormalizeName(name)
+	src := cflib.NormalizeName(srcZone)
+	switch {
+	case n == src:
+		return targetZone
+	case strings.HasSuffix(n, "."+src):
+		return n[:len(n)-len(src)] + targetZone
+	default:
+		return name
+	}
+}
+
+// containsRecord reports whether recs contains a record equal to r.
+func containsRecord(recs []cloudflare.DNSRecord, r cloudflare.DNSRecord) bool {
+	for _, e := range recs {
+		if cflib.RecordsEqual(e, r) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,151 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+type crawlerSettings struct {
+	Hints            bool
+	AIBots           string
+	RobotsTxtManaged bool
+}
+
+func cmdCrawlers(c *cmd.Command, args []string) error {
+	if len(args) != 0 && len(args) != 2 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	if len(args) == 0 {
+		s, err := getCrawlerSettings(api, zoneID)
+		if err != nil {
+			return err
+		}
+		printf("Crawler hints:      %s\n", onOff(s.Hints))
+		printf("AI crawlers:        %s\n", s.AIBots)
+		printf("Managed robots.txt: %s\n", onOff(s.RobotsTxtManaged))
+		return nil
+	}
+
+	switch strings.ToLower(args[0]) {
+	case "hints":
+		var on bool
+		if on, err = parseOnOff(args[1]); err == nil {
+			err = setCrawlerHints(api, zoneID, on)
+		}
+	case "ai":
+		var value string
+		switch strings.ToLower(args[1]) {
+		case "block":
+			value = "block"
+		case "allow":
+			value = "disabled"
+		default:
+			err = fmt.Errorf("AI crawler setting must be block or allow")
+		}
+		if err == nil {
+			_, err = api.UpdateBotManagement(commandCtx, zoneID,
+				cloudflare.UpdateBotManagementParams{AIBotsProtection: &value})
+		}
+	case "robots":
+		var on bool
+		if on, err = parseOnOff(args[1]); err == nil {
+			body := map[string]bool{"is_robots_txt_managed": on}
+			_, err = api.Raw(commandCtx, http.MethodPut,
+				"/zones/"+zoneID.Identifier+"/bot_management", body, nil)
+		}
+	default:
+		return usageError(c)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	printf("Crawler settings updated.\n")
+	return nil
+}
+
+func getCrawlerSettings(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) (crawlerSettings, error) {
+	var s crawlerSettings
+
+	r, err := api.Raw(commandCtx, http.MethodGet,
+		"/zones/"+zoneID.Identifier+"/flags/products/cache/changes", nil, nil)
+	if err != nil {
+		return s, err
+	}
+	var flags struct {
+		Enabled bool `json:"crawlhints_enabled"`
+	}
+	if err := json.Unmarshal(r.Result, &flags); err != nil {
+		return s, err
+	}
+	s.Hints = flags.Enabled
+
+	r, err = api.Raw(commandCtx, http.MethodGet,
+		"/zones/"+zoneID.Identifier+"/bot_management", nil, nil)
+	if err != nil {
+		return s, err
+	}
+	var bm struct {
+		AIBotsProtection string `json:"ai_bots_protection"`
+		RobotsTxtManaged bool   `json:"is_robots_txt_managed"`
+	}
+	if err := json.Unmarshal(r.Result, &bm); err != nil {
+		return s, err
+	}
+
+	s.AIBots = "allow"
+	if bm.AIBotsProtection == "block" {
+		s.AIBots = "block"
+	}
+	s.RobotsTxtManaged = bm.RobotsTxtManaged
+	return s, nil
+}
+
+func setCrawlerHints(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, on bool) error {
+	body := map[string]any{
+		"feature": "crawlhints_enabled",
+		"value":   on,
+	}
+	_, err := api.Raw(commandCtx, http.MethodPatch,
+		"/zones/"+zoneID.Identifier+"/flags/products/cache/changes", body, nil)
+	return err
+}
+
+func parseOnOff(s string) (bool, error) {
+	switch strings.ToLower(s) {
+	case "on", "true", "yes", "enable", "enabled":
+		return true, nil
+	case "off", "false", "no", "disable", "disabled":
+		return false, nil
+	default:
+		return false, fmt.Errorf("expected on or off, got %q", s)
+	}
+}
+
+func onOff(b bool) string {
+	if b {
+		return "on"
+	}
+	return "off"
+}
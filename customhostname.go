@@ -0,0 +1,249 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// customHostnameMethods are the ways the certificate of a custom hostname
+// may be validated.
+var customHostnameMethods = []string{"txt", "http", "email"}
+
+var hostnameFlags = registerFlags("hostname", mergeFlags(forceFlags,
+	flagSpec{"origin": true, "validation": true}))
+
+// cmdHostname manages the custom hostnames of the active zone, the
+// hostnames of a SaaS provider's customers that the zone serves.
+func cmdHostname(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, hostnameFlags)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		return listCustomHostnames()
+	case len(args) == 2 && args[0] == "add":
+		return addCustomHostname(args[1], flags.get("origin", ""), flags.get("validation", "txt"))
+	case len(args) == 2 && args[0] == "show":
+		return showCustomHostname(args[1])
+	case len(args) == 2 && args[0] == "delete":
+		return deleteCustomHostname(args[1], flags.force())
+	default:
+		return usageError(c)
+	}
+}
+
+// customHostnames returns all custom hostnames of a zone, requesting them
+// a page at a time.
+func customHostnames(api *cloudflare.API, zoneID string) ([]cloudflare.CustomHostname, error) {
+	var all []cloudflare.CustomHostname
+	for page := 1; ; page++ {
+		hostnames, info, err := api.CustomHostnames(commandCtx, zoneID, page, cloudflare.CustomHostname{})
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, hostnames...)
+		if len(hostnames) == 0 || page >= info.TotalPages {
+			return all, nil
+		}
+	}
+}
+
+// findCustomHostname returns the custom hostname of a zone with a name.
+func findCustomHostname(api *cloudflare.API, zoneID, hostname string) (cloudflare.CustomHostname, error) {
+	hostnames, _, err := api.CustomHostnames(commandCtx, zoneID, 1, cloudflare.CustomHostname{Hostname: hostname})
+	if err != nil {
+		return cloudflare.CustomHostname{}, err
+	}
+	for _, ch := range hostnames {
+		if cflib.NormalizeName(ch.Hostname) == cflib.NormalizeName(hostname) {
+			return ch, nil
+		}
+	}
+	return cloudflare.CustomHostname{}, fmt.Errorf("no custom hostname %s found", hostname)
+}
+
+func listCustomHostnames() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	hostnames, err := customHostnames(api, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+	if len(hostnames) == 0 {
+		printf("No custom hostnames found.\n")
+		return nil
+	}
+
+	sort.Slice(hostnames, func(i, j int) bool { return hostnames[i].Hostname < hostnames[j].Hostname })
+	width := 0
+	for _, ch := range hostnames {
+		width = max(width, len(ch.Hostname))
+	}
+	for _, ch := range hostnames {
+		fmt.Fprintf(output, "%-*s %-8s certificate %s\n", width, ch.Hostname, ch.Status, customHostnameSSLStatus(ch))
+	}
+	return nil
+}
+
+// customHostnameSSLStatus returns the status of a custom hostname's
+// certificate.
+func customHostnameSSLStatus(ch cloudflare.CustomHostname) string {
+	if ch.SSL == nil || ch.SSL.Status == "" {
+		return "none"
+	}
+	return ch.SSL.Status
+}
+
+// addCustomHostname adds a custom hostname to the active zone, served by
+// its fallback origin or by an origin server given with --origin, and
+// displays the records its owner must create to validate it.
+func addCustomHostname(hostname, origin, method string) error {
+	if err := validateName(hostname, true); err != nil {
+		return argError(err)
+	}
+	if !isOneOf(method, customHostnameMethods) {
+		return argError(fmt.Errorf("invalid validation method %q (valid are txt, http and email)", method))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	if inZone(hostname, activeZoneName) {
+		return argError(fmt.Errorf("%s is in zone %s; custom hostnames belong to other domains", hostname, activeZoneName))
+	}
+
+	resp, err := api.CreateCustomHostname(commandCtx, zoneID.Identifier, cloudflare.CustomHostname{
+		Hostname:           hostname,
+		CustomOriginServer: origin,
+		SSL: &cloudflare.CustomHostnameSSL{
+			Method: method,
+			Type:   "dv",
+		},
+	})
+	if err != nil {
+		return err
+	}
+	printf("Added custom hostname %s.\n", resp.Result.Hostname)
+	printCustomHostnameValidation(resp.Result)
+	return nil
+}
+
+func showCustomHostname(hostname string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	ch, err := findCustomHostname(api, zoneID.Identifier, hostname)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(output, "Hostname:    %s\n", ch.Hostname)
+	fmt.Fprintf(output, "ID:          %s\n", ch.ID)
+	fmt.Fprintf(output, "Status:      %s\n", ch.Status)
+	fmt.Fprintf(output, "Certificate: %s\n", customHostnameSSLStatus(ch))
+	if ch.CustomOriginServer != "" {
+		fmt.Fprintf(output, "Origin:      %s\n", ch.CustomOriginServer)
+	}
+	for _, e := range ch.VerificationErrors {
+		fmt.Fprintf(output, "Error:       %s\n", e)
+	}
+	if ch.SSL != nil {
+		for _, e := range ch.SSL.ValidationErrors {
+			fmt.Fprintf(output, "Error:       %s\n", e.Message)
+		}
+	}
+	printCustomHostnameValidation(ch)
+	return nil
+}
+
+// printCustomHostnameValidation displays the records and files with which
+// the owner of a custom hostname proves their ownership and validates its
+// certificate, until both are done.
+func printCustomHostnameValidation(ch cloudflare.CustomHostname) {
+	var lines []string
+	if ch.Status != cloudflare.ACTIVE {
+		if v := ch.OwnershipVerification; v.Name != "" {
+			lines = append(lines, fmt.Sprintf("%s %s %s", strings.ToUpper(v.Type), v.Name, v.Value))
+		}
+		if v := ch.OwnershipVerificationHTTP; v.HTTPUrl != "" {
+			lines = append(lines, fmt.Sprintf("HTTP %s %s", v.HTTPUrl, v.HTTPBody))
+		}
+	}
+	if ch.SSL != nil && ch.SSL.Status != "active" {
+		records := ch.SSL.ValidationRecords
+		if len(records) == 0 {
+			records = []cloudflare.SSLValidationRecord{ch.SSL.SSLValidationRecord}
+		}
+		for _, r := range records {
+			switch {
+			case r.TxtName != "":
+				lines = append(lines, fmt.Sprintf("TXT %s %s", r.TxtName, r.TxtValue))
+			case r.CnameName != "":
+				lines = append(lines, fmt.Sprintf("CNAME %s %s", r.CnameName, r.CnameTarget))
+			case r.HTTPUrl != "":
+				lines = append(lines, fmt.Sprintf("HTTP %s %s", r.HTTPUrl, r.HTTPBody))
+			}
+		}
+	}
+	if len(lines) == 0 {
+		return
+	}
+	printf("The hostname's owner must create these records, or serve these files:\n")
+	for _, l := range lines {
+		fmt.Fprintf(output, "    %s\n", l)
+	}
+}
+
+// deleteCustomHostname deletes a custom hostname, after confirmation.
+func deleteCustomHostname(hostname string, force bool) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	ch, err := findCustomHostname(api, zoneID.Identifier, hostname)
+	if err != nil {
+		return err
+	}
+	if !force && !confirm(sprintf("Delete custom hostname %s?", ch.Hostname)) {
+		printf("Custom hostname not deleted.\n")
+		return nil
+	}
+	if err := api.DeleteCustomHostname(commandCtx, zoneID.Identifier, ch.ID); err != nil {
+		return err
+	}
+	printf("Deleted custom hostname %s.\n", ch.Hostname)
+	return nil
+}
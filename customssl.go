@@ -0,0 +1,229 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func cmdSSL(c *cmd.Command, args []string) error {
+	if len(args) == 0 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "upload-cert":
+		return cmdSSLUpload(c, args[1:])
+	case "list-custom":
+		if len(args) == 1 {
+			return cmdSSLList()
+		}
+	case "priority":
+		return cmdSSLPriority(c, args[1:])
+	case "delete-custom":
+		return cmdSSLDelete(c, args[1:])
+	}
+	return usageError(c)
+}
+
+var sslUploadFlags = registerFlags("ssl upload-cert", flagSpec{"bundle-method": true, "type": true})
+
+// cmdSSLUpload uploads a custom certificate and its private key to the
+// active zone, after checking that they belong together.
+func cmdSSLUpload(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, sslUploadFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	bundle := flags.get("bundle-method", "")
+	switch bundle {
+	case "", "ubiquitous", "optimal", "force":
+	default:
+		return argError(fmt.Errorf("invalid bundle method %q (valid are ubiquitous, optimal and force)", bundle))
+	}
+	certType := flags.get("type", "")
+	switch certType {
+	case "", "sni_custom", "legacy_custom":
+	default:
+		return argError(fmt.Errorf("invalid certificate type %q (valid are sni_custom and legacy_custom)", certType))
+	}
+
+	certPEM, err := os.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+	keyPEM, err := os.ReadFile(args[1])
+	if err != nil {
+		return err
+	}
+	leaf, err := checkKeyPair(certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	if err := requireCapability(api, zoneID, capCustomCerts); err != nil {
+		return err
+	}
+
+	cert, err := api.CreateSSL(commandCtx, zoneID.Identifier, cloudflare.ZoneCustomSSLOptions{
+		Certificate:  string(certPEM),
+		PrivateKey:   string(keyPEM),
+		BundleMethod: bundle,
+		Type:         certType,
+	})
+	if err != nil {
+		return err
+	}
+	printf("Uploaded certificate %s for %s, expiring %s.\n", cert.ID,
+		strings.Join(leaf.DNSNames, ", "), leaf.NotAfter.Format("2006-01-02"))
+	return nil
+}
+
+// checkKeyPair checks that a PEM-encoded certificate, optionally followed
+// by its chain, matches a private key and has not expired, and returns the
+// certificate.
+func checkKeyPair(certPEM, keyPEM []byte) (*x509.Certificate, error) {
+	pair, err := tls.X509KeyPair(certPEM, keyPEM)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate or key: %v", err)
+	}
+	leaf, err := x509.ParseCertificate(pair.Certificate[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificate: %v", err)
+	}
+	if time.Now().After(leaf.NotAfter) {
+		return nil, fmt.Errorf("certificate expired on %s", leaf.NotAfter.Format("2006-01-02"))
+	}
+	return leaf, nil
+}
+
+// cmdSSLList lists the custom certificates of the active zone in order of
+// priority.
+func cmdSSLList() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	certs, err := api.ListSSL(commandCtx, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		printf("No custom certificates found.\n")
+		return nil
+	}
+
+	slices.SortStableFunc(certs, func(a, b cloudflare.ZoneCustomSSL) int {
+		return a.Priority - b.Priority
+	})
+	widthStatus := 0
+	for _, cert := range certs {
+		widthStatus = max(widthStatus, len(cert.Status))
+	}
+	for _, cert := range certs {
+		fmt.Fprintf(output, "%s %3d %-*s expires %s %s\n", cert.ID, cert.Priority, widthStatus, cert.Status,
+			cert.ExpiresOn.Format("2006-01-02"), strings.Join(cert.Hosts, ", "))
+	}
+	return nil
+}
+
+// cmdSSLPriority sets the priorities of custom certificates, given as
+// pairs of certificate IDs and priorities.
+func cmdSSLPriority(c *cmd.Command, args []string) error {
+	if len(args) == 0 || len(args)%2 != 0 {
+		return usageError(c)
+	}
+
+	var priorities []cloudflare.ZoneCustomSSLPriority
+	for i := 0; i < len(args); i += 2 {
+		n, err := strconv.Atoi(args[i+1])
+		if err != nil || n < 0 {
+			return argError(fmt.Errorf("invalid priority %q", args[i+1]))
+		}
+		priorities = append(priorities, cloudflare.ZoneCustomSSLPriority{ID: args[i], Priority: n})
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.ReprioritizeSSL(commandCtx, zoneID.Identifier, priorities); err != nil {
+		return err
+	}
+	for _, p := range priorities {
+		printf("Certificate %s has priority %d.\n", p.ID, p.Priority)
+	}
+	return nil
+}
+
+var sslDeleteFlags = registerFlags("ssl delete-custom", forceFlags)
+
+// cmdSSLDelete deletes a custom certificate, after confirmation.
+func cmdSSLDelete(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, sslDeleteFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	cert, err := api.SSLDetails(commandCtx, zoneID.Identifier, args[0])
+	if err != nil {
+		return err
+	}
+	hosts := strings.Join(cert.Hosts, ", ")
+	if !flags.force() && !confirm(sprintf("Delete custom certificate %s for %s?", cert.ID, hosts)) {
+		printf("Certificate not deleted.\n")
+		return nil
+	}
+
+	if err := api.DeleteSSL(commandCtx, zoneID.Identifier, cert.ID); err != nil {
+		return err
+	}
+	printf("Deleted custom certificate %s for %s.\n", cert.ID, hosts)
+	return nil
+}
@@ -0,0 +1,190 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A ddnsZone lists the records of a zone kept updated by the ddns command
+// when no name is given on the command line.
+type ddnsZone struct {
+	Zone  string   `json:"zone"`
+	Names []string `json:"names"`
+}
+
+// A ddnsTarget is a record kept updated by the ddns command.
+type ddnsTarget struct {
+	zone zoneTarget
+	name string
+}
+
+var ddnsFlags = registerFlags("ddns", mergeFlags(ipSourceFlags, flagSpec{
+	"interval": true,
+	"4":        false,
+	"6":        false,
+	"once":     false,
+}))
+
+func cmdDDNS(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, ddnsFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 || (len(args) == 0 && len(cfg.DDNS) == 0) {
+		return usageError(c)
+	}
+
+	interval, err := time.ParseDuration(flags.get("interval", "5m"))
+	if err != nil || interval < 0 {
+		return argError(fmt.Errorf("invalid interval %q", flags.get("interval", "")))
+	}
+
+	var recTypes []string
+	if flags.has("4") || !flags.has("6") {
+		recTypes = append(recTypes, "A")
+	}
+	if flags.has("6") {
+		recTypes = append(recTypes, "AAAA")
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	targets, err := ddnsTargets(api, args)
+	if err != nil {
+		return err
+	}
+
+	sources, err := parseIPSources(flags)
+	if err != nil {
+		return argError(err)
+	}
+
+	last := make(map[string]string)
+
+	// A hangup signal reloads the configuration and credentials, so that
+	// rotated credentials take effect without a restart.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	log.Printf("Starting dynamic DNS updates for %s.", targetNames(targets))
+	for {
+		failed := false
+		for _, recType := range recTypes {
+			ip, err := detectPublicIP(recType == "AAAA", sources)
+			if err != nil {
+				log.Printf("Error detecting public address for %s records: %v", recType, err)
+				noteRunFailure()
+				failed = true
+				continue
+			}
+
+			for _, t := range targets {
+				key := recType + " " + t.name
+				if last[key] == ip {
+					continue
+				}
+
+				changed, err := upsertRecord(api, t.zone.id, recType, t.name, ip, 0, recordMeta{})
+				if err != nil {
+					log.Printf("Error updating %s record %s: %v", recType, t.name, err)
+					noteRunFailure()
+					failed = true
+					continue
+				}
+
+				if changed {
+					log.Printf("Updated %s record %s to %s.", recType, t.name, ip)
+				} else {
+					log.Printf("%s record %s is already %s.", recType, t.name, ip)
+				}
+				last[key] = ip
+			}
+		}
+
+		code := exitSuccess
+		if failed {
+			code = exitFailure
+		}
+		writeSummaryFile("ddns", code)
+
+		if flags.has("once") || interval == 0 {
+			return nil
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-timer.C:
+		case <-hangup:
+			timer.Stop()
+			if err := reloadConfig(); err != nil {
+				log.Printf("Error reloading configuration: %v", err)
+				break
+			}
+			api, _ = getAPI()
+			if t, err := ddnsTargets(api, args); err == nil {
+				targets = t
+			} else {
+				log.Printf("Error reloading dynamic DNS records: %v", err)
+			}
+			if s, err := parseIPSources(flags); err == nil {
+				sources = s
+			}
+			clear(last)
+			log.Printf("Configuration reloaded.")
+		case <-commandCtx.Done():
+			timer.Stop()
+			return errInterrupted
+		}
+		refreshResponses()
+	}
+}
+
+// ddnsTargets returns the records the ddns command keeps updated: the record
+// named on the command line in the active zone, or else the records listed
+// in the configuration file.
+func ddnsTargets(api *cloudflare.API, args []string) ([]ddnsTarget, error) {
+	if len(args) == 1 {
+		zoneID, err := getZoneIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		return []ddnsTarget{{zoneTarget{activeZoneName, zoneID}, args[0]}}, nil
+	}
+
+	var targets []ddnsTarget
+	for _, z := range cfg.DDNS {
+		zoneID, err := recordBackend(api).ZoneIDByName(z.Zone)
+		if err != nil {
+			return nil, zoneError(err)
+		}
+		for _, name := range z.Names {
+			targets = append(targets, ddnsTarget{zoneTarget{z.Zone, cloudflare.ZoneIdentifier(zoneID)}, name})
+		}
+	}
+	return targets, nil
+}
+
+// targetNames returns the names of the records kept updated, for logging.
+func targetNames(targets []ddnsTarget) string {
+	names := make([]string, len(targets))
+	for i, t := range targets {
+		names[i] = t.name
+	}
+	return strings.Join(names, ", ")
+}
@@ -0,0 +1,325 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/term"
+)
+
+// ANSI colors used for the lines of a diff and the columns of a listing.
+const (
+	colorRed     = "\x1b[31m"
+	colorGreen   = "\x1b[32m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorBold    = "\x1b[1m"
+	colorDim     = "\x1b[2m"
+	colorProxied = "\x1b[1;38;5;208m" // bold Cloudflare orange
+	colorReset   = "\x1b[0m"
+)
+
+// A recordDiff is a difference between a snapshot of a zone and its live
+// records. Old is nil for an added record, and New for a removed one.
+type recordDiff struct {
+	Old, New *cloudflare.DNSRecord
+}
+
+func cmdDiff(c *cmd.Command, args []string) error {
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	snapshot, err := readSnapshot(args[0], activeZoneName)
+	if err != nil {
+		return err
+	}
+
+	live, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+
+	diffs := diffRecords(snapshot, live)
+	if len(diffs) == 0 {
+		printf("The zone matches the snapshot.\n")
+		return nil
+	}
+
+	printDiffs(diffs)
+	return fmt.Errorf("%d difference(s) found", len(diffs))
+}
+
+// cmdZoneCompare compares the records of two zones, ignoring the zone
+// names, so that records present in only the first zone are displayed as
+// removed and those present in only the second as added.
+func cmdZoneCompare(c *cmd.Command, args []string) error {
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	var recs [2][]cloudflare.DNSRecord
+	for i, zone := range args {
+		zoneID, err := recordBackend(api).ZoneIDByName(zone)
+		if err != nil {
+			return zoneError(err)
+		}
+		live, err := listRecords(api, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{})
+		if err != nil {
+			return err
+		}
+		recs[i] = relativeRecords(live, zone)
+	}
+
+	diffs := diffRecords(recs[0], recs[1])
+	if len(diffs) == 0 {
+		printf("Zones %s and %s have the same records.\n", args[0], args[1])
+		return nil
+	}
+
+	printDiffs(diffs)
+	return fmt.Errorf("%d difference(s) found", len(diffs))
+}
+
+// relativeRecords returns copies of a zone's records whose names, and
+// whose contents naming hosts in the zone, are relative to the zone, with
+// "@" standing for the zone itself.
+func relativeRecords(recs []cloudflare.DNSRecord, zone string) []cloudflare.DNSRecord {
+	zone = cflib.NormalizeName(zone)
+	relative := func(name string) string {
+		name = cflib.NormalizeName(name)
+		if name == zone {
+			return "@"
+		}
+		return strings.TrimSuffix(name, "."+zone)
+	}
+
+	out := make([]cloudflare.DNSRecord, len(recs))
+	for i, r := range recs {
+		r.Name = relative(r.Name)
+		switch strings.ToUpper(r.Type) {
+		case "CNAME", "MX", "NS", "PTR":
+			if inZone(r.Content, zone) {
+				r.Content = relative(r.Content)
+			}
+		}
+		out[i] = r
+	}
+	return out
+}
+
+// readSnapshot reads the records of a zone snapshot, either a JSON list of
+// records, as output by "list" in JSON mode, or a zone file.
+func readSnapshot(path, zone string) ([]cloudflare.DNSRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var recs []cloudflare.DNSRecord
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		if err := json.Unmarshal(data, &recs); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+	} else if recs, err = parseZoneFile(bytes.NewReader(data), zone); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	// Cloudflare's own nameservers appear in exported zone files but are
+	// not records of the zone.
+	kept := recs[:0]
+	for _, r := range recs {
+		if strings.EqualFold(r.Type, "NS") && strings.HasSuffix(cflib.NormalizeName(r.Content), ".ns.cloudflare.com") {
+			continue
+		}
+		kept = append(kept, r)
+	}
+	return kept, nil
+}
+
+// diffRecords compares the records of a snapshot with the live records.
+// Records with the same type, name and content are the same record, which
+// has changed if its TTL or proxy setting differ. Otherwise the remaining
+// records with the same type and name are paired as records whose content
+// has changed, and the rest have been added or removed.
+func diffRecords(snapshot, live []cloudflare.DNSRecord) []recordDiff {
+	byContent := make(map[string][]int)
+	for i, r := range live {
+		k := diffKey(r, true)
+		byContent[k] = append(byContent[k], i)
+	}
+
+	var diffs []recordDiff
+	matched := make([]bool, len(live))
+	var unmatched []cloudflare.DNSRecord
+	for _, old := range snapshot {
+		k := diffKey(old, true)
+		if len(byContent[k]) == 0 {
+			unmatched = append(unmatched, old)
+			continue
+		}
+		i := byContent[k][0]
+		byContent[k] = byContent[k][1:]
+		matched[i] = true
+		if settingsDiffer(old, live[i]) {
+			diffs = append(diffs, recordDiff{&old, &live[i]})
+		}
+	}
+
+	byName := make(map[string][]int)
+	for i, r := range live {
+		if !matched[i] {
+			k := diffKey(r, false)
+			byName[k] = append(byName[k], i)
+		}
+	}
+	for _, old := range unmatched {
+		k := diffKey(old, false)
+		if len(byName[k]) == 0 {
+			diffs = append(diffs, recordDiff{Old: &old})
+			continue
+		}
+		i := byName[k][0]
+		byName[k] = byName[k][1:]
+		matched[i] = true
+		diffs = append(diffs, recordDiff{&old, &live[i]})
+	}
+	for i := range live {
+		if !matched[i] {
+			diffs = append(diffs, recordDiff{New: &live[i]})
+		}
+	}
+
+	sort.SliceStable(diffs, func(i, j int) bool {
+		a, b := diffs[i].record(), diffs[j].record()
+		if n, m := cflib.NormalizeName(a.Name), cflib.NormalizeName(b.Name); n != m {
+			return n < m
+		}
+		return a.Type < b.Type
+	})
+	return diffs
+}
+
+// record returns the record the difference applies to.
+func (d *recordDiff) record() *cloudflare.DNSRecord {
+	if d.New != nil {
+		return d.New
+	}
+	return d.Old
+}
+
+// diffKey identifies a record by its type and name and, if withContent is
+// true, its priority and content.
+func diffKey(r cloudflare.DNSRecord, withContent bool) string {
+	k := strings.ToUpper(r.Type) + " " + cflib.NormalizeName(r.Name)
+	if withContent {
+		k += " " + diffContent(r)
+	}
+	return k
+}
+
+// diffContent returns a record's normalized content, preceded by its
+// priority if it has one.
+func diffContent(r cloudflare.DNSRecord) string {
+	content := cflib.NormalizeContent(r.Type, r.Content)
+	if r.Priority != nil {
+		content = fmt.Sprintf("%d %s", *r.Priority, content)
+	}
+	return content
+}
+
+// settingsDiffer reports whether two records with the same content have
+// different TTLs or proxy settings. A snapshot without a proxy setting
+// matches either.
+func settingsDiffer(old, cur cloudflare.DNSRecord) bool {
+	return cflib.NormalizeTTL(old.TTL) != cflib.NormalizeTTL(cur.TTL) ||
+		(old.Proxied != nil && *old.Proxied != isProxied(cur))
+}
+
+// printDiffs displays differences, one per line, colored if output is
+// colored.
+func printDiffs(diffs []recordDiff) {
+	color := useColor()
+	for _, d := range diffs {
+		line, code := formatDiff(d)
+		if color {
+			line = code + line + colorReset
+		}
+		fmt.Fprintln(output, line)
+	}
+}
+
+// formatDiff returns the line describing a difference and the color it is
+// displayed in.
+func formatDiff(d recordDiff) (string, string) {
+	switch {
+	case d.Old == nil:
+		r := d.New
+		return fmt.Sprintf("+ %s %s %s", r.Type, r.Name, diffContent(*r)), colorGreen
+	case d.New == nil:
+		r := d.Old
+		return fmt.Sprintf("- %s %s %s", r.Type, r.Name, diffContent(*r)), colorRed
+	}
+
+	old, cur := d.Old, d.New
+	var changes []string
+	if a, b := diffContent(*old), diffContent(*cur); a != b {
+		changes = append(changes, fmt.Sprintf("%s -> %s", a, b))
+	} else {
+		changes = append(changes, a)
+	}
+	if cflib.NormalizeTTL(old.TTL) != cflib.NormalizeTTL(cur.TTL) {
+		changes = append(changes, fmt.Sprintf("(TTL %s -> %s)",
+			formatTTL(cflib.NormalizeTTL(old.TTL)), formatTTL(cflib.NormalizeTTL(cur.TTL))))
+	}
+	if old.Proxied != nil && *old.Proxied != isProxied(*cur) {
+		changes = append(changes, fmt.Sprintf("(proxied %s -> %s)", onOff(*old.Proxied), onOff(isProxied(*cur))))
+	}
+	return fmt.Sprintf("~ %s %s %s", cur.Type, cur.Name, strings.Join(changes, " ")), colorYellow
+}
+
+// useColor reports whether output should be colored. Unless "set color"
+// says otherwise, it is colored only when standard output is a terminal
+// and the NO_COLOR environment variable is not set.
+func useColor() bool {
+	switch colorMode {
+	case "on":
+		return true
+	case "off":
+		return false
+	}
+	return os.Getenv("NO_COLOR") == "" && stdoutIsTerminal()
+}
+
+// stdoutIsTerminal reports whether standard output is a terminal.
+func stdoutIsTerminal() bool {
+	return term.IsTerminal(int(stdout.Fd()))
+}
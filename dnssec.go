@@ -0,0 +1,83 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var dnssecFlags = registerFlags("dnssec", forceFlags)
+
+func cmdDNSSEC(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, dnssecFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		d, err := api.ZoneDNSSECSetting(commandCtx, zoneID.Identifier)
+		if err != nil {
+			return err
+		}
+		displayDNSSEC(d)
+		return nil
+
+	case "enable":
+		d, err := api.UpdateZoneDNSSEC(commandCtx, zoneID.Identifier,
+			cloudflare.ZoneDNSSECUpdateOptions{Status: "active"})
+		if err != nil {
+			return err
+		}
+		printf("DNSSEC enabled. Add the following DS record at the registrar:\n")
+		displayDNSSEC(d)
+		return nil
+
+	case "disable":
+		// Disabling DNSSEC while the registrar still publishes the DS record
+		// makes the zone unresolvable by validating resolvers.
+		if !flags.force() && !confirm("Remove the DS record at the registrar before disabling DNSSEC. Disable DNSSEC?") {
+			printf("DNSSEC not disabled.\n")
+			return nil
+		}
+		_, err := api.UpdateZoneDNSSEC(commandCtx, zoneID.Identifier,
+			cloudflare.ZoneDNSSECUpdateOptions{Status: "disabled"})
+		if err != nil {
+			return err
+		}
+		printf("DNSSEC disabled.\n")
+		return nil
+
+	default:
+		return usageError(c)
+	}
+}
+
+func displayDNSSEC(d cloudflare.ZoneDNSSEC) {
+	printf("Status:           %s\n", d.Status)
+	if d.Status == "disabled" || d.DS == "" {
+		return
+	}
+	printf("Key tag:          %d\n", d.KeyTag)
+	printf("Algorithm:        %s\n", d.Algorithm)
+	printf("Digest type:      %s\n", d.DigestType)
+	printf("Digest:           %s\n", d.Digest)
+	printf("DS record:        %s\n", d.DS)
+	printf("Public key:       %s\n", d.PublicKey)
+}
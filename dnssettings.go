@@ -0,0 +1,179 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// dnsSettings holds the DNS settings of a zone, or the defaults applied to
+// new zones in an account.
+type dnsSettings struct {
+	FoundationDNS      *bool           `json:"foundation_dns,omitempty"`
+	MultiProvider      *bool           `json:"multi_provider,omitempty"`
+	SecondaryOverrides *bool           `json:"secondary_overrides,omitempty"`
+	Nameservers        *dnsNameservers `json:"nameservers,omitempty"`
+	NSTTL              *int            `json:"ns_ttl,omitempty"`
+	ZoneMode           string          `json:"zone_mode,omitempty"`
+}
+
+type dnsNameservers struct {
+	Type string `json:"type"`
+}
+
+// nameserverTypes maps the short names accepted for the nameservers
+// setting to the API's nameserver assignment types.
+var nameserverTypes = map[string]string{
+	"standard": "cloudflare.standard",
+	"account":  "custom.account",
+	"tenant":   "custom.tenant",
+	"zone":     "custom.zone",
+}
+
+var dnsSettingsFlags = registerFlags("dns", flagSpec{"account": true})
+
+func cmdDNSSettings(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, dnsSettingsFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 && len(args) != 2 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	var endpoint string
+	var zoneID *cloudflare.ResourceContainer
+	if flags.has("account") {
+		account, err := selectAccount(api, flags.get("account", ""))
+		if err != nil {
+			return err
+		}
+		endpoint = "/accounts/" + account.ID + "/dns_settings"
+	} else {
+		if zoneID, err = getZoneIdentifier(); err != nil {
+			return err
+		}
+		endpoint = "/zones/" + zoneID.Identifier + "/dns_settings"
+	}
+
+	if len(args) == 0 {
+		r, err := api.Raw(commandCtx, http.MethodGet, endpoint, nil, nil)
+		if err != nil {
+			return err
+		}
+		var s dnsSettings
+		if flags.has("account") {
+			var a struct {
+				ZoneDefaults dnsSettings `json:"zone_defaults"`
+			}
+			err = json.Unmarshal(r.Result, &a)
+			s = a.ZoneDefaults
+		} else {
+			err = json.Unmarshal(r.Result, &s)
+		}
+		if err != nil {
+			return err
+		}
+		displayDNSSettings(s)
+		return nil
+	}
+
+	var s dnsSettings
+	value := strings.ToLower(args[1])
+	switch strings.ToLower(args[0]) {
+	case "foundation-dns":
+		s.FoundationDNS, err = parseOnOffPtr(value)
+	case "multi-provider":
+		s.MultiProvider, err = parseOnOffPtr(value)
+	case "secondary-overrides":
+		s.SecondaryOverrides, err = parseOnOffPtr(value)
+	case "nameservers":
+		t, ok := nameserverTypes[value]
+		if !ok {
+			t = value
+		}
+		s.Nameservers = &dnsNameservers{Type: t}
+	case "ns-ttl":
+		var ttl int
+		ttl, err = strconv.Atoi(value)
+		if err != nil || ttl < 30 || ttl > 86400 {
+			err = fmt.Errorf("nameserver TTL must be between 30 and 86400 seconds")
+		}
+		s.NSTTL = &ttl
+	default:
+		return usageError(c)
+	}
+	if err != nil {
+		return argError(err)
+	}
+
+	if zoneID != nil {
+		var need *capability
+		switch {
+		case s.FoundationDNS != nil && *s.FoundationDNS:
+			need = &capFoundationDNS
+		case s.Nameservers != nil && s.Nameservers.Type == "custom.zone":
+			need = &capZoneNS
+		}
+		if need != nil {
+			if err := requireCapability(api, zoneID, *need); err != nil {
+				return err
+			}
+		}
+	}
+
+	var body any = s
+	if flags.has("account") {
+		body = map[string]any{"zone_defaults": s}
+	}
+	if _, err := api.Raw(commandCtx, http.MethodPatch, endpoint, body, nil); err != nil {
+		return err
+	}
+
+	printf("DNS settings updated.\n")
+	return nil
+}
+
+func displayDNSSettings(s dnsSettings) {
+	printf("Foundation DNS:      %s\n", formatOptionalBool(s.FoundationDNS))
+	printf("Multi-provider:      %s\n", formatOptionalBool(s.MultiProvider))
+	printf("Secondary overrides: %s\n", formatOptionalBool(s.SecondaryOverrides))
+	if s.Nameservers != nil {
+		printf("Nameservers:         %s\n", s.Nameservers.Type)
+	}
+	if s.NSTTL != nil {
+		printf("Nameserver TTL:      %d\n", *s.NSTTL)
+	}
+	if s.ZoneMode != "" {
+		printf("Zone mode:           %s\n", s.ZoneMode)
+	}
+}
+
+func parseOnOffPtr(s string) (*bool, error) {
+	on, err := parseOnOff(s)
+	if err != nil {
+		return nil, err
+	}
+	return &on, nil
+}
+
+func formatOptionalBool(b *bool) string {
+	if b == nil {
+		return "-"
+	}
+	return onOff(*b)
+}
@@ -0,0 +1,177 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+)
+
+// defaultHostnameLabel is the container label holding the hostnames that
+// should point at the container host.
+const defaultHostnameLabel = "cf.hostname"
+
+var dockerSyncFlags = registerFlags("docker sync", mergeFlags(ipSourceFlags, flagSpec{
+	"label":    true,
+	"interval": true,
+	"4":        false,
+	"6":        false,
+	"once":     false,
+}))
+
+func cmdDocker(c *cmd.Command, args []string) error {
+	if len(args) < 1 || args[0] != "sync" {
+		return usageError(c)
+	}
+	flags, args, err := parseFlags(args[1:], dockerSyncFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	interval, err := time.ParseDuration(flags.get("interval", "5m"))
+	if err != nil || interval < 0 {
+		return argError(fmt.Errorf("invalid interval %q", flags.get("interval", "")))
+	}
+
+	var recTypes []string
+	if flags.has("4") || !flags.has("6") {
+		recTypes = append(recTypes, "A")
+	}
+	if flags.has("6") {
+		recTypes = append(recTypes, "AAAA")
+	}
+
+	sources, err := parseIPSources(flags)
+	if err != nil {
+		return argError(err)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	label := flags.get("label", defaultHostnameLabel)
+	zone := cflib.NormalizeName(activeZoneName)
+	last := make(map[string]string)
+
+	log.Printf("Starting record updates for containers labeled %s.", label)
+	for {
+		failed := false
+		names, err := containerHostnames(label)
+		if err != nil {
+			log.Printf("Error listing containers: %v", err)
+			noteRunFailure()
+			failed = true
+		}
+
+		var inZone []string
+		for _, name := range names {
+			if name == zone || strings.HasSuffix(name, "."+zone) {
+				inZone = append(inZone, name)
+			} else if last[name] == "" {
+				log.Printf("Skipping %s, which is not in zone %s.", name, zone)
+				last[name] = "skipped"
+			}
+		}
+
+		for _, recType := range recTypes {
+			if len(inZone) == 0 {
+				break
+			}
+			ip, err := detectPublicIP(recType == "AAAA", sources)
+			if err != nil {
+				log.Printf("Error detecting public address for %s records: %v", recType, err)
+				noteRunFailure()
+				failed = true
+				continue
+			}
+
+			for _, name := range inZone {
+				key := recType + " " + name
+				if last[key] == ip {
+					continue
+				}
+				changed, err := upsertRecord(api, zoneID, recType, name, ip, 0, recordMeta{})
+				if err != nil {
+					log.Printf("Error updating %s record %s: %v", recType, name, err)
+					noteRunFailure()
+					failed = true
+					continue
+				}
+				if changed {
+					log.Printf("Updated %s record %s to %s.", recType, name, ip)
+				} else {
+					log.Printf("%s record %s is already %s.", recType, name, ip)
+				}
+				last[key] = ip
+			}
+		}
+
+		code := exitSuccess
+		if failed {
+			code = exitFailure
+		}
+		writeSummaryFile("docker sync", code)
+
+		if flags.has("once") || interval == 0 {
+			return nil
+		}
+		if err := sleep(interval); err != nil {
+			return err
+		}
+	}
+}
+
+// containerHostnames returns the hostnames listed in the label of the
+// running Docker containers. A label may hold several comma-separated
+// hostnames.
+func containerHostnames(label string) ([]string, error) {
+	var stdout, stderr bytes.Buffer
+	docker := exec.Command("docker", "ps",
+		"--filter", "label="+label,
+		"--format", fmt.Sprintf("{{.Label %q}}", label))
+	docker.Stdout = &stdout
+	docker.Stderr = &stderr
+	if err := docker.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("docker was not found in the PATH")
+		}
+		return nil, fmt.Errorf("docker: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	seen := make(map[string]bool)
+	var names []string
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		for _, name := range strings.Split(scanner.Text(), ",") {
+			name = cflib.NormalizeName(name)
+			if name != "" && !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
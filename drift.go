@@ -0,0 +1,222 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// settingsSuffix ends the names of the settings snapshots written by
+// backup --settings.
+const settingsSuffix = ".settings.json"
+
+// A settingsSnapshot holds a zone's configuration other than its records:
+// its settings, page rules and the rules of its zone-level rulesets, keyed
+// by phase. A section that could not be read is nil and is not compared.
+type settingsSnapshot struct {
+	Zone      string                              `json:"zone"`
+	Taken     time.Time                           `json:"taken"`
+	Settings  map[string]any                      `json:"settings"`
+	PageRules []cloudflare.PageRule               `json:"page_rules"`
+	Rulesets  map[string][]cloudflare.RulesetRule `json:"rulesets"`
+	Notes     []string                            `json:"notes,omitempty"`
+}
+
+// A settingDrift is a difference between a settings snapshot and a zone's
+// live configuration. Old is empty for an added item, and New for a
+// removed one.
+type settingDrift struct {
+	Key      string
+	Old, New string
+}
+
+func cmdDrift(c *cmd.Command, args []string) error {
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	baseline, err := readSettingsSnapshot(args[0])
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := recordBackend(api).ZoneIDByName(baseline.Zone)
+	if err != nil {
+		return zoneError(err)
+	}
+
+	live := takeSettingsSnapshot(api, baseline.Zone, zoneID)
+	for _, n := range live.Notes {
+		printf("Warning: %s.\n", n)
+	}
+
+	drifts := diffSettings(baseline, live)
+	if len(drifts) == 0 {
+		printf("Zone %s matches the baseline of %s.\n", baseline.Zone,
+			baseline.Taken.Local().Format("2006-01-02 15:04"))
+		return nil
+	}
+
+	color := useColor()
+	for _, d := range drifts {
+		var line, code string
+		switch {
+		case d.Old == "":
+			line, code = fmt.Sprintf("+ %s %s", d.Key, d.New), colorGreen
+		case d.New == "":
+			line, code = fmt.Sprintf("- %s %s", d.Key, d.Old), colorRed
+		default:
+			line, code = fmt.Sprintf("~ %s %s -> %s", d.Key, d.Old, d.New), colorYellow
+		}
+		if color {
+			line = code + line + colorReset
+		}
+		fmt.Fprintln(output, line)
+	}
+	return fmt.Errorf("%d difference(s) found", len(drifts))
+}
+
+// takeSettingsSnapshot reads the configuration of a zone. Sections the
+// credentials are not permitted to read, or the zone's plan lacks, are left
+// nil, with a note.
+func takeSettingsSnapshot(api *cloudflare.API, zone, zoneID string) settingsSnapshot {
+	s := settingsSnapshot{Zone: zone, Taken: time.Now().UTC()}
+	note := func(what string, err error) {
+		s.Notes = append(s.Notes, fmt.Sprintf("could not read %s of zone %s: %v", what, zone, err))
+	}
+
+	if resp, err := api.ZoneSettings(commandCtx, zoneID); err != nil {
+		note("settings", err)
+	} else {
+		s.Settings = make(map[string]any)
+		for _, setting := range resp.Result {
+			s.Settings[setting.ID] = setting.Value
+		}
+	}
+
+	if rules, err := api.ListPageRules(commandCtx, zoneID); err != nil {
+		note("page rules", err)
+	} else {
+		sort.Slice(rules, func(i, j int) bool { return rules[i].Priority > rules[j].Priority })
+		s.PageRules = append([]cloudflare.PageRule{}, rules...)
+	}
+
+	rc := cloudflare.ZoneIdentifier(zoneID)
+	if rulesets, err := api.ListRulesets(commandCtx, rc, cloudflare.ListRulesetsParams{}); err != nil {
+		note("rulesets", err)
+	} else {
+		s.Rulesets = make(map[string][]cloudflare.RulesetRule)
+		for _, rs := range rulesets {
+			if rs.Kind != "zone" {
+				continue
+			}
+			full, err := api.GetRuleset(commandCtx, rc, rs.ID)
+			if err != nil {
+				note("ruleset "+rs.Phase, err)
+				continue
+			}
+			s.Rulesets[rs.Phase] = full.Rules
+		}
+	}
+	return s
+}
+
+func readSettingsSnapshot(path string) (settingsSnapshot, error) {
+	var s settingsSnapshot
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return s, err
+	}
+	if err := json.Unmarshal(data, &s); err != nil || s.Zone == "" {
+		return s, fmt.Errorf("%s is not a settings snapshot written by backup --settings", path)
+	}
+	return s, nil
+}
+
+// flattenSettings describes each item of a snapshot by a key and its value
+// in JSON. Page rules are keyed by their targets, and ruleset rules by
+// their phase and ID. Timestamps and versions, which change without any
+// change of configuration, are left out.
+func flattenSettings(s settingsSnapshot) map[string]string {
+	items := make(map[string]string)
+	encode := func(v any) string {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+
+	for id, v := range s.Settings {
+		if items["setting "+id] = formatSettingValue(v); v == "" {
+			items["setting "+id] = `""`
+		}
+	}
+	for _, r := range s.PageRules {
+		var targets []string
+		for _, t := range r.Targets {
+			targets = append(targets, t.Constraint.Value)
+		}
+		items["page rule "+strings.Join(targets, ",")] = encode(struct {
+			Actions  []cloudflare.PageRuleAction `json:"actions"`
+			Priority int                         `json:"priority"`
+			Status   string                      `json:"status"`
+		}{r.Actions, r.Priority, r.Status})
+	}
+	for phase, rules := range s.Rulesets {
+		for _, r := range rules {
+			r.Version, r.LastUpdated = nil, nil
+			id := r.ID
+			if r.Ref != "" {
+				id = r.Ref
+			}
+			items["rule "+phase+"/"+id] = encode(r)
+		}
+	}
+	return items
+}
+
+// diffSettings compares a baseline snapshot with a live one, sorted by key.
+// Sections missing from either snapshot are not compared.
+func diffSettings(baseline, live settingsSnapshot) []settingDrift {
+	if baseline.Settings == nil || live.Settings == nil {
+		baseline.Settings, live.Settings = nil, nil
+	}
+	if baseline.PageRules == nil || live.PageRules == nil {
+		baseline.PageRules, live.PageRules = nil, nil
+	}
+	if baseline.Rulesets == nil || live.Rulesets == nil {
+		baseline.Rulesets, live.Rulesets = nil, nil
+	}
+
+	old, cur := flattenSettings(baseline), flattenSettings(live)
+	var drifts []settingDrift
+	for k, v := range old {
+		if c, ok := cur[k]; !ok {
+			drifts = append(drifts, settingDrift{Key: k, Old: v})
+		} else if c != v {
+			drifts = append(drifts, settingDrift{Key: k, Old: v, New: c})
+		}
+	}
+	for k, v := range cur {
+		if _, ok := old[k]; !ok {
+			drifts = append(drifts, settingDrift{Key: k, New: v})
+		}
+	}
+	sort.Slice(drifts, func(i, j int) bool { return drifts[i].Key < drifts[j].Key })
+	return drifts
+}
@@ -0,0 +1,71 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// dryRun is true when mutating API requests should be displayed instead of
+// performed.
+var dryRun bool
+
+// dryRunResponse is the response returned in place of a mutating request's
+// real response while in dry-run mode.
+const dryRunResponse = `{"success":true,"errors":[],"messages":[],"result":{}}`
+
+// A dryRunTransport passes read-only API requests through to the underlying
+// transport. While dry-run mode is active, it displays every other request
+// and answers it with an empty successful response.
+type dryRunTransport struct {
+	base http.RoundTripper
+}
+
+func (t *dryRunTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !dryRun || !isMutating(req) {
+		return t.base.RoundTrip(req)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/client/v4")
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+	fmt.Fprintf(output, "[dry-run] %s %s\n", req.Method, path)
+
+	if req.Body != nil {
+		body, err := io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if len(body) > 0 {
+			fmt.Fprintf(output, "[dry-run]     %s\n", body)
+		}
+	}
+
+	return &http.Response{
+		Status:     "200 OK",
+		StatusCode: http.StatusOK,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     http.Header{"Content-Type": {"application/json"}},
+		Body:       io.NopCloser(strings.NewReader(dryRunResponse)),
+		Request:    req,
+	}, nil
+}
+
+// isMutating returns true if the request may change account state. Request
+// traces are posted but change nothing.
+func isMutating(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return false
+	}
+	return !strings.HasSuffix(req.URL.Path, "/request-tracer/trace")
+}
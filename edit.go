@@ -0,0 +1,786 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/term"
+)
+
+// editHeader introduces the records written to the editor by the edit
+// command.
+const editHeader = `# Change a line to update its record, delete a line to delete its record,
+# or add a line with the ID "-" to create a record. The content is the rest
+# of the line. Lines starting with # are ignored.
+#
+`
+
+// An editedRecord is a record parsed from the edited text, at a position
+// such as "line 3" used in error messages. Its ID is empty for a record to
+// be created.
+type editedRecord struct {
+	pos string
+	rec cloudflare.DNSRecord
+}
+
+// An editJSONRecord is a record as presented in the editor by edit --json.
+// Records with structured data, such as SRV, CAA and LOC records, hold their
+// value in Data rather than Content.
+type editJSONRecord struct {
+	ID       string         `json:"id,omitempty"`
+	Type     string         `json:"type"`
+	Name     string         `json:"name"`
+	TTL      int            `json:"ttl"`
+	Proxied  bool           `json:"proxied"`
+	Priority *uint16        `json:"priority,omitempty"`
+	Content  string         `json:"content,omitempty"`
+	Data     map[string]any `json:"data,omitempty"`
+}
+
+// An editPlan holds the changes made in the editor.
+type editPlan struct {
+	creates []cloudflare.DNSRecord
+	updates []cloudflare.DNSRecord
+	deletes []cloudflare.DNSRecord
+}
+
+func (p *editPlan) len() int {
+	return len(p.creates) + len(p.updates) + len(p.deletes)
+}
+
+// runEditor opens a file in the user's editor and waits for it to exit.
+var runEditor = func(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+		if runtime.GOOS == "windows" {
+			editor = "notepad"
+		}
+	}
+
+	// The editor setting may include arguments, such as "code --wait".
+	fields := strings.Fields(editor)
+	c := exec.Command(fields[0], append(fields[1:], path)...)
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, stdout, os.Stderr
+	if err := c.Run(); err != nil {
+		return fmt.Errorf("editor %s failed: %v", fields[0], err)
+	}
+	return nil
+}
+
+var editFlags = registerFlags("edit", mergeFlags(forceFlags, flagSpec{"json": false}))
+
+func cmdEdit(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, editFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 2 {
+		return usageError(c)
+	}
+	asJSON := flags.has("json")
+
+	params, matchName, err := parseRecordFilter(args)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	all, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+
+	var recs, skipped []cloudflare.DNSRecord
+	for _, r := range all {
+		if matchName != nil && !matchName(r.Name) {
+			continue
+		}
+		if !asJSON && !editable(r) {
+			skipped = append(skipped, r)
+			continue
+		}
+		recs = append(recs, r)
+	}
+	if len(recs) == 0 && len(skipped) == 0 {
+		return errNoMatch
+	}
+
+	text, ext := formatEditBlock(recs, skipped), ".txt"
+	parse := parseEditBlock
+	if asJSON {
+		text, ext, parse = formatEditJSON(recs), ".json", parseEditJSON
+	}
+	var plan editPlan
+	for {
+		edited, err := editText(text, ext)
+		if err != nil {
+			return err
+		}
+
+		var lines []editedRecord
+		lines, err = parse(edited)
+		if err == nil {
+			plan, err = planEdit(recs, lines, activeZoneName)
+		}
+		if err == nil {
+			break
+		}
+
+		printf("Error: %v\n", err)
+		if !interactive || !confirm(sprintf("Edit again?")) {
+			return err
+		}
+		text = edited
+	}
+
+	if plan.len() == 0 {
+		printf("No records changed.\n")
+		return nil
+	}
+
+	// Records may have changed in the zone while the editor was open.
+	refreshResponses()
+	live, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+	unresolved := 0
+	if conflicts := findEditConflicts(recs, live, plan); len(conflicts) > 0 {
+		plan, unresolved, err = resolveEditConflicts(plan, conflicts, flags.force(), asJSON)
+		if err != nil {
+			return err
+		}
+	}
+	if plan.len() == 0 {
+		printf("No records changed.\n")
+		return skippedConflicts(unresolved)
+	}
+
+	if !flags.force() {
+		orig := make(map[string]cloudflare.DNSRecord)
+		for _, r := range recs {
+			orig[r.ID] = r
+		}
+		var protected []string
+		for _, r := range slices.Concat(plan.updates, plan.deletes) {
+			if o := orig[r.ID]; isProtected(activeZoneName, o.Type, o.Name) {
+				protected = append(protected, o.Type+" "+o.Name)
+			}
+		}
+		if err := protectedError("change", protected); err != nil {
+			return err
+		}
+
+		printf("The following changes will be made:\n")
+		for _, r := range plan.creates {
+			fmt.Fprintf(output, "    + %s %s %s\n", r.Type, r.Name, editValue(r))
+		}
+		for _, r := range plan.updates {
+			fmt.Fprintf(output, "    ~ %s %s %s (ID %s)\n", r.Type, r.Name, editValue(r), r.ID)
+		}
+		for _, r := range plan.deletes {
+			fmt.Fprintf(output, "    - %s %s %s (ID %s)\n", r.Type, r.Name, editValue(r), r.ID)
+		}
+		if !confirm(sprintf("Apply %d change(s)?", plan.len())) {
+			printf("No changes applied.\n")
+			return nil
+		}
+	}
+
+	if err := applyEditPlan(api, zoneID, plan, flags.force()); err != nil {
+		return err
+	}
+	return skippedConflicts(unresolved)
+}
+
+// skippedConflicts returns the error of an edit whose conflicting records
+// were skipped, or nil if none were.
+func skippedConflicts(n int) error {
+	if n == 0 {
+		return nil
+	}
+	return fmt.Errorf("%d conflicting record(s) skipped; edit them again to resolve them", n)
+}
+
+// editable reports whether a record's value is held entirely in its
+// content, so that it can be edited as a single line. Records with
+// structured data or a priority are edited with edit --json.
+func editable(r cloudflare.DNSRecord) bool {
+	return r.Data == nil && r.Priority == nil
+}
+
+// editText writes text to a temporary file with an extension, opens it in
+// the user's editor and returns the edited text.
+func editText(text, ext string) (string, error) {
+	f, err := os.CreateTemp("", "cf-edit-*"+ext)
+	if err != nil {
+		return "", err
+	}
+	path := f.Name()
+	defer os.Remove(path)
+
+	_, err = f.WriteString(text)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return "", err
+	}
+
+	if err := runEditor(path); err != nil {
+		return "", err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// formatEditBlock returns the text presented in the editor for a set of
+// records. Records that cannot be edited are listed as comments.
+func formatEditBlock(recs, skipped []cloudflare.DNSRecord) string {
+	widthID, widthType, widthName, widthTTL := len("-"), 0, 0, 0
+	for _, r := range recs {
+		widthID = max(widthID, len(r.ID))
+		widthType = max(widthType, len(r.Type))
+		widthName = max(widthName, len(r.Name))
+		widthTTL = max(widthTTL, len(formatTTL(r.TTL)))
+	}
+
+	var b strings.Builder
+	b.WriteString(editHeader)
+	b.WriteString("# ID TYPE NAME TTL PROXIED CONTENT\n")
+	for _, r := range skipped {
+		fmt.Fprintf(&b, "# (edit with --json) %s %s %s\n", r.Type, r.Name, r.Content)
+	}
+	for _, r := range recs {
+		fmt.Fprintf(&b, "%-*s %-*s %-*s %-*s %-3s %s\n", widthID, r.ID, widthType, r.Type,
+			widthName, r.Name, widthTTL, formatTTL(r.TTL), onOff(isProxied(r)), r.Content)
+	}
+	return b.String()
+}
+
+// parseEditBlock parses the records in edited text.
+func parseEditBlock(text string) ([]editedRecord, error) {
+	var recs []editedRecord
+	scanner := bufio.NewScanner(strings.NewReader(text))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// The content is the rest of the line after the first five
+		// fields, so that it may contain spaces.
+		rest := line
+		var fields []string
+		for len(fields) < 5 {
+			if rest == "" {
+				return nil, fmt.Errorf("line %d: expected ID, type, name, TTL, proxied and content", n)
+			}
+			field, remainder := rest, ""
+			if i := strings.IndexAny(rest, " \t"); i >= 0 {
+				field, remainder = rest[:i], rest[i:]
+			}
+			fields = append(fields, field)
+			rest = strings.TrimLeft(remainder, " \t")
+		}
+		content := rest
+		if content == "" {
+			return nil, fmt.Errorf("line %d: missing content", n)
+		}
+
+		ttl, err := parseTTL(fields[3])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		proxied, err := parseOnOff(fields[4])
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+		recType := strings.ToUpper(fields[1])
+		if err := validateRecord(recType, fields[2], content); err != nil {
+			return nil, fmt.Errorf("line %d: %v", n, err)
+		}
+
+		id := fields[0]
+		if id == "-" {
+			id = ""
+		}
+		recs = append(recs, editedRecord{
+			pos: fmt.Sprintf("line %d", n),
+			rec: cloudflare.DNSRecord{
+				ID:      id,
+				Type:    recType,
+				Name:    fields[2],
+				TTL:     ttl,
+				Proxied: &proxied,
+				Content: content,
+			},
+		})
+	}
+	return recs, scanner.Err()
+}
+
+// formatEditJSON returns the JSON presented in the editor by edit --json
+// for a set of records.
+func formatEditJSON(recs []cloudflare.DNSRecord) string {
+	out := []editJSONRecord{}
+	for _, r := range recs {
+		e := editJSONRecord{
+			ID:       r.ID,
+			Type:     r.Type,
+			Name:     r.Name,
+			TTL:      r.TTL,
+			Proxied:  isProxied(r),
+			Priority: r.Priority,
+			Data:     dataMap(r.Data),
+		}
+		if e.Data == nil {
+			e.Content = r.Content
+		}
+		out = append(out, e)
+	}
+	data, _ := json.MarshalIndent(out, "", "  ")
+	return string(data) + "\n"
+}
+
+// parseEditJSON parses the records in JSON edited by edit --json. Records
+// with structured data need no content, and MX records need a priority.
+func parseEditJSON(text string) ([]editedRecord, error) {
+	dec := json.NewDecoder(strings.NewReader(text))
+	dec.DisallowUnknownFields()
+	var in []editJSONRecord
+	if err := dec.Decode(&in); err != nil {
+		return nil, fmt.Errorf("invalid JSON: %v", err)
+	}
+
+	var recs []editedRecord
+	for i, e := range in {
+		pos := fmt.Sprintf("record %d", i+1)
+		recType := strings.ToUpper(e.Type)
+		switch {
+		case recType == "" || e.Name == "":
+			return nil, fmt.Errorf("%s: missing type or name", pos)
+		case e.Data == nil && e.Content == "":
+			return nil, fmt.Errorf("%s: missing content", pos)
+		case recType == "MX" && e.Priority == nil:
+			return nil, fmt.Errorf("%s: missing priority", pos)
+		}
+
+		var err error
+		if e.Data == nil {
+			err = validateRecord(recType, e.Name, e.Content)
+		} else {
+			err = validateName(e.Name, true)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", pos, err)
+		}
+
+		ttl := e.TTL
+		if ttl == 0 {
+			ttl = ttlAuto
+		}
+		proxied := e.Proxied
+		rec := cloudflare.DNSRecord{
+			ID:       e.ID,
+			Type:     recType,
+			Name:     e.Name,
+			TTL:      ttl,
+			Proxied:  &proxied,
+			Priority: e.Priority,
+			Content:  e.Content,
+		}
+		if e.Data != nil {
+			rec.Data = e.Data
+		}
+		recs = append(recs, editedRecord{pos: pos, rec: rec})
+	}
+	return recs, nil
+}
+
+// dataMap returns a record's structured data as a JSON object, or nil if
+// it has none.
+func dataMap(data any) map[string]any {
+	if data == nil {
+		return nil
+	}
+	if m, ok := data.(map[string]any); ok {
+		return m
+	}
+	var m map[string]any
+	if b, err := json.Marshal(data); err == nil {
+		json.Unmarshal(b, &m)
+	}
+	return m
+}
+
+func samePriority(a, b *uint16) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func sameData(a, b any) bool {
+	ja, _ := json.Marshal(dataMap(a))
+	jb, _ := json.Marshal(dataMap(b))
+	return string(ja) == string(jb)
+}
+
+// editValue describes the value of an edited record: its content, preceded
+// by its priority if it has one, or its structured data.
+func editValue(r cloudflare.DNSRecord) string {
+	if r.Data != nil && r.Content == "" {
+		data, _ := json.Marshal(dataMap(r.Data))
+		return string(data)
+	}
+	if r.Priority != nil {
+		return fmt.Sprintf("%d %s", *r.Priority, r.Content)
+	}
+	return r.Content
+}
+
+// sameEdit reports whether an edited record holds the same values as the
+// original. The content of a record with structured data is ignored.
+func sameEdit(r, o cloudflare.DNSRecord) bool {
+	return r.Type == o.Type && r.Name == o.Name && r.TTL == o.TTL &&
+		isProxied(r) == isProxied(o) && samePriority(r.Priority, o.Priority) &&
+		(r.Content == o.Content || r.Data != nil) && sameData(r.Data, o.Data)
+}
+
+// planEdit compares the edited records of a zone with the originals,
+// returning the changes needed to apply the edits. Updated records keep the
+// comment and tags of the originals.
+func planEdit(orig []cloudflare.DNSRecord, edited []editedRecord, zone string) (editPlan, error) {
+	byID := make(map[string]cloudflare.DNSRecord)
+	for _, r := range orig {
+		byID[r.ID] = r
+	}
+
+	var plan editPlan
+	seen := make(map[string]bool)
+	for _, e := range edited {
+		r := e.rec
+		if err := validateApex(r.Type, r.Name, zone); err != nil {
+			return editPlan{}, fmt.Errorf("%s: %v", e.pos, err)
+		}
+		if r.ID == "" {
+			plan.creates = append(plan.creates, r)
+			continue
+		}
+
+		o, ok := byID[r.ID]
+		switch {
+		case !ok:
+			return editPlan{}, fmt.Errorf("%s: unknown record ID %s", e.pos, r.ID)
+		case seen[r.ID]:
+			return editPlan{}, fmt.Errorf("%s: record ID %s appears more than once", e.pos, r.ID)
+		}
+		seen[r.ID] = true
+
+		if sameEdit(r, o) {
+			continue
+		}
+		r.Comment, r.Tags = o.Comment, o.Tags
+		plan.updates = append(plan.updates, r)
+	}
+
+	for _, o := range orig {
+		if !seen[o.ID] {
+			plan.deletes = append(plan.deletes, o)
+		}
+	}
+	return plan, nil
+}
+
+// applyEditPlan makes the changes of an edit plan. Protected records are
+// deleted only if force is true.
+func applyEditPlan(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, plan editPlan, force bool) error {
+	type change struct {
+		verb string
+		rec  cloudflare.DNSRecord
+	}
+
+	var changes []change
+	var ops []operation
+	for _, r := range plan.deletes {
+		id := r.ID
+		changes = append(changes, change{"delete", r})
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, id),
+			fn: func() error {
+				return newClient(api, zoneID).Delete(deleteCtx(force), id)
+			},
+			change: deleteChange(zoneID.Identifier, activeZoneName, r),
+		})
+	}
+	for _, r := range plan.updates {
+		params := cloudflare.UpdateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			ID:       r.ID,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Data:     r.Data,
+			Tags:     r.Tags,
+		}
+		changes = append(changes, change{"update", r})
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.ID),
+			fn: func() error {
+				_, err := recordBackend(api).UpdateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: updateChange(zoneID.Identifier, activeZoneName, params),
+		})
+	}
+	for _, r := range plan.creates {
+		params := cloudflare.CreateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Data:     r.Data,
+		}
+		changes = append(changes, change{"create", r})
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.Type+" "+r.Name),
+			fn: func() error {
+				_, err := recordBackend(api).CreateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: createChange(zoneID.Identifier, activeZoneName, params),
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		c := changes[i]
+		if err != nil {
+			printf("Error changing %s record %s: %v\n", c.rec.Type, c.rec.Name, err)
+			failed++
+			continue
+		}
+		switch c.verb {
+		case "create":
+			printf("Created %s record %s.\n", c.rec.Type, c.rec.Name)
+		case "update":
+			printf("Updated %s record %s.\n", c.rec.Type, c.rec.Name)
+		case "delete":
+			printf("Deleted %s record %s.\n", c.rec.Type, c.rec.Name)
+		}
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d change(s) could not be applied", failed, len(ops))
+	}
+	printf("%d change(s) applied.\n", len(ops))
+	return nil
+}
+
+// An editConflict is a record changed in the zone while it was open in the
+// editor, which the edits also change or delete.
+type editConflict struct {
+	orig   cloudflare.DNSRecord  // as opened in the editor
+	remote *cloudflare.DNSRecord // as now in the zone, or nil if deleted
+	edited *cloudflare.DNSRecord // as edited, or nil if deleted in the editor
+}
+
+// findEditConflicts compares the records changed by an edit plan with the
+// zone's live records, returning those changed in the zone since they were
+// opened in the editor.
+func findEditConflicts(orig, live []cloudflare.DNSRecord, plan editPlan) []editConflict {
+	byID := make(map[string]cloudflare.DNSRecord)
+	for _, r := range live {
+		byID[r.ID] = r
+	}
+	origByID := make(map[string]cloudflare.DNSRecord)
+	for _, r := range orig {
+		origByID[r.ID] = r
+	}
+
+	var conflicts []editConflict
+	check := func(id string, edited *cloudflare.DNSRecord) {
+		c := editConflict{orig: origByID[id], edited: edited}
+		if r, ok := byID[id]; ok {
+			if sameEdit(r, c.orig) {
+				return
+			}
+			c.remote = &r
+		}
+		conflicts = append(conflicts, c)
+	}
+	for i := range plan.updates {
+		check(plan.updates[i].ID, &plan.updates[i])
+	}
+	for _, r := range plan.deletes {
+		check(r.ID, nil)
+	}
+	return conflicts
+}
+
+// resolveEditConflicts asks how to resolve each conflict between the edits
+// and the changes made in the zone while the editor was open: by keeping
+// the zone's record, applying the edit over it, skipping the record, or
+// editing it again. With force, the edits are applied. Outside interactive
+// mode, conflicting records are skipped. It returns the plan making the
+// chosen changes and the number of records skipped.
+func resolveEditConflicts(plan editPlan, conflicts []editConflict, force, asJSON bool) (editPlan, int, error) {
+	conflicting := make(map[string]bool)
+	for _, c := range conflicts {
+		conflicting[c.orig.ID] = true
+	}
+	var resolved editPlan
+	resolved.creates = plan.creates
+	for _, r := range plan.updates {
+		if !conflicting[r.ID] {
+			resolved.updates = append(resolved.updates, r)
+		}
+	}
+	for _, r := range plan.deletes {
+		if !conflicting[r.ID] {
+			resolved.deletes = append(resolved.deletes, r)
+		}
+	}
+
+	ask := interactive && term.IsTerminal(int(os.Stdin.Fd()))
+	skipped := 0
+	for _, c := range conflicts {
+		printf("%s record %s changed in the zone while it was being edited.\n", c.orig.Type, c.orig.Name)
+		fmt.Fprintf(output, "    %-8s %s\n", tr("zone:"), conflictValue(c.remote))
+		fmt.Fprintf(output, "    %-8s %s\n", tr("yours:"), conflictValue(c.edited))
+
+		choice := "s"
+		switch {
+		case force:
+			choice = "a"
+		case ask:
+			for {
+				answer, err := readString(tr("[k]eep the zone's record, [a]pply yours, [s]kip or [e]dit? "))
+				if err != nil {
+					fmt.Fprintln(output)
+					return editPlan{}, 0, errInterrupted
+				}
+				if choice = strings.ToLower(strings.TrimSpace(answer)); isOneOf(choice, []string{"k", "a", "s", "e"}) {
+					break
+				}
+			}
+		}
+
+		edited := c.edited
+		if choice == "e" {
+			r, err := editConflictRecord(c, asJSON)
+			if err != nil {
+				return editPlan{}, 0, err
+			}
+			edited, choice = r, "a"
+		}
+
+		switch choice {
+		case "k":
+			printf("Kept %s record %s as it is in the zone.\n", c.orig.Type, c.orig.Name)
+		case "s":
+			printf("Skipped %s record %s.\n", c.orig.Type, c.orig.Name)
+			skipped++
+		case "a":
+			switch {
+			case edited != nil && c.remote != nil:
+				r := *edited
+				r.ID, r.Comment, r.Tags = c.remote.ID, c.remote.Comment, c.remote.Tags
+				resolved.updates = append(resolved.updates, r)
+			case edited != nil:
+				r := *edited
+				r.ID = ""
+				resolved.creates = append(resolved.creates, r)
+			case c.remote != nil:
+				resolved.deletes = append(resolved.deletes, *c.remote)
+			}
+		}
+	}
+	return resolved, skipped, nil
+}
+
+// editConflictRecord opens a conflicting record in the editor, starting
+// from the edited record, or the zone's record if it was deleted in the
+// editor. It returns nil if the record is deleted.
+func editConflictRecord(c editConflict, asJSON bool) (*cloudflare.DNSRecord, error) {
+	base := c.edited
+	if base == nil {
+		base = c.remote
+	}
+	var recs []cloudflare.DNSRecord
+	if base != nil {
+		recs = append(recs, *base)
+	}
+
+	text, ext, parse := formatEditBlock(recs, nil), ".txt", parseEditBlock
+	if asJSON {
+		text, ext, parse = formatEditJSON(recs), ".json", parseEditJSON
+	} else {
+		text = fmt.Sprintf("# In the zone: %s\n# Yours:       %s\n", conflictValue(c.remote), conflictValue(c.edited)) + text
+	}
+	for {
+		edited, err := editText(text, ext)
+		if err != nil {
+			return nil, err
+		}
+		lines, err := parse(edited)
+		if err == nil && len(lines) > 1 {
+			err = fmt.Errorf("expected one record, found %d", len(lines))
+		}
+		if err == nil && len(lines) == 1 {
+			err = validateApex(lines[0].rec.Type, lines[0].rec.Name, activeZoneName)
+		}
+		if err == nil {
+			if len(lines) == 0 {
+				return nil, nil
+			}
+			return &lines[0].rec, nil
+		}
+		printf("Error: %v\n", err)
+		if !confirm(sprintf("Edit again?")) {
+			return nil, err
+		}
+		text = edited
+	}
+}
+
+// conflictValue describes a record in a conflict, or its deletion.
+func conflictValue(r *cloudflare.DNSRecord) string {
+	if r == nil {
+		return tr("(deleted)")
+	}
+	return fmt.Sprintf("%s %s %s %s %s", r.Type, r.Name, formatTTL(r.TTL), onOff(isProxied(*r)), editValue(*r))
+}
@@ -0,0 +1,333 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"net"
+	"net/netip"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// bandwidthAlliance maps the autonomous systems of Bandwidth Alliance
+// members, which discount or waive egress fees for traffic to Cloudflare,
+// to the members' names.
+var bandwidthAlliance = map[uint32]string{
+	8075:   "Microsoft Azure",
+	15169:  "Google Cloud",
+	396982: "Google Cloud",
+	31898:  "Oracle Cloud",
+	36351:  "IBM Cloud",
+	45102:  "Alibaba Cloud",
+	14061:  "DigitalOcean",
+	63949:  "Linode",
+	20473:  "Vultr",
+	12876:  "Scaleway",
+	26347:  "DreamHost",
+	54825:  "Equinix Metal",
+	40401:  "Backblaze",
+}
+
+// originProviders names the autonomous systems of common origin providers
+// outside the Bandwidth Alliance. Other networks are named after their AS
+// names.
+var originProviders = map[uint32]string{
+	16509: "Amazon Web Services",
+	14618: "Amazon Web Services",
+	24940: "Hetzner",
+	16276: "OVHcloud",
+	13335: "Cloudflare",
+}
+
+// lookupOriginAddrs returns the addresses of an origin named by a proxied
+// CNAME record.
+var lookupOriginAddrs = func(ctx context.Context, host string) ([]netip.Addr, error) {
+	return net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+}
+
+// lookupASN returns the number and name of the autonomous system announcing
+// an address.
+var lookupASN = cymruASN
+
+// An egressReport maps the origins of a zone's proxied records to the
+// networks hosting them, and summarizes which of the networks belong to
+// Bandwidth Alliance members, for reviewing what Cloudflare's requests to
+// the origins cost.
+type egressReport struct {
+	Generated time.Time        `json:"generated"`
+	Zone      string           `json:"zone"`
+	Origins   []egressOrigin   `json:"origins"`
+	Providers []egressProvider `json:"providers"`
+	Notes     []string         `json:"notes,omitempty"`
+}
+
+// An egressOrigin is an address of the origin of a proxied record. Origins
+// whose address or network could not be found have no ASN.
+type egressOrigin struct {
+	Type              string `json:"type"`
+	Name              string `json:"name"`
+	Content           string `json:"content"`
+	Address           string `json:"address,omitempty"`
+	ASN               uint32 `json:"asn,omitempty"`
+	Network           string `json:"network,omitempty"`
+	Provider          string `json:"provider"`
+	BandwidthAlliance bool   `json:"bandwidth_alliance"`
+}
+
+type egressProvider struct {
+	Provider          string   `json:"provider"`
+	BandwidthAlliance bool     `json:"bandwidth_alliance"`
+	ASNs              []uint32 `json:"asns"`
+	Records           int      `json:"records"`
+	Addresses         int      `json:"addresses"`
+}
+
+// takeEgressReport collects the egress report of a zone. Origins that
+// cannot be resolved or mapped to a network are reported as unknown, with
+// a note.
+func takeEgressReport(api *cloudflare.API, zone string) (egressReport, error) {
+	rep := egressReport{
+		Generated: time.Now().UTC(),
+		Zone:      zone,
+		Origins:   []egressOrigin{},
+		Providers: []egressProvider{},
+	}
+
+	zoneID, err := recordBackend(api).ZoneIDByName(zone)
+	if err != nil {
+		return rep, zoneError(err)
+	}
+	recs, err := listRecords(api, cloudflare.ZoneIdentifier(zoneID), cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return rep, err
+	}
+	sort.Slice(recs, func(i, j int) bool {
+		if recs[i].Name != recs[j].Name {
+			return recs[i].Name < recs[j].Name
+		}
+		return recs[i].Type < recs[j].Type
+	})
+
+	networks := make(map[netip.Addr]egressOrigin)
+	for _, r := range recs {
+		if !isProxied(r) {
+			continue
+		}
+		if commandCtx.Err() != nil {
+			return rep, errInterrupted
+		}
+
+		var addrs []netip.Addr
+		switch r.Type {
+		case "A", "AAAA":
+			addr, err := netip.ParseAddr(r.Content)
+			if err != nil {
+				continue
+			}
+			addrs = []netip.Addr{addr}
+		case "CNAME":
+			addrs, err = lookupOriginAddrs(commandCtx, r.Content)
+			if err != nil || len(addrs) == 0 {
+				rep.Notes = append(rep.Notes, fmt.Sprintf("could not resolve %s, the origin of %s", r.Content, r.Name))
+				rep.Origins = append(rep.Origins, egressOrigin{Type: r.Type, Name: r.Name, Content: r.Content, Provider: "unknown"})
+				continue
+			}
+		default:
+			continue
+		}
+
+		for _, addr := range addrs {
+			addr = addr.Unmap()
+			o, ok := networks[addr]
+			if !ok {
+				o = originNetwork(addr)
+				if o.ASN == 0 {
+					rep.Notes = append(rep.Notes, fmt.Sprintf("could not find the network of %s", addr))
+				}
+				networks[addr] = o
+			}
+			o.Type, o.Name, o.Content = r.Type, r.Name, r.Content
+			rep.Origins = append(rep.Origins, o)
+		}
+	}
+
+	rep.Providers = egressProviders(rep.Origins)
+	return rep, nil
+}
+
+// originNetwork returns an origin describing the network of an address.
+func originNetwork(addr netip.Addr) egressOrigin {
+	o := egressOrigin{Address: addr.String(), Provider: "unknown"}
+	asn, network, err := lookupASN(commandCtx, addr)
+	if err != nil {
+		return o
+	}
+	o.ASN, o.Network = asn, network
+	if name, ok := bandwidthAlliance[asn]; ok {
+		o.Provider, o.BandwidthAlliance = name, true
+	} else if name, ok := originProviders[asn]; ok {
+		o.Provider = name
+	} else if network != "" {
+		o.Provider = network
+	} else {
+		o.Provider = "AS" + strconv.FormatUint(uint64(asn), 10)
+	}
+	return o
+}
+
+// egressProviders summarizes the origins by provider, the providers with
+// the most records first.
+func egressProviders(origins []egressOrigin) []egressProvider {
+	type key struct{ provider, record string }
+	byName := make(map[string]*egressProvider)
+	records := make(map[key]bool)
+	var providers []*egressProvider
+	for _, o := range origins {
+		p := byName[o.Provider]
+		if p == nil {
+			p = &egressProvider{Provider: o.Provider, BandwidthAlliance: o.BandwidthAlliance, ASNs: []uint32{}}
+			byName[o.Provider] = p
+			providers = append(providers, p)
+		}
+		if o.ASN != 0 && !slices.Contains(p.ASNs, o.ASN) {
+			p.ASNs = append(p.ASNs, o.ASN)
+		}
+		if o.Address != "" {
+			p.Addresses++
+		}
+		if k := (key{o.Provider, o.Type + " " + o.Name}); !records[k] {
+			records[k] = true
+			p.Records++
+		}
+	}
+
+	sort.Slice(providers, func(i, j int) bool {
+		if providers[i].Records != providers[j].Records {
+			return providers[i].Records > providers[j].Records
+		}
+		return providers[i].Provider < providers[j].Provider
+	})
+	result := []egressProvider{}
+	for _, p := range providers {
+		slices.Sort(p.ASNs)
+		result = append(result, *p)
+	}
+	return result
+}
+
+// cymruASN looks up the autonomous system announcing an address with Team
+// Cymru's IP to ASN mapping service, which answers TXT queries for the
+// reversed address such as "1.2.0.192.origin.asn.cymru.com" with
+// "64496 | 192.0.2.0/24 | US | arin | 2010-07-14", and queries for
+// "AS64496.asn.cymru.com" with the AS name in the last field.
+func cymruASN(ctx context.Context, addr netip.Addr) (uint32, string, error) {
+	var name string
+	if addr.Is4() {
+		b := addr.As4()
+		name = fmt.Sprintf("%d.%d.%d.%d.origin.asn.cymru.com", b[3], b[2], b[1], b[0])
+	} else {
+		b := addr.As16()
+		var sb strings.Builder
+		for i := len(b) - 1; i >= 0; i-- {
+			fmt.Fprintf(&sb, "%x.%x.", b[i]&0xf, b[i]>>4)
+		}
+		name = sb.String() + "origin6.asn.cymru.com"
+	}
+
+	txts, err := lookupTXT(ctx, name)
+	if err != nil {
+		return 0, "", err
+	}
+	if len(txts) == 0 {
+		return 0, "", fmt.Errorf("no network found for %s", addr)
+	}
+	// Addresses announced by several systems list them all; use the first.
+	fields := strings.Fields(strings.SplitN(txts[0], "|", 2)[0])
+	if len(fields) == 0 {
+		return 0, "", fmt.Errorf("no network found for %s", addr)
+	}
+	asn, err := strconv.ParseUint(fields[0], 10, 32)
+	if err != nil {
+		return 0, "", fmt.Errorf("invalid ASN %q for %s", fields[0], addr)
+	}
+
+	// The AS name is optional; the number alone identifies the network.
+	var network string
+	if txts, err := lookupTXT(ctx, fmt.Sprintf("AS%d.asn.cymru.com", asn)); err == nil && len(txts) > 0 {
+		f := strings.Split(txts[0], "|")
+		network = strings.TrimSpace(f[len(f)-1])
+	}
+	return uint32(asn), network, nil
+}
+
+// writeEgressReport writes an egress report as JSON, as CSV with a row for
+// each origin address, or as an HTML page.
+func writeEgressReport(w io.Writer, rep egressReport, format string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"zone", "type", "name", "content", "address", "asn", "network", "provider", "bandwidth_alliance"})
+		for _, o := range rep.Origins {
+			asn := ""
+			if o.ASN != 0 {
+				asn = strconv.FormatUint(uint64(o.ASN), 10)
+			}
+			cw.Write([]string{rep.Zone, o.Type, o.Name, o.Content, o.Address, asn, o.Network,
+				o.Provider, strconv.FormatBool(o.BandwidthAlliance)})
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "html":
+		return egressTemplate.Execute(w, rep)
+
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(rep)
+	}
+}
+
+var egressTemplate = template.Must(template.New("egress").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Origins of {{.Zone}}</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+td.content { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>Origins of {{.Zone}}</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 UTC"}}</p>
+{{range .Notes}}<p><em>Note: {{.}}</em></p>
+{{end}}
+<h2>Providers</h2>
+{{if .Providers}}<table>
+<tr><th>Provider</th><th>Bandwidth Alliance</th><th>ASNs</th><th>Records</th><th>Addresses</th></tr>
+{{range .Providers}}<tr><td>{{.Provider}}</td><td>{{if .BandwidthAlliance}}yes{{else}}no{{end}}</td><td>{{range $i, $a := .ASNs}}{{if $i}}, {{end}}AS{{$a}}{{end}}</td><td>{{.Records}}</td><td>{{.Addresses}}</td></tr>
+{{end}}</table>{{else}}<p>No proxied records.</p>{{end}}
+<h2>Origins</h2>
+{{if .Origins}}<table>
+<tr><th>Type</th><th>Name</th><th>Content</th><th>Address</th><th>Network</th><th>Provider</th><th>Bandwidth Alliance</th></tr>
+{{range .Origins}}<tr><td>{{.Type}}</td><td>{{.Name}}</td><td class="content">{{.Content}}</td><td>{{.Address}}</td><td>{{if .ASN}}AS{{.ASN}} {{.Network}}{{end}}</td><td>{{.Provider}}</td><td>{{if .BandwidthAlliance}}yes{{else}}no{{end}}</td></tr>
+{{end}}</table>{{else}}<p>None.</p>{{end}}
+</body>
+</html>
+`))
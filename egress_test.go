@@ -0,0 +1,110 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"net/netip"
+	"slices"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestCymruASN(t *testing.T) {
+	saved := lookupTXT
+	defer func() { lookupTXT = saved }()
+	answers := map[string][]string{
+		"7.100.51.198.origin.asn.cymru.com": {"14061 | 198.51.100.0/24 | US | arin | 2012-03-08"},
+		"1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.origin6.asn.cymru.com": {
+			"16509 14618 | 2001:db8::/32 | US | arin | 2011-05-04"},
+		"AS14061.asn.cymru.com": {"14061 | US | arin | 2012-09-25 | DIGITALOCEAN-ASN, US"},
+	}
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		if txts, ok := answers[name]; ok {
+			return txts, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		addr    string
+		asn     uint32
+		network string
+		err     bool
+	}{
+		{"198.51.100.7", 14061, "DIGITALOCEAN-ASN, US", false},
+		{"2001:db8::1", 16509, "", false},
+		{"192.0.2.1", 0, "", true},
+	}
+	for _, test := range tests {
+		asn, network, err := cymruASN(context.Background(), netip.MustParseAddr(test.addr))
+		if asn != test.asn || network != test.network || (err != nil) != test.err {
+			t.Errorf("cymruASN(%s) = %d, %q, %v", test.addr, asn, network, err)
+		}
+	}
+}
+
+func TestEgressReport(t *testing.T) {
+	b := useMemoryBackend(t)
+	savedAddrs, savedASN := lookupOriginAddrs, lookupASN
+	defer func() { lookupOriginAddrs, lookupASN = savedAddrs, savedASN }()
+	lookupOriginAddrs = func(ctx context.Context, host string) ([]netip.Addr, error) {
+		if host == "app.example.net" {
+			return []netip.Addr{netip.MustParseAddr("198.51.100.7"), netip.MustParseAddr("198.51.100.8")}, nil
+		}
+		return nil, errors.New("no such host")
+	}
+	asns := map[string]uint32{"198.51.100.7": 14061, "198.51.100.8": 14061, "203.0.113.10": 16509, "203.0.113.11": 64496}
+	lookupASN = func(ctx context.Context, addr netip.Addr) (uint32, string, error) {
+		if asn, ok := asns[addr.String()]; ok {
+			return asn, "EXAMPLE-ASN", nil
+		}
+		return 0, "", errors.New("no such host")
+	}
+
+	on := true
+	for _, r := range []cloudflare.DNSRecord{
+		{Type: "A", Name: "api.example.com", Content: "203.0.113.10", Proxied: &on},
+		{Type: "A", Name: "api.example.com", Content: "203.0.113.11", Proxied: &on},
+		{Type: "CNAME", Name: "www.example.com", Content: "app.example.net", Proxied: &on},
+		{Type: "CNAME", Name: "old.example.com", Content: "gone.example.net", Proxied: &on},
+		{Type: "A", Name: "dev.example.com", Content: "192.0.2.1", Proxied: &on},
+		{Type: "A", Name: "mail.example.com", Content: "203.0.113.12"},
+	} {
+		if _, err := b.CreateDNSRecord(context.Background(), activeZoneIdentifier.Identifier, cloudflare.CreateDNSRecordParams{
+			Type: r.Type, Name: r.Name, Content: r.Content, Proxied: r.Proxied,
+		}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	rep, err := takeEgressReport(activeAPI, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var providers []string
+	for _, p := range rep.Providers {
+		providers = append(providers, p.Provider)
+	}
+	if want := []string{"unknown", "Amazon Web Services", "DigitalOcean", "EXAMPLE-ASN"}; !slices.Equal(providers, want) {
+		t.Errorf("providers = %q, want %q", providers, want)
+	}
+	for _, p := range rep.Providers {
+		if p.BandwidthAlliance != (p.Provider == "DigitalOcean") {
+			t.Errorf("provider %s has bandwidth_alliance %v", p.Provider, p.BandwidthAlliance)
+		}
+		if p.Provider == "DigitalOcean" && (p.Records != 1 || p.Addresses != 2) {
+			t.Errorf("DigitalOcean has %d record(s) and %d address(es), want 1 and 2", p.Records, p.Addresses)
+		}
+		if p.Provider == "unknown" && (p.Records != 2 || p.Addresses != 1) {
+			t.Errorf("unknown has %d record(s) and %d address(es), want 2 and 1", p.Records, p.Addresses)
+		}
+	}
+	if len(rep.Origins) != 6 || len(rep.Notes) != 2 {
+		t.Errorf("got %d origin(s) and notes %q, want 6 origins and 2 notes", len(rep.Origins), rep.Notes)
+	}
+}
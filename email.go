@@ -0,0 +1,292 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/mail"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var emailFlags = registerFlags("email", forceFlags)
+
+func cmdEmail(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, emailFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "status", "enable", "disable", "list":
+		if len(args) != 1 {
+			return usageError(c)
+		}
+	case "add":
+		if len(args) != 3 {
+			return usageError(c)
+		}
+	case "delete":
+		if len(args) != 2 {
+			return usageError(c)
+		}
+	default:
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "status":
+		return emailStatus(api, zoneID)
+	case "enable":
+		return enableEmail(api, zoneID)
+	case "disable":
+		return disableEmail(api, zoneID, flags.force())
+	case "list":
+		return listEmailRules(api, zoneID)
+	case "add":
+		return addEmailRule(api, zoneID, args[1], args[2])
+	default:
+		return deleteEmailRule(api, zoneID, args[1], flags.force())
+	}
+}
+
+// emailAddress completes an alias with the active zone's domain, unless it
+// is already a full address.
+func emailAddress(alias string) string {
+	if strings.Contains(alias, "@") {
+		return alias
+	}
+	return alias + "@" + activeZoneName
+}
+
+func emailStatus(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) error {
+	s, err := api.GetEmailRoutingSettings(commandCtx, zoneID)
+	if err != nil {
+		return err
+	}
+	printf("Email routing: %s (%s)\n", onOff(s.Enabled), s.Status)
+	return nil
+}
+
+// enableEmail enables email routing, then creates any of the MX and TXT
+// records it requires that the zone lacks.
+func enableEmail(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) error {
+	if _, err := api.EnableEmailRouting(commandCtx, zoneID); err != nil {
+		return err
+	}
+	printf("Email routing enabled.\n")
+
+	required, err := api.GetEmailRoutingDNSSettings(commandCtx, zoneID)
+	if err != nil {
+		return err
+	}
+	existing, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+	missing := missingRecords(required, existing)
+
+	// The zone may have several MX records with the same name, so the
+	// missing records are created rather than upserted.
+	b := recordBackend(api)
+	for _, r := range missing {
+		_, err := b.CreateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.CreateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			TTL:      r.TTL,
+			Priority: r.Priority,
+		})
+		if err != nil {
+			return err
+		}
+		printf("Created %s record %s.\n", r.Type, r.Name)
+	}
+	return nil
+}
+
+// missingRecords returns the required records that have no equal among the
+// existing records.
+func missingRecords(required, existing []cloudflare.DNSRecord) []cloudflare.DNSRecord {
+	var missing []cloudflare.DNSRecord
+	for _, r := range required {
+		found := false
+		for _, e := range existing {
+			if strings.EqualFold(r.Type, e.Type) &&
+				cflib.NormalizeName(r.Name) == cflib.NormalizeName(e.Name) &&
+				cflib.ContentEqual(r.Type, r.Content, e.Content) &&
+				(r.Priority == nil || e.Priority != nil && *r.Priority == *e.Priority) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
+
+func disableEmail(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, force bool) error {
+	if !force && !confirm(sprintf("Disable email routing for zone %s?", activeZoneName)) {
+		printf("Email routing left enabled.\n")
+		return nil
+	}
+	if _, err := api.DisableEmailRouting(commandCtx, zoneID); err != nil {
+		return err
+	}
+	printf("Email routing disabled.\n")
+	return nil
+}
+
+func listEmailRules(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) error {
+	rules, _, err := api.ListEmailRoutingRules(commandCtx, zoneID, cloudflare.ListEmailRoutingRulesParameters{})
+	if err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		printf("No email routing rules defined.\n")
+		return nil
+	}
+
+	for _, r := range rules {
+		status := ""
+		if r.Enabled != nil && !*r.Enabled {
+			status = " (disabled)"
+		}
+		fmt.Fprintf(output, "%-30s -> %s%s  [%s]\n", ruleMatch(r), ruleActions(r), status, r.Tag)
+	}
+	return nil
+}
+
+// ruleMatch describes the addresses an email routing rule matches.
+func ruleMatch(r cloudflare.EmailRoutingRule) string {
+	var m []string
+	for _, matcher := range r.Matchers {
+		switch matcher.Type {
+		case "literal":
+			m = append(m, matcher.Value)
+		default:
+			m = append(m, matcher.Type)
+		}
+	}
+	return strings.Join(m, ", ")
+}
+
+// ruleActions describes what an email routing rule does with a message.
+func ruleActions(r cloudflare.EmailRoutingRule) string {
+	var a []string
+	for _, action := range r.Actions {
+		if len(action.Value) == 0 {
+			a = append(a, action.Type)
+			continue
+		}
+		a = append(a, action.Type+" "+strings.Join(action.Value, ", "))
+	}
+	return strings.Join(a, "; ")
+}
+
+// addEmailRule adds a rule forwarding an address of the zone to a
+// destination, which is registered with the account first if necessary.
+func addEmailRule(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, alias, dest string) error {
+	addr := emailAddress(alias)
+	for _, a := range []string{addr, dest} {
+		if _, err := mail.ParseAddress(a); err != nil {
+			return argError(fmt.Errorf("invalid email address %q", a))
+		}
+	}
+	if !strings.HasSuffix(strings.ToLower(addr), "@"+cflib.NormalizeName(activeZoneName)) {
+		return argError(fmt.Errorf("%s is not an address of zone %s", addr, activeZoneName))
+	}
+
+	accountID, err := getAccountID(api, zoneID)
+	if err != nil {
+		return err
+	}
+	account := cloudflare.AccountIdentifier(accountID)
+	dests, _, err := api.ListEmailRoutingDestinationAddresses(commandCtx, account,
+		cloudflare.ListEmailRoutingAddressParameters{})
+	if err != nil {
+		return err
+	}
+	verified, registered := false, false
+	for _, d := range dests {
+		if strings.EqualFold(d.Email, dest) {
+			registered, verified = true, d.Verified != nil
+		}
+	}
+	if !registered {
+		_, err := api.CreateEmailRoutingDestinationAddress(commandCtx, account,
+			cloudflare.CreateEmailRoutingAddressParameters{Email: dest})
+		if err != nil {
+			return err
+		}
+	}
+
+	enabled := true
+	_, err = api.CreateEmailRoutingRule(commandCtx, zoneID, cloudflare.CreateEmailRoutingRuleParameters{
+		Name:     "Forward " + addr + " to " + dest,
+		Enabled:  &enabled,
+		Matchers: []cloudflare.EmailRoutingRuleMatcher{{Type: "literal", Field: "to", Value: addr}},
+		Actions:  []cloudflare.EmailRoutingRuleAction{{Type: "forward", Value: []string{dest}}},
+	})
+	if err != nil {
+		return err
+	}
+
+	printf("Email to %s is forwarded to %s.\n", addr, dest)
+	if !verified {
+		printf("Messages are delivered once %s is verified using the email sent to it.\n", dest)
+	}
+	return nil
+}
+
+// deleteEmailRule deletes the rule matching an address of the zone, or with
+// a tag.
+func deleteEmailRule(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, target string, force bool) error {
+	rules, _, err := api.ListEmailRoutingRules(commandCtx, zoneID, cloudflare.ListEmailRoutingRulesParameters{})
+	if err != nil {
+		return err
+	}
+
+	addr := emailAddress(target)
+	var match *cloudflare.EmailRoutingRule
+	for i, r := range rules {
+		if r.Tag == target || strings.EqualFold(ruleMatch(r), addr) {
+			match = &rules[i]
+			break
+		}
+	}
+	if match == nil {
+		return errNoMatch
+	}
+
+	desc := ruleMatch(*match) + " -> " + ruleActions(*match)
+	if !force && !confirm(sprintf("Delete email routing rule %s?", desc)) {
+		printf("No rules deleted.\n")
+		return nil
+	}
+	if _, err := api.DeleteEmailRoutingRule(commandCtx, zoneID, match.Tag); err != nil {
+		return err
+	}
+	printf("Deleted email routing rule %s.\n", desc)
+	return nil
+}
@@ -0,0 +1,158 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"slices"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// Exit codes returned by cf in non-interactive mode.
+const (
+	exitSuccess  = 0
+	exitFailure  = 1 // API or other general failure
+	exitUsage    = 2 // invalid command or arguments
+	exitAuth     = 3 // missing or rejected credentials
+	exitNotFound = 4 // zone not found
+
+	exitInterrupted = 130 // interrupted with Ctrl-C
+)
+
+// errQuit is returned by the quit command to end an interactive session.
+var errQuit = errors.New("exiting program")
+
+// errUsage is returned by a command handler after displaying the command's
+// usage in response to invalid arguments.
+var errUsage = errors.New("invalid usage")
+
+// errNoMatch is returned when no DNS records match a command's criteria.
+var errNoMatch = errors.New("no matching record(s) found")
+
+// errNotExist is returned by the exists command when no matching record
+// exists. It is never displayed.
+var errNotExist = errors.New("record does not exist")
+
+// errCommandNotFound and errCommandAmbiguous are the errors of command
+// lines naming no command, or the prefix of more than one.
+var (
+	errCommandNotFound  = errors.New("command not found")
+	errCommandAmbiguous = errors.New("command ambiguous")
+)
+
+// errInterrupted is returned by a command interrupted with Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// A codedError is an error associated with a specific exit code.
+type codedError struct {
+	code int
+	err  error
+}
+
+func (e *codedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *codedError) Unwrap() error {
+	return e.err
+}
+
+// usageError displays a command's usage and returns errUsage.
+func usageError(c *cmd.Command) error {
+	c.DisplayUsage(output)
+	return errUsage
+}
+
+// authError wraps an error caused by missing or invalid credentials.
+func authError(err error) error {
+	return &codedError{exitAuth, err}
+}
+
+// zoneError wraps an error caused by a zone that could not be found.
+func zoneError(err error) error {
+	return &codedError{exitNotFound, err}
+}
+
+// argError wraps an error caused by invalid command arguments or flags.
+func argError(err error) error {
+	return &codedError{exitUsage, err}
+}
+
+// exitCode returns the process exit code corresponding to an error
+// returned by a command handler.
+func exitCode(err error) int {
+	var ce *codedError
+	var cfErr *cloudflare.Error
+	switch {
+	case err == nil || err == errQuit:
+		return exitSuccess
+	case err == errUsage || err == errCommandNotFound || err == errCommandAmbiguous:
+		return exitUsage
+	case err == errInterrupted:
+		return exitInterrupted
+	case errors.As(err, &ce):
+		return ce.code
+	case errors.As(err, &cfErr) &&
+		(cfErr.StatusCode == http.StatusUnauthorized || cfErr.StatusCode == http.StatusForbidden):
+		return exitAuth
+	default:
+		return exitFailure
+	}
+}
+
+// rateLimitHint is the hint for errors caused by exceeding the API's rate
+// limit, which are reported with a status code rather than an error code.
+const rateLimitHint = "Cloudflare is limiting the rate of requests. Wait a few minutes, " +
+	"or lower CLOUDFLARE_RATE_LIMIT; changes that failed in a bulk run can be " +
+	"reattempted with \"retry\"."
+
+// errorHints suggest how to resolve the Cloudflare API errors most often
+// met, by error code.
+var errorHints = []struct {
+	codes []int
+	hint  string
+}{
+	{[]int{81053, 81054, 81057, 81058},
+		"A conflicting record already exists. \"get <type> <name>\" shows it; " +
+			"use \"update\" to change it, or \"upsert\" to create or update records in one step."},
+	{[]int{81044},
+		"The record no longer exists. \"refresh\" discards the cached records."},
+	{[]int{6003, 6111, 9103, 9106, 9109},
+		"The credentials were rejected. Check them with \"account verify\", or " +
+			"remove the stored credentials with \"logout\" and enter new ones."},
+	{[]int{10000},
+		"The credentials lack permission for this request. \"account permissions\" " +
+			"lists the permissions of the API token in use."},
+	{[]int{971}, rateLimitHint},
+	{[]int{1061},
+		"The zone already exists. \"zone <domain>\" selects it, and \"zone onboard " +
+			"<domain>\" continues its onboarding."},
+	{[]int{7003},
+		"The zone or object was not found. \"zones\" lists the zones the " +
+			"credentials can access, and \"refresh\" discards the cached zones."},
+}
+
+// errorHint returns a suggestion for resolving a Cloudflare API error, or
+// the empty string if there is none.
+func errorHint(err error) string {
+	var cfErr *cloudflare.Error
+	if !errors.As(err, &cfErr) {
+		return ""
+	}
+	for _, h := range errorHints {
+		for _, code := range h.codes {
+			if slices.Contains(cfErr.ErrorCodes, code) {
+				return h.hint
+			}
+		}
+	}
+	if cfErr.StatusCode == http.StatusTooManyRequests || cfErr.Type == cloudflare.ErrorTypeRateLimit {
+		return rateLimitHint
+	}
+	return ""
+}
@@ -0,0 +1,742 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+)
+
+// The functions in this file check the rules language expressions used by
+// Cloudflare's WAF, redirect and cache rules, so that mistakes are caught
+// before a rule is submitted. Only the syntax, the names of the fields and
+// functions, and the types of the values compared are checked.
+
+// An exprType is the type of a field or value in an expression.
+type exprType struct {
+	kind string    // bytes, int, bool, ip, array or map
+	elem *exprType // the element type of an array or map
+	each bool      // the value is computed for each element of an array
+}
+
+func (t exprType) String() string {
+	switch t.kind {
+	case "array", "map":
+		return fmt.Sprintf("%s<%s>", t.kind, t.elem)
+	case "bytes":
+		return "string"
+	default:
+		return t.kind
+	}
+}
+
+var (
+	typeBytes = exprType{kind: "bytes"}
+	typeInt   = exprType{kind: "int"}
+	typeBool  = exprType{kind: "bool"}
+	typeIP    = exprType{kind: "ip"}
+)
+
+func arrayOf(t exprType) exprType { return exprType{kind: "array", elem: &t} }
+func mapOf(t exprType) exprType   { return exprType{kind: "map", elem: &t} }
+
+// exprFields are the fields of the rules language known to cf.
+var exprFields = map[string]exprType{
+	"cf.bot_management.detection_ids":       arrayOf(typeInt),
+	"cf.bot_management.ja3_hash":            typeBytes,
+	"cf.bot_management.ja4":                 typeBytes,
+	"cf.bot_management.js_detection.passed": typeBool,
+	"cf.bot_management.score":               typeInt,
+	"cf.bot_management.static_resource":     typeBool,
+	"cf.bot_management.verified_bot":        typeBool,
+	"cf.client.bot":                         typeBool,
+	"cf.edge.server_ip":                     typeIP,
+	"cf.edge.server_port":                   typeInt,
+	"cf.hostname.metadata":                  typeBytes,
+	"cf.threat_score":                       typeInt,
+	"cf.tls_client_auth.cert_presented":     typeBool,
+	"cf.tls_client_auth.cert_verified":      typeBool,
+	"cf.verified_bot_category":              typeBytes,
+	"cf.waf.score":                          typeInt,
+	"cf.waf.score.rce":                      typeInt,
+	"cf.waf.score.sqli":                     typeInt,
+	"cf.waf.score.xss":                      typeInt,
+	"http.cookie":                           typeBytes,
+	"http.host":                             typeBytes,
+	"http.referer":                          typeBytes,
+	"http.request.accepted_languages":       arrayOf(typeBytes),
+	"http.request.body.form":                mapOf(arrayOf(typeBytes)),
+	"http.request.body.mime":                typeBytes,
+	"http.request.body.raw":                 typeBytes,
+	"http.request.body.size":                typeInt,
+	"http.request.body.truncated":           typeBool,
+	"http.request.cookies":                  mapOf(arrayOf(typeBytes)),
+	"http.request.full_uri":                 typeBytes,
+	"http.request.headers":                  mapOf(arrayOf(typeBytes)),
+	"http.request.headers.names":            arrayOf(typeBytes),
+	"http.request.headers.truncated":        typeBool,
+	"http.request.headers.values":           arrayOf(typeBytes),
+	"http.request.method":                   typeBytes,
+	"http.request.timestamp.msec":           typeInt,
+	"http.request.timestamp.sec":            typeInt,
+	"http.request.uri":                      typeBytes,
+	"http.request.uri.args":                 mapOf(arrayOf(typeBytes)),
+	"http.request.uri.args.names":           arrayOf(typeBytes),
+	"http.request.uri.args.values":          arrayOf(typeBytes),
+	"http.request.uri.path":                 typeBytes,
+	"http.request.uri.path.extension":       typeBytes,
+	"http.request.uri.query":                typeBytes,
+	"http.request.version":                  typeBytes,
+	"http.response.code":                    typeInt,
+	"http.response.headers":                 mapOf(arrayOf(typeBytes)),
+	"http.user_agent":                       typeBytes,
+	"http.x_forwarded_for":                  typeBytes,
+	"ip.geoip.asnum":                        typeInt,
+	"ip.geoip.continent":                    typeBytes,
+	"ip.geoip.country":                      typeBytes,
+	"ip.geoip.is_in_european_union":         typeBool,
+	"ip.geoip.subdivision_1_iso_code":       typeBytes,
+	"ip.src":                                typeIP,
+	"ip.src.asnum":                          typeInt,
+	"ip.src.city":                           typeBytes,
+	"ip.src.continent":                      typeBytes,
+	"ip.src.country":                        typeBytes,
+	"ip.src.is_in_european_union":           typeBool,
+	"ip.src.postal_code":                    typeBytes,
+	"ip.src.region_code":                    typeBytes,
+	"ip.src.timezone.name":                  typeBytes,
+	"raw.http.request.full_uri":             typeBytes,
+	"raw.http.request.uri":                  typeBytes,
+	"raw.http.request.uri.args":             mapOf(arrayOf(typeBytes)),
+	"raw.http.request.uri.path":             typeBytes,
+	"raw.http.request.uri.path.extension":   typeBytes,
+	"raw.http.request.uri.query":            typeBytes,
+	"ssl":                                   typeBool,
+}
+
+// An exprFunc describes a function of the rules language: the number of
+// arguments it accepts and the type it returns. A nil result means the
+// function returns the type of its first argument.
+type exprFunc struct {
+	min, max int
+	result   *exprType
+}
+
+var exprFuncs = map[string]exprFunc{
+	"all":                    {1, 1, &typeBool},
+	"any":                    {1, 1, &typeBool},
+	"cidr":                   {3, 3, &typeIP},
+	"cidr6":                  {2, 2, &typeIP},
+	"concat":                 {1, 99, nil},
+	"decode_base64":          {1, 1, &typeBytes},
+	"ends_with":              {2, 2, &typeBool},
+	"has_key":                {2, 2, &typeBool},
+	"has_value":              {2, 2, &typeBool},
+	"is_timed_hmac_valid_v0": {2, 5, &typeBool},
+	"len":                    {1, 1, &typeInt},
+	"lookup_json_integer":    {2, 99, &typeInt},
+	"lookup_json_string":     {2, 99, &typeBytes},
+	"lower":                  {1, 1, &typeBytes},
+	"regex_replace":          {3, 3, &typeBytes},
+	"remove_bytes":           {2, 2, &typeBytes},
+	"starts_with":            {2, 2, &typeBool},
+	"substring":              {2, 3, &typeBytes},
+	"to_string":              {1, 1, &typeBytes},
+	"upper":                  {1, 1, &typeBytes},
+	"url_decode":             {1, 2, &typeBytes},
+	"uuidv4":                 {1, 1, &typeBytes},
+	"wildcard_replace":       {3, 4, &typeBytes},
+}
+
+// exprOperators maps the comparison operators, in both their English and
+// C-like forms, to their English names.
+var exprOperators = map[string]string{
+	"eq": "eq", "==": "eq",
+	"ne": "ne", "!=": "ne",
+	"lt": "lt", "<": "lt",
+	"le": "le", "<=": "le",
+	"gt": "gt", ">": "gt",
+	"ge": "ge", ">=": "ge",
+	"contains": "contains",
+	"matches":  "matches", "~": "matches",
+	"wildcard": "wildcard",
+	"strict":   "strict wildcard",
+	"in":       "in",
+}
+
+// An exprError is an error at a position in an expression.
+type exprError struct {
+	pos int
+	msg string
+}
+
+func (e *exprError) Error() string {
+	return fmt.Sprintf("position %d: %s", e.pos+1, e.msg)
+}
+
+// An exprToken is a lexical token of an expression.
+type exprToken struct {
+	kind string // word, string, list, punct or end
+	text string
+	pos  int
+}
+
+// lexExpression splits an expression into tokens.
+func lexExpression(s string) ([]exprToken, error) {
+	var toks []exprToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+
+		case c == '"':
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' {
+					j++
+				}
+			}
+			if j >= len(s) {
+				return nil, &exprError{i, "unterminated string"}
+			}
+			toks = append(toks, exprToken{"string", s[i : j+1], i})
+			i = j + 1
+
+		case c == 'r' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '#'):
+			j := i + 1
+			for j < len(s) && s[j] == '#' {
+				j++
+			}
+			hashes := s[i+1 : j]
+			if j >= len(s) || s[j] != '"' {
+				return nil, &exprError{i, "invalid raw string"}
+			}
+			end := strings.Index(s[j+1:], `"`+hashes)
+			if end < 0 {
+				return nil, &exprError{i, "unterminated raw string"}
+			}
+			n := j + 1 + end + 1 + len(hashes)
+			toks = append(toks, exprToken{"string", s[i:n], i})
+			i = n
+
+		case c == '$':
+			j := i + 1
+			for j < len(s) && isExprWordChar(s[j]) {
+				j++
+			}
+			if j == i+1 {
+				return nil, &exprError{i, "missing list name after $"}
+			}
+			toks = append(toks, exprToken{"list", s[i:j], i})
+			i = j
+
+		case isExprWordChar(c) || c == ':' || (c == '-' && i+1 < len(s) && s[i+1] >= '0' && s[i+1] <= '9'):
+			j := i + 1
+			for j < len(s) && (isExprWordChar(s[j]) || s[j] == ':' || s[j] == '/') {
+				j++
+			}
+			toks = append(toks, exprToken{"word", s[i:j], i})
+			i = j
+
+		default:
+			op := ""
+			for _, p := range []string{"==", "!=", "<=", ">=", "&&", "||", "^^"} {
+				if strings.HasPrefix(s[i:], p) {
+					op = p
+					break
+				}
+			}
+			if op == "" && strings.IndexByte("()[]{},*<>~!", c) >= 0 {
+				op = string(c)
+			}
+			if op == "" {
+				return nil, &exprError{i, fmt.Sprintf("unexpected character %q", c)}
+			}
+			toks = append(toks, exprToken{"punct", op, i})
+			i += len(op)
+		}
+	}
+	return append(toks, exprToken{"end", "", len(s)}), nil
+}
+
+// usesRegex reports whether an expression uses the matches operator, which
+// only some plans offer.
+func usesRegex(expr string) bool {
+	toks, err := lexExpression(expr)
+	if err != nil {
+		return false
+	}
+	for _, tok := range toks {
+		if tok.kind != "string" && exprOperators[tok.text] == "matches" {
+			return true
+		}
+	}
+	return false
+}
+
+func isExprWordChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '_' || c == '.'
+}
+
+// An exprParser checks the tokens of an expression.
+type exprParser struct {
+	toks []exprToken
+	i    int
+}
+
+// checkExpression checks the syntax and types of an expression.
+func checkExpression(s string) error {
+	toks, err := lexExpression(s)
+	if err != nil {
+		return err
+	}
+	p := &exprParser{toks: toks}
+	if p.peek().kind == "end" {
+		return &exprError{0, "empty expression"}
+	}
+	t, err := p.parseLogical(0)
+	if err != nil {
+		return err
+	}
+	if tok := p.peek(); tok.kind != "end" {
+		return &exprError{tok.pos, fmt.Sprintf("unexpected %s", tok.text)}
+	}
+	if t.kind != "bool" || t.each {
+		return &exprError{0, fmt.Sprintf("expression is of type %s, not a condition", describeType(t))}
+	}
+	return nil
+}
+
+func (p *exprParser) peek() exprToken {
+	return p.toks[p.i]
+}
+
+func (p *exprParser) next() exprToken {
+	tok := p.toks[p.i]
+	if tok.kind != "end" {
+		p.i++
+	}
+	return tok
+}
+
+func (p *exprParser) expect(text string) error {
+	tok := p.next()
+	if tok.text != text || (tok.kind != "punct" && tok.kind != "word") {
+		return &exprError{tok.pos, fmt.Sprintf("expected %s, found %s", text, describeToken(tok))}
+	}
+	return nil
+}
+
+// exprLogical lists the logical operators by increasing precedence.
+var exprLogical = [][]string{
+	{"or", "||"},
+	{"xor", "^^"},
+	{"and", "&&"},
+}
+
+// parseLogical parses operands joined by the logical operators of a
+// precedence level and above.
+func (p *exprParser) parseLogical(level int) (exprType, error) {
+	if level == len(exprLogical) {
+		return p.parseNot()
+	}
+	t, err := p.parseLogical(level + 1)
+	if err != nil {
+		return t, err
+	}
+	for {
+		tok := p.peek()
+		if !isOneOf(tok.text, exprLogical[level]) {
+			return t, nil
+		}
+		if err := requireBool(t, tok); err != nil {
+			return t, err
+		}
+		p.next()
+		rhs, err := p.parseLogical(level + 1)
+		if err != nil {
+			return t, err
+		}
+		if err := requireBool(rhs, tok); err != nil {
+			return t, err
+		}
+	}
+}
+
+func (p *exprParser) parseNot() (exprType, error) {
+	if tok := p.peek(); tok.text == "not" || tok.text == "!" {
+		p.next()
+		t, err := p.parseNot()
+		if err != nil {
+			return t, err
+		}
+		return typeBool, requireBool(t, tok)
+	}
+	return p.parseComparison()
+}
+
+// parseComparison parses a parenthesized expression, or a value optionally
+// compared with another.
+func (p *exprParser) parseComparison() (exprType, error) {
+	if p.peek().text == "(" {
+		p.next()
+		t, err := p.parseLogical(0)
+		if err != nil {
+			return t, err
+		}
+		return t, p.expect(")")
+	}
+
+	start := p.peek()
+	lhs, err := p.parseValue()
+	if err != nil {
+		return lhs, err
+	}
+
+	tok := p.peek()
+	op, ok := exprOperators[tok.text]
+	if !ok || tok.kind != "word" && tok.kind != "punct" {
+		return lhs, nil
+	}
+	p.next()
+	if op == "strict wildcard" {
+		if err := p.expect("wildcard"); err != nil {
+			return lhs, err
+		}
+	}
+
+	base := lhs
+	base.each = false
+	switch {
+	case base.kind == "bool":
+		return lhs, &exprError{start.pos, fmt.Sprintf("%s is a boolean, which is used alone rather than compared", start.text)}
+	case base.kind == "array" || base.kind == "map":
+		return lhs, &exprError{start.pos, fmt.Sprintf("%s is of type %s; select an element with [...]", start.text, base)}
+	case (op == "contains" || op == "matches" || op == "wildcard" || op == "strict wildcard") && base.kind != "bytes":
+		return lhs, &exprError{tok.pos, fmt.Sprintf("operator %s requires a string, not %s", tok.text, describeType(base))}
+	case (op == "lt" || op == "le" || op == "gt" || op == "ge") && base.kind == "ip":
+		return lhs, &exprError{tok.pos, fmt.Sprintf("operator %s cannot compare IP addresses", tok.text)}
+	}
+
+	if op == "in" {
+		err = p.parseSet(base)
+	} else {
+		err = p.parseOperand(base, op)
+	}
+	result := typeBool
+	result.each = lhs.each
+	return result, err
+}
+
+// parseValue parses a field, with any element selections, or a function
+// call.
+func (p *exprParser) parseValue() (exprType, error) {
+	tok := p.next()
+	if tok.kind != "word" {
+		return exprType{}, &exprError{tok.pos, fmt.Sprintf("expected a field or function, found %s", describeToken(tok))}
+	}
+
+	var t exprType
+	if p.peek().text == "(" {
+		f, ok := exprFuncs[tok.text]
+		if !ok {
+			return t, &exprError{tok.pos, "unknown function " + tok.text + suggestion(tok.text, funcNames())}
+		}
+		p.next()
+		var args []exprType
+		for p.peek().text != ")" {
+			if len(args) > 0 {
+				if err := p.expect(","); err != nil {
+					return t, err
+				}
+			}
+			arg, err := p.parseArg()
+			if err != nil {
+				return t, err
+			}
+			args = append(args, arg)
+		}
+		p.next()
+		if len(args) < f.min || len(args) > f.max {
+			return t, &exprError{tok.pos, fmt.Sprintf("wrong number of arguments to %s", tok.text)}
+		}
+		if (tok.text == "any" || tok.text == "all") && (args[0].kind != "bool" || !args[0].each) {
+			return t, &exprError{tok.pos, fmt.Sprintf("%s requires a comparison of the elements of an array, such as %s(x[*] eq 1)", tok.text, tok.text)}
+		}
+		if f.result != nil {
+			t = *f.result
+		} else {
+			t = args[0]
+		}
+		if tok.text != "any" && tok.text != "all" {
+			for _, a := range args {
+				t.each = t.each || a.each
+			}
+		}
+	} else {
+		var ok bool
+		if t, ok = exprFields[tok.text]; !ok {
+			return t, &exprError{tok.pos, "unknown field " + tok.text + suggestion(tok.text, fieldNames())}
+		}
+	}
+
+	for p.peek().text == "[" {
+		open := p.next()
+		idx := p.next()
+		switch {
+		case t.kind == "array" && (idx.text == "*" || isInt(idx)):
+			t = exprType{kind: t.elem.kind, elem: t.elem.elem, each: t.each || idx.text == "*"}
+		case t.kind == "map" && idx.kind == "string":
+			t = exprType{kind: t.elem.kind, elem: t.elem.elem, each: t.each}
+		case t.kind == "map" && idx.text == "*":
+			t = exprType{kind: t.elem.kind, elem: t.elem.elem, each: true}
+		default:
+			return t, &exprError{open.pos, fmt.Sprintf("cannot select %s from a value of type %s", idx.text, t)}
+		}
+		if err := p.expect("]"); err != nil {
+			return t, err
+		}
+	}
+	return t, nil
+}
+
+// parseArg parses a function argument: a literal or an expression.
+func (p *exprParser) parseArg() (exprType, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == "string":
+		p.next()
+		return typeBytes, nil
+	case isInt(tok):
+		p.next()
+		return typeInt, nil
+	}
+	return p.parseLogical(0)
+}
+
+// parseOperand parses the literal compared with a value of a type.
+func (p *exprParser) parseOperand(t exprType, op string) error {
+	tok := p.next()
+	if op == "matches" && tok.kind != "string" {
+		return &exprError{tok.pos, "matches requires a regular expression string"}
+	}
+	return checkLiteral(tok, t, false)
+}
+
+// parseSet parses the set or list of values following "in".
+func (p *exprParser) parseSet(t exprType) error {
+	tok := p.next()
+	if tok.kind == "list" {
+		return nil
+	}
+	if tok.text != "{" {
+		return &exprError{tok.pos, fmt.Sprintf("expected a set {...} or a $list, found %s", describeToken(tok))}
+	}
+	n := 0
+	for {
+		tok := p.next()
+		switch {
+		case tok.text == "}" && tok.kind == "punct":
+			if n == 0 {
+				return &exprError{tok.pos, "empty set"}
+			}
+			return nil
+		case tok.kind == "end":
+			return &exprError{tok.pos, "unterminated set"}
+		case tok.text == ",":
+			return &exprError{tok.pos, "set values are separated by spaces, not commas"}
+		}
+		if err := checkLiteral(tok, t, true); err != nil {
+			return err
+		}
+		n++
+	}
+}
+
+// checkLiteral checks that a token is a literal of a type. Ranges such as
+// 1..10 are accepted in sets.
+func checkLiteral(tok exprToken, t exprType, inSet bool) error {
+	if inSet && tok.kind == "word" {
+		if lo, hi, ok := strings.Cut(tok.text, ".."); ok {
+			a, b := tok, tok
+			a.text, b.text = lo, hi
+			if err := checkLiteral(a, t, false); err != nil {
+				return err
+			}
+			return checkLiteral(b, t, false)
+		}
+	}
+
+	var ok bool
+	switch t.kind {
+	case "bytes":
+		ok = tok.kind == "string"
+	case "int":
+		ok = isInt(tok)
+	case "ip":
+		if tok.kind == "word" {
+			_, err := netip.ParseAddr(tok.text)
+			_, perr := netip.ParsePrefix(tok.text)
+			ok = err == nil || perr == nil
+		}
+	}
+	if !ok {
+		return &exprError{tok.pos, fmt.Sprintf("expected %s, found %s", describeType(t), describeToken(tok))}
+	}
+	return nil
+}
+
+func requireBool(t exprType, op exprToken) error {
+	if t.kind != "bool" || t.each {
+		return &exprError{op.pos, fmt.Sprintf("%s requires conditions, not %s", op.text, describeType(t))}
+	}
+	return nil
+}
+
+func isInt(tok exprToken) bool {
+	if tok.kind != "word" {
+		return false
+	}
+	_, err := strconv.ParseInt(tok.text, 10, 64)
+	return err == nil
+}
+
+func isOneOf(s string, list []string) bool {
+	for _, l := range list {
+		if s == l {
+			return true
+		}
+	}
+	return false
+}
+
+func describeType(t exprType) string {
+	switch {
+	case t.each:
+		return "a value for each element of an array (use any() or all())"
+	case t.kind == "bytes":
+		return "a string"
+	case t.kind == "int":
+		return "an integer"
+	case t.kind == "ip":
+		return "an IP address or CIDR range"
+	case t.kind == "bool":
+		return "a boolean"
+	default:
+		return t.String()
+	}
+}
+
+func describeToken(tok exprToken) string {
+	switch tok.kind {
+	case "end":
+		return "end of expression"
+	case "string":
+		return "string " + tok.text
+	default:
+		return tok.text
+	}
+}
+
+func fieldNames() []string {
+	names := make([]string, 0, len(exprFields))
+	for name := range exprFields {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func funcNames() []string {
+	names := make([]string, 0, len(exprFuncs))
+	for name := range exprFuncs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// suggestion returns a hint naming the known name closest to a misspelled
+// one, or the empty string if none is close.
+func suggestion(name string, known []string) string {
+	best, bestDist := "", 4
+	for _, k := range known {
+		if d := editDistance(name, k); d < bestDist {
+			best, bestDist = k, d
+		}
+	}
+	if best == "" {
+		return ""
+	}
+	return fmt.Sprintf(" (did you mean %s?)", best)
+}
+
+// editDistance returns the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	cur := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		cur[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(b)]
+}
+
+var exprFlags = registerFlags("expr", flagSpec{"file": true})
+
+func cmdExpr(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, exprFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 || args[0] != "test" || (len(args) == 1) == !flags.has("file") {
+		return usageError(c)
+	}
+
+	// The command line strips the double quotes from strings and expands
+	// $ references, so an expression using either is read from a file.
+	var expr string
+	if flags.has("file") {
+		b, err := os.ReadFile(flags.get("file", ""))
+		if err != nil {
+			return err
+		}
+		expr = strings.TrimSpace(string(b))
+	} else {
+		expr = strings.Join(args[1:], " ")
+	}
+
+	err = checkExpression(expr)
+	if err == nil {
+		printf("The expression is valid.\n")
+		return nil
+	}
+
+	var e *exprError
+	if errors.As(err, &e) && !strings.Contains(expr, "\n") {
+		fmt.Fprintln(output, expr)
+		fmt.Fprintln(output, strings.Repeat(" ", e.pos)+"^")
+	}
+	return argError(err)
+}
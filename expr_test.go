@@ -0,0 +1,71 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCheckExpression(t *testing.T) {
+	valid := []string{
+		`http.host eq "example.com"`,
+		`(http.request.uri.path contains "/admin" and not ip.src in {192.0.2.0/24 2001:db8::/32}) or ssl`,
+		`ip.src.asnum in {13335 64496..64511} && cf.threat_score > 10`,
+		`http.request.full_uri wildcard "*://old.example.com/*"`,
+		`http.user_agent strict wildcard "curl/*"`,
+		`http.request.uri.path ~ r"^/api/v[0-9]+/"`,
+		`any(http.request.headers["x-debug"][*] eq "1")`,
+		`lower(http.host) eq "example.com" ^^ ip.src in $blocked`,
+		`starts_with(http.request.uri.path, "/static/")`,
+		redirectRule("old.example.com/*", "https://new.example.com/$1", 301).Expression,
+	}
+	for _, s := range valid {
+		if err := checkExpression(s); err != nil {
+			t.Errorf("checkExpression(%s): %v", s, err)
+		}
+	}
+
+	invalid := []struct {
+		expr, err string
+	}{
+		{``, "empty expression"},
+		{`http.hots eq "example.com"`, "did you mean http.host?"},
+		{`http.host eq "example.com`, "unterminated string"},
+		{`http.host eq 80`, "expected a string"},
+		{`ip.src.asnum contains "133"`, "requires a string"},
+		{`ssl eq true`, "is a boolean"},
+		{`ip.src in {192.0.2.1, 192.0.2.2}`, "separated by spaces"},
+		{`http.host`, "not a condition"},
+		{`http.request.headers["x"][*] eq "1"`, "any() or all()"},
+		{`(http.host eq "a"`, "expected )"},
+		{`http.host eq "a" and`, "expected a field"},
+		{`lowr(http.host) eq "a"`, "did you mean lower?"},
+	}
+	for _, c := range invalid {
+		err := checkExpression(c.expr)
+		if err == nil || !strings.Contains(err.Error(), c.err) {
+			t.Errorf("checkExpression(%s) = %v, want error containing %q", c.expr, err, c.err)
+		}
+	}
+}
+
+func TestUsesRegex(t *testing.T) {
+	tests := []struct {
+		expr string
+		want bool
+	}{
+		{`http.host matches "^www\\."`, true},
+		{`http.request.uri.path ~ "^/api/"`, true},
+		{`http.host eq "matches"`, false},
+		{`http.host contains "~"`, false},
+		{`not ssl`, false},
+	}
+	for _, test := range tests {
+		if got := usesRegex(test.expr); got != test.want {
+			t.Errorf("usesRegex(%s) = %v, want %v", test.expr, got, test.want)
+		}
+	}
+}
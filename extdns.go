@@ -0,0 +1,328 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// external-dns records its ownership of a record in a TXT record whose
+// content has the form "heritage=external-dns,external-dns/owner=<owner>,
+// external-dns/resource=<resource>". Older versions name the TXT record
+// after the record it owns, and newer versions prefix the first label with
+// the record type, as in a-www.example.com.
+
+// An extDNSMarker is an external-dns ownership TXT record.
+type extDNSMarker struct {
+	cloudflare.DNSRecord
+	recType  string // type of the owned records, or "" for old markers
+	target   string // name of the owned records
+	owner    string
+	resource string
+}
+
+// parseExtDNSMarker interprets a TXT record as an external-dns ownership
+// marker.
+func parseExtDNSMarker(r cloudflare.DNSRecord) (extDNSMarker, bool) {
+	m := extDNSMarker{DNSRecord: r, target: r.Name}
+	if r.Type != "TXT" {
+		return m, false
+	}
+	fields := strings.Split(strings.Trim(r.Content, "\""), ",")
+	if fields[0] != "heritage=external-dns" {
+		return m, false
+	}
+	for _, f := range fields[1:] {
+		if v, ok := strings.CutPrefix(f, "external-dns/owner="); ok {
+			m.owner = v
+		} else if v, ok := strings.CutPrefix(f, "external-dns/resource="); ok {
+			m.resource = v
+		}
+	}
+
+	label, rest, _ := strings.Cut(r.Name, ".")
+	if t, name, ok := strings.Cut(label, "-"); ok && isRecordType(t) && name != "" {
+		m.recType = strings.ToUpper(t)
+		m.target = name
+		if rest != "" {
+			m.target += "." + rest
+		}
+	}
+	return m, true
+}
+
+func isRecordType(s string) bool {
+	for _, t := range recordTypes {
+		if strings.EqualFold(s, t) {
+			return true
+		}
+	}
+	return false
+}
+
+// extDNSMarkerName returns the name of the TXT record in which newer
+// versions of external-dns record their ownership of a record.
+func extDNSMarkerName(recType, name string) string {
+	return strings.ToLower(recType) + "-" + name
+}
+
+// owns reports whether a marker records external-dns's ownership of a
+// record.
+func (m extDNSMarker) owns(r cloudflare.DNSRecord) bool {
+	if !strings.EqualFold(m.target, r.Name) || isExtDNSMarker(r) {
+		return false
+	}
+	return m.recType == "" || m.recType == r.Type
+}
+
+func isExtDNSMarker(r cloudflare.DNSRecord) bool {
+	_, ok := parseExtDNSMarker(r)
+	return ok
+}
+
+var (
+	extDNSListFlags    = registerFlags("external-dns list", flagSpec{"owner": true})
+	extDNSAdoptFlags   = registerFlags("external-dns adopt", forceFlags)
+	extDNSReleaseFlags = registerFlags("external-dns release", flagSpec{"owner": true})
+)
+
+func cmdExternalDNS(c *cmd.Command, args []string) error {
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "list":
+		flags, args, err := parseFlags(args[1:], extDNSListFlags)
+		if err != nil {
+			return err
+		}
+		if len(args) != 0 {
+			return usageError(c)
+		}
+		return listExternalDNS(flags.get("owner", ""))
+	case "adopt":
+		flags, args, err := parseFlags(args[1:], extDNSAdoptFlags)
+		if err != nil {
+			return err
+		}
+		if len(args) != 2 {
+			return usageError(c)
+		}
+		return adoptExternalDNS(strings.ToUpper(args[0]), args[1], flags.force())
+	case "release":
+		flags, args, err := parseFlags(args[1:], extDNSReleaseFlags)
+		if err != nil {
+			return err
+		}
+		if len(args) != 2 || !flags.has("owner") {
+			return usageError(c)
+		}
+		return releaseExternalDNS(strings.ToUpper(args[0]), args[1], flags.get("owner", ""))
+	default:
+		return usageError(c)
+	}
+}
+
+// loadExtDNS returns the records of the active zone along with the
+// external-dns ownership markers among them.
+func loadExtDNS(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) ([]cloudflare.DNSRecord, []extDNSMarker, error) {
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return nil, nil, err
+	}
+	var markers []extDNSMarker
+	for _, r := range recs {
+		if m, ok := parseExtDNSMarker(r); ok {
+			markers = append(markers, m)
+		}
+	}
+	return recs, markers, nil
+}
+
+// listExternalDNS displays the records of the active zone owned by
+// external-dns, optionally only those of one owner, followed by any markers
+// whose records no longer exist.
+func listExternalDNS(owner string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recs, markers, err := loadExtDNS(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	type owned struct {
+		rec    cloudflare.DNSRecord
+		marker extDNSMarker
+	}
+	var found []owned
+	used := make(map[string]bool)
+	for _, r := range recs {
+		for _, m := range markers {
+			if m.owns(r) {
+				used[m.ID] = true
+				if owner == "" || m.owner == owner {
+					found = append(found, owned{r, m})
+				}
+				break
+			}
+		}
+	}
+
+	if len(found) == 0 {
+		printf("No records are owned by external-dns.\n")
+	}
+
+	widthType, widthName, widthOwner := 0, 0, 0
+	for _, o := range found {
+		widthType = max(widthType, len(o.rec.Type))
+		widthName = max(widthName, len(o.rec.Name))
+		widthOwner = max(widthOwner, len(o.marker.owner))
+	}
+	for _, o := range found {
+		fmt.Fprintf(output, "%-*s %-*s %-*s %s\n", widthType, o.rec.Type, widthName, o.rec.Name,
+			widthOwner, o.marker.owner, o.marker.resource)
+	}
+
+	for _, m := range markers {
+		if !used[m.ID] && (owner == "" || m.owner == owner) {
+			printf("Orphaned external-dns marker %s (owner %s).\n", m.Name, m.owner)
+		}
+	}
+	return nil
+}
+
+// adoptExternalDNS transfers the ownership of records from external-dns to
+// cf by deleting external-dns's markers and creating cf's own.
+func adoptExternalDNS(recType, name string, force bool) error {
+	if ownerID == "" {
+		return argError(errors.New("an owner must be set before records can be adopted"))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recs, markers, err := loadExtDNS(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	var targets []cloudflare.DNSRecord
+	for _, r := range recs {
+		if r.Type == recType && strings.EqualFold(r.Name, name) && !isExtDNSMarker(r) {
+			targets = append(targets, r)
+		}
+	}
+	if len(targets) == 0 {
+		return errNoMatch
+	}
+
+	var owning []extDNSMarker
+	for _, m := range markers {
+		if m.owns(targets[0]) {
+			owning = append(owning, m)
+		}
+	}
+	if len(owning) == 0 {
+		return fmt.Errorf("%s %s is not owned by external-dns", recType, name)
+	}
+
+	// An old-style marker covers every type of record with its name, so
+	// deleting it would release the name's other records too.
+	for _, m := range owning {
+		if m.recType == "" {
+			for _, r := range recs {
+				if r.Type != recType && m.owns(r) {
+					return fmt.Errorf("marker %s also owns the %s record named %s", m.Name, r.Type, r.Name)
+				}
+			}
+		}
+	}
+
+	if !force && !confirm(sprintf("Adopt %s %s from external-dns owner %s?", recType, name, owning[0].owner)) {
+		printf("Records not adopted.\n")
+		return nil
+	}
+
+	for _, m := range owning {
+		if err := recordBackend(api).DeleteDNSRecord(commandCtx, zoneID.Identifier, m.ID); err != nil {
+			return err
+		}
+	}
+	if err := markOwned(api, zoneID, recType, targets[0].Name); err != nil {
+		return err
+	}
+
+	printf("Adopted %s %s.\n", recType, name)
+	return nil
+}
+
+// releaseExternalDNS hands the ownership of records to an external-dns
+// owner, deleting cf's marker and creating external-dns's.
+func releaseExternalDNS(recType, name, owner string) error {
+	if err := validateOwner(owner); err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: recType, Name: name})
+	if err != nil {
+		return err
+	}
+	if len(recs) == 0 {
+		return errNoMatch
+	}
+
+	if err := checkOwner(api, zoneID, recType, name); err != nil {
+		return err
+	}
+
+	params := cloudflare.CreateDNSRecordParams{
+		Type:    "TXT",
+		Name:    extDNSMarkerName(recType, recs[0].Name),
+		Content: "heritage=external-dns,external-dns/owner=" + owner,
+		TTL:     ttlAuto,
+	}
+	if _, err := recordBackend(api).CreateDNSRecord(commandCtx, zoneID.Identifier, params); err != nil {
+		return err
+	}
+	if ownerID != "" {
+		if err := unmarkOwned(api, zoneID, recType, recs[0].Name); err != nil {
+			return err
+		}
+	}
+
+	printf("Released %s %s to external-dns owner %s.\n", recType, name, owner)
+	return nil
+}
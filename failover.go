@@ -0,0 +1,304 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// Defaults for the failover settings of the configuration file.
+const (
+	defaultDownAfter     = 3
+	defaultUpAfter       = 5
+	failoverProbeTimeout = 5 * time.Second
+)
+
+// A failoverRecord, defined in the configuration file, is an address record
+// the failover command points at a standby address while its primary
+// address fails its health check. Check is an http(s) URL, tcp:<host>:<port>
+// or icmp:<host>, and defaults to pinging the primary address. Notify is a
+// URL to which each change is posted as JSON, or a command run with the
+// change in its environment.
+type failoverRecord struct {
+	Zone      string `json:"zone"`
+	Name      string `json:"name"`
+	Primary   string `json:"primary"`
+	Standby   string `json:"standby"`
+	Check     string `json:"check,omitempty"`
+	DownAfter int    `json:"down_after,omitempty"` // failed checks before failing over
+	UpAfter   int    `json:"up_after,omitempty"`   // passed checks before failing back
+	Notify    string `json:"notify,omitempty"`
+
+	recType string
+	probe   func(ctx context.Context) error
+
+	onStandby bool // the record points at the standby address
+	passed    int  // consecutive passed checks
+	failed    int  // consecutive failed checks
+}
+
+// parse validates a failover record's settings.
+func (f *failoverRecord) parse() error {
+	if !inZone(f.Name, f.Zone) {
+		return fmt.Errorf("failover record %s is not in zone %s", f.Name, f.Zone)
+	}
+	primary, err := netip.ParseAddr(f.Primary)
+	if err != nil {
+		return fmt.Errorf("invalid failover primary address %q", f.Primary)
+	}
+	standby, err := netip.ParseAddr(f.Standby)
+	if err != nil || standby.Is4() != primary.Is4() {
+		return fmt.Errorf("invalid failover standby address %q", f.Standby)
+	}
+	f.recType = "A"
+	if primary.Is6() {
+		f.recType = "AAAA"
+	}
+
+	if f.DownAfter == 0 {
+		f.DownAfter = defaultDownAfter
+	}
+	if f.UpAfter == 0 {
+		f.UpAfter = defaultUpAfter
+	}
+	if f.DownAfter < 0 || f.UpAfter < 0 {
+		return fmt.Errorf("invalid failover thresholds for %s", f.Name)
+	}
+
+	check := f.Check
+	if check == "" {
+		check = "icmp:" + f.Primary
+	}
+	f.probe, err = parseProbe(check)
+	return err
+}
+
+// observe records the result of a health check of the primary address,
+// returning the address the record should now point at, or the empty string
+// if it should not change. The record fails over after DownAfter failed
+// checks in a row, and back after UpAfter passed checks in a row, so that a
+// flapping origin does not make the record flap too.
+func (f *failoverRecord) observe(healthy bool) string {
+	if healthy {
+		f.passed, f.failed = f.passed+1, 0
+		if f.onStandby && f.passed >= f.UpAfter {
+			return f.Primary
+		}
+	} else {
+		f.passed, f.failed = 0, f.failed+1
+		if !f.onStandby && f.failed >= f.DownAfter {
+			return f.Standby
+		}
+	}
+	return ""
+}
+
+// parseProbe returns a health check probing a target.
+func parseProbe(check string) (func(ctx context.Context) error, error) {
+	kind, arg, _ := strings.Cut(check, ":")
+	switch strings.ToLower(kind) {
+	case "http", "https":
+		return func(ctx context.Context) error {
+			req, err := http.NewRequestWithContext(ctx, http.MethodGet, check, nil)
+			if err != nil {
+				return err
+			}
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				return err
+			}
+			resp.Body.Close()
+			if resp.StatusCode >= 400 {
+				return fmt.Errorf("%s returned %s", check, resp.Status)
+			}
+			return nil
+		}, nil
+	case "tcp":
+		if _, _, err := net.SplitHostPort(arg); err != nil {
+			return nil, fmt.Errorf("invalid failover check %q", check)
+		}
+		return func(ctx context.Context) error {
+			var d net.Dialer
+			conn, err := d.DialContext(ctx, "tcp", arg)
+			if err != nil {
+				return err
+			}
+			return conn.Close()
+		}, nil
+	case "icmp":
+		if arg == "" {
+			return nil, fmt.Errorf("invalid failover check %q", check)
+		}
+		return func(ctx context.Context) error {
+			// Sending ICMP requires privileges, which the system's ping
+			// command already has.
+			args := []string{"-c", "1", arg}
+			if runtime.GOOS == "windows" {
+				args = []string{"-n", "1", arg}
+			}
+			if err := exec.CommandContext(ctx, "ping", args...).Run(); err != nil {
+				return fmt.Errorf("ping %s failed: %v", arg, err)
+			}
+			return nil
+		}, nil
+	default:
+		return nil, fmt.Errorf("invalid failover check %q", check)
+	}
+}
+
+var failoverFlags = registerFlags("failover", flagSpec{"interval": true, "once": false})
+
+func cmdFailover(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, failoverFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 || len(cfg.Failover) == 0 {
+		return usageError(c)
+	}
+
+	interval, err := time.ParseDuration(flags.get("interval", "30s"))
+	if err != nil || interval <= 0 {
+		return argError(fmt.Errorf("invalid interval %q", flags.get("interval", "")))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	// The records start in whichever state their current content shows.
+	records := cfg.Failover
+	zoneIDs := make([]*cloudflare.ResourceContainer, len(records))
+	for i := range records {
+		f := &records[i]
+		id, err := recordBackend(api).ZoneIDByName(f.Zone)
+		if err != nil {
+			return zoneError(err)
+		}
+		zoneIDs[i] = cloudflare.ZoneIdentifier(id)
+
+		recs, err := listRecords(api, zoneIDs[i], cloudflare.ListDNSRecordsParams{Type: f.recType, Name: f.Name})
+		if err != nil {
+			return err
+		}
+		f.onStandby = len(recs) > 0 && recs[0].Content == f.Standby
+		f.passed, f.failed = 0, 0
+	}
+
+	log.Printf("Starting failover monitoring of %d record(s).", len(records))
+	for {
+		for i := range records {
+			f := &records[i]
+			ctx, cancel := context.WithTimeout(commandCtx, failoverProbeTimeout)
+			err := f.probe(ctx)
+			cancel()
+			if commandCtx.Err() != nil {
+				return errInterrupted
+			}
+
+			if err != nil {
+				log.Printf("Health check of %s failed: %v", f.Primary, err)
+			}
+			addr := f.observe(err == nil)
+			if addr == "" {
+				continue
+			}
+			if err := failOver(api, zoneIDs[i], f, addr); err != nil {
+				log.Printf("Error updating %s record %s: %v", f.recType, f.Name, err)
+			}
+		}
+
+		if flags.has("once") {
+			return nil
+		}
+
+		if err := sleep(interval); err != nil {
+			return err
+		}
+	}
+}
+
+// failOver points a failover record at an address and sends the
+// notification of the change.
+func failOver(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, f *failoverRecord, addr string) error {
+	if _, err := upsertRecord(api, zoneID, f.recType, f.Name, addr, 0, recordMeta{}); err != nil {
+		return err
+	}
+	f.onStandby = addr == f.Standby
+	f.passed, f.failed = 0, 0
+
+	state := "primary"
+	if f.onStandby {
+		state = "standby"
+	}
+	log.Printf("Pointed %s record %s at %s address %s.", f.recType, f.Name, state, addr)
+	if f.Notify != "" {
+		// The command is run with CF_FAILOVER_NAME, CF_FAILOVER_STATE and
+		// CF_FAILOVER_ADDRESS set.
+		fields := map[string]string{"name": f.Name, "state": state, "address": addr}
+		if err := sendNotification(f.Notify, fields, "CF_FAILOVER_"); err != nil {
+			log.Printf("Error sending failover notification: %v", err)
+		}
+	}
+	return nil
+}
+
+// sendNotification posts fields, and the time, as JSON to a notification
+// target that is an http(s) URL. Any other target is a command, run with
+// each field in its environment as envPrefix followed by the upper-case
+// field name.
+func sendNotification(target string, fields map[string]string, envPrefix string) error {
+	ctx, cancel := context.WithTimeout(commandCtx, 30*time.Second)
+	defer cancel()
+
+	if strings.HasPrefix(target, "http://") || strings.HasPrefix(target, "https://") {
+		payload := map[string]string{"time": time.Now().UTC().Format(time.RFC3339)}
+		for k, v := range fields {
+			payload[k] = v
+		}
+		body, err := json.Marshal(payload)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %s", target, resp.Status)
+		}
+		return nil
+	}
+
+	c := shellCommand(ctx, target)
+	c.Env = os.Environ()
+	for k, v := range fields {
+		c.Env = append(c.Env, envPrefix+strings.ToUpper(k)+"="+v)
+	}
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	return c.Run()
+}
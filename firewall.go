@@ -0,0 +1,334 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// firewallPhase is the ruleset phase holding a zone's custom firewall rules.
+const firewallPhase = string(cloudflare.RulesetPhaseHTTPRequestFirewallCustom)
+
+// firewallActions maps the actions accepted on the command line to the
+// modes of IP access rules. The custom rule actions are the same, except
+// that they use "log" where access rules use "whitelist".
+var firewallActions = map[string]string{
+	"block":             "block",
+	"challenge":         "challenge",
+	"js-challenge":      "js_challenge",
+	"managed-challenge": "managed_challenge",
+	"allow":             "whitelist",
+}
+
+var firewallFlags = registerFlags("firewall", flagSpec{"note": true, "file": true})
+
+func cmdFirewall(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, firewallFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch {
+	case args[0] == "list":
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		return listFirewallRules()
+	case args[0] == "add":
+		if len(args) < 2 || (len(args) == 2) == !flags.has("file") {
+			return usageError(c)
+		}
+		expr := strings.Join(args[2:], " ")
+		if flags.has("file") {
+			b, err := os.ReadFile(flags.get("file", ""))
+			if err != nil {
+				return err
+			}
+			expr = strings.TrimSpace(string(b))
+		}
+		return addFirewallRule(args[1], expr, flags.get("note", ""))
+	case args[0] == "delete":
+		if len(args) != 2 {
+			return usageError(c)
+		}
+		return deleteFirewallRule(args[1])
+	case firewallActions[args[0]] != "":
+		if len(args) != 3 {
+			return usageError(c)
+		}
+		rule, err := accessRule(args[0], args[1], args[2])
+		if err != nil {
+			return argError(err)
+		}
+		rule.Notes = flags.get("note", "")
+		return addAccessRule(rule)
+	default:
+		return usageError(c)
+	}
+}
+
+// accessRule builds an IP access rule applying an action to requests from
+// an IP address or range, a country or an autonomous system.
+func accessRule(action, kind, value string) (cloudflare.AccessRule, error) {
+	rule := cloudflare.AccessRule{Mode: firewallActions[action]}
+	switch kind {
+	case "ip":
+		if prefix, err := netip.ParsePrefix(value); err == nil {
+			// Cloudflare accepts only /16 and /24 IPv4 ranges and /32, /48
+			// and /64 IPv6 ranges, and reports any other as an error.
+			rule.Configuration = cloudflare.AccessRuleConfiguration{Target: "ip_range", Value: prefix.Masked().String()}
+			break
+		}
+		addr, err := netip.ParseAddr(value)
+		if err != nil {
+			return rule, fmt.Errorf("invalid IP address %q", value)
+		}
+		target := "ip"
+		if addr.Is6() {
+			target = "ip6"
+		}
+		rule.Configuration = cloudflare.AccessRuleConfiguration{Target: target, Value: addr.String()}
+	case "country":
+		// Country codes are ISO 3166-1 alpha-2 codes, or T1 for Tor.
+		value = strings.ToUpper(value)
+		if len(value) != 2 || value[0] < 'A' || value[0] > 'Z' || !isAlnum(value[1]) {
+			return rule, fmt.Errorf("invalid country code %q", value)
+		}
+		rule.Configuration = cloudflare.AccessRuleConfiguration{Target: "country", Value: value}
+	case "asn":
+		n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToUpper(value), "AS"), 10, 32)
+		if err != nil {
+			return rule, fmt.Errorf("invalid autonomous system number %q", value)
+		}
+		rule.Configuration = cloudflare.AccessRuleConfiguration{Target: "asn", Value: "AS" + strconv.FormatUint(n, 10)}
+	default:
+		return rule, fmt.Errorf("unknown firewall rule target %q; use ip, country or asn", kind)
+	}
+	return rule, nil
+}
+
+// listAccessRules returns all IP access rules of a zone.
+func listAccessRules(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) ([]cloudflare.AccessRule, error) {
+	var rules []cloudflare.AccessRule
+	for page := 1; ; page++ {
+		resp, err := api.ListZoneAccessRules(commandCtx, zoneID.Identifier, cloudflare.AccessRule{}, page)
+		if err != nil {
+			return nil, err
+		}
+		rules = append(rules, resp.Result...)
+		if page >= resp.TotalPages {
+			return rules, nil
+		}
+	}
+}
+
+// getFirewallRuleset returns the zone's custom firewall rules ruleset, or
+// an empty one if the zone has no custom rules.
+func getFirewallRuleset(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) (cloudflare.Ruleset, error) {
+	rs, err := api.GetEntrypointRuleset(commandCtx, zoneID, firewallPhase)
+	if err != nil && !isNotFound(err) {
+		return rs, err
+	}
+	return rs, nil
+}
+
+func listFirewallRules() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	access, err := listAccessRules(api, zoneID)
+	if err != nil {
+		return err
+	}
+	rs, err := getFirewallRuleset(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	if len(access) == 0 && len(rs.Rules) == 0 {
+		printf("No firewall rules defined.\n")
+		return nil
+	}
+
+	if len(access) > 0 {
+		printf("IP access rules:\n")
+		for _, r := range access {
+			note := ""
+			if r.Notes != "" {
+				note = " (" + r.Notes + ")"
+			}
+			fmt.Fprintf(output, "  %-17s %-8s %-39s%s  [%s]\n", r.Mode, r.Configuration.Target,
+				r.Configuration.Value, note, r.ID)
+		}
+	}
+
+	if len(rs.Rules) > 0 {
+		printf("Custom rules:\n")
+		for _, r := range rs.Rules {
+			status := ""
+			if r.Enabled != nil && !*r.Enabled {
+				status = " (disabled)"
+			}
+			desc := r.Expression
+			if r.Description != "" {
+				desc = r.Description + ": " + desc
+			}
+			fmt.Fprintf(output, "  %-17s %s%s  [%s]\n", r.Action, desc, status, r.ID)
+		}
+	}
+	return nil
+}
+
+func addAccessRule(rule cloudflare.AccessRule) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	if _, err := api.CreateZoneAccessRule(commandCtx, zoneID.Identifier, rule); err != nil {
+		return err
+	}
+
+	printf("Firewall rule added.\n")
+	return nil
+}
+
+// addFirewallRule appends a custom rule applying an action to the requests
+// matching an expression.
+func addFirewallRule(action, expr, note string) error {
+	mode := firewallActions[action]
+	switch {
+	case action == "log":
+		mode = "log"
+	case mode == "" || mode == "whitelist":
+		return argError(fmt.Errorf("invalid custom rule action %q", action))
+	}
+	if err := checkExpression(expr); err != nil {
+		return argError(fmt.Errorf("invalid expression: %v", err))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	if usesRegex(expr) {
+		if err := requireCapability(api, zoneID, capRegexRules); err != nil {
+			return err
+		}
+	}
+
+	rs, err := getFirewallRuleset(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	// Existing rules are resubmitted with only the fields the API accepts
+	// on update.
+	var rules []cloudflare.RulesetRule
+	for _, r := range rs.Rules {
+		rules = append(rules, cloudflare.RulesetRule{
+			ID:               r.ID,
+			Action:           r.Action,
+			ActionParameters: r.ActionParameters,
+			Expression:       r.Expression,
+			Description:      r.Description,
+			Enabled:          r.Enabled,
+		})
+	}
+	enabled := true
+	rules = append(rules, cloudflare.RulesetRule{
+		Action:      mode,
+		Expression:  expr,
+		Description: note,
+		Enabled:     &enabled,
+	})
+
+	_, err = api.UpdateEntrypointRuleset(commandCtx, zoneID,
+		cloudflare.UpdateEntrypointRulesetParams{Phase: firewallPhase, Rules: rules})
+	if err != nil {
+		return err
+	}
+
+	printf("Firewall rule added.\n")
+	return nil
+}
+
+// deleteFirewallRule deletes the IP access rule with an ID or value, or the
+// custom rule with an ID.
+func deleteFirewallRule(which string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	access, err := listAccessRules(api, zoneID)
+	if err != nil {
+		return err
+	}
+	for _, r := range access {
+		if r.ID == which || strings.EqualFold(r.Configuration.Value, which) {
+			if _, err := api.DeleteZoneAccessRule(commandCtx, zoneID.Identifier, r.ID); err != nil {
+				return err
+			}
+			printf("Firewall rule deleted.\n")
+			return nil
+		}
+	}
+
+	rs, err := getFirewallRuleset(api, zoneID)
+	if err != nil {
+		return err
+	}
+	for _, r := range rs.Rules {
+		if r.ID == which {
+			err := api.DeleteRulesetRule(commandCtx, zoneID,
+				cloudflare.DeleteRulesetRuleParams{RulesetID: rs.ID, RulesetRuleID: r.ID})
+			if err != nil {
+				return err
+			}
+			printf("Firewall rule deleted.\n")
+			return nil
+		}
+	}
+
+	return fmt.Errorf("firewall rule %s not found", which)
+}
+
+func isAlnum(c byte) bool {
+	return 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+}
@@ -0,0 +1,235 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cmd"
+)
+
+// A flagSpec describes the flags accepted by a command. Each flag name
+// (without leading dashes) maps to true if the flag requires a value.
+type flagSpec map[string]bool
+
+// flagValues holds the flags parsed from a command's arguments, keyed by
+// flag name. Boolean flags are stored with an empty value.
+type flagValues map[string]string
+
+// parseFlags separates the flags in args from the positional arguments.
+// Flags may be written as -name, --name, --name=value or --name value. An
+// argument of "--" ends flag parsing.
+func parseFlags(args []string, spec flagSpec) (flagValues, []string, error) {
+	flags := make(flagValues)
+	var positional []string
+
+	for i := 0; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			positional = append(positional, args[i+1:]...)
+			break
+		}
+		if len(a) < 2 || a[0] != '-' {
+			positional = append(positional, a)
+			continue
+		}
+
+		name := strings.TrimLeft(a, "-")
+		value, hasValue := "", false
+		if j := strings.IndexByte(name, '='); j >= 0 {
+			name, value, hasValue = name[:j], name[j+1:], true
+		}
+
+		takesValue, ok := spec[name]
+		switch {
+		case !ok:
+			return nil, nil, argError(fmt.Errorf("unknown flag %s", a))
+		case takesValue && !hasValue:
+			if i+1 >= len(args) {
+				return nil, nil, argError(fmt.Errorf("flag %s requires a value", a))
+			}
+			i++
+			value = args[i]
+		case !takesValue && hasValue:
+			return nil, nil, argError(fmt.Errorf("flag -%s does not take a value", name))
+		}
+		flags[name] = value
+	}
+
+	return flags, positional, nil
+}
+
+func (f flagValues) has(name string) bool {
+	_, ok := f[name]
+	return ok
+}
+
+func (f flagValues) get(name, def string) string {
+	if v, ok := f[name]; ok {
+		return v
+	}
+	return def
+}
+
+// parseLeadingFlags parses the flags that appear before the first
+// positional argument, returning the flags and the remaining arguments.
+func parseLeadingFlags(args []string, spec flagSpec) (flagValues, []string, error) {
+	n := 0
+	for n < len(args) && len(args[n]) > 1 && args[n][0] == '-' && args[n] != "--" {
+		name := strings.TrimLeft(args[n], "-")
+		n++
+		if spec[name] && n < len(args) {
+			n++
+		}
+	}
+
+	flags, rest, err := parseFlags(args[:n], spec)
+	if err != nil {
+		return nil, nil, err
+	}
+	return flags, append(rest, args[n:]...), nil
+}
+
+// commandFlags are the leading flags that may also be given among the
+// arguments of any command, applying to that command alone. A command whose
+// registered flags include one of them, such as edit --json or list --zone,
+// handles the flag itself.
+var commandFlags = flagSpec{
+	"zone":            true,
+	"json":            false,
+	"dry-run":         false,
+	"override-freeze": false,
+}
+
+// commandSpecs holds the flags of each command, and of each subcommand
+// parsing flags of its own, keyed by the command's name followed by the
+// subcommand's, such as "delete" or "zone pull".
+var commandSpecs = make(map[string]flagSpec)
+
+// registerFlags registers the flags a command or subcommand parses,
+// returning them for its handler to parse its arguments with.
+func registerFlags(name string, spec flagSpec) flagSpec {
+	commandSpecs[name] = spec
+	return spec
+}
+
+// commandSpec returns the flags registered for a command, or for the
+// subcommand named by its leading arguments, and whether any are
+// registered.
+func commandSpec(c *cmd.Command, args []string) (flagSpec, bool) {
+	name := c.Name
+	spec, ok := commandSpecs[name]
+	for _, a := range args {
+		if strings.HasPrefix(a, "-") || !hasSubcommandFlags(name+" "+a) {
+			break
+		}
+		name += " " + a
+		if s, found := commandSpecs[name]; found {
+			spec, ok = s, true
+		}
+	}
+	return spec, ok
+}
+
+// hasSubcommandFlags reports whether flags are registered for a
+// subcommand, or for a subcommand below it such as "mail spf set" below
+// "mail spf".
+func hasSubcommandFlags(name string) bool {
+	for n := range commandSpecs {
+		if n == name || strings.HasPrefix(n, name+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+// registeredFlag reports whether a flag registered for a command or any
+// of its subcommands takes a value, and whether it is registered at all.
+func registeredFlag(command, flag string) (value, ok bool) {
+	for n, spec := range commandSpecs {
+		if n == command || strings.HasPrefix(n, command+" ") {
+			if v, found := spec[flag]; found {
+				value, ok = value || v, true
+			}
+		}
+	}
+	if !ok {
+		value, ok = commandFlags[flag]
+	}
+	return value, ok
+}
+
+// takeCommandFlags removes from a command's arguments the command flags
+// the command does not handle itself, returning their values. The flags
+// may appear anywhere before "--", which passes the arguments after it
+// through unchanged. The "--" itself is removed for commands without
+// flags of their own, which would otherwise receive it as an argument.
+func takeCommandFlags(c *cmd.Command, args []string) ([]string, flagValues, error) {
+	own, parsed := commandSpec(c, args)
+
+	flags := make(flagValues)
+	kept := args[:0:0]
+	i := 0
+	for ; i < len(args); i++ {
+		a := args[i]
+		if a == "--" {
+			break
+		}
+		if len(a) < 2 || a[0] != '-' {
+			kept = append(kept, a)
+			continue
+		}
+		name, value, hasValue := strings.Cut(strings.TrimLeft(a, "-"), "=")
+		takesValue, ok := commandFlags[name]
+		if _, handled := own[name]; handled || !ok {
+			// The command's own flags are kept, along with their values.
+			kept = append(kept, a)
+			if own[name] && !hasValue && i+1 < len(args) {
+				i++
+				kept = append(kept, args[i])
+			}
+			continue
+		}
+		switch {
+		case takesValue && !hasValue:
+			if i+1 >= len(args) {
+				return nil, nil, argError(fmt.Errorf("flag %s requires a value", a))
+			}
+			i++
+			value = args[i]
+		case !takesValue && hasValue:
+			return nil, nil, argError(fmt.Errorf("flag -%s does not take a value", name))
+		}
+		flags[name] = value
+	}
+	if i < len(args) && !parsed {
+		i++
+	}
+	return append(kept, args[i:]...), flags, nil
+}
+
+// forceFlags are the flags accepted by commands that ask for confirmation
+// before destroying data. Either flag skips the confirmation.
+var forceFlags = flagSpec{
+	"force": false,
+	"y":     false,
+}
+
+// mergeFlags returns a flag spec containing the flags of every spec.
+func mergeFlags(specs ...flagSpec) flagSpec {
+	merged := make(flagSpec)
+	for _, spec := range specs {
+		for name, takesValue := range spec {
+			merged[name] = takesValue
+		}
+	}
+	return merged
+}
+
+// force returns true if the flags request that confirmation be skipped.
+func (f flagValues) force() bool {
+	return f.has("force") || f.has("y")
+}
@@ -0,0 +1,160 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+)
+
+// A freezeWindow is a weekly period, defined in the configuration file,
+// during which changes to some or all zones are refused unless the command
+// is given --override-freeze. Start and End have the form "Fri 18:00".
+type freezeWindow struct {
+	Start    string   `json:"start"`
+	End      string   `json:"end"`
+	Zones    []string `json:"zones,omitempty"`    // all zones if empty
+	Timezone string   `json:"timezone,omitempty"` // local time if empty
+
+	start, end int // minutes since Sunday 00:00
+	loc        *time.Location
+}
+
+// minutesPerWeek is the length of the weekly cycle of freeze windows.
+const minutesPerWeek = 7 * 24 * 60
+
+// overrideFreeze is true while the running command may change zones during
+// a freeze window.
+var overrideFreeze bool
+
+// parse validates a freeze window's settings.
+func (w *freezeWindow) parse() error {
+	var err error
+	if w.start, err = parseWeekTime(w.Start); err != nil {
+		return err
+	}
+	if w.end, err = parseWeekTime(w.End); err != nil {
+		return err
+	}
+	w.loc = time.Local
+	if w.Timezone != "" {
+		if w.loc, err = time.LoadLocation(w.Timezone); err != nil {
+			return fmt.Errorf("invalid freeze timezone %q", w.Timezone)
+		}
+	}
+	return nil
+}
+
+// parseWeekTime parses a day and time of the week, such as "Fri 18:00",
+// returning the number of minutes since Sunday 00:00.
+func parseWeekTime(s string) (int, error) {
+	day, clock, ok := strings.Cut(strings.TrimSpace(s), " ")
+	if ok {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			if !strings.EqualFold(day, d.String()[:3]) && !strings.EqualFold(day, d.String()) {
+				continue
+			}
+			t, err := time.Parse("15:04", strings.TrimSpace(clock))
+			if err != nil {
+				break
+			}
+			return int(d)*24*60 + t.Hour()*60 + t.Minute(), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid freeze time %q; use a day and time such as \"Fri 18:00\"", s)
+}
+
+// active reports whether the window includes the time t.
+func (w *freezeWindow) active(t time.Time) bool {
+	t = t.In(w.loc)
+	m := int(t.Weekday())*24*60 + t.Hour()*60 + t.Minute()
+	if w.start <= w.end {
+		return w.start <= m && m < w.end
+	}
+	return m >= w.start || m < w.end
+}
+
+// covers reports whether the window applies to a zone. An empty zone name
+// stands for requests that do not change a single zone, which only windows
+// covering all zones apply to.
+func (w *freezeWindow) covers(zone string) bool {
+	if len(w.Zones) == 0 {
+		return true
+	}
+	for _, z := range w.Zones {
+		if zone != "" && cflib.NormalizeName(z) == cflib.NormalizeName(zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// A freezeError reports a change refused because of a freeze window.
+type freezeError struct {
+	zone   string
+	window *freezeWindow
+}
+
+func (e *freezeError) Error() string {
+	target := "changes"
+	if e.zone != "" {
+		target = "changes to zone " + e.zone
+	}
+	return fmt.Sprintf("%s are frozen from %s to %s; use --override-freeze to make them anyway",
+		target, e.window.Start, e.window.End)
+}
+
+// checkFreeze returns a freezeError if a freeze window in effect at time t
+// covers the zone.
+func checkFreeze(zone string, t time.Time) error {
+	if overrideFreeze {
+		return nil
+	}
+	for i := range cfg.Freezes {
+		w := &cfg.Freezes[i]
+		if w.covers(zone) && w.active(t) {
+			return &freezeError{zone, w}
+		}
+	}
+	return nil
+}
+
+// A freezeTransport refuses mutating API requests covered by a freeze
+// window in effect, before they are sent or retried.
+type freezeTransport struct {
+	base http.RoundTripper
+}
+
+func (t *freezeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(cfg.Freezes) > 0 && !overrideFreeze && isMutating(req) {
+		zone, err := requestZone(req)
+		if err != nil {
+			return nil, err
+		}
+		if err := checkFreeze(zone, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// requestZone returns the name of the zone an API request addresses, or
+// the empty string if it does not address a single zone.
+func requestZone(req *http.Request) (string, error) {
+	path := strings.TrimPrefix(req.URL.Path, "/client/v4")
+	rest, ok := strings.CutPrefix(path, "/zones/")
+	if !ok {
+		return "", nil
+	}
+	zoneID, _, _ := strings.Cut(rest, "/")
+	if zoneID == "" {
+		return "", nil
+	}
+	return lookupZoneName(req.Context(), zoneID)
+}
@@ -0,0 +1,62 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestFreezeWindow(t *testing.T) {
+	w := freezeWindow{Start: "Fri 18:00", End: "mon 08:00", Zones: []string{"example.com"}, Timezone: "UTC"}
+	if err := w.parse(); err != nil {
+		t.Fatal(err)
+	}
+
+	// 2024-03-01 is a Friday.
+	tests := []struct {
+		when   string
+		active bool
+	}{
+		{"2024-03-01T17:59:00Z", false},
+		{"2024-03-01T18:00:00Z", true},
+		{"2024-03-03T12:00:00Z", true},
+		{"2024-03-04T07:59:00Z", true},
+		{"2024-03-04T08:00:00Z", false},
+		{"2024-03-06T12:00:00Z", false},
+	}
+	for _, test := range tests {
+		at, _ := time.Parse(time.RFC3339, test.when)
+		if got := w.active(at); got != test.active {
+			t.Errorf("active(%s) = %v, want %v", test.when, got, test.active)
+		}
+	}
+
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{Freezes: []freezeWindow{w}}
+
+	at, _ := time.Parse(time.RFC3339, "2024-03-02T12:00:00Z")
+	var frozen *freezeError
+	if err := checkFreeze("Example.com.", at); !errors.As(err, &frozen) {
+		t.Errorf("change to a frozen zone allowed: %v", err)
+	}
+	if err := checkFreeze("example.org", at); err != nil {
+		t.Errorf("change to another zone refused: %v", err)
+	}
+
+	overrideFreeze = true
+	defer func() { overrideFreeze = false }()
+	if err := checkFreeze("example.com", at); err != nil {
+		t.Errorf("overridden freeze refused a change: %v", err)
+	}
+
+	for _, s := range []string{"Fri", "Friday 25:00", "Someday 10:00"} {
+		if _, err := parseWeekTime(s); err == nil {
+			t.Errorf("parseWeekTime(%q) succeeded", s)
+		}
+	}
+}
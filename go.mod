@@ -5,6 +5,7 @@ go 1.22
 require (
 	github.com/beevik/cmd v0.3.0
 	github.com/cloudflare/cloudflare-go v0.109.0
+	golang.org/x/net v0.31.0
 	golang.org/x/term v0.26.0
 )
 
@@ -12,7 +13,6 @@ require (
 	github.com/beevik/prefixtree/v2 v2.0.1 // indirect
 	github.com/goccy/go-json v0.10.3 // indirect
 	github.com/google/go-querystring v1.1.0 // indirect
-	golang.org/x/net v0.31.0 // indirect
 	golang.org/x/sys v0.27.0 // indirect
 	golang.org/x/text v0.20.0 // indirect
 	golang.org/x/time v0.8.0 // indirect
@@ -0,0 +1,152 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// The output of commands is compared with the golden files in
+// testdata/golden. After an intended change of the output, rewrite them
+// with "go test -run TestGoldenOutput -update" and review the difference.
+var update = flag.Bool("update", false, "rewrite the golden files in testdata/golden")
+
+// captureOutput runs a command line, returning everything it displays.
+func captureOutput(t *testing.T, line string) string {
+	t.Helper()
+	var buf bytes.Buffer
+	saved := output
+	output = &buf
+	defer func() { output = saved }()
+	processCmd(line)
+	return buf.String()
+}
+
+// checkGolden compares output with a golden file, or rewrites the file
+// with -update.
+func checkGolden(t *testing.T, name, got string) {
+	t.Helper()
+	path := filepath.Join("testdata", "golden", name+".golden")
+	if *update {
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("%v; run go test -update to create it", err)
+	}
+	if got != string(want) {
+		t.Errorf("output differs from %s:\n%s", path, lineDiff(string(want), got))
+	}
+}
+
+// timestamp matches the times displayed by commands such as undo --list
+// and history, which differ from run to run.
+var timestamp = regexp.MustCompile(`\d{4}-\d{2}-\d{2}[ T]\d{2}:\d{2}:\d{2}(\.\d+)?Z?`)
+
+// maskTimes replaces the times in output with a fixed placeholder. The
+// zero times of records created in memory are left alone.
+func maskTimes(output string) string {
+	return timestamp.ReplaceAllStringFunc(output, func(s string) string {
+		if strings.HasPrefix(s, "0001-") {
+			return s
+		}
+		return "<time>"
+	})
+}
+
+// lineDiff lists the lines of want and got that differ.
+func lineDiff(want, got string) string {
+	w, g := strings.Split(want, "\n"), strings.Split(got, "\n")
+	var b strings.Builder
+	for i := 0; i < max(len(w), len(g)); i++ {
+		var wl, gl string
+		if i < len(w) {
+			wl = w[i]
+		}
+		if i < len(g) {
+			gl = g[i]
+		}
+		if wl != gl {
+			b.WriteString("-" + wl + "\n+" + gl + "\n")
+		}
+	}
+	return b.String()
+}
+
+// TestGoldenOutput covers the commands that run on the in-memory backend.
+// Commands calling other parts of the API, such as show, zones and report,
+// are not covered.
+func TestGoldenOutput(t *testing.T) {
+	useMemoryBackend(t)
+	for _, c := range []string{
+		"ip4 www.example.com 10.0.0.1",
+		"ip6 www.example.com 2001:db8::1",
+		"cname blog.example.com www.example.com",
+		"txt example.com \"v=spf1 -all\"",
+		"add MX example.com \"10 mail.example.com\"",
+		"tag add --force env:prod A www.example.com",
+		"srv wizard --host sip.example.net sip",
+	} {
+		if err := processCmd(c); err != nil {
+			t.Fatalf("%s: %v", c, err)
+		}
+	}
+
+	tests := []struct {
+		name, line string
+	}{
+		{"list", "list"},
+		{"list-json", "list --json"},
+		{"list-long", "list --long"},
+		{"list-sorted", "list --sort content"},
+		{"list-type", "list A"},
+		{"list-group", "list --group-by type"},
+		{"search", "search www"},
+		{"search-json", "search --json www"},
+		{"graph", "graph"},
+		{"graph-dot", "graph --format dot"},
+		{"graph-json", "graph --json"},
+		{"srv-services", "srv services"},
+		{"get", "get A www.example.com"},
+		{"get-json", "get --json A www.example.com"},
+		{"rlookup", "rlookup 10.0.0.1"},
+		{"rlookup-json", "rlookup --json 10.0.0.1"},
+		{"diff", "diff " + filepath.Join("testdata", "snapshot.zone")},
+		{"lint", "lint"},
+		{"lint-json", "lint --json"},
+		{"delete-confirm", "delete A www.example.com"},
+		{"delete-content", "delete A * --content 10.0.0.*"},
+		{"undo-list", "undo --list"},
+		{"history", "history A www.example.com"},
+		{"history-json", "history --json A www.example.com"},
+		{"protect", "protect"},
+		{"retry-list", "retry list"},
+		{"totp", "totp"},
+		{"redo", "redo"},
+		{"set", "set"},
+		{"help", "help"},
+		{"help-command", "help delete"},
+		{"unknown-command", "frobnicate"},
+		{"usage", "ip4"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			checkGolden(t, tt.name, maskTimes(captureOutput(t, tt.line)))
+		})
+	}
+}
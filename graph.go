@@ -0,0 +1,309 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// How an alias chain ends.
+const (
+	aliasResolves = "resolves" // at a name in the zone holding other records
+	aliasExternal = "external" // at a name outside the zone
+	aliasDangling = "dangling" // at a name in the zone with no records
+	aliasLoop     = "loop"     // at a name earlier in the chain
+)
+
+// An aliasChain is a sequence of names, each a CNAME record pointing at the
+// next, and how the chain ends.
+type aliasChain struct {
+	Names []string `json:"names"`
+	End   string   `json:"end"`
+}
+
+// hops returns the number of CNAME records followed by the chain.
+func (c aliasChain) hops() int {
+	return len(c.Names) - 1
+}
+
+func (c aliasChain) String() string {
+	return strings.Join(c.Names, " -> ")
+}
+
+// aliasGraph is the graph of the CNAME records of a zone: the name each
+// alias points at, and the names holding records of any type.
+type aliasGraph struct {
+	zone    string
+	targets map[string]string
+	exists  map[string]bool
+}
+
+func newAliasGraph(zone string, recs []cloudflare.DNSRecord) *aliasGraph {
+	g := &aliasGraph{
+		zone:    cflib.NormalizeName(zone),
+		targets: make(map[string]string),
+		exists:  make(map[string]bool),
+	}
+	for _, r := range recs {
+		name := cflib.NormalizeName(r.Name)
+		g.exists[name] = true
+		if r.Type == "CNAME" {
+			g.targets[name] = cflib.NormalizeName(r.Content)
+		}
+	}
+	return g
+}
+
+// sources returns the names of the zone's CNAME records, sorted.
+func (g *aliasGraph) sources() []string {
+	return sortedKeys(g.targets)
+}
+
+// chains returns the longest alias chains of the graph: one starting at each
+// alias no other alias points at, and one for each loop of aliases only
+// pointing at each other.
+func (g *aliasGraph) chains() []aliasChain {
+	targeted := make(map[string]bool)
+	for _, t := range g.targets {
+		targeted[t] = true
+	}
+
+	visited := make(map[string]bool)
+	var chains []aliasChain
+	for _, s := range g.sources() {
+		if !targeted[s] {
+			chains = append(chains, g.follow(s, visited))
+		}
+	}
+	for _, s := range g.sources() {
+		if !visited[s] {
+			chains = append(chains, g.follow(s, visited))
+		}
+	}
+	return chains
+}
+
+// follow returns the alias chain starting at a name, marking the names it
+// passes as visited.
+func (g *aliasGraph) follow(name string, visited map[string]bool) aliasChain {
+	c := aliasChain{Names: []string{name}}
+	seen := map[string]bool{name: true}
+	visited[name] = true
+	for {
+		next, ok := g.targets[name]
+		if !ok {
+			break
+		}
+		c.Names = append(c.Names, next)
+		if seen[next] {
+			c.End = aliasLoop
+			return c
+		}
+		seen[next], visited[next] = true, true
+		name = next
+	}
+
+	switch {
+	case !inZone(name, g.zone):
+		c.End = aliasExternal
+	case !g.exists[name]:
+		c.End = aliasDangling
+	default:
+		c.End = aliasResolves
+	}
+	return c
+}
+
+// aliasProblem describes what is wrong with an alias chain, or returns the
+// empty string if nothing is.
+func aliasProblem(c aliasChain) string {
+	switch {
+	case c.End == aliasLoop:
+		return sprintf("CNAME loop %s", c)
+	case c.End == aliasDangling:
+		return sprintf("points at %s, which has no records", c.Names[len(c.Names)-1])
+	case c.hops() > 1:
+		return sprintf("CNAME chain %s of %d hops; point it at %s directly",
+			c, c.hops(), c.Names[len(c.Names)-1])
+	}
+	return ""
+}
+
+// lintCNAMEs flags the CNAME records starting loops, chains of aliases and
+// aliases of names with no records.
+func lintCNAMEs(recs []zoneRecord) []lintProblem {
+	byZone := make(map[string][]cloudflare.DNSRecord)
+	aliases := make(map[string]zoneRecord)
+	var zones []string
+	for _, r := range recs {
+		if _, ok := byZone[r.zone]; !ok {
+			zones = append(zones, r.zone)
+		}
+		byZone[r.zone] = append(byZone[r.zone], r.DNSRecord)
+		if r.Type == "CNAME" {
+			aliases[r.zone+" "+cflib.NormalizeName(r.Name)] = r
+		}
+	}
+
+	var problems []lintProblem
+	for _, zone := range zones {
+		for _, c := range newAliasGraph(zone, byZone[zone]).chains() {
+			if msg := aliasProblem(c); msg != "" {
+				problems = append(problems, lintProblem{aliases[zone+" "+c.Names[0]], msg})
+			}
+		}
+	}
+	return problems
+}
+
+var graphFlags = registerFlags("graph", flagSpec{"format": true})
+
+// cmdGraph displays the graph of the active zone's CNAME records, as alias
+// chains or in the Graphviz DOT or Mermaid languages.
+func cmdGraph(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, graphFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+	format := flags.get("format", "text")
+	if !isOneOf(format, []string{"text", "dot", "mermaid"}) {
+		return argError(fmt.Errorf("unknown format %q", format))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+	g := newAliasGraph(activeZoneName, recs)
+
+	switch {
+	case format == "dot":
+		writeDOTGraph(output, g)
+	case format == "mermaid":
+		writeMermaidGraph(output, g)
+	case outputFormat == "json":
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(g.chains())
+	default:
+		displayAliasChains(g)
+	}
+	return nil
+}
+
+func displayAliasChains(g *aliasGraph) {
+	chains := g.chains()
+	if len(chains) == 0 {
+		printf("No CNAME records found.\n")
+		return
+	}
+	problems := 0
+	for _, c := range chains {
+		note := ""
+		switch c.End {
+		case aliasExternal:
+			note = tr("(external)")
+		case aliasDangling:
+			note = tr("(no records)")
+		case aliasLoop:
+			note = tr("(loop)")
+		}
+		fmt.Fprintln(output, strings.TrimRight(c.String()+"  "+note, " "))
+		if aliasProblem(c) != "" {
+			problems++
+		}
+	}
+	fmt.Fprintln(output)
+	printf("%d alias(es), %d chain(s), %d with problems.\n", len(g.targets), len(chains), problems)
+}
+
+// aliasNodes returns every name of the graph's aliases and their targets,
+// sorted.
+func (g *aliasGraph) aliasNodes() []string {
+	nodes := make(map[string]string)
+	for s, t := range g.targets {
+		nodes[s], nodes[t] = s, t
+	}
+	return sortedKeys(nodes)
+}
+
+// nodeEnd returns how the alias chain of a name that is not itself an alias
+// ends, or the empty string for an alias.
+func (g *aliasGraph) nodeEnd(name string) string {
+	if _, ok := g.targets[name]; ok {
+		return ""
+	}
+	return g.follow(name, make(map[string]bool)).End
+}
+
+// writeDOTGraph writes the graph of a zone's aliases in the Graphviz DOT
+// language. Names outside the zone are drawn dashed, and names with no
+// records in red.
+func writeDOTGraph(w io.Writer, g *aliasGraph) {
+	fmt.Fprintf(w, "digraph %q {\n", g.zone)
+	fmt.Fprintf(w, "  rankdir=LR;\n")
+	for _, n := range g.aliasNodes() {
+		switch g.nodeEnd(n) {
+		case aliasExternal:
+			fmt.Fprintf(w, "  %q [style=dashed];\n", n)
+		case aliasDangling:
+			fmt.Fprintf(w, "  %q [color=red];\n", n)
+		}
+	}
+	for _, s := range g.sources() {
+		fmt.Fprintf(w, "  %q -> %q;\n", s, g.targets[s])
+	}
+	fmt.Fprintf(w, "}\n")
+}
+
+// writeMermaidGraph writes the graph of a zone's aliases as a Mermaid
+// flowchart, styled as the DOT graph is.
+func writeMermaidGraph(w io.Writer, g *aliasGraph) {
+	nodes := g.aliasNodes()
+	ids := make(map[string]string, len(nodes))
+	fmt.Fprintf(w, "graph LR\n")
+	for i, n := range nodes {
+		ids[n] = fmt.Sprintf("n%d", i)
+		fmt.Fprintf(w, "  %s[\"%s\"]\n", ids[n], n)
+	}
+	for _, s := range g.sources() {
+		fmt.Fprintf(w, "  %s --> %s\n", ids[s], ids[g.targets[s]])
+	}
+	var external, dangling []string
+	for _, n := range nodes {
+		switch g.nodeEnd(n) {
+		case aliasExternal:
+			external = append(external, ids[n])
+		case aliasDangling:
+			dangling = append(dangling, ids[n])
+		}
+	}
+	if len(external) > 0 {
+		fmt.Fprintf(w, "  classDef external stroke-dasharray: 5 5\n")
+		fmt.Fprintf(w, "  class %s external\n", strings.Join(external, ","))
+	}
+	if len(dangling) > 0 {
+		fmt.Fprintf(w, "  classDef dangling stroke:red\n")
+		fmt.Fprintf(w, "  class %s dangling\n", strings.Join(dangling, ","))
+	}
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// noGroup is the key of the group holding the records that have no value
+// for the grouping, such as records without tags.
+const noGroup = "(none)"
+
+// recordGroupings maps the values of list --group-by to functions returning
+// the keys of the groups a record belongs to. A record with several tags
+// belongs to the group of each.
+var recordGroupings = map[string]func(r cloudflare.DNSRecord) []string{
+	"tag": func(r cloudflare.DNSRecord) []string {
+		return r.Tags
+	},
+	"comment-prefix": func(r cloudflare.DNSRecord) []string {
+		if p := commentPrefix(r.Comment); p != "" {
+			return []string{p}
+		}
+		return nil
+	},
+	"type": func(r cloudflare.DNSRecord) []string {
+		return []string{r.Type}
+	},
+}
+
+// commentPrefix returns the first word of a comment, without trailing
+// punctuation, so that comments such as "billing: api backend" and
+// "billing - cron" are grouped together.
+func commentPrefix(comment string) string {
+	fields := strings.Fields(comment)
+	if len(fields) == 0 {
+		return ""
+	}
+	return strings.TrimRight(fields[0], ":;,.-")
+}
+
+// groupRecords sorts records into groups. It returns the group keys in
+// order, with the group of records without a key last.
+func groupRecords(recs []zoneRecord, groupBy string) ([]string, map[string][]zoneRecord) {
+	keyFn := recordGroupings[groupBy]
+	groups := make(map[string][]zoneRecord)
+	for _, r := range recs {
+		keys := keyFn(r.DNSRecord)
+		if len(keys) == 0 {
+			keys = []string{noGroup}
+		}
+		for _, k := range keys {
+			groups[k] = append(groups[k], r)
+		}
+	}
+
+	var keys []string
+	for k := range groups {
+		if k != noGroup {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	if _, ok := groups[noGroup]; ok {
+		keys = append(keys, noGroup)
+	}
+	return keys, groups
+}
+
+// displayRecordGroups prints records grouped under a header for each group,
+// followed by the number of records in the group, or as a JSON object
+// mapping group keys to records if that output format is selected.
+func displayRecordGroups(recs []zoneRecord, groupBy string, showZone, long bool) {
+	keys, groups := groupRecords(recs, groupBy)
+
+	if outputFormat == "json" {
+		out := make(map[string][]cloudflare.DNSRecord)
+		for k, g := range groups {
+			for _, r := range g {
+				out[k] = append(out[k], r.DNSRecord)
+			}
+		}
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		enc.Encode(out)
+		return
+	}
+
+	for i, k := range keys {
+		if i > 0 {
+			fmt.Fprintln(output)
+		}
+		fmt.Fprintf(output, "[%s]\n", k)
+		displayRecords(groups[k], showZone, long)
+		printf("%d record(s)\n", len(groups[k]))
+	}
+	if len(keys) > 1 {
+		fmt.Fprintln(output)
+		printf("%d record(s) in %d group(s)\n", len(recs), len(keys))
+	}
+}
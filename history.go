@@ -0,0 +1,260 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// maxHistorySize is the size at which the record history is trimmed to its
+// most recent half. It is larger than the journal, as the history is kept
+// for reviewing incidents long after the changes can be undone.
+const maxHistorySize = 16 << 20
+
+// A historyEntry records the content of a DNS record at a point in time:
+// after it was created or updated, or when it was deleted. Source is "cf"
+// for changes made with cf, and "watch" for changes made elsewhere and
+// seen by the watch command.
+type historyEntry struct {
+	Time     time.Time `json:"time"`
+	ZoneID   string    `json:"zone_id"`
+	Action   string    `json:"action"` // create, update or delete
+	ID       string    `json:"id"`
+	Type     string    `json:"type"`
+	Name     string    `json:"name"`
+	Content  string    `json:"content"`
+	Previous string    `json:"previous,omitempty"` // content before an update
+	TTL      int       `json:"ttl"`
+	Proxied  bool      `json:"proxied"`
+	Source   string    `json:"source"`
+}
+
+// newHistoryEntry returns the history entry of a change to a record, given
+// the record's state before and after it.
+func newHistoryEntry(zoneID, source string, before, after *cloudflare.DNSRecord) historyEntry {
+	e := historyEntry{Time: time.Now().UTC(), ZoneID: zoneID, Source: source}
+	r := after
+	switch {
+	case before == nil:
+		e.Action = "create"
+	case after == nil:
+		e.Action, r = "delete", before
+	default:
+		e.Action, e.Previous = "update", before.Content
+	}
+	e.ID, e.Type, e.Name, e.Content = r.ID, r.Type, cflib.NormalizeName(r.Name), r.Content
+	e.TTL, e.Proxied = r.TTL, isProxied(*r)
+	return e
+}
+
+// appendHistory adds entries to the record history in the state directory.
+// Failing to is reported but does not fail the change.
+func appendHistory(entries ...historyEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	err := withStateLock(func() error {
+		path, err := statePath("record-history")
+		if err != nil {
+			return err
+		}
+		var buf []byte
+		for _, e := range entries {
+			line, err := json.Marshal(e)
+			if err != nil {
+				return err
+			}
+			buf = append(append(buf, line...), '\n')
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(buf)
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(path); err == nil && info.Size() > maxHistorySize {
+			entries, err := readHistory(path)
+			if err != nil {
+				return err
+			}
+			return writeHistory(path, entries[len(entries)/2:])
+		}
+		return nil
+	})
+	if err != nil {
+		printf("Unable to record the change in the record history: %v\n", err)
+	}
+}
+
+// readHistory returns the entries of the record history, oldest first.
+func readHistory(path string) ([]historyEntry, error) {
+	f, err := os.Open(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var entries []historyEntry
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, 1<<20)
+	for scanner.Scan() {
+		var e historyEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// writeHistory replaces the entries of the record history. The state lock
+// must be held.
+func writeHistory(path string, entries []historyEntry) error {
+	var buf []byte
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf = append(append(buf, line...), '\n')
+	}
+	return writeStateFile(path, buf)
+}
+
+var historyFlags = registerFlags("history", flagSpec{"since": true})
+
+// cmdHistory displays how the content of the records of a type and name in
+// the active zone changed over time.
+func cmdHistory(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, historyFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usageError(c)
+	}
+	var since time.Time
+	if flags.has("since") {
+		age, err := parseAge(flags.get("since", ""))
+		if err != nil || age == 0 {
+			return argError(fmt.Errorf("invalid time %q", flags.get("since", "")))
+		}
+		since = time.Now().Add(-age)
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	zone := cflib.NormalizeName(activeZoneName)
+	recType, name := strings.ToUpper(args[0]), args[1]
+	if name == "@" {
+		name = zone
+	}
+	name = cflib.NormalizeName(name)
+	if !inZone(name, zone) {
+		return argError(fmt.Errorf("%s is not in zone %s", name, zone))
+	}
+
+	path, err := statePath("record-history")
+	if err != nil {
+		return err
+	}
+	var all []historyEntry
+	err = withStateLock(func() error {
+		all, err = readHistory(path)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	entries := []historyEntry{}
+	for _, e := range all {
+		if e.ZoneID == zoneID.Identifier && e.Type == recType && e.Name == name && !e.Time.Before(since) {
+			entries = append(entries, e)
+		}
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(entries)
+	}
+	if len(entries) == 0 {
+		printf("No history of %s records named %s.\n", recType, name)
+		return nil
+	}
+	for _, e := range entries {
+		content := e.Content
+		if e.Action == "update" && e.Previous != e.Content {
+			content = e.Previous + " -> " + e.Content
+		}
+		proxied := ""
+		if e.Proxied {
+			proxied = " " + tr("(proxied)")
+		}
+		fmt.Fprintf(output, "%s  %-6s %-5s %s%s  TTL %s\n", e.Time.Local().Format("2006-01-02 15:04:05"),
+			e.Action, e.Source, content, proxied, formatTTL(e.TTL))
+	}
+	return nil
+}
+
+// recordObservedChanges adds the changes seen by watch to the record
+// history, skipping those already recorded by the cf command that made
+// them.
+func recordObservedChanges(zoneID string, diffs []recordDiff) {
+	path, err := statePath("record-history")
+	if err != nil {
+		return
+	}
+	var all []historyEntry
+	err = withStateLock(func() error {
+		all, err = readHistory(path)
+		return err
+	})
+	if err != nil {
+		printf("Unable to record the change in the record history: %v\n", err)
+		return
+	}
+	latest := make(map[string]historyEntry)
+	for _, e := range all {
+		if e.ZoneID == zoneID {
+			latest[e.ID] = e
+		}
+	}
+
+	var entries []historyEntry
+	for _, d := range diffs {
+		e := newHistoryEntry(zoneID, "watch", d.Old, d.New)
+		if l, ok := latest[e.ID]; ok && l.Action == e.Action && l.Content == e.Content &&
+			l.TTL == e.TTL && l.Proxied == e.Proxied {
+			continue
+		}
+		entries = append(entries, e)
+	}
+	appendHistory(entries...)
+}
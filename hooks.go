@@ -0,0 +1,184 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// hookTimeout is how long a hook may run.
+const hookTimeout = 30 * time.Second
+
+// A hook, defined in the configuration file, runs before or after the
+// listed commands, or all commands if none are listed. Run is a URL to which
+// the command's details are posted as JSON, or a shell command run with
+// them in its environment. A hook run before a command that fails prevents
+// the command from running, so that a hook can, for example, require a
+// ticket to be open. A hook run after a command also receives the record
+// changes the command made.
+type hook struct {
+	Commands []string `json:"commands,omitempty"`
+	When     string   `json:"when"` // before or after
+	Run      string   `json:"run"`
+}
+
+// parse validates a hook's settings.
+func (h *hook) parse() error {
+	if h.When != "before" && h.When != "after" {
+		return fmt.Errorf("hook has invalid when %q, expected before or after", h.When)
+	}
+	if strings.TrimSpace(h.Run) == "" {
+		return fmt.Errorf("%s hook has nothing to run", h.When)
+	}
+	return nil
+}
+
+// applies reports whether the hook runs at a point of a command.
+func (h *hook) applies(when, command string) bool {
+	return h.When == when && (len(h.Commands) == 0 || slices.Contains(h.Commands, command))
+}
+
+// A hookEvent describes the command a hook runs for.
+type hookEvent struct {
+	When    string   `json:"when"`
+	Command string   `json:"command"`
+	Args    []string `json:"args"`
+	Zone    string   `json:"zone,omitempty"`
+	Profile string   `json:"profile,omitempty"`
+	DryRun  bool     `json:"dry_run"`
+	Status  string   `json:"status,omitempty"` // ok or error, after the command
+	Error   string   `json:"error,omitempty"`
+	Changes []string `json:"changes,omitempty"`
+}
+
+// hookChanges collects the record changes made by the running command for
+// the hooks run after it.
+var hookChanges struct {
+	mu      sync.Mutex
+	entries []string
+}
+
+// noteHookChange records a change made by the running command.
+func noteHookChange(e journalEntry) {
+	if len(cfg.Hooks) == 0 {
+		return
+	}
+	hookChanges.mu.Lock()
+	hookChanges.entries = append(hookChanges.entries, e.String())
+	hookChanges.mu.Unlock()
+}
+
+// runWithHooks runs a command between the hooks configured for it. A hook
+// run before the command that fails prevents it from running. The failures
+// of hooks run after it are only reported.
+func runWithHooks(command string, args []string, fn func() error) error {
+	if len(cfg.Hooks) == 0 {
+		return fn()
+	}
+
+	event := hookEvent{
+		When:    "before",
+		Command: command,
+		Args:    args,
+		Zone:    activeZoneName,
+		Profile: activeProfileName,
+		DryRun:  dryRun,
+	}
+	for i := range cfg.Hooks {
+		h := &cfg.Hooks[i]
+		if !h.applies("before", command) {
+			continue
+		}
+		if err := h.run(event); err != nil {
+			return fmt.Errorf("before hook %q refused the command: %v", h.Run, err)
+		}
+	}
+
+	hookChanges.mu.Lock()
+	hookChanges.entries = nil
+	hookChanges.mu.Unlock()
+
+	err := fn()
+
+	hookChanges.mu.Lock()
+	event.Changes = hookChanges.entries
+	hookChanges.mu.Unlock()
+	event.When, event.Status, event.Zone = "after", "ok", activeZoneName
+	if err != nil {
+		event.Status, event.Error = "error", err.Error()
+	}
+	for i := range cfg.Hooks {
+		h := &cfg.Hooks[i]
+		if !h.applies("after", command) {
+			continue
+		}
+		if herr := h.run(event); herr != nil {
+			printf("Warning: after hook %q failed: %v\n", h.Run, herr)
+		}
+	}
+	return err
+}
+
+// run runs the hook for an event.
+func (h *hook) run(event hookEvent) error {
+	ctx, cancel := context.WithTimeout(commandCtx, hookTimeout)
+	defer cancel()
+
+	if strings.HasPrefix(h.Run, "http://") || strings.HasPrefix(h.Run, "https://") {
+		body, err := json.Marshal(event)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.Run, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return err
+		}
+		resp.Body.Close()
+		if resp.StatusCode >= 400 {
+			return fmt.Errorf("%s returned %s", h.Run, resp.Status)
+		}
+		return nil
+	}
+
+	c := shellCommand(ctx, h.Run)
+	c.Env = append(os.Environ(),
+		"CF_HOOK_WHEN="+event.When,
+		"CF_HOOK_COMMAND="+event.Command,
+		"CF_HOOK_ARGS="+strings.Join(event.Args, " "),
+		"CF_HOOK_ZONE="+event.Zone,
+		"CF_HOOK_PROFILE="+event.Profile,
+		"CF_HOOK_DRY_RUN="+strconv.FormatBool(event.DryRun),
+		"CF_HOOK_STATUS="+event.Status,
+		"CF_HOOK_ERROR="+event.Error,
+		"CF_HOOK_CHANGES="+strings.Join(event.Changes, "\n"))
+	c.Stdout, c.Stderr = os.Stderr, os.Stderr
+	return c.Run()
+}
+
+// shellCommand returns a command running a command line with the system's
+// shell.
+func shellCommand(ctx context.Context, line string) *exec.Cmd {
+	if runtime.GOOS == "windows" {
+		return exec.CommandContext(ctx, "cmd", "/C", line)
+	}
+	return exec.CommandContext(ctx, "sh", "-c", line)
+}
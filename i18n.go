@@ -0,0 +1,58 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// catalogs holds the translations of user-facing messages, keyed by
+// language and then by the English message format.
+var catalogs = map[string]map[string]string{}
+
+// activeCatalog holds the translations for the selected language, or nil
+// if messages are displayed in English.
+var activeCatalog map[string]string
+
+// setLocale selects the language used for messages. An empty locale is
+// taken from the LC_ALL, LC_MESSAGES or LANG environment variables.
+// Locales such as "de_DE.UTF-8" select their language's catalog, and
+// languages without a catalog fall back to English.
+func setLocale(locale string) {
+	if locale == "" {
+		for _, v := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+			if locale = os.Getenv(v); locale != "" {
+				break
+			}
+		}
+	}
+
+	lang := strings.ToLower(locale)
+	if i := strings.IndexAny(lang, "_-.@"); i >= 0 {
+		lang = lang[:i]
+	}
+	activeCatalog = catalogs[lang]
+}
+
+// tr returns the translation of an English message format in the selected
+// language. Messages without a translation are returned unchanged.
+func tr(format string) string {
+	if t, ok := activeCatalog[format]; ok {
+		return t
+	}
+	return format
+}
+
+// printf displays a translated message.
+func printf(format string, a ...any) {
+	fmt.Fprintf(output, tr(format), a...)
+}
+
+// sprintf formats a translated message.
+func sprintf(format string, a ...any) string {
+	return fmt.Sprintf(tr(format), a...)
+}
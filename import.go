@@ -0,0 +1,213 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+)
+
+// importSources maps the formats accepted by import --from to functions
+// extracting the hostnames from a file of that format.
+var importSources = map[string]func(data string) []string{
+	"caddyfile": caddyfileHosts,
+	"traefik":   traefikHosts,
+}
+
+var importFlags = registerFlags("import", mergeFlags(forceFlags, conflictFlags, flagSpec{
+	"from":   true,
+	"target": true,
+}))
+
+func cmdImport(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, importFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 || !flags.has("from") || !flags.has("target") {
+		return usageError(c)
+	}
+	onConflict, err := parseConflict(flags)
+	if err != nil {
+		return err
+	}
+
+	from := strings.ToLower(flags.get("from", ""))
+	extract, ok := importSources[from]
+	if !ok {
+		return argError(fmt.Errorf("unknown import format %q", from))
+	}
+
+	filename := args[0]
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recType, content := targetRecord(flags.get("target", ""))
+	var proposals []proposal
+	seen := make(map[string]bool)
+	for _, h := range extract(string(data)) {
+		if seen[h] {
+			continue
+		}
+		seen[h] = true
+		if !inZone(h, activeZoneName) {
+			printf("Skipping %s, which is not in zone %s.\n", h, activeZoneName)
+			continue
+		}
+		proposals = append(proposals, proposal{
+			source:  filename,
+			recType: recType,
+			name:    h,
+			content: content,
+		})
+	}
+	if len(proposals) == 0 {
+		printf("No records to propose.\n")
+		return nil
+	}
+	sortProposals(proposals)
+
+	changes, err := planProposals(api, zoneID, proposals, onConflict)
+	if err != nil {
+		return err
+	}
+	if !flags.force() {
+		changes = protectProposals(proposals, activeZoneName, changes)
+	}
+
+	displayProposals(proposals)
+	return applyProposals(api, zoneID, proposals, changes, flags.force())
+}
+
+// caddyfileHosts returns the hostnames of the site addresses in a
+// Caddyfile. Site addresses begin each top-level block, or the file itself
+// when it defines a single site without braces.
+func caddyfileHosts(data string) []string {
+	var hosts []string
+	depth := 0
+	first := true
+	for _, line := range strings.Split(data, "\n") {
+		if i := strings.Index(line, "#"); i >= 0 && (i == 0 || line[i-1] == ' ' || line[i-1] == '\t') {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+
+		if depth == 0 {
+			opens := fields[len(fields)-1] == "{"
+			addrs := fields
+			if opens {
+				addrs = fields[:len(fields)-1]
+			}
+			// Blocks without addresses hold global options, blocks named
+			// (name) are snippets, and import is a top-level directive.
+			isSite := len(addrs) > 0 && !strings.HasPrefix(addrs[0], "(") && addrs[0] != "import"
+			if isSite && (opens || first) {
+				for _, a := range addrs {
+					for _, addr := range strings.Split(a, ",") {
+						if h := caddyAddressHost(addr); h != "" {
+							hosts = append(hosts, h)
+						}
+					}
+				}
+			}
+		}
+		first = false
+
+		for _, f := range fields {
+			switch f {
+			case "{":
+				depth++
+			case "}":
+				depth--
+			}
+		}
+	}
+	return hosts
+}
+
+// caddyAddressHost returns the hostname of a Caddy site address such as
+// https://example.com:8443/path, or the empty string if the address has no
+// public hostname.
+func caddyAddressHost(addr string) string {
+	addr = strings.TrimSpace(addr)
+	if i := strings.Index(addr, "://"); i >= 0 {
+		addr = addr[i+3:]
+	}
+	if i := strings.IndexByte(addr, '/'); i >= 0 {
+		addr = addr[:i]
+	}
+	if i := strings.LastIndexByte(addr, ':'); i >= 0 && !strings.Contains(addr[:i], ":") {
+		addr = addr[:i]
+	}
+	return publicHostname(addr)
+}
+
+// traefikRule matches the host matchers of Traefik routing rules, in both
+// the Host(`a`, `b`) form and the older Host:a,b form.
+var traefikRule = regexp.MustCompile("(?:Host|HostSNI)\\(([^)]*)\\)|Host:\\s*([^\\s\"';]+)")
+
+// traefikName matches the quoted names within a host matcher.
+var traefikName = regexp.MustCompile("[`\"']([^`\"']+)[`\"']")
+
+// traefikHosts returns the hostnames in the routing rules of a Traefik
+// configuration, whether written in YAML, TOML or container labels.
+func traefikHosts(data string) []string {
+	var hosts []string
+	for _, m := range traefikRule.FindAllStringSubmatch(data, -1) {
+		var names []string
+		if m[1] != "" {
+			for _, n := range traefikName.FindAllStringSubmatch(m[1], -1) {
+				names = append(names, n[1])
+			}
+		} else {
+			names = strings.Split(m[2], ",")
+		}
+		for _, n := range names {
+			if h := publicHostname(n); h != "" {
+				hosts = append(hosts, h)
+			}
+		}
+	}
+	return hosts
+}
+
+// publicHostname returns the normalized form of a hostname, or the empty
+// string if it is not a hostname that could be published in DNS.
+func publicHostname(name string) string {
+	name = cflib.NormalizeName(name)
+	switch {
+	case name == "" || name == "*" || name == "localhost":
+		return ""
+	case strings.ContainsAny(name, "{}$ "):
+		return ""
+	case !strings.Contains(name, "."):
+		return ""
+	}
+	if _, err := netip.ParseAddr(strings.Trim(name, "[]")); err == nil {
+		return ""
+	}
+	return name
+}
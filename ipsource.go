@@ -0,0 +1,443 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// defaultIPService is queried for the public address when no other source
+// is configured. It reports the address of whichever IP family the request
+// arrives on.
+const defaultIPService = "https://api64.ipify.org"
+
+// ipSourceTimeout limits the time spent asking a single source for the
+// public address.
+const ipSourceTimeout = 15 * time.Second
+
+// ipSourceFlags are the flags selecting the sources of the public address
+// used by the ddns and docker commands.
+var ipSourceFlags = flagSpec{
+	"source":    true,
+	"service":   true,
+	"interface": true,
+}
+
+// An ipSource is a way of detecting the machine's public address. Sources
+// are written as:
+//
+//	https://<url>         an HTTP service echoing the caller's address
+//	interface:<name>      the public address of a network interface
+//	nat-pmp:<gateway>     the external address of a NAT-PMP router
+//	upnp                  the external address of a UPnP internet gateway
+//	command:<command>     the address printed by a shell command
+type ipSource struct {
+	spec   string
+	detect func(ctx context.Context, ipv6 bool) (netip.Addr, error)
+}
+
+func (s ipSource) String() string {
+	return s.spec
+}
+
+// parseIPSource parses the description of an address source.
+func parseIPSource(spec string) (ipSource, error) {
+	s := ipSource{spec: spec}
+	kind, arg, _ := strings.Cut(spec, ":")
+	kind = strings.ToLower(kind)
+	switch kind {
+	case "http", "https":
+		if _, err := url.ParseRequestURI(spec); err != nil {
+			return s, fmt.Errorf("invalid address source URL %q", spec)
+		}
+		s.detect = func(ctx context.Context, ipv6 bool) (netip.Addr, error) {
+			return serviceIP(ctx, ipv6, spec)
+		}
+	case "interface":
+		s.detect = func(_ context.Context, ipv6 bool) (netip.Addr, error) {
+			return interfaceIP(ipv6, arg)
+		}
+	case "nat-pmp":
+		gateway, err := netip.ParseAddr(arg)
+		if err != nil || !gateway.Is4() {
+			return s, fmt.Errorf("invalid NAT-PMP gateway %q", arg)
+		}
+		s.detect = func(ctx context.Context, ipv6 bool) (netip.Addr, error) {
+			if ipv6 {
+				return netip.Addr{}, errors.New("NAT-PMP reports only IPv4 addresses")
+			}
+			return natpmpIP(ctx, gateway)
+		}
+	case "upnp":
+		s.detect = func(ctx context.Context, ipv6 bool) (netip.Addr, error) {
+			if ipv6 {
+				return netip.Addr{}, errors.New("UPnP reports only IPv4 addresses")
+			}
+			return upnpIP(ctx)
+		}
+	case "command":
+		s.detect = func(ctx context.Context, _ bool) (netip.Addr, error) {
+			return commandIP(ctx, arg)
+		}
+	default:
+		return s, fmt.Errorf("unknown address source %q", spec)
+	}
+	if kind != "upnp" && kind != "http" && kind != "https" && arg == "" {
+		return s, fmt.Errorf("address source %q is incomplete", spec)
+	}
+	return s, nil
+}
+
+// parseIPSources returns the address sources requested by the --source,
+// --interface and --service flags, or else by the configuration file. Several
+// sources given with --source are separated by commas and tried in order.
+func parseIPSources(flags flagValues) ([]ipSource, error) {
+	var specs []string
+	switch {
+	case flags.has("source"):
+		specs = strings.Split(flags.get("source", ""), ",")
+	case flags.has("interface") || flags.has("service"):
+		if flags.has("interface") {
+			specs = append(specs, "interface:"+flags.get("interface", ""))
+		}
+		if flags.has("service") {
+			specs = append(specs, flags.get("service", ""))
+		}
+	case len(cfg.IPSources) > 0:
+		specs = cfg.IPSources
+	default:
+		specs = []string{defaultIPService}
+	}
+
+	var sources []ipSource
+	for _, spec := range specs {
+		s, err := parseIPSource(strings.TrimSpace(spec))
+		if err != nil {
+			return nil, err
+		}
+		sources = append(sources, s)
+	}
+	return sources, nil
+}
+
+// detectPublicIP returns the machine's public IPv4 or IPv6 address, asking
+// each source in turn until one answers. The failures of sources followed
+// by others are logged.
+func detectPublicIP(ipv6 bool, sources []ipSource) (string, error) {
+	var err error
+	for i, s := range sources {
+		if i > 0 {
+			log.Printf("Unable to detect the public address using %s: %v", sources[i-1], err)
+		}
+
+		var addr netip.Addr
+		ctx, cancel := context.WithTimeout(commandCtx, ipSourceTimeout)
+		addr, err = s.detect(ctx, ipv6)
+		cancel()
+		if err == nil && addr.Is4() == ipv6 {
+			err = errors.New("address of the wrong family")
+		}
+		if err == nil {
+			return addr.String(), nil
+		}
+	}
+	if len(sources) > 1 {
+		return "", fmt.Errorf("%s: %v", sources[len(sources)-1], err)
+	}
+	return "", err
+}
+
+// parseIP parses an address reported by a source.
+func parseIP(s string) (netip.Addr, error) {
+	addr, err := netip.ParseAddr(strings.TrimSpace(s))
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid address %q", strings.TrimSpace(s))
+	}
+	return addr.Unmap(), nil
+}
+
+// serviceIP queries an HTTP service for the caller's address over the
+// requested IP family.
+func serviceIP(ctx context.Context, ipv6 bool, service string) (netip.Addr, error) {
+	network := "tcp4"
+	if ipv6 {
+		network = "tcp6"
+	}
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	client := &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, addr string) (net.Conn, error) {
+				return dialer.DialContext(ctx, network, addr)
+			},
+		},
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, service, nil)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("%s returned %s", service, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 256))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	return parseIP(string(body))
+}
+
+// interfaceIP returns the first public address of the requested family
+// assigned to a network interface.
+func interfaceIP(ipv6 bool, name string) (netip.Addr, error) {
+	ifi, err := net.InterfaceByName(name)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	for _, a := range addrs {
+		prefix, err := netip.ParsePrefix(a.String())
+		if err != nil {
+			continue
+		}
+		addr := prefix.Addr()
+		if addr.Is4() == ipv6 || !addr.IsGlobalUnicast() || addr.IsPrivate() {
+			continue
+		}
+		return addr, nil
+	}
+
+	return netip.Addr{}, fmt.Errorf("no public address found on interface %s", name)
+}
+
+// natpmpIP asks a NAT-PMP gateway (RFC 6886) for its external address,
+// resending the request with the protocol's doubling delay until it
+// answers.
+func natpmpIP(ctx context.Context, gateway netip.Addr) (netip.Addr, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp4", netip.AddrPortFrom(gateway, 5351).String())
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer conn.Close()
+
+	resp := make([]byte, 16)
+	for delay := 250 * time.Millisecond; ctx.Err() == nil; delay *= 2 {
+		if _, err := conn.Write([]byte{0, 0}); err != nil {
+			return netip.Addr{}, err
+		}
+		deadline := time.Now().Add(delay)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		conn.SetReadDeadline(deadline)
+
+		n, err := conn.Read(resp)
+		var ne net.Error
+		if errors.As(err, &ne) && ne.Timeout() {
+			continue
+		}
+		if err != nil {
+			return netip.Addr{}, err
+		}
+		if n < 12 || resp[0] != 0 || resp[1] != 128 {
+			return netip.Addr{}, errors.New("invalid NAT-PMP response")
+		}
+		if code := binary.BigEndian.Uint16(resp[2:4]); code != 0 {
+			return netip.Addr{}, fmt.Errorf("NAT-PMP gateway returned result code %d", code)
+		}
+		return netip.AddrFrom4([4]byte(resp[8:12])), nil
+	}
+	return netip.Addr{}, fmt.Errorf("no response from NAT-PMP gateway %s", gateway)
+}
+
+// upnpIP asks the UPnP internet gateway device on the local network for its
+// external address.
+func upnpIP(ctx context.Context) (netip.Addr, error) {
+	location, err := ssdpDiscover(ctx)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	controlURL, serviceType, err := upnpService(ctx, location)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+
+	body := `<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" ` +
+		`s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:GetExternalIPAddress xmlns:u="` + serviceType + `"/></s:Body>` +
+		`</s:Envelope>`
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, controlURL, strings.NewReader(body))
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+serviceType+`#GetExternalIPAddress"`)
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return netip.Addr{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return netip.Addr{}, fmt.Errorf("UPnP gateway returned %s", resp.Status)
+	}
+
+	dec := xml.NewDecoder(io.LimitReader(resp.Body, 64*1024))
+	for {
+		tok, err := dec.Token()
+		if err != nil {
+			return netip.Addr{}, errors.New("UPnP gateway did not report an external address")
+		}
+		if se, ok := tok.(xml.StartElement); ok && se.Name.Local == "NewExternalIPAddress" {
+			var s string
+			if err := dec.DecodeElement(&s, &se); err != nil {
+				return netip.Addr{}, err
+			}
+			return parseIP(s)
+		}
+	}
+}
+
+// ssdpDiscover finds an internet gateway device using SSDP, returning the
+// location of its description.
+func ssdpDiscover(ctx context.Context) (string, error) {
+	conn, err := net.ListenPacket("udp4", ":0")
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	const search = "M-SEARCH * HTTP/1.1\r\n" +
+		"HOST: 239.255.255.250:1900\r\n" +
+		"MAN: \"ssdp:discover\"\r\n" +
+		"MX: 2\r\n" +
+		"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n\r\n"
+	dst := &net.UDPAddr{IP: net.IPv4(239, 255, 255, 250), Port: 1900}
+	if _, err := conn.WriteTo([]byte(search), dst); err != nil {
+		return "", err
+	}
+
+	deadline := time.Now().Add(3 * time.Second)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	conn.SetReadDeadline(deadline)
+
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return "", errors.New("no UPnP internet gateway found")
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		if location := resp.Header.Get("Location"); location != "" {
+			return location, nil
+		}
+	}
+}
+
+// A upnpDevice is a device in a UPnP device description.
+type upnpDevice struct {
+	Services []struct {
+		ServiceType string `xml:"serviceType"`
+		ControlURL  string `xml:"controlURL"`
+	} `xml:"serviceList>service"`
+	Devices []upnpDevice `xml:"deviceList>device"`
+}
+
+// upnpService reads a gateway's device description, returning the control
+// URL and type of its WAN connection service.
+func upnpService(ctx context.Context, location string) (controlURL, serviceType string, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return "", "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", "", err
+	}
+	defer resp.Body.Close()
+
+	var desc struct {
+		URLBase string     `xml:"URLBase"`
+		Device  upnpDevice `xml:"device"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 1<<20)).Decode(&desc); err != nil {
+		return "", "", fmt.Errorf("invalid UPnP device description: %v", err)
+	}
+
+	base, err := url.Parse(location)
+	if desc.URLBase != "" {
+		base, err = url.Parse(desc.URLBase)
+	}
+	if err != nil {
+		return "", "", err
+	}
+
+	devices := []upnpDevice{desc.Device}
+	for len(devices) > 0 {
+		d := devices[0]
+		devices = append(devices[1:], d.Devices...)
+		for _, s := range d.Services {
+			if strings.Contains(s.ServiceType, ":WANIPConnection:") ||
+				strings.Contains(s.ServiceType, ":WANPPPConnection:") {
+				ref, err := url.Parse(s.ControlURL)
+				if err != nil {
+					return "", "", err
+				}
+				return base.ResolveReference(ref).String(), s.ServiceType, nil
+			}
+		}
+	}
+	return "", "", errors.New("UPnP gateway has no WAN connection service")
+}
+
+// commandIP runs a shell command and returns the address it prints.
+func commandIP(ctx context.Context, command string) (netip.Addr, error) {
+	var c *exec.Cmd
+	if runtime.GOOS == "windows" {
+		c = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		c = exec.CommandContext(ctx, "sh", "-c", command)
+	}
+	out, err := c.Output()
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("command failed: %v", err)
+	}
+	return parseIP(string(out))
+}
@@ -0,0 +1,70 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"testing"
+)
+
+func TestParseIPSource(t *testing.T) {
+	valid := []string{
+		"https://api64.ipify.org",
+		"interface:eth0",
+		"nat-pmp:192.168.1.1",
+		"upnp",
+		"command:dig +short myip.opendns.com @resolver1.opendns.com",
+	}
+	for _, spec := range valid {
+		if _, err := parseIPSource(spec); err != nil {
+			t.Errorf("parseIPSource(%q): %v", spec, err)
+		}
+	}
+
+	invalid := []string{"", "ftp://example.com", "interface:", "nat-pmp:router", "nat-pmp:2001:db8::1", "command:"}
+	for _, spec := range invalid {
+		if _, err := parseIPSource(spec); err == nil {
+			t.Errorf("parseIPSource(%q) succeeded", spec)
+		}
+	}
+}
+
+func TestDetectPublicIP(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "203.0.113.7")
+	}))
+	defer srv.Close()
+
+	var sources []ipSource
+	for _, spec := range []string{"command:exit 1", "command:echo not-an-address", srv.URL} {
+		s, err := parseIPSource(spec)
+		if err != nil {
+			t.Fatal(err)
+		}
+		sources = append(sources, s)
+	}
+
+	ip, err := detectPublicIP(false, sources)
+	if err != nil || ip != "203.0.113.7" {
+		t.Errorf("detectPublicIP = %q, %v, want 203.0.113.7", ip, err)
+	}
+
+	// The service's IPv4 address is not accepted for an AAAA record.
+	if ip, err := detectPublicIP(true, sources); err == nil {
+		t.Errorf("detectPublicIP(ipv6) = %q, want an error", ip)
+	}
+
+	cmd, _ := parseIPSource("command:echo ' 2001:db8::5 '")
+	if ip, err := detectPublicIP(true, []ipSource{cmd}); err != nil || ip != "2001:db8::5" {
+		t.Errorf("detectPublicIP(command) = %q, %v, want 2001:db8::5", ip, err)
+	}
+}
@@ -0,0 +1,537 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// maxJournalSize is the size at which the journal is trimmed to its most
+// recent half.
+const maxJournalSize = 1 << 20
+
+// undoListLength is the number of journal entries shown by "undo --list".
+const undoListLength = 10
+
+// A journalEntry records a change made to a DNS record, with the record's
+// state before and after the change. Before is nil for a created record,
+// and After for a deleted one.
+type journalEntry struct {
+	Time   time.Time             `json:"time"`
+	ZoneID string                `json:"zone_id"`
+	Action string                `json:"action"` // create, update or delete
+	Before *cloudflare.DNSRecord `json:"before,omitempty"`
+	After  *cloudflare.DNSRecord `json:"after,omitempty"`
+	Run    string                `json:"run,omitempty"` // the command's run ID
+}
+
+// record returns the state of the entry's record that identifies it.
+func (e *journalEntry) record() *cloudflare.DNSRecord {
+	if e.After != nil {
+		return e.After
+	}
+	return e.Before
+}
+
+func (e *journalEntry) String() string {
+	r := e.record()
+	switch e.Action {
+	case "update":
+		return fmt.Sprintf("update %s %s %s -> %s", r.Type, r.Name, e.Before.Content, e.After.Content)
+	default:
+		return fmt.Sprintf("%s %s %s %s", e.Action, r.Type, r.Name, r.Content)
+	}
+}
+
+// A journalBackend records the changes made through a backend in the
+// journal, so that they can be undone, and in the audit log.
+type journalBackend struct {
+	cflib.Backend
+}
+
+func (b journalBackend) CreateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.CreateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	applyProxyPolicy(zoneID, &params)
+	proxied := params.Proxied != nil && *params.Proxied
+	if err := checkTTLPolicies(zoneID, params.Type, params.Name, params.TTL, proxied); err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+	rec, err := b.Backend.CreateDNSRecord(ctx, zoneID, params)
+	if err == nil {
+		appendJournal(journalEntry{ZoneID: zoneID, Action: "create", After: &rec})
+		auditChange(zoneID, "create", nil, &rec, nil)
+	} else {
+		auditChange(zoneID, "create", nil, &cloudflare.DNSRecord{
+			Type:    params.Type,
+			Name:    params.Name,
+			Content: params.Content,
+		}, err)
+	}
+	return rec, err
+}
+
+func (b journalBackend) UpdateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	before, err := b.Backend.GetDNSRecord(ctx, zoneID, params.ID)
+	if err != nil {
+		auditChange(zoneID, "update", &cloudflare.DNSRecord{ID: params.ID}, nil, err)
+		return cloudflare.DNSRecord{}, err
+	}
+
+	// Only changes setting a TTL are checked, so that records already
+	// violating a policy can still be changed in other ways.
+	if params.TTL != 0 {
+		recType, name := params.Type, params.Name
+		if recType == "" {
+			recType = before.Type
+		}
+		if name == "" {
+			name = before.Name
+		}
+		proxied := isProxied(before)
+		if params.Proxied != nil {
+			proxied = *params.Proxied
+		}
+		if err := checkTTLPolicies(zoneID, recType, name, params.TTL, proxied); err != nil {
+			return cloudflare.DNSRecord{}, err
+		}
+	}
+	rec, err := b.Backend.UpdateDNSRecord(ctx, zoneID, params)
+	if err == nil {
+		appendJournal(journalEntry{ZoneID: zoneID, Action: "update", Before: &before, After: &rec})
+		auditChange(zoneID, "update", &before, &rec, nil)
+	} else {
+		auditChange(zoneID, "update", &before, nil, err)
+	}
+	return rec, err
+}
+
+func (b journalBackend) DeleteDNSRecord(ctx context.Context, zoneID, id string) error {
+	before, err := b.Backend.GetDNSRecord(ctx, zoneID, id)
+	if err != nil {
+		auditChange(zoneID, "delete", &cloudflare.DNSRecord{ID: id}, nil, err)
+		return err
+	}
+	err = b.Backend.DeleteDNSRecord(ctx, zoneID, id)
+	if err == nil {
+		appendJournal(journalEntry{ZoneID: zoneID, Action: "delete", Before: &before})
+	}
+	auditChange(zoneID, "delete", &before, nil, err)
+	return err
+}
+
+// appendJournal adds an entry to the journal. Failing to record a change
+// does not fail the change, which has already been made, so errors are
+// only reported.
+func appendJournal(e journalEntry) {
+	e.Time, e.Run = time.Now().UTC(), currentRun()
+	noteRunChange(e.Action)
+	pushSessionChange(e)
+	noteHookChange(e)
+	err := withStateLock(func() error {
+		path, err := statePath("journal")
+		if err != nil {
+			return err
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0600)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(line, '\n'))
+		if cerr := f.Close(); err == nil {
+			err = cerr
+		}
+		if err != nil {
+			return err
+		}
+
+		if info, err := os.Stat(path); err == nil && info.Size() > maxJournalSize {
+			entries, err := readJournal(path)
+			if err != nil {
+				return err
+			}
+			return writeJournal(path, entries[len(entries)/2:])
+		}
+		return nil
+	})
+	if err != nil {
+		printf("Unable to record the change in the journal: %v\n", err)
+	}
+	appendHistory(newHistoryEntry(e.ZoneID, "cf", e.Before, e.After))
+}
+
+// readJournal returns the entries of the journal, oldest first. The state
+// lock must be held.
+func readJournal(path string) ([]journalEntry, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []journalEntry
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(nil, maxJournalSize)
+	for scanner.Scan() {
+		var e journalEntry
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			return nil, fmt.Errorf("%s: %v", path, err)
+		}
+		entries = append(entries, e)
+	}
+	return entries, scanner.Err()
+}
+
+// writeJournal replaces the entries of the journal. The state lock must be
+// held.
+func writeJournal(path string, entries []journalEntry) error {
+	var buf bytes.Buffer
+	for _, e := range entries {
+		line, err := json.Marshal(e)
+		if err != nil {
+			return err
+		}
+		buf.Write(append(line, '\n'))
+	}
+	return writeStateFile(path, buf.Bytes())
+}
+
+var undoFlags = registerFlags("undo", mergeFlags(forceFlags, flagSpec{"list": false}))
+
+func cmdUndo(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, undoFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 || (flags.has("list") && len(args) > 0) {
+		return usageError(c)
+	}
+
+	n := 1
+	if len(args) == 1 {
+		if n, err = strconv.Atoi(args[0]); err != nil || n < 1 {
+			return argError(fmt.Errorf("invalid change number %q", args[0]))
+		}
+	}
+
+	if flags.has("list") {
+		return undoList()
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	// The changes made during an interactive session are undone from the
+	// session's own history first.
+	if len(args) == 0 && session.canUndo() {
+		return session.undo(api, flags.force())
+	}
+
+	return withStateLock(func() error {
+		path, err := statePath("journal")
+		if err != nil {
+			return err
+		}
+		entries, err := readJournal(path)
+		if err != nil {
+			return err
+		}
+		if n > len(entries) {
+			if len(entries) == 0 {
+				printf("There are no changes to undo.\n")
+				return nil
+			}
+			return argError(fmt.Errorf("only %d change(s) can be undone", len(entries)))
+		}
+
+		i := len(entries) - n
+		e := entries[i]
+		if !flags.force() && !confirm(sprintf("Undo %s?", e.String())) {
+			printf("Nothing undone.\n")
+			return nil
+		}
+
+		id, err := revert(baseBackend(api), &e, flags.force())
+		if err != nil {
+			return err
+		}
+
+		// A deleted record is restored with a new ID, which the earlier
+		// entries for the record must use to be undone.
+		entries = append(entries[:i:i], entries[i+1:]...)
+		if e.Action == "delete" {
+			for _, other := range entries {
+				for _, r := range []*cloudflare.DNSRecord{other.Before, other.After} {
+					if r != nil && r.ID == e.Before.ID {
+						r.ID = id
+					}
+				}
+			}
+		}
+		if err := writeJournal(path, entries); err != nil {
+			return err
+		}
+		printf("Undid %s.\n", e.String())
+		return nil
+	})
+}
+
+func undoList() error {
+	var entries []journalEntry
+	err := withStateLock(func() error {
+		path, err := statePath("journal")
+		if err != nil {
+			return err
+		}
+		entries, err = readJournal(path)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(entries) == 0 {
+		printf("There are no changes to undo.\n")
+		return nil
+	}
+
+	for n := 1; n <= undoListLength && n <= len(entries); n++ {
+		e := entries[len(entries)-n]
+		fmt.Fprintf(output, "%3d  %s  %s\n", n, e.Time.Local().Format("2006-01-02 15:04:05"), e.String())
+	}
+	return nil
+}
+
+// revert makes the change undoing a journal entry through a backend that
+// does not record it in the journal, returning the ID of the record
+// changed. Unless force is true, a record changed since the entry was made
+// is left alone.
+func revert(b cflib.Backend, e *journalEntry, force bool) (string, error) {
+	ctx := commandCtx
+	if e.After != nil {
+		current, err := b.GetDNSRecord(ctx, e.ZoneID, e.After.ID)
+		switch {
+		case isNotFound(err) && e.Action == "create":
+			return e.After.ID, nil
+		case err != nil:
+			return "", err
+		case !force && !cflib.RecordsEqual(current, *e.After):
+			return "", fmt.Errorf("%s record %s has changed since; use --force to undo the change anyway",
+				current.Type, current.Name)
+		}
+	}
+
+	switch e.Action {
+	case "create":
+		return e.After.ID, b.DeleteDNSRecord(ctx, e.ZoneID, e.After.ID)
+	case "update":
+		r := e.Before
+		_, err := b.UpdateDNSRecord(ctx, e.ZoneID, cloudflare.UpdateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			Data:     r.Data,
+			ID:       r.ID,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Comment:  &r.Comment,
+			Tags:     r.Tags,
+		})
+		return r.ID, err
+	case "delete":
+		r := e.Before
+		rec, err := b.CreateDNSRecord(ctx, e.ZoneID, cloudflare.CreateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			Data:     r.Data,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Comment:  r.Comment,
+			Tags:     r.Tags,
+		})
+		return rec.ID, err
+	default:
+		return "", fmt.Errorf("unknown action %q", e.Action)
+	}
+}
+
+// A sessionHistory holds the changes made during an interactive session,
+// so that undo and redo step back and forth through them like an editor's,
+// without consulting the journal. Undoing or redoing a change is itself a
+// change recorded in the journal, but not in the session's history.
+type sessionHistory struct {
+	mu        sync.Mutex
+	done      []journalEntry
+	undone    []journalEntry
+	replaying bool
+}
+
+var session sessionHistory
+
+// pushSessionChange adds a change made in interactive mode to the session's
+// history. A new change discards the changes that were undone, which can no
+// longer be redone.
+func pushSessionChange(e journalEntry) {
+	if !interactive {
+		return
+	}
+	session.mu.Lock()
+	defer session.mu.Unlock()
+	if session.replaying {
+		return
+	}
+	session.done = append(session.done, e)
+	session.undone = nil
+}
+
+func (h *sessionHistory) canUndo() bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.done) > 0
+}
+
+// undo reverts the most recent change of the session.
+func (h *sessionHistory) undo(api *cloudflare.API, force bool) error {
+	h.mu.Lock()
+	e := h.done[len(h.done)-1]
+	h.mu.Unlock()
+
+	if !force && !confirm(sprintf("Undo %s?", e.String())) {
+		printf("Nothing undone.\n")
+		return nil
+	}
+	if err := h.replay(api, &e, force); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	h.done = h.done[:len(h.done)-1]
+	h.undone = append(h.undone, e)
+	h.mu.Unlock()
+	printf("Undid %s.\n", e.String())
+	return nil
+}
+
+// redo makes the most recently undone change of the session again.
+func (h *sessionHistory) redo(api *cloudflare.API, force bool) error {
+	h.mu.Lock()
+	if len(h.undone) == 0 {
+		h.mu.Unlock()
+		printf("There are no changes to redo.\n")
+		return nil
+	}
+	e := h.undone[len(h.undone)-1]
+	h.mu.Unlock()
+
+	if !force && !confirm(sprintf("Redo %s?", e.String())) {
+		printf("Nothing redone.\n")
+		return nil
+	}
+	inverse := e.inverse()
+	if err := h.replay(api, &inverse, force); err != nil {
+		return err
+	}
+	e = inverse.inverse()
+
+	h.mu.Lock()
+	h.undone = h.undone[:len(h.undone)-1]
+	h.done = append(h.done, e)
+	h.mu.Unlock()
+	printf("Redid %s.\n", e.String())
+	return nil
+}
+
+// replay reverts a change through the journal without recording the revert
+// in the session's history. A record recreated by the revert has a new ID,
+// which the entry and the other entries of the history take.
+func (h *sessionHistory) replay(api *cloudflare.API, e *journalEntry, force bool) error {
+	h.mu.Lock()
+	h.replaying = true
+	h.mu.Unlock()
+	defer func() {
+		h.mu.Lock()
+		h.replaying = false
+		h.mu.Unlock()
+	}()
+
+	id, err := revert(recordBackend(api), e, force)
+	if err != nil {
+		return err
+	}
+	if e.Action == "delete" && id != e.Before.ID {
+		h.mu.Lock()
+		old := e.Before.ID
+		for _, entries := range [][]journalEntry{h.done, h.undone, {*e}} {
+			for _, other := range entries {
+				for _, r := range []*cloudflare.DNSRecord{other.Before, other.After} {
+					if r != nil && r.ID == old {
+						r.ID = id
+					}
+				}
+			}
+		}
+		h.mu.Unlock()
+	}
+	return nil
+}
+
+// inverse returns the change undoing the entry's change.
+func (e journalEntry) inverse() journalEntry {
+	inv := journalEntry{Time: e.Time, ZoneID: e.ZoneID, Before: e.After, After: e.Before}
+	switch e.Action {
+	case "create":
+		inv.Action = "delete"
+	case "delete":
+		inv.Action = "create"
+	default:
+		inv.Action = e.Action
+	}
+	return inv
+}
+
+var redoFlags = registerFlags("redo", forceFlags)
+
+func cmdRedo(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, redoFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	return session.redo(api, flags.force())
+}
@@ -0,0 +1,236 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+)
+
+// hostnameAnnotation is the annotation external-dns reads the hostnames of
+// a Service from.
+const hostnameAnnotation = "external-dns.alpha.kubernetes.io/hostname"
+
+// k8sList is the subset of a Kubernetes list of Ingress and Service
+// resources used to derive DNS records.
+type k8sList struct {
+	Items []k8sResource `json:"items"`
+}
+
+type k8sResource struct {
+	Kind     string `json:"kind"`
+	Metadata struct {
+		Name        string            `json:"name"`
+		Namespace   string            `json:"namespace"`
+		Annotations map[string]string `json:"annotations"`
+	} `json:"metadata"`
+	Spec struct {
+		Type  string `json:"type"`
+		Rules []struct {
+			Host string `json:"host"`
+		} `json:"rules"`
+		TLS []struct {
+			Hosts []string `json:"hosts"`
+		} `json:"tls"`
+	} `json:"spec"`
+	Status struct {
+		LoadBalancer struct {
+			Ingress []struct {
+				IP       string `json:"ip"`
+				Hostname string `json:"hostname"`
+			} `json:"ingress"`
+		} `json:"loadBalancer"`
+	} `json:"status"`
+}
+
+// hosts returns the hostnames a resource serves.
+func (r k8sResource) hosts() []string {
+	var hosts []string
+	switch r.Kind {
+	case "Ingress":
+		for _, rule := range r.Spec.Rules {
+			hosts = append(hosts, rule.Host)
+		}
+		for _, tls := range r.Spec.TLS {
+			hosts = append(hosts, tls.Hosts...)
+		}
+	case "Service":
+		if r.Spec.Type != "LoadBalancer" {
+			return nil
+		}
+		hosts = strings.Split(r.Metadata.Annotations[hostnameAnnotation], ",")
+	}
+
+	var out []string
+	seen := make(map[string]bool)
+	for _, h := range hosts {
+		h = cflib.NormalizeName(strings.TrimSpace(h))
+		if h != "" && !seen[h] {
+			seen[h] = true
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+// targets returns the load balancer addresses and hostnames of a resource.
+func (r k8sResource) targets() []string {
+	var targets []string
+	for _, lb := range r.Status.LoadBalancer.Ingress {
+		if lb.IP != "" {
+			targets = append(targets, lb.IP)
+		} else if lb.Hostname != "" {
+			targets = append(targets, lb.Hostname)
+		}
+	}
+	return targets
+}
+
+var k8sScanFlags = registerFlags("k8s scan", mergeFlags(forceFlags, conflictFlags, flagSpec{
+	"context":   true,
+	"namespace": true,
+	"target":    true,
+	"apply":     false,
+}))
+
+func cmdK8s(c *cmd.Command, args []string) error {
+	if len(args) < 1 || args[0] != "scan" {
+		return usageError(c)
+	}
+	flags, args, err := parseFlags(args[1:], k8sScanFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+	onConflict, err := parseConflict(flags)
+	if err != nil {
+		return err
+	}
+
+	resources, err := kubectlResources(flags.get("context", ""), flags.get("namespace", ""))
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	proposals, skipped := proposeK8sRecords(resources, activeZoneName, flags.get("target", ""))
+	for _, h := range skipped {
+		printf("Skipping %s, which is not in zone %s.\n", h, activeZoneName)
+	}
+	if len(proposals) == 0 {
+		printf("No records to propose.\n")
+		return nil
+	}
+
+	changes, err := planProposals(api, zoneID, proposals, onConflict)
+	if err != nil {
+		return err
+	}
+	if !flags.force() {
+		changes = protectProposals(proposals, activeZoneName, changes)
+	}
+
+	displayProposals(proposals)
+
+	if !flags.has("apply") {
+		return nil
+	}
+	return applyProposals(api, zoneID, proposals, changes, flags.force())
+}
+
+// kubectlResources reads the Ingress and Service resources of a cluster
+// with kubectl, which takes care of the kubeconfig file and of every
+// authentication method it supports.
+func kubectlResources(context, namespace string) ([]k8sResource, error) {
+	args := []string{"get", "ingresses,services", "-o", "json"}
+	if context != "" {
+		args = append(args, "--context", context)
+	}
+	if namespace != "" {
+		args = append(args, "--namespace", namespace)
+	} else {
+		args = append(args, "--all-namespaces")
+	}
+
+	var stdout, stderr bytes.Buffer
+	kubectl := exec.Command("kubectl", args...)
+	kubectl.Stdout = &stdout
+	kubectl.Stderr = &stderr
+	if err := kubectl.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, errors.New("kubectl was not found in the PATH")
+		}
+		return nil, fmt.Errorf("kubectl: %s", strings.TrimSpace(stderr.String()))
+	}
+
+	var list k8sList
+	if err := json.Unmarshal(stdout.Bytes(), &list); err != nil {
+		return nil, fmt.Errorf("kubectl: %v", err)
+	}
+	return list.Items, nil
+}
+
+// proposeK8sRecords derives a DNS record for every hostname served by the
+// resources within the zone. Each hostname points at its resource's load
+// balancer, or at target if one is given. It also returns the hostnames
+// outside the zone.
+func proposeK8sRecords(resources []k8sResource, zone, target string) ([]proposal, []string) {
+	var proposals []proposal
+	var skipped []string
+	seen := make(map[string]bool)
+
+	for _, r := range resources {
+		targets := r.targets()
+		if target != "" {
+			targets = []string{target}
+		}
+		if len(targets) == 0 {
+			continue
+		}
+
+		source := strings.ToLower(r.Kind) + "/" + r.Metadata.Namespace + "/" + r.Metadata.Name
+		for _, h := range r.hosts() {
+			if !inZone(h, zone) {
+				skipped = append(skipped, h)
+				continue
+			}
+			for _, t := range targets {
+				recType, content := targetRecord(t)
+				key := recType + " " + h
+				if seen[key] {
+					continue
+				}
+				seen[key] = true
+				proposals = append(proposals, proposal{
+					source:  source,
+					recType: recType,
+					name:    h,
+					content: content,
+				})
+			}
+		}
+	}
+
+	sortProposals(proposals)
+	return proposals, skipped
+}
@@ -0,0 +1,92 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+
+	"github.com/beevik/cmd"
+)
+
+// keyringService is the service name under which credentials are stored
+// in the system keyring.
+const keyringService = "cf"
+
+var errKeyringUnsupported = errors.New("system keyring not supported on this platform")
+
+// keyringCredentials are the credentials stored in the system keyring.
+type keyringCredentials struct {
+	Email string `json:"email,omitempty"`
+	Key   string `json:"key,omitempty"`
+	Token string `json:"token,omitempty"`
+}
+
+// keyringAccount returns the keyring account name used to store the
+// credentials of the active profile.
+func keyringAccount() string {
+	if activeProfileName != "" {
+		return activeProfileName
+	}
+	return "default"
+}
+
+// loadKeyringCredentials retrieves credentials previously stored in the
+// system keyring. It returns false if no credentials are stored.
+func loadKeyringCredentials() (keyringCredentials, bool) {
+	var creds keyringCredentials
+
+	secret, err := keyringGet(keyringService, keyringAccount())
+	if err != nil || secret == "" {
+		return creds, false
+	}
+
+	data, err := base64.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return creds, false
+	}
+	if err := json.Unmarshal(data, &creds); err != nil {
+		return creds, false
+	}
+	return creds, true
+}
+
+func saveKeyringCredentials(creds keyringCredentials) error {
+	data, err := json.Marshal(creds)
+	if err != nil {
+		return err
+	}
+	secret := base64.StdEncoding.EncodeToString(data)
+	return keyringSet(keyringService, keyringAccount(), secret)
+}
+
+// offerKeyringStorage asks the user whether interactively entered
+// credentials should be stored in the system keyring.
+func offerKeyringStorage(creds keyringCredentials) {
+	if !confirm("Store these credentials in the system keyring?") {
+		return
+	}
+	if err := saveKeyringCredentials(creds); err != nil {
+		printf("Error: %v\n", err)
+		return
+	}
+	printf("Credentials stored.\n")
+}
+
+func cmdLogout(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	activeAPI = nil
+	err := keyringDelete(keyringService, keyringAccount())
+	if err != nil {
+		return err
+	}
+
+	printf("Stored credentials removed.\n")
+	return nil
+}
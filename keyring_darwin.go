@@ -0,0 +1,43 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// On macOS, the keychain is accessed through the security command line
+// tool. Secrets are passed on the tool's standard input so they never
+// appear in a process listing.
+
+func keyringGet(service, account string) (string, error) {
+	out, err := exec.Command("security", "find-generic-password",
+		"-s", service, "-a", account, "-w").Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keyringSet(service, account, secret string) error {
+	c := exec.Command("security", "-i")
+	c.Stdin = strings.NewReader(fmt.Sprintf(
+		"add-generic-password -U -s %q -a %q -w %q\n", service, account, secret))
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("security: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func keyringDelete(service, account string) error {
+	err := exec.Command("security", "delete-generic-password",
+		"-s", service, "-a", account).Run()
+	if err != nil {
+		return fmt.Errorf("no stored credentials found")
+	}
+	return nil
+}
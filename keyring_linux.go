@@ -0,0 +1,45 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+// On Linux, the keyring is accessed through libsecret's secret-tool
+// command, which is available on most desktop distributions.
+
+func keyringGet(service, account string) (string, error) {
+	out, err := exec.Command("secret-tool", "lookup",
+		"service", service, "account", account).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func keyringSet(service, account, secret string) error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return errKeyringUnsupported
+	}
+
+	c := exec.Command("secret-tool", "store", "--label=cf credentials",
+		"service", service, "account", account)
+	c.Stdin = strings.NewReader(secret)
+	if out, err := c.CombinedOutput(); err != nil {
+		return fmt.Errorf("secret-tool: %s", strings.TrimSpace(string(out)))
+	}
+	return nil
+}
+
+func keyringDelete(service, account string) error {
+	if _, err := exec.LookPath("secret-tool"); err != nil {
+		return errKeyringUnsupported
+	}
+	return exec.Command("secret-tool", "clear",
+		"service", service, "account", account).Run()
+}
@@ -0,0 +1,19 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !darwin && !linux && !windows
+
+package main
+
+func keyringGet(service, account string) (string, error) {
+	return "", errKeyringUnsupported
+}
+
+func keyringSet(service, account, secret string) error {
+	return errKeyringUnsupported
+}
+
+func keyringDelete(service, account string) error {
+	return errKeyringUnsupported
+}
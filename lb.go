@@ -0,0 +1,373 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var lbFlags = registerFlags("lb", forceFlags)
+
+func cmdLB(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, lbFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch {
+	case args[0] == "list" && len(args) == 1:
+		return listLoadBalancers()
+	case args[0] == "pools" && len(args) == 1:
+		return listPools()
+	case args[0] == "monitors" && len(args) == 1:
+		return listMonitors()
+	case args[0] == "health" && len(args) <= 2:
+		return showPoolHealth(args[1:])
+	case args[0] == "origin" && len(args) == 4 && (args[1] == "enable" || args[1] == "disable"):
+		return toggleOrigin(args[2], args[3], args[1] == "enable", flags.force())
+	default:
+		return usageError(c)
+	}
+}
+
+// accountRC returns the resource container of the active zone's account,
+// which holds its load balancer pools and monitors.
+func accountRC(api *cloudflare.API) (*cloudflare.ResourceContainer, error) {
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	accountID, err := getAccountID(api, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	return cloudflare.AccountIdentifier(accountID), nil
+}
+
+// accountPools returns the load balancer pools of the active zone's
+// account, sorted by name.
+func accountPools(api *cloudflare.API) (*cloudflare.ResourceContainer, []cloudflare.LoadBalancerPool, error) {
+	rc, err := accountRC(api)
+	if err != nil {
+		return nil, nil, err
+	}
+	pools, err := api.ListLoadBalancerPools(commandCtx, rc, cloudflare.ListLoadBalancerPoolParams{})
+	if err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(pools, func(i, j int) bool { return pools[i].Name < pools[j].Name })
+	return rc, pools, nil
+}
+
+// findPool returns the pool with a name or ID.
+func findPool(pools []cloudflare.LoadBalancerPool, pool string) (cloudflare.LoadBalancerPool, error) {
+	for _, p := range pools {
+		if p.ID == pool || strings.EqualFold(p.Name, pool) {
+			return p, nil
+		}
+	}
+	return cloudflare.LoadBalancerPool{}, fmt.Errorf("pool %s not found", pool)
+}
+
+func enabledString(enabled bool) string {
+	if enabled {
+		return "enabled"
+	}
+	return "disabled"
+}
+
+func healthString(healthy *bool) string {
+	switch {
+	case healthy == nil:
+		return "unknown"
+	case *healthy:
+		return "healthy"
+	default:
+		return "unhealthy"
+	}
+}
+
+// listLoadBalancers displays the load balancers of the active zone and the
+// pools they steer traffic to.
+func listLoadBalancers() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	lbs, err := api.ListLoadBalancers(commandCtx, zoneID, cloudflare.ListLoadBalancerParams{})
+	if err != nil {
+		return err
+	}
+	if len(lbs) == 0 {
+		printf("No load balancers found.\n")
+		return nil
+	}
+
+	poolNames := make(map[string]string)
+	if _, pools, err := accountPools(api); err == nil {
+		for _, p := range pools {
+			poolNames[p.ID] = p.Name
+		}
+	}
+	name := func(id string) string {
+		if n, ok := poolNames[id]; ok {
+			return n
+		}
+		return id
+	}
+
+	sort.Slice(lbs, func(i, j int) bool { return lbs[i].Name < lbs[j].Name })
+	width := 0
+	for _, lb := range lbs {
+		width = max(width, len(lb.Name))
+	}
+	for _, lb := range lbs {
+		var pools []string
+		for _, id := range lb.DefaultPools {
+			pools = append(pools, name(id))
+		}
+		enabled := lb.Enabled == nil || *lb.Enabled
+		proxied := "dns-only"
+		if lb.Proxied {
+			proxied = "proxied"
+		}
+		fmt.Fprintf(output, "%-*s %-8s %-8s %s pools: %s\n", width, lb.Name, enabledString(enabled),
+			proxied, lb.SteeringPolicy, strings.Join(pools, ", "))
+		if lb.FallbackPool != "" {
+			fmt.Fprintf(output, "%-*s fallback: %s\n", width, "", name(lb.FallbackPool))
+		}
+	}
+	return nil
+}
+
+// listPools displays the load balancer pools of the active zone's account
+// and their origins.
+func listPools() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	_, pools, err := accountPools(api)
+	if err != nil {
+		return err
+	}
+	if len(pools) == 0 {
+		printf("No load balancer pools found.\n")
+		return nil
+	}
+
+	width := 0
+	for _, p := range pools {
+		width = max(width, len(p.Name))
+	}
+	for _, p := range pools {
+		fmt.Fprintf(output, "%-*s %s %-8s %s\n", width, p.Name, p.ID, enabledString(p.Enabled), healthString(p.Healthy))
+		for _, o := range p.Origins {
+			fmt.Fprintf(output, "    %-20s %-30s %-8s weight %g\n", o.Name, o.Address, enabledString(o.Enabled), o.Weight)
+		}
+	}
+	return nil
+}
+
+// listMonitors displays the load balancer health monitors of the active
+// zone's account.
+func listMonitors() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	rc, err := accountRC(api)
+	if err != nil {
+		return err
+	}
+	monitors, err := api.ListLoadBalancerMonitors(commandCtx, rc, cloudflare.ListLoadBalancerMonitorParams{})
+	if err != nil {
+		return err
+	}
+	if len(monitors) == 0 {
+		printf("No health monitors found.\n")
+		return nil
+	}
+
+	for _, m := range monitors {
+		check := strings.ToUpper(m.Type)
+		if m.Type == "http" || m.Type == "https" {
+			check += " " + m.Method + " " + m.Path
+			if m.ExpectedCodes != "" {
+				check += " -> " + m.ExpectedCodes
+			}
+		}
+		if m.Port != 0 {
+			check += fmt.Sprintf(" port %d", m.Port)
+		}
+		fmt.Fprintf(output, "%s %-40s every %ds, %d retries", m.ID, check, m.Interval, m.Retries)
+		if m.Description != "" {
+			fmt.Fprintf(output, " (%s)", m.Description)
+		}
+		fmt.Fprintln(output)
+	}
+	return nil
+}
+
+// An originHealth summarizes the health checks of an origin across the
+// data centers checking it.
+type originHealth struct {
+	Healthy  int      // data centers finding the origin healthy
+	Checked  int      // data centers checking the origin
+	Failures []string // distinct failure reasons, sorted
+}
+
+// summarizePoolHealth summarizes the health of each origin of a pool,
+// keyed by origin address, from the checks made by each data center.
+func summarizePoolHealth(h cloudflare.LoadBalancerPoolHealth) map[string]*originHealth {
+	origins := make(map[string]*originHealth)
+	failures := make(map[string]map[string]bool)
+	for _, pop := range h.PopHealth {
+		for _, m := range pop.Origins {
+			for addr, oh := range m {
+				s, ok := origins[addr]
+				if !ok {
+					s = &originHealth{}
+					origins[addr] = s
+					failures[addr] = make(map[string]bool)
+				}
+				s.Checked++
+				if oh.Healthy {
+					s.Healthy++
+				} else if oh.FailureReason != "" && !failures[addr][oh.FailureReason] {
+					failures[addr][oh.FailureReason] = true
+					s.Failures = append(s.Failures, oh.FailureReason)
+				}
+			}
+		}
+	}
+	for _, s := range origins {
+		sort.Strings(s.Failures)
+	}
+	return origins
+}
+
+// showPoolHealth displays the health of the origins of one pool, or of
+// every pool in the active zone's account.
+func showPoolHealth(args []string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	rc, pools, err := accountPools(api)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 {
+		p, err := findPool(pools, args[0])
+		if err != nil {
+			return err
+		}
+		pools = []cloudflare.LoadBalancerPool{p}
+	}
+	if len(pools) == 0 {
+		printf("No load balancer pools found.\n")
+		return nil
+	}
+
+	for _, p := range pools {
+		fmt.Fprintf(output, "%s %s\n", p.Name, healthString(p.Healthy))
+		h, err := api.GetLoadBalancerPoolHealth(commandCtx, rc, p.ID)
+		if err != nil {
+			return err
+		}
+		health := summarizePoolHealth(h)
+		for _, o := range p.Origins {
+			if !o.Enabled {
+				fmt.Fprintf(output, "    %-20s %-30s %s\n", o.Name, o.Address, "disabled")
+				continue
+			}
+			s, ok := health[o.Address]
+			if !ok {
+				fmt.Fprintf(output, "    %-20s %-30s %s\n", o.Name, o.Address, "not checked")
+				continue
+			}
+			fmt.Fprintf(output, "    %-20s %-30s healthy in %d of %d data centers\n", o.Name, o.Address,
+				s.Healthy, s.Checked)
+			for _, f := range s.Failures {
+				fmt.Fprintf(output, "        %s\n", f)
+			}
+		}
+	}
+	return nil
+}
+
+// toggleOrigin enables or disables an origin of a pool, given by its name
+// or address. Disabling an origin removes it from rotation, so it asks for
+// confirmation unless forced.
+func toggleOrigin(pool, origin string, enable, force bool) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	rc, pools, err := accountPools(api)
+	if err != nil {
+		return err
+	}
+	p, err := findPool(pools, pool)
+	if err != nil {
+		return err
+	}
+
+	i := slices.IndexFunc(p.Origins, func(o cloudflare.LoadBalancerOrigin) bool {
+		return strings.EqualFold(o.Name, origin) || o.Address == origin
+	})
+	if i < 0 {
+		return fmt.Errorf("origin %s not found in pool %s", origin, p.Name)
+	}
+	o := &p.Origins[i]
+	if o.Enabled == enable {
+		printf("Origin %s of pool %s is already %s.\n", o.Name, p.Name, tr(enabledString(enable)))
+		return nil
+	}
+
+	if !enable {
+		remaining := 0
+		for _, other := range p.Origins {
+			if other.Enabled && other.Name != o.Name {
+				remaining++
+			}
+		}
+		if remaining == 0 {
+			printf("Warning: origin %s is the last enabled origin of pool %s.\n", o.Name, p.Name)
+		}
+		if !force && !confirm(sprintf("Disable origin %s (%s) of pool %s?", o.Name, o.Address, p.Name)) {
+			printf("Origin not disabled.\n")
+			return nil
+		}
+	}
+
+	o.Enabled = enable
+	updated, err := api.UpdateLoadBalancerPool(commandCtx, rc, cloudflare.UpdateLoadBalancerPoolParams{LoadBalancer: p})
+	if err != nil {
+		return err
+	}
+	printf("Origin %s of pool %s is now %s.\n", o.Name, updated.Name, tr(enabledString(enable)))
+	return nil
+}
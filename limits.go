@@ -0,0 +1,124 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// planLimits holds the documented per-zone limits of a Cloudflare plan for
+// resources whose limits are not reported by the API.
+type planLimits struct {
+	records     int
+	customRules int
+}
+
+var planLimitTable = map[string]planLimits{
+	"free":       {records: 200, customRules: 5},
+	"pro":        {records: 3500, customRules: 20},
+	"business":   {records: 3500, customRules: 100},
+	"enterprise": {records: 3500, customRules: 1000},
+}
+
+// zoneUsage describes how much of its plan's allowance a zone is using.
+type zoneUsage struct {
+	plan, planID                 string
+	records, recordLimit         int
+	pageRules, pageRuleLimit     int
+	customRules, customRuleLimit int
+}
+
+func cmdLimits(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	u, err := getZoneUsage(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	printf("Plan: %s\n", u.plan)
+	fmt.Fprintf(output, "%-12s %6s %6s\n", "Resource", "Used", "Limit")
+	fmt.Fprintf(output, "%-12s %6d %6s\n", "DNS records", u.records, formatLimit(u.recordLimit))
+	fmt.Fprintf(output, "%-12s %6d %6s\n", "Page rules", u.pageRules, formatLimit(u.pageRuleLimit))
+	fmt.Fprintf(output, "%-12s %6d %6s\n", "Custom rules", u.customRules, formatLimit(u.customRuleLimit))
+
+	fmt.Fprintln(output)
+	for _, c := range capabilities {
+		available := tr("yes")
+		if !hasCapability(u.planID, c) {
+			available = tr("no")
+		}
+		fmt.Fprintf(output, "%-40s %s\n", c.feature, available)
+	}
+	return nil
+}
+
+func getZoneUsage(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) (zoneUsage, error) {
+	var u zoneUsage
+	ctx := commandCtx
+
+	zone, err := api.ZoneDetails(ctx, zoneID.Identifier)
+	if err != nil {
+		return u, err
+	}
+	u.plan, u.planID = zone.Plan.Name, zone.Plan.LegacyID
+	u.pageRuleLimit = zone.Meta.PageRuleQuota
+
+	limits, ok := planLimitTable[zone.Plan.LegacyID]
+	if ok {
+		u.recordLimit = limits.records
+		u.customRuleLimit = limits.customRules
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return u, err
+	}
+	u.records = len(recs)
+
+	rules, err := api.ListPageRules(ctx, zoneID.Identifier)
+	if err != nil {
+		return u, err
+	}
+	u.pageRules = len(rules)
+
+	rs, err := api.GetEntrypointRuleset(ctx, zoneID, "http_request_firewall_custom")
+	if err != nil && !isNotFound(err) {
+		return u, err
+	}
+	u.customRules = len(rs.Rules)
+
+	return u, nil
+}
+
+// formatLimit formats a plan limit, displaying unknown limits as "?".
+func formatLimit(n int) string {
+	if n == 0 {
+		return "?"
+	}
+	return fmt.Sprint(n)
+}
+
+func isNotFound(err error) bool {
+	var cfErr *cloudflare.Error
+	return errors.As(err, &cfErr) && cfErr.StatusCode == http.StatusNotFound
+}
@@ -0,0 +1,175 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A lintProblem is a record flagged by a lint check.
+type lintProblem struct {
+	rec     zoneRecord
+	message string
+}
+
+// lintChecks maps the flags selecting lint checks to the checks. Each check
+// examines all of the records and returns the problems it finds.
+var lintChecks = map[string]func(recs []zoneRecord) []lintProblem{
+	"cnames":   lintCNAMEs,
+	"exposure": lintExposure,
+	"ttl":      lintTTL,
+}
+
+// lintFlags select the checks run by lint, each named after its check.
+var lintFlags = registerFlags("lint", func() flagSpec {
+	spec := flagSpec{}
+	for name := range lintChecks {
+		spec[name] = false
+	}
+	return mergeFlags(zoneFlags, spec)
+}())
+
+func cmdLint(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, lintFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	// Without flags selecting checks, all checks are run.
+	var checks []string
+	for name := range lintChecks {
+		if flags.has(name) {
+			checks = append(checks, name)
+		}
+	}
+	if len(checks) == 0 {
+		for name := range lintChecks {
+			checks = append(checks, name)
+		}
+	}
+	sort.Strings(checks)
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	recs, err := listZoneRecords(api, zones, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+
+	var problems []lintProblem
+	for _, name := range checks {
+		problems = append(problems, lintChecks[name](recs)...)
+	}
+	if len(problems) == 0 {
+		printf("No problems found.\n")
+		return nil
+	}
+
+	showZone := len(zones) > 1
+	for _, p := range problems {
+		if showZone {
+			fmt.Fprintf(output, "%s: ", p.rec.zone)
+		}
+		fmt.Fprintf(output, "%s %s %s: %s\n", p.rec.Type, p.rec.Name, p.rec.Content, p.message)
+	}
+	return fmt.Errorf("%d problem(s) found", len(problems))
+}
+
+// lintExposure flags the unproxied records revealing the origin of a
+// proxied record. A proxied record's content is its origin, which the
+// proxy hides, so an unproxied address or CNAME record with the same
+// content, or an SPF policy listing the origin address, reveals it.
+func lintExposure(recs []zoneRecord) []lintProblem {
+	origins := make(map[string][]string) // origin -> proxied names
+	for _, r := range recs {
+		if isProxied(r.DNSRecord) {
+			key := originKey(r.Type, r.Content)
+			origins[key] = append(origins[key], r.Name)
+		}
+	}
+
+	var problems []lintProblem
+	report := func(r zoneRecord, key string) {
+		names := origins[key]
+		if len(names) == 0 {
+			return
+		}
+		problems = append(problems, lintProblem{
+			rec:     r,
+			message: sprintf("exposes the origin of proxied %s", strings.Join(names, ", ")),
+		})
+	}
+
+	for _, r := range recs {
+		if isProxied(r.DNSRecord) {
+			continue
+		}
+		switch r.Type {
+		case "A", "AAAA", "CNAME":
+			report(r, originKey(r.Type, r.Content))
+		case "TXT":
+			for _, addr := range spfAddresses(r.Content) {
+				report(r, originKey("A", addr))
+			}
+		}
+	}
+	return problems
+}
+
+func isProxied(r cloudflare.DNSRecord) bool {
+	return r.Proxied != nil && *r.Proxied
+}
+
+// originKey returns the key identifying the origin named by a record's
+// content. A and AAAA records share keys, since both hold addresses, and
+// an IPv4-mapped IPv6 address has the key of its IPv4 address.
+func originKey(recType, content string) string {
+	if recType == "CNAME" {
+		return "name " + cflib.NormalizeName(content)
+	}
+	if addr, err := netip.ParseAddr(content); err == nil {
+		return "addr " + addr.Unmap().String()
+	}
+	return "addr " + content
+}
+
+// spfAddresses returns the addresses listed by the ip4 and ip6 mechanisms
+// of an SPF policy. Address ranges are skipped.
+func spfAddresses(content string) []string {
+	content = cflib.NormalizeTXT(content)
+	if !strings.HasPrefix(strings.ToLower(content), "v=spf1") {
+		return nil
+	}
+	var addrs []string
+	for _, term := range strings.Fields(content) {
+		term = strings.TrimLeft(term, "+")
+		_, value, ok := strings.Cut(term, ":")
+		if !ok || !(strings.HasPrefix(term, "ip4:") || strings.HasPrefix(term, "ip6:")) {
+			continue
+		}
+		if _, err := netip.ParseAddr(value); err == nil {
+			addrs = append(addrs, value)
+		}
+	}
+	return addrs
+}
@@ -0,0 +1,147 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestLintExposure(t *testing.T) {
+	proxied := func(recType, name, content string) zoneRecord {
+		on := true
+		return zoneRecord{DNSRecord: cloudflare.DNSRecord{Type: recType, Name: name, Content: content, Proxied: &on}}
+	}
+	plain := func(recType, name, content string) zoneRecord {
+		return zoneRecord{DNSRecord: cloudflare.DNSRecord{Type: recType, Name: name, Content: content}}
+	}
+
+	recs := []zoneRecord{
+		proxied("A", "www.example.com", "203.0.113.7"),
+		proxied("AAAA", "www.example.com", "2001:db8::7"),
+		proxied("CNAME", "shop.example.com", "shops.example.net"),
+		plain("A", "direct.example.com", "203.0.113.7"),
+		plain("AAAA", "direct.example.com", "2001:DB8:0::7"),
+		plain("A", "mail.example.com", "203.0.113.25"),
+		plain("CNAME", "checkout.example.com", "shops.example.net."),
+		plain("TXT", "example.com", "v=spf1 ip4:203.0.113.7 ip4:198.51.100.0/24 -all"),
+		plain("TXT", "note.example.com", "ip4:203.0.113.7"),
+	}
+
+	var got []string
+	for _, p := range lintExposure(recs) {
+		got = append(got, p.rec.Type+" "+p.rec.Name)
+	}
+	want := []string{
+		"A direct.example.com",
+		"AAAA direct.example.com",
+		"CNAME checkout.example.com",
+		"TXT example.com",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("exposing records = %q, want %q", got, want)
+	}
+}
+
+func TestTTLPolicies(t *testing.T) {
+	b := useMemoryBackend(t)
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{TTLPolicies: []ttlPolicy{
+		{Zones: []string{"example.com"}, Types: []string{"A"}, Max: 300},
+		{Types: []string{"MX"}, Min: 3600, Block: true},
+	}}
+	for i := range cfg.TTLPolicies {
+		if err := cfg.TTLPolicies[i].parse(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// A warning policy lets the change through, while a blocking one
+	// refuses it.
+	if err := processCmd("add A www.example.com 203.0.113.7 3600"); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("add MX example.com mail.example.com 300"); err == nil {
+		t.Error("change violating a blocking policy was made")
+	}
+	if err := processCmd("add A api.example.com 203.0.113.8"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b, "A api.example.com 203.0.113.8", "A www.example.com 203.0.113.7")
+
+	var recs []zoneRecord
+	for _, r := range b.Records() {
+		recs = append(recs, zoneRecord{zone: "example.com", DNSRecord: r})
+	}
+	problems := lintTTL(recs)
+	if len(problems) != 1 || problems[0].rec.Name != "www.example.com" {
+		t.Errorf("lintTTL found %v", problems)
+	}
+
+	if err := (&ttlPolicy{Min: 600, Max: 60}).parse(); err == nil {
+		t.Error("policy with min above max accepted")
+	}
+}
+
+func TestAliasGraph(t *testing.T) {
+	rec := func(recType, name, content string) zoneRecord {
+		return zoneRecord{zone: "example.com", DNSRecord: cloudflare.DNSRecord{Type: recType, Name: name, Content: content}}
+	}
+	recs := []zoneRecord{
+		rec("CNAME", "www.example.com", "lb.example.com"),
+		rec("CNAME", "lb.example.com", "origin.example.net."),
+		rec("CNAME", "shop.example.com", "shops.example.net"),
+		rec("CNAME", "old.example.com", "gone.example.com"),
+		rec("CNAME", "a.example.com", "b.example.com"),
+		rec("CNAME", "b.example.com", "a.example.com"),
+		rec("CNAME", "api.example.com", "example.com"),
+		rec("A", "example.com", "203.0.113.7"),
+	}
+
+	var drs []cloudflare.DNSRecord
+	for _, r := range recs {
+		drs = append(drs, r.DNSRecord)
+	}
+	var got []string
+	for _, c := range newAliasGraph("example.com", drs).chains() {
+		got = append(got, c.String()+" "+c.End)
+	}
+	want := []string{
+		"api.example.com -> example.com resolves",
+		"old.example.com -> gone.example.com dangling",
+		"shop.example.com -> shops.example.net external",
+		"www.example.com -> lb.example.com -> origin.example.net external",
+		"a.example.com -> b.example.com -> a.example.com loop",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("chains = %q, want %q", got, want)
+	}
+
+	got = nil
+	for _, p := range lintCNAMEs(recs) {
+		got = append(got, p.rec.Name)
+	}
+	want = []string{"old.example.com", "www.example.com", "a.example.com"}
+	if !slices.Equal(got, want) {
+		t.Errorf("flagged aliases = %q, want %q", got, want)
+	}
+
+	var buf strings.Builder
+	writeMermaidGraph(&buf, newAliasGraph("example.com", drs))
+	for _, line := range []string{
+		`  n10["www.example.com"]`,
+		`  n10 --> n5`,
+		`  class n7,n9 external`,
+		`  class n4 dangling`,
+	} {
+		if !strings.Contains(buf.String(), line+"\n") {
+			t.Errorf("mermaid graph lacks %q:\n%s", line, buf.String())
+		}
+	}
+}
@@ -0,0 +1,704 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// spfLookupLimit is the number of DNS lookups an SPF policy may cause,
+// including those of the policies it includes, before receivers reject it
+// (RFC 7208, section 4.6.4).
+const spfLookupLimit = 10
+
+// lookupTXT returns the TXT records of a name, for following the includes
+// of SPF policies and finding the networks of origins.
+var lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+	return net.DefaultResolver.LookupTXT(ctx, name)
+}
+
+// A mailIssue is a problem found in a mail authentication record. Errors
+// make the record ineffective or harmful; warnings are common mistakes.
+type mailIssue struct {
+	kind    string // SPF, DKIM or DMARC
+	name    string
+	err     bool
+	message string
+}
+
+var (
+	spfSetFlags   = registerFlags("mail spf set", forceFlags)
+	dmarcSetFlags = registerFlags("mail dmarc set", flagSpec{
+		"policy":           true,
+		"subdomain-policy": true,
+		"rua":              true,
+		"ruf":              true,
+		"pct":              true,
+	})
+)
+
+func cmdMail(c *cmd.Command, args []string) error {
+	switch {
+	case len(args) >= 2 && args[0] == "spf" && args[1] == "set":
+		flags, args, err := parseFlags(args[2:], spfSetFlags)
+		if err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		return setSPF(args[0], flags.force())
+	case len(args) >= 2 && args[0] == "dmarc" && args[1] == "set":
+		flags, args, err := parseFlags(args[2:], dmarcSetFlags)
+		if err != nil {
+			return err
+		}
+		if len(args) != 0 {
+			return usageError(c)
+		}
+		return setDMARC(flags)
+	case len(args) == 1 && args[0] == "check":
+		return checkMail()
+	default:
+		return usageError(c)
+	}
+}
+
+// isSPF reports whether TXT content holds an SPF policy.
+func isSPF(content string) bool {
+	content = strings.ToLower(cflib.NormalizeTXT(content))
+	return content == "v=spf1" || strings.HasPrefix(content, "v=spf1 ")
+}
+
+// setSPF sets the SPF policy of the active zone's apex, replacing any
+// existing policy. Other TXT records at the apex are left alone.
+func setSPF(policy string, force bool) error {
+	for _, issue := range checkSPFSyntax(policy) {
+		if issue.err {
+			return argError(fmt.Errorf("invalid SPF policy: %s", issue.message))
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	zone := activeZoneName
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: "TXT", Name: zone})
+	if err != nil {
+		return err
+	}
+	var existing []cloudflare.DNSRecord
+	for _, r := range recs {
+		if isSPF(r.Content) {
+			existing = append(existing, r)
+		}
+	}
+
+	if len(existing) == 1 && cflib.ContentEqual("TXT", existing[0].Content, policy) {
+		printf("%s record %s is already %s.\n", "TXT", zone, policy)
+		return nil
+	}
+	if len(existing) > 1 && !force {
+		printf("Zone %s has %d SPF policies, which receivers reject; all but one will be deleted:\n",
+			zone, len(existing))
+		for _, r := range existing {
+			fmt.Fprintf(output, "    %s\n", r.Content)
+		}
+		if !confirm(sprintf("Replace them with the new policy?")) {
+			printf("No changes applied.\n")
+			return nil
+		}
+	}
+
+	b := recordBackend(api)
+	if len(existing) == 0 {
+		_, err = b.CreateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.CreateDNSRecordParams{
+			Type:    "TXT",
+			Name:    zone,
+			Content: policy,
+			TTL:     defaultTTL,
+		})
+	} else {
+		r := existing[0]
+		_, err = b.UpdateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.UpdateDNSRecordParams{
+			ID:      r.ID,
+			Type:    r.Type,
+			Name:    r.Name,
+			Content: policy,
+			TTL:     r.TTL,
+			Comment: &r.Comment,
+			Tags:    r.Tags,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range existing[min(1, len(existing)):] {
+		if err := b.DeleteDNSRecord(commandCtx, zoneID.Identifier, r.ID); err != nil {
+			return err
+		}
+		printf("Deleted %s record %s (%s).\n", r.Type, r.Name, r.Content)
+	}
+	printf("Set the SPF policy of %s to %s.\n", zone, policy)
+	return nil
+}
+
+// setDMARC sets the DMARC policy of the active zone. Tags of an existing
+// policy not given by flags are kept.
+func setDMARC(flags flagValues) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	name := "_dmarc." + cflib.NormalizeName(activeZoneName)
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: "TXT", Name: name})
+	if err != nil {
+		return err
+	}
+
+	var tags []dmarcTag
+	for _, r := range recs {
+		if t := parseDMARCTags(cflib.NormalizeTXT(r.Content)); len(t) > 0 && strings.EqualFold(t[0].value, "DMARC1") {
+			tags = t
+			break
+		}
+	}
+	if len(tags) == 0 {
+		if !flags.has("policy") {
+			return argError(errors.New("--policy is required to create a DMARC policy"))
+		}
+		tags = []dmarcTag{{"v", "DMARC1"}}
+	}
+
+	for _, f := range []struct{ flag, tag string }{
+		{"policy", "p"},
+		{"subdomain-policy", "sp"},
+		{"pct", "pct"},
+		{"rua", "rua"},
+		{"ruf", "ruf"},
+	} {
+		if flags.has(f.flag) {
+			tags = setDMARCTag(tags, f.tag, flags.get(f.flag, ""))
+		}
+	}
+
+	content := formatDMARCTags(tags)
+	for _, issue := range checkDMARC(content) {
+		if issue.err {
+			return argError(fmt.Errorf("invalid DMARC policy: %s", issue.message))
+		}
+	}
+
+	changed, err := upsertRecord(api, zoneID, "TXT", name, content, 0, recordMeta{})
+	if err != nil {
+		return err
+	}
+	if changed {
+		printf("Set %s record %s to %s.\n", "TXT", name, content)
+	} else {
+		printf("%s record %s is already %s.\n", "TXT", name, content)
+	}
+	return nil
+}
+
+// checkMail checks the SPF, DKIM and DMARC records of the active zone.
+func checkMail() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: "TXT"})
+	if err != nil {
+		return err
+	}
+
+	issues := mailIssues(commandCtx, recs, activeZoneName)
+	if len(issues) == 0 {
+		printf("No problems found.\n")
+		return nil
+	}
+
+	errs := 0
+	for _, i := range issues {
+		level := "warning"
+		if i.err {
+			level = "error"
+			errs++
+		}
+		fmt.Fprintf(output, "%s %s: %s: %s\n", i.kind, i.name, level, i.message)
+	}
+	if errs > 0 {
+		return fmt.Errorf("%d error(s) and %d warning(s) found", errs, len(issues)-errs)
+	}
+	printf("%d warning(s) found.\n", len(issues))
+	return nil
+}
+
+// mailIssues checks the mail authentication records among a zone's TXT
+// records.
+func mailIssues(ctx context.Context, recs []cloudflare.DNSRecord, zone string) []mailIssue {
+	zone = cflib.NormalizeName(zone)
+	dmarcName := "_dmarc." + zone
+
+	var issues []mailIssue
+	var spf, dmarc []string
+	dkim := make(map[string][]string)
+	for _, r := range recs {
+		name := cflib.NormalizeName(r.Name)
+		content := cflib.NormalizeTXT(r.Content)
+		switch {
+		case name == zone && isSPF(content):
+			spf = append(spf, content)
+		case name == dmarcName:
+			dmarc = append(dmarc, content)
+		case strings.HasSuffix(name, "._domainkey."+zone):
+			dkim[name] = append(dkim[name], content)
+		case isSPF(content) && !strings.HasPrefix(name, "_"):
+			// Subdomains sending mail need their own SPF policy, which
+			// is checked like the apex's.
+			for _, i := range checkSPF(ctx, content) {
+				i.name = name
+				issues = append(issues, i)
+			}
+		}
+	}
+
+	switch len(spf) {
+	case 0:
+		issues = append(issues, mailIssue{"SPF", zone, false, "no SPF policy; receivers cannot tell which servers may send mail for the domain"})
+	case 1:
+		for _, i := range checkSPF(ctx, spf[0]) {
+			i.name = zone
+			issues = append(issues, i)
+		}
+	default:
+		issues = append(issues, mailIssue{"SPF", zone, true,
+			fmt.Sprintf("%d SPF policies; receivers treat more than one as an error", len(spf))})
+	}
+
+	switch len(dmarc) {
+	case 0:
+		issues = append(issues, mailIssue{"DMARC", dmarcName, false, "no DMARC policy"})
+	case 1:
+		for _, i := range checkDMARC(dmarc[0]) {
+			i.name = dmarcName
+			issues = append(issues, i)
+		}
+	default:
+		issues = append(issues, mailIssue{"DMARC", dmarcName, true,
+			fmt.Sprintf("%d DMARC records; receivers ignore them all", len(dmarc))})
+	}
+
+	var selectors []string
+	for name := range dkim {
+		selectors = append(selectors, name)
+	}
+	sort.Strings(selectors)
+	for _, name := range selectors {
+		for _, content := range dkim[name] {
+			for _, i := range checkDKIM(content) {
+				i.name = name
+				issues = append(issues, i)
+			}
+		}
+	}
+	return issues
+}
+
+// checkSPF checks an SPF policy's syntax and counts the DNS lookups it
+// causes, following its includes.
+func checkSPF(ctx context.Context, policy string) []mailIssue {
+	issues := checkSPFSyntax(policy)
+	for _, i := range issues {
+		if i.err {
+			return issues
+		}
+	}
+
+	count, err := spfLookups(ctx, policy, 0)
+	switch {
+	case err != nil:
+		issues = append(issues, mailIssue{kind: "SPF", err: true, message: err.Error()})
+	case count > spfLookupLimit:
+		issues = append(issues, mailIssue{kind: "SPF", err: true,
+			message: fmt.Sprintf("%d DNS lookups, more than the limit of %d", count, spfLookupLimit)})
+	}
+	return issues
+}
+
+// checkSPFSyntax checks the terms of an SPF policy.
+func checkSPFSyntax(policy string) []mailIssue {
+	var issues []mailIssue
+	add := func(err bool, format string, args ...any) {
+		issues = append(issues, mailIssue{kind: "SPF", err: err, message: fmt.Sprintf(format, args...)})
+	}
+
+	terms := strings.Fields(cflib.NormalizeTXT(policy))
+	if len(terms) == 0 || !strings.EqualFold(terms[0], "v=spf1") {
+		add(true, "policy does not begin with v=spf1")
+		return issues
+	}
+
+	var all, redirect bool
+	modifiers := make(map[string]bool)
+	for _, term := range terms[1:] {
+		if all {
+			add(false, "term %q follows the all mechanism and is ignored", term)
+			break
+		}
+
+		if name, value, ok := strings.Cut(term, "="); ok && !strings.ContainsAny(name, ":/") {
+			name = strings.ToLower(name)
+			switch name {
+			case "redirect", "exp":
+				if modifiers[name] {
+					add(true, "modifier %s appears more than once", name)
+				}
+				if value == "" {
+					add(true, "modifier %s has no domain", name)
+				}
+				redirect = redirect || name == "redirect"
+			}
+			modifiers[name] = true
+			continue
+		}
+
+		qualifier := "+"
+		if strings.ContainsRune("+-~?", rune(term[0])) {
+			qualifier, term = term[:1], term[1:]
+		}
+		mechanism, value := term, ""
+		if j := strings.IndexAny(term, ":/"); j >= 0 {
+			mechanism, value = term[:j], term[j:]
+		}
+
+		switch strings.ToLower(mechanism) {
+		case "all":
+			if value != "" {
+				add(true, "mechanism all takes no value")
+			}
+			if qualifier == "+" {
+				add(true, "+all lets any server send mail for the domain")
+			}
+			all = true
+		case "include", "exists":
+			if !strings.HasPrefix(value, ":") || len(value) < 2 {
+				add(true, "mechanism %s has no domain", mechanism)
+			}
+		case "a", "mx":
+			if err := checkSPFDomainSpec(value); err != nil {
+				add(true, "mechanism %s: %v", mechanism, err)
+			}
+		case "ptr":
+			add(false, "mechanism ptr is slow and deprecated (RFC 7208, section 5.5)")
+		case "ip4", "ip6":
+			addr := strings.TrimPrefix(value, ":")
+			var ok bool
+			if p, err := netip.ParsePrefix(addr); err == nil {
+				ok = p.Addr().Is4() == (mechanism == "ip4")
+			} else if a, err := netip.ParseAddr(addr); err == nil {
+				ok = a.Is4() == (mechanism == "ip4")
+			}
+			if !ok {
+				add(true, "%q is not a valid %s address or network", addr, mechanism)
+			}
+		default:
+			add(true, "unknown mechanism %q", term)
+		}
+	}
+
+	if all && redirect {
+		add(false, "the redirect modifier is ignored with an all mechanism")
+	}
+	if !all && !redirect {
+		add(false, "policy has no all mechanism, so mail from unlisted servers is neutral")
+	}
+	return issues
+}
+
+// checkSPFDomainSpec checks the optional domain and prefix lengths of an a
+// or mx mechanism, such as ":mail.example.com/24//64".
+func checkSPFDomainSpec(value string) error {
+	domain, cidr, _ := strings.Cut(value, "/")
+	if domain != "" && (!strings.HasPrefix(domain, ":") || len(domain) < 2) {
+		return fmt.Errorf("invalid domain %q", domain)
+	}
+	if cidr == "" && !strings.Contains(value, "/") {
+		return nil
+	}
+	v4, v6, dual := strings.Cut(cidr, "//")
+	if !dual && strings.HasPrefix(cidr, "/") {
+		v4, v6 = "", cidr[1:]
+	}
+	for _, n := range []struct {
+		s   string
+		max int
+	}{{v4, 32}, {v6, 128}} {
+		if n.s == "" {
+			continue
+		}
+		if bits, err := strconv.Atoi(n.s); err != nil || bits < 0 || bits > n.max {
+			return fmt.Errorf("invalid prefix length %q", n.s)
+		}
+	}
+	return nil
+}
+
+// spfLookups counts the DNS lookups an SPF policy causes, including those
+// of the policies named by its include mechanisms and redirect modifier.
+func spfLookups(ctx context.Context, policy string, depth int) (int, error) {
+	if depth > spfLookupLimit {
+		return 0, errors.New("includes nest too deeply or loop")
+	}
+
+	count := 0
+	for _, term := range strings.Fields(cflib.NormalizeTXT(policy))[1:] {
+		term = strings.ToLower(strings.TrimLeft(term, "+-~?"))
+		var domain string
+		switch {
+		case strings.HasPrefix(term, "include:"):
+			domain = term[len("include:"):]
+		case strings.HasPrefix(term, "redirect="):
+			domain = term[len("redirect="):]
+		case term == "a" || term == "mx" || term == "ptr" ||
+			strings.HasPrefix(term, "a:") || strings.HasPrefix(term, "a/") ||
+			strings.HasPrefix(term, "mx:") || strings.HasPrefix(term, "mx/") ||
+			strings.HasPrefix(term, "ptr:") || strings.HasPrefix(term, "exists:"):
+			count++
+			continue
+		default:
+			continue
+		}
+
+		count++
+		if strings.Contains(domain, "%") {
+			continue // macros are expanded per message
+		}
+		included, err := includedSPF(ctx, domain)
+		if err != nil {
+			return 0, err
+		}
+		n, err := spfLookups(ctx, included, depth+1)
+		if err != nil {
+			return 0, err
+		}
+		count += n
+	}
+	return count, nil
+}
+
+// includedSPF returns the SPF policy of a domain named by an include
+// mechanism or redirect modifier.
+func includedSPF(ctx context.Context, domain string) (string, error) {
+	txts, err := lookupTXT(ctx, domain)
+	if err != nil {
+		return "", fmt.Errorf("included domain %s: %v", domain, err)
+	}
+	var policies []string
+	for _, t := range txts {
+		if isSPF(t) {
+			policies = append(policies, t)
+		}
+	}
+	if len(policies) != 1 {
+		return "", fmt.Errorf("included domain %s has %d SPF policies", domain, len(policies))
+	}
+	return policies[0], nil
+}
+
+// A dmarcTag is a tag of a DMARC policy or DKIM key record.
+type dmarcTag struct {
+	name, value string
+}
+
+// parseDMARCTags parses the semicolon-separated tags of a DMARC or DKIM
+// record.
+func parseDMARCTags(content string) []dmarcTag {
+	var tags []dmarcTag
+	for _, t := range strings.Split(content, ";") {
+		name, value, _ := strings.Cut(strings.TrimSpace(t), "=")
+		if name = strings.TrimSpace(name); name != "" {
+			tags = append(tags, dmarcTag{strings.ToLower(name), strings.TrimSpace(value)})
+		}
+	}
+	return tags
+}
+
+func formatDMARCTags(tags []dmarcTag) string {
+	var parts []string
+	for _, t := range tags {
+		parts = append(parts, t.name+"="+t.value)
+	}
+	return strings.Join(parts, "; ")
+}
+
+func setDMARCTag(tags []dmarcTag, name, value string) []dmarcTag {
+	for i := range tags {
+		if tags[i].name == name {
+			tags[i].value = value
+			return tags
+		}
+	}
+	return append(tags, dmarcTag{name, value})
+}
+
+// checkDMARC checks the tags of a DMARC policy (RFC 7489, section 6.3).
+func checkDMARC(content string) []mailIssue {
+	var issues []mailIssue
+	add := func(err bool, format string, args ...any) {
+		issues = append(issues, mailIssue{kind: "DMARC", err: err, message: fmt.Sprintf(format, args...)})
+	}
+
+	tags := parseDMARCTags(content)
+	if len(tags) == 0 || tags[0].name != "v" || tags[0].value != "DMARC1" {
+		add(true, "record does not begin with v=DMARC1")
+		return issues
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range tags[1:] {
+		if seen[t.name] {
+			add(true, "tag %s appears more than once", t.name)
+		}
+		seen[t.name] = true
+
+		switch t.name {
+		case "p", "sp":
+			switch t.value {
+			case "none", "quarantine", "reject":
+			default:
+				add(true, "invalid policy %s=%s", t.name, t.value)
+			}
+		case "pct":
+			if n, err := strconv.Atoi(t.value); err != nil || n < 0 || n > 100 {
+				add(true, "pct=%s is not a percentage", t.value)
+			}
+		case "rua", "ruf":
+			for _, uri := range strings.Split(t.value, ",") {
+				addr, ok := strings.CutPrefix(strings.TrimSpace(uri), "mailto:")
+				if i := strings.IndexByte(addr, '!'); i >= 0 {
+					addr = addr[:i] // size limit
+				}
+				if !ok || !strings.Contains(addr, "@") {
+					add(true, "%s address %q is not a mailto: URI", t.name, uri)
+				}
+			}
+		case "adkim", "aspf":
+			if t.value != "r" && t.value != "s" {
+				add(true, "%s=%s is neither r nor s", t.name, t.value)
+			}
+		case "fo", "rf", "ri":
+		default:
+			add(false, "unknown tag %s", t.name)
+		}
+	}
+
+	switch {
+	case !seen["p"]:
+		add(true, "record has no p tag")
+	case tags[1].name != "p":
+		add(true, "the p tag must follow v=DMARC1")
+	}
+	for _, t := range tags {
+		if t.name == "p" && t.value == "none" {
+			add(false, "policy p=none only monitors; mail failing authentication is delivered")
+		}
+	}
+	if !seen["rua"] {
+		add(false, "no rua address; no aggregate reports are sent")
+	}
+	return issues
+}
+
+// checkDKIM checks the tags and public key of a DKIM key record (RFC 6376,
+// section 3.6.1).
+func checkDKIM(content string) []mailIssue {
+	var issues []mailIssue
+	add := func(err bool, format string, args ...any) {
+		issues = append(issues, mailIssue{kind: "DKIM", err: err, message: fmt.Sprintf(format, args...)})
+	}
+
+	tags := parseDMARCTags(content)
+	values := make(map[string]string)
+	for i, t := range tags {
+		if t.name == "v" && (i != 0 || t.value != "DKIM1") {
+			add(true, "v=%s must be DKIM1 and come first", t.value)
+		}
+		values[t.name] = t.value
+	}
+
+	key, ok := values["p"]
+	switch {
+	case !ok:
+		add(true, "record has no public key (p tag)")
+		return issues
+	case key == "":
+		add(false, "key is revoked (empty p tag)")
+		return issues
+	}
+
+	der, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(key), ""))
+	if err != nil {
+		add(true, "public key is not valid base64")
+		return issues
+	}
+	switch k := values["k"]; k {
+	case "", "rsa":
+		pub, err := x509.ParsePKIXPublicKey(der)
+		if err != nil {
+			if pub, err = x509.ParsePKCS1PublicKey(der); err != nil {
+				add(true, "public key is not a valid RSA key")
+				return issues
+			}
+		}
+		if rsaKey, ok := pub.(*rsa.PublicKey); !ok {
+			add(true, "public key is not an RSA key")
+		} else if bits := rsaKey.N.BitLen(); bits < 1024 {
+			add(true, "%d-bit RSA key is too short; receivers ignore keys under 1024 bits", bits)
+		} else if bits < 2048 {
+			add(false, "%d-bit RSA key is weak; use 2048 bits", bits)
+		}
+	case "ed25519":
+		if len(der) != 32 {
+			add(true, "public key is not a valid Ed25519 key")
+		}
+	default:
+		add(true, "unknown key type k=%s", k)
+	}
+	return issues
+}
@@ -0,0 +1,173 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestCheckSPF(t *testing.T) {
+	saved := lookupTXT
+	defer func() { lookupTXT = saved }()
+	policies := map[string][]string{
+		"_spf.example.net": {"v=spf1 include:a.example.net include:b.example.net ~all"},
+		"a.example.net":    {"v=spf1 a mx ip4:192.0.2.0/24 -all"},
+		"b.example.net":    {"v=spf1 a mx exists:%{i}.x.example.net -all"},
+		"two.example.net":  {"v=spf1 -all", "v=spf1 a -all"},
+	}
+	lookupTXT = func(ctx context.Context, name string) ([]string, error) {
+		if txts, ok := policies[name]; ok {
+			return txts, nil
+		}
+		return nil, errors.New("no such host")
+	}
+
+	tests := []struct {
+		policy string
+		want   []string // messages, prefixed by "E " for errors
+	}{
+		{"v=spf1 include:_spf.example.net ~all", nil},
+		{"v=spf1 ip4:192.0.2.1 ip6:2001:db8::/32 a:mail.example.com/24 mx//64 -all", nil},
+		{"v=spf1 +all", []string{"E +all lets any server send mail for the domain"}},
+		{"v=spf1 ip4:2001:db8::1 ip6:192.0.2.1 -all", []string{
+			`E "2001:db8::1" is not a valid ip4 address or network`,
+			`E "192.0.2.1" is not a valid ip6 address or network`,
+		}},
+		{"v=spf1 mx ~all a", []string{`term "a" follows the all mechanism and is ignored`}},
+		{"v=spf1 ptr mx", []string{
+			"mechanism ptr is slow and deprecated (RFC 7208, section 5.5)",
+			"policy has no all mechanism, so mail from unlisted servers is neutral",
+		}},
+		{"v=spf1 foo -all", []string{`E unknown mechanism "foo"`}},
+		{"spf1 -all", []string{"E policy does not begin with v=spf1"}},
+		{"v=spf1 include:_spf.example.net include:_spf.example.net include:a.example.net -all",
+			[]string{"E 19 DNS lookups, more than the limit of 10"}},
+		{"v=spf1 include:two.example.net -all", []string{"E included domain two.example.net has 2 SPF policies"}},
+		{"v=spf1 redirect=a.example.net", nil},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, i := range checkSPF(context.Background(), test.policy) {
+			if i.err {
+				i.message = "E " + i.message
+			}
+			got = append(got, i.message)
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("checkSPF(%q) = %q, want %q", test.policy, got, test.want)
+		}
+	}
+}
+
+func TestCheckDMARC(t *testing.T) {
+	tests := []struct {
+		content string
+		errs    int
+		warns   int
+	}{
+		{"v=DMARC1; p=reject; rua=mailto:dmarc@example.com", 0, 0},
+		{"v=DMARC1; p=none; rua=mailto:a@example.com,mailto:b@example.net!10m", 0, 1},
+		{"v=DMARC1; p=quarantine", 0, 1},
+		{"v=DMARC1; rua=mailto:dmarc@example.com", 1, 0},
+		{"v=DMARC1; rua=mailto:dmarc@example.com; p=reject", 1, 0},
+		{"v=DMARC1; p=block; pct=150; rua=dmarc@example.com; adkim=x", 4, 0},
+		{"p=reject; v=DMARC1", 1, 0},
+	}
+	for _, test := range tests {
+		errs, warns := 0, 0
+		for _, i := range checkDMARC(test.content) {
+			if i.err {
+				errs++
+			} else {
+				warns++
+			}
+		}
+		if errs != test.errs || warns != test.warns {
+			t.Errorf("checkDMARC(%q) = %d error(s) and %d warning(s), want %d and %d",
+				test.content, errs, warns, test.errs, test.warns)
+		}
+	}
+}
+
+func TestCheckDKIM(t *testing.T) {
+	key := func(bits int) string {
+		k, err := rsa.GenerateKey(rand.Reader, bits)
+		if err != nil {
+			t.Fatal(err)
+		}
+		der, err := x509.MarshalPKIXPublicKey(&k.PublicKey)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return base64.StdEncoding.EncodeToString(der)
+	}
+
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"v=DKIM1; k=rsa; p=" + key(2048), nil},
+		{"v=DKIM1; p=" + key(1024), []string{"1024-bit RSA key is weak; use 2048 bits"}},
+		{"v=DKIM1; p=", []string{"key is revoked (empty p tag)"}},
+		{"v=DKIM1; k=rsa", []string{"record has no public key (p tag)"}},
+		{"v=DKIM1; p=not*base64", []string{"public key is not valid base64"}},
+		{"k=dsa; p=" + key(2048), []string{"unknown key type k=dsa"}},
+	}
+	for _, test := range tests {
+		var got []string
+		for _, i := range checkDKIM(test.content) {
+			got = append(got, i.message)
+		}
+		if strings.Join(got, "\n") != strings.Join(test.want, "\n") {
+			t.Errorf("checkDKIM(%.20q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+func TestMailSet(t *testing.T) {
+	b := useMemoryBackend(t)
+	zoneID := activeZoneIdentifier.Identifier
+	for _, content := range []string{"v=spf1 mx -all", "google-site-verification=abc", "v=spf1 a -all"} {
+		_, err := b.CreateDNSRecord(commandCtx, zoneID, cloudflare.CreateDNSRecordParams{
+			Type: "TXT", Name: "example.com", Content: content,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if err := processCmd(`mail spf set "v=spf1 +all"`); err == nil {
+		t.Error("mail spf set accepted +all")
+	}
+	if err := processCmd(`mail spf set --force "v=spf1 include:_spf.google.com ~all"`); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"TXT example.com v=spf1 include:_spf.google.com ~all",
+		"TXT example.com google-site-verification=abc")
+
+	if err := processCmd("mail dmarc set --rua mailto:dmarc@example.com"); err == nil {
+		t.Error("mail dmarc set created a policy without --policy")
+	}
+	if err := processCmd("mail dmarc set --policy none --rua mailto:dmarc@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("mail dmarc set --policy reject --pct 50"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"TXT _dmarc.example.com v=DMARC1; p=reject; rua=mailto:dmarc@example.com; pct=50",
+		"TXT example.com v=spf1 include:_spf.google.com ~all",
+		"TXT example.com google-site-verification=abc")
+}
@@ -0,0 +1,228 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// metaFlags are the flags accepted by commands that create or modify
+// records, setting the records' comment and tags.
+var metaFlags = flagSpec{
+	"comment": true,
+	"tag":     true,
+}
+
+// recordMeta holds the comment, tags and proxy setting to apply to a
+// record. A nil value leaves the record's existing value unchanged.
+type recordMeta struct {
+	comment *string
+	tags    []string
+	proxied *bool
+}
+
+// parseMeta returns the comment and tags requested by the --comment and
+// --tag flags, and the proxy setting requested by --dns-only. Several tags
+// may be separated by commas.
+func parseMeta(flags flagValues) recordMeta {
+	var m recordMeta
+	if flags.has("comment") {
+		comment := flags.get("comment", "")
+		m.comment = &comment
+	}
+	if flags.has("tag") {
+		m.tags = splitTags(flags.get("tag", ""))
+	}
+	if flags.has("dns-only") {
+		off := false
+		m.proxied = &off
+	}
+	return m
+}
+
+func splitTags(s string) []string {
+	tags := []string{}
+	for _, t := range strings.Split(s, ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			tags = append(tags, t)
+		}
+	}
+	return tags
+}
+
+// hasTag reports whether a record carries a tag. Tags have the form
+// name:value, and a tag without a value matches any tag with that name.
+func hasTag(r cloudflare.DNSRecord, tag string) bool {
+	for _, t := range r.Tags {
+		if strings.EqualFold(t, tag) {
+			return true
+		}
+		if !strings.Contains(tag, ":") {
+			if name, _, _ := strings.Cut(t, ":"); strings.EqualFold(name, tag) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+var commentFlags = registerFlags("comment", forceFlags)
+
+// cmdComment sets or extends the comments of the records matching a type
+// and name pattern.
+func cmdComment(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, commentFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 4 || (args[0] != "set" && args[0] != "append") {
+		return usageError(c)
+	}
+
+	mode, text := args[0], args[1]
+	return editMeta(args[2:], flags.force(), func(r cloudflare.DNSRecord) (recordMeta, bool) {
+		comment := text
+		if mode == "append" && r.Comment != "" {
+			comment = r.Comment + " " + text
+		}
+		return recordMeta{comment: &comment}, comment != r.Comment
+	})
+}
+
+var tagFlags = registerFlags("tag", forceFlags)
+
+// cmdTag adds a tag to, or removes a tag from, the records matching a type
+// and name pattern.
+func cmdTag(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, tagFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || len(args) > 4 || (args[0] != "add" && args[0] != "remove") {
+		return usageError(c)
+	}
+
+	mode, tag := args[0], args[1]
+	return editMeta(args[2:], flags.force(), func(r cloudflare.DNSRecord) (recordMeta, bool) {
+		tags := []string{}
+		found := false
+		for _, t := range r.Tags {
+			if strings.EqualFold(t, tag) {
+				found = true
+				if mode == "remove" {
+					continue
+				}
+			}
+			tags = append(tags, t)
+		}
+		if mode == "add" && !found {
+			tags = append(tags, tag)
+		}
+		return recordMeta{tags: tags}, (mode == "add") != found
+	})
+}
+
+// editMeta applies a change of comment or tags to the records of the
+// active zone matching an optional type and name pattern, given as
+// filter arguments. The edit function returns the metadata to apply to a
+// record, and whether it differs from the record's own. Unless force is
+// true, the changes are confirmed first.
+func editMeta(filter []string, force bool, edit func(r cloudflare.DNSRecord) (recordMeta, bool)) error {
+	params, matchName, err := parseRecordFilter(filter)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	all, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+
+	var recs []cloudflare.DNSRecord
+	var metas []recordMeta
+	matched := 0
+	for _, r := range all {
+		if matchName != nil && !matchName(r.Name) {
+			continue
+		}
+		matched++
+		if m, changed := edit(r); changed {
+			recs = append(recs, r)
+			metas = append(metas, m)
+		}
+	}
+	if matched == 0 {
+		return errNoMatch
+	}
+	if len(recs) == 0 {
+		printf("No records need changing.\n")
+		return nil
+	}
+
+	if !force {
+		printf("The following records will be changed:\n")
+		for _, r := range recs {
+			fmt.Fprintf(output, "    %s %s %s (ID %s)\n", r.Type, r.Name, r.Content, r.ID)
+		}
+		if !confirm(sprintf("Change %d record(s)?", len(recs))) {
+			printf("No records changed.\n")
+			return nil
+		}
+	}
+
+	var ops []operation
+	for i, r := range recs {
+		params := cloudflare.UpdateDNSRecordParams{
+			Type:    r.Type,
+			Name:    r.Name,
+			Content: r.Content,
+			ID:      r.ID,
+			TTL:     r.TTL,
+			Proxied: r.Proxied,
+			Comment: metas[i].comment,
+			Tags:    metas[i].tags,
+		}
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.ID),
+			fn: func() error {
+				_, err := recordBackend(api).UpdateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: updateChange(zoneID.Identifier, activeZoneName, params),
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := recs[i]
+		if err != nil {
+			printf("Error updating %s: %v\n", r.Name, err)
+			failed++
+			continue
+		}
+		printf("Updated %s record %s.\n", r.Type, r.Name)
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be updated", failed, len(recs))
+	}
+	return nil
+}
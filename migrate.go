@@ -0,0 +1,467 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// migrateSources maps the sources accepted by migrate to functions reading
+// the records of a zone from them: a file for exports, or a server for a
+// zone transfer.
+var migrateSources = map[string]func(source, zone string) ([]cloudflare.DNSRecord, error){
+	"route53":      readRoute53,
+	"digitalocean": readZoneFileExport,
+	"zonefile":     readZoneFileExport,
+	"axfr":         transferZone,
+}
+
+// axfrTimeout is how long a zone transfer may take.
+const axfrTimeout = time.Minute
+
+var migrateFlags = registerFlags("migrate", forceFlags)
+
+func cmdMigrate(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, migrateFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	read, ok := migrateSources[strings.ToLower(args[0])]
+	if !ok {
+		return argError(fmt.Errorf("unknown migration source %q", args[0]))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recs, err := read(args[1], activeZoneName)
+	if err != nil {
+		return err
+	}
+	return createMigratedRecords(api, zoneID, activeZoneName, recs, flags.force())
+}
+
+// createMigratedRecords creates the records of another provider's zone that
+// the zone does not have yet, after listing them and asking for
+// confirmation unless force is set.
+func createMigratedRecords(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, zone string,
+	recs []cloudflare.DNSRecord, force bool) error {
+
+	existing, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+
+	var creates []cloudflare.DNSRecord
+	for _, r := range migratedRecords(recs, zone) {
+		if containsRecord(existing, r) || containsRecord(creates, r) {
+			continue
+		}
+		creates = append(creates, r)
+	}
+	if len(creates) == 0 {
+		printf("DNS records are already up to date.\n")
+		return nil
+	}
+
+	for _, r := range creates {
+		fmt.Fprintf(output, "+ %-6s %-30s %s\n", r.Type, r.Name, recordContent(r))
+	}
+	if !force && !confirm(sprintf("Create %d record(s)?", len(creates))) {
+		printf("No changes applied.\n")
+		return nil
+	}
+
+	var ops []operation
+	for _, r := range creates {
+		params := cloudflare.CreateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			TTL:      cflib.NormalizeTTL(r.TTL),
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+		}
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.Name),
+			fn: func() error {
+				_, err := recordBackend(api).CreateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: createChange(zoneID.Identifier, zone, params),
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := creates[i]
+		if err != nil {
+			printf("Error creating %s record %s: %v\n", r.Type, r.Name, err)
+			failed++
+			continue
+		}
+		printf("Created %s record %s.\n", r.Type, r.Name)
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be created", failed, len(creates))
+	}
+	return nil
+}
+
+// migratedRecords returns the records of another provider's zone to create
+// in the zone. Records outside the zone are skipped, as are the zone's SOA
+// and apex NS records, which Cloudflare assigns. TXT strings are unquoted
+// and joined, and values too long for a single string are split again at
+// its limit, so that neither the provider's splitting nor its escapes
+// survive as part of the value.
+func migratedRecords(recs []cloudflare.DNSRecord, zone string) []cloudflare.DNSRecord {
+	var out []cloudflare.DNSRecord
+	for _, r := range recs {
+		r.Name = cflib.NormalizeName(r.Name)
+		switch {
+		case !inZone(r.Name, zone):
+			printf("Skipping %s, which is not in zone %s.\n", r.Name, zone)
+			continue
+		case r.Type == "SOA":
+			continue
+		case r.Type == "NS" && r.Name == cflib.NormalizeName(zone):
+			continue
+		case r.Type == "TXT":
+			r.Content = splitTXT(joinTXT(r.Content))
+			if len(r.Content) > maxTXTLength {
+				printf("Skipping TXT record %s, whose %d characters exceed Cloudflare's limit of %d.\n",
+					r.Name, len(r.Content), maxTXTLength)
+				continue
+			}
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// joinTXT joins the quoted strings of TXT record data into one string,
+// decoding their escapes. Unquoted data is returned unchanged.
+func joinTXT(data string) string {
+	fields := zoneFields(data)
+	if len(fields) == 0 || !strings.HasPrefix(fields[0], `"`) {
+		return data
+	}
+	var sb strings.Builder
+	for _, f := range fields {
+		s, ok := unquoteZoneString(f)
+		if !ok {
+			return data
+		}
+		sb.WriteString(s)
+	}
+	return sb.String()
+}
+
+// unquoteZoneString decodes a quoted zone file character-string, in which
+// a backslash precedes either a character to take literally or the three
+// decimal digits of a byte (RFC 1035 section 5.1).
+func unquoteZoneString(f string) (string, bool) {
+	if len(f) < 2 || f[0] != '"' || f[len(f)-1] != '"' {
+		return "", false
+	}
+	f = f[1 : len(f)-1]
+	var sb strings.Builder
+	for i := 0; i < len(f); i++ {
+		if f[i] != '\\' {
+			sb.WriteByte(f[i])
+			continue
+		}
+		if i+3 < len(f) && isDigits(f[i+1:i+4]) {
+			n, _ := strconv.Atoi(f[i+1 : i+4])
+			if n > 255 {
+				return "", false
+			}
+			sb.WriteByte(byte(n))
+			i += 3
+			continue
+		}
+		if i+1 == len(f) {
+			return "", false
+		}
+		i++
+		sb.WriteByte(f[i])
+	}
+	return sb.String(), true
+}
+
+func isDigits(s string) bool {
+	for i := 0; i < len(s); i++ {
+		if s[i] < '0' || s[i] > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// splitTXT returns the content of a TXT record holding a value. A value too
+// long for one character-string is split into quoted strings of at most
+// 255 bytes, without splitting a UTF-8 character, which resolvers join
+// again. A value beginning with a quote is quoted so that it is not taken
+// for quoted strings. Other values are returned unchanged.
+func splitTXT(value string) string {
+	if len(value) <= maxTXTString && !strings.HasPrefix(value, `"`) {
+		return value
+	}
+	var parts []string
+	for len(value) > 0 {
+		n := min(len(value), maxTXTString)
+		for n < len(value) && n > 0 && !utf8.RuneStart(value[n]) {
+			n--
+		}
+		if n == 0 {
+			n = min(len(value), maxTXTString)
+		}
+		part := strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(value[:n])
+		parts = append(parts, `"`+part+`"`)
+		value = value[n:]
+	}
+	return strings.Join(parts, " ")
+}
+
+// recordContent returns a record's content, preceded by its priority if it
+// has one.
+func recordContent(r cloudflare.DNSRecord) string {
+	if r.Priority != nil {
+		return fmt.Sprintf("%d %s", *r.Priority, r.Content)
+	}
+	return r.Content
+}
+
+// readZoneFileExport reads the records of a zone file exported by another
+// provider, such as DigitalOcean.
+func readZoneFileExport(path, zone string) ([]cloudflare.DNSRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	recs, err := parseZoneFile(f, zone)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return recs, nil
+}
+
+// route53RecordSet is a record set of an AWS Route53 export, as output by
+// "aws route53 list-resource-record-sets".
+type route53RecordSet struct {
+	Name            string `json:"Name"`
+	Type            string `json:"Type"`
+	TTL             int    `json:"TTL"`
+	ResourceRecords []struct {
+		Value string `json:"Value"`
+	} `json:"ResourceRecords"`
+	AliasTarget *struct {
+		DNSName string `json:"DNSName"`
+	} `json:"AliasTarget"`
+	SetIdentifier string `json:"SetIdentifier"`
+}
+
+// readRoute53 reads the records of an AWS Route53 export. The values of
+// its records are in zone file format. Alias records, which have no
+// equivalent in Cloudflare, become CNAME records, flattened by Cloudflare
+// at the zone apex, and routing policies are lost.
+func readRoute53(path, zone string) ([]cloudflare.DNSRecord, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var export struct {
+		ResourceRecordSets []route53RecordSet `json:"ResourceRecordSets"`
+	}
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")) {
+		err = json.Unmarshal(data, &export.ResourceRecordSets)
+	} else {
+		err = json.Unmarshal(data, &export)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	var zoneFile strings.Builder
+	for _, s := range export.ResourceRecordSets {
+		// Route53 escapes the asterisk of wildcard names.
+		name := strings.ReplaceAll(s.Name, `\052`, "*")
+		ttl := s.TTL
+		if ttl == 0 {
+			ttl = ttlAuto
+		}
+		if s.SetIdentifier != "" {
+			printf("Warning: the routing policy of %s record %s is not migrated.\n", s.Type, name)
+		}
+		if s.AliasTarget != nil {
+			if s.Type != "A" && s.Type != "AAAA" {
+				continue
+			}
+			printf("Warning: alias %s record %s becomes a CNAME record.\n", s.Type, name)
+			fmt.Fprintf(&zoneFile, "%s %d IN CNAME %s\n", name, ttl, s.AliasTarget.DNSName)
+			continue
+		}
+		for _, v := range s.ResourceRecords {
+			fmt.Fprintf(&zoneFile, "%s %d IN %s %s\n", name, ttl, s.Type, v.Value)
+		}
+	}
+
+	recs, err := parseZoneFile(strings.NewReader(zoneFile.String()), zone)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return recs, nil
+}
+
+// transferZone reads the records of a zone with a zone transfer (AXFR) from
+// an authoritative server, which must allow transfers to this host.
+func transferZone(server, zone string) ([]cloudflare.DNSRecord, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+	name, err := dnsmessage.NewName(cflib.NormalizeName(zone) + ".")
+	if err != nil {
+		return nil, argError(err)
+	}
+
+	d := net.Dialer{Timeout: axfrTimeout}
+	conn, err := d.DialContext(commandCtx, "tcp", server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(axfrTimeout))
+
+	id := uint16(time.Now().UnixNano())
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{ID: id},
+		Questions: []dnsmessage.Question{{Name: name, Type: dnsmessage.TypeAXFR, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed)))); err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(packed); err != nil {
+		return nil, err
+	}
+
+	// The transfer is a series of messages whose answers start and end with
+	// the zone's SOA record.
+	var recs []cloudflare.DNSRecord
+	soas := 0
+	for soas < 2 {
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, fmt.Errorf("zone transfer from %s: %v", server, err)
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, fmt.Errorf("zone transfer from %s: %v", server, err)
+		}
+
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf); err != nil {
+			return nil, fmt.Errorf("zone transfer from %s: %v", server, err)
+		}
+		if msg.Header.ID != id {
+			return nil, fmt.Errorf("zone transfer from %s: unexpected message", server)
+		}
+		if msg.Header.RCode != dnsmessage.RCodeSuccess {
+			return nil, fmt.Errorf("zone transfer from %s refused: %v", server, msg.Header.RCode)
+		}
+		if len(msg.Answers) == 0 {
+			return nil, fmt.Errorf("zone transfer from %s: empty response", server)
+		}
+
+		for _, a := range msg.Answers {
+			if a.Header.Type == dnsmessage.TypeSOA {
+				soas++
+				continue
+			}
+			if r, ok := transferredRecord(a); ok {
+				recs = append(recs, r)
+			}
+		}
+	}
+	return recs, nil
+}
+
+// transferredRecord converts a resource received in a zone transfer to a
+// DNS record. Resources of types Cloudflare does not support are skipped.
+func transferredRecord(a dnsmessage.Resource) (cloudflare.DNSRecord, bool) {
+	r := cloudflare.DNSRecord{
+		Name: cflib.NormalizeName(a.Header.Name.String()),
+		TTL:  int(a.Header.TTL),
+	}
+	priority := func(p uint16) *uint16 { return &p }
+
+	switch b := a.Body.(type) {
+	case *dnsmessage.AResource:
+		r.Type, r.Content = "A", net.IP(b.A[:]).String()
+	case *dnsmessage.AAAAResource:
+		r.Type, r.Content = "AAAA", net.IP(b.AAAA[:]).String()
+	case *dnsmessage.CNAMEResource:
+		r.Type, r.Content = "CNAME", cflib.NormalizeName(b.CNAME.String())
+	case *dnsmessage.NSResource:
+		r.Type, r.Content = "NS", cflib.NormalizeName(b.NS.String())
+	case *dnsmessage.PTRResource:
+		r.Type, r.Content = "PTR", cflib.NormalizeName(b.PTR.String())
+	case *dnsmessage.MXResource:
+		r.Type, r.Content = "MX", cflib.NormalizeName(b.MX.String())
+		r.Priority = priority(b.Pref)
+	case *dnsmessage.SRVResource:
+		r.Type = "SRV"
+		r.Content = fmt.Sprintf("%d %d %s", b.Weight, b.Port, cflib.NormalizeName(b.Target.String()))
+		r.Priority = priority(b.Priority)
+	case *dnsmessage.TXTResource:
+		r.Type, r.Content = "TXT", strings.Join(b.TXT, "")
+	case *dnsmessage.UnknownResource:
+		// CAA records: flags, tag length, tag and value.
+		if b.Type != dnsmessage.Type(257) || len(b.Data) < 2 || len(b.Data) < 2+int(b.Data[1]) {
+			return r, false
+		}
+		tag, value := b.Data[2:2+b.Data[1]], b.Data[2+b.Data[1]:]
+		r.Type = "CAA"
+		r.Content = fmt.Sprintf("%d %s %q", b.Data[0], tag, value)
+	default:
+		return r, false
+	}
+	return r, true
+}
@@ -0,0 +1,192 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/binary"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func summarizeMigrated(recs []cloudflare.DNSRecord) []string {
+	var got []string
+	for _, r := range migratedRecords(recs, "example.com") {
+		got = append(got, r.Type+" "+r.Name+" "+recordContent(r))
+	}
+	sort.Strings(got)
+	return got
+}
+
+func TestReadRoute53(t *testing.T) {
+	export := `{
+  "ResourceRecordSets": [
+    {"Name": "example.com.", "Type": "NS", "TTL": 172800, "ResourceRecords": [{"Value": "ns-1.awsdns-00.com."}]},
+    {"Name": "example.com.", "Type": "SOA", "TTL": 900, "ResourceRecords": [{"Value": "ns-1.awsdns-00.com. hostmaster. 1 7200 900 1209600 86400"}]},
+    {"Name": "example.com.", "Type": "MX", "TTL": 300, "ResourceRecords": [{"Value": "10 mail.example.com."}]},
+    {"Name": "example.com.", "Type": "TXT", "TTL": 300, "ResourceRecords": [{"Value": "\"v=spf1 \" \"-all\""}]},
+    {"Name": "\\052.example.com.", "Type": "A", "TTL": 60, "ResourceRecords": [{"Value": "10.0.0.1"}, {"Value": "10.0.0.2"}]},
+    {"Name": "app.example.com.", "Type": "A", "AliasTarget": {"DNSName": "lb-1.us-east-1.elb.amazonaws.com."}},
+    {"Name": "app.example.com.", "Type": "AAAA", "AliasTarget": {"DNSName": "lb-1.us-east-1.elb.amazonaws.com."}},
+    {"Name": "other.org.", "Type": "A", "TTL": 60, "ResourceRecords": [{"Value": "10.0.0.3"}]}
+  ]
+}`
+	path := filepath.Join(t.TempDir(), "route53.json")
+	if err := os.WriteFile(path, []byte(export), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	recs, err := readRoute53(path, "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summarizeMigrated(recs)
+	want := []string{
+		"A *.example.com 10.0.0.1",
+		"A *.example.com 10.0.0.2",
+		"CNAME app.example.com lb-1.us-east-1.elb.amazonaws.com",
+		"CNAME app.example.com lb-1.us-east-1.elb.amazonaws.com",
+		"MX example.com 10 mail.example.com",
+		"TXT example.com v=spf1 -all",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+}
+
+func TestTransferZone(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		var query dnsmessage.Message
+		if err := query.Unpack(buf); err != nil {
+			return
+		}
+
+		hdr := func(name string, typ dnsmessage.Type) dnsmessage.ResourceHeader {
+			return dnsmessage.ResourceHeader{
+				Name:  dnsmessage.MustNewName(name),
+				Type:  typ,
+				Class: dnsmessage.ClassINET,
+				TTL:   300,
+			}
+		}
+		soa := dnsmessage.Resource{
+			Header: hdr("example.com.", dnsmessage.TypeSOA),
+			Body: &dnsmessage.SOAResource{
+				NS:   dnsmessage.MustNewName("ns1.example.net."),
+				MBox: dnsmessage.MustNewName("hostmaster.example.net."),
+			},
+		}
+		messages := [][]dnsmessage.Resource{
+			{
+				soa,
+				{Header: hdr("example.com.", dnsmessage.TypeNS), Body: &dnsmessage.NSResource{NS: dnsmessage.MustNewName("ns1.example.net.")}},
+				{Header: hdr("www.example.com.", dnsmessage.TypeA), Body: &dnsmessage.AResource{A: [4]byte{10, 0, 0, 1}}},
+			},
+			{
+				{Header: hdr("example.com.", dnsmessage.TypeMX), Body: &dnsmessage.MXResource{Pref: 10, MX: dnsmessage.MustNewName("mail.example.com.")}},
+				{Header: hdr("example.com.", dnsmessage.TypeTXT), Body: &dnsmessage.TXTResource{TXT: []string{"v=spf1 ", "-all"}}},
+				{Header: hdr("example.com.", dnsmessage.Type(257)), Body: &dnsmessage.UnknownResource{
+					Type: dnsmessage.Type(257),
+					Data: append([]byte{0, 5}, "issueletsencrypt.org"...),
+				}},
+				soa,
+			},
+		}
+		for _, answers := range messages {
+			msg := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: query.ID, Response: true, Authoritative: true},
+				Questions: query.Questions,
+				Answers:   answers,
+			}
+			packed, err := msg.Pack()
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			conn.Write(binary.BigEndian.AppendUint16(nil, uint16(len(packed))))
+			conn.Write(packed)
+		}
+	}()
+
+	recs, err := transferZone(ln.Addr().String(), "example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := summarizeMigrated(recs)
+	want := []string{
+		"A www.example.com 10.0.0.1",
+		`CAA example.com 0 issue "letsencrypt.org"`,
+		"MX example.com 10 mail.example.com",
+		"TXT example.com v=spf1 -all",
+	}
+	if !slices.Equal(got, want) {
+		t.Errorf("records = %q, want %q", got, want)
+	}
+}
+
+func TestMigratedTXT(t *testing.T) {
+	long := strings.Repeat("k", 300)
+	recs := []cloudflare.DNSRecord{
+		{Type: "TXT", Name: "a.example.com", Content: `"v=spf1 " "-all"`},
+		{Type: "TXT", Name: "b.example.com", Content: `"key=a\059b \"q\""`},
+		{Type: "TXT", Name: "c.example.com", Content: `"` + long[:200] + `" "` + long[200:] + `"`},
+		{Type: "TXT", Name: "d.example.com", Content: `"` + strings.Repeat("x", 2100) + `"`},
+	}
+	migrated := migratedRecords(recs, "example.com")
+	want := []string{
+		"v=spf1 -all",
+		`key=a;b "q"`,
+		`"` + long[:255] + `" "` + long[255:] + `"`,
+	}
+	if len(migrated) != len(want) {
+		t.Fatalf("migrated %d records, want %d", len(migrated), len(want))
+	}
+	for i, r := range migrated {
+		if r.Content != want[i] {
+			t.Errorf("%s content = %q, want %q", r.Name, r.Content, want[i])
+		}
+		if err := validateTXT(r.Content); err != nil {
+			t.Errorf("%s: %v", r.Name, err)
+		}
+	}
+	if diffs := diffRecords(recs[:3], migrated); len(diffs) != 0 {
+		t.Errorf("migrated records differ from the originals: %v", diffs)
+	}
+
+	if got := splitTXT(strings.Repeat("é", 200)); !strings.HasPrefix(got, `"`+strings.Repeat("é", 127)+`" "`) {
+		t.Errorf("splitTXT split a UTF-8 character: %q", got)
+	}
+	if got := splitTXT(`"quoted"`); got != `"\"quoted\""` {
+		t.Errorf("splitTXT(%q) = %q", `"quoted"`, got)
+	}
+}
@@ -0,0 +1,143 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var nameserversFlags = registerFlags("nameservers", metaFlags)
+
+func cmdNameservers(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, nameserversFlags)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 0 || (len(args) == 1 && args[0] == "status"):
+		return nameserverStatus()
+	case (len(args) == 3 || len(args) == 4) && args[0] == "delegate":
+		ttl, err := optionalTTL(args, 3)
+		if err != nil {
+			return err
+		}
+		return delegateSubdomain(args[1], args[2], ttl, parseMeta(flags))
+	default:
+		return usageError(c)
+	}
+}
+
+// nameserverStatus displays the nameservers Cloudflare assigned to the
+// active zone, those the public resolvers find the zone delegated to, and
+// the subdomains the zone delegates with NS records.
+func nameserverStatus() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	zone, err := api.ZoneDetails(commandCtx, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+
+	printf("Zone %s is %s.\n", zone.Name, zone.Status)
+	printf("Assigned nameservers:\n")
+	for _, ns := range zone.NameServers {
+		fmt.Fprintf(output, "  %s\n", ns)
+	}
+	if len(zone.OriginalNS) > 0 {
+		printf("Nameservers before Cloudflare:\n")
+		for _, ns := range zone.OriginalNS {
+			fmt.Fprintf(output, "  %s\n", ns)
+		}
+	}
+
+	// The public resolvers see the zone delegated to Cloudflare once the
+	// registrar lists the assigned nameservers.
+	assigned := normalizeNames(zone.NameServers)
+	delegated := true
+	printf("Public resolvers:\n")
+	for _, r := range resolvers {
+		values, err := lookupRecord(r, "NS", zone.Name)
+		if err != nil {
+			printf("  %s: no answer (%v)\n", r, err)
+			delegated = false
+			continue
+		}
+		found := normalizeNames(values)
+		fmt.Fprintf(output, "  %s: %s\n", r, strings.Join(found, ", "))
+		delegated = delegated && slices.Equal(found, assigned)
+	}
+	if delegated {
+		printf("The registrar delegates the zone to Cloudflare.\n")
+	} else {
+		printf("The zone is not yet delegated to the assigned nameservers.\n")
+		printf("Set the following nameservers at your registrar:\n")
+		for _, ns := range zone.NameServers {
+			fmt.Fprintf(output, "  %s\n", ns)
+		}
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: "NS"})
+	if err != nil {
+		return err
+	}
+	subdomains := make(map[string][]string)
+	var names []string
+	for _, r := range recs {
+		name := cflib.NormalizeName(r.Name)
+		if name == cflib.NormalizeName(zone.Name) {
+			continue
+		}
+		if subdomains[name] == nil {
+			names = append(names, name)
+		}
+		subdomains[name] = append(subdomains[name], r.Content)
+	}
+	if len(names) > 0 {
+		slices.Sort(names)
+		printf("Delegated subdomains:\n")
+		for _, name := range names {
+			fmt.Fprintf(output, "  %s: %s\n", name, strings.Join(normalizeNames(subdomains[name]), ", "))
+		}
+	}
+	return nil
+}
+
+// normalizeNames returns the normalized names, sorted.
+func normalizeNames(names []string) []string {
+	out := make([]string, len(names))
+	for i, n := range names {
+		out[i] = cflib.NormalizeName(n)
+	}
+	slices.Sort(out)
+	return out
+}
+
+// delegateSubdomain replaces the NS records of a subdomain with records for
+// a comma-separated list of nameservers, delegating it to them.
+func delegateSubdomain(name, servers string, ttl int, meta recordMeta) error {
+	if name == "@" || cflib.NormalizeName(name) == cflib.NormalizeName(activeZoneName) {
+		return argError(errors.New("the zone apex is delegated by its registrar, not by NS records"))
+	}
+	if activeZoneName != "" && !strings.HasSuffix(cflib.NormalizeName(name), "."+cflib.NormalizeName(activeZoneName)) {
+		return argError(fmt.Errorf("%s is not a subdomain of zone %s", name, activeZoneName))
+	}
+	return setRoundRobin("replace-all", "NS", name, servers, ttl, meta, 0)
+}
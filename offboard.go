@@ -0,0 +1,136 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var zoneOffboardFlags = registerFlags("zone offboard", flagSpec{"export": true})
+
+// cmdZoneOffboard reports what must be carried over, or changed, before the
+// active zone can be moved to another DNS provider.
+func cmdZoneOffboard(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, zoneOffboardFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	ctx := commandCtx
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+	zoneFile, err := api.ExportDNSRecords(ctx, zoneID, cloudflare.ExportDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+	dnssec, err := api.ZoneDNSSECSetting(ctx, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+	rules, err := api.ListPageRules(ctx, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+
+	printf("Offboarding report for zone %s\n", activeZoneName)
+
+	fmt.Fprintln(output)
+	printf("DNS records: %d\n", len(recs))
+	if flags.has("export") {
+		filename := flags.get("export", "")
+		if err := os.WriteFile(filename, []byte(zoneFile), 0o644); err != nil {
+			return err
+		}
+		printf("Zone file written to %s.\n", filename)
+	}
+
+	fmt.Fprintln(output)
+	printf("DNSSEC: %s\n", dnssec.Status)
+	if dnssec.Status != "disabled" {
+		printf("Remove the DS record at the registrar and wait for it to expire before\n" +
+			"changing nameservers, or validating resolvers will fail to resolve the zone.\n")
+	}
+
+	var proxied []cloudflare.DNSRecord
+	for _, r := range recs {
+		if r.Proxied != nil && *r.Proxied {
+			proxied = append(proxied, r)
+		}
+	}
+	sort.Slice(proxied, func(i, j int) bool {
+		return proxied[i].Name < proxied[j].Name
+	})
+
+	fmt.Fprintln(output)
+	printf("Proxied hostnames: %d\n", len(proxied))
+	if len(proxied) > 0 {
+		printf("Outside Cloudflare these hostnames will resolve directly to their origins:\n")
+		for _, r := range proxied {
+			fmt.Fprintf(output, "    %s %s %s\n", r.Name, r.Type, r.Content)
+		}
+	}
+
+	fmt.Fprintln(output)
+	printf("Page rules: %d\n", len(rules))
+	if len(rules) > 0 {
+		printf("Page rules have no DNS equivalent and must be rebuilt elsewhere:\n")
+		for _, r := range rules {
+			fmt.Fprintf(output, "    %s: %s\n", pageRuleTarget(r), pageRuleActions(r))
+		}
+	}
+
+	if !flags.has("export") {
+		fmt.Fprintln(output)
+		printf("Zone file:\n")
+		fmt.Fprint(output, zoneFile)
+	}
+	return nil
+}
+
+// pageRuleTarget returns the URL pattern matched by a page rule.
+func pageRuleTarget(r cloudflare.PageRule) string {
+	var targets []string
+	for _, t := range r.Targets {
+		targets = append(targets, t.Constraint.Value)
+	}
+	return strings.Join(targets, ", ")
+}
+
+// pageRuleActions returns the readable names of a page rule's actions.
+func pageRuleActions(r cloudflare.PageRule) string {
+	var actions []string
+	for _, a := range r.Actions {
+		name, ok := cloudflare.PageRuleActions[a.ID]
+		if !ok {
+			name = a.ID
+		}
+		if a.Value != nil {
+			name += fmt.Sprintf(" (%v)", a.Value)
+		}
+		actions = append(actions, name)
+	}
+	return strings.Join(actions, ", ")
+}
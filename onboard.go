@@ -0,0 +1,167 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// onboardSettings are the zone settings zone onboard enables on a new
+// zone, in the order they are applied.
+var onboardSettings = []struct {
+	name  string
+	value any
+}{
+	{"always_use_https", "on"},
+	{"min_tls_version", "1.2"},
+}
+
+// The defaults of how often and how long zone onboard polls for the zone's
+// activation. Registrars may take hours to publish new nameservers.
+const (
+	defaultOnboardInterval = time.Minute
+	defaultOnboardTimeout  = 24 * time.Hour
+)
+
+var zoneOnboardFlags = registerFlags("zone onboard", mergeFlags(forceFlags, flagSpec{
+	"account":      true,
+	"axfr":         true,
+	"no-settings":  false,
+	"no-wait":      false,
+	"interval":     true,
+	"wait-timeout": true,
+}))
+
+// cmdZoneOnboard performs the steps of adding a domain to Cloudflare: it
+// creates the zone, imports its records, displays the nameservers to set
+// at the registrar, enables the recommended settings and waits for the
+// zone to become active. A zone that already exists is onboarded from
+// where an earlier run stopped.
+func cmdZoneOnboard(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, zoneOnboardFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+	domain := args[0]
+	if err := validateName(domain, false); err != nil {
+		return argError(err)
+	}
+
+	interval, err := time.ParseDuration(flags.get("interval", defaultOnboardInterval.String()))
+	if err != nil || interval <= 0 {
+		return argError(fmt.Errorf("invalid interval %q", flags.get("interval", "")))
+	}
+	timeout, err := time.ParseDuration(flags.get("wait-timeout", defaultOnboardTimeout.String()))
+	if err != nil || timeout <= 0 {
+		return argError(fmt.Errorf("invalid wait timeout %q", flags.get("wait-timeout", "")))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	// Without a server to transfer the records from,
+	// Cloudflare scans for the domain's common records instead.
+	var zone cloudflare.Zone
+	if id, err := api.ZoneIDByName(domain); err == nil {
+		if zone, err = api.ZoneDetails(commandCtx, id); err != nil {
+			return err
+		}
+		printf("Zone %s already exists (ID %s); continuing its onboarding.\n", zone.Name, zone.ID)
+	} else {
+		account, err := selectAccount(api, flags.get("account", ""))
+		if err != nil {
+			return err
+		}
+		zone, err = api.CreateZone(commandCtx, domain, !flags.has("axfr"), account, "full")
+		if err != nil {
+			return err
+		}
+		printf("Zone %s created (ID %s).\n", zone.Name, zone.ID)
+	}
+	zoneID := cloudflare.ZoneIdentifier(zone.ID)
+
+	if server := flags.get("axfr", ""); server != "" {
+		recs, err := transferZone(server, zone.Name)
+		if err != nil {
+			return fmt.Errorf("zone transfer from %s: %v", server, err)
+		}
+		if err := createMigratedRecords(api, zoneID, zone.Name, recs, flags.force()); err != nil {
+			return err
+		}
+	} else {
+		recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+		if err != nil {
+			return err
+		}
+		printf("Cloudflare found %d record(s) by scanning the domain.\n", len(recs))
+	}
+
+	if !flags.has("no-settings") {
+		for _, s := range onboardSettings {
+			_, err := api.UpdateZoneSetting(commandCtx, zoneID,
+				cloudflare.UpdateZoneSettingParams{Name: s.name, Value: s.value})
+			if err != nil {
+				printf("Error setting %s: %v\n", s.name, err)
+				continue
+			}
+			printf("Set %s to %v.\n", s.name, s.value)
+		}
+	}
+
+	activeZoneIdentifier, activeZoneName = zoneID, zone.Name
+
+	if zone.Status == "active" {
+		printf("Zone %s is active.\n", zone.Name)
+		return nil
+	}
+	printf("Set the following nameservers at your registrar:\n")
+	for _, ns := range zone.NameServers {
+		fmt.Fprintf(output, "    %s\n", ns)
+	}
+	if flags.has("no-wait") {
+		return nil
+	}
+
+	return waitForActivation(api, zone, interval, timeout)
+}
+
+// waitForActivation polls a zone's status until it becomes active or the
+// timeout expires. Cloudflare is asked to check the zone's nameservers once
+// at the start; it rechecks pending zones by itself afterwards.
+func waitForActivation(api *cloudflare.API, zone cloudflare.Zone, interval, timeout time.Duration) error {
+	if _, err := api.ZoneActivationCheck(commandCtx, zone.ID); err != nil {
+		printf("Error requesting an activation check: %v\n", err)
+	}
+
+	printf("Waiting for zone %s to become active (Ctrl-C to stop)...\n", zone.Name)
+	deadline := time.Now().Add(timeout)
+	for {
+		z, err := api.ZoneDetails(commandCtx, zone.ID)
+		if err != nil {
+			return err
+		}
+		if z.Status == "active" {
+			printf("Zone %s is active.\n", zone.Name)
+			return nil
+		}
+
+		if time.Now().Add(interval).After(deadline) {
+			return fmt.Errorf("zone %s is still %s after %v; check the nameservers at the registrar",
+				zone.Name, z.Status, timeout)
+		}
+		if err := sleep(interval); err != nil {
+			return err
+		}
+	}
+}
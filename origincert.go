@@ -0,0 +1,209 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// originCertValidities are the validity periods, in days, the Origin CA
+// issues certificates for.
+var originCertValidities = []int{7, 30, 90, 365, 730, 1095, 5475}
+
+// defaultOriginCertValidity is the validity of an origin certificate, in
+// days, unless --validity is given. It is the longest the Origin CA allows.
+const defaultOriginCertValidity = 5475
+
+var certCreateFlags = registerFlags("cert create", mergeFlags(forceFlags,
+	flagSpec{"validity": true, "type": true, "out": true}))
+
+// cmdCertCreate requests an Origin CA certificate for hostnames in the
+// active zone. The private key is generated locally and written, with the
+// certificate, to files named after the first hostname or --out.
+func cmdCertCreate(c *cmd.Command, args []string) error {
+	flags, hostnames, err := parseFlags(args, certCreateFlags)
+	if err != nil {
+		return err
+	}
+	if len(hostnames) == 0 {
+		return usageError(c)
+	}
+
+	validity := defaultOriginCertValidity
+	if flags.has("validity") {
+		validity, err = strconv.Atoi(flags.get("validity", ""))
+		if err != nil || !slices.Contains(originCertValidities, validity) {
+			return argError(fmt.Errorf("invalid validity %q (valid are 7, 30, 90, 365, 730, 1095 and 5475 days)",
+				flags.get("validity", "")))
+		}
+	}
+	keyType := flags.get("type", "rsa")
+	if keyType != "rsa" && keyType != "ecc" {
+		return argError(fmt.Errorf("invalid key type %q (valid are rsa and ecc)", keyType))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	if _, err := getZoneIdentifier(); err != nil {
+		return err
+	}
+	for _, h := range hostnames {
+		if !inZone(h, activeZoneName) {
+			return argError(fmt.Errorf("hostname %s is not in zone %s", h, activeZoneName))
+		}
+	}
+
+	prefix := flags.get("out", strings.Replace(hostnames[0], "*", "wildcard", 1))
+	certFile, keyFile := prefix+".pem", prefix+".key"
+	if !flags.force() {
+		for _, f := range []string{certFile, keyFile} {
+			if _, err := os.Stat(f); !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("%s already exists; use --force to overwrite it", f)
+			}
+		}
+	}
+
+	cert, key, err := issueOriginCert(api, hostnames, validity, keyType)
+	if err != nil {
+		return err
+	}
+	printf("Issued origin certificate %s for %s, expiring %s.\n", cert.ID,
+		strings.Join(cert.Hostnames, ", "), cert.ExpiresOn.Format("2006-01-02"))
+
+	if err := os.WriteFile(keyFile, key, 0o600); err != nil {
+		return err
+	}
+	if err := os.WriteFile(certFile, []byte(cert.Certificate), 0o644); err != nil {
+		return err
+	}
+	printf("Wrote the certificate to %s and its private key to %s.\n", certFile, keyFile)
+	return nil
+}
+
+// issueOriginCert generates a private key of the type, rsa or ecc, and has
+// the Origin CA issue a certificate for it. The key is returned PEM-encoded.
+func issueOriginCert(api *cloudflare.API, hostnames []string, validity int, keyType string) (*cloudflare.OriginCACertificate, []byte, error) {
+	var key crypto.Signer
+	var err error
+	if keyType == "ecc" {
+		key, err = ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	} else {
+		key, err = rsa.GenerateKey(rand.Reader, 2048)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	csr, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: hostnames[0]},
+		DNSNames: hostnames,
+	}, key)
+	if err != nil {
+		return nil, nil, err
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	cert, err := api.CreateOriginCACertificate(commandCtx, cloudflare.CreateOriginCertificateParams{
+		Hostnames:       hostnames,
+		RequestType:     "origin-" + keyType,
+		RequestValidity: validity,
+		CSR:             string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: csr})),
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return cert, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der}), nil
+}
+
+// cmdCertList lists the Origin CA certificates issued for the active zone.
+func cmdCertList() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	certs, err := api.ListOriginCACertificates(commandCtx,
+		cloudflare.ListOriginCertificatesParams{ZoneID: zoneID.Identifier})
+	if err != nil {
+		return err
+	}
+	if len(certs) == 0 {
+		printf("No origin certificates found.\n")
+		return nil
+	}
+
+	slices.SortFunc(certs, func(a, b cloudflare.OriginCACertificate) int {
+		return a.ExpiresOn.Compare(b.ExpiresOn)
+	})
+	for _, cert := range certs {
+		status := "expires " + cert.ExpiresOn.Format("2006-01-02")
+		if !cert.RevokedAt.IsZero() {
+			status = "revoked " + cert.RevokedAt.Format("2006-01-02")
+		}
+		fmt.Fprintf(output, "%s %-10s %s %s\n", cert.ID, strings.TrimPrefix(cert.RequestType, "origin-"),
+			status, strings.Join(cert.Hostnames, ", "))
+	}
+	return nil
+}
+
+var certRevokeFlags = registerFlags("cert revoke", forceFlags)
+
+// cmdCertRevoke revokes an Origin CA certificate, after confirmation.
+func cmdCertRevoke(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, certRevokeFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	cert, err := api.GetOriginCACertificate(commandCtx, args[0])
+	if err != nil {
+		return err
+	}
+	hosts := strings.Join(cert.Hostnames, ", ")
+	if !flags.force() && !confirm(sprintf("Revoke origin certificate %s for %s?", cert.ID, hosts)) {
+		printf("Certificate not revoked.\n")
+		return nil
+	}
+
+	if _, err := api.RevokeOriginCACertificate(commandCtx, cert.ID); err != nil {
+		return err
+	}
+	printf("Revoked origin certificate %s for %s.\n", cert.ID, hosts)
+	return nil
+}
@@ -0,0 +1,21 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"io"
+	"os"
+)
+
+// output receives everything commands display. It writes to the current
+// os.Stdout, following it as redaction and RPC mode redirect it, and tests
+// replace it to capture the output of commands.
+var output io.Writer = stdoutWriter{}
+
+type stdoutWriter struct{}
+
+func (stdoutWriter) Write(p []byte) (int, error) {
+	return os.Stdout.Write(p)
+}
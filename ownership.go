@@ -0,0 +1,200 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// Records created by cf may be marked as owned by an owner ID, so that cf
+// can share a zone with other automation without touching records it did
+// not create. Ownership is recorded in a companion TXT record, in the same
+// way as external-dns. The marker of an A record named www.example.com is a
+// TXT record named _cf-a.www.example.com with the content
+// "heritage=cf,cf/owner=<owner>".
+
+// ownerID is the owner recorded in the ownership markers of new records. An
+// empty owner disables ownership tracking.
+var ownerID = ""
+
+// markerPrefix begins the first label of every ownership marker's name.
+const markerPrefix = "_cf-"
+
+// errNotOwned is returned when a record cannot be modified because another
+// owner manages it.
+var errNotOwned = errors.New("record is owned by another owner")
+
+// validateOwner checks that an owner ID may be stored in a marker.
+func validateOwner(owner string) error {
+	if owner == "" || strings.ContainsAny(owner, " \t\",=") {
+		return argError(fmt.Errorf("invalid owner %q", owner))
+	}
+	return nil
+}
+
+// markerName returns the name of the ownership marker of the records with
+// the requested type and name. A leading wildcard label is replaced, since
+// the wildcard may only appear as the first label of a name.
+func markerName(recType, name string) string {
+	prefix := markerPrefix + strings.ToLower(recType)
+	switch {
+	case name == "@":
+		return prefix
+	case name == "*":
+		return prefix + "._any"
+	case strings.HasPrefix(name, "*."):
+		return prefix + "._any" + name[1:]
+	default:
+		return prefix + "." + name
+	}
+}
+
+// markerContent returns the content of an ownership marker.
+func markerContent(owner string) string {
+	return "heritage=cf,cf/owner=" + owner
+}
+
+// markerOwner returns the owner named by an ownership marker's content, or
+// false if the content is not that of a marker.
+func markerOwner(content string) (string, bool) {
+	content = strings.Trim(content, "\"")
+	fields := strings.Split(content, ",")
+	if len(fields) < 2 || fields[0] != "heritage=cf" {
+		return "", false
+	}
+	for _, f := range fields[1:] {
+		if owner, ok := strings.CutPrefix(f, "cf/owner="); ok {
+			return owner, true
+		}
+	}
+	return "", false
+}
+
+// An ownerIndex maps the marker names of a set of zones to their owners.
+type ownerIndex map[string]string
+
+// loadOwners reads the ownership markers of the zones.
+func loadOwners(api *cloudflare.API, zones []zoneTarget) (ownerIndex, error) {
+	txts, err := listZoneRecords(api, zones, cloudflare.ListDNSRecordsParams{Type: "TXT"})
+	if err != nil {
+		return nil, err
+	}
+
+	owners := make(ownerIndex)
+	for _, r := range txts {
+		if !strings.HasPrefix(r.Name, markerPrefix) {
+			continue
+		}
+		if owner, ok := markerOwner(r.Content); ok {
+			owners[ownerKey(r.zoneID, r.Name)] = owner
+		}
+	}
+	return owners, nil
+}
+
+// owner returns the owner of a record, or the empty string if the record
+// has no ownership marker.
+func (o ownerIndex) owner(r zoneRecord) string {
+	return o[ownerKey(r.zoneID, markerName(r.Type, r.Name))]
+}
+
+func ownerKey(zoneID *cloudflare.ResourceContainer, name string) string {
+	return zoneID.Identifier + "/" + strings.ToLower(name)
+}
+
+// checkOwner returns errNotOwned if the records with the requested type and
+// name are marked as owned by an owner other than the active one.
+func checkOwner(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, recType, name string) error {
+	if ownerID == "" {
+		return nil
+	}
+	params := cloudflare.ListDNSRecordsParams{Type: "TXT", Name: markerName(recType, name)}
+	markers, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+	for _, m := range markers {
+		if owner, ok := markerOwner(m.Content); ok && owner != ownerID {
+			return fmt.Errorf("%s %s: %w (%s)", recType, name, errNotOwned, owner)
+		}
+	}
+	return nil
+}
+
+// markOwned creates the ownership marker of the records with the requested
+// type and name, unless ownership tracking is disabled or the marker already
+// exists.
+func markOwned(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, recType, name string) error {
+	if ownerID == "" {
+		return nil
+	}
+	marker := markerName(recType, name)
+	params := cloudflare.ListDNSRecordsParams{Type: "TXT", Name: marker}
+	markers, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+	for _, m := range markers {
+		if owner, ok := markerOwner(m.Content); ok && owner == ownerID {
+			return nil
+		}
+	}
+
+	createParams := cloudflare.CreateDNSRecordParams{
+		Type:    "TXT",
+		Name:    marker,
+		Content: markerContent(ownerID),
+		TTL:     ttlAuto,
+	}
+	_, err = recordBackend(api).CreateDNSRecord(commandCtx, zoneID.Identifier, createParams)
+	return err
+}
+
+// unmarkOwned deletes the active owner's marker of the records with the
+// requested type and name.
+func unmarkOwned(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, recType, name string) error {
+	params := cloudflare.ListDNSRecordsParams{Type: "TXT", Name: markerName(recType, name)}
+	markers, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+	for _, m := range markers {
+		if owner, ok := markerOwner(m.Content); ok && owner == ownerID {
+			if err := newClient(api, zoneID).Delete(commandCtx, m.ID); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// releaseDeleted deletes the active owner's markers of the record sets
+// whose records have all been deleted. The records are all of the records
+// considered for deletion, and deleted holds the IDs of those that were.
+func releaseDeleted(api *cloudflare.API, owners ownerIndex, recs []zoneRecord, deleted map[string]bool) error {
+	remaining := make(map[string]bool)
+	for _, r := range recs {
+		if !deleted[r.ID] {
+			remaining[ownerKey(r.zoneID, markerName(r.Type, r.Name))] = true
+		}
+	}
+
+	released := make(map[string]bool)
+	for _, r := range recs {
+		key := ownerKey(r.zoneID, markerName(r.Type, r.Name))
+		if !deleted[r.ID] || remaining[key] || released[key] || owners[key] != ownerID {
+			continue
+		}
+		released[key] = true
+		if err := unmarkOwned(api, r.zoneID, r.Type, r.Name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
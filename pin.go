@@ -0,0 +1,156 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A pinnedRecord, defined in the configuration file, is a record the pin
+// command keeps at its expected content, re-applying it whenever something
+// else changes the record. Its type and name should hold no other records.
+// TTL and Proxied are only enforced when set. Notify is a URL to which each
+// correction is posted as JSON, or a command run with the correction in its
+// environment.
+type pinnedRecord struct {
+	Zone    string `json:"zone"`
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+	Proxied *bool  `json:"proxied,omitempty"`
+	Notify  string `json:"notify,omitempty"`
+}
+
+// parse validates a pinned record's settings.
+func (p *pinnedRecord) parse() error {
+	if !inZone(p.Name, p.Zone) {
+		return fmt.Errorf("pinned record %s is not in zone %s", p.Name, p.Zone)
+	}
+	if p.Type == "" || p.Content == "" {
+		return fmt.Errorf("pinned record %s needs a type and content", p.Name)
+	}
+	p.Type = strings.ToUpper(p.Type)
+	if p.TTL != 0 {
+		if _, err := parseTTL(strconv.Itoa(p.TTL)); err != nil {
+			return fmt.Errorf("pinned record %s: %v", p.Name, err)
+		}
+	}
+	return nil
+}
+
+// drift describes how the records of a pinned record's type and name differ
+// from it, or returns the empty string if one of them matches it.
+func (p *pinnedRecord) drift(recs []cloudflare.DNSRecord) string {
+	if len(recs) == 0 {
+		return "record is missing"
+	}
+	for _, r := range recs {
+		if !cflib.ContentEqual(p.Type, r.Content, p.Content) {
+			continue
+		}
+		switch {
+		case p.TTL != 0 && cflib.NormalizeTTL(r.TTL) != cflib.NormalizeTTL(p.TTL):
+			return "TTL is " + formatTTL(r.TTL)
+		case p.Proxied != nil && isProxied(r) != *p.Proxied:
+			return fmt.Sprintf("proxied is %t", isProxied(r))
+		}
+		return ""
+	}
+	return "content is " + recs[0].Content
+}
+
+var pinFlags = registerFlags("pin", flagSpec{"interval": true, "once": false})
+
+func cmdPin(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, pinFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 || len(cfg.Pinned) == 0 {
+		return usageError(c)
+	}
+
+	interval, err := time.ParseDuration(flags.get("interval", "5m"))
+	if err != nil || interval <= 0 {
+		return argError(fmt.Errorf("invalid interval %q", flags.get("interval", "")))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	pins := cfg.Pinned
+	zoneIDs := make([]*cloudflare.ResourceContainer, len(pins))
+	for i := range pins {
+		id, err := recordBackend(api).ZoneIDByName(pins[i].Zone)
+		if err != nil {
+			return zoneError(err)
+		}
+		zoneIDs[i] = cloudflare.ZoneIdentifier(id)
+	}
+
+	log.Printf("Starting reconciliation of %d pinned record(s).", len(pins))
+	for {
+		for i := range pins {
+			if err := reconcilePin(api, zoneIDs[i], &pins[i]); err != nil {
+				log.Printf("Error reconciling %s record %s: %v", pins[i].Type, pins[i].Name, err)
+			}
+			if commandCtx.Err() != nil {
+				return errInterrupted
+			}
+		}
+
+		if flags.has("once") {
+			return nil
+		}
+
+		if err := sleep(interval); err != nil {
+			return err
+		}
+	}
+}
+
+// reconcilePin re-applies a pinned record that has drifted from its
+// expected value and sends the notification of the correction.
+func reconcilePin(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, p *pinnedRecord) error {
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: p.Type, Name: p.Name})
+	if err != nil {
+		return err
+	}
+	drift := p.drift(recs)
+	if drift == "" {
+		return nil
+	}
+
+	if _, err := upsertRecord(api, zoneID, p.Type, p.Name, p.Content, p.TTL, recordMeta{proxied: p.Proxied}); err != nil {
+		return err
+	}
+	log.Printf("Restored pinned %s record %s to %s (%s).", p.Type, p.Name, p.Content, drift)
+	if p.Notify != "" {
+		// The command is run with CF_PIN_ZONE, CF_PIN_TYPE, CF_PIN_NAME,
+		// CF_PIN_CONTENT and CF_PIN_DRIFT set.
+		fields := map[string]string{
+			"zone":    p.Zone,
+			"type":    p.Type,
+			"name":    p.Name,
+			"content": p.Content,
+			"drift":   drift,
+		}
+		if err := sendNotification(p.Notify, fields, "CF_PIN_"); err != nil {
+			log.Printf("Error sending pin notification: %v", err)
+		}
+	}
+	return nil
+}
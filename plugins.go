@@ -0,0 +1,187 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// externalPrefix is the prefix of the names of executables on the PATH that
+// cf offers as commands: cf-deploy is offered as the command deploy.
+const externalPrefix = "cf-"
+
+// addPlugins adds to a command tree the commands registered by other Go
+// packages with cflib.RegisterCommand, and the cf-<name> executables found
+// on the PATH. Neither may replace one of cf's own commands, and registered
+// commands take precedence over executables.
+func addPlugins(root *cmd.Tree) {
+	taken := make(map[string]bool)
+	for _, c := range root.Commands() {
+		taken[c.Name] = true
+	}
+
+	for _, rc := range cflib.RegisteredCommands() {
+		if taken[rc.Name] {
+			continue
+		}
+		taken[rc.Name] = true
+		run := rc.Run
+		root.AddCommand(cmd.CommandDescriptor{
+			Name:        rc.Name,
+			Brief:       rc.Brief,
+			Description: rc.Description,
+			Usage:       rc.Usage,
+			Data: func(c *cmd.Command, args []string) error {
+				return run(pluginSession{}, args)
+			},
+		})
+	}
+
+	external := externalCommands()
+	for _, name := range sortedKeys(external) {
+		if taken[name] {
+			continue
+		}
+		path := external[name]
+		root.AddCommand(cmd.CommandDescriptor{
+			Name:  name,
+			Brief: "External command " + filepath.Base(path),
+			Description: "Run the external command " + path + " with the " +
+				"arguments given, the session's credentials and the " +
+				"active zone in its environment.",
+			Usage: name + " [<arg> ...]",
+			Data: func(c *cmd.Command, args []string) error {
+				return runExternal(path, args)
+			},
+		})
+	}
+}
+
+// externalCommands returns the cf-<name> executables found on the PATH by
+// command name. When several directories hold one of the same name, the
+// first on the PATH is used, as the shell would.
+func externalCommands() map[string]string {
+	found := make(map[string]string)
+	for _, dir := range filepath.SplitList(os.Getenv("PATH")) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name, ok := strings.CutPrefix(e.Name(), externalPrefix)
+			if runtime.GOOS == "windows" {
+				name, ok = strings.CutSuffix(name, ".exe")
+			}
+			if !ok || e.IsDir() || name == "" || found[name] != "" || !usageSubcommand.MatchString(name) {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if info, err := os.Stat(path); err != nil || (runtime.GOOS != "windows" && info.Mode()&0111 == 0) {
+				continue
+			}
+			found[name] = path
+		}
+	}
+	return found
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// runExternal runs an external command, passing it the session's
+// credentials as CLOUDFLARE_API_TOKEN, or CLOUDFLARE_EMAIL and
+// CLOUDFLARE_KEY, and the active zone as CLOUDFLARE_ZONE and
+// CLOUDFLARE_ZONE_ID. CF_DRY_RUN is set to 1 in dry-run mode and
+// CF_OUTPUT to the output format. A failing command's exit status becomes
+// cf's.
+func runExternal(path string, args []string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	env := os.Environ()
+	if api.APIToken != "" {
+		env = append(env, "CLOUDFLARE_API_TOKEN="+api.APIToken)
+	} else {
+		env = append(env, "CLOUDFLARE_EMAIL="+api.APIEmail, "CLOUDFLARE_KEY="+api.APIKey)
+	}
+	// A command may manage the account rather than a zone, so it runs
+	// without one if none is chosen.
+	if zoneID, err := getZoneIdentifier(); err == nil {
+		env = append(env, "CLOUDFLARE_ZONE="+activeZoneName, "CLOUDFLARE_ZONE_ID="+zoneID.Identifier)
+	}
+	if dryRun {
+		env = append(env, "CF_DRY_RUN=1")
+	}
+	env = append(env, "CF_OUTPUT="+outputFormat)
+
+	c := exec.CommandContext(commandCtx, path, args...)
+	c.Env = env
+	c.Stdin, c.Stdout, c.Stderr = os.Stdin, os.Stdout, os.Stderr
+	err = c.Run()
+	var exitErr *exec.ExitError
+	switch {
+	case commandCtx.Err() != nil:
+		return errInterrupted
+	case errors.As(err, &exitErr):
+		return &codedError{exitErr.ExitCode(), fmt.Errorf("%s exited with status %d", filepath.Base(path), exitErr.ExitCode())}
+	}
+	return err
+}
+
+// A pluginSession gives commands registered with cflib.RegisterCommand
+// access to the session.
+type pluginSession struct{}
+
+func (pluginSession) Context() context.Context {
+	return commandCtx
+}
+
+func (pluginSession) API() (*cloudflare.API, error) {
+	return getAPI()
+}
+
+func (pluginSession) Zone() (string, string, error) {
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return "", "", err
+	}
+	return activeZoneName, zoneID.Identifier, nil
+}
+
+func (pluginSession) Client() (*cflib.Client, error) {
+	api, err := getAPI()
+	if err != nil {
+		return nil, err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return nil, err
+	}
+	return newClient(api, zoneID), nil
+}
+
+func (pluginSession) Interactive() bool {
+	return interactive
+}
@@ -0,0 +1,273 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A vantagePoint is a public DNS-over-HTTPS resolver answering with the
+// JSON API, queried by verify --global. A resolver honoring EDNS Client
+// Subnet answers as it would for a client in Subnet, which stands in for
+// a region where the resolver has no endpoint of its own.
+type vantagePoint struct {
+	Name   string
+	URL    string
+	Subnet string
+}
+
+// vantagePoints are the resolvers of verify --global, chosen to see a
+// record as clients on every continent do.
+var vantagePoints = []vantagePoint{
+	{"Cloudflare", "https://cloudflare-dns.com/dns-query", ""},
+	{"Quad9", "https://dns.quad9.net:5053/dns-query", ""},
+	{"AliDNS (China)", "https://dns.alidns.com/resolve", ""},
+	{"Google (N. America)", "https://dns.google/resolve", "24.0.0.0/24"},
+	{"Google (S. America)", "https://dns.google/resolve", "200.160.0.0/24"},
+	{"Google (Europe)", "https://dns.google/resolve", "81.2.69.0/24"},
+	{"Google (Africa)", "https://dns.google/resolve", "196.25.1.0/24"},
+	{"Google (Asia)", "https://dns.google/resolve", "202.12.27.0/24"},
+	{"Google (Oceania)", "https://dns.google/resolve", "1.128.0.0/24"},
+}
+
+// dnsTypeCodes are the codes of the record types verify --global checks, as
+// they appear in DNS-over-HTTPS JSON answers.
+var dnsTypeCodes = map[string]int{"A": 1, "NS": 2, "CNAME": 5, "TXT": 16, "AAAA": 28}
+
+// dohLookup returns the values a DNS-over-HTTPS resolver serves for the
+// records of a type and name.
+func dohLookup(ctx context.Context, vp vantagePoint, recType, name string) ([]string, error) {
+	q := url.Values{"name": {name}, "type": {recType}}
+	if vp.Subnet != "" {
+		q.Set("edns_client_subnet", vp.Subnet)
+	}
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, vp.URL+"?"+q.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/dns-json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	var answer struct {
+		Status int `json:"Status"`
+		Answer []struct {
+			Type int    `json:"type"`
+			Data string `json:"data"`
+		} `json:"Answer"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return nil, err
+	}
+	switch answer.Status {
+	case 0:
+	case 3:
+		return nil, fmt.Errorf("no such host")
+	default:
+		return nil, fmt.Errorf("DNS response code %d", answer.Status)
+	}
+
+	var values []string
+	for _, a := range answer.Answer {
+		if a.Type != dnsTypeCodes[recType] {
+			continue
+		}
+		switch recType {
+		case "CNAME", "NS":
+			values = append(values, cflib.NormalizeName(a.Data))
+		case "TXT":
+			values = append(values, unquoteTXT(a.Data))
+		default:
+			values = append(values, a.Data)
+		}
+	}
+	return values, nil
+}
+
+// unquoteTXT joins the quoted strings of a TXT record as served in a JSON
+// answer, such as "v=spf1 " "-all".
+func unquoteTXT(data string) string {
+	if !strings.HasPrefix(data, `"`) {
+		return data
+	}
+	var b strings.Builder
+	for _, part := range strings.Split(data, `" "`) {
+		b.WriteString(strings.Trim(part, `"`))
+	}
+	return b.String()
+}
+
+// A propagationCell is the status of the records of a name as seen from a
+// vantage point.
+type propagationCell struct {
+	Vantage string   `json:"vantage_point"`
+	Name    string   `json:"name"`
+	Values  []string `json:"values"`
+	Error   string   `json:"error,omitempty"`
+	Match   bool     `json:"match"`
+	Note    string   `json:"note"`
+}
+
+// propagationMatrix queries every vantage point for the records of a type
+// and each of several names in parallel, comparing their answers with the
+// records configured in Cloudflare. The cells are ordered by vantage point
+// and then by name.
+func propagationMatrix(recType string, names []string, configured map[string][]cloudflare.DNSRecord) []propagationCell {
+	cells := make([]propagationCell, len(vantagePoints)*len(names))
+	var wg sync.WaitGroup
+	for i, vp := range vantagePoints {
+		for j, name := range names {
+			wg.Add(1)
+			go func(cell *propagationCell, vp vantagePoint, name string) {
+				defer wg.Done()
+				recs := configured[name]
+				// A proxied CNAME is flattened to Cloudflare's addresses.
+				queryType := recType
+				if recType == "CNAME" && len(recs) > 0 && isProxied(recs[0]) {
+					queryType = "A"
+				}
+				values, err := dohLookup(commandCtx, vp, queryType, name)
+				*cell = propagationCell{Vantage: vp.Name, Name: name, Values: values}
+				if err != nil {
+					cell.Error = err.Error()
+				}
+				cell.Match, cell.Note = checkPublicAnswer(recType, recs, values, err)
+			}(&cells[i*len(names)+j], vp, name)
+		}
+	}
+	wg.Wait()
+	return cells
+}
+
+// verifyGlobal checks that resolvers around the world serve the records of
+// a type and names as configured in Cloudflare, displaying the status of
+// each name from each vantage point. With a wait timeout, the resolvers are
+// polled until they all agree with Cloudflare.
+func verifyGlobal(recType string, names []string, wait time.Duration) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	configured := make(map[string][]cloudflare.DNSRecord)
+	for i, name := range names {
+		if name == "@" {
+			name = activeZoneName
+		}
+		names[i] = cflib.NormalizeName(name)
+		recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: recType, Name: names[i]})
+		if err != nil {
+			return err
+		}
+		configured[names[i]] = recs
+	}
+
+	deadline := time.Now().Add(wait)
+	if wait > 0 {
+		printf("Waiting for %d resolver(s) to serve the records...\n", len(vantagePoints))
+	}
+	var cells []propagationCell
+	mismatches := 0
+	for {
+		cells = propagationMatrix(recType, names, configured)
+		mismatches = 0
+		for _, c := range cells {
+			if !c.Match {
+				mismatches++
+			}
+		}
+		if mismatches == 0 || !time.Now().Add(waitInterval).Before(deadline) {
+			break
+		}
+		if err := sleep(waitInterval); err != nil {
+			return err
+		}
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(cells); err != nil {
+			return err
+		}
+	} else {
+		displayPropagationMatrix(names, cells)
+	}
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d answer(s) disagree with Cloudflare", mismatches, len(cells))
+	}
+	return nil
+}
+
+// displayPropagationMatrix displays the status of each name from each
+// vantage point, one row per vantage point, followed by the notes
+// explaining the differences.
+func displayPropagationMatrix(names []string, cells []propagationCell) {
+	width := len(tr("Resolver"))
+	for _, vp := range vantagePoints {
+		width = max(width, len(vp.Name))
+	}
+	widths := make([]int, len(names))
+	for j, name := range names {
+		widths[j] = max(len(name), len(tr("no answer")), len(tr("differs")))
+	}
+
+	header := fmt.Sprintf("%-*s", width, tr("Resolver"))
+	for j, name := range names {
+		header += fmt.Sprintf("  %-*s", widths[j], name)
+	}
+	fmt.Fprintln(output, strings.TrimRight(header, " "))
+
+	var notes []string
+	for i, vp := range vantagePoints {
+		row := fmt.Sprintf("%-*s", width, vp.Name)
+		for j := range names {
+			c := cells[i*len(names)+j]
+			status := tr("ok")
+			switch {
+			case c.Match:
+			case c.Error != "":
+				status = tr("no answer")
+			default:
+				status = tr("differs")
+			}
+			if !c.Match {
+				notes = append(notes, fmt.Sprintf("%s, %s: %s", c.Vantage, c.Name, c.Note))
+			}
+			row += fmt.Sprintf("  %-*s", widths[j], status)
+		}
+		fmt.Fprintln(output, strings.TrimRight(row, " "))
+	}
+
+	if len(notes) > 0 {
+		fmt.Fprintln(output)
+		for _, n := range notes {
+			fmt.Fprintln(output, n)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A proposal is a DNS record derived from some external source, such as a
+// Kubernetes cluster or a reverse proxy's configuration, along with the
+// change needed to create it.
+type proposal struct {
+	source  string
+	recType string
+	name    string
+	content string
+	action  string
+}
+
+// targetRecord returns the type and content of a record pointing at a
+// target, which is an A or AAAA record for an IP address and a CNAME record
+// for a hostname.
+func targetRecord(target string) (recType, content string) {
+	addr, err := netip.ParseAddr(target)
+	if err != nil {
+		return "CNAME", cflib.NormalizeName(target)
+	}
+	addr = addr.Unmap()
+	if addr.Is6() {
+		return "AAAA", addr.String()
+	}
+	return "A", addr.String()
+}
+
+// inZone reports whether a hostname belongs to a zone.
+func inZone(name, zone string) bool {
+	name, zone = cflib.NormalizeName(name), cflib.NormalizeName(zone)
+	return name == zone || strings.HasSuffix(name, "."+zone)
+}
+
+func sortProposals(proposals []proposal) {
+	sort.Slice(proposals, func(i, j int) bool {
+		if proposals[i].name != proposals[j].name {
+			return proposals[i].name < proposals[j].name
+		}
+		return proposals[i].recType < proposals[j].recType
+	})
+}
+
+// conflictFlags are the flags accepted by commands that create records
+// from an external source, selecting how records already existing with
+// different content are treated.
+var conflictFlags = flagSpec{
+	"on-conflict": true,
+}
+
+// The strategies for records conflicting with an existing record of the
+// same type and name. Overwrite replaces the existing record's content,
+// merge adds a record alongside it, skip leaves it alone, and fail makes
+// no changes at all.
+const (
+	conflictOverwrite = "overwrite"
+	conflictMerge     = "merge"
+	conflictSkip      = "skip"
+	conflictFail      = "fail"
+)
+
+// parseConflict returns the conflict strategy requested by --on-conflict,
+// which defaults to overwrite.
+func parseConflict(flags flagValues) (string, error) {
+	strategy := strings.ToLower(flags.get("on-conflict", conflictOverwrite))
+	switch strategy {
+	case conflictOverwrite, conflictMerge, conflictSkip, conflictFail:
+		return strategy, nil
+	}
+	return "", argError(fmt.Errorf("invalid conflict strategy %q", flags.get("on-conflict", "")))
+}
+
+// planProposals compares the proposals with the records of a zone, setting
+// the action each requires, with proposals conflicting with existing
+// records treated as the conflict strategy requires. It returns the number
+// of proposals that would change the zone.
+func planProposals(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	proposals []proposal, onConflict string) (int, error) {
+
+	changes := 0
+	var conflicts []string
+	for i := range proposals {
+		p := &proposals[i]
+		params := cloudflare.ListDNSRecordsParams{Type: p.recType, Name: p.name}
+		recs, err := listRecords(api, zoneID, params)
+		if err != nil {
+			return 0, err
+		}
+		matches := func(r cloudflare.DNSRecord) bool {
+			return cflib.ContentEqual(p.recType, r.Content, p.content)
+		}
+		switch {
+		case len(recs) == 0:
+			p.action = "create"
+			changes++
+		case matches(recs[0]) || (onConflict != conflictOverwrite && slices.ContainsFunc(recs, matches)):
+			p.action = "ok"
+		case onConflict == conflictOverwrite:
+			p.action = "update"
+			changes++
+		case onConflict == conflictMerge && p.recType != "CNAME":
+			p.action = "add"
+			changes++
+		default:
+			// A name holds at most one CNAME record, so a conflicting
+			// CNAME record cannot be merged.
+			p.action = "skip"
+			conflicts = append(conflicts, p.recType+" "+p.name)
+		}
+	}
+	if onConflict == conflictFail && len(conflicts) > 0 {
+		return 0, fmt.Errorf("%d record(s) conflict with existing records: %s",
+			len(conflicts), strings.Join(conflicts, ", "))
+	}
+	return changes, nil
+}
+
+// protectProposals keeps the proposals from changing protected records,
+// returning the number of proposals still changing the zone.
+func protectProposals(proposals []proposal, zone string, changes int) int {
+	for i := range proposals {
+		p := &proposals[i]
+		if p.action == "update" && isProtected(zone, p.recType, p.name) {
+			p.action = "protected"
+			changes--
+		}
+	}
+	return changes
+}
+
+// applyProposals makes the changes required by planned proposals, after
+// asking for confirmation unless force is true.
+func applyProposals(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	proposals []proposal, changes int, force bool) error {
+
+	if changes == 0 {
+		return nil
+	}
+	if !force && !confirm(sprintf("Apply %d change(s)?", changes)) {
+		printf("No changes applied.\n")
+		return nil
+	}
+
+	var pending []proposal
+	var ops []operation
+	for _, p := range proposals {
+		if p.action == "ok" || p.action == "skip" || p.action == "protected" {
+			continue
+		}
+		pending = append(pending, p)
+		key := zoneID.Identifier + "/" + p.recType + " " + cflib.NormalizeName(p.name)
+		if p.action == "add" {
+			params := cloudflare.CreateDNSRecordParams{
+				Type:    p.recType,
+				Name:    p.name,
+				Content: p.content,
+				TTL:     ttlAuto,
+			}
+			ops = append(ops, operation{
+				key: key,
+				fn: func() error {
+					_, err := recordBackend(api).CreateDNSRecord(commandCtx, zoneID.Identifier, params)
+					return err
+				},
+				change: createChange(zoneID.Identifier, activeZoneName, params),
+			})
+			continue
+		}
+		ops = append(ops, operation{
+			key: key,
+			fn: func() error {
+				_, err := upsertRecord(api, zoneID, p.recType, p.name, p.content, 0, recordMeta{})
+				return err
+			},
+			change: upsertChange(zoneID.Identifier, activeZoneName, p.recType, p.name, p.content),
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		p := pending[i]
+		if err != nil {
+			printf("Error updating %s record %s: %v\n", p.recType, p.name, err)
+			failed++
+			continue
+		}
+		if p.action == "create" || p.action == "add" {
+			printf("Created %s record %s.\n", p.recType, p.name)
+		} else {
+			printf("Updated %s record %s.\n", p.recType, p.name)
+		}
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d change(s) could not be applied", failed, changes)
+	}
+	printf("%d change(s) applied.\n", changes)
+	return nil
+}
+
+func displayProposals(proposals []proposal) {
+	widthAction, widthType, widthName, widthContent := 0, 0, 0, 0
+	for _, p := range proposals {
+		widthAction = max(widthAction, len(p.action))
+		widthType = max(widthType, len(p.recType))
+		widthName = max(widthName, len(p.name))
+		widthContent = max(widthContent, len(p.content))
+	}
+	for _, p := range proposals {
+		fmt.Fprintf(output, "%-*s %-*s %-*s %-*s %s\n", widthAction, p.action, widthType, p.recType,
+			widthName, p.name, widthContent, p.content, p.source)
+	}
+}
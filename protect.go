@@ -0,0 +1,213 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+)
+
+// A protectedRecord, listed in the configuration file, names records that
+// delete, import and edit refuse to change unless --force is given, such
+// as a zone's MX records or its apex address. A type of * protects the
+// records of every type with the name.
+type protectedRecord struct {
+	Zone string `json:"zone"`
+	Type string `json:"type"`
+	Name string `json:"name"`
+}
+
+// covers reports whether the entry protects a record of a type and name in
+// a zone.
+func (p protectedRecord) covers(zone, recType, name string) bool {
+	if cflib.NormalizeName(p.Zone) != cflib.NormalizeName(zone) {
+		return false
+	}
+	if p.Type != "*" && !strings.EqualFold(p.Type, recType) {
+		return false
+	}
+	return cflib.NormalizeName(p.Name) == cflib.NormalizeName(name)
+}
+
+// isProtected reports whether a record of a type and name in a zone is
+// protected.
+func isProtected(zone, recType, name string) bool {
+	for _, p := range cfg.Protected {
+		if p.covers(zone, recType, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// protectedError returns the error refusing to change protected records,
+// each described by its type and name, or nil if there are none.
+func protectedError(verb string, records []string) error {
+	if len(records) == 0 {
+		return nil
+	}
+	return fmt.Errorf("refusing to %s protected record(s) %s; use --force to override",
+		verb, strings.Join(records, ", "))
+}
+
+// protectOverrideKey marks the context of requests allowed to delete
+// protected records.
+type protectOverrideKey struct{}
+
+// deleteCtx returns the context of requests deleting records. Protected
+// records are deleted only if force is true, as when a command is given
+// --force.
+func deleteCtx(force bool) context.Context {
+	if !force {
+		return commandCtx
+	}
+	return context.WithValue(commandCtx, protectOverrideKey{}, true)
+}
+
+// A protectBackend refuses to delete protected records, unless the request
+// is made with the context returned by deleteCtx(true). Every command
+// deleting records through recordBackend is held to it, whether or not it
+// checks for protected records itself before asking for confirmation.
+type protectBackend struct {
+	cflib.Backend
+}
+
+func (b protectBackend) DeleteDNSRecord(ctx context.Context, zoneID, id string) error {
+	if len(cfg.Protected) > 0 && ctx.Value(protectOverrideKey{}) == nil {
+		r, err := b.Backend.GetDNSRecord(ctx, zoneID, id)
+		if err != nil {
+			return err
+		}
+		if isProtected(zoneName(zoneID), r.Type, r.Name) {
+			return protectedError("delete", []string{r.Type + " " + r.Name})
+		}
+	}
+	return b.Backend.DeleteDNSRecord(ctx, zoneID, id)
+}
+
+func cmdProtect(c *cmd.Command, args []string) error {
+	switch {
+	case len(args) == 0 || (args[0] == "list" && len(args) == 1):
+		return listProtected()
+	case (args[0] == "add" || args[0] == "remove") && len(args) == 3:
+		return changeProtected(args[0] == "add", strings.ToUpper(args[1]), args[2])
+	default:
+		return usageError(c)
+	}
+}
+
+func listProtected() error {
+	if len(cfg.Protected) == 0 {
+		printf("No records are protected.\n")
+		return nil
+	}
+	widthZone, widthType := 0, 0
+	for _, p := range cfg.Protected {
+		widthZone = max(widthZone, len(p.Zone))
+		widthType = max(widthType, len(p.Type))
+	}
+	for _, p := range cfg.Protected {
+		fmt.Fprintf(output, "%-*s %-*s %s\n", widthZone, p.Zone, widthType, p.Type, p.Name)
+	}
+	return nil
+}
+
+// changeProtected adds the records of a type and name in the active zone to
+// the protection list, or removes them from it.
+func changeProtected(add bool, recType, name string) error {
+	if _, err := getZoneIdentifier(); err != nil {
+		return err
+	}
+	zone := cflib.NormalizeName(activeZoneName)
+	if name == "@" {
+		name = zone
+	}
+	name = cflib.NormalizeName(name)
+	if !inZone(name, zone) {
+		return argError(fmt.Errorf("%s is not in zone %s", name, zone))
+	}
+	entry := protectedRecord{Zone: zone, Type: recType, Name: name}
+
+	var list []protectedRecord
+	found := false
+	for _, p := range cfg.Protected {
+		if p == entry {
+			found = true
+			if !add {
+				continue
+			}
+		}
+		list = append(list, p)
+	}
+	switch {
+	case add && found:
+		printf("%s records named %s are already protected.\n", recType, name)
+		return nil
+	case !add && !found:
+		return fmt.Errorf("%s records named %s are not protected", recType, name)
+	case add:
+		list = append(list, entry)
+	}
+
+	if err := saveConfigValue("protected", list); err != nil {
+		return err
+	}
+	cfg.Protected = list
+	if add {
+		printf("Protected %s records named %s.\n", recType, name)
+	} else {
+		printf("Removed the protection of %s records named %s.\n", recType, name)
+	}
+	return nil
+}
+
+// saveConfigValue sets a top-level value of the configuration file, leaving
+// its other settings as they are. An empty list removes the value.
+func saveConfigValue(key string, value any) error {
+	path := configPath()
+	if path == "" {
+		return errors.New("no configuration file location")
+	}
+
+	settings := make(map[string]json.RawMessage)
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+	case err != nil:
+		return err
+	default:
+		if err := json.Unmarshal(data, &settings); err != nil {
+			return fmt.Errorf("%s: %v", path, err)
+		}
+	}
+
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	if string(raw) == "null" || string(raw) == "[]" {
+		delete(settings, key)
+	} else {
+		settings[key] = raw
+	}
+
+	data, err = json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	return writeStateFile(path, append(data, '\n'))
+}
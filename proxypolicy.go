@@ -0,0 +1,49 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// proxyFlags are the flags accepted by commands that create address and
+// CNAME records. --dns-only creates a record without Cloudflare's proxy in
+// a zone whose policy proxies new records.
+var proxyFlags = flagSpec{
+	"dns-only": false,
+}
+
+// proxiedByPolicy reports whether the configuration file's proxied_zones
+// setting requires new records of a type in a zone to be proxied.
+func proxiedByPolicy(zone, recType string) bool {
+	switch strings.ToUpper(recType) {
+	case "A", "AAAA", "CNAME":
+	default:
+		return false
+	}
+	for _, z := range cfg.ProxiedZones {
+		if cflib.NormalizeName(z) == cflib.NormalizeName(zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// applyProxyPolicy turns on the proxy of a new record in a zone whose
+// policy proxies new records, unless the proxy setting was given
+// explicitly, so that a record cannot expose an origin's address by
+// accident.
+func applyProxyPolicy(zoneID string, params *cloudflare.CreateDNSRecordParams) {
+	if params.Proxied != nil || len(cfg.ProxiedZones) == 0 {
+		return
+	}
+	if proxiedByPolicy(zoneName(zoneID), params.Type) {
+		on := true
+		params.Proxied = &on
+	}
+}
@@ -0,0 +1,223 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// cloudflareRanges are the address ranges Cloudflare answers proxied
+// records with, as published at https://www.cloudflare.com/ips/.
+var cloudflareRanges = []netip.Prefix{
+	netip.MustParsePrefix("173.245.48.0/20"),
+	netip.MustParsePrefix("103.21.244.0/22"),
+	netip.MustParsePrefix("103.22.200.0/22"),
+	netip.MustParsePrefix("103.31.4.0/22"),
+	netip.MustParsePrefix("141.101.64.0/18"),
+	netip.MustParsePrefix("108.162.192.0/18"),
+	netip.MustParsePrefix("190.93.240.0/20"),
+	netip.MustParsePrefix("188.114.96.0/20"),
+	netip.MustParsePrefix("197.234.240.0/22"),
+	netip.MustParsePrefix("198.41.128.0/17"),
+	netip.MustParsePrefix("162.158.0.0/15"),
+	netip.MustParsePrefix("104.16.0.0/13"),
+	netip.MustParsePrefix("104.24.0.0/14"),
+	netip.MustParsePrefix("172.64.0.0/13"),
+	netip.MustParsePrefix("131.0.72.0/22"),
+	netip.MustParsePrefix("2400:cb00::/32"),
+	netip.MustParsePrefix("2606:4700::/32"),
+	netip.MustParsePrefix("2803:f800::/32"),
+	netip.MustParsePrefix("2405:b500::/32"),
+	netip.MustParsePrefix("2405:8100::/32"),
+	netip.MustParsePrefix("2a06:98c0::/29"),
+	netip.MustParsePrefix("2c0f:f248::/32"),
+}
+
+// isCloudflareAddress reports whether an address is one of Cloudflare's.
+func isCloudflareAddress(s string) bool {
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return false
+	}
+	for _, p := range cloudflareRanges {
+		if p.Contains(addr.Unmap()) {
+			return true
+		}
+	}
+	return false
+}
+
+// A publicAnswer is what a public resolver serves for a record, and how it
+// compares with what Cloudflare has configured.
+type publicAnswer struct {
+	Resolver string   `json:"resolver"`
+	Values   []string `json:"values"`
+	Error    string   `json:"error,omitempty"`
+	Match    bool     `json:"match"`
+	Note     string   `json:"note"`
+}
+
+// comparePublicDNS displays the records of a type and name configured in
+// Cloudflare beside the answers of the public resolvers, and fails if any
+// resolver's answer differs from what Cloudflare serves.
+func comparePublicDNS(recType, name string, recs []cloudflare.DNSRecord) error {
+	switch recType {
+	case "A", "AAAA", "CNAME", "TXT", "NS":
+	default:
+		return argError(fmt.Errorf("comparing %s records is not supported", recType))
+	}
+
+	// A proxied CNAME is flattened to Cloudflare's addresses, so resolvers
+	// are asked for those instead.
+	queryType := recType
+	if recType == "CNAME" && len(recs) > 0 && isProxied(recs[0]) {
+		queryType = "A"
+	}
+
+	var answers []publicAnswer
+	mismatches := 0
+	for _, ns := range resolvers {
+		a := publicAnswer{Resolver: ns}
+		values, err := lookupRecord(ns, queryType, name)
+		if err != nil {
+			a.Error = err.Error()
+		}
+		a.Values = values
+		a.Match, a.Note = checkPublicAnswer(recType, recs, values, err)
+		if !a.Match {
+			mismatches++
+		}
+		answers = append(answers, a)
+	}
+
+	if outputFormat == "json" {
+		var configured []string
+		for _, r := range recs {
+			configured = append(configured, r.Content)
+		}
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		err := enc.Encode(map[string]any{
+			"type":       recType,
+			"name":       name,
+			"configured": configured,
+			"proxied":    len(recs) > 0 && isProxied(recs[0]),
+			"resolvers":  answers,
+		})
+		if err != nil {
+			return err
+		}
+	} else {
+		displayPublicDNS(recs, answers)
+	}
+
+	if mismatches > 0 {
+		return fmt.Errorf("%d of %d resolver(s) disagree with Cloudflare", mismatches, len(resolvers))
+	}
+	return nil
+}
+
+func displayPublicDNS(recs []cloudflare.DNSRecord, answers []publicAnswer) {
+	width := len("Cloudflare")
+	for _, a := range answers {
+		width = max(width, len(a.Resolver))
+	}
+
+	var configured []string
+	for _, r := range recs {
+		configured = append(configured, r.Content)
+	}
+	switch {
+	case len(recs) == 0:
+		fmt.Fprintf(output, "%-*s  %s\n", width+1, "Cloudflare:", tr("(no records)"))
+	case isProxied(recs[0]):
+		fmt.Fprintf(output, "%-*s  %s  %s\n", width+1, "Cloudflare:", strings.Join(configured, ", "),
+			sprintf("(proxied, TTL %s)", formatTTL(recs[0].TTL)))
+	default:
+		fmt.Fprintf(output, "%-*s  %s  %s\n", width+1, "Cloudflare:", strings.Join(configured, ", "),
+			sprintf("(TTL %s)", formatTTL(recs[0].TTL)))
+	}
+
+	for _, a := range answers {
+		values := strings.Join(a.Values, ", ")
+		if a.Error != "" {
+			values = tr("no answer")
+		}
+		flag := ""
+		if !a.Match {
+			flag = "! "
+		}
+		fmt.Fprintf(output, "%-*s  %s  %s%s\n", width+1, a.Resolver+":", values, flag, a.Note)
+	}
+}
+
+// checkPublicAnswer compares the values a resolver returned for a record
+// with the records configured in Cloudflare. It reports whether they agree
+// and describes the difference, suggesting its likely cause.
+func checkPublicAnswer(recType string, recs []cloudflare.DNSRecord, values []string, err error) (bool, string) {
+	switch {
+	case len(recs) == 0 && (err != nil || len(values) == 0):
+		return true, tr("not served")
+	case len(recs) == 0:
+		return false, tr("served, but not configured in Cloudflare; another provider may still be authoritative")
+	case err != nil || len(values) == 0:
+		return false, tr("not served yet; resolvers may cache the missing record for up to the zone's negative TTL")
+	}
+
+	if isProxied(recs[0]) {
+		for _, v := range values {
+			if !isCloudflareAddress(v) {
+				return false, sprintf("serves %s, not Cloudflare's addresses; the record was recently proxied, or is cached", v)
+			}
+		}
+		return true, tr("Cloudflare addresses, as expected for a proxied record")
+	}
+
+	for _, r := range recs {
+		if !containsContent(recType, values, r.Content) {
+			if recType == "A" || recType == "AAAA" {
+				for _, v := range values {
+					if isCloudflareAddress(v) {
+						return false, sprintf("serves Cloudflare's addresses; the record was recently unproxied, or is cached for up to %s", formatTTL(r.TTL))
+					}
+				}
+			}
+			return false, sprintf("stale; resolvers may cache the old value for up to %s", formatTTL(r.TTL))
+		}
+	}
+	for _, v := range values {
+		if !containsRecordContent(recType, recs, v) {
+			return false, sprintf("also serves %s, which is not configured; it may be cached", v)
+		}
+	}
+	return true, tr("matches")
+}
+
+// containsContent reports whether a list of values holds a record's content.
+func containsContent(recType string, values []string, content string) bool {
+	for _, v := range values {
+		if cflib.ContentEqual(recType, v, content) {
+			return true
+		}
+	}
+	return false
+}
+
+// containsRecordContent reports whether any of a list of records has a
+// content.
+func containsRecordContent(recType string, recs []cloudflare.DNSRecord, content string) bool {
+	for _, r := range recs {
+		if cflib.ContentEqual(recType, r.Content, content) {
+			return true
+		}
+	}
+	return false
+}
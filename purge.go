@@ -0,0 +1,99 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// maxPurgeBatch is the largest number of URLs, tags, hosts or prefixes
+// accepted by a single purge request.
+const maxPurgeBatch = 30
+
+// purgeCapabilities holds the capabilities required by the kinds of purge
+// offered only on some plans.
+var purgeCapabilities = map[string]capability{
+	"tag":    capPurgeByTag,
+	"host":   capPurgeByHost,
+	"prefix": capPurgeByPrefix,
+}
+
+var purgeFlags = registerFlags("purge", forceFlags)
+
+func cmdPurge(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, purgeFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	kind := strings.ToLower(args[0])
+	items := args[1:]
+	if (kind == "all") != (len(items) == 0) {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	ctx := commandCtx
+
+	if need, ok := purgeCapabilities[kind]; ok {
+		if err := requireCapability(api, zoneID, need); err != nil {
+			return err
+		}
+	}
+
+	if kind == "all" {
+		if !flags.force() && !confirm(sprintf("Purge all cached content of zone %s?", activeZoneName)) {
+			printf("Cache not purged.\n")
+			return nil
+		}
+		if _, err := api.PurgeEverything(ctx, zoneID.Identifier); err != nil {
+			return err
+		}
+		printf("Purged all cached content.\n")
+		return nil
+	}
+
+	for len(items) > 0 {
+		n := min(len(items), maxPurgeBatch)
+		batch := items[:n]
+		items = items[n:]
+
+		var req cloudflare.PurgeCacheRequest
+		switch kind {
+		case "url":
+			req.Files = batch
+		case "tag":
+			req.Tags = batch
+		case "host":
+			req.Hosts = batch
+		case "prefix":
+			req.Prefixes = batch
+		default:
+			return usageError(c)
+		}
+
+		if _, err := api.PurgeCache(ctx, zoneID.Identifier, req); err != nil {
+			return err
+		}
+		for _, item := range batch {
+			printf("Purged %s.\n", item)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,237 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// recordFields maps the field names accepted by the get command to
+// functions extracting the field's value from a DNS record.
+var recordFields = map[string]func(r cloudflare.DNSRecord) string{
+	"id":      func(r cloudflare.DNSRecord) string { return r.ID },
+	"type":    func(r cloudflare.DNSRecord) string { return r.Type },
+	"name":    func(r cloudflare.DNSRecord) string { return r.Name },
+	"content": func(r cloudflare.DNSRecord) string { return r.Content },
+	"ttl":     func(r cloudflare.DNSRecord) string { return formatTTL(r.TTL) },
+	"comment": func(r cloudflare.DNSRecord) string { return r.Comment },
+	"proxied": func(r cloudflare.DNSRecord) string {
+		return strconv.FormatBool(r.Proxied != nil && *r.Proxied)
+	},
+	"priority": func(r cloudflare.DNSRecord) string {
+		if r.Priority == nil {
+			return ""
+		}
+		return strconv.Itoa(int(*r.Priority))
+	},
+}
+
+var getFlags = registerFlags("get", flagSpec{"field": true, "compare": false})
+
+func cmdGet(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, getFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 || (flags.has("compare") && flags.has("field")) {
+		return usageError(c)
+	}
+
+	field := strings.ToLower(flags.get("field", "content"))
+	value, ok := recordFields[field]
+	if !ok {
+		return argError(fmt.Errorf("unknown field %q", field))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	params := cloudflare.ListDNSRecordsParams{
+		Type: strings.ToUpper(args[0]),
+		Name: args[1],
+	}
+	recs, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+	if flags.has("compare") {
+		return comparePublicDNS(params.Type, params.Name, recs)
+	}
+
+	switch len(recs) {
+	case 0:
+		return errNoMatch
+	case 1:
+		fmt.Fprintln(output, value(recs[0]))
+		return nil
+	default:
+		return fmt.Errorf("%d records match; expected exactly one", len(recs))
+	}
+}
+
+func cmdExists(c *cmd.Command, args []string) error {
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	params := cloudflare.ListDNSRecordsParams{
+		Type: strings.ToUpper(args[0]),
+		Name: args[1],
+	}
+	recs, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+
+	if len(recs) == 0 {
+		return errNotExist
+	}
+	return nil
+}
+
+// A recordDetails holds every field of a DNS record, including those the
+// API returns but the cloudflare package does not decode.
+type recordDetails struct {
+	cloudflare.DNSRecord
+	Locked bool   `json:"locked"`
+	ZoneID string `json:"zone_id"`
+}
+
+var showFlags = registerFlags("show", flagSpec{"id": true})
+
+func cmdShow(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, showFlags)
+	if err != nil {
+		return err
+	}
+	if flags.has("id") != (len(args) == 0) || (len(args) != 0 && len(args) != 2) {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	var ids []string
+	if flags.has("id") {
+		ids = append(ids, flags.get("id", ""))
+	} else {
+		params := cloudflare.ListDNSRecordsParams{
+			Type: strings.ToUpper(args[0]),
+			Name: args[1],
+		}
+		recs, err := listRecords(api, zoneID, params)
+		if err != nil {
+			return err
+		}
+		if len(recs) == 0 {
+			return errNoMatch
+		}
+		for _, r := range recs {
+			ids = append(ids, r.ID)
+		}
+	}
+
+	var details []recordDetails
+	for _, id := range ids {
+		d, err := getRecordDetails(api, zoneID, id)
+		if err != nil {
+			return err
+		}
+		details = append(details, d)
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(details)
+	}
+
+	for i, d := range details {
+		if i > 0 {
+			fmt.Fprintln(output)
+		}
+		displayRecordDetails(d)
+	}
+	return nil
+}
+
+// getRecordDetails returns every field of the record with the requested
+// ID. A record that does not exist results in errNoMatch.
+func getRecordDetails(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, id string) (recordDetails, error) {
+	var d recordDetails
+	resp, err := api.Raw(commandCtx, http.MethodGet,
+		"/zones/"+zoneID.Identifier+"/dns_records/"+url.PathEscape(id), nil, nil)
+	if isNotFound(err) {
+		return d, errNoMatch
+	}
+	if err != nil {
+		return d, err
+	}
+	if err := json.Unmarshal(resp.Result, &d); err != nil {
+		return d, err
+	}
+	if d.ZoneID == "" {
+		d.ZoneID = zoneID.Identifier
+	}
+	return d, nil
+}
+
+func displayRecordDetails(d recordDetails) {
+	r := d.DNSRecord
+	priority := "-"
+	if r.Priority != nil {
+		priority = strconv.Itoa(int(*r.Priority))
+	}
+	comment := r.Comment
+	if comment == "" {
+		comment = "-"
+	}
+	printf("ID:        %s\n", r.ID)
+	printf("Type:      %s\n", r.Type)
+	printf("Name:      %s\n", r.Name)
+	printf("Content:   %s\n", r.Content)
+	printf("TTL:       %s\n", formatTTL(r.TTL))
+	printf("Proxied:   %s\n", onOff(r.Proxied != nil && *r.Proxied))
+	printf("Priority:  %s\n", priority)
+	printf("Locked:    %s\n", onOff(d.Locked))
+	printf("Comment:   %s\n", comment)
+	printf("Tags:      %s\n", formatTags(r.Tags))
+	printf("Created:   %s\n", r.CreatedOn.Local().Format(time.RFC3339))
+	printf("Modified:  %s\n", r.ModifiedOn.Local().Format(time.RFC3339))
+	printf("Zone ID:   %s\n", d.ZoneID)
+}
@@ -0,0 +1,139 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// defaultPageSize is the default number of DNS records requested per page.
+const defaultPageSize = cflib.DefaultPageSize
+
+// minPageSize is the smallest page size accepted by the API.
+const minPageSize = 5
+
+// pageSize is the number of DNS records requested per page when listing
+// records.
+var pageSize = defaultPageSize
+
+// parsePageSize validates a page size argument.
+func parsePageSize(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < minPageSize {
+		return 0, argError(fmt.Errorf("page size must be a number no less than %d", minPageSize))
+	}
+	return n, nil
+}
+
+// parseRecordFilter parses the optional type and name pattern arguments
+// selecting records. A type of * matches all types. A name containing the
+// wildcards *, ? or [...] is returned as a matcher; other names are matched
+// by the API.
+func parseRecordFilter(filter []string) (cloudflare.ListDNSRecordsParams, matcher, error) {
+	var params cloudflare.ListDNSRecordsParams
+	var matchName matcher
+	if len(filter) > 0 && filter[0] != "*" {
+		params.Type = strings.ToUpper(filter[0])
+	}
+	if len(filter) > 1 {
+		if strings.ContainsAny(filter[1], "*?[") {
+			var err error
+			if matchName, err = newMatcher(filter[1], false); err != nil {
+				return params, nil, err
+			}
+		} else {
+			params.Name = filter[1]
+		}
+	}
+	return params, matchName, nil
+}
+
+// listRecords returns all DNS records in a zone matching the type, name and
+// content of params.
+func listRecords(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, error) {
+
+	return newClient(api, zoneID).ListRecords(commandCtx, params)
+}
+
+// backend, when not nil, replaces the Cloudflare API for zone lookups and
+// record operations. Tests set it to an in-memory backend.
+var backend cflib.Backend
+
+// recordBackend returns the backend for zone lookups and record operations
+// made through api. Outside of dry-run mode, the changes it makes are
+// recorded in the journal. With a staging zone set, they are also made in,
+// or made only in, the staging zone.
+func recordBackend(api *cloudflare.API) cflib.Backend {
+	b := baseBackend(api)
+	if !dryRun {
+		b = journalBackend{b}
+	}
+	b = protectBackend{b}
+	if shadowZone != "" {
+		b = shadowBackend{b}
+	}
+	return b
+}
+
+// baseBackend returns the backend for record operations made through api
+// that are not recorded in the journal.
+func baseBackend(api *cloudflare.API) cflib.Backend {
+	switch {
+	case backend != nil:
+		return knownZoneBackend{backend}
+	case offline:
+		return replicaBackend{}
+	}
+	return knownZoneBackend{cflib.NewAPIBackend(api)}
+}
+
+// newClient returns a cflib client for a zone, configured with the page
+// size and warning output of the current session.
+func newClient(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) *cflib.Client {
+	client := cflib.NewWithBackend(recordBackend(api), zoneID.Identifier)
+	client.PageSize = pageSize
+	client.DefaultTTL = defaultTTL
+	client.Warnf = printf
+	return client
+}
+
+// parseWindow returns the number of records to display and the page to
+// display, requested by the --limit and --page flags. A limit of 0 displays
+// all records. Without --limit, pages hold pageSize records.
+func parseWindow(flags flagValues) (limit, page int, err error) {
+	page = 1
+	if flags.has("page") {
+		page, err = strconv.Atoi(flags.get("page", ""))
+		if err != nil || page < 1 {
+			return 0, 0, argError(errors.New("page must be a positive number"))
+		}
+		limit = pageSize
+	}
+	if flags.has("limit") {
+		limit, err = strconv.Atoi(flags.get("limit", ""))
+		if err != nil || limit < 1 {
+			return 0, 0, argError(errors.New("limit must be a positive number"))
+		}
+	}
+	return limit, page, nil
+}
+
+// window returns the requested page of records, where each page holds
+// limit records. A limit of 0 returns all records.
+func window(recs []zoneRecord, limit, page int) []zoneRecord {
+	if limit == 0 {
+		return recs
+	}
+	start := min((page-1)*limit, len(recs))
+	end := min(start+limit, len(recs))
+	return recs[start:end]
+}
@@ -0,0 +1,127 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"net/netip"
+	"os"
+	"regexp"
+	"strings"
+	"sync/atomic"
+)
+
+// stdout is the process's standard output. os.Stdout is replaced by a pipe
+// while output is redacted, so programs needing the terminal, such as the
+// editor, are given this instead.
+var stdout = os.Stdout
+
+// redactOutput is set while output is redacted, by --redact or "set redact".
+var redactOutput atomic.Bool
+
+// redactDone is closed when the redacting goroutine has written all
+// output, once output has been redirected through it.
+var redactDone chan struct{}
+
+var (
+	ipv4Pattern   = regexp.MustCompile(`\b\d{1,3}\.\d{1,3}\.\d{1,3}\.\d{1,3}\b`)
+	ipv6Pattern   = regexp.MustCompile(`[0-9A-Fa-f]{0,4}(:[0-9A-Fa-f]{0,4}){2,7}`)
+	tokenPattern  = regexp.MustCompile(`[A-Za-z0-9_+/-]{36,}={0,2}`)
+	secretPattern = regexp.MustCompile(`=([A-Za-z0-9_+/-]{4})[A-Za-z0-9_+/-]{12,}={0,2}`)
+)
+
+// startRedacting redirects standard output through a goroutine masking IP
+// addresses, tokens and the secrets of TXT records, such as verification
+// tokens and DKIM keys, so that the output can be shown on a shared screen.
+// Output written by a single call is redacted as a whole.
+func startRedacting() error {
+	redactOutput.Store(true)
+	if redactDone != nil {
+		return nil
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	os.Stdout = w
+	redactDone = make(chan struct{})
+
+	go func() {
+		defer close(redactDone)
+		buf := make([]byte, 64*1024)
+		var pending []byte
+		for {
+			n, err := r.Read(buf)
+			data := append(pending, buf[:n]...)
+			pending = nil
+
+			// A full buffer may end within an address or token; hold
+			// back its last word until the rest of it is read.
+			if n == len(buf) {
+				if i := bytes.LastIndexAny(data, " \t\r\n"); i >= 0 {
+					data, pending = data[:i+1], bytes.Clone(data[i+1:])
+				}
+			}
+			if len(data) > 0 {
+				if redactOutput.Load() {
+					data = []byte(redactText(string(data)))
+				}
+				stdout.Write(data)
+			}
+			if err != nil {
+				stdout.Write(pending)
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// stopRedacting waits for all redirected output to be written.
+func stopRedacting() {
+	if redactDone == nil {
+		return
+	}
+	os.Stdout.Close()
+	<-redactDone
+	os.Stdout, redactDone = stdout, nil
+}
+
+// exit exits with a code once all output has been written.
+func exit(code int) {
+	stopRedacting()
+	os.Exit(code)
+}
+
+// redactText masks the IP addresses in text, keeping the first half of
+// each, and the tokens and secret values, keeping their first characters.
+func redactText(s string) string {
+	s = secretPattern.ReplaceAllString(s, "=${1}…")
+	s = tokenPattern.ReplaceAllStringFunc(s, func(t string) string {
+		if !strings.ContainsAny(t, "0123456789") || strings.Trim(t, "-_") != t {
+			return t
+		}
+		return t[:4] + "…"
+	})
+	s = ipv4Pattern.ReplaceAllStringFunc(s, func(a string) string {
+		if _, err := netip.ParseAddr(a); err != nil {
+			return a
+		}
+		f := strings.Split(a, ".")
+		return f[0] + "." + f[1] + ".x.x"
+	})
+	s = ipv6Pattern.ReplaceAllStringFunc(s, func(a string) string {
+		if ip, err := netip.ParseAddr(a); err != nil || !ip.Is6() {
+			return a
+		}
+		f := strings.SplitN(a, ":", 3)
+		if f[0] == "" || f[1] == "" {
+			return a[:strings.Index(a, "::")+2] + "x"
+		}
+		return f[0] + ":" + f[1] + ":x"
+	})
+	return s
+}
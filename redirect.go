@@ -0,0 +1,225 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// redirectPhase is the ruleset phase holding a zone's redirect rules.
+const redirectPhase = string(cloudflare.RulesetPhaseHTTPRequestDynamicRedirect)
+
+// wildcardRef matches the $1, $2, ... references in a redirect target.
+var wildcardRef = regexp.MustCompile(`\$(\d+)`)
+
+func cmdRedirect(c *cmd.Command, args []string) error {
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		return listRedirects()
+	case "add":
+		if len(args) < 3 || len(args) > 4 {
+			return usageError(c)
+		}
+		status := 301
+		if len(args) == 4 {
+			var err error
+			status, err = strconv.Atoi(args[3])
+			if err != nil || (status != 301 && status != 302 && status != 307 && status != 308) {
+				return argError(fmt.Errorf("redirect status must be 301, 302, 307 or 308"))
+			}
+		}
+		return addRedirect(args[1], args[2], status)
+	case "delete":
+		if len(args) != 2 {
+			return usageError(c)
+		}
+		return deleteRedirect(args[1])
+	default:
+		return usageError(c)
+	}
+}
+
+// getRedirectRuleset returns the zone's redirect rules ruleset. A zone
+// without any redirect rules has no ruleset, and an empty one is returned.
+func getRedirectRuleset(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) (cloudflare.Ruleset, error) {
+	rs, err := api.GetEntrypointRuleset(commandCtx, zoneID, redirectPhase)
+	if err != nil && !isNotFound(err) {
+		return rs, err
+	}
+	return rs, nil
+}
+
+func listRedirects() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	rs, err := getRedirectRuleset(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	if len(rs.Rules) == 0 {
+		printf("No redirect rules defined.\n")
+		return nil
+	}
+	for i, r := range rs.Rules {
+		status := ""
+		if r.Enabled != nil && !*r.Enabled {
+			status = " (disabled)"
+		}
+		desc := r.Description
+		if desc == "" {
+			desc = r.Expression
+		}
+		fmt.Fprintf(output, "%2d  %s%s  [%s]\n", i+1, desc, status, r.ID)
+	}
+	return nil
+}
+
+func addRedirect(source, target string, status int) error {
+	rule := redirectRule(source, target, status)
+	if err := checkExpression(rule.Expression); err != nil {
+		return argError(fmt.Errorf("invalid redirect pattern %q: %v", source, err))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	rs, err := getRedirectRuleset(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	// Existing rules are resubmitted with only the fields the API accepts
+	// on update.
+	var rules []cloudflare.RulesetRule
+	for _, r := range rs.Rules {
+		rules = append(rules, cloudflare.RulesetRule{
+			ID:               r.ID,
+			Action:           r.Action,
+			ActionParameters: r.ActionParameters,
+			Expression:       r.Expression,
+			Description:      r.Description,
+			Enabled:          r.Enabled,
+		})
+	}
+	rules = append(rules, rule)
+
+	_, err = api.UpdateEntrypointRuleset(commandCtx, zoneID,
+		cloudflare.UpdateEntrypointRulesetParams{Phase: redirectPhase, Rules: rules})
+	if err != nil {
+		return err
+	}
+
+	printf("Redirect rule added.\n")
+	return nil
+}
+
+// redirectRule builds a redirect rule from a page rule style URL pattern,
+// such as old.example.com/*, and a target URL whose $1, $2, ... references
+// are replaced by the text matched by the pattern's wildcards.
+func redirectRule(source, target string, status int) cloudflare.RulesetRule {
+	pattern := source
+	shift := 0
+	if !strings.Contains(pattern, "://") {
+		// Match both http and https. The scheme wildcard becomes ${1}, so
+		// the user's references are shifted by one.
+		pattern = "*://" + pattern
+		shift = 1
+	}
+
+	replacement := wildcardRef.ReplaceAllStringFunc(target, func(ref string) string {
+		n, _ := strconv.Atoi(ref[1:])
+		return "${" + strconv.Itoa(n+shift) + "}"
+	})
+
+	enabled := true
+	preserveQuery := false
+	return cloudflare.RulesetRule{
+		Action:      string(cloudflare.RulesetRuleActionRedirect),
+		Expression:  fmt.Sprintf("http.request.full_uri wildcard %q", pattern),
+		Description: fmt.Sprintf("%s -> %s (%d)", source, target, status),
+		Enabled:     &enabled,
+		ActionParameters: &cloudflare.RulesetRuleActionParameters{
+			FromValue: &cloudflare.RulesetRuleActionParametersFromValue{
+				StatusCode: uint16(status),
+				TargetURL: cloudflare.RulesetRuleActionParametersTargetURL{
+					Expression: fmt.Sprintf("wildcard_replace(http.request.full_uri, %q, %q)",
+						pattern, replacement),
+				},
+				PreserveQueryString: &preserveQuery,
+			},
+		},
+	}
+}
+
+// deleteRedirect deletes a redirect rule identified by its position in the
+// list, or by its rule ID.
+func deleteRedirect(which string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	rs, err := getRedirectRuleset(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	ruleID := ""
+	if n, err := strconv.Atoi(which); err == nil && n >= 1 && n <= len(rs.Rules) {
+		ruleID = rs.Rules[n-1].ID
+	} else {
+		for _, r := range rs.Rules {
+			if r.ID == which {
+				ruleID = r.ID
+			}
+		}
+	}
+	if ruleID == "" {
+		return fmt.Errorf("redirect rule %s not found", which)
+	}
+
+	err = api.DeleteRulesetRule(commandCtx, zoneID,
+		cloudflare.DeleteRulesetRuleParams{RulesetID: rs.ID, RulesetRuleID: ruleID})
+	if err != nil {
+		return err
+	}
+
+	printf("Redirect rule deleted.\n")
+	return nil
+}
@@ -0,0 +1,136 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var replaceIPFlags = registerFlags("replace-ip", mergeFlags(zoneFlags, forceFlags))
+
+// cmdReplaceIP points every address record holding an old address at a new
+// one, in the active zone or, with --all-zones, in every zone. The records
+// to update are listed and confirmed first, unless --force is given.
+func cmdReplaceIP(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, replaceIPFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	oldAddr, err := netip.ParseAddr(args[0])
+	if err != nil {
+		return argError(fmt.Errorf("%q is not a valid IP address", args[0]))
+	}
+	newAddr, err := netip.ParseAddr(args[1])
+	if err != nil {
+		return argError(fmt.Errorf("%q is not a valid IP address", args[1]))
+	}
+	oldAddr, newAddr = oldAddr.Unmap(), newAddr.Unmap()
+	if oldAddr.Is4() != newAddr.Is4() {
+		return argError(errors.New("the old and new addresses must both be IPv4 or both be IPv6"))
+	}
+	recType := "A"
+	if oldAddr.Is6() {
+		recType = "AAAA"
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	all, err := listZoneRecords(api, zones, cloudflare.ListDNSRecordsParams{Type: recType})
+	if err != nil {
+		return err
+	}
+
+	var recs []zoneRecord
+	for _, r := range all {
+		if addr, err := netip.ParseAddr(r.Content); err == nil && addr.Unmap() == oldAddr {
+			recs = append(recs, r)
+		}
+	}
+	if len(recs) == 0 {
+		return errNoMatch
+	}
+
+	var ops []operation
+	changed := make(map[string]bool)
+	for _, r := range recs {
+		zoneID := r.zoneID
+		comment := r.Comment
+		params := cloudflare.UpdateDNSRecordParams{
+			Type:    r.Type,
+			Name:    r.Name,
+			Content: newAddr.String(),
+			ID:      r.ID,
+			TTL:     r.TTL,
+			Proxied: r.Proxied,
+			Comment: &comment,
+			Tags:    r.Tags,
+		}
+		ops = append(ops, operation{
+			key: recordKey(zoneID.Identifier, r.ID),
+			fn: func() error {
+				_, err := recordBackend(api).UpdateDNSRecord(commandCtx, zoneID.Identifier, params)
+				return err
+			},
+			change: updateChange(zoneID.Identifier, r.zone, params),
+		})
+		changed[r.zone] = true
+	}
+
+	printf("The following records will be changed from %s to %s:\n", oldAddr, newAddr)
+	for _, r := range recs {
+		fmt.Fprintf(output, "    %s %s %s (ID %s)\n", r.zone, r.Type, r.Name, r.ID)
+	}
+	if !flags.force() {
+		var protected []string
+		for _, r := range recs {
+			if isProtected(r.zone, r.Type, r.Name) {
+				protected = append(protected, r.Type+" "+r.Name)
+			}
+		}
+		if err := protectedError("update", protected); err != nil {
+			return err
+		}
+		if !confirm(sprintf("Change %d record(s)?", len(recs))) {
+			printf("No records changed.\n")
+			return nil
+		}
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		r := recs[i]
+		if err != nil {
+			printf("Error updating %s: %v\n", r.Name, err)
+			failed++
+			continue
+		}
+		printf("Updated %s record %s.\n", r.Type, r.Name)
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be updated", failed, len(recs))
+	}
+	printf("%d record(s) in %d zone(s) changed from %s to %s.\n", len(recs), len(changed), oldAddr, newAddr)
+	return nil
+}
@@ -0,0 +1,269 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// offline is set by --offline. In offline mode no API requests are made:
+// zone lookups and record lists are answered from the replicas saved by
+// "zone pull", and everything else fails with errOffline.
+var offline bool
+
+var errOffline = errors.New("not available offline; only the records of zones saved with \"zone pull\" can be read")
+
+// A zoneReplica is a local copy of a zone's records, settings and rules,
+// saved by "zone pull" for reading in offline mode.
+type zoneReplica struct {
+	Zone     string                 `json:"zone"`
+	ZoneID   string                 `json:"zone_id"`
+	Pulled   time.Time              `json:"pulled"`
+	Records  []cloudflare.DNSRecord `json:"records"`
+	Settings settingsSnapshot       `json:"settings"`
+}
+
+// replicaPath returns the path of the replica of a zone in the state
+// directory.
+func replicaPath(zone string) (string, error) {
+	return statePath("replicas", cflib.NormalizeName(zone)+".json")
+}
+
+func readReplica(path string) (*zoneReplica, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var r zoneReplica
+	if err := json.Unmarshal(data, &r); err != nil || r.ZoneID == "" {
+		return nil, fmt.Errorf("%s is not a zone replica", path)
+	}
+	return &r, nil
+}
+
+// loadReplica returns the replica of a zone.
+func loadReplica(zone string) (*zoneReplica, error) {
+	path, err := replicaPath(zone)
+	if err != nil {
+		return nil, err
+	}
+	r, err := readReplica(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("no replica of zone %s; run \"zone pull\" while online", cflib.NormalizeName(zone))
+	}
+	return r, err
+}
+
+// loadReplicas returns every replica in the state directory, sorted by
+// zone name.
+func loadReplicas() ([]*zoneReplica, error) {
+	dir, err := statePath("replicas")
+	if err != nil {
+		return nil, err
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	var replicas []*zoneReplica
+	for _, path := range paths {
+		r, err := readReplica(path)
+		if err != nil {
+			return nil, err
+		}
+		replicas = append(replicas, r)
+	}
+	sort.Slice(replicas, func(i, j int) bool { return replicas[i].Zone < replicas[j].Zone })
+	return replicas, nil
+}
+
+// replicaByID returns the replica of the zone with an ID.
+func replicaByID(zoneID string) (*zoneReplica, error) {
+	replicas, err := loadReplicas()
+	if err != nil {
+		return nil, err
+	}
+	for _, r := range replicas {
+		if r.ZoneID == zoneID {
+			return r, nil
+		}
+	}
+	return nil, fmt.Errorf("no replica of zone %s; run \"zone pull\" while online", zoneID)
+}
+
+// replicaZones returns the zones with replicas, for --all-zones in offline
+// mode.
+func replicaZones() ([]zoneTarget, error) {
+	replicas, err := loadReplicas()
+	if err != nil {
+		return nil, err
+	}
+	if len(replicas) == 0 {
+		return nil, zoneError(errors.New("no zone replicas found; run \"zone pull\" while online"))
+	}
+	var targets []zoneTarget
+	for _, r := range replicas {
+		targets = append(targets, zoneTarget{r.Zone, cloudflare.ZoneIdentifier(r.ZoneID)})
+	}
+	return targets, nil
+}
+
+// replicaBackend is the read-only backend of offline mode, answering zone
+// lookups and record lists from the replicas saved by "zone pull".
+type replicaBackend struct{}
+
+func (replicaBackend) ZoneIDByName(name string) (string, error) {
+	r, err := loadReplica(name)
+	if err != nil {
+		return "", err
+	}
+	return r.ZoneID, nil
+}
+
+func (replicaBackend) ListDNSRecords(ctx context.Context, zoneID string,
+	params cloudflare.ListDNSRecordsParams) ([]cloudflare.DNSRecord, error) {
+
+	r, err := replicaByID(zoneID)
+	if err != nil {
+		return nil, err
+	}
+	recs := []cloudflare.DNSRecord{}
+	for _, rec := range r.Records {
+		switch {
+		case params.Type != "" && !strings.EqualFold(rec.Type, params.Type):
+		case params.Name != "" && cflib.NormalizeName(rec.Name) != cflib.NormalizeName(params.Name):
+		case params.Content != "" && rec.Content != params.Content:
+		default:
+			recs = append(recs, rec)
+		}
+	}
+	return recs, nil
+}
+
+func (replicaBackend) GetDNSRecord(ctx context.Context, zoneID, id string) (cloudflare.DNSRecord, error) {
+	r, err := replicaByID(zoneID)
+	if err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+	for _, rec := range r.Records {
+		if rec.ID == id {
+			return rec, nil
+		}
+	}
+	return cloudflare.DNSRecord{}, fmt.Errorf("record %s is not in the replica of zone %s", id, r.Zone)
+}
+
+func (replicaBackend) CreateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.CreateDNSRecordParams) (cloudflare.DNSRecord, error) {
+	return cloudflare.DNSRecord{}, errOffline
+}
+
+func (replicaBackend) UpdateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
+	return cloudflare.DNSRecord{}, errOffline
+}
+
+func (replicaBackend) DeleteDNSRecord(ctx context.Context, zoneID, id string) error {
+	return errOffline
+}
+
+// An offlineTransport refuses every API request, so that commands other
+// than those reading records from replicas fail in offline mode instead of
+// waiting for a network that is not there.
+type offlineTransport struct{}
+
+func (offlineTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return nil, errOffline
+}
+
+var zonePullFlags = registerFlags("zone pull", zoneFlags)
+
+// cmdZonePull saves a replica of the records, settings and rules of the
+// active zone, the zone selected with --zone, or every zone with
+// --all-zones, for reading in offline mode.
+func cmdZonePull(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, zonePullFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+	if offline {
+		return errOffline
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	refreshResponses()
+	failed := 0
+	for _, z := range zones {
+		if commandCtx.Err() != nil {
+			return errInterrupted
+		}
+		path, err := pullZone(api, z)
+		if err != nil {
+			printf("Error pulling zone %s: %v\n", z.name, err)
+			failed++
+			continue
+		}
+		printf("Pulled zone %s to %s.\n", z.name, path)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d zone(s) not pulled", failed, len(zones))
+	}
+	return nil
+}
+
+// pullZone saves the replica of a zone, returning its path. Settings and
+// rules the credentials cannot read are left out of the replica, with a
+// note.
+func pullZone(api *cloudflare.API, z zoneTarget) (string, error) {
+	recs, err := listRecords(api, z.id, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return "", err
+	}
+	r := zoneReplica{
+		Zone:     cflib.NormalizeName(z.name),
+		ZoneID:   z.id.Identifier,
+		Pulled:   time.Now().UTC(),
+		Records:  recs,
+		Settings: takeSettingsSnapshot(api, z.name, z.id.Identifier),
+	}
+	for _, n := range r.Settings.Notes {
+		printf("Warning: %s.\n", n)
+	}
+
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	path, err := replicaPath(z.name)
+	if err != nil {
+		return "", err
+	}
+	return path, writeStateFile(path, append(data, '\n'))
+}
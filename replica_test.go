@@ -0,0 +1,77 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestOfflineReplica(t *testing.T) {
+	t.Setenv("CLOUDFLARE_STATE_DIR", t.TempDir())
+	t.Setenv("CLOUDFLARE_ZONE", "example.com")
+	defer func() {
+		offline, activeAPI = false, nil
+		activeZoneIdentifier, activeZoneName = nil, ""
+	}()
+
+	r := zoneReplica{
+		Zone:   "example.com",
+		ZoneID: "z1",
+		Records: []cloudflare.DNSRecord{
+			{ID: "r1", Type: "A", Name: "www.example.com", Content: "203.0.113.7"},
+			{ID: "r2", Type: "TXT", Name: "example.com", Content: "v=spf1 -all"},
+		},
+	}
+	data, _ := json.Marshal(r)
+	path, err := replicaPath("example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeStateFile(path, data); err != nil {
+		t.Fatal(err)
+	}
+
+	offline = true
+	api, err := getAPI()
+	if err != nil {
+		t.Fatal(err)
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zoneID.Identifier != "z1" {
+		t.Errorf("zone ID = %s, want z1", zoneID.Identifier)
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: "a", Name: "WWW.example.com."})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(recs) != 1 || recs[0].ID != "r1" {
+		t.Errorf("records = %v, want r1", recs)
+	}
+
+	flags, _, _ := parseFlags([]string{"--all-zones"}, zoneFlags)
+	zones, err := selectZones(api, flags)
+	if err != nil || len(zones) != 1 || zones[0].name != "example.com" {
+		t.Errorf("all zones = %v, %v", zones, err)
+	}
+
+	if err := recordBackend(api).DeleteDNSRecord(context.Background(), "z1", "r1"); !errors.Is(err, errOffline) {
+		t.Errorf("delete: err = %v", err)
+	}
+	if _, err := api.ListZones(context.Background()); err == nil {
+		t.Error("API request made offline")
+	}
+	if _, err := recordBackend(api).ZoneIDByName("example.org"); err == nil {
+		t.Error("zone without a replica found")
+	}
+}
@@ -0,0 +1,259 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// An inventory describes every zone of an account and its records.
+type inventory struct {
+	Generated time.Time       `json:"generated"`
+	Zones     []inventoryZone `json:"zones"`
+}
+
+type inventoryZone struct {
+	Name    string            `json:"name"`
+	ID      string            `json:"id"`
+	Plan    string            `json:"plan"`
+	Status  string            `json:"status"`
+	DNSSEC  string            `json:"dnssec"`
+	Records []inventoryRecord `json:"records"`
+}
+
+type inventoryRecord struct {
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Content string   `json:"content"`
+	TTL     int      `json:"ttl"`
+	Proxied bool     `json:"proxied"`
+	Comment string   `json:"comment,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+var reportFlags = registerFlags("report", flagSpec{"format": true, "output": true, "since": true})
+
+func cmdReport(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, reportFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) == 0 {
+		return usageError(c)
+	}
+
+	format := flags.get("format", "json")
+	switch format {
+	case "json", "csv", "html":
+	default:
+		return argError(fmt.Errorf("invalid report format %q", format))
+	}
+
+	var write func(w io.Writer) error
+	var done func(path string)
+	switch {
+	case len(args) == 1 && args[0] == "inventory" && !flags.has("since"):
+		api, err := getAPI()
+		if err != nil {
+			return err
+		}
+		inv, err := takeInventory(api)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error { return writeInventory(w, inv, format) }
+		done = func(path string) {
+			printf("Wrote inventory of %d zone(s) to %s.\n", len(inv.Zones), path)
+		}
+
+	case (len(args) == 1 || len(args) == 2) && args[0] == "access":
+		since, err := parseAge(flags.get("since", "30d"))
+		if err != nil {
+			return argError(err)
+		}
+		api, err := getAPI()
+		if err != nil {
+			return err
+		}
+		zone := ""
+		if len(args) == 2 {
+			zone = args[1]
+		} else {
+			if _, err := getZoneIdentifier(); err != nil {
+				return err
+			}
+			zone = activeZoneName
+		}
+		rep, err := takeAccessReport(api, zone, since)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error { return writeAccessReport(w, rep, format) }
+		done = func(path string) {
+			printf("Wrote access report of zone %s to %s.\n", rep.Zone, path)
+		}
+
+	case (len(args) == 1 || len(args) == 2) && args[0] == "egress" && !flags.has("since"):
+		api, err := getAPI()
+		if err != nil {
+			return err
+		}
+		zone := ""
+		if len(args) == 2 {
+			zone = args[1]
+		} else {
+			if _, err := getZoneIdentifier(); err != nil {
+				return err
+			}
+			zone = activeZoneName
+		}
+		rep, err := takeEgressReport(api, zone)
+		if err != nil {
+			return err
+		}
+		write = func(w io.Writer) error { return writeEgressReport(w, rep, format) }
+		done = func(path string) {
+			printf("Wrote egress report of zone %s to %s.\n", rep.Zone, path)
+		}
+
+	default:
+		return usageError(c)
+	}
+
+	var w io.Writer = output
+	if flags.has("output") {
+		f, err := os.Create(flags.get("output", ""))
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = f
+	}
+	if err := write(w); err != nil {
+		return err
+	}
+
+	if flags.has("output") {
+		done(flags.get("output", ""))
+	}
+	return nil
+}
+
+// takeInventory collects the zones of the account and their records.
+func takeInventory(api *cloudflare.API) (inventory, error) {
+	inv := inventory{Generated: time.Now().UTC()}
+
+	zones, err := api.ListZones(commandCtx)
+	if err != nil {
+		return inv, err
+	}
+
+	for _, z := range zones {
+		if commandCtx.Err() != nil {
+			return inv, errInterrupted
+		}
+
+		dnssec, err := api.ZoneDNSSECSetting(commandCtx, z.ID)
+		if err != nil {
+			return inv, fmt.Errorf("zone %s: %v", z.Name, err)
+		}
+		recs, err := listRecords(api, cloudflare.ZoneIdentifier(z.ID), cloudflare.ListDNSRecordsParams{})
+		if err != nil {
+			return inv, fmt.Errorf("zone %s: %v", z.Name, err)
+		}
+
+		iz := inventoryZone{
+			Name:    z.Name,
+			ID:      z.ID,
+			Plan:    z.Plan.Name,
+			Status:  z.Status,
+			DNSSEC:  dnssec.Status,
+			Records: []inventoryRecord{},
+		}
+		for _, r := range recs {
+			iz.Records = append(iz.Records, inventoryRecord{
+				Type:    r.Type,
+				Name:    r.Name,
+				Content: r.Content,
+				TTL:     r.TTL,
+				Proxied: isProxied(r),
+				Comment: r.Comment,
+				Tags:    r.Tags,
+			})
+		}
+		inv.Zones = append(inv.Zones, iz)
+	}
+	return inv, nil
+}
+
+// writeInventory writes an inventory as JSON, as CSV with a row for each
+// record, or as an HTML page with a table for each zone.
+func writeInventory(w io.Writer, inv inventory, format string) error {
+	switch format {
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"zone", "plan", "status", "dnssec", "type", "name", "content", "ttl", "proxied", "comment", "tags"})
+		for _, z := range inv.Zones {
+			zone := []string{z.Name, z.Plan, z.Status, z.DNSSEC}
+			if len(z.Records) == 0 {
+				cw.Write(append(zone, "", "", "", "", "", "", ""))
+			}
+			for _, r := range z.Records {
+				cw.Write(append(zone, r.Type, r.Name, r.Content, strconv.Itoa(r.TTL),
+					strconv.FormatBool(r.Proxied), r.Comment, strings.Join(r.Tags, " ")))
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+
+	case "html":
+		return inventoryTemplate.Execute(w, inv)
+
+	default:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(inv)
+	}
+}
+
+var inventoryTemplate = template.Must(template.New("inventory").Funcs(template.FuncMap{
+	"ttl": formatTTL,
+}).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>DNS inventory</title>
+<style>
+body { font-family: sans-serif; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.2em 0.5em; text-align: left; vertical-align: top; }
+td.content { font-family: monospace; word-break: break-all; }
+</style>
+</head>
+<body>
+<h1>DNS inventory</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05 UTC"}}, {{len .Zones}} zone(s).</p>
+{{range .Zones}}
+<h2>{{.Name}}</h2>
+<p>Plan: {{.Plan}} &middot; Status: {{.Status}} &middot; DNSSEC: {{.DNSSEC}} &middot; {{len .Records}} record(s)</p>
+{{if .Records}}<table>
+<tr><th>Type</th><th>Name</th><th>Content</th><th>TTL</th><th>Proxied</th><th>Comment</th></tr>
+{{range .Records}}<tr><td>{{.Type}}</td><td>{{.Name}}</td><td class="content">{{.Content}}</td><td>{{ttl .TTL}}</td><td>{{if .Proxied}}yes{{else}}no{{end}}</td><td>{{.Comment}}</td></tr>
+{{end}}</table>{{end}}
+{{end}}
+</body>
+</html>
+`))
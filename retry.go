@@ -0,0 +1,177 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default retry settings for failed API requests.
+const (
+	defaultRetries    = 3
+	defaultRetryDelay = time.Second
+	maxRetryDelay     = 30 * time.Second
+)
+
+// retries is the number of times a failed API request is retried, and
+// retryDelay the delay before the first retry. Each further retry waits
+// twice as long as the one before, up to maxRetryDelay.
+var (
+	retries    = defaultRetries
+	retryDelay = defaultRetryDelay
+)
+
+// parseRetries validates a retry count argument.
+func parseRetries(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, argError(fmt.Errorf("invalid retry count %q", s))
+	}
+	return n, nil
+}
+
+// parseRetryDelay validates a retry delay argument.
+func parseRetryDelay(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, argError(fmt.Errorf("invalid retry delay %q", s))
+	}
+	return d, nil
+}
+
+// A retryTransport sends API requests through the underlying transport,
+// limiting each attempt to requestTimeout and retrying attempts that fail
+// for transient reasons. Requests refused with 429 Too Many Requests or 503
+// Service Unavailable were not processed, so they are always retried. Other
+// server errors and network failures are retried only for idempotent
+// requests, since the server may have acted on them. A Retry-After header
+// in the response overrides the exponential backoff. Notices of retries are
+// written to standard error, keeping them out of JSON output.
+type retryTransport struct {
+	base http.RoundTripper
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	delay := retryDelay
+	r := req
+	for attempt := 0; ; attempt++ {
+		if attempt > 0 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				return nil, err
+			}
+			r = req.Clone(req.Context())
+			r.Body = body
+		}
+
+		resp, err := t.attempt(r)
+		if attempt == retries || !isRetryable(req, resp, err) {
+			return resp, err
+		}
+
+		wait := delay
+		if resp != nil {
+			if d, ok := retryAfter(resp); ok {
+				wait = d
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		fmt.Fprint(os.Stderr, sprintf("Request failed (%s); retrying in %s.\n", retryReason(resp, err), wait))
+
+		timer := time.NewTimer(wait)
+		select {
+		case <-timer.C:
+		case <-req.Context().Done():
+			timer.Stop()
+			return nil, req.Context().Err()
+		}
+		delay = min(2*delay, maxRetryDelay)
+	}
+}
+
+// attempt sends a request once, canceling it if it does not complete
+// within requestTimeout. The time limit includes reading the response body.
+func (t *retryTransport) attempt(req *http.Request) (*http.Response, error) {
+	if requestTimeout == 0 {
+		return t.base.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), requestTimeout)
+	resp, err := t.base.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelingBody{resp.Body, cancel}
+	return resp, nil
+}
+
+// A cancelingBody cancels the context of its request when closed.
+type cancelingBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelingBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// isRetryable reports whether a failed request attempt should be retried.
+func isRetryable(req *http.Request, resp *http.Response, err error) bool {
+	if req.Context().Err() != nil || (req.Body != nil && req.GetBody == nil) {
+		return false
+	}
+	if err == nil {
+		switch {
+		case resp.StatusCode == http.StatusTooManyRequests,
+			resp.StatusCode == http.StatusServiceUnavailable:
+			return true
+		case resp.StatusCode < 500:
+			return false
+		}
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return false
+}
+
+// retryAfter returns the delay requested by a response's Retry-After
+// header, which holds either a number of seconds or a date. The delay is
+// limited to maxRetryDelay, so that a server asking for a long wait does
+// not stall the command.
+func retryAfter(resp *http.Response) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.ParseInt(v, 10, 64); err == nil && secs >= 0 {
+		return time.Duration(min(secs, int64(maxRetryDelay/time.Second))) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		return min(max(time.Until(t), 0), maxRetryDelay), true
+	}
+	return 0, false
+}
+
+func retryReason(resp *http.Response, err error) string {
+	if err != nil {
+		if errors.Is(err, context.DeadlineExceeded) {
+			return sprintf("timed out after %s", requestTimeout)
+		}
+		return err.Error()
+	}
+	return resp.Status
+}
@@ -0,0 +1,363 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A queuedChange is a record mutation that failed for a transient reason
+// during a bulk run, saved so that "retry run" can reattempt it later.
+type queuedChange struct {
+	ZoneID string               `json:"zone_id"`
+	Zone   string               `json:"zone,omitempty"`
+	Action string               `json:"action"` // create, update, delete or upsert
+	Record cloudflare.DNSRecord `json:"record"`
+
+	// Comment is the comment set by an update. Nil keeps the record's
+	// current comment.
+	Comment *string `json:"comment,omitempty"`
+
+	Error  string    `json:"error"`
+	Failed time.Time `json:"failed"`
+}
+
+func createChange(zoneID, zone string, p cloudflare.CreateDNSRecordParams) *queuedChange {
+	return &queuedChange{ZoneID: zoneID, Zone: zone, Action: "create", Record: cloudflare.DNSRecord{
+		Type: p.Type, Name: p.Name, Content: p.Content, Data: p.Data, TTL: p.TTL,
+		Proxied: p.Proxied, Priority: p.Priority, Comment: p.Comment, Tags: p.Tags,
+	}}
+}
+
+func updateChange(zoneID, zone string, p cloudflare.UpdateDNSRecordParams) *queuedChange {
+	return &queuedChange{ZoneID: zoneID, Zone: zone, Action: "update", Comment: p.Comment, Record: cloudflare.DNSRecord{
+		ID: p.ID, Type: p.Type, Name: p.Name, Content: p.Content, Data: p.Data, TTL: p.TTL,
+		Proxied: p.Proxied, Priority: p.Priority, Tags: p.Tags,
+	}}
+}
+
+func deleteChange(zoneID, zone string, r cloudflare.DNSRecord) *queuedChange {
+	return &queuedChange{ZoneID: zoneID, Zone: zone, Action: "delete", Record: cloudflare.DNSRecord{
+		ID: r.ID, Type: r.Type, Name: r.Name, Content: r.Content,
+	}}
+}
+
+func upsertChange(zoneID, zone, recType, name, content string) *queuedChange {
+	return &queuedChange{ZoneID: zoneID, Zone: zone, Action: "upsert", Record: cloudflare.DNSRecord{
+		Type: recType, Name: name, Content: content,
+	}}
+}
+
+func (q *queuedChange) zoneName() string {
+	if q.Zone != "" {
+		return q.Zone
+	}
+	return q.ZoneID
+}
+
+// apply makes the queued change.
+func (q *queuedChange) apply(api *cloudflare.API) error {
+	zoneID := cloudflare.ZoneIdentifier(q.ZoneID)
+	r := q.Record
+	b := recordBackend(api)
+	switch q.Action {
+	case "create":
+		_, err := b.CreateDNSRecord(commandCtx, q.ZoneID, cloudflare.CreateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			Data:     r.Data,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Comment:  r.Comment,
+			Tags:     r.Tags,
+		})
+		return err
+	case "update":
+		_, err := b.UpdateDNSRecord(commandCtx, q.ZoneID, cloudflare.UpdateDNSRecordParams{
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  r.Content,
+			Data:     r.Data,
+			ID:       r.ID,
+			TTL:      r.TTL,
+			Proxied:  r.Proxied,
+			Priority: r.Priority,
+			Comment:  q.Comment,
+			Tags:     r.Tags,
+		})
+		return err
+	case "delete":
+		// A record deleted since the failure needs no further work.
+		err := b.DeleteDNSRecord(commandCtx, q.ZoneID, r.ID)
+		if isNotFound(err) {
+			return nil
+		}
+		return err
+	case "upsert":
+		_, err := upsertRecord(api, zoneID, r.Type, r.Name, r.Content, r.TTL, recordMeta{proxied: r.Proxied})
+		return err
+	default:
+		return fmt.Errorf("unknown action %q", q.Action)
+	}
+}
+
+// isTransient reports whether a failed mutation might succeed if attempted
+// again later. The Cloudflare client reports exhausted rate limits and
+// server errors as plain errors, so they are recognized by their messages.
+func isTransient(err error) bool {
+	var frozen *freezeError
+	if errors.As(err, &frozen) {
+		return false
+	}
+	if errors.Is(err, errInterrupted) || errors.Is(err, context.DeadlineExceeded) ||
+		errors.Is(err, context.Canceled) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	var cfErr *cloudflare.Error
+	if errors.As(err, &cfErr) {
+		return cfErr.StatusCode == 429 || cfErr.StatusCode >= 500
+	}
+	msg := err.Error()
+	return strings.Contains(msg, "rate limit retries") || strings.Contains(msg, "please try again later")
+}
+
+// readRetryQueue returns the changes in the retry queue. The state lock
+// must be held.
+func readRetryQueue() ([]queuedChange, string, error) {
+	path, err := statePath("retry-queue.json")
+	if err != nil {
+		return nil, "", err
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, path, nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	var queue []queuedChange
+	if err := json.Unmarshal(data, &queue); err != nil {
+		return nil, "", fmt.Errorf("%s: %v", path, err)
+	}
+	return queue, path, nil
+}
+
+// writeRetryQueue replaces the contents of the retry queue. The state lock
+// must be held.
+func writeRetryQueue(path string, queue []queuedChange) error {
+	if len(queue) == 0 {
+		err := os.Remove(path)
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	data, err := json.MarshalIndent(queue, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeStateFile(path, append(data, '\n'))
+}
+
+// queueRetries saves the operations of a bulk run that failed for
+// transient reasons to the retry queue.
+func queueRetries(ops []operation, errs []error) {
+	now := time.Now().UTC()
+	var failed []queuedChange
+	for i, op := range ops {
+		if op.change == nil || errs[i] == nil || !isTransient(errs[i]) {
+			continue
+		}
+		q := *op.change
+		q.Error = errs[i].Error()
+		q.Failed = now
+		failed = append(failed, q)
+	}
+	if len(failed) == 0 {
+		return
+	}
+
+	err := withStateLock(func() error {
+		queue, path, err := readRetryQueue()
+		if err != nil {
+			return err
+		}
+		return writeRetryQueue(path, append(queue, failed...))
+	})
+	if err != nil {
+		printf("Unable to save failed changes for retry: %v\n", err)
+		return
+	}
+	printf("%d failed change(s) saved; run \"retry run\" to reattempt them.\n", len(failed))
+}
+
+var retryFlags = registerFlags("retry", forceFlags)
+
+func cmdRetry(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, retryFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "list":
+		return retryList()
+	case "run":
+		return retryRun(flags.force())
+	case "clear":
+		return retryClear(flags.force())
+	default:
+		return usageError(c)
+	}
+}
+
+func retryList() error {
+	var queue []queuedChange
+	err := withStateLock(func() (err error) {
+		queue, _, err = readRetryQueue()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		printf("The retry queue is empty.\n")
+		return nil
+	}
+	for _, q := range queue {
+		fmt.Fprintf(output, "%s %s: %s %s %s (%s)\n", q.Failed.Local().Format("2006-01-02 15:04:05"),
+			q.zoneName(), q.Action, q.Record.Type, q.Record.Name, q.Error)
+	}
+	return nil
+}
+
+// retryRun reattempts the queued changes. Changes that fail again for
+// transient reasons return to the queue; changes that fail for other
+// reasons are reported and dropped, since retrying them cannot help. The
+// queue is not locked while the changes are made, so the changes are taken
+// from it first.
+func retryRun(force bool) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	var queue []queuedChange
+	err = withStateLock(func() (err error) {
+		queue, _, err = readRetryQueue()
+		return err
+	})
+	if err != nil {
+		return err
+	}
+	if len(queue) == 0 {
+		printf("The retry queue is empty.\n")
+		return nil
+	}
+	if !force && !confirm(sprintf("Retry %d change(s)?", len(queue))) {
+		printf("No changes retried.\n")
+		return nil
+	}
+
+	err = withStateLock(func() error {
+		var path string
+		var err error
+		if queue, path, err = readRetryQueue(); err != nil {
+			return err
+		}
+		return writeRetryQueue(path, nil)
+	})
+	if err != nil {
+		return err
+	}
+
+	var ops []operation
+	for _, q := range queue {
+		ops = append(ops, operation{
+			key: q.ZoneID + "/" + q.Record.Type + " " + q.Record.Name,
+			fn:  func() error { return q.apply(api) },
+		})
+	}
+
+	var remaining []queuedChange
+	failed := 0
+	for i, err := range sched.run(ops) {
+		q := queue[i]
+		switch {
+		case err == nil:
+			printf("Applied %s of %s record %s.\n", q.Action, q.Record.Type, q.Record.Name)
+			continue
+		case isTransient(err):
+			q.Error = err.Error()
+			q.Failed = time.Now().UTC()
+			remaining = append(remaining, q)
+		}
+		printf("Error retrying %s of %s record %s: %v\n", q.Action, q.Record.Type, q.Record.Name, err)
+		failed++
+	}
+
+	if len(remaining) > 0 {
+		err := withStateLock(func() error {
+			current, path, err := readRetryQueue()
+			if err != nil {
+				return err
+			}
+			return writeRetryQueue(path, append(remaining, current...))
+		})
+		if err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		if len(remaining) > 0 {
+			printf("%d change(s) remain in the retry queue.\n", len(remaining))
+		}
+		return fmt.Errorf("%d of %d change(s) could not be applied", failed, len(queue))
+	}
+	printf("%d change(s) applied.\n", len(queue))
+	return nil
+}
+
+func retryClear(force bool) error {
+	return withStateLock(func() error {
+		queue, path, err := readRetryQueue()
+		if err != nil {
+			return err
+		}
+		if len(queue) == 0 {
+			printf("The retry queue is empty.\n")
+			return nil
+		}
+		if !force && !confirm(sprintf("Discard %d queued change(s)?", len(queue))) {
+			printf("No changes discarded.\n")
+			return nil
+		}
+		if err := writeRetryQueue(path, nil); err != nil {
+			return err
+		}
+		printf("Discarded %d queued change(s).\n", len(queue))
+		return nil
+	})
+}
@@ -0,0 +1,159 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// roundRobinFlags are the flags accepted by commands that manage address
+// records, which may have several records of the same name.
+var roundRobinFlags = flagSpec{
+	"append":      false,
+	"replace-all": false,
+}
+
+// parseRoundRobin returns the round-robin mode requested by the flags:
+// "append", "replace-all", or the empty string to update a single record.
+func parseRoundRobin(flags flagValues) (string, error) {
+	switch {
+	case flags.has("append") && flags.has("replace-all"):
+		return "", argError(errors.New("--append and --replace-all cannot be used together"))
+	case flags.has("append"):
+		return "append", nil
+	case flags.has("replace-all"):
+		return "replace-all", nil
+	default:
+		return "", nil
+	}
+}
+
+// A recordBatch is the body of a request to the batch endpoint, which
+// applies all of its changes atomically.
+type recordBatch struct {
+	Deletes []recordBatchID                    `json:"deletes,omitempty"`
+	Posts   []cloudflare.CreateDNSRecordParams `json:"posts,omitempty"`
+}
+
+type recordBatchID struct {
+	ID string `json:"id"`
+}
+
+// setRoundRobin manages the set of address records with a name. In append
+// mode, a record holding the address is added to the set. In replace-all
+// mode, addr is a comma-separated list of addresses, and the set is
+// replaced by records holding exactly those addresses in a single atomic
+// change.
+func setRoundRobin(mode, recType, name, addr string, ttl int, meta recordMeta, wait time.Duration) error {
+	candidates := []string{addr}
+	if mode == "replace-all" {
+		candidates = strings.Split(addr, ",")
+	}
+	for _, a := range candidates {
+		if a = strings.TrimSpace(a); a != "" || mode != "replace-all" {
+			if err := validateRecord(recType, name, a); err != nil {
+				return argError(err)
+			}
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	if err := checkOwner(api, zoneID, recType, name); err != nil {
+		return err
+	}
+
+	params := cloudflare.ListDNSRecordsParams{Type: recType, Name: name}
+	existing, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+
+	var addrs []string
+	if mode == "replace-all" {
+		for _, a := range strings.Split(addr, ",") {
+			if a = strings.TrimSpace(a); a != "" {
+				addrs = append(addrs, cflib.CanonicalIP(a))
+			}
+		}
+		if len(addrs) == 0 {
+			return argError(errors.New("no addresses given"))
+		}
+	} else {
+		addrs = []string{cflib.CanonicalIP(addr)}
+	}
+
+	if ttl == 0 {
+		ttl = defaultTTL
+	}
+
+	var batch recordBatch
+	kept := make(map[string]bool)
+	for _, r := range existing {
+		keep := false
+		for _, a := range addrs {
+			if cflib.ContentEqual(recType, r.Content, a) && !kept[a] {
+				keep, kept[a] = true, true
+				break
+			}
+		}
+		if !keep && mode == "replace-all" {
+			batch.Deletes = append(batch.Deletes, recordBatchID{r.ID})
+		}
+	}
+	for _, a := range addrs {
+		if kept[a] {
+			continue
+		}
+		kept[a] = true
+		p := cloudflare.CreateDNSRecordParams{
+			Type:    recType,
+			Name:    name,
+			Content: a,
+			TTL:     ttl,
+			Tags:    meta.tags,
+		}
+		if meta.comment != nil {
+			p.Comment = *meta.comment
+		}
+		batch.Posts = append(batch.Posts, p)
+	}
+
+	if len(batch.Deletes) == 0 && len(batch.Posts) == 0 {
+		printf("DNS records are already up to date.\n")
+		return nil
+	}
+
+	_, err = api.Raw(commandCtx, http.MethodPost,
+		"/zones/"+zoneID.Identifier+"/dns_records/batch", batch, nil)
+	if err != nil {
+		return err
+	}
+	if len(existing) == 0 {
+		if err := markOwned(api, zoneID, recType, name); err != nil {
+			return err
+		}
+	}
+
+	printf("%d record(s) added, %d record(s) removed.\n", len(batch.Posts), len(batch.Deletes))
+	if wait > 0 {
+		return waitForRecord(api, zoneID, recType, name, addrs[0], wait)
+	}
+	return nil
+}
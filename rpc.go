@@ -0,0 +1,317 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// In RPC mode, cf reads one JSON request per line from standard input and
+// writes one JSON response per line to standard output, so that editors and
+// other programs can use it as a backend. A request has the form
+//
+//	{"id": 1, "method": "list", "params": {"type": "A"}}
+//
+// and is answered with either {"id": 1, "result": ...} or
+// {"id": 1, "error": {"code": 4, "message": "..."}}, where the error code is
+// the exit code the equivalent command would have returned. Every other
+// message cf prints is written to standard error.
+
+type rpcRequest struct {
+	ID     json.RawMessage `json:"id"`
+	Method string          `json:"method"`
+	Params json.RawMessage `json:"params"`
+}
+
+type rpcResponse struct {
+	ID     json.RawMessage `json:"id"`
+	Result any             `json:"result,omitempty"`
+	Error  *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcRecord is a DNS record as accepted and returned by RPC methods.
+type rpcRecord struct {
+	ID      string   `json:"id,omitempty"`
+	Type    string   `json:"type"`
+	Name    string   `json:"name"`
+	Content string   `json:"content"`
+	TTL     int      `json:"ttl,omitempty"`
+	Proxied bool     `json:"proxied,omitempty"`
+	Comment string   `json:"comment,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+func newRPCRecord(r cloudflare.DNSRecord) rpcRecord {
+	return rpcRecord{
+		ID:      r.ID,
+		Type:    r.Type,
+		Name:    r.Name,
+		Content: r.Content,
+		TTL:     r.TTL,
+		Proxied: r.Proxied != nil && *r.Proxied,
+		Comment: r.Comment,
+		Tags:    r.Tags,
+	}
+}
+
+// rpcMethods maps the names of RPC methods to their implementations. Each
+// decodes its own parameters.
+var rpcMethods = map[string]func(params json.RawMessage) (any, error){
+	"list":   rpcList,
+	"upsert": rpcUpsert,
+	"delete": rpcDelete,
+	"diff":   rpcDiff,
+}
+
+// runRPC serves RPC requests until standard input is closed.
+func runRPC() int {
+	out := json.NewEncoder(os.Stdout)
+	os.Stdout = os.Stderr
+
+	reader := bufio.NewReader(os.Stdin)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(strings.TrimSpace(string(line))) > 0 {
+			out.Encode(handleRPC(line))
+		}
+		if err == io.EOF {
+			return exitSuccess
+		}
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "%s\n", sprintf("Error: %v", err))
+			return exitFailure
+		}
+	}
+}
+
+func handleRPC(line []byte) rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		return rpcResponse{Error: &rpcError{exitUsage, err.Error()}}
+	}
+
+	method, ok := rpcMethods[req.Method]
+	if !ok {
+		err := fmt.Sprintf("unknown method %q", req.Method)
+		return rpcResponse{ID: req.ID, Error: &rpcError{exitUsage, err}}
+	}
+
+	if len(req.Params) == 0 {
+		req.Params = json.RawMessage("{}")
+	}
+	result, err := method(req.Params)
+	if err != nil {
+		return rpcResponse{ID: req.ID, Error: &rpcError{exitCode(err), err.Error()}}
+	}
+	return rpcResponse{ID: req.ID, Result: result}
+}
+
+// decodeParams decodes a method's parameters, rejecting unknown fields.
+func decodeParams(params json.RawMessage, v any) error {
+	dec := json.NewDecoder(strings.NewReader(string(params)))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(v); err != nil {
+		return argError(fmt.Errorf("invalid params: %v", err))
+	}
+	return nil
+}
+
+// rpcZone returns the zone named by a method's parameters, or the active
+// zone if none is named.
+func rpcZone(api *cloudflare.API, zone string) (*cloudflare.ResourceContainer, error) {
+	if zone == "" {
+		return getZoneIdentifier()
+	}
+	zoneID, err := recordBackend(api).ZoneIDByName(zone)
+	if err != nil {
+		return nil, zoneError(err)
+	}
+	return cloudflare.ZoneIdentifier(zoneID), nil
+}
+
+func rpcList(params json.RawMessage) (any, error) {
+	var p struct {
+		Zone string `json:"zone"`
+		Type string `json:"type"`
+		Name string `json:"name"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return nil, err
+	}
+	zoneID, err := rpcZone(api, p.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	listParams := cloudflare.ListDNSRecordsParams{Type: strings.ToUpper(p.Type), Name: p.Name}
+	recs, err := listRecords(api, zoneID, listParams)
+	if err != nil {
+		return nil, err
+	}
+	out := make([]rpcRecord, 0, len(recs))
+	for _, r := range recs {
+		out = append(out, newRPCRecord(r))
+	}
+	return out, nil
+}
+
+func rpcUpsert(params json.RawMessage) (any, error) {
+	var p struct {
+		Zone    string    `json:"zone"`
+		Type    string    `json:"type"`
+		Name    string    `json:"name"`
+		Content string    `json:"content"`
+		TTL     int       `json:"ttl"`
+		Comment *string   `json:"comment"`
+		Tags    *[]string `json:"tags"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.Type == "" || p.Name == "" || p.Content == "" {
+		return nil, argError(errors.New("type, name and content are required"))
+	}
+	if err := validateRecord(p.Type, p.Name, p.Content); err != nil {
+		return nil, argError(err)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return nil, err
+	}
+	zoneID, err := rpcZone(api, p.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	meta := recordMeta{comment: p.Comment}
+	if p.Tags != nil {
+		meta.tags = append([]string{}, *p.Tags...)
+	}
+	changed, err := upsertRecord(api, zoneID, strings.ToUpper(p.Type), p.Name, p.Content, p.TTL, meta)
+	if err != nil {
+		return nil, err
+	}
+	return map[string]bool{"changed": changed}, nil
+}
+
+func rpcDelete(params json.RawMessage) (any, error) {
+	var p struct {
+		Zone  string `json:"zone"`
+		ID    string `json:"id"`
+		Type  string `json:"type"`
+		Name  string `json:"name"`
+		Force bool   `json:"force"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+	if p.ID == "" && (p.Type == "" || p.Name == "") {
+		return nil, argError(errors.New("either id, or type and name, are required"))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return nil, err
+	}
+	zoneID, err := rpcZone(api, p.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	if p.ID != "" {
+		ids = []string{p.ID}
+	} else {
+		listParams := cloudflare.ListDNSRecordsParams{Type: strings.ToUpper(p.Type), Name: p.Name}
+		recs, err := listRecords(api, zoneID, listParams)
+		if err != nil {
+			return nil, err
+		}
+		if len(recs) == 0 {
+			return nil, errNoMatch
+		}
+		for _, r := range recs {
+			if err := checkOwner(api, zoneID, r.Type, r.Name); err != nil {
+				return nil, err
+			}
+			ids = append(ids, r.ID)
+		}
+	}
+
+	for _, id := range ids {
+		if err := newClient(api, zoneID).Delete(deleteCtx(p.Force), id); err != nil {
+			if isNotFound(err) {
+				return nil, errNoMatch
+			}
+			return nil, err
+		}
+	}
+	return map[string]int{"deleted": len(ids)}, nil
+}
+
+// rpcDiff compares a desired set of records with the records of a zone. It
+// reports the desired records missing from the zone, and the zone's records
+// of the desired types and names that are not desired.
+func rpcDiff(params json.RawMessage) (any, error) {
+	var p struct {
+		Zone    string      `json:"zone"`
+		Records []rpcRecord `json:"records"`
+	}
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return nil, err
+	}
+	zoneID, err := rpcZone(api, p.Zone)
+	if err != nil {
+		return nil, err
+	}
+
+	desired := make([]cflib.Record, len(p.Records))
+	for i, r := range p.Records {
+		desired[i] = cflib.Record{Type: strings.ToUpper(r.Type), Name: r.Name, Content: r.Content, TTL: r.TTL}
+	}
+	plan, err := newClient(api, zoneID).Diff(commandCtx, desired)
+	if err != nil {
+		return nil, err
+	}
+
+	result := struct {
+		Create    []rpcRecord `json:"create"`
+		Delete    []rpcRecord `json:"delete"`
+		Unchanged int         `json:"unchanged"`
+	}{Create: []rpcRecord{}, Delete: []rpcRecord{}, Unchanged: plan.Unchanged}
+
+	for _, d := range plan.Create {
+		result.Create = append(result.Create, rpcRecord{Type: d.Type, Name: d.Name, Content: d.Content, TTL: d.TTL})
+	}
+	for _, l := range plan.Delete {
+		result.Delete = append(result.Delete, newRPCRecord(l))
+	}
+	return result, nil
+}
@@ -0,0 +1,122 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+)
+
+// defaultRateLimit is the default global API request budget in requests
+// per second. It matches Cloudflare's documented limit of 1200 requests
+// per five minutes.
+const defaultRateLimit = 4.0
+
+// defaultWorkers is the default number of operations a scheduler runs
+// concurrently.
+const defaultWorkers = 5
+
+// maxWorkers is the largest number of concurrent operations accepted by
+// the concurrency setting. More would only queue at the rate limiter.
+const maxWorkers = 50
+
+// An operation is a single API mutation scheduled for execution. Its key
+// begins with the ID of the zone it changes, followed by a slash, and
+// typically identifies a single DNS record. Operations sharing the same key
+// are run one at a time in the order they were submitted. An operation
+// describing its change may be saved to the retry queue if it fails.
+type operation struct {
+	key    string
+	fn     func() error
+	change *queuedChange
+}
+
+// A scheduler runs batches of API mutations. Operations on different keys
+// are run in parallel by a bounded number of workers, while operations on
+// the same key are serialized. Request throttling is handled by the rate
+// limiter of the API client shared by all operations.
+type scheduler struct {
+	workers int
+}
+
+var sched = &scheduler{workers: defaultWorkers}
+
+// run executes the operations and waits for them to complete. The returned
+// slice holds the error result of each operation, in submission order.
+// Once the running command is interrupted, operations not yet started fail
+// with errInterrupted. With zone locks enabled, the operations of zones
+// whose locks cannot be taken fail without being run.
+func (s *scheduler) run(ops []operation) []error {
+	errs := make([]error, len(ops))
+
+	locks, lockErrs := lockZones(ops)
+	defer unlockZones(locks)
+	defer keepLocks(locks)()
+
+	// Group operations by key, preserving submission order within each
+	// group.
+	var groups [][]int
+	index := make(map[string]int)
+	for i, op := range ops {
+		if err := lockErrs[keyZone(op.key)]; err != nil {
+			errs[i] = err
+			continue
+		}
+		g, ok := index[op.key]
+		if !ok {
+			g = len(groups)
+			index[op.key] = g
+			groups = append(groups, nil)
+		}
+		groups[g] = append(groups[g], i)
+	}
+
+	workers := s.workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ch := make(chan []int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers && w < len(groups); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for group := range ch {
+				for _, i := range group {
+					if commandCtx.Err() != nil {
+						errs[i] = errInterrupted
+						continue
+					}
+					errs[i] = ops[i].fn()
+				}
+			}
+		}()
+	}
+
+	for _, group := range groups {
+		ch <- group
+	}
+	close(ch)
+	wg.Wait()
+
+	noteRunResults(errs)
+	return errs
+}
+
+// parseWorkers validates a concurrency argument.
+func parseWorkers(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 || n > maxWorkers {
+		return 0, argError(fmt.Errorf("concurrency must be a number from 1 to %d", maxWorkers))
+	}
+	return n, nil
+}
+
+// recordKey returns the scheduler key for a DNS record in a zone.
+func recordKey(zoneID, recordID string) string {
+	return zoneID + "/" + recordID
+}
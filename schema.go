@@ -0,0 +1,299 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A schemaError reports where JSON input is malformed or does not match
+// the type it is decoded into.
+type schemaError struct {
+	line, col int
+	msg       string
+}
+
+func (e *schemaError) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.line, e.col, e.msg)
+}
+
+// A jsonDocument is JSON input checked against the Go type it was decoded
+// into, remembering where each of its values starts so that errors found
+// in the decoded values can be reported at their line and column.
+type jsonDocument struct {
+	data    []byte
+	offsets map[string]int // by path, such as "freezes[1].start"
+}
+
+var (
+	jsonUnmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+	textUnmarshalerType = reflect.TypeOf((*encoding.TextUnmarshaler)(nil)).Elem()
+)
+
+// decodeJSON decodes JSON input into v, a pointer, using the type of v as
+// the input's schema. Malformed input, unknown object keys and values of
+// the wrong kind are reported as schemaErrors, naming the key most likely
+// meant in place of an unknown one, rather than left to fail later or be
+// silently ignored.
+func decodeJSON(data []byte, v any) (*jsonDocument, error) {
+	doc := &jsonDocument{data: data, offsets: make(map[string]int)}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	if err := doc.check(dec, reflect.TypeOf(v).Elem(), ""); err != nil {
+		return nil, err
+	}
+	end := doc.skipSpace(int(dec.InputOffset()))
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, doc.errorAt(end, "unexpected data after the end of the document")
+	}
+	if err := json.Unmarshal(data, v); err != nil {
+		return nil, doc.convert(err)
+	}
+	return doc, nil
+}
+
+// errorf returns a schemaError located at the value of a path, or at the
+// start of the document if the path was not seen.
+func (d *jsonDocument) errorf(path, format string, args ...any) error {
+	msg := fmt.Sprintf(format, args...)
+	if path != "" {
+		msg = path + ": " + msg
+	}
+	return d.errorAt(d.offsets[path], msg)
+}
+
+func (d *jsonDocument) errorAt(offset int, msg string) error {
+	offset = min(offset, len(d.data))
+	line := 1 + bytes.Count(d.data[:offset], []byte("\n"))
+	col := 1 + offset - (bytes.LastIndexByte(d.data[:offset], '\n') + 1)
+	return &schemaError{line, col, msg}
+}
+
+// convert locates the syntax and type errors of the json package.
+func (d *jsonDocument) convert(err error) error {
+	var syntax *json.SyntaxError
+	var typ *json.UnmarshalTypeError
+	switch {
+	case errors.As(err, &syntax):
+		// The offset follows the byte in error, unless the input ended.
+		offset := int(syntax.Offset)
+		if offset > 0 && offset < len(d.data) {
+			offset--
+		}
+		return d.errorAt(offset, syntax.Error())
+	case errors.As(err, &typ):
+		return d.errorAt(int(typ.Offset), fmt.Sprintf("%s: cannot be a %s", typ.Field, typ.Value))
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return d.errorAt(len(d.data), "unexpected end of the document")
+	}
+	return err
+}
+
+// skipSpace returns the offset of the first byte at or after an offset
+// that is neither white space nor a separator, where the next value or
+// key starts.
+func (d *jsonDocument) skipSpace(offset int) int {
+	for offset < len(d.data) && strings.IndexByte(" \t\r\n,:", d.data[offset]) >= 0 {
+		offset++
+	}
+	return offset
+}
+
+// check reads the next value of the input, checking it against a type.
+func (d *jsonDocument) check(dec *json.Decoder, t reflect.Type, path string) error {
+	start := d.skipSpace(int(dec.InputOffset()))
+	d.offsets[path] = start
+	tok, err := dec.Token()
+	if err != nil {
+		return d.convert(err)
+	}
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	// Values decoded by their own methods are checked by those methods.
+	if t.Kind() == reflect.Interface || reflect.PointerTo(t).Implements(jsonUnmarshalerType) {
+		return d.skip(dec, tok)
+	}
+
+	mismatch := func(found string) error {
+		where := "value"
+		if path != "" {
+			where = path
+		}
+		return d.errorAt(start, fmt.Sprintf("%s must be %s, not %s", where, kindName(t), found))
+	}
+
+	switch tok := tok.(type) {
+	case nil:
+		return nil
+	case bool:
+		if t.Kind() != reflect.Bool {
+			return mismatch("true or false")
+		}
+	case string:
+		if t.Kind() != reflect.String && !reflect.PointerTo(t).Implements(textUnmarshalerType) {
+			return mismatch("a string")
+		}
+	case json.Number:
+		switch t.Kind() {
+		case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+			reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+			if _, err := strconv.ParseInt(tok.String(), 10, 64); err != nil {
+				return mismatch("the number " + tok.String())
+			}
+		case reflect.Float32, reflect.Float64:
+		default:
+			return mismatch("a number")
+		}
+	case json.Delim:
+		switch {
+		case tok == '[' && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array):
+			for i := 0; dec.More(); i++ {
+				if err := d.check(dec, t.Elem(), fmt.Sprintf("%s[%d]", path, i)); err != nil {
+					return err
+				}
+			}
+			_, err := dec.Token()
+			return d.convert(err)
+		case tok == '{' && t.Kind() == reflect.Map:
+			return d.checkObject(dec, path, func(string) (reflect.Type, []string) { return t.Elem(), nil })
+		case tok == '{' && t.Kind() == reflect.Struct:
+			fields := jsonFields(t)
+			return d.checkObject(dec, path, func(key string) (reflect.Type, []string) {
+				if ft, ok := fields[key]; ok {
+					return ft, nil
+				}
+				for name, ft := range fields {
+					if strings.EqualFold(name, key) {
+						return ft, nil
+					}
+				}
+				known := make([]string, 0, len(fields))
+				for name := range fields {
+					known = append(known, name)
+				}
+				sort.Strings(known)
+				return nil, known
+			})
+		case tok == '[':
+			return mismatch("a list")
+		default:
+			return mismatch("an object")
+		}
+	}
+	return nil
+}
+
+// checkObject reads the keys and values of an object, checking each value
+// against the type that field returns for its key. For an unknown key,
+// field returns a nil type and the known keys.
+func (d *jsonDocument) checkObject(dec *json.Decoder, path string,
+	field func(key string) (reflect.Type, []string)) error {
+
+	for dec.More() {
+		start := d.skipSpace(int(dec.InputOffset()))
+		tok, err := dec.Token()
+		if err != nil {
+			return d.convert(err)
+		}
+		key, _ := tok.(string)
+		ft, known := field(key)
+		if ft == nil {
+			msg := fmt.Sprintf("unknown key %q", key)
+			if path != "" {
+				msg = fmt.Sprintf("unknown key %q in %s", key, path)
+			}
+			return d.errorAt(start, msg+suggestion(key, known))
+		}
+		sub := key
+		if path != "" {
+			sub = path + "." + key
+		}
+		if err := d.check(dec, ft, sub); err != nil {
+			return err
+		}
+	}
+	_, err := dec.Token()
+	return d.convert(err)
+}
+
+// skip reads the rest of a value whose first token has been read.
+func (d *jsonDocument) skip(dec *json.Decoder, tok json.Token) error {
+	if delim, ok := tok.(json.Delim); !ok || (delim != '{' && delim != '[') {
+		return nil
+	}
+	for depth := 1; depth > 0; {
+		tok, err := dec.Token()
+		if err != nil {
+			return d.convert(err)
+		}
+		switch tok {
+		case json.Delim('{'), json.Delim('['):
+			depth++
+		case json.Delim('}'), json.Delim(']'):
+			depth--
+		}
+	}
+	return nil
+}
+
+// jsonFields returns the types of a struct's fields by their JSON keys,
+// including those of embedded structs.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type)
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name, _, _ := strings.Cut(tag, ",")
+		if f.Anonymous && name == "" && f.Type.Kind() == reflect.Struct {
+			for k, ft := range jsonFields(f.Type) {
+				fields[k] = ft
+			}
+			continue
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		fields[name] = f.Type
+	}
+	return fields
+}
+
+// kindName describes the JSON values a type accepts.
+func kindName(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Bool:
+		return "true or false"
+	case reflect.String:
+		return "a string"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a whole number"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.Slice, reflect.Array:
+		return "a list"
+	}
+	if reflect.PointerTo(t).Implements(textUnmarshalerType) {
+		return "a string"
+	}
+	return "an object"
+}
@@ -0,0 +1,63 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestDecodeJSON(t *testing.T) {
+	tests := []struct {
+		input string
+		err   string
+	}{
+		{`{"locale": "de", "profiles": {"work": {"zone": "example.com"}}}`, ""},
+		{`{"freezes": [{"start": "Fri 18:00", "end": "Mon 08:00", "zone": ["example.com"]}]}`,
+			`line 1, column 57: unknown key "zone" in freezes[0] (did you mean zones?)`},
+		{"{\n  \"locale\": \"de\",\n  \"ip_source\": []\n}",
+			`line 3, column 3: unknown key "ip_source" (did you mean ip_sources?)`},
+		{"{\n  \"disk_cache\": \"yes\"\n}", `line 2, column 17: disk_cache must be true or false, not a string`},
+		{`{"failover": [{"down_after": 2.5}]}`, `line 1, column 30: failover[0].down_after must be a whole number, not the number 2.5`},
+		{`{"profiles": []}`, `line 1, column 14: profiles must be an object, not a list`},
+		{"{\n  \"locale\": \"de\"\n  \"audit_log\": \"x\"\n}", `line 3, column 3: invalid character '"' after object key:value pair`},
+		{`{"locale": "de"} {}`, `line 1, column 18: unexpected data after the end of the document`},
+		{`{"locale": "de"`, `line 1, column 16: unexpected end of JSON input`},
+	}
+	for _, test := range tests {
+		var c config
+		_, err := decodeJSON([]byte(test.input), &c)
+		got := ""
+		if err != nil {
+			got = err.Error()
+		}
+		if got != test.err {
+			t.Errorf("decodeJSON(%q):\n got %s\nwant %s", test.input, got, test.err)
+		}
+	}
+}
+
+func TestLoadConfigLocation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	t.Setenv("CLOUDFLARE_CONFIG", path)
+	saved := cfg
+	defer func() { cfg = saved }()
+
+	data := "{\n  \"ttl_policies\": [\n    {\"min\": 300},\n    {\"min\": 600, \"max\": 60}\n  ]\n}\n"
+	if err := os.WriteFile(path, []byte(data), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	err := loadConfig()
+	if err == nil || !strings.Contains(err.Error(), "line 4, column 5: ttl_policies[1]: invalid TTL policy bounds") {
+		t.Errorf("err = %v", err)
+	}
+
+	lines, err := readUpsertLines(strings.NewReader("A www 10.0.0.1\n{\"type\": \"A\", \"nmae\": \"x\"}\n"))
+	if err == nil || err.Error() != `line 2, column 15: unknown key "nmae" (did you mean name?)` {
+		t.Errorf("readUpsertLines = %v, %v", lines, err)
+	}
+}
@@ -0,0 +1,186 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"net/netip"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A matcher reports whether a string matches a search pattern.
+type matcher func(s string) bool
+
+// newMatcher compiles a search pattern. Regular expression patterns match
+// any part of the string. Other patterns containing glob wildcards must
+// match the whole string, ignoring case, while patterns without wildcards
+// match any case-insensitive substring.
+func newMatcher(pattern string, regex bool) (matcher, error) {
+	if regex {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, argError(fmt.Errorf("invalid regular expression: %v", err))
+		}
+		return re.MatchString, nil
+	}
+
+	pattern = strings.ToLower(pattern)
+	if !strings.ContainsAny(pattern, "*?[") {
+		return func(s string) bool {
+			return strings.Contains(strings.ToLower(s), pattern)
+		}, nil
+	}
+
+	if _, err := path.Match(pattern, ""); err != nil {
+		return nil, argError(fmt.Errorf("invalid pattern %q", pattern))
+	}
+	return func(s string) bool {
+		ok, _ := path.Match(pattern, strings.ToLower(s))
+		return ok
+	}, nil
+}
+
+var searchFlags = registerFlags("search", mergeFlags(zoneFlags, flagSpec{
+	"type":    true,
+	"content": true,
+	"regex":   false,
+}))
+
+func cmdSearch(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, searchFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 || (len(args) == 0 && !flags.has("content")) {
+		return usageError(c)
+	}
+
+	regex := flags.has("regex")
+	var matchName, matchContent matcher
+	if len(args) > 0 {
+		if matchName, err = newMatcher(args[0], regex); err != nil {
+			return err
+		}
+	}
+	if flags.has("content") {
+		if matchContent, err = newMatcher(flags.get("content", ""), regex); err != nil {
+			return err
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	params := cloudflare.ListDNSRecordsParams{
+		Type: strings.ToUpper(flags.get("type", "")),
+	}
+	recs, err := listZoneRecords(api, zones, params)
+	if err != nil {
+		return err
+	}
+
+	var found []zoneRecord
+	for _, r := range recs {
+		if matchName != nil && !matchName(r.Name) {
+			continue
+		}
+		if matchContent != nil && !matchContent(r.Content) {
+			continue
+		}
+		found = append(found, r)
+	}
+
+	displayRecords(found, len(zones) > 1, false)
+	return nil
+}
+
+var rlookupFlags = registerFlags("rlookup", zoneFlags)
+
+// cmdRlookup lists the records pointing at an address, a network or a
+// hostname: the A and AAAA records holding the address, or an address in
+// the network, and the CNAME records naming the host.
+func cmdRlookup(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, rlookupFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	points, err := pointsAt(args[0])
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := selectZones(api, flags)
+	if err != nil {
+		return err
+	}
+
+	recs, err := listZoneRecords(api, zones, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+
+	var found []zoneRecord
+	for _, r := range recs {
+		if points(r.DNSRecord) {
+			found = append(found, r)
+		}
+	}
+
+	if len(found) == 0 && outputFormat != "json" {
+		printf("No records point at %s.\n", args[0])
+		return nil
+	}
+	displayRecords(found, len(zones) > 1, false)
+	return nil
+}
+
+// pointsAt returns a function reporting whether a record points at a
+// target: an address, a network in CIDR notation, or a hostname.
+func pointsAt(target string) (func(r cloudflare.DNSRecord) bool, error) {
+	var prefix netip.Prefix
+	if addr, err := netip.ParseAddr(target); err == nil {
+		prefix = netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen())
+	} else if p, err := netip.ParsePrefix(target); err == nil {
+		prefix = p.Masked()
+	} else {
+		host := cflib.NormalizeName(target)
+		if err := validateName(host, false); err != nil {
+			return nil, argError(fmt.Errorf("%q is not an address, network or hostname", target))
+		}
+		return func(r cloudflare.DNSRecord) bool {
+			return r.Type == "CNAME" && cflib.NormalizeName(r.Content) == host
+		}, nil
+	}
+
+	return func(r cloudflare.DNSRecord) bool {
+		if r.Type != "A" && r.Type != "AAAA" {
+			return false
+		}
+		addr, err := netip.ParseAddr(r.Content)
+		return err == nil && prefix.Contains(addr.Unmap())
+	}, nil
+}
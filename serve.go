@@ -0,0 +1,271 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"net/netip"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// ddnsUpdatePath is where DynDNS2 clients, such as routers and NAS devices,
+// send their updates.
+const ddnsUpdatePath = "/nic/update"
+
+// A ddnsClient is a device allowed to update the records of a zone through
+// the DynDNS2 endpoint of the serve command, authenticating with a user
+// name and password.
+type ddnsClient struct {
+	Username string   `json:"username"`
+	Password string   `json:"password"`
+	Zone     string   `json:"zone"`
+	Names    []string `json:"names"`
+}
+
+func (c *ddnsClient) parse() error {
+	if c.Username == "" || c.Password == "" {
+		return errors.New("missing username or password")
+	}
+	if strings.Contains(c.Username, ":") {
+		return fmt.Errorf("username %q contains a colon", c.Username)
+	}
+	for _, name := range c.Names {
+		if !inZone(name, c.Zone) {
+			return fmt.Errorf("record %s is not in zone %s", name, c.Zone)
+		}
+	}
+	return nil
+}
+
+// allows reports whether a client may update a record.
+func (c *ddnsClient) allows(name string) bool {
+	for _, n := range c.Names {
+		if cflib.NormalizeName(n) == cflib.NormalizeName(name) {
+			return true
+		}
+	}
+	return false
+}
+
+var serveFlags = registerFlags("serve", flagSpec{"ddns-endpoint": true})
+
+func cmdServe(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, serveFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 || !flags.has("ddns-endpoint") {
+		return usageError(c)
+	}
+	if len(cfg.DDNSClients) == 0 {
+		return errors.New("no DynDNS clients are listed under \"ddns_clients\" in the configuration file")
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	l, err := net.Listen("tcp", flags.get("ddns-endpoint", ""))
+	if err != nil {
+		return err
+	}
+	endpoint := &ddnsEndpoint{api: api}
+	mux := http.NewServeMux()
+	mux.Handle(ddnsUpdatePath, endpoint)
+	srv := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	served := make(chan error, 1)
+	go func() { served <- srv.Serve(l) }()
+
+	// A hangup signal reloads the configuration and credentials, as it
+	// does for the ddns command, so that clients can be added or removed
+	// without a restart.
+	hangup := make(chan os.Signal, 1)
+	signal.Notify(hangup, syscall.SIGHUP)
+	defer signal.Stop(hangup)
+
+	log.Printf("Accepting DynDNS updates at http://%s%s.", l.Addr(), ddnsUpdatePath)
+	for {
+		select {
+		case err := <-served:
+			return err
+		case <-hangup:
+			endpoint.mu.Lock()
+			if err := reloadConfig(); err != nil {
+				log.Printf("Error reloading configuration: %v", err)
+			} else {
+				endpoint.api, _ = getAPI()
+				log.Printf("Configuration reloaded.")
+			}
+			endpoint.mu.Unlock()
+		case <-commandCtx.Done():
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			srv.Shutdown(ctx)
+			cancel()
+			return errInterrupted
+		}
+	}
+}
+
+// A ddnsEndpoint answers update requests of the DynDNS2 protocol, as sent
+// by routers and NAS devices, by updating the A and AAAA records of the
+// ddns_clients listed in the configuration file. Requests are handled one
+// at a time.
+type ddnsEndpoint struct {
+	mu  sync.Mutex
+	api *cloudflare.API
+}
+
+// ServeHTTP handles a request of the form
+//
+//	GET /nic/update?hostname=<name>[,<name>...][&myip=<address>[,<address>]]
+//
+// authenticated with HTTP basic authentication. Without a valid myip, the
+// address the request comes from is used. The response has a line for each
+// name, as the protocol specifies: "good <address>" if the record was
+// updated, "nochg <address>" if it already held the address, "nohost" if
+// the client may not update the name and "dnserr" if the update failed.
+// Requests with missing or wrong credentials are answered with "badauth",
+// and those without a name with "notfqdn".
+func (e *ddnsEndpoint) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	client := e.authenticate(r)
+	if client == nil {
+		log.Printf("Rejected DynDNS update from %s: bad credentials.", r.RemoteAddr)
+		w.Header().Set("WWW-Authenticate", `Basic realm="cf"`)
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprintln(w, "badauth")
+		return
+	}
+
+	var names []string
+	for _, name := range strings.Split(r.FormValue("hostname"), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		fmt.Fprintln(w, "notfqdn")
+		return
+	}
+	addrs := updateAddresses(r)
+	if len(addrs) == 0 {
+		fmt.Fprintln(w, "dnserr")
+		return
+	}
+
+	refreshResponses()
+	for _, name := range names {
+		fmt.Fprintln(w, e.update(client, name, addrs))
+	}
+}
+
+// authenticate returns the client whose credentials a request carries, or
+// nil if none does.
+func (e *ddnsEndpoint) authenticate(r *http.Request) *ddnsClient {
+	user, password, ok := r.BasicAuth()
+	if !ok {
+		return nil
+	}
+	for i := range cfg.DDNSClients {
+		c := &cfg.DDNSClients[i]
+		if subtle.ConstantTimeCompare([]byte(user), []byte(c.Username)) == 1 &&
+			subtle.ConstantTimeCompare([]byte(password), []byte(c.Password)) == 1 {
+			return c
+		}
+	}
+	return nil
+}
+
+// update points a client's record at the addresses of an update request,
+// returning the protocol's answer for the record.
+func (e *ddnsEndpoint) update(client *ddnsClient, name string, addrs []netip.Addr) string {
+	if !client.allows(name) {
+		log.Printf("Rejected DynDNS update of %s by %s: not one of its records.", name, client.Username)
+		return "nohost"
+	}
+	zoneID, err := recordBackend(e.api).ZoneIDByName(client.Zone)
+	if err != nil {
+		log.Printf("Error updating %s for %s: %v", name, client.Username, err)
+		noteRunFailure()
+		return "dnserr"
+	}
+
+	changed := false
+	var contents []string
+	for _, addr := range addrs {
+		recType := "A"
+		if addr.Is6() {
+			recType = "AAAA"
+		}
+		c, err := upsertRecord(e.api, cloudflare.ZoneIdentifier(zoneID), recType, name, addr.String(), 0, recordMeta{})
+		if err != nil {
+			log.Printf("Error updating %s record %s for %s: %v", recType, name, client.Username, err)
+			noteRunFailure()
+			return "dnserr"
+		}
+		if c {
+			log.Printf("Updated %s record %s to %s for %s.", recType, name, addr, client.Username)
+		}
+		changed = changed || c
+		contents = append(contents, addr.String())
+	}
+	if changed {
+		return "good " + strings.Join(contents, ",")
+	}
+	return "nochg " + strings.Join(contents, ",")
+}
+
+// updateAddresses returns the addresses of an update request: at most one
+// IPv4 and one IPv6 address from its myip parameter, or else the address
+// the request comes from.
+func updateAddresses(r *http.Request) []netip.Addr {
+	var v4, v6 netip.Addr
+	for _, s := range strings.Split(r.FormValue("myip"), ",") {
+		addr, err := netip.ParseAddr(strings.TrimSpace(s))
+		if err != nil {
+			continue
+		}
+		addr = addr.Unmap()
+		switch {
+		case addr.Is4() && !v4.IsValid():
+			v4 = addr
+		case addr.Is6() && !v6.IsValid():
+			v6 = addr.WithZone("")
+		}
+	}
+	if !v4.IsValid() && !v6.IsValid() {
+		if ap, err := netip.ParseAddrPort(r.RemoteAddr); err == nil {
+			return []netip.Addr{ap.Addr().Unmap().WithZone("")}
+		}
+		return nil
+	}
+	var addrs []netip.Addr
+	for _, addr := range []netip.Addr{v4, v6} {
+		if addr.IsValid() {
+			addrs = append(addrs, addr)
+		}
+	}
+	return addrs
+}
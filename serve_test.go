@@ -0,0 +1,71 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestDDNSEndpoint(t *testing.T) {
+	b := useMemoryBackend(t)
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{DDNSClients: []ddnsClient{{
+		Username: "router",
+		Password: "secret",
+		Zone:     "example.com",
+		Names:    []string{"home.example.com"},
+	}}}
+	e := &ddnsEndpoint{api: activeAPI}
+
+	update := func(query, user, password, remote string) (int, string) {
+		t.Helper()
+		r := httptest.NewRequest("GET", ddnsUpdatePath+"?"+query, nil)
+		r.RemoteAddr = remote
+		if user != "" {
+			r.SetBasicAuth(user, password)
+		}
+		w := httptest.NewRecorder()
+		e.ServeHTTP(w, r)
+		return w.Code, strings.TrimSpace(w.Body.String())
+	}
+
+	tests := []struct {
+		query, user, password string
+		code                  int
+		want                  string
+	}{
+		{"hostname=home.example.com&myip=10.0.0.1", "router", "wrong", http.StatusUnauthorized, "badauth"},
+		{"hostname=home.example.com&myip=10.0.0.1", "", "", http.StatusUnauthorized, "badauth"},
+		{"myip=10.0.0.1", "router", "secret", http.StatusOK, "notfqdn"},
+		{"hostname=www.example.com&myip=10.0.0.1", "router", "secret", http.StatusOK, "nohost"},
+		{"hostname=home.example.com&myip=10.0.0.1", "router", "secret", http.StatusOK, "good 10.0.0.1"},
+		{"hostname=home.example.com&myip=10.0.0.1", "router", "secret", http.StatusOK, "nochg 10.0.0.1"},
+		{"hostname=home.example.com&myip=10.0.0.2,2001:db8::1", "router", "secret", http.StatusOK, "good 10.0.0.2,2001:db8::1"},
+		{"hostname=HOME.example.com&myip=bogus", "router", "secret", http.StatusOK, "good 192.0.2.7"},
+	}
+	for _, tt := range tests {
+		code, got := update(tt.query, tt.user, tt.password, "192.0.2.7:5000")
+		if code != tt.code || got != tt.want {
+			t.Errorf("%s as %s: %d %q, want %d %q", tt.query, tt.user, code, got, tt.code, tt.want)
+		}
+	}
+	checkRecords(t, b, "A home.example.com 192.0.2.7", "AAAA home.example.com 2001:db8::1")
+}
+
+func TestDDNSClientConfig(t *testing.T) {
+	for _, c := range []ddnsClient{
+		{Username: "router", Zone: "example.com"},
+		{Username: "a:b", Password: "x", Zone: "example.com"},
+		{Username: "router", Password: "x", Zone: "example.com", Names: []string{"home.example.net"}},
+	} {
+		if err := c.parse(); err == nil {
+			t.Errorf("%+v: no error", c)
+		}
+	}
+}
@@ -0,0 +1,403 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+)
+
+// Session settings changed only by "set".
+var (
+	// defaultTTL is the TTL of records created without one.
+	defaultTTL = ttlAuto
+
+	// confirmations is false if questions asked before destructive
+	// changes are answered yes without asking.
+	confirmations = true
+
+	// colorMode is "on", "off", or "auto" to color output only on a
+	// terminal when NO_COLOR is not set.
+	colorMode = "auto"
+
+	// failFast is true if a script or interactive session ends at the
+	// first failing command. It is set for scripts unless
+	// --continue-on-error is given, and for sessions by --fail-fast.
+	failFast bool
+)
+
+func cmdSet(c *cmd.Command, args []string) error {
+	switch len(args) {
+	case 0:
+		for _, s := range sessionSettings() {
+			fmt.Fprintf(output, "%-12s %s\n", s.name, s.value)
+		}
+	case 2:
+		return applySetting(args[0], args[1])
+	default:
+		return usageError(c)
+	}
+	return nil
+}
+
+// A sessionSetting is the name and value of a setting changed by "set", in
+// the form "set" accepts.
+type sessionSetting struct {
+	name, value string
+}
+
+// sessionSettings returns the current values of the settings changed by
+// "set", in the order they are displayed and restored.
+func sessionSettings() []sessionSetting {
+	owner, shadow := ownerID, shadowZone
+	if owner == "" {
+		owner = "off"
+	}
+	if shadow == "" {
+		shadow = "off"
+	}
+	return []sessionSetting{
+		{"dry-run", onOff(dryRun)},
+		{"output", outputFormat},
+		{"ttl", formatTTL(defaultTTL)},
+		{"confirm", onOff(confirmations)},
+		{"color", colorMode},
+		{"fail-fast", onOff(failFast)},
+		{"redact", onOff(redactOutput.Load())},
+		{"page-size", strconv.Itoa(pageSize)},
+		{"timeout", formatTimeout(requestTimeout)},
+		{"concurrency", strconv.Itoa(sched.workers)},
+		{"cache", formatTimeout(cacheTTL)},
+		{"resolvers", strings.Join(resolvers, ",")},
+		{"owner", owner},
+		{"shadow", shadow},
+		{"shadow-only", onOff(shadowOnly)},
+	}
+}
+
+// applySetting changes a setting as "set <name> <value>" does.
+func applySetting(name, value string) error {
+	switch name {
+	case "dry-run":
+		on, err := parseOnOff(value)
+		if err != nil {
+			return argError(err)
+		}
+		dryRun = on
+		printf("Dry-run mode %s.\n", onOff(dryRun))
+	case "output":
+		switch value {
+		case "text", "json":
+			outputFormat = value
+		default:
+			return argError(fmt.Errorf("invalid output format %q", value))
+		}
+		printf("Output format set to %s.\n", outputFormat)
+	case "ttl":
+		ttl, err := parseTTL(value)
+		if err != nil {
+			return argError(err)
+		}
+		defaultTTL = ttl
+		printf("Default TTL set to %s.\n", formatTTL(defaultTTL))
+	case "confirm":
+		on, err := parseOnOff(value)
+		if err != nil {
+			return argError(err)
+		}
+		confirmations = on
+		printf("Confirmations %s.\n", onOff(confirmations))
+	case "color":
+		switch value {
+		case "auto", "on", "off":
+			colorMode = value
+		default:
+			return argError(fmt.Errorf("expected auto, on or off, got %q", value))
+		}
+		printf("Color set to %s.\n", colorMode)
+	case "fail-fast":
+		on, err := parseOnOff(value)
+		if err != nil {
+			return argError(err)
+		}
+		failFast = on
+		printf("Fail-fast %s.\n", onOff(failFast))
+	case "redact":
+		on, err := parseOnOff(value)
+		if err != nil {
+			return argError(err)
+		}
+		if !on {
+			redactOutput.Store(false)
+		} else if err := startRedacting(); err != nil {
+			return err
+		}
+		printf("Redaction %s.\n", onOff(redactOutput.Load()))
+	case "page-size":
+		n, err := parsePageSize(value)
+		if err != nil {
+			return err
+		}
+		pageSize = n
+		printf("Page size set to %d.\n", pageSize)
+	case "timeout":
+		d, err := parseTimeout(value)
+		if err != nil {
+			return err
+		}
+		requestTimeout = d
+		printf("Request timeout set to %s.\n", formatTimeout(requestTimeout))
+	case "concurrency":
+		n, err := parseWorkers(value)
+		if err != nil {
+			return err
+		}
+		sched.workers = n
+		printf("Concurrency set to %d.\n", n)
+	case "cache":
+		d, err := parseCacheTTL(value)
+		if err != nil {
+			return err
+		}
+		cacheTTL = d
+		printf("Cache lifetime set to %s.\n", formatTimeout(cacheTTL))
+	case "resolvers":
+		list, err := parseResolvers(value)
+		if err != nil {
+			return err
+		}
+		resolvers = list
+		printf("Resolvers set to %s.\n", strings.Join(resolvers, ", "))
+	case "owner":
+		if value == "off" {
+			ownerID = ""
+			printf("Ownership tracking disabled.\n")
+			break
+		}
+		if err := validateOwner(value); err != nil {
+			return err
+		}
+		ownerID = value
+		printf("Owner set to %s.\n", ownerID)
+	case "shadow":
+		if value == "off" {
+			shadowZone, shadowOnly = "", false
+			printf("Shadow writes disabled.\n")
+			break
+		}
+		api, err := getAPI()
+		if err != nil {
+			return err
+		}
+		if _, err := baseBackend(api).ZoneIDByName(value); err != nil {
+			return zoneError(err)
+		}
+		shadowZone = value
+		printf("Staging zone set to %s.\n", shadowZone)
+	case "shadow-only":
+		on, err := parseOnOff(value)
+		if err != nil {
+			return argError(err)
+		}
+		if on && shadowZone == "" {
+			return argError(errors.New("set a staging zone with \"set shadow <zone>\" first"))
+		}
+		shadowOnly = on
+		printf("Shadow-only mode %s.\n", onOff(shadowOnly))
+	default:
+		return argError(fmt.Errorf("unknown setting %q", name))
+	}
+	return nil
+}
+
+// A savedSession holds the settings an interactive session leaves for the
+// next one to resume: the active profile and zone, and the output
+// preferences. It is kept in session.json in the state directory.
+type savedSession struct {
+	Profile string `json:"profile,omitempty"`
+	Zone    string `json:"zone,omitempty"`
+	Output  string `json:"output,omitempty"`
+	Color   string `json:"color,omitempty"`
+	TTL     int    `json:"ttl,omitempty"`
+}
+
+// resumedZone is the active zone of the previous interactive session. It is
+// used when no zone is given with --zone or CLOUDFLARE_ZONE, before the
+// profile's zone, and is forgotten when another profile is selected.
+var resumedZone string
+
+// loadSession reads the settings saved by the previous interactive session.
+// Without a saved session, the zero savedSession is returned.
+func loadSession() (savedSession, error) {
+	var saved savedSession
+	path, err := statePath("session.json")
+	if err != nil {
+		return saved, err
+	}
+	data, err := os.ReadFile(path)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		return saved, nil
+	case err != nil:
+		return saved, err
+	}
+	if err := json.Unmarshal(data, &saved); err != nil {
+		return savedSession{}, fmt.Errorf("%s: %v", path, err)
+	}
+	return saved, nil
+}
+
+// resumeSession applies the settings of a saved session that were not
+// given on the command line. The profile must already be selected; the
+// zone is only resumed if the saved session used the same profile.
+func resumeSession(saved savedSession, flags flagValues) {
+	if saved.Profile == activeProfileName {
+		resumedZone = saved.Zone
+	}
+	if !flags.has("json") && (saved.Output == "text" || saved.Output == "json") {
+		outputFormat = saved.Output
+	}
+	if isOneOf(saved.Color, []string{"auto", "on", "off"}) {
+		colorMode = saved.Color
+	}
+	if saved.TTL != 0 {
+		if ttl, err := parseTTL(fmt.Sprint(saved.TTL)); err == nil {
+			defaultTTL = ttl
+		}
+	}
+}
+
+// saveSession saves the settings of the interactive session for the next
+// one to resume.
+func saveSession() error {
+	saved := savedSession{
+		Profile: activeProfileName,
+		Zone:    activeZoneName,
+		Output:  outputFormat,
+		Color:   colorMode,
+		TTL:     defaultTTL,
+	}
+	if saved.Zone == "" {
+		saved.Zone = resumedZone
+	}
+	data, err := json.MarshalIndent(saved, "", "  ")
+	if err != nil {
+		return err
+	}
+	return withStateLock(func() error {
+		path, err := statePath("session.json")
+		if err != nil {
+			return err
+		}
+		return writeStateFile(path, append(data, '\n'))
+	})
+}
+
+// An exportedState is the session context written by "state export" and
+// restored by "state import": the active profile and zone, and the values
+// of the settings changed by "set". It holds no credentials.
+type exportedState struct {
+	Profile  string            `json:"profile,omitempty"`
+	Zone     string            `json:"zone,omitempty"`
+	Settings map[string]string `json:"settings,omitempty"`
+}
+
+func cmdState(c *cmd.Command, args []string) error {
+	switch {
+	case len(args) >= 1 && len(args) <= 2 && args[0] == "export":
+		path := ""
+		if len(args) == 2 {
+			path = args[1]
+		}
+		return exportState(path)
+	case len(args) == 2 && args[0] == "import":
+		return importState(args[1])
+	default:
+		return usageError(c)
+	}
+}
+
+// exportState writes the session context to a file, or to standard output
+// if path is empty.
+func exportState(path string) error {
+	state := exportedState{
+		Profile:  activeProfileName,
+		Zone:     activeZoneName,
+		Settings: make(map[string]string),
+	}
+	if state.Zone == "" {
+		state.Zone = resumedZone
+	}
+	for _, s := range sessionSettings() {
+		state.Settings[s.name] = s.value
+	}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append(data, '\n')
+
+	if path == "" {
+		_, err := stdout.Write(data)
+		return err
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return err
+	}
+	printf("Session state exported to %s.\n", path)
+	return nil
+}
+
+// importState restores the session context exported to a file. The
+// profile is selected first, as selecting it resets the output format and
+// owner, then the zone and the settings that differ from the current ones.
+func importState(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	var state exportedState
+	if _, err := decodeJSON(data, &state); err != nil {
+		return argError(fmt.Errorf("%s: %v", path, err))
+	}
+
+	current := sessionSettings()
+	var names []string
+	for _, s := range current {
+		names = append(names, s.name)
+	}
+	for name := range state.Settings {
+		if !isOneOf(name, names) {
+			return argError(fmt.Errorf("%s: unknown setting %q%s", path, name, suggestion(name, names)))
+		}
+	}
+	if state.Profile != "" && state.Profile != activeProfileName {
+		if err := selectProfile(state.Profile); err != nil {
+			return err
+		}
+		printf("Active profile set to %s.\n", state.Profile)
+		current = sessionSettings()
+	}
+	if state.Zone != "" && state.Zone != activeZoneName {
+		if err := activateZone(state.Zone); err != nil {
+			return err
+		}
+	}
+	for _, s := range current {
+		if value, ok := state.Settings[s.name]; ok && value != s.value {
+			if err := applySetting(s.name, value); err != nil {
+				return fmt.Errorf("%s: %v", path, err)
+			}
+		}
+	}
+	return nil
+}
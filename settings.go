@@ -0,0 +1,169 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func cmdSettings(c *cmd.Command, args []string) error {
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		return listZoneSettings()
+	case "get":
+		if len(args) != 2 {
+			return usageError(c)
+		}
+		return getZoneSetting(args[1])
+	case "set":
+		if len(args) != 3 {
+			return usageError(c)
+		}
+		return setZoneSetting(args[1], parseSettingValue(args[2]))
+	default:
+		return usageError(c)
+	}
+}
+
+func cmdDevMode(c *cmd.Command, args []string) error {
+	switch len(args) {
+	case 0:
+		return getZoneSetting("development_mode")
+	case 1:
+		on, err := parseOnOff(args[0])
+		if err != nil {
+			return argError(err)
+		}
+		return setZoneSetting("development_mode", onOff(on))
+	default:
+		return usageError(c)
+	}
+}
+
+func listZoneSettings() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	resp, err := api.ZoneSettings(commandCtx, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+
+	settings := resp.Result
+	sort.Slice(settings, func(i, j int) bool { return settings[i].ID < settings[j].ID })
+
+	width := 0
+	for _, s := range settings {
+		width = max(width, len(s.ID))
+	}
+	for _, s := range settings {
+		fmt.Fprintf(output, "%-*s %s\n", width, s.ID, formatSettingValue(s.Value))
+	}
+	return nil
+}
+
+func getZoneSetting(name string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	s, err := api.GetZoneSetting(commandCtx, zoneID,
+		cloudflare.GetZoneSettingParams{Name: settingName(name)})
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintln(output, formatSettingValue(s.Value))
+	if s.TimeRemaining > 0 {
+		printf("Time remaining: %d seconds\n", s.TimeRemaining)
+	}
+	return nil
+}
+
+func setZoneSetting(name string, value any) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	name = settingName(name)
+	_, err = api.UpdateZoneSetting(commandCtx, zoneID,
+		cloudflare.UpdateZoneSettingParams{Name: name, Value: value})
+	if err != nil {
+		return err
+	}
+
+	printf("Setting %s updated.\n", name)
+	return nil
+}
+
+// settingName converts a setting name as typed by the user, such as
+// always-use-https, into its API identifier.
+func settingName(name string) string {
+	return strings.ReplaceAll(strings.ToLower(name), "-", "_")
+}
+
+// parseSettingValue converts a setting value argument into the value sent
+// to the API. JSON objects and arrays are decoded, integers are sent as
+// numbers, and everything else is sent as a string.
+func parseSettingValue(s string) any {
+	if strings.HasPrefix(s, "{") || strings.HasPrefix(s, "[") {
+		var v any
+		if err := json.Unmarshal([]byte(s), &v); err == nil {
+			return v
+		}
+	}
+	if n, err := strconv.Atoi(s); err == nil {
+		return n
+	}
+	return s
+}
+
+func formatSettingValue(v any) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case nil:
+		return "-"
+	default:
+		b, err := json.Marshal(v)
+		if err != nil {
+			return fmt.Sprint(v)
+		}
+		return string(b)
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// Shadow-write settings, changed by --shadow, --shadow-only and "set".
+var (
+	// shadowZone, when not empty, is the name of the staging zone that
+	// record changes are also made in.
+	shadowZone string
+
+	// shadowOnly is true if record changes are made only in the staging
+	// zone, leaving the zones they were meant for untouched.
+	shadowOnly bool
+)
+
+// A shadowBackend repeats the record changes made in a zone in the staging
+// zone, moving record names from one zone to the other. The change is made
+// in the staging zone first, so a change that fails there is never made in
+// the real zone. Records are read from the real zone, so in shadow-only
+// mode commands plan their changes against the records they would change.
+type shadowBackend struct {
+	cflib.Backend
+}
+
+// shadowTarget returns the name of the zone with an ID and the ID of the
+// staging zone. The ID of the staging zone is empty when zoneID is the
+// staging zone itself, whose changes are not shadowed.
+func (b shadowBackend) shadowTarget(ctx context.Context, zoneID string) (zone, stagingID string, err error) {
+	zone, err = lookupZoneName(ctx, zoneID)
+	if err != nil {
+		return "", "", err
+	}
+	if cflib.NormalizeName(zone) == cflib.NormalizeName(shadowZone) {
+		return zone, "", nil
+	}
+	stagingID, err = b.Backend.ZoneIDByName(shadowZone)
+	if err != nil {
+		return "", "", zoneError(err)
+	}
+	return zone, stagingID, nil
+}
+
+// shadowRecord returns the record in the staging zone corresponding to a
+// record in the real zone, or nil if the staging zone has no such record.
+// The record with the same type, name and content corresponds to it, or
+// else the only record with the same type and name, whose content may have
+// been changed in shadow-only mode.
+func (b shadowBackend) shadowRecord(ctx context.Context, stagingID, zone string,
+	r cloudflare.DNSRecord) (*cloudflare.DNSRecord, error) {
+
+	recs, err := b.Backend.ListDNSRecords(ctx, stagingID, cloudflare.ListDNSRecordsParams{
+		Type: r.Type,
+		Name: rezoneName(r.Name, zone, shadowZone),
+	})
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range recs {
+		if cflib.ContentEqual(r.Type, s.Content, r.Content) {
+			return &s, nil
+		}
+	}
+	if len(recs) == 1 {
+		return &recs[0], nil
+	}
+	return nil, nil
+}
+
+func (b shadowBackend) CreateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.CreateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	zone, stagingID, err := b.shadowTarget(ctx, zoneID)
+	if err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+	if stagingID == "" {
+		return b.Backend.CreateDNSRecord(ctx, zoneID, params)
+	}
+
+	shadow := params
+	shadow.Name = rezoneName(params.Name, zone, shadowZone)
+	rec, err := b.Backend.CreateDNSRecord(ctx, stagingID, shadow)
+	if err != nil || shadowOnly {
+		return rec, err
+	}
+	return b.Backend.CreateDNSRecord(ctx, zoneID, params)
+}
+
+// UpdateDNSRecord updates the staging zone's copy of the record, or creates
+// the updated record there if the staging zone has no copy.
+func (b shadowBackend) UpdateDNSRecord(ctx context.Context, zoneID string,
+	params cloudflare.UpdateDNSRecordParams) (cloudflare.DNSRecord, error) {
+
+	zone, stagingID, err := b.shadowTarget(ctx, zoneID)
+	if err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+	if stagingID == "" {
+		return b.Backend.UpdateDNSRecord(ctx, zoneID, params)
+	}
+
+	before, err := b.Backend.GetDNSRecord(ctx, zoneID, params.ID)
+	if err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+	shadow, err := b.shadowRecord(ctx, stagingID, zone, before)
+	if err != nil {
+		return cloudflare.DNSRecord{}, err
+	}
+
+	var rec cloudflare.DNSRecord
+	if shadow != nil {
+		p := params
+		p.ID = shadow.ID
+		if p.Name != "" {
+			p.Name = rezoneName(p.Name, zone, shadowZone)
+		}
+		rec, err = b.Backend.UpdateDNSRecord(ctx, stagingID, p)
+	} else {
+		rec, err = b.Backend.CreateDNSRecord(ctx, stagingID, shadowCreateParams(before, params, zone))
+	}
+	if err != nil || shadowOnly {
+		return rec, err
+	}
+	return b.Backend.UpdateDNSRecord(ctx, zoneID, params)
+}
+
+// DeleteDNSRecord deletes the staging zone's copy of the record, if it has
+// one.
+func (b shadowBackend) DeleteDNSRecord(ctx context.Context, zoneID, id string) error {
+	zone, stagingID, err := b.shadowTarget(ctx, zoneID)
+	if err != nil {
+		return err
+	}
+	if stagingID == "" {
+		return b.Backend.DeleteDNSRecord(ctx, zoneID, id)
+	}
+
+	before, err := b.Backend.GetDNSRecord(ctx, zoneID, id)
+	if err != nil {
+		return err
+	}
+	shadow, err := b.shadowRecord(ctx, stagingID, zone, before)
+	if err != nil {
+		return err
+	}
+	if shadow != nil {
+		if err := b.Backend.DeleteDNSRecord(ctx, stagingID, shadow.ID); err != nil {
+			return err
+		}
+	}
+	if shadowOnly {
+		return nil
+	}
+	return b.Backend.DeleteDNSRecord(ctx, zoneID, id)
+}
+
+// shadowCreateParams returns the parameters creating, in the staging zone,
+// a record as it is after an update.
+func shadowCreateParams(before cloudflare.DNSRecord, params cloudflare.UpdateDNSRecordParams,
+	zone string) cloudflare.CreateDNSRecordParams {
+
+	p := cloudflare.CreateDNSRecordParams{
+		Type:     before.Type,
+		Name:     before.Name,
+		Content:  before.Content,
+		TTL:      before.TTL,
+		Proxied:  before.Proxied,
+		Priority: before.Priority,
+		Data:     before.Data,
+		Comment:  before.Comment,
+		Tags:     before.Tags,
+	}
+	if params.Type != "" {
+		p.Type = params.Type
+	}
+	if params.Name != "" {
+		p.Name = params.Name
+	}
+	if params.Content != "" {
+		p.Content = params.Content
+	}
+	if params.TTL != 0 {
+		p.TTL = params.TTL
+	}
+	if params.Proxied != nil {
+		p.Proxied = params.Proxied
+	}
+	if params.Priority != nil {
+		p.Priority = params.Priority
+	}
+	if params.Data != nil {
+		p.Data = params.Data
+	}
+	if params.Comment != nil {
+		p.Comment = *params.Comment
+	}
+	if params.Tags != nil {
+		p.Tags = params.Tags
+	}
+	p.Name = rezoneName(p.Name, zone, shadowZone)
+	return p
+}
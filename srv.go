@@ -0,0 +1,247 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+	"golang.org/x/term"
+)
+
+// An srvService is the SRV record layout of a common protocol, as created
+// by "srv wizard".
+type srvService struct {
+	label string // the service and protocol labels, such as "_sip._tls"
+	port  int    // the protocol's usual port
+	about string
+}
+
+// srvServices are the protocols "srv wizard" knows, by the name given on
+// the command line.
+var srvServices = map[string]srvService{
+	"sip":          {"_sip._tls", 5061, "SIP over TLS"},
+	"xmpp-client":  {"_xmpp-client._tcp", 5222, "XMPP client connections"},
+	"xmpp-server":  {"_xmpp-server._tcp", 5269, "XMPP server federation"},
+	"minecraft":    {"_minecraft._tcp", 25565, "Minecraft Java Edition servers"},
+	"autodiscover": {"_autodiscover._tcp", 443, "Exchange and Outlook autodiscover"},
+	"imaps":        {"_imaps._tcp", 993, "IMAP over TLS mail access"},
+	"submission":   {"_submission._tcp", 587, "mail submission"},
+	"caldavs":      {"_caldavs._tcp", 443, "CalDAV over TLS calendars"},
+	"carddavs":     {"_carddavs._tcp", 443, "CardDAV over TLS contacts"},
+}
+
+// The priority and weight of the records created by "srv wizard", which
+// point each service at a single host.
+const (
+	srvPriority = 10
+	srvWeight   = 5
+)
+
+var srvWizardFlags = registerFlags("srv wizard", mergeFlags(forceFlags, flagSpec{
+	"host": true,
+	"port": true,
+	"name": true,
+}))
+
+func cmdSRV(c *cmd.Command, args []string) error {
+	switch {
+	case len(args) >= 1 && args[0] == "wizard":
+		flags, args, err := parseFlags(args[1:], srvWizardFlags)
+		if err != nil {
+			return err
+		}
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		return srvWizard(args[0], flags)
+	case len(args) == 1 && args[0] == "services":
+		for _, name := range srvServiceNames() {
+			s := srvServices[name]
+			fmt.Fprintf(output, "%-13s %-19s %5d  %s\n", name, s.label, s.port, s.about)
+		}
+		return nil
+	default:
+		return usageError(c)
+	}
+}
+
+func srvServiceNames() []string {
+	names := make([]string, 0, len(srvServices))
+	for name := range srvServices {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// srvWizard creates or updates the SRV record of a service, asking for the
+// host and port not given by flags when standard input is a terminal.
+func srvWizard(service string, flags flagValues) error {
+	s, ok := srvServices[strings.ToLower(service)]
+	if !ok {
+		return argError(fmt.Errorf("unknown service %q%s; \"srv services\" lists the known ones",
+			service, suggestion(strings.ToLower(service), srvServiceNames())))
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+	domain := flags.get("name", activeZoneName)
+	if !inZone(domain, activeZoneName) {
+		return argError(fmt.Errorf("%s is not in zone %s", domain, activeZoneName))
+	}
+	name := s.label + "." + cflib.NormalizeName(domain)
+
+	ask := term.IsTerminal(int(os.Stdin.Fd()))
+	host := flags.get("host", "")
+	if host == "" && ask {
+		if host, err = readString(sprintf("Host providing %s: ", s.about)); err != nil {
+			return err
+		}
+	}
+	if host = strings.TrimSpace(host); host == "" {
+		return argError(errors.New("--host is required"))
+	}
+	if _, err := netip.ParseAddr(host); err == nil {
+		return argError(fmt.Errorf("the host must be a name, not the address %s", host))
+	}
+	if err := validateName(host, false); err != nil {
+		return argError(err)
+	}
+	host = cflib.NormalizeName(host)
+
+	port := strconv.Itoa(s.port)
+	if flags.has("port") {
+		port = flags.get("port", "")
+	} else if ask {
+		answer, err := readString(sprintf("Port [%d]: ", s.port))
+		if err != nil {
+			return err
+		}
+		if answer = strings.TrimSpace(answer); answer != "" {
+			port = answer
+		}
+	}
+	p, err := strconv.Atoi(port)
+	if err != nil || p < 1 || p > 65535 {
+		return argError(fmt.Errorf("invalid port %q", port))
+	}
+
+	return setSRV(zoneID, name, host, p, flags.force())
+}
+
+// setSRV makes a service's SRV record point at a host and port, updating
+// an existing record of the name or creating one. Other records of the name
+// are deleted after asking for confirmation.
+func setSRV(zoneID *cloudflare.ResourceContainer, name, host string, port int, force bool) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	existing, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: "SRV", Name: name})
+	if err != nil {
+		return err
+	}
+
+	priority := uint16(srvPriority)
+	content := fmt.Sprintf("%d %d %s", srvWeight, port, host)
+	data := map[string]any{
+		"priority": srvPriority,
+		"weight":   srvWeight,
+		"port":     port,
+		"target":   host,
+	}
+
+	if len(existing) == 1 {
+		if p, t := srvTarget(existing[0]); p == port && t == host {
+			printf("SRV record %s already points at %s port %d.\n", name, host, port)
+			return nil
+		}
+	}
+	if len(existing) > 0 && !force {
+		printf("Replacing the SRV record(s) of %s:\n", name)
+		for _, r := range existing {
+			p, t := srvTarget(r)
+			fmt.Fprintf(output, "    %s port %d\n", t, p)
+		}
+		if !confirm(sprintf("Point %s at %s port %d instead?", name, host, port)) {
+			printf("No changes applied.\n")
+			return nil
+		}
+	}
+	if err := checkOwner(api, zoneID, "SRV", name); err != nil {
+		return err
+	}
+
+	b := recordBackend(api)
+	if len(existing) == 0 {
+		_, err = b.CreateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.CreateDNSRecordParams{
+			Type:     "SRV",
+			Name:     name,
+			Content:  content,
+			Data:     data,
+			Priority: &priority,
+			TTL:      defaultTTL,
+		})
+		if err == nil {
+			err = markOwned(api, zoneID, "SRV", name)
+		}
+	} else {
+		r := existing[0]
+		_, err = b.UpdateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.UpdateDNSRecordParams{
+			ID:       r.ID,
+			Type:     r.Type,
+			Name:     r.Name,
+			Content:  content,
+			Data:     data,
+			Priority: &priority,
+			TTL:      r.TTL,
+			Comment:  &r.Comment,
+			Tags:     r.Tags,
+		})
+	}
+	if err != nil {
+		return err
+	}
+	for _, r := range existing[min(1, len(existing)):] {
+		if err := b.DeleteDNSRecord(commandCtx, zoneID.Identifier, r.ID); err != nil {
+			return err
+		}
+		p, t := srvTarget(r)
+		printf("Deleted SRV record %s (%s port %d).\n", r.Name, t, p)
+	}
+	printf("SRV record %s points at %s port %d.\n", name, host, port)
+	return nil
+}
+
+// srvTarget returns the port and target of an SRV record, from its
+// structured data or else its content.
+func srvTarget(r cloudflare.DNSRecord) (int, string) {
+	if m := dataMap(r.Data); m != nil {
+		if port, ok := m["port"].(float64); ok {
+			target, _ := m["target"].(string)
+			return int(port), cflib.NormalizeName(target)
+		}
+		if port, ok := m["port"].(int); ok {
+			target, _ := m["target"].(string)
+			return port, cflib.NormalizeName(target)
+		}
+	}
+	if f := strings.Fields(r.Content); len(f) == 3 {
+		port, _ := strconv.Atoi(f[1])
+		return port, cflib.NormalizeName(f[2])
+	}
+	return 0, ""
+}
@@ -0,0 +1,53 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSRVWizard(t *testing.T) {
+	b := useMemoryBackend(t)
+
+	if err := processCmd("srv wizard --host sip.example.net sip"); err != nil {
+		t.Fatal(err)
+	}
+	if err := processCmd("srv wizard --host mc.example.com --port 25570 --name games.example.com minecraft"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"SRV _minecraft._tcp.games.example.com 5 25570 mc.example.com",
+		"SRV _sip._tls.example.com 5 5061 sip.example.net")
+
+	// Running the wizard again updates the record in place.
+	if err := processCmd("srv wizard --force --host sip2.example.net --port 443 sip"); err != nil {
+		t.Fatal(err)
+	}
+	checkRecords(t, b,
+		"SRV _minecraft._tcp.games.example.com 5 25570 mc.example.com",
+		"SRV _sip._tls.example.com 5 443 sip2.example.net")
+	for _, r := range b.Records() {
+		if r.Priority == nil || *r.Priority != srvPriority {
+			t.Errorf("%s has priority %v", r.Name, r.Priority)
+		}
+	}
+
+	for _, c := range []string{
+		"srv wizard --host sip.example.net sipp",
+		"srv wizard sip",
+		"srv wizard --host 10.0.0.1 sip",
+		"srv wizard --host sip.example.net --port 70000 sip",
+		"srv wizard --host sip.example.net --name example.org sip",
+	} {
+		if err := processCmd(c); err == nil {
+			t.Errorf("%s: no error", c)
+		}
+	}
+	err := processCmd("srv wizard --host sip.example.net minecraf")
+	if err == nil || !strings.Contains(err.Error(), "did you mean minecraft") {
+		t.Errorf("unknown service error: %v", err)
+	}
+}
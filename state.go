@@ -0,0 +1,119 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// stateDir returns the directory holding cf's persistent state, such as the
+// command history, change journal, snapshots and caches. It follows the XDG
+// base directory specification, and may be overridden with the
+// CLOUDFLARE_STATE_DIR environment variable.
+func stateDir() (string, error) {
+	if d := os.Getenv("CLOUDFLARE_STATE_DIR"); d != "" {
+		return d, nil
+	}
+	if d := os.Getenv("XDG_STATE_HOME"); d != "" {
+		return filepath.Join(d, "cf"), nil
+	}
+
+	switch runtime.GOOS {
+	case "windows", "darwin":
+		d, err := os.UserConfigDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(d, "cf", "state"), nil
+	default:
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return "", err
+		}
+		return filepath.Join(home, ".local", "state", "cf"), nil
+	}
+}
+
+// statePath returns the path of a file or directory within the state
+// directory, creating the directories leading to it.
+func statePath(elem ...string) (string, error) {
+	dir, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	p := filepath.Join(append([]string{dir}, elem...)...)
+	if err := os.MkdirAll(filepath.Dir(p), 0700); err != nil {
+		return "", err
+	}
+	return p, nil
+}
+
+// withStateLock runs fn while holding an exclusive lock on the state
+// directory, so that concurrent cf processes do not modify shared state at
+// the same time.
+func withStateLock(fn func() error) error {
+	path, err := statePath("lock")
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if err := lockFile(file); err != nil {
+		return err
+	}
+	defer unlockFile(file)
+
+	return fn()
+}
+
+// writeStateFile atomically replaces the contents of a state file.
+func writeStateFile(path string, data []byte) error {
+	return writeFileAtomic(path, data, 0600)
+}
+
+// writeFileAtomic atomically replaces the contents of a file. The data is
+// written to a temporary file and synced before being renamed over the
+// original, so a crash never leaves a partially written file behind.
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// migrateStateFile moves a file from its location in an earlier version of
+// cf into the state directory, unless the state directory already has one.
+func migrateStateFile(oldPath, newPath string) {
+	if _, err := os.Stat(newPath); !errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	os.Rename(oldPath, newPath)
+}
@@ -0,0 +1,210 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// runSummary counts the record changes made by the running command, and
+// its failed operations, for the summary line ending a bulk command in
+// non-interactive mode. Each command is given a run ID, recorded with its
+// changes in the journal.
+var runSummary struct {
+	mu      sync.Mutex
+	run     string
+	bulk    bool // the command ran operations through the scheduler
+	created int
+	updated int
+	deleted int
+	failed  int
+
+	// The totals of every command run since the program started, for the
+	// summary file written by --summary, and whether a long-running
+	// command has written the file after a check.
+	total   runTotals
+	written bool
+}
+
+// runTotals are the totals of a summary file.
+type runTotals struct {
+	created  int
+	updated  int
+	deleted  int
+	failed   int
+	apiCalls int
+}
+
+// summaryFile is the file to which the run summary is written by --summary,
+// in Prometheus text format if it ends in .prom and in JSON otherwise.
+var summaryFile string
+
+// programStart is the time the program started.
+var programStart = time.Now()
+
+// startRun resets the summary for a new command.
+func startRun() {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	runSummary.run = strconv.FormatInt(time.Now().UnixNano(), 36)
+	runSummary.bulk = false
+	runSummary.created, runSummary.updated, runSummary.deleted, runSummary.failed = 0, 0, 0, 0
+}
+
+// currentRun returns the run ID of the running command.
+func currentRun() string {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	return runSummary.run
+}
+
+// noteRunChange counts a change recorded in the journal.
+func noteRunChange(action string) {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	switch action {
+	case "create":
+		runSummary.created++
+		runSummary.total.created++
+	case "update":
+		runSummary.updated++
+		runSummary.total.updated++
+	case "delete":
+		runSummary.deleted++
+		runSummary.total.deleted++
+	}
+}
+
+// noteRunFailure counts a failed change made outside the scheduler, such as
+// a failed update of a long-running command.
+func noteRunFailure() {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	runSummary.failed++
+	runSummary.total.failed++
+}
+
+// noteAPICall counts a request sent to the API.
+func noteAPICall() {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	runSummary.total.apiCalls++
+}
+
+// noteRunResults counts the failures of a batch of scheduled operations.
+func noteRunResults(errs []error) {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	runSummary.bulk = true
+	for _, err := range errs {
+		if err != nil {
+			runSummary.failed++
+			runSummary.total.failed++
+		}
+	}
+}
+
+// printRunSummary displays the summary line of a bulk command, such as
+//
+//	summary: created=2 updated=1 deleted=0 failed=0 journal=lt3qx0m1w2
+//
+// The journal value is the run ID recorded with the command's changes.
+func printRunSummary() {
+	runSummary.mu.Lock()
+	defer runSummary.mu.Unlock()
+	if !runSummary.bulk {
+		return
+	}
+	fmt.Fprintf(output, "summary: created=%d updated=%d deleted=%d failed=%d journal=%s\n",
+		runSummary.created, runSummary.updated, runSummary.deleted, runSummary.failed, runSummary.run)
+}
+
+// writeSummaryFile writes the totals of the commands run so far, the time
+// since the program started and whether it succeeded to the summary file,
+// if --summary was given. Monitoring can then alert when a scheduled run
+// starts failing. Errors are only reported, as the run itself is over.
+func writeSummaryFile(command string, code int) {
+	if summaryFile == "" {
+		return
+	}
+	runSummary.mu.Lock()
+	t := runSummary.total
+	runSummary.written = true
+	runSummary.mu.Unlock()
+
+	now := time.Now()
+	duration := now.Sub(programStart).Seconds()
+	var data []byte
+	if strings.HasSuffix(summaryFile, ".prom") {
+		data = formatPromSummary(command, code, t, duration, now)
+	} else {
+		data, _ = json.MarshalIndent(map[string]any{
+			"command":          command,
+			"success":          code == exitSuccess,
+			"exit_code":        code,
+			"created":          t.created,
+			"updated":          t.updated,
+			"deleted":          t.deleted,
+			"failed":           t.failed,
+			"api_calls":        t.apiCalls,
+			"duration_seconds": duration,
+			"time":             now.UTC().Format(time.RFC3339),
+		}, "", "  ")
+		data = append(data, '\n')
+	}
+	if err := writeFileAtomic(summaryFile, data, 0o644); err != nil {
+		printf("Unable to write the run summary: %v\n", err)
+	}
+}
+
+// finishSummaryFile writes the summary file as the program exits, unless a
+// long-running command, such as ddns --once, has written it after its last
+// check, whose result it keeps.
+func finishSummaryFile(command string, code int) {
+	runSummary.mu.Lock()
+	written := runSummary.written
+	runSummary.mu.Unlock()
+	if !written {
+		writeSummaryFile(command, code)
+	}
+}
+
+// formatPromSummary formats a run summary as Prometheus metrics, for the
+// textfile collector of the node exporter.
+func formatPromSummary(command string, code int, t runTotals, duration float64, now time.Time) []byte {
+	var b bytes.Buffer
+	label := fmt.Sprintf("command=%q", command)
+	metric := func(name, help string, values ...string) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s gauge\n", name, help, name)
+		for _, v := range values {
+			fmt.Fprintf(&b, "%s%s\n", name, v)
+		}
+	}
+	metric("cf_run_records", "Records changed by the last run, by action.",
+		fmt.Sprintf("{%s,action=\"created\"} %d", label, t.created),
+		fmt.Sprintf("{%s,action=\"updated\"} %d", label, t.updated),
+		fmt.Sprintf("{%s,action=\"deleted\"} %d", label, t.deleted))
+	metric("cf_run_failed", "Changes that failed during the last run.",
+		fmt.Sprintf("{%s} %d", label, t.failed))
+	metric("cf_run_api_calls", "API requests sent during the last run.",
+		fmt.Sprintf("{%s} %d", label, t.apiCalls))
+	metric("cf_run_duration_seconds", "Duration of the last run.",
+		fmt.Sprintf("{%s} %.3f", label, duration))
+	success := 0
+	if code == exitSuccess {
+		success = 1
+	}
+	metric("cf_run_success", "Whether the last run succeeded.",
+		fmt.Sprintf("{%s} %d", label, success))
+	metric("cf_run_timestamp_seconds", "Time the last run ended.",
+		fmt.Sprintf("{%s} %d", label, now.Unix()))
+	return b.Bytes()
+}
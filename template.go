@@ -0,0 +1,127 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/beevik/cmd"
+)
+
+// templateFuncs are the functions available to record templates, in
+// addition to Go's built-in template functions.
+var templateFuncs = template.FuncMap{
+	"split": func(s, sep string) []string {
+		var fields []string
+		for _, f := range strings.Split(s, sep) {
+			if f = strings.TrimSpace(f); f != "" {
+				fields = append(fields, f)
+			}
+		}
+		return fields
+	},
+	"default": func(def, v string) string {
+		if v == "" {
+			return def
+		}
+		return v
+	},
+}
+
+// missingTemplateKey matches the error of a template using a parameter
+// that was not given.
+var missingTemplateKey = regexp.MustCompile(`map has no entry for key "([^"]*)"`)
+
+func cmdTemplate(c *cmd.Command, args []string) error {
+	if len(args) < 2 || (args[0] != "apply" && args[0] != "show") {
+		return usageError(c)
+	}
+
+	lines, err := expandTemplate(args[1], args[2:], activeZoneName)
+	if err != nil {
+		return err
+	}
+
+	if args[0] == "show" {
+		for _, l := range lines {
+			fmt.Fprintf(output, "%-6s %-30s %s\n", l.Type, l.Name, l.Content)
+		}
+		return nil
+	}
+
+	if len(lines) == 0 {
+		printf("The template contains no records.\n")
+		return nil
+	}
+	for _, l := range lines {
+		if err := validateRecord(l.Type, l.Name, l.Content); err != nil {
+			return argError(fmt.Errorf("%s record %s: %v", l.Type, l.Name, err))
+		}
+		if err := validateApex(l.Type, l.Name, activeZoneName); err != nil {
+			return argError(err)
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	return upsertLines(api, zoneID, lines)
+}
+
+// expandTemplate expands a record template with the key=value parameters
+// and the zone, available to the template as .Zone. The expanded template
+// holds one record per line, in the format read by "upsert -". Record names
+// not in the zone, such as "www" or "@", are completed relative to it.
+func expandTemplate(filename string, params []string, zone string) ([]upsertLine, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := map[string]string{"Zone": zone}
+	for _, p := range params {
+		k, v, ok := strings.Cut(p, "=")
+		if !ok || k == "" {
+			return nil, argError(fmt.Errorf("invalid template parameter %q, expected key=value", p))
+		}
+		vars[k] = v
+	}
+
+	t, err := template.New(filepath.Base(filename)).Funcs(templateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, argError(err)
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, vars); err != nil {
+		if m := missingTemplateKey.FindStringSubmatch(err.Error()); m != nil {
+			return nil, argError(fmt.Errorf("%v%s", err, suggestion(m[1], sortedKeys(vars))))
+		}
+		return nil, argError(err)
+	}
+
+	lines, err := readUpsertLines(&buf)
+	if err != nil {
+		return nil, argError(fmt.Errorf("%s: %v", filename, err))
+	}
+	for i := range lines {
+		if !inZone(lines[i].Name, zone) {
+			lines[i].Name = absoluteName(lines[i].Name, zone)
+		}
+	}
+	return lines, nil
+}
@@ -0,0 +1,285 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	"golang.org/x/term"
+)
+
+// The parameters of the time-based one-time passwords (RFC 6238) required
+// before deleting from the zones listed in the configuration file's
+// totp_zones. They are those of common authenticator apps.
+const (
+	totpPeriod = 30 * time.Second
+	totpDigits = 6
+	totpSkew   = 1 // periods either side of the current one accepted
+)
+
+// totpKeyringPrefix is the prefix of the keyring account names under which
+// the zones' TOTP secrets are stored, followed by the zone name.
+const totpKeyringPrefix = "totp:"
+
+// loadTOTPSecret returns the base32 TOTP secret of a zone from the system
+// keyring. Tests replace it.
+var loadTOTPSecret = func(zone string) (string, error) {
+	return keyringGet(keyringService, totpKeyringPrefix+zone)
+}
+
+// totpVerified holds, by zone, the run ID of the command for which a TOTP
+// code was last accepted, so that a command deleting many records asks for
+// a code only once.
+var totpVerified sync.Map
+
+// totpMu serializes the prompts for codes of concurrent requests.
+var totpMu sync.Mutex
+
+// A totpError reports a deletion refused because no valid TOTP code was
+// given for a zone requiring one.
+type totpError struct {
+	zone string
+	err  error
+}
+
+func (e *totpError) Error() string {
+	return fmt.Sprintf("deleting from zone %s requires a TOTP code: %v", e.zone, e.err)
+}
+
+// totpCode returns the code of a base32 secret for the period including
+// time t.
+func totpCode(secret string, t time.Time) (string, error) {
+	key, err := decodeTOTPSecret(secret)
+	if err != nil {
+		return "", err
+	}
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(t.Unix()/int64(totpPeriod/time.Second)))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:]) & 0x7fffffff
+	mod := uint32(1)
+	for i := 0; i < totpDigits; i++ {
+		mod *= 10
+	}
+	return fmt.Sprintf("%0*d", totpDigits, value%mod), nil
+}
+
+// checkTOTPCode reports whether a code is valid for a secret at time t,
+// allowing for clocks that are a period fast or slow.
+func checkTOTPCode(secret, code string, t time.Time) bool {
+	code = strings.ReplaceAll(strings.TrimSpace(code), " ", "")
+	for i := -totpSkew; i <= totpSkew; i++ {
+		want, err := totpCode(secret, t.Add(time.Duration(i)*totpPeriod))
+		if err != nil {
+			return false
+		}
+		if hmac.Equal([]byte(code), []byte(want)) {
+			return true
+		}
+	}
+	return false
+}
+
+func decodeTOTPSecret(secret string) ([]byte, error) {
+	secret = strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	key, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(strings.TrimRight(secret, "="))
+	if err != nil || len(key) == 0 {
+		return nil, errors.New("invalid TOTP secret")
+	}
+	return key, nil
+}
+
+// requiresTOTP reports whether deleting from a zone requires a TOTP code.
+func requiresTOTP(zone string) bool {
+	for _, z := range cfg.TOTPZones {
+		if zone != "" && cflib.NormalizeName(z) == cflib.NormalizeName(zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// verifyTOTP asks for the TOTP code of a zone, unless one was accepted
+// earlier in the running command. Without a terminal, the code is read
+// from CLOUDFLARE_TOTP_CODE.
+func verifyTOTP(zone string) error {
+	zone = cflib.NormalizeName(zone)
+	run := currentRun()
+	if v, ok := totpVerified.Load(zone); ok && v == run {
+		return nil
+	}
+
+	totpMu.Lock()
+	defer totpMu.Unlock()
+	if v, ok := totpVerified.Load(zone); ok && v == run {
+		return nil
+	}
+
+	secret, err := loadTOTPSecret(zone)
+	if err != nil || secret == "" {
+		return &totpError{zone, errors.New("its secret is missing from the system keyring")}
+	}
+
+	code := os.Getenv("CLOUDFLARE_TOTP_CODE")
+	if code == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return &totpError{zone, errors.New("set CLOUDFLARE_TOTP_CODE")}
+		}
+		if code, err = readString(sprintf("TOTP code for zone %s: ", zone)); err != nil {
+			fmt.Fprintln(output)
+			return &totpError{zone, err}
+		}
+	}
+	if !checkTOTPCode(secret, code, time.Now()) {
+		return &totpError{zone, errors.New("invalid code")}
+	}
+	totpVerified.Store(zone, run)
+	return nil
+}
+
+// A totpTransport refuses DELETE requests to the zones requiring a TOTP
+// code until a valid code is given. Dry runs delete nothing and are let
+// through, as are the deletions of cf's own zone lock markers.
+type totpTransport struct {
+	base http.RoundTripper
+}
+
+func (t *totpTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(cfg.TOTPZones) > 0 && !dryRun && req.Method == http.MethodDelete && !deletesLockMarker(req) {
+		zone, err := requestZone(req)
+		if err != nil {
+			return nil, err
+		}
+		if requiresTOTP(zone) {
+			if err := verifyTOTP(zone); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return t.base.RoundTrip(req)
+}
+
+// cmdTOTP lists the zones requiring a TOTP code, or adds the active zone to
+// them or removes it.
+func cmdTOTP(c *cmd.Command, args []string) error {
+	switch {
+	case len(args) == 0 || (args[0] == "list" && len(args) == 1):
+		return listTOTPZones()
+	case args[0] == "enable" && len(args) == 1:
+		return enableTOTP()
+	case args[0] == "disable" && len(args) == 1:
+		return disableTOTP()
+	default:
+		return usageError(c)
+	}
+}
+
+func listTOTPZones() error {
+	if len(cfg.TOTPZones) == 0 {
+		printf("No zones require a TOTP code.\n")
+		return nil
+	}
+	for _, z := range cfg.TOTPZones {
+		fmt.Fprintln(output, z)
+	}
+	return nil
+}
+
+// enableTOTP generates a TOTP secret for the active zone, displays it for
+// an authenticator app, and once a code generated from it is entered,
+// stores it in the system keyring and requires a code before deleting from
+// the zone.
+func enableTOTP() error {
+	if _, err := getZoneIdentifier(); err != nil {
+		return err
+	}
+	zone := cflib.NormalizeName(activeZoneName)
+	if requiresTOTP(zone) {
+		printf("Zone %s already requires a TOTP code.\n", zone)
+		return nil
+	}
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return errors.New("enabling TOTP requires a terminal")
+	}
+
+	key := make([]byte, 20)
+	if _, err := rand.Read(key); err != nil {
+		return err
+	}
+	secret := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(key)
+	uri := fmt.Sprintf("otpauth://totp/%s?secret=%s&issuer=cf",
+		url.PathEscape("cf:"+zone), secret)
+
+	printf("Add this secret to your authenticator app:\n")
+	fmt.Fprintf(output, "    %s\n    %s\n", secret, uri)
+	code, err := readString(tr("Enter the code it displays: "))
+	if err != nil {
+		fmt.Fprintln(output)
+		return err
+	}
+	if !checkTOTPCode(secret, code, time.Now()) {
+		return errors.New("invalid code; TOTP not enabled")
+	}
+
+	if err := keyringSet(keyringService, totpKeyringPrefix+zone, secret); err != nil {
+		return err
+	}
+	list := append(append([]string(nil), cfg.TOTPZones...), zone)
+	if err := saveConfigValue("totp_zones", list); err != nil {
+		return err
+	}
+	cfg.TOTPZones = list
+	printf("Deleting from zone %s now requires a TOTP code.\n", zone)
+	return nil
+}
+
+// disableTOTP stops requiring a TOTP code before deleting from the active
+// zone. Doing so requires a valid code itself.
+func disableTOTP() error {
+	if _, err := getZoneIdentifier(); err != nil {
+		return err
+	}
+	zone := cflib.NormalizeName(activeZoneName)
+	if !requiresTOTP(zone) {
+		return fmt.Errorf("zone %s does not require a TOTP code", zone)
+	}
+	if err := verifyTOTP(zone); err != nil {
+		return err
+	}
+
+	var list []string
+	for _, z := range cfg.TOTPZones {
+		if cflib.NormalizeName(z) != zone {
+			list = append(list, z)
+		}
+	}
+	if err := saveConfigValue("totp_zones", list); err != nil {
+		return err
+	}
+	cfg.TOTPZones = list
+	if err := keyringDelete(keyringService, totpKeyringPrefix+zone); err != nil {
+		printf("Error removing the secret from the system keyring: %v\n", err)
+	}
+	printf("Deleting from zone %s no longer requires a TOTP code.\n", zone)
+	return nil
+}
@@ -0,0 +1,108 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/base32"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestTOTPCode(t *testing.T) {
+	// The SHA-1 test vectors of RFC 6238, truncated to six digits.
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+	for _, test := range tests {
+		got, err := totpCode(secret, time.Unix(test.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != test.code {
+			t.Errorf("totpCode(%d) = %s, want %s", test.unix, got, test.code)
+		}
+	}
+
+	at := time.Unix(1111111109, 0)
+	if !checkTOTPCode(secret, "081 804", at) || !checkTOTPCode(secret, "081804", at.Add(totpPeriod)) {
+		t.Error("valid code refused")
+	}
+	if checkTOTPCode(secret, "081804", at.Add(3*totpPeriod)) || checkTOTPCode(secret, "", at) {
+		t.Error("invalid code accepted")
+	}
+	if _, err := totpCode("not base32!", at); err == nil {
+		t.Error("invalid secret accepted")
+	}
+}
+
+func TestTOTPTransport(t *testing.T) {
+	const secret = "JBSWY3DPEHPK3PXP"
+	savedCfg, savedLoad := cfg, loadTOTPSecret
+	defer func() {
+		cfg, loadTOTPSecret = savedCfg, savedLoad
+		activeZoneIdentifier, activeZoneName = nil, ""
+	}()
+	cfg = &config{TOTPZones: []string{"example.com"}}
+	loadTOTPSecret = func(zone string) (string, error) { return secret, nil }
+	activeZoneIdentifier, activeZoneName = cloudflare.ZoneIdentifier("z1"), "example.com"
+
+	deletes := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodDelete {
+			deletes++
+		}
+	}))
+	defer srv.Close()
+	client := &http.Client{Transport: &totpTransport{base: http.DefaultTransport}}
+	send := func(method string) error {
+		req, _ := http.NewRequest(method, srv.URL+"/zones/z1/dns_records/r1", nil)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+		}
+		return err
+	}
+
+	startRun()
+	var denied *totpError
+	t.Setenv("CLOUDFLARE_TOTP_CODE", "000000x")
+	if err := send(http.MethodDelete); !errors.As(err, &denied) {
+		t.Errorf("delete with invalid code: err = %v", err)
+	}
+	if err := send(http.MethodGet); err != nil {
+		t.Errorf("get: %v", err)
+	}
+
+	code, _ := totpCode(secret, time.Now())
+	t.Setenv("CLOUDFLARE_TOTP_CODE", code)
+	if err := send(http.MethodDelete); err != nil {
+		t.Fatalf("delete with valid code: %v", err)
+	}
+
+	// The code is accepted once per command.
+	t.Setenv("CLOUDFLARE_TOTP_CODE", "")
+	if err := send(http.MethodDelete); err != nil {
+		t.Errorf("second delete: %v", err)
+	}
+	startRun()
+	if err := send(http.MethodDelete); !errors.As(err, &denied) {
+		t.Errorf("delete in the next command: err = %v", err)
+	}
+	if deletes != 2 {
+		t.Errorf("deletes = %d, want 2", deletes)
+	}
+}
@@ -0,0 +1,131 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+type traceStep struct {
+	StepName    string      `json:"step_name"`
+	Type        string      `json:"type"`
+	Matched     bool        `json:"matched"`
+	PublicName  string      `json:"public_name"`
+	Name        string      `json:"name"`
+	Action      string      `json:"action"`
+	Description string      `json:"description"`
+	Expression  string      `json:"expression"`
+	Trace       []traceStep `json:"trace"`
+}
+
+type traceResult struct {
+	StatusCode int         `json:"status_code"`
+	Trace      []traceStep `json:"trace"`
+}
+
+var traceFlags = registerFlags("trace", flagSpec{
+	"method": true,
+	"all":    false,
+})
+
+func cmdTrace(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, traceFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	accountID, err := getAccountID(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	url := args[0]
+	if !strings.Contains(url, "://") {
+		url = "https://" + url
+	}
+
+	body := map[string]string{
+		"url":    url,
+		"method": strings.ToUpper(flags.get("method", "GET")),
+	}
+	r, err := api.Raw(commandCtx, http.MethodPost,
+		"/accounts/"+accountID+"/request-tracer/trace", body, nil)
+	if err != nil {
+		return err
+	}
+
+	var result traceResult
+	if err := json.Unmarshal(r.Result, &result); err != nil {
+		return err
+	}
+
+	if result.StatusCode != 0 {
+		printf("Status code: %d\n", result.StatusCode)
+	}
+	displayTrace(result.Trace, 0, flags.has("all"))
+	return nil
+}
+
+// displayTrace prints the steps of a request trace as an indented tree.
+// Unless all is true, steps that did not match the request are omitted.
+func displayTrace(steps []traceStep, depth int, all bool) {
+	for _, s := range steps {
+		if !s.Matched && !all {
+			continue
+		}
+
+		name := s.PublicName
+		if name == "" {
+			name = s.StepName
+		}
+		if name == "" {
+			name = s.Name
+		}
+		if name == "" {
+			name = s.Description
+		}
+
+		line := fmt.Sprintf("%s[%s] %s", strings.Repeat("  ", depth), s.Type, name)
+		if s.Action != "" {
+			line += " -> " + s.Action
+		}
+		if !s.Matched {
+			line += " (not matched)"
+		}
+		fmt.Fprintln(output, line)
+
+		if s.Expression != "" {
+			fmt.Fprintf(output, "%s  when %s\n", strings.Repeat("  ", depth), s.Expression)
+		}
+		displayTrace(s.Trace, depth+1, all)
+	}
+}
+
+func getAccountID(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) (string, error) {
+	zone, err := api.ZoneDetails(commandCtx, zoneID.Identifier)
+	if err != nil {
+		return "", err
+	}
+	return zone.Account.ID, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+)
+
+// autoTTLSeconds is the TTL Cloudflare serves for records with an
+// automatic TTL.
+const autoTTLSeconds = 300
+
+// effectiveTTL returns the TTL in seconds with which a record is served.
+func effectiveTTL(ttl int) int {
+	if seconds := cflib.NormalizeTTL(ttl); seconds != cflib.TTLAuto {
+		return seconds
+	}
+	return autoTTLSeconds
+}
+
+// A ttlPolicy, defined in the configuration file, bounds the TTLs of the
+// records of some types in some zones. Changes violating a policy produce a
+// warning, or are refused if Block is true, and lint reports the records
+// violating any policy. Proxied records are exempt, since Cloudflare
+// answers for them with its own TTL.
+type ttlPolicy struct {
+	Zones []string `json:"zones,omitempty"` // all zones if empty
+	Types []string `json:"types,omitempty"` // all types if empty
+	Min   int      `json:"min,omitempty"`
+	Max   int      `json:"max,omitempty"`
+	Block bool     `json:"block,omitempty"`
+}
+
+// parse validates a TTL policy's settings.
+func (p *ttlPolicy) parse() error {
+	if p.Min < 0 || p.Max < 0 || (p.Min == 0 && p.Max == 0) || (p.Max != 0 && p.Min > p.Max) {
+		return fmt.Errorf("invalid TTL policy bounds %d to %d", p.Min, p.Max)
+	}
+	return nil
+}
+
+// covers reports whether the policy applies to records of a type in a zone.
+func (p *ttlPolicy) covers(zone, recType string) bool {
+	zoneOK, typeOK := len(p.Zones) == 0, len(p.Types) == 0
+	for _, z := range p.Zones {
+		zoneOK = zoneOK || cflib.NormalizeName(z) == cflib.NormalizeName(zone)
+	}
+	for _, t := range p.Types {
+		typeOK = typeOK || strings.EqualFold(t, recType)
+	}
+	return zoneOK && typeOK
+}
+
+// violation describes how a TTL violates the policy, or returns the empty
+// string if it does not.
+func (p *ttlPolicy) violation(recType string, ttl int) string {
+	seconds := effectiveTTL(ttl)
+	switch {
+	case p.Min != 0 && seconds < p.Min:
+		return fmt.Sprintf("TTL %s is below the policy minimum of %s for %s records",
+			formatTTL(ttl), formatTTL(p.Min), strings.ToUpper(recType))
+	case p.Max != 0 && seconds > p.Max:
+		return fmt.Sprintf("TTL %s is above the policy maximum of %s for %s records",
+			formatTTL(ttl), formatTTL(p.Max), strings.ToUpper(recType))
+	}
+	return ""
+}
+
+// ttlViolations returns the violations of the TTL policies by a record, and
+// whether any of the policies violated blocks changes.
+func ttlViolations(zone, recType string, ttl int, proxied bool) (msgs []string, block bool) {
+	if proxied {
+		return nil, false
+	}
+	for i := range cfg.TTLPolicies {
+		p := &cfg.TTLPolicies[i]
+		if !p.covers(zone, recType) {
+			continue
+		}
+		if msg := p.violation(recType, ttl); msg != "" {
+			msgs = append(msgs, msg)
+			block = block || p.Block
+		}
+	}
+	return msgs, block
+}
+
+// checkTTLPolicies checks a change giving a record a TTL against the TTL
+// policies, displaying a warning for each violation, or returning an error
+// if a violated policy blocks changes.
+func checkTTLPolicies(zoneID, recType, name string, ttl int, proxied bool) error {
+	if len(cfg.TTLPolicies) == 0 {
+		return nil
+	}
+	msgs, block := ttlViolations(zoneName(zoneID), recType, ttl, proxied)
+	if block {
+		return argError(fmt.Errorf("%s record %s: %s", recType, name, strings.Join(msgs, "; ")))
+	}
+	for _, msg := range msgs {
+		printf("Warning: %s record %s: %s.\n", recType, name, msg)
+	}
+	return nil
+}
+
+// lintTTL flags the records violating a TTL policy.
+func lintTTL(recs []zoneRecord) []lintProblem {
+	var problems []lintProblem
+	for _, r := range recs {
+		msgs, _ := ttlViolations(r.zone, r.Type, r.TTL, isProxied(r.DNSRecord))
+		for _, msg := range msgs {
+			problems = append(problems, lintProblem{r, msg})
+		}
+	}
+	return problems
+}
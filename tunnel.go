@@ -0,0 +1,138 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// tunnelDomain is the domain under which Cloudflare Tunnels are addressed
+// by their IDs.
+const tunnelDomain = "cfargotunnel.com"
+
+func cmdTunnel(c *cmd.Command, args []string) error {
+	if len(args) < 1 {
+		return usageError(c)
+	}
+
+	switch args[0] {
+	case "list":
+		if len(args) != 1 {
+			return usageError(c)
+		}
+		return listTunnels()
+	case "route":
+		if len(args) != 3 {
+			return usageError(c)
+		}
+		return routeTunnel(args[1], args[2])
+	default:
+		return usageError(c)
+	}
+}
+
+// accountTunnels returns the tunnels of the active zone's account that have
+// not been deleted.
+func accountTunnels(api *cloudflare.API, zoneID *cloudflare.ResourceContainer) ([]cloudflare.Tunnel, error) {
+	accountID, err := getAccountID(api, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	deleted := false
+	tunnels, _, err := api.ListTunnels(commandCtx, cloudflare.AccountIdentifier(accountID),
+		cloudflare.TunnelListParams{IsDeleted: &deleted})
+	return tunnels, err
+}
+
+func listTunnels() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	tunnels, err := accountTunnels(api, zoneID)
+	if err != nil {
+		return err
+	}
+	if len(tunnels) == 0 {
+		printf("No tunnels found.\n")
+		return nil
+	}
+
+	widthName, widthStatus := 0, 0
+	for _, t := range tunnels {
+		widthName = max(widthName, len(t.Name))
+		widthStatus = max(widthStatus, len(t.Status))
+	}
+	for _, t := range tunnels {
+		fmt.Fprintf(output, "%-*s %s %-*s %d connection(s)\n", widthName, t.Name, t.ID,
+			widthStatus, t.Status, len(t.Connections))
+	}
+	return nil
+}
+
+// routeTunnel points a hostname at a tunnel, given by its name or ID.
+func routeTunnel(hostname, tunnel string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	tunnels, err := accountTunnels(api, zoneID)
+	if err != nil {
+		return err
+	}
+
+	var id string
+	for _, t := range tunnels {
+		if t.ID == tunnel || strings.EqualFold(t.Name, tunnel) {
+			id = t.ID
+			break
+		}
+	}
+	if id == "" {
+		return fmt.Errorf("tunnel %s not found", tunnel)
+	}
+
+	return setTunnelRoute(api, zoneID, hostname, id)
+}
+
+// setTunnelRoute creates or updates the proxied CNAME record pointing a
+// hostname at the tunnel with an ID.
+func setTunnelRoute(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, hostname, id string) error {
+	target := id + "." + tunnelDomain
+	if err := validateRecord("CNAME", hostname, target); err != nil {
+		return argError(err)
+	}
+	if err := validateApex("CNAME", hostname, activeZoneName); err != nil {
+		return argError(err)
+	}
+
+	proxied := true
+	changed, err := upsertRecord(api, zoneID, "CNAME", hostname, target, 0, recordMeta{proxied: &proxied})
+	if err != nil {
+		return err
+	}
+	if changed {
+		printf("Set %s record %s to %s.\n", "CNAME", hostname, target)
+	} else {
+		printf("%s record %s is already %s.\n", "CNAME", hostname, target)
+	}
+	return nil
+}
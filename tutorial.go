@@ -0,0 +1,174 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// tutorialContent is the content of the TXT record the tutorial creates.
+const tutorialContent = "created by the cf tutorial"
+
+// errTutorialQuit is returned by a tutorial step when the user quits.
+var errTutorialQuit = errors.New("tutorial ended")
+
+// cmdTutorial walks the user through cf's basic commands, running each one
+// through processCmd as if it had been typed, after showing it and asking
+// to go ahead. The test record it creates has a name no other record has,
+// and is deleted before the tutorial ends.
+func cmdTutorial(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+	if !interactive {
+		return errors.New("the tutorial runs only in interactive mode")
+	}
+
+	var created string
+	err := runTutorial(&created)
+	if created != "" {
+		printf("\nDeleting the test record %s.\n", created)
+		if derr := processCmd(fmt.Sprintf("delete --force TXT %s", created)); derr != nil && err == nil {
+			err = derr
+		}
+	}
+	if err == errTutorialQuit {
+		printf("Tutorial ended. Enter \"tutorial\" to start it again.\n")
+		return nil
+	}
+	return err
+}
+
+// runTutorial runs the steps of the tutorial. It sets created to the name
+// of the test record while the record exists.
+func runTutorial(created *string) error {
+	printf("This tutorial runs a few cf commands with you. Each command is shown\n" +
+		"before it runs; press Enter to run it, s to skip it, or q to quit.\n")
+
+	printf("\n1. Credentials. cf uses an API token from a profile, the\n" +
+		"CLOUDFLARE_API_TOKEN environment variable or the system keyring, and\n" +
+		"asks for one if there is none. \"account verify\" checks it.\n")
+	switch err := tutorialStep("account verify"); err {
+	case nil, errSkipped:
+	case errTutorialQuit:
+		return err
+	default:
+		printf("Fix the credentials, then start the tutorial again.\n")
+		return errTutorialQuit
+	}
+
+	printf("\n2. Zones. Every domain on Cloudflare is a zone. \"zones\" lists the zones\n" +
+		"the credentials can access.\n")
+	if err := tutorialStep("zones"); err == errTutorialQuit {
+		return err
+	}
+
+	printf("\n3. The active zone. Record commands work on the active zone, selected\n" +
+		"with \"zone <name>\". Choose a zone where a temporary test record does no\n" +
+		"harm.\n")
+	zone, err := readString(tr("Zone to use: "))
+	if err != nil {
+		return errTutorialQuit
+	}
+	if zone = strings.TrimSpace(zone); zone == "" {
+		if zone = activeZoneName; zone == "" {
+			return errTutorialQuit
+		}
+	}
+	switch err := tutorialStep("zone " + zone); err {
+	case nil:
+	case errSkipped:
+		if activeZoneName == "" {
+			printf("Without a zone, the rest of the tutorial is skipped.\n")
+			return nil
+		}
+		zone = activeZoneName
+	case errTutorialQuit:
+		return err
+	default:
+		printf("Check the zone's name in the list above, then start the tutorial again.\n")
+		return errTutorialQuit
+	}
+
+	printf("\n4. Listing records. \"list\" displays the zone's records; \"list TXT\" would\n" +
+		"display only its TXT records.\n")
+	if err := tutorialStep("list"); err == errTutorialQuit {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	rand.Read(suffix)
+	name := fmt.Sprintf("cf-tutorial-%s.%s", hex.EncodeToString(suffix), zone)
+	printf("\n5. Creating a record. \"txt\" creates a TXT record, or updates the record\n" +
+		"with the name. The test record's name is new, so no existing record\n" +
+		"changes; the tutorial deletes it at the end.\n")
+	switch err := tutorialStep(fmt.Sprintf("txt %s %q", name, tutorialContent)); err {
+	case nil:
+		*created = name
+	case errSkipped:
+		printf("Without the test record, the rest of the tutorial is skipped.\n")
+		return nil
+	default:
+		return errTutorialQuit
+	}
+
+	printf("\n6. Inspecting a record. \"show\" displays every field of the records with\n" +
+		"a type and name.\n")
+	if err := tutorialStep("show TXT " + name); err == errTutorialQuit {
+		return err
+	}
+
+	printf("\n7. Deleting a record. \"delete\" lists the records it would delete and\n" +
+		"asks first, unless --force is given.\n")
+	if err := tutorialStep("delete TXT " + name); err == errTutorialQuit {
+		return err
+	}
+	if api, err := getAPI(); err == nil {
+		params := cloudflare.ListDNSRecordsParams{Type: "TXT", Name: name}
+		if recs, err := listRecords(api, activeZoneIdentifier, params); err == nil && len(recs) == 0 {
+			*created = ""
+		}
+	}
+
+	printf("\nThat's all. \"help\" lists every command, and \"help <command>\" describes\n" +
+		"one. \"undo\" reverts the last change made by cf.\n")
+	return nil
+}
+
+// errSkipped is returned by a tutorial step the user skipped.
+var errSkipped = errors.New("step skipped")
+
+// tutorialStep shows a command line and runs it unless the user skips it or
+// quits the tutorial. It returns the error of the command.
+func tutorialStep(line string) error {
+	fmt.Fprintf(output, "    cf> %s\n", line)
+	for answered := false; !answered; {
+		answer, err := readString(tr("[Enter/s/q] "))
+		if err != nil {
+			return errTutorialQuit
+		}
+		switch strings.ToLower(strings.TrimSpace(answer)) {
+		case "":
+			answered = true
+		case "s":
+			return errSkipped
+		case "q":
+			return errTutorialQuit
+		}
+	}
+
+	err := processCmd(line)
+	if err == errInterrupted {
+		return errTutorialQuit
+	}
+	return err
+}
@@ -0,0 +1,341 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+)
+
+// version is the release version of cf. Release builds set it with
+// -ldflags "-X main.version=v1.2.3".
+var version = ""
+
+// releasesURL is queried for the most recent release of cf.
+const releasesURL = "https://api.github.com/repos/beevik/cf/releases/latest"
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	HTMLURL string         `json:"html_url"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+var updateClient = &http.Client{Timeout: 2 * time.Minute}
+
+// goosNames are the operating systems release asset names may mention.
+var goosNames = []string{
+	"aix", "android", "darwin", "dragonfly", "freebsd", "illumos", "ios",
+	"js", "linux", "netbsd", "openbsd", "plan9", "solaris", "wasip1", "windows",
+}
+
+// buildVersion returns the version of the running binary.
+func buildVersion() string {
+	if version != "" {
+		return version
+	}
+	if info, ok := debug.ReadBuildInfo(); ok && info.Main.Version != "" {
+		return info.Main.Version
+	}
+	return "(devel)"
+}
+
+func cmdVersion(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	current := buildVersion()
+	printf("cf %s (%s/%s)\n", current, runtime.GOOS, runtime.GOARCH)
+
+	latest, err := latestRelease()
+	if err != nil {
+		return fmt.Errorf("could not check for updates: %v", err)
+	}
+	if newerVersion(latest.TagName, current) {
+		printf("Version %s is available: %s\n", latest.TagName, latest.HTMLURL)
+		printf("Run \"cf self-update\" to install it.\n")
+	} else {
+		printf("cf is up to date.\n")
+	}
+	return nil
+}
+
+func cmdSelfUpdate(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	current := buildVersion()
+	latest, err := latestRelease()
+	if err != nil {
+		return err
+	}
+	if !newerVersion(latest.TagName, current) {
+		printf("cf is up to date.\n")
+		return nil
+	}
+
+	asset, ok := releaseAssetFor(latest, runtime.GOOS, runtime.GOARCH)
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", latest.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := checksumAsset(latest)
+	if !ok {
+		return fmt.Errorf("release %s publishes no SHA256 checksums to verify %s with", latest.TagName, asset.Name)
+	}
+
+	printf("Downloading %s...\n", asset.Name)
+	bin, err := downloadBinary(asset, sums)
+	if err != nil {
+		return err
+	}
+
+	if err := replaceExecutable(bin); err != nil {
+		return err
+	}
+	printf("Updated cf from %s to %s.\n", current, latest.TagName)
+	return nil
+}
+
+func latestRelease() (release, error) {
+	var r release
+	data, err := download(releasesURL)
+	if err != nil {
+		return r, err
+	}
+	err = json.Unmarshal(data, &r)
+	return r, err
+}
+
+// download returns the body of a GET request, which is canceled along
+// with the command.
+func download(url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(commandCtx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := updateClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", url, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// releaseAssetFor returns the release asset built for the requested
+// operating system and architecture. The words of the asset's name,
+// separated by "_", "-" or ".", must include both exactly and name no
+// other operating system, so that arm does not match arm64 and linux does
+// not match android.
+func releaseAssetFor(r release, goos, goarch string) (releaseAsset, bool) {
+	for _, a := range r.Assets {
+		name := strings.ToLower(a.Name)
+		if strings.HasSuffix(name, ".sha256") || strings.HasSuffix(name, ".sig") || strings.HasSuffix(name, ".asc") {
+			continue
+		}
+		words := strings.FieldsFunc(name, func(c rune) bool {
+			return c == '_' || c == '-' || c == '.'
+		})
+		if !slices.Contains(words, goos) || !slices.Contains(words, goarch) {
+			continue
+		}
+		if slices.ContainsFunc(words, func(w string) bool {
+			return w != goos && slices.Contains(goosNames, w)
+		}) {
+			continue
+		}
+		return a, true
+	}
+	return releaseAsset{}, false
+}
+
+// checksumAsset returns the release asset listing the SHA256 checksums of
+// the others, such as checksums.txt or SHA256SUMS.
+func checksumAsset(r release) (releaseAsset, bool) {
+	for _, a := range r.Assets {
+		name := strings.ToLower(a.Name)
+		if strings.Contains(name, "checksums") || strings.HasPrefix(name, "sha256sums") {
+			return a, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// verifyChecksum checks the SHA256 checksum of a downloaded asset against
+// the list of checksums published with the release, whose lines have the
+// form "<checksum>  <name>" of sha256sum.
+func verifyChecksum(name string, data, sums []byte) error {
+	sum := sha256.Sum256(data)
+	for _, line := range strings.Split(string(sums), "\n") {
+		f := strings.Fields(line)
+		if len(f) != 2 || strings.TrimPrefix(f[1], "*") != name {
+			continue
+		}
+		if !strings.EqualFold(f[0], hex.EncodeToString(sum[:])) {
+			return fmt.Errorf("the SHA256 checksum of %s does not match the release's", name)
+		}
+		return nil
+	}
+	return fmt.Errorf("the release lists no SHA256 checksum for %s", name)
+}
+
+// downloadBinary downloads a release asset, verifies it against the
+// release's checksums and returns the cf executable it contains. Assets
+// may be bare executables, tar.gz archives or zip archives.
+func downloadBinary(a, sums releaseAsset) ([]byte, error) {
+	data, err := download(a.URL)
+	if err != nil {
+		return nil, err
+	}
+	list, err := download(sums.URL)
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(a.Name, data, list); err != nil {
+		return nil, err
+	}
+
+	switch {
+	case strings.HasSuffix(a.Name, ".tar.gz") || strings.HasSuffix(a.Name, ".tgz"):
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return nil, err
+		}
+		tarReader := tar.NewReader(gz)
+		for {
+			h, err := tarReader.Next()
+			if err != nil {
+				return nil, fmt.Errorf("%s does not contain a cf executable", a.Name)
+			}
+			if isExecutableName(h.Name) {
+				return io.ReadAll(tarReader)
+			}
+		}
+
+	case strings.HasSuffix(a.Name, ".zip"):
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range zr.File {
+			if isExecutableName(f.Name) {
+				rc, err := f.Open()
+				if err != nil {
+					return nil, err
+				}
+				defer rc.Close()
+				return io.ReadAll(rc)
+			}
+		}
+		return nil, fmt.Errorf("%s does not contain a cf executable", a.Name)
+
+	default:
+		return data, nil
+	}
+}
+
+func isExecutableName(name string) bool {
+	base := filepath.Base(name)
+	return base == "cf" || base == "cf.exe"
+}
+
+// replaceExecutable replaces the running executable with a new binary. The
+// new binary is written alongside the old one and renamed over it, so the
+// executable is never left partially written.
+func replaceExecutable(bin []byte) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if exe, err = filepath.EvalSymlinks(exe); err != nil {
+		return err
+	}
+
+	tmp := exe + ".new"
+	if err := os.WriteFile(tmp, bin, 0755); err != nil {
+		return err
+	}
+
+	// Windows does not allow a running executable to be replaced, but it
+	// may be renamed out of the way.
+	old := exe + ".old"
+	os.Remove(old)
+	if err := os.Rename(exe, old); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, exe); err != nil {
+		os.Rename(old, exe)
+		return err
+	}
+	if err := os.Remove(old); err != nil && runtime.GOOS != "windows" {
+		return err
+	}
+	return nil
+}
+
+// newerVersion reports whether version a is newer than version b. Versions
+// have the form v1.2.3. Versions that cannot be parsed, such as that of a
+// local development build, are never considered newer or older.
+func newerVersion(a, b string) bool {
+	va, okA := parseVersion(a)
+	vb, okB := parseVersion(b)
+	if !okA || !okB {
+		return false
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] > vb[i]
+		}
+	}
+	return false
+}
+
+func parseVersion(s string) ([3]int, bool) {
+	var v [3]int
+	s = strings.TrimPrefix(s, "v")
+	if i := strings.IndexAny(s, "-+"); i >= 0 {
+		s = s[:i]
+	}
+	parts := strings.Split(s, ".")
+	if len(parts) != 3 {
+		return v, false
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil {
+			return v, false
+		}
+		v[i] = n
+	}
+	return v, true
+}
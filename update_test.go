@@ -0,0 +1,80 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestReleaseAssetFor(t *testing.T) {
+	r := release{}
+	for _, name := range []string{
+		"checksums.txt",
+		"cf_1.2.0_linux-android_arm64.tar.gz",
+		"cf_1.2.0_linux_arm64.tar.gz",
+		"cf_1.2.0_linux_arm64.tar.gz.sha256",
+		"cf_1.2.0_linux_arm.tar.gz",
+		"cf_1.2.0_windows_amd64.zip",
+	} {
+		r.Assets = append(r.Assets, releaseAsset{Name: name})
+	}
+
+	tests := []struct {
+		goos, goarch, want string
+	}{
+		{"linux", "arm", "cf_1.2.0_linux_arm.tar.gz"},
+		{"linux", "arm64", "cf_1.2.0_linux_arm64.tar.gz"},
+		{"windows", "amd64", "cf_1.2.0_windows_amd64.zip"},
+		{"darwin", "arm64", ""},
+	}
+	for _, tt := range tests {
+		a, _ := releaseAssetFor(r, tt.goos, tt.goarch)
+		if a.Name != tt.want {
+			t.Errorf("releaseAssetFor(%s, %s) = %q, want %q", tt.goos, tt.goarch, a.Name, tt.want)
+		}
+	}
+	if a, ok := checksumAsset(r); !ok || a.Name != "checksums.txt" {
+		t.Errorf("checksumAsset = %q, %v", a.Name, ok)
+	}
+}
+
+func TestDownloadBinaryChecksum(t *testing.T) {
+	bin := []byte("new cf executable")
+	sum := sha256.Sum256(bin)
+	sums := map[string]string{
+		"good": hex.EncodeToString(sum[:]) + "  cf_linux_amd64\n",
+		"bad":  strings.Repeat("0", 64) + "  cf_linux_amd64\n",
+		"none": hex.EncodeToString(sum[:]) + "  cf_darwin_amd64\n",
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/cf_linux_amd64" {
+			w.Write(bin)
+			return
+		}
+		fmt.Fprint(w, sums[strings.TrimPrefix(r.URL.Path, "/")])
+	}))
+	defer srv.Close()
+
+	asset := releaseAsset{Name: "cf_linux_amd64", URL: srv.URL + "/cf_linux_amd64"}
+	for name, wantErr := range map[string]string{
+		"good": "",
+		"bad":  "does not match",
+		"none": "no SHA256 checksum",
+	} {
+		got, err := downloadBinary(asset, releaseAsset{Name: "checksums.txt", URL: srv.URL + "/" + name})
+		switch {
+		case wantErr == "" && (err != nil || string(got) != string(bin)):
+			t.Errorf("%s checksums: %q, %v", name, got, err)
+		case wantErr != "" && (err == nil || !strings.Contains(err.Error(), wantErr)):
+			t.Errorf("%s checksums: error %v, want %q", name, err, wantErr)
+		}
+	}
+}
@@ -0,0 +1,181 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// An upsertLine is a record read by "upsert -", either from a line of the
+// form TYPE NAME CONTENT [TTL] [proxied|unproxied] or from a line of JSON.
+type upsertLine struct {
+	Type    string `json:"type"`
+	Name    string `json:"name"`
+	Content string `json:"content"`
+	TTL     int    `json:"ttl,omitempty"`
+	Proxied *bool  `json:"proxied,omitempty"`
+}
+
+func cmdUpsert(c *cmd.Command, args []string) error {
+	var lines []upsertLine
+	switch {
+	case len(args) == 1 && args[0] == "-":
+		var err error
+		if lines, err = readUpsertLines(stdin); err != nil {
+			return err
+		}
+	case len(args) >= 3 && len(args) <= 5:
+		l, err := parseUpsertFields(args)
+		if err != nil {
+			return argError(err)
+		}
+		lines = append(lines, l)
+	default:
+		return usageError(c)
+	}
+
+	for _, l := range lines {
+		if err := validateRecord(l.Type, l.Name, l.Content); err != nil {
+			return argError(fmt.Errorf("%s record %s: %v", l.Type, l.Name, err))
+		}
+		if err := validateApex(l.Type, l.Name, activeZoneName); err != nil {
+			return argError(err)
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	return upsertLines(api, zoneID, lines)
+}
+
+// readUpsertLines reads the records to upsert, one per line. Blank lines
+// and lines starting with # are ignored. All lines are read before any
+// change is made, so that a mistake anywhere leaves the zone unchanged.
+func readUpsertLines(r io.Reader) ([]upsertLine, error) {
+	var lines []upsertLine
+	scanner := bufio.NewScanner(r)
+	for n := 1; scanner.Scan(); n++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		var l upsertLine
+		var err error
+		if strings.HasPrefix(text, "{") {
+			if _, err = decodeJSON(scanner.Bytes(), &l); err == nil && (l.Type == "" || l.Name == "" || l.Content == "") {
+				err = errors.New("type, name and content are required")
+			}
+			if err == nil && l.TTL != 0 {
+				_, err = parseTTL(strconv.Itoa(l.TTL))
+			}
+		} else {
+			l, err = parseUpsertFields(zoneFields(text))
+		}
+		var located *schemaError
+		if errors.As(err, &located) {
+			located.line = n // a line holds one record
+			return nil, argError(located)
+		}
+		if err != nil {
+			return nil, argError(fmt.Errorf("line %d: %v", n, err))
+		}
+		l.Type = strings.ToUpper(l.Type)
+		lines = append(lines, l)
+	}
+	return lines, scanner.Err()
+}
+
+// parseUpsertFields parses the fields TYPE NAME CONTENT [TTL]
+// [proxied|unproxied]. Content containing spaces is quoted.
+func parseUpsertFields(fields []string) (upsertLine, error) {
+	var l upsertLine
+	if len(fields) < 3 || len(fields) > 5 {
+		return l, errors.New("expected TYPE NAME CONTENT [TTL] [proxied]")
+	}
+	l.Type, l.Name, l.Content = strings.ToUpper(fields[0]), fields[1], fields[2]
+	if s, err := strconv.Unquote(l.Content); err == nil && strings.HasPrefix(l.Content, `"`) {
+		l.Content = s
+	}
+
+	for _, f := range fields[3:] {
+		switch {
+		case strings.EqualFold(f, "proxied") && l.Proxied == nil:
+			on := true
+			l.Proxied = &on
+		case strings.EqualFold(f, "unproxied") && l.Proxied == nil:
+			off := false
+			l.Proxied = &off
+		case l.TTL == 0 && l.Proxied == nil:
+			ttl, err := parseTTL(f)
+			if err != nil {
+				return l, err
+			}
+			l.TTL = ttl
+		default:
+			return l, fmt.Errorf("unexpected field %q", f)
+		}
+	}
+	return l, nil
+}
+
+// upsertLines converges the records of the active zone with the lines,
+// updating the first record with each type and name, or creating one.
+func upsertLines(api *cloudflare.API, zoneID *cloudflare.ResourceContainer, lines []upsertLine) error {
+	var ops []operation
+	changed := make([]bool, len(lines))
+	for i, l := range lines {
+		change := upsertChange(zoneID.Identifier, activeZoneName, l.Type, l.Name, l.Content)
+		change.Record.TTL, change.Record.Proxied = l.TTL, l.Proxied
+		ops = append(ops, operation{
+			key: zoneID.Identifier + "/" + l.Type + " " + cflib.NormalizeName(l.Name),
+			fn: func() error {
+				var err error
+				changed[i], err = upsertRecord(api, zoneID, l.Type, l.Name, l.Content, l.TTL,
+					recordMeta{proxied: l.Proxied})
+				return err
+			},
+			change: change,
+		})
+	}
+
+	failed := 0
+	errs := sched.run(ops)
+	for i, err := range errs {
+		l := lines[i]
+		switch {
+		case err != nil:
+			printf("Error updating %s record %s: %v\n", l.Type, l.Name, err)
+			failed++
+		case changed[i]:
+			printf("Set %s record %s to %s.\n", l.Type, l.Name, l.Content)
+		default:
+			printf("%s record %s is already %s.\n", l.Type, l.Name, l.Content)
+		}
+	}
+	queueRetries(ops, errs)
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %d record(s) could not be updated", failed, len(lines))
+	}
+	return nil
+}
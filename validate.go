@@ -0,0 +1,139 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+)
+
+// DNS limits on names and TXT content. Cloudflare splits unquoted TXT
+// content into character-strings itself, but limits its total length.
+const (
+	maxNameLength  = 253
+	maxLabelLength = 63
+	maxTXTString   = 255
+	maxTXTLength   = 2048
+)
+
+// validateRecord checks the name and content of a record before it is sent
+// to the API, so that mistakes are reported clearly instead of as API
+// errors. Types cf knows nothing about are passed through unchecked.
+func validateRecord(recType, name, content string) error {
+	if name != "@" {
+		if err := validateName(name, true); err != nil {
+			return err
+		}
+	}
+
+	switch strings.ToUpper(recType) {
+	case "A":
+		addr, err := netip.ParseAddr(content)
+		if err != nil || !addr.Is4() {
+			return fmt.Errorf("%q is not a valid IPv4 address", content)
+		}
+	case "AAAA":
+		addr, err := netip.ParseAddr(content)
+		if err != nil || !addr.Is6() || addr.Zone() != "" {
+			return fmt.Errorf("%q is not a valid IPv6 address", content)
+		}
+	case "CNAME", "NS", "PTR":
+		if err := validateName(content, false); err != nil {
+			return err
+		}
+	case "TXT":
+		return validateTXT(content)
+	}
+	return nil
+}
+
+// validateApex rejects records that may not exist at the zone apex.
+func validateApex(recType, name, zone string) error {
+	apex := name == "@" || cflib.NormalizeName(name) == cflib.NormalizeName(zone)
+	if apex && strings.EqualFold(recType, "CNAME") {
+		return fmt.Errorf("a CNAME record cannot be created at the zone apex %s", zone)
+	}
+	return nil
+}
+
+// validateName checks that a name is a valid DNS host name. Labels may
+// contain underscores, as in service names like _dmarc, and, if wildcard is
+// true, the first label may be *. Non-ASCII labels are accepted, since the
+// API converts internationalized names itself.
+func validateName(name string, wildcard bool) error {
+	n := strings.TrimSuffix(name, ".")
+	if n == "" {
+		return errors.New("empty name")
+	}
+	if len(n) > maxNameLength {
+		return fmt.Errorf("name %s is longer than %d characters", name, maxNameLength)
+	}
+
+	for i, label := range strings.Split(n, ".") {
+		switch {
+		case label == "":
+			return fmt.Errorf("name %s has an empty label", name)
+		case len(label) > maxLabelLength:
+			return fmt.Errorf("label %s of name %s is longer than %d characters", label, name, maxLabelLength)
+		case label == "*" && wildcard && i == 0:
+			continue
+		case label[0] == '-' || label[len(label)-1] == '-':
+			return fmt.Errorf("label %s of name %s begins or ends with a hyphen", label, name)
+		}
+		for _, r := range label {
+			if r < 0x80 && !isLabelChar(byte(r)) {
+				return fmt.Errorf("name %s contains the invalid character %q", name, r)
+			}
+		}
+	}
+	return nil
+}
+
+func isLabelChar(c byte) bool {
+	return 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c == '-' || c == '_'
+}
+
+// validateTXT checks the quoting and length of TXT content. Content that
+// begins with a quote must consist entirely of quoted character-strings of
+// at most 255 bytes each.
+func validateTXT(content string) error {
+	if len(content) > maxTXTLength {
+		return fmt.Errorf("TXT content is longer than %d characters", maxTXTLength)
+	}
+	if !strings.HasPrefix(content, `"`) {
+		return nil
+	}
+
+	s := content
+	for {
+		s = strings.TrimLeft(s, " \t")
+		if s == "" {
+			return nil
+		}
+		if s[0] != '"' {
+			return errors.New("TXT content mixes quoted and unquoted text")
+		}
+
+		n, i := 0, 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+			}
+			n++
+		}
+		if i >= len(s) {
+			return errors.New("TXT content has an unterminated quoted string")
+		}
+		if n > maxTXTString {
+			return fmt.Errorf("quoted TXT string is longer than %d characters", maxTXTString)
+		}
+		s = s[i+1:]
+	}
+}
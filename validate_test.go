@@ -0,0 +1,55 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestValidateRecord(t *testing.T) {
+	long := strings.Repeat("a", 256)
+	tests := []struct {
+		recType, name, content string
+		ok                     bool
+	}{
+		{"A", "www", "192.0.2.1", true},
+		{"A", "www", "999.1.2.3", false},
+		{"A", "www", "2001:db8::1", false},
+		{"AAAA", "www", "2001:db8::1", true},
+		{"AAAA", "www", "192.0.2.1", false},
+		{"AAAA", "www", "fe80::1%eth0", false},
+		{"A", "*.example.com", "192.0.2.1", true},
+		{"A", "www.*.example.com", "192.0.2.1", false},
+		{"A", "-www.example.com", "192.0.2.1", false},
+		{"A", "www..example.com", "192.0.2.1", false},
+		{"A", "w w w", "192.0.2.1", false},
+		{"A", strings.Repeat("a", 64) + ".example.com", "192.0.2.1", false},
+		{"A", "@", "192.0.2.1", true},
+		{"TXT", "_dmarc.example.com", "v=DMARC1; p=none", true},
+		{"TXT", "example.com", `"v=spf1 " "-all"`, true},
+		{"TXT", "example.com", `"v=spf1 -all`, false},
+		{"TXT", "example.com", `"v=spf1" -all`, false},
+		{"TXT", "example.com", `"` + long + `"`, false},
+		{"TXT", "example.com", long, true},
+		{"TXT", "example.com", strings.Repeat("a", 2049), false},
+		{"CNAME", "www", "target.example.net.", true},
+		{"CNAME", "www", "target example", false},
+		{"SRV", "_sip._tcp", "anything", true},
+	}
+	for _, test := range tests {
+		err := validateRecord(test.recType, test.name, test.content)
+		if (err == nil) != test.ok {
+			t.Errorf("validateRecord(%s, %s, %q) = %v, want ok %v", test.recType, test.name, test.content, err, test.ok)
+		}
+	}
+
+	if err := validateApex("CNAME", "Example.com.", "example.com"); err == nil {
+		t.Error("CNAME at the zone apex accepted")
+	}
+	if err := validateApex("CNAME", "www.example.com", "example.com"); err != nil {
+		t.Errorf("CNAME below the zone apex rejected: %v", err)
+	}
+}
@@ -0,0 +1,165 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Levels of API request logging, selected by --verbose (or -v) and --debug.
+const (
+	logOff = iota
+	logRequests
+	logBodies
+)
+
+// logLevel is the level of API request logging.
+var logLevel = logOff
+
+// maxLoggedBody is the number of bytes of a request or response body logged
+// at the debug level.
+const maxLoggedBody = 4096
+
+// redacted replaces secrets in logged headers and bodies.
+const redacted = "[redacted]"
+
+// A logTransport logs each API request sent through the underlying
+// transport to standard error: its method, path, status and latency, and at
+// the debug level its headers and bodies, with credentials and other
+// secrets redacted. Every attempt of a retried request is logged, and
+// counted for the run summary.
+type logTransport struct {
+	base http.RoundTripper
+}
+
+func (t *logTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	noteAPICall()
+	if logLevel == logOff {
+		return t.base.RoundTrip(req)
+	}
+
+	path := strings.TrimPrefix(req.URL.Path, "/client/v4")
+	if req.URL.RawQuery != "" {
+		path += "?" + req.URL.RawQuery
+	}
+
+	if logLevel >= logBodies {
+		logHeaders(">", req.Header)
+		if req.Body != nil {
+			body, err := io.ReadAll(req.Body)
+			req.Body.Close()
+			if err != nil {
+				return nil, err
+			}
+			req.Body = io.NopCloser(bytes.NewReader(body))
+			logBody(">", body)
+		}
+	}
+
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	elapsed := time.Since(start).Round(time.Millisecond)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "[api] %s %s failed after %s: %v\n", req.Method, path, elapsed, err)
+		return nil, err
+	}
+	fmt.Fprintf(os.Stderr, "[api] %s %s %d %s\n", req.Method, path, resp.StatusCode, elapsed)
+
+	if logLevel >= logBodies {
+		logHeaders("<", resp.Header)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		logBody("<", body)
+	}
+	return resp, nil
+}
+
+func logHeaders(dir string, h http.Header) {
+	names := make([]string, 0, len(h))
+	for name := range h {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		for _, v := range h[name] {
+			if isSecretHeader(name) {
+				v = redacted
+			}
+			fmt.Fprintf(os.Stderr, "[api] %s %s: %s\n", dir, name, v)
+		}
+	}
+}
+
+func logBody(dir string, body []byte) {
+	if len(body) == 0 {
+		return
+	}
+	body = redactBody(body)
+	if len(body) > maxLoggedBody {
+		body = append(body[:maxLoggedBody:maxLoggedBody], "..."...)
+	}
+	fmt.Fprintf(os.Stderr, "[api] %s %s\n", dir, body)
+}
+
+func isSecretHeader(name string) bool {
+	switch http.CanonicalHeaderKey(name) {
+	case "Authorization", "X-Auth-Key", "X-Auth-Email", "X-Auth-User-Service-Key", "Cookie", "Set-Cookie":
+		return true
+	}
+	return false
+}
+
+// isSecretField reports whether a JSON field holds a secret, such as a
+// tunnel token, a webhook secret or the private key of an uploaded
+// certificate.
+func isSecretField(name string) bool {
+	name = strings.ToLower(name)
+	return strings.HasSuffix(name, "key") || strings.Contains(name, "token") ||
+		strings.Contains(name, "secret") || strings.Contains(name, "password")
+}
+
+// redactBody returns a JSON body with the values of its secret fields
+// redacted. Other bodies are returned unchanged.
+func redactBody(body []byte) []byte {
+	var v any
+	if err := json.Unmarshal(body, &v); err != nil {
+		return body
+	}
+	out, err := json.Marshal(redactValue(v))
+	if err != nil {
+		return body
+	}
+	return out
+}
+
+func redactValue(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if isSecretField(k) {
+				v[k] = redacted
+			} else {
+				v[k] = redactValue(e)
+			}
+		}
+	case []any:
+		for i, e := range v {
+			v[i] = redactValue(e)
+		}
+	}
+	return v
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/beevik/cmd"
+)
+
+var verifyFlags = registerFlags("verify", mergeFlags(waitFlags, flagSpec{"global": false}))
+
+// cmdVerify queries the public resolvers for the records of a type and
+// name. Without expected content, it displays the values each resolver
+// returns. With expected content, it checks that every resolver serves it,
+// waiting for the change to propagate if --wait is given. With --global,
+// resolvers around the world are checked for several names at once.
+func cmdVerify(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, verifyFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) < 2 || (len(args) > 3 && !flags.has("global")) {
+		return usageError(c)
+	}
+
+	recType, name := strings.ToUpper(args[0]), args[1]
+	switch recType {
+	case "A", "AAAA", "CNAME", "TXT", "NS":
+	default:
+		return argError(fmt.Errorf("verifying %s records is not supported", recType))
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+	if flags.has("global") {
+		return verifyGlobal(recType, args[1:], wait)
+	}
+
+	if len(args) == 2 {
+		if wait > 0 {
+			return usageError(c)
+		}
+		for _, ns := range resolvers {
+			values, err := lookupRecord(ns, recType, name)
+			if err != nil {
+				printf("%s: no answer (%v)\n", ns, err)
+				continue
+			}
+			fmt.Fprintf(output, "%s: %s\n", ns, strings.Join(values, ", "))
+		}
+		return nil
+	}
+
+	content := args[2]
+	if wait > 0 {
+		printf("Waiting for %s to serve the new record...\n", strings.Join(resolvers, ", "))
+		if err := waitForServers(resolvers, recType, name, content, wait); err != nil {
+			return err
+		}
+	}
+
+	pending := 0
+	for _, ns := range resolvers {
+		if nameserverServes(ns, recType, name, content) {
+			printf("%s: visible\n", ns)
+		} else {
+			printf("%s: not visible\n", ns)
+			pending++
+		}
+	}
+	if pending > 0 {
+		return fmt.Errorf("%d of %d resolver(s) do not serve the record", pending, len(resolvers))
+	}
+	return nil
+}
@@ -0,0 +1,201 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A tokenProvider describes the record a service asks domain owners to
+// create to prove their ownership of a domain. Either the record's content
+// or its name is derived from the token the service hands out.
+type tokenProvider struct {
+	desc    string
+	recType string
+	prefix  string // TXT content preceding the token
+	target  string // CNAME target, for records named after the token
+}
+
+var tokenProviders = map[string]tokenProvider{
+	"google":    {desc: "Google site verification", recType: "TXT", prefix: "google-site-verification="},
+	"microsoft": {desc: "Microsoft 365 and Azure", recType: "TXT", prefix: "MS="},
+	"apple":     {desc: "Apple Business and iCloud Mail", recType: "TXT", prefix: "apple-domain-verification="},
+	"facebook":  {desc: "Meta business domain verification", recType: "TXT", prefix: "facebook-domain-verification="},
+	"stripe":    {desc: "Stripe", recType: "TXT", prefix: "stripe-verification="},
+	"atlassian": {desc: "Atlassian", recType: "TXT", prefix: "atlassian-domain-verification="},
+	"adobe":     {desc: "Adobe identity", recType: "TXT", prefix: "adobe-idp-site-verification="},
+	"docusign":  {desc: "DocuSign", recType: "TXT", prefix: "docusign="},
+	"keybase":   {desc: "Keybase", recType: "TXT", prefix: "keybase-site-verification="},
+	"openai":    {desc: "OpenAI", recType: "TXT", prefix: "openai-domain-verification="},
+	"zoom":      {desc: "Zoom", recType: "TXT", prefix: "ZOOM_verify_"},
+	"bing":      {desc: "Bing Webmaster Tools", recType: "CNAME", target: "verify.bing.com"},
+}
+
+// record returns the type, name and content of the record proving the
+// ownership of a zone with a token. A token given with the provider's
+// prefix, as copied from its instructions, is accepted too.
+func (p tokenProvider) record(zone, token string) (recType, name, content string, err error) {
+	if len(p.prefix) > 0 && len(token) > len(p.prefix) && strings.EqualFold(token[:len(p.prefix)], p.prefix) {
+		token = token[len(p.prefix):]
+	}
+	if token == "" || strings.ContainsAny(token, " \t\"") {
+		return "", "", "", fmt.Errorf("invalid verification token %q", token)
+	}
+
+	switch p.recType {
+	case "CNAME":
+		return p.recType, token + "." + cflib.NormalizeName(zone), p.target, nil
+	default:
+		return p.recType, cflib.NormalizeName(zone), p.prefix + token, nil
+	}
+}
+
+var verifyTokenFlags = registerFlags("verify-token", waitFlags)
+
+func cmdVerifyToken(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, verifyTokenFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) == 1 && args[0] == "list" {
+		listTokenProviders()
+		return nil
+	}
+	if len(args) != 2 {
+		return usageError(c)
+	}
+
+	p, ok := tokenProviders[strings.ToLower(args[0])]
+	if !ok {
+		return argError(fmt.Errorf("unknown verification provider %q (see \"verify-token list\")", args[0]))
+	}
+
+	wait, err := parseWait(flags)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recType, name, content, err := p.record(activeZoneName, args[1])
+	if err != nil {
+		return argError(err)
+	}
+	if err := validateRecord(recType, name, content); err != nil {
+		return argError(err)
+	}
+
+	if err := addVerificationRecord(api, zoneID, p, recType, name, content); err != nil {
+		return err
+	}
+	if wait > 0 {
+		return waitForRecord(api, zoneID, recType, name, content, wait)
+	}
+	return nil
+}
+
+func listTokenProviders() {
+	var names []string
+	for name := range tokenProviders {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		p := tokenProviders[name]
+		shape := fmt.Sprintf("TXT @ %s<token>", p.prefix)
+		if p.recType == "CNAME" {
+			shape = fmt.Sprintf("CNAME <token> %s", p.target)
+		}
+		fmt.Fprintf(output, "%-10s %-34s %s\n", name, p.desc, shape)
+	}
+}
+
+// addVerificationRecord creates a verification record. Since a zone's apex
+// usually holds other TXT records, such as its SPF policy, a TXT record
+// replaces only an earlier token of the same provider.
+func addVerificationRecord(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	p tokenProvider, recType, name, content string) error {
+
+	if recType != "TXT" {
+		changed, err := upsertRecord(api, zoneID, recType, name, content, 0, recordMeta{})
+		if err != nil {
+			return err
+		}
+		if changed {
+			printf("Set %s record %s to %s.\n", recType, name, content)
+		} else {
+			printf("%s record %s is already %s.\n", recType, name, content)
+		}
+		return nil
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{Type: recType, Name: name})
+	if err != nil {
+		return err
+	}
+	var earlier *cloudflare.DNSRecord
+	for i, r := range recs {
+		switch {
+		case cflib.ContentEqual(recType, r.Content, content):
+			printf("%s record %s is already %s.\n", recType, name, content)
+			return nil
+		case strings.HasPrefix(strings.ToLower(strings.Trim(r.Content, `"`)), strings.ToLower(p.prefix)):
+			earlier = &recs[i]
+		}
+	}
+
+	if err := checkOwner(api, zoneID, recType, name); err != nil {
+		return err
+	}
+
+	b := recordBackend(api)
+	if earlier != nil {
+		_, err := b.UpdateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.UpdateDNSRecordParams{
+			ID:      earlier.ID,
+			Type:    earlier.Type,
+			Name:    earlier.Name,
+			Content: content,
+			TTL:     earlier.TTL,
+			Comment: &earlier.Comment,
+			Tags:    earlier.Tags,
+		})
+		if err != nil {
+			return err
+		}
+		printf("Set %s record %s to %s.\n", recType, name, content)
+		return nil
+	}
+
+	_, err = b.CreateDNSRecord(commandCtx, zoneID.Identifier, cloudflare.CreateDNSRecordParams{
+		Type:    recType,
+		Name:    name,
+		Content: content,
+		TTL:     defaultTTL,
+		Comment: p.desc + " verification",
+	})
+	if err != nil {
+		return err
+	}
+	if err := markOwned(api, zoneID, recType, name); err != nil {
+		return err
+	}
+	printf("Created %s record %s.\n", recType, name)
+	return nil
+}
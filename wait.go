@@ -0,0 +1,209 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// defaultWaitTimeout is how long --wait polls the zone's nameservers and
+// the public resolvers before giving up.
+const defaultWaitTimeout = 2 * time.Minute
+
+// waitInterval is the delay between nameserver polls.
+const waitInterval = 2 * time.Second
+
+// waitFlags are the flags accepted by commands that can wait for a change
+// to become visible on the zone's nameservers.
+var waitFlags = flagSpec{
+	"wait":         false,
+	"wait-timeout": true,
+}
+
+// parseWait returns how long a command should wait for its change to be
+// served, or zero if it should not wait.
+func parseWait(flags flagValues) (time.Duration, error) {
+	if !flags.has("wait") {
+		return 0, nil
+	}
+	timeout, err := time.ParseDuration(flags.get("wait-timeout", defaultWaitTimeout.String()))
+	if err != nil || timeout <= 0 {
+		return 0, argError(fmt.Errorf("invalid wait timeout %q", flags.get("wait-timeout", "")))
+	}
+	return timeout, nil
+}
+
+// defaultResolvers are the public resolvers queried by verify and --wait.
+var defaultResolvers = []string{"1.1.1.1", "8.8.8.8"}
+
+// resolvers are the public resolvers queried by verify and --wait, which
+// check that a change is visible to clients and not only on the zone's own
+// nameservers.
+var resolvers = defaultResolvers
+
+// parseResolvers validates a comma-separated list of resolver addresses or
+// host names.
+func parseResolvers(s string) ([]string, error) {
+	var list []string
+	for _, r := range strings.Split(s, ",") {
+		r = strings.TrimSpace(r)
+		if r == "" || strings.ContainsAny(r, " /") {
+			return nil, argError(fmt.Errorf("invalid resolver list %q", s))
+		}
+		list = append(list, r)
+	}
+	return list, nil
+}
+
+// waitForRecord polls each of the zone's authoritative nameservers and each
+// public resolver until they all serve a record with the requested content,
+// or until the timeout expires.
+func waitForRecord(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	recType, name, content string, timeout time.Duration) error {
+
+	if dryRun {
+		return nil
+	}
+
+	recType = strings.ToUpper(recType)
+	switch recType {
+	case "A", "AAAA", "CNAME", "TXT":
+	default:
+		printf("Waiting is not supported for %s records.\n", recType)
+		return nil
+	}
+
+	// Proxied records are answered with Cloudflare's own addresses, so
+	// their content never appears in DNS responses.
+	params := cloudflare.ListDNSRecordsParams{Type: recType, Name: name}
+	recs, err := listRecords(api, zoneID, params)
+	if err != nil {
+		return err
+	}
+	for _, r := range recs {
+		if r.Proxied != nil && *r.Proxied {
+			printf("Not waiting for a proxied record.\n")
+			return nil
+		}
+	}
+
+	zone, err := api.ZoneDetails(commandCtx, zoneID.Identifier)
+	if err != nil {
+		return err
+	}
+	if len(zone.NameServers) == 0 {
+		return fmt.Errorf("zone %s has no assigned nameservers", zone.Name)
+	}
+
+	servers := append(slices.Clone(zone.NameServers), resolvers...)
+	printf("Waiting for %s to serve the new record...\n", strings.Join(servers, ", "))
+	if err := waitForServers(servers, recType, name, content, timeout); err != nil {
+		return err
+	}
+	printf("DNS record is being served.\n")
+	return nil
+}
+
+// waitForServers polls DNS servers until they all serve a record with the
+// requested content, or until the timeout expires.
+func waitForServers(servers []string, recType, name, content string, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		pending := 0
+		for _, ns := range servers {
+			if !nameserverServes(ns, recType, name, content) {
+				pending++
+			}
+		}
+		if pending == 0 {
+			return nil
+		}
+
+		if time.Now().Add(waitInterval).After(deadline) {
+			return fmt.Errorf("timed out after %v waiting for %d of %d nameserver(s)",
+				timeout, pending, len(servers))
+		}
+		if err := sleep(waitInterval); err != nil {
+			return err
+		}
+	}
+}
+
+// nameserverServes queries a nameserver directly and reports whether it
+// serves a record of the requested type, name and content.
+func nameserverServes(ns, recType, name, content string) bool {
+	values, err := lookupRecord(ns, recType, name)
+	if err != nil {
+		return false
+	}
+	for _, v := range values {
+		if cflib.ContentEqual(recType, v, content) {
+			return true
+		}
+	}
+	return false
+}
+
+// lookupRecord queries a DNS server directly for the values of the records
+// of a type and name.
+func lookupRecord(ns, recType, name string) ([]string, error) {
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, network, net.JoinHostPort(ns, "53"))
+		},
+	}
+
+	ctx, cancel := context.WithTimeout(commandCtx, 5*time.Second)
+	defer cancel()
+
+	switch recType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recType == "AAAA" {
+			network = "ip6"
+		}
+		addrs, err := resolver.LookupNetIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		var values []string
+		for _, a := range addrs {
+			values = append(values, a.String())
+		}
+		return values, nil
+
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		return []string{cname}, nil
+
+	case "TXT":
+		return resolver.LookupTXT(ctx, name)
+
+	case "NS":
+		servers, err := resolver.LookupNS(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		var values []string
+		for _, ns := range servers {
+			values = append(values, cflib.NormalizeName(ns.Host))
+		}
+		return values, nil
+	}
+	return nil, fmt.Errorf("unsupported record type %s", recType)
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var watchFlags = registerFlags("watch", flagSpec{"interval": true})
+
+func cmdWatch(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, watchFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	interval, err := time.ParseDuration(flags.get("interval", "30s"))
+	if err != nil || interval <= 0 {
+		return argError(fmt.Errorf("invalid interval %q", flags.get("interval", "")))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return err
+	}
+	printf("Watching %d record(s) in zone %s every %s.\n", len(recs), activeZoneName, interval)
+
+	for {
+		if err := sleep(interval); err != nil {
+			return err
+		}
+
+		// A failed poll is reported and retried at the next interval, and
+		// the changes are then reported against the last records seen.
+		var diffs []recordDiff
+		recs, diffs, err = pollRecords(api, zoneID, recs)
+		now := time.Now().Format("2006-01-02 15:04:05")
+		switch {
+		case commandCtx.Err() != nil:
+			return errInterrupted
+		case err != nil:
+			printf("%s: error listing records: %v\n", now, err)
+		case len(diffs) > 0:
+			printf("%s: %d change(s) in zone %s\n", now, len(diffs), activeZoneName)
+			printDiffs(diffs)
+			recordObservedChanges(zoneID.Identifier, diffs)
+		}
+	}
+}
+
+// pollRecords lists the zone's records and returns them along with their
+// differences from the records last seen. If the records cannot be listed,
+// the records last seen are returned.
+func pollRecords(api *cloudflare.API, zoneID *cloudflare.ResourceContainer,
+	last []cloudflare.DNSRecord) ([]cloudflare.DNSRecord, []recordDiff, error) {
+
+	recs, err := listRecords(api, zoneID, cloudflare.ListDNSRecordsParams{})
+	if err != nil {
+		return last, nil, err
+	}
+	return recs, diffRecords(last, recs), nil
+}
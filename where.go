@@ -0,0 +1,339 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+)
+
+// A recordFilter reports whether a record matches a --where filter.
+type recordFilter func(r zoneRecord) bool
+
+// whereFields lists the record fields a filter may test, mapped to their
+// kinds: text, number, bool or tags.
+var whereFields = map[string]string{
+	"type":     "text",
+	"name":     "text",
+	"content":  "text",
+	"comment":  "text",
+	"zone":     "text",
+	"ttl":      "number",
+	"priority": "number",
+	"proxied":  "bool",
+	"tag":      "tags",
+	"tags":     "tags",
+}
+
+// whereOperators are the comparison operators of filters, longest first.
+var whereOperators = []string{"==", "!=", "!~", "<=", ">=", "=", "~", "<", ">"}
+
+// A whereToken is a lexical token of a filter: a word, a quoted string, an
+// operator or the end of the filter.
+type whereToken struct {
+	kind string // word, string, op or end
+	text string
+	pos  int
+}
+
+// lexWhere splits a filter into tokens. Words run up to a space, quote,
+// parenthesis or operator character. In quoted strings, \" and \\ stand
+// for a quote and a backslash; other backslashes are kept, so that regular
+// expressions need no double escaping.
+func lexWhere(s string) ([]whereToken, error) {
+	var toks []whereToken
+	i := 0
+	for i < len(s) {
+		c := s[i]
+		switch {
+		case c == ' ' || c == '\t':
+			i++
+
+		case c == '"':
+			var sb strings.Builder
+			j := i + 1
+			for ; j < len(s) && s[j] != '"'; j++ {
+				if s[j] == '\\' && j+1 < len(s) && (s[j+1] == '"' || s[j+1] == '\\') {
+					j++
+				}
+				sb.WriteByte(s[j])
+			}
+			if j >= len(s) {
+				return nil, &exprError{i, "unterminated string"}
+			}
+			toks = append(toks, whereToken{"string", sb.String(), i})
+			i = j + 1
+
+		case strings.HasPrefix(s[i:], "&&") || strings.HasPrefix(s[i:], "||"):
+			toks = append(toks, whereToken{"op", s[i : i+2], i})
+			i += 2
+
+		case c == '(' || c == ')':
+			toks = append(toks, whereToken{"op", string(c), i})
+			i++
+
+		case strings.IndexByte("=!<>~", c) >= 0:
+			op := string(c)
+			for _, o := range whereOperators {
+				if strings.HasPrefix(s[i:], o) {
+					op = o
+					break
+				}
+			}
+			toks = append(toks, whereToken{"op", op, i})
+			i += len(op)
+
+		default:
+			j := i
+			for j < len(s) && strings.IndexByte(" \t\"()=!<>~&|", s[j]) < 0 {
+				j++
+			}
+			if j == i {
+				return nil, &exprError{i, fmt.Sprintf("unexpected character %q", c)}
+			}
+			toks = append(toks, whereToken{"word", s[i:j], i})
+			i = j
+		}
+	}
+	return append(toks, whereToken{"end", "", len(s)}), nil
+}
+
+// A whereParser parses the tokens of a filter.
+type whereParser struct {
+	toks []whereToken
+	i    int
+}
+
+// parseWhere parses a filter such as
+//
+//	type=A && content~"^10\." && ttl<300
+//
+// Comparisons of a field with a value are combined with &&, || and !, and
+// grouped with parentheses. Text fields are compared with =, != and the
+// regular expression operators ~ and !~; types and names ignore case.
+// Numeric fields take any comparison, proxied takes = and != with true or
+// false, and tag matches records with any tag equal to, or with ~ matching,
+// the value.
+func parseWhere(s string) (recordFilter, error) {
+	toks, err := lexWhere(s)
+	if err != nil {
+		return nil, err
+	}
+	p := &whereParser{toks: toks}
+	f, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if tok := p.peek(); tok.kind != "end" {
+		return nil, &exprError{tok.pos, fmt.Sprintf("unexpected %q", tok.text)}
+	}
+	return f, nil
+}
+
+func (p *whereParser) peek() whereToken {
+	return p.toks[p.i]
+}
+
+func (p *whereParser) next() whereToken {
+	tok := p.toks[p.i]
+	if tok.kind != "end" {
+		p.i++
+	}
+	return tok
+}
+
+func (p *whereParser) parseOr() (recordFilter, error) {
+	f, err := p.parseAnd()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "||" && p.peek().kind == "op" {
+		p.next()
+		g, err := p.parseAnd()
+		if err != nil {
+			return nil, err
+		}
+		f = func(f, g recordFilter) recordFilter {
+			return func(r zoneRecord) bool { return f(r) || g(r) }
+		}(f, g)
+	}
+	return f, nil
+}
+
+func (p *whereParser) parseAnd() (recordFilter, error) {
+	f, err := p.parseNot()
+	if err != nil {
+		return nil, err
+	}
+	for p.peek().text == "&&" && p.peek().kind == "op" {
+		p.next()
+		g, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		f = func(f, g recordFilter) recordFilter {
+			return func(r zoneRecord) bool { return f(r) && g(r) }
+		}(f, g)
+	}
+	return f, nil
+}
+
+func (p *whereParser) parseNot() (recordFilter, error) {
+	tok := p.peek()
+	switch {
+	case tok.kind == "op" && tok.text == "!":
+		p.next()
+		f, err := p.parseNot()
+		if err != nil {
+			return nil, err
+		}
+		return func(r zoneRecord) bool { return !f(r) }, nil
+
+	case tok.kind == "op" && tok.text == "(":
+		p.next()
+		f, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.next(); tok.text != ")" || tok.kind != "op" {
+			return nil, &exprError{tok.pos, "missing )"}
+		}
+		return f, nil
+	}
+	return p.parseComparison()
+}
+
+func (p *whereParser) parseComparison() (recordFilter, error) {
+	field := p.next()
+	if field.kind != "word" {
+		return nil, &exprError{field.pos, "expected a field name"}
+	}
+	name := strings.ToLower(field.text)
+	kind, ok := whereFields[name]
+	if !ok {
+		return nil, &exprError{field.pos, fmt.Sprintf("unknown field %q", field.text)}
+	}
+
+	op := p.next()
+	if op.kind != "op" || !isOneOf(op.text, whereOperators) {
+		return nil, &exprError{op.pos, fmt.Sprintf("expected an operator after %s", field.text)}
+	}
+	value := p.next()
+	if value.kind != "word" && value.kind != "string" {
+		return nil, &exprError{value.pos, fmt.Sprintf("expected a value after %s", op.text)}
+	}
+
+	if op.text == "~" || op.text == "!~" {
+		if kind != "text" && kind != "tags" {
+			return nil, &exprError{op.pos, fmt.Sprintf("operator %s needs a text field", op.text)}
+		}
+		re, err := regexp.Compile(value.text)
+		if err != nil {
+			return nil, &exprError{value.pos, fmt.Sprintf("invalid regular expression: %v", err)}
+		}
+		match := func(r zoneRecord) bool {
+			if kind == "tags" {
+				for _, t := range r.Tags {
+					if re.MatchString(t) {
+						return true
+					}
+				}
+				return false
+			}
+			return re.MatchString(whereText(r, name))
+		}
+		if op.text == "!~" {
+			return func(r zoneRecord) bool { return !match(r) }, nil
+		}
+		return match, nil
+	}
+
+	switch kind {
+	case "number":
+		n, err := strconv.Atoi(value.text)
+		if strings.EqualFold(value.text, "auto") && name == "ttl" {
+			n, err = ttlAuto, nil
+		}
+		if err != nil {
+			return nil, &exprError{value.pos, fmt.Sprintf("%s is compared with a number", field.text)}
+		}
+		cmp := op.text
+		return func(r zoneRecord) bool { return compareInts(whereNumber(r, name), n, cmp) }, nil
+
+	case "bool":
+		b, err := strconv.ParseBool(value.text)
+		if err != nil || !isOneOf(op.text, []string{"=", "==", "!="}) {
+			return nil, &exprError{op.pos, fmt.Sprintf("%s is compared with = or != and true or false", field.text)}
+		}
+		want := b == (op.text != "!=")
+		return func(r zoneRecord) bool { return isProxied(r.DNSRecord) == want }, nil
+	}
+
+	if !isOneOf(op.text, []string{"=", "==", "!="}) {
+		return nil, &exprError{op.pos, fmt.Sprintf("operator %s needs a numeric field", op.text)}
+	}
+	equal := op.text != "!="
+	if kind == "tags" {
+		return func(r zoneRecord) bool { return hasTag(r.DNSRecord, value.text) == equal }, nil
+	}
+	want := value.text
+	switch name {
+	case "type":
+		want = strings.ToUpper(want)
+	case "name", "zone":
+		want = cflib.NormalizeName(want)
+	}
+	return func(r zoneRecord) bool { return (whereText(r, name) == want) == equal }, nil
+}
+
+// whereText returns the value of a record's text field, normalized as
+// filters compare it.
+func whereText(r zoneRecord, field string) string {
+	switch field {
+	case "type":
+		return strings.ToUpper(r.Type)
+	case "name":
+		return cflib.NormalizeName(r.Name)
+	case "content":
+		return r.Content
+	case "comment":
+		return r.Comment
+	default:
+		return cflib.NormalizeName(r.zone)
+	}
+}
+
+// whereNumber returns the value of a record's numeric field. Records
+// without a priority have priority 0.
+func whereNumber(r zoneRecord, field string) int {
+	if field == "ttl" {
+		return r.TTL
+	}
+	if r.Priority != nil {
+		return int(*r.Priority)
+	}
+	return 0
+}
+
+func compareInts(a, b int, op string) bool {
+	switch op {
+	case "<":
+		return a < b
+	case "<=":
+		return a <= b
+	case ">":
+		return a > b
+	case ">=":
+		return a >= b
+	case "!=":
+		return a != b
+	default:
+		return a == b
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"slices"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestParseWhere(t *testing.T) {
+	on, prio := true, uint16(10)
+	recs := []zoneRecord{
+		{zone: "example.com", DNSRecord: cloudflare.DNSRecord{Type: "A", Name: "www.example.com", Content: "10.0.0.1", TTL: 120, Proxied: &on}},
+		{zone: "example.com", DNSRecord: cloudflare.DNSRecord{Type: "A", Name: "api.example.com", Content: "10.1.0.1", TTL: 3600,
+			Tags: []string{"team:api"}, Comment: "legacy host"}},
+		{zone: "example.com", DNSRecord: cloudflare.DNSRecord{Type: "AAAA", Name: "www.example.com", Content: "2001:db8::1", TTL: 1}},
+		{zone: "example.com", DNSRecord: cloudflare.DNSRecord{Type: "MX", Name: "example.com", Content: "mail.example.com", TTL: 300, Priority: &prio}},
+		{zone: "example.com", DNSRecord: cloudflare.DNSRecord{Type: "TXT", Name: "my-host.example.com", Content: `v="quoted"`, TTL: 300}},
+	}
+	names := func(f recordFilter) []string {
+		var got []string
+		for _, r := range recs {
+			if f(r) {
+				got = append(got, r.Type+" "+r.Name)
+			}
+		}
+		return got
+	}
+
+	tests := []struct {
+		filter string
+		want   []string
+	}{
+		{`type=A && content~"^10\.0\." && ttl<300`, []string{"A www.example.com"}},
+		{`type == a`, []string{"A www.example.com", "A api.example.com"}},
+		{`name=WWW.example.com. && !(type=AAAA)`, []string{"A www.example.com"}},
+		{`proxied=false && ttl>=300`, []string{"A api.example.com", "MX example.com", "TXT my-host.example.com"}},
+		{`ttl=auto || priority>5`, []string{"AAAA www.example.com", "MX example.com"}},
+		{`tag=team || comment~legacy`, []string{"A api.example.com"}},
+		{`tag!~"^team:"&&type!=MX&&type!=TXT`, []string{"A www.example.com", "AAAA www.example.com"}},
+		{`name=my-host.example.com`, []string{"TXT my-host.example.com"}},
+		{`content="v=\"quoted\""`, []string{"TXT my-host.example.com"}},
+		{`zone=example.org`, nil},
+	}
+	for _, test := range tests {
+		f, err := parseWhere(test.filter)
+		if err != nil {
+			t.Errorf("%s: %v", test.filter, err)
+			continue
+		}
+		if got := names(f); !slices.Equal(got, test.want) {
+			t.Errorf("%s: got %q, want %q", test.filter, got, test.want)
+		}
+	}
+
+	bad := []struct {
+		filter, want string
+	}{
+		{`type=A &&`, "position 10: expected a field name"},
+		{`colour=red`, `position 1: unknown field "colour"`},
+		{`ttl~3`, "position 4: operator ~ needs a text field"},
+		{`name<b`, "position 5: operator < needs a numeric field"},
+		{`ttl<short`, "position 5: ttl is compared with a number"},
+		{`proxied>true`, "position 8: proxied is compared with = or != and true or false"},
+		{`content~"(["`, "position 9: invalid regular expression"},
+		{`(type=A`, "position 8: missing )"},
+		{`type=A)`, `position 7: unexpected ")"`},
+		{`name="www`, "position 6: unterminated string"},
+	}
+	for _, test := range bad {
+		_, err := parseWhere(test.filter)
+		if err == nil || !strings.HasPrefix(err.Error(), test.want) {
+			t.Errorf("%s: got error %v, want %s", test.filter, err, test.want)
+		}
+	}
+}
@@ -0,0 +1,463 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// workerConfigNames are the names of the configuration files looked for in
+// a directory given to worker deploy.
+var workerConfigNames = []string{"wrangler.json", "wrangler.jsonc"}
+
+// workerModuleTypes maps the extensions of the files of a bundle to the
+// content types of their upload parts. Files with other extensions are
+// not uploaded.
+var workerModuleTypes = map[string]string{
+	".js":   "application/javascript+module",
+	".mjs":  "application/javascript+module",
+	".cjs":  "application/javascript",
+	".wasm": "application/wasm",
+	".txt":  "text/plain",
+	".html": "text/plain",
+	".bin":  "application/octet-stream",
+}
+
+// A workerConfig is the part of a wrangler-style configuration file used to
+// deploy a Worker bundle. Module files are found in base_dir, which
+// defaults to the directory of main.
+type workerConfig struct {
+	Name               string         `json:"name"`
+	Main               string         `json:"main"`
+	BaseDir            string         `json:"base_dir"`
+	CompatibilityDate  string         `json:"compatibility_date"`
+	CompatibilityFlags []string       `json:"compatibility_flags"`
+	Vars               map[string]any `json:"vars"`
+	KVNamespaces       []struct {
+		Binding string `json:"binding"`
+		ID      string `json:"id"`
+	} `json:"kv_namespaces"`
+	R2Buckets []struct {
+		Binding    string `json:"binding"`
+		BucketName string `json:"bucket_name"`
+	} `json:"r2_buckets"`
+	D1Databases []struct {
+		Binding    string `json:"binding"`
+		DatabaseID string `json:"database_id"`
+	} `json:"d1_databases"`
+	Services []struct {
+		Binding     string `json:"binding"`
+		Service     string `json:"service"`
+		Environment string `json:"environment"`
+	} `json:"services"`
+	DurableObjects struct {
+		Bindings []struct {
+			Name       string `json:"name"`
+			ClassName  string `json:"class_name"`
+			ScriptName string `json:"script_name"`
+		} `json:"bindings"`
+	} `json:"durable_objects"`
+}
+
+// A workerBundle is a Worker script ready to upload: its modules, the first
+// of which is the main one, and the metadata describing them.
+type workerBundle struct {
+	name     string
+	modules  []workerModule
+	metadata workerMetadata
+}
+
+type workerModule struct {
+	name        string
+	contentType string
+	content     []byte
+}
+
+type workerMetadata struct {
+	MainModule         string           `json:"main_module,omitempty"`
+	BodyPart           string           `json:"body_part,omitempty"`
+	CompatibilityDate  string           `json:"compatibility_date,omitempty"`
+	CompatibilityFlags []string         `json:"compatibility_flags,omitempty"`
+	Bindings           []map[string]any `json:"bindings"`
+}
+
+var workerFlags = registerFlags("worker", flagSpec{"name": true})
+
+func cmdWorker(c *cmd.Command, args []string) error {
+	if len(args) > 0 {
+		switch args[0] {
+		case "routes":
+			return cmdWorkerRoutes(c, args[1:])
+		case "subdomain":
+			return cmdWorkerSubdomain(c, args[1:])
+		case "usage":
+			return cmdWorkerUsage(c, args[1:])
+		}
+	}
+
+	flags, args, err := parseFlags(args, workerFlags)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		return listWorkers()
+	case len(args) == 2 && args[0] == "deploy":
+		return deployWorker(args[1], flags.get("name", ""))
+	default:
+		return usageError(c)
+	}
+}
+
+func listWorkers() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	rc, err := accountRC(api)
+	if err != nil {
+		return err
+	}
+
+	resp, _, err := api.ListWorkers(commandCtx, rc, cloudflare.ListWorkersParams{})
+	if err != nil {
+		return err
+	}
+	if len(resp.WorkerList) == 0 {
+		printf("No workers found.\n")
+		return nil
+	}
+
+	scripts := resp.WorkerList
+	sort.Slice(scripts, func(i, j int) bool { return scripts[i].ID < scripts[j].ID })
+	width := 0
+	for _, s := range scripts {
+		width = max(width, len(s.ID))
+	}
+	for _, s := range scripts {
+		fmt.Fprintf(output, "%-*s %s\n", width, s.ID, s.ModifiedOn.Local().Format("2006-01-02 15:04"))
+	}
+	return nil
+}
+
+// deployWorker uploads a Worker to the active zone's account. The source is
+// a single script, or a configuration file, or a directory holding one, for
+// a bundle of modules. A name given with --name overrides the configured
+// one.
+func deployWorker(source, name string) error {
+	bundle, err := loadWorkerBundle(source)
+	if err != nil {
+		return err
+	}
+	if name != "" {
+		bundle.name = name
+	}
+	if bundle.name == "" {
+		return argError(errors.New("the worker has no name; give one with --name"))
+	}
+
+	contentType, body, err := formatWorkerUpload(bundle)
+	if err != nil {
+		return err
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	rc, err := accountRC(api)
+	if err != nil {
+		return err
+	}
+
+	_, err = api.Raw(commandCtx, http.MethodPut,
+		"/accounts/"+rc.Identifier+"/workers/scripts/"+bundle.name,
+		body, http.Header{"Content-Type": {contentType}})
+	if err != nil {
+		return err
+	}
+
+	printf("Deployed worker %s with %d module(s) and %d binding(s).\n",
+		bundle.name, len(bundle.modules), len(bundle.metadata.Bindings))
+	return nil
+}
+
+// loadWorkerBundle reads the Worker to deploy from a script, configuration
+// file or directory.
+func loadWorkerBundle(source string) (workerBundle, error) {
+	info, err := os.Stat(source)
+	if err != nil {
+		return workerBundle{}, err
+	}
+	if info.IsDir() {
+		for _, n := range workerConfigNames {
+			p := filepath.Join(source, n)
+			if _, err := os.Stat(p); err == nil {
+				return readWorkerConfig(p)
+			}
+		}
+		return workerBundle{}, fmt.Errorf("%s has no %s", source, strings.Join(workerConfigNames, " or "))
+	}
+	if ext := filepath.Ext(source); ext == ".json" || ext == ".jsonc" {
+		return readWorkerConfig(source)
+	}
+	return readWorkerScript(source)
+}
+
+// readWorkerScript reads a single-file Worker, named after its file. A
+// script exporting a default handler is uploaded as an ES module, and any
+// other as a service worker script.
+func readWorkerScript(file string) (workerBundle, error) {
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return workerBundle{}, err
+	}
+
+	base := filepath.Base(file)
+	b := workerBundle{name: strings.TrimSuffix(base, filepath.Ext(base))}
+	if filepath.Ext(base) == ".mjs" || bytes.Contains(content, []byte("export default")) {
+		b.modules = []workerModule{{base, "application/javascript+module", content}}
+		b.metadata.MainModule = base
+	} else {
+		b.modules = []workerModule{{"script", "application/javascript", content}}
+		b.metadata.BodyPart = "script"
+	}
+	b.metadata.Bindings = []map[string]any{}
+	return b, nil
+}
+
+// readWorkerConfig reads a wrangler-style configuration file and the
+// modules of the bundle it describes.
+func readWorkerConfig(file string) (workerBundle, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return workerBundle{}, err
+	}
+	var wc workerConfig
+	if err := json.Unmarshal(stripJSONComments(data), &wc); err != nil {
+		return workerBundle{}, fmt.Errorf("%s: %v", file, err)
+	}
+	if wc.Main == "" {
+		return workerBundle{}, fmt.Errorf("%s: no main module", file)
+	}
+
+	dir := filepath.Dir(file)
+	mainPath := filepath.Join(dir, wc.Main)
+	baseDir := filepath.Dir(mainPath)
+	if wc.BaseDir != "" {
+		baseDir = filepath.Join(dir, wc.BaseDir)
+	}
+	mainName, err := filepath.Rel(baseDir, mainPath)
+	if err != nil || strings.HasPrefix(mainName, "..") {
+		return workerBundle{}, fmt.Errorf("%s: main module %s is outside %s", file, wc.Main, baseDir)
+	}
+	mainName = filepath.ToSlash(mainName)
+
+	modules, err := findWorkerModules(baseDir)
+	if err != nil {
+		return workerBundle{}, err
+	}
+	i := sort.Search(len(modules), func(i int) bool { return modules[i].name >= mainName })
+	if i == len(modules) || modules[i].name != mainName {
+		return workerBundle{}, fmt.Errorf("%s: main module %s not found", file, wc.Main)
+	}
+	// The main module is uploaded first.
+	main := modules[i]
+	modules = slices.Insert(slices.Delete(modules, i, i+1), 0, main)
+
+	bindings, err := workerBindings(wc)
+	if err != nil {
+		return workerBundle{}, fmt.Errorf("%s: %v", file, err)
+	}
+
+	return workerBundle{
+		name:    wc.Name,
+		modules: modules,
+		metadata: workerMetadata{
+			MainModule:         mainName,
+			CompatibilityDate:  wc.CompatibilityDate,
+			CompatibilityFlags: wc.CompatibilityFlags,
+			Bindings:           bindings,
+		},
+	}, nil
+}
+
+// findWorkerModules reads the files of a bundle's base directory with the
+// extensions of module types, sorted by name. Hidden directories and
+// node_modules are skipped.
+func findWorkerModules(baseDir string) ([]workerModule, error) {
+	var modules []workerModule
+	err := filepath.WalkDir(baseDir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if p != baseDir && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		contentType, ok := workerModuleTypes[strings.ToLower(filepath.Ext(p))]
+		if !ok {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		name, err := filepath.Rel(baseDir, p)
+		if err != nil {
+			return err
+		}
+		modules = append(modules, workerModule{filepath.ToSlash(name), contentType, content})
+		return nil
+	})
+	sort.Slice(modules, func(i, j int) bool { return modules[i].name < modules[j].name })
+	return modules, err
+}
+
+// workerBindings returns the binding definitions of a configuration in the
+// form of the upload metadata, sorted by name.
+func workerBindings(wc workerConfig) ([]map[string]any, error) {
+	bindings := []map[string]any{}
+	add := func(name, kind string, fields ...string) {
+		b := map[string]any{"name": name, "type": kind}
+		for i := 0; i+1 < len(fields); i += 2 {
+			if fields[i+1] != "" {
+				b[fields[i]] = fields[i+1]
+			}
+		}
+		bindings = append(bindings, b)
+	}
+
+	for name, v := range wc.Vars {
+		if s, ok := v.(string); ok {
+			add(name, "plain_text", "text", s)
+		} else {
+			bindings = append(bindings, map[string]any{"name": name, "type": "json", "json": v})
+		}
+	}
+	for _, kv := range wc.KVNamespaces {
+		add(kv.Binding, "kv_namespace", "namespace_id", kv.ID)
+	}
+	for _, r2 := range wc.R2Buckets {
+		add(r2.Binding, "r2_bucket", "bucket_name", r2.BucketName)
+	}
+	for _, d1 := range wc.D1Databases {
+		add(d1.Binding, "d1", "id", d1.DatabaseID)
+	}
+	for _, s := range wc.Services {
+		add(s.Binding, "service", "service", s.Service, "environment", s.Environment)
+	}
+	for _, do := range wc.DurableObjects.Bindings {
+		add(do.Name, "durable_object_namespace", "class_name", do.ClassName, "script_name", do.ScriptName)
+	}
+
+	seen := make(map[string]bool)
+	for _, b := range bindings {
+		name := b["name"].(string)
+		if name == "" {
+			return nil, fmt.Errorf("%s binding has no name", b["type"])
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("binding %s is defined more than once", name)
+		}
+		seen[name] = true
+	}
+	sort.Slice(bindings, func(i, j int) bool { return bindings[i]["name"].(string) < bindings[j]["name"].(string) })
+	return bindings, nil
+}
+
+// formatWorkerUpload encodes a bundle as the multipart form uploaded to the
+// Workers API and returns its content type.
+func formatWorkerUpload(b workerBundle) (string, []byte, error) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+
+	meta, err := json.Marshal(b.metadata)
+	if err != nil {
+		return "", nil, err
+	}
+	hdr := textproto.MIMEHeader{}
+	hdr.Set("Content-Disposition", `form-data; name="metadata"`)
+	hdr.Set("Content-Type", "application/json")
+	part, err := w.CreatePart(hdr)
+	if err != nil {
+		return "", nil, err
+	}
+	if _, err := part.Write(meta); err != nil {
+		return "", nil, err
+	}
+
+	for _, m := range b.modules {
+		hdr := textproto.MIMEHeader{}
+		hdr.Set("Content-Disposition", fmt.Sprintf(`form-data; name=%q; filename=%q`, m.name, m.name))
+		hdr.Set("Content-Type", m.contentType)
+		part, err := w.CreatePart(hdr)
+		if err != nil {
+			return "", nil, err
+		}
+		if _, err := part.Write(m.content); err != nil {
+			return "", nil, err
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return "", nil, err
+	}
+	return w.FormDataContentType(), buf.Bytes(), nil
+}
+
+// stripJSONComments removes the // and /* */ comments of a JSONC document,
+// leaving strings intact.
+func stripJSONComments(data []byte) []byte {
+	var out []byte
+	for i := 0; i < len(data); i++ {
+		switch {
+		case data[i] == '"':
+			j := i + 1
+			for ; j < len(data) && data[j] != '"'; j++ {
+				if data[j] == '\\' {
+					j++
+				}
+			}
+			end := min(j+1, len(data))
+			out = append(out, data[i:end]...)
+			i = end - 1
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '/':
+			for i < len(data) && data[i] != '\n' {
+				i++
+			}
+			if i < len(data) {
+				out = append(out, '\n')
+			}
+		case data[i] == '/' && i+1 < len(data) && data[i+1] == '*':
+			end := bytes.Index(data[i+2:], []byte("*/"))
+			if end < 0 {
+				return out
+			}
+			i += end + 3
+		default:
+			out = append(out, data[i])
+		}
+	}
+	return out
+}
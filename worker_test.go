@@ -0,0 +1,116 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"mime/multipart"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestWorkerBundle(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"wrangler.jsonc": `{
+			// Deployed by cf.
+			"name": "api",
+			"main": "dist/index.js",
+			"compatibility_date": "2024-09-23",
+			"vars": {"ENVIRONMENT": "production", "LIMITS": {"rps": 10}, "URL": "https://example.com/*"},
+			"kv_namespaces": [{"binding": "CACHE", "id": "kv1"}],
+			"services": [{"binding": "AUTH", "service": "auth"}] /* no environment */
+		}`,
+		"dist/index.js":              `import { route } from "./lib/route.js"; export default { fetch: route };`,
+		"dist/lib/route.js":          `export function route() {}`,
+		"dist/lib/image.wasm":        "\x00asm",
+		"dist/README.md":             "not uploaded",
+		"dist/node_modules/x/x.js":   "not uploaded",
+		"dist/.cache/stale/index.js": "not uploaded",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	b, err := loadWorkerBundle(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.name != "api" || b.metadata.MainModule != "index.js" {
+		t.Errorf("name, main module = %q, %q, want api, index.js", b.name, b.metadata.MainModule)
+	}
+	var names []string
+	for _, m := range b.modules {
+		names = append(names, m.name+" "+m.contentType)
+	}
+	want := []string{
+		"index.js application/javascript+module",
+		"lib/image.wasm application/wasm",
+		"lib/route.js application/javascript+module",
+	}
+	if !slices.Equal(names, want) {
+		t.Errorf("modules = %q, want %q", names, want)
+	}
+
+	contentType, body, err := formatWorkerUpload(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	part, err := r.NextPart()
+	if err != nil || part.FormName() != "metadata" {
+		t.Fatalf("first part = %v, %v, want metadata", part, err)
+	}
+	meta, _ := io.ReadAll(part)
+	wantMeta := `{"main_module":"index.js","compatibility_date":"2024-09-23","bindings":[` +
+		`{"name":"AUTH","service":"auth","type":"service"},` +
+		`{"name":"CACHE","namespace_id":"kv1","type":"kv_namespace"},` +
+		`{"name":"ENVIRONMENT","text":"production","type":"plain_text"},` +
+		`{"json":{"rps":10},"name":"LIMITS","type":"json"},` +
+		`{"name":"URL","text":"https://example.com/*","type":"plain_text"}]}`
+	if !json.Valid(meta) || string(meta) != wantMeta {
+		t.Errorf("metadata = %s, want %s", meta, wantMeta)
+	}
+	var parts []string
+	for {
+		part, err := r.NextPart()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		parts = append(parts, part.FormName())
+	}
+	if want := []string{"index.js", "lib/image.wasm", "lib/route.js"}; !slices.Equal(parts, want) {
+		t.Errorf("parts = %q, want %q", parts, want)
+	}
+
+	single := filepath.Join(dir, "hello.js")
+	if err := os.WriteFile(single, []byte(`addEventListener("fetch", e => {})`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	b, err = loadWorkerBundle(single)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b.name != "hello" || b.metadata.BodyPart != "script" || b.metadata.MainModule != "" {
+		t.Errorf("service worker bundle = %+v", b.metadata)
+	}
+}
@@ -0,0 +1,140 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+var workerRoutesFlags = registerFlags("worker routes", forceFlags)
+
+// cmdWorkerRoutes lists, adds and deletes the Worker routes of the active
+// zone.
+func cmdWorkerRoutes(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, workerRoutesFlags)
+	if err != nil {
+		return err
+	}
+
+	switch {
+	case len(args) == 1 && args[0] == "list":
+		return listWorkerRoutes()
+	case len(args) == 3 && args[0] == "add":
+		return addWorkerRoute(args[1], args[2])
+	case len(args) == 2 && args[0] == "delete":
+		return deleteWorkerRoute(args[1], flags.force())
+	default:
+		return usageError(c)
+	}
+}
+
+func listWorkerRoutes() error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	resp, err := api.ListWorkerRoutes(commandCtx, zoneID, cloudflare.ListWorkerRoutesParams{})
+	if err != nil {
+		return err
+	}
+	if len(resp.Routes) == 0 {
+		printf("No worker routes found.\n")
+		return nil
+	}
+
+	routes := resp.Routes
+	sort.Slice(routes, func(i, j int) bool { return routes[i].Pattern < routes[j].Pattern })
+	width := 0
+	for _, r := range routes {
+		width = max(width, len(r.Pattern))
+	}
+	for _, r := range routes {
+		script := r.ScriptName
+		if script == "" {
+			script = "(no worker)"
+		}
+		fmt.Fprintf(output, "%s %-*s %s\n", r.ID, width, r.Pattern, script)
+	}
+	return nil
+}
+
+// addWorkerRoute routes the requests matching a pattern, such as
+// api.example.com/*, to a Worker script. The pattern's host must be in the
+// active zone.
+func addWorkerRoute(pattern, script string) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	host, _, _ := strings.Cut(pattern, "/")
+	if !strings.Contains(pattern, "/") || !inZone(strings.TrimPrefix(host, "*."), activeZoneName) {
+		return argError(fmt.Errorf("route pattern %q must be a host in zone %s followed by a path, such as %s/*",
+			pattern, activeZoneName, activeZoneName))
+	}
+
+	resp, err := api.CreateWorkerRoute(commandCtx, zoneID, cloudflare.CreateWorkerRouteParams{
+		Pattern: pattern,
+		Script:  script,
+	})
+	if err != nil {
+		return err
+	}
+	printf("Added route %s for %s to worker %s.\n", resp.ID, pattern, script)
+	return nil
+}
+
+// deleteWorkerRoute deletes the Worker route with a pattern or ID, after
+// confirmation.
+func deleteWorkerRoute(route string, force bool) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	resp, err := api.ListWorkerRoutes(commandCtx, zoneID, cloudflare.ListWorkerRoutesParams{})
+	if err != nil {
+		return err
+	}
+	i := -1
+	for j, r := range resp.Routes {
+		if r.ID == route || r.Pattern == route {
+			i = j
+			break
+		}
+	}
+	if i < 0 {
+		return fmt.Errorf("no worker route %s found", route)
+	}
+	r := resp.Routes[i]
+
+	if !force && !confirm(sprintf("Delete route %s to worker %s?", r.Pattern, r.ScriptName)) {
+		printf("Route not deleted.\n")
+		return nil
+	}
+	if _, err := api.DeleteWorkerRoute(commandCtx, zoneID, r.ID); err != nil {
+		return err
+	}
+	printf("Deleted route %s.\n", r.Pattern)
+	return nil
+}
@@ -0,0 +1,243 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// The period of worker usage by default. Cloudflare keeps the invocation
+// analytics of Workers for about a month.
+const defaultWorkerUsagePeriod = 7 * 24 * time.Hour
+
+// A workerUsage is the use of a Worker script over a period. CPU times are
+// the median and 99th percentile per request, in microseconds.
+type workerUsage struct {
+	Script   string    `json:"script"`
+	Modified time.Time `json:"modified_on"`
+	Requests int64     `json:"requests"`
+	Errors   int64     `json:"errors"`
+	CPUP50   float64   `json:"cpu_time_p50_us"`
+	CPUP99   float64   `json:"cpu_time_p99_us"`
+}
+
+var workerSubdomainFlags = registerFlags("worker subdomain", forceFlags)
+
+// cmdWorkerSubdomain displays the workers.dev subdomain of the active zone's
+// account, or sets it after confirmation.
+func cmdWorkerSubdomain(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, workerSubdomainFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) > 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	rc, err := accountRC(api)
+	if err != nil {
+		return err
+	}
+
+	current, err := api.WorkersGetSubdomain(commandCtx, rc)
+	if err != nil && len(args) == 0 {
+		return err
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(output, "%s.workers.dev\n", current.Name)
+		return nil
+	}
+
+	name := strings.TrimSuffix(strings.ToLower(args[0]), ".workers.dev")
+	if err := validateName(name, false); err != nil || strings.Contains(name, ".") {
+		return argError(fmt.Errorf("invalid subdomain %q", args[0]))
+	}
+	if current.Name == name {
+		printf("The workers.dev subdomain is already %s.\n", name)
+		return nil
+	}
+	if current.Name != "" && !flags.force() &&
+		!confirm(sprintf("Change the workers.dev subdomain from %s to %s? Workers will no longer be reachable at %s.workers.dev.",
+			current.Name, name, current.Name)) {
+		printf("Subdomain not changed.\n")
+		return nil
+	}
+	if _, err := api.WorkersCreateSubdomain(commandCtx, rc, cloudflare.WorkersSubdomain{Name: name}); err != nil {
+		return err
+	}
+	printf("Set the workers.dev subdomain to %s.\n", name)
+	return nil
+}
+
+var workerUsageFlags = registerFlags("worker usage", flagSpec{"since": true, "unused": false})
+
+// cmdWorkerUsage displays the requests, errors and CPU time of every Worker
+// script of the active zone's account over a period, and the scripts that
+// received no requests, which may be candidates for cleanup.
+func cmdWorkerUsage(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, workerUsageFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	since := defaultWorkerUsagePeriod
+	if flags.has("since") {
+		if since, err = parseAge(flags.get("since", "")); err != nil || since == 0 {
+			return argError(fmt.Errorf("invalid time %q", flags.get("since", "")))
+		}
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	rc, err := accountRC(api)
+	if err != nil {
+		return err
+	}
+
+	resp, _, err := api.ListWorkers(commandCtx, rc, cloudflare.ListWorkersParams{})
+	if err != nil {
+		return err
+	}
+	now := time.Now().UTC().Truncate(time.Minute)
+	usage, err := accountWorkerUsage(api, rc.Identifier, now.Add(-since), now)
+	if err != nil {
+		return err
+	}
+
+	var report []workerUsage
+	for _, s := range resp.WorkerList {
+		u := usage[s.ID]
+		u.Script, u.Modified = s.ID, s.ModifiedOn
+		if flags.has("unused") && u.Requests != 0 {
+			continue
+		}
+		report = append(report, u)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		if report[i].Requests != report[j].Requests {
+			return report[i].Requests > report[j].Requests
+		}
+		return report[i].Script < report[j].Script
+	})
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(output)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+	displayWorkerUsage(report, since)
+	return nil
+}
+
+// accountWorkerUsage returns the use of the Worker scripts of an account
+// between two times, by script name.
+func accountWorkerUsage(api *cloudflare.API, accountID string, since, until time.Time) (map[string]workerUsage, error) {
+	const query = `query ($account: string, $since: Time, $until: Time) {
+  viewer {
+    accounts(filter: {accountTag: $account}) {
+      workersInvocationsAdaptive(limit: 10000, filter: {datetime_geq: $since, datetime_lt: $until}) {
+        sum { requests errors }
+        quantiles { cpuTimeP50 cpuTimeP99 }
+        dimensions { scriptName }
+      }
+    }
+  }
+}`
+
+	var data struct {
+		Viewer struct {
+			Accounts []struct {
+				Invocations []struct {
+					Sum struct {
+						Requests int64 `json:"requests"`
+						Errors   int64 `json:"errors"`
+					} `json:"sum"`
+					Quantiles struct {
+						CPUTimeP50 float64 `json:"cpuTimeP50"`
+						CPUTimeP99 float64 `json:"cpuTimeP99"`
+					} `json:"quantiles"`
+					Dimensions struct {
+						ScriptName string `json:"scriptName"`
+					} `json:"dimensions"`
+				} `json:"workersInvocationsAdaptive"`
+			} `json:"accounts"`
+		} `json:"viewer"`
+	}
+	vars := map[string]any{
+		"account": accountID,
+		"since":   since.Format(time.RFC3339),
+		"until":   until.Format(time.RFC3339),
+	}
+	if err := graphQL(api, query, vars, &data); err != nil {
+		return nil, err
+	}
+
+	usage := make(map[string]workerUsage)
+	for _, a := range data.Viewer.Accounts {
+		for _, g := range a.Invocations {
+			u := usage[g.Dimensions.ScriptName]
+			u.Requests += g.Sum.Requests
+			u.Errors += g.Sum.Errors
+			u.CPUP50 = max(u.CPUP50, g.Quantiles.CPUTimeP50)
+			u.CPUP99 = max(u.CPUP99, g.Quantiles.CPUTimeP99)
+			usage[g.Dimensions.ScriptName] = u
+		}
+	}
+	return usage, nil
+}
+
+func displayWorkerUsage(report []workerUsage, since time.Duration) {
+	if len(report) == 0 {
+		printf("No workers found.\n")
+		return
+	}
+
+	width := len(tr("Script"))
+	for _, u := range report {
+		width = max(width, len(u.Script))
+	}
+	fmt.Fprintf(output, "%-*s %12s %10s %10s %10s  %s\n", width, tr("Script"), tr("Requests"), tr("Errors"),
+		tr("CPU p50"), tr("CPU p99"), tr("Modified"))
+	var unused []string
+	for _, u := range report {
+		fmt.Fprintf(output, "%-*s %12d %10d %10s %10s  %s\n", width, u.Script, u.Requests, u.Errors,
+			formatCPUTime(u.CPUP50), formatCPUTime(u.CPUP99), u.Modified.Local().Format("2006-01-02"))
+		if u.Requests == 0 {
+			unused = append(unused, u.Script)
+		}
+	}
+	if len(unused) > 0 {
+		fmt.Fprintln(output)
+		printf("%d worker(s) received no requests in the last %s: %s\n",
+			len(unused), formatAge(since), strings.Join(unused, ", "))
+	}
+}
+
+// formatCPUTime formats a CPU time given in microseconds.
+func formatCPUTime(us float64) string {
+	if us == 0 {
+		return "-"
+	}
+	if us < 1000 {
+		return fmt.Sprintf("%.0fµs", us)
+	}
+	return fmt.Sprintf("%.1fms", us/1000)
+}
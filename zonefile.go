@@ -0,0 +1,206 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/beevik/cf/cflib"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// parseZoneFile reads the records of a BIND zone file, such as the one
+// exported by "zone offboard". Relative names are completed with origin
+// until a $ORIGIN directive changes it. SOA records are skipped, and the
+// proxy settings Cloudflare records in cf_tags comments are kept.
+func parseZoneFile(r io.Reader, origin string) ([]cloudflare.DNSRecord, error) {
+	origin = cflib.NormalizeName(origin)
+	var recs []cloudflare.DNSRecord
+	var owner string
+	defaultTTL := 0
+
+	scanner := bufio.NewScanner(r)
+	n := 0
+	for scanner.Scan() {
+		n++
+		start := n
+		line, comment := splitZoneComment(scanner.Text())
+
+		// Parentheses continue an entry over several lines.
+		for strings.Count(line, "(") > strings.Count(line, ")") && scanner.Scan() {
+			n++
+			more, c := splitZoneComment(scanner.Text())
+			line += " " + more
+			comment += " " + c
+		}
+		line = strings.NewReplacer("(", " ", ")", " ").Replace(line)
+
+		fields := zoneFields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		if strings.HasPrefix(fields[0], "$") {
+			switch strings.ToUpper(fields[0]) {
+			case "$ORIGIN":
+				if len(fields) < 2 {
+					return nil, fmt.Errorf("line %d: missing origin", start)
+				}
+				origin = cflib.NormalizeName(fields[1])
+			case "$TTL":
+				ttl, err := strconv.Atoi(fieldAt(fields, 1))
+				if err != nil {
+					return nil, fmt.Errorf("line %d: invalid TTL %q", start, fieldAt(fields, 1))
+				}
+				defaultTTL = ttl
+			default:
+				return nil, fmt.Errorf("line %d: unsupported directive %s", start, fields[0])
+			}
+			continue
+		}
+
+		// An entry starting with a space has the previous entry's owner.
+		if line[0] != ' ' && line[0] != '\t' {
+			owner = absoluteName(fields[0], origin)
+			fields = fields[1:]
+		} else if owner == "" {
+			return nil, fmt.Errorf("line %d: missing owner name", start)
+		}
+
+		rec := cloudflare.DNSRecord{Name: owner, TTL: defaultTTL}
+		for len(fields) > 0 && rec.Type == "" {
+			f := fields[0]
+			fields = fields[1:]
+			if ttl, err := strconv.Atoi(f); err == nil {
+				rec.TTL = ttl
+				continue
+			}
+			switch strings.ToUpper(f) {
+			case "IN", "CH", "HS", "CS":
+			default:
+				rec.Type = strings.ToUpper(f)
+			}
+		}
+		if rec.Type == "" || len(fields) == 0 {
+			return nil, fmt.Errorf("line %d: expected a record type and data", start)
+		}
+		if rec.Type == "SOA" {
+			continue
+		}
+
+		switch rec.Type {
+		case "MX", "SRV", "URI":
+			prio, err := strconv.ParseUint(fields[0], 10, 16)
+			if err != nil || len(fields) < 2 {
+				return nil, fmt.Errorf("line %d: invalid %s data", start, rec.Type)
+			}
+			p := uint16(prio)
+			rec.Priority = &p
+			fields = fields[1:]
+		}
+		rec.Content = strings.Join(fields, " ")
+		switch rec.Type {
+		case "CNAME", "NS", "PTR", "MX":
+			rec.Content = absoluteName(rec.Content, origin)
+		}
+
+		if tags := cfTag(comment, "cf-proxied"); tags != "" {
+			proxied := tags == "true"
+			rec.Proxied = &proxied
+		}
+		recs = append(recs, rec)
+	}
+	return recs, scanner.Err()
+}
+
+// splitZoneComment separates a zone file line from its comment, ignoring
+// semicolons in quoted strings.
+func splitZoneComment(line string) (text, comment string) {
+	quoted := false
+	for i := 0; i < len(line); i++ {
+		switch line[i] {
+		case '\\':
+			i++
+		case '"':
+			quoted = !quoted
+		case ';':
+			if !quoted {
+				return line[:i], line[i+1:]
+			}
+		}
+	}
+	return line, ""
+}
+
+// zoneFields splits a zone file line into fields, keeping each quoted
+// string, with its quotes, as a single field.
+func zoneFields(line string) []string {
+	var fields []string
+	for i := 0; i < len(line); {
+		switch c := line[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '"':
+			j := i + 1
+			for ; j < len(line) && line[j] != '"'; j++ {
+				if line[j] == '\\' {
+					j++
+				}
+			}
+			j = min(j+1, len(line))
+			fields = append(fields, line[i:j])
+			i = j
+		default:
+			j := i
+			for j < len(line) && line[j] != ' ' && line[j] != '\t' {
+				j++
+			}
+			fields = append(fields, line[i:j])
+			i = j
+		}
+	}
+	return fields
+}
+
+func fieldAt(fields []string, i int) string {
+	if i < len(fields) {
+		return fields[i]
+	}
+	return ""
+}
+
+// absoluteName completes a zone file name relative to the origin.
+func absoluteName(name, origin string) string {
+	switch {
+	case name == "@":
+		return origin
+	case strings.HasSuffix(name, "."):
+		return cflib.NormalizeName(name)
+	case origin == "":
+		return cflib.NormalizeName(name)
+	default:
+		return cflib.NormalizeName(name + "." + origin)
+	}
+}
+
+// cfTag returns the value of a tag in the cf_tags of a zone file comment,
+// such as "cf_tags=cf-proxied:true".
+func cfTag(comment, tag string) string {
+	for _, f := range strings.Fields(comment) {
+		list, ok := strings.CutPrefix(f, "cf_tags=")
+		if !ok {
+			continue
+		}
+		for _, t := range strings.Split(list, ",") {
+			if v, ok := strings.CutPrefix(t, tag+":"); ok {
+				return v
+			}
+		}
+	}
+	return ""
+}
@@ -0,0 +1,163 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// API tokens scoped to a single zone's DNS records often cannot list zones,
+// so zone names cannot be looked up. Such a zone is selected by its ID
+// instead, with "zone set-id", the "zone_id" of a profile or the
+// CLOUDFLARE_ZONE_ID environment variable. The zones selected by ID are
+// remembered for the rest of the process, and stand in for the list of
+// zones when it cannot be read.
+
+// knownZoneIDs holds the IDs of the zones selected by ID, by normalized
+// zone name.
+var knownZoneIDs sync.Map
+
+// zoneIDPattern matches the form of Cloudflare zone IDs.
+var zoneIDPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+// errCannotListZones is returned when a zone list is needed, the
+// credentials cannot list zones and no zone has been selected by ID.
+var errCannotListZones = errors.New("the credentials cannot list zones; " +
+	"select a zone by ID with \"zone set-id <id> <name>\"")
+
+// rememberZoneID records the ID of a zone, so that its name is not looked
+// up.
+func rememberZoneID(name, zoneID string) {
+	knownZoneIDs.Store(cflib.NormalizeName(name), zoneID)
+	zoneNames.Store(zoneID, cflib.NormalizeName(name))
+}
+
+// knownZones returns the zones selected by ID, sorted by name.
+func knownZones() []zoneTarget {
+	var targets []zoneTarget
+	knownZoneIDs.Range(func(name, zoneID any) bool {
+		targets = append(targets, zoneTarget{name.(string), cloudflare.ZoneIdentifier(zoneID.(string))})
+		return true
+	})
+	sort.Slice(targets, func(i, j int) bool { return targets[i].name < targets[j].name })
+	return targets
+}
+
+// cannotListZones reports whether an error is the refusal of a request to
+// list zones.
+func cannotListZones(err error) bool {
+	var cfErr *cloudflare.Error
+	return errors.As(err, &cfErr) && cfErr.StatusCode == http.StatusForbidden
+}
+
+// A knownZoneBackend looks up the zones selected by ID without asking the
+// backend it wraps, and suggests selecting a zone by ID when its name
+// cannot be looked up.
+type knownZoneBackend struct {
+	cflib.Backend
+}
+
+func (b knownZoneBackend) ZoneIDByName(name string) (string, error) {
+	if zoneID, ok := knownZoneIDs.Load(cflib.NormalizeName(name)); ok {
+		return zoneID.(string), nil
+	}
+	zoneID, err := b.Backend.ZoneIDByName(name)
+	if err != nil && (cannotListZones(err) || strings.Contains(err.Error(), "could not be found")) {
+		err = fmt.Errorf("%w; if the credentials cannot list zones, select it by ID with \"zone set-id <id> %s\"",
+			err, cflib.NormalizeName(name))
+	}
+	return zoneID, err
+}
+
+// accessibleZones returns the zones of the account, or the zones selected
+// by ID if the credentials cannot list them.
+func accessibleZones(api *cloudflare.API) ([]zoneTarget, error) {
+	zones, err := api.ListZones(commandCtx)
+	if cannotListZones(err) || (err == nil && len(zones) == 0) {
+		return zonesSelectedByID(err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	var targets []zoneTarget
+	for _, z := range zones {
+		targets = append(targets, zoneTarget{z.Name, cloudflare.ZoneIdentifier(z.ID)})
+	}
+	return targets, nil
+}
+
+// zonesSelectedByID returns the zones selected by ID in place of a zone
+// list that could not be read, with a warning, or an error if there are
+// none. listErr is the error of the zone list request, or nil if the list
+// was empty.
+func zonesSelectedByID(listErr error) ([]zoneTarget, error) {
+	known := knownZones()
+	switch {
+	case len(known) > 0:
+		printf("Warning: the credentials cannot list zones; using the zones selected by ID.\n")
+		return known, nil
+	case listErr != nil:
+		return nil, zoneError(errCannotListZones)
+	default:
+		return nil, zoneError(errors.New("no zones found; if the credentials cannot list zones, " +
+			"select a zone by ID with \"zone set-id <id> <name>\""))
+	}
+}
+
+// cmdZoneSetID makes the zone with an ID the active zone, without looking
+// up its name. The name is read from the zone's details, or must be given
+// when the credentials cannot read them.
+func cmdZoneSetID(c *cmd.Command, args []string) error {
+	if len(args) < 1 || len(args) > 2 {
+		return usageError(c)
+	}
+	zoneID := strings.ToLower(args[0])
+	if !zoneIDPattern.MatchString(zoneID) {
+		return argError(fmt.Errorf("invalid zone ID %q; zone IDs are 32 hexadecimal digits", args[0]))
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	var name string
+	if len(args) == 2 {
+		name = cflib.NormalizeName(args[1])
+		if err := validateName(name, false); err != nil {
+			return argError(err)
+		}
+	} else {
+		zone, err := api.ZoneDetails(commandCtx, zoneID)
+		if err != nil {
+			return zoneError(fmt.Errorf("unable to read the name of zone %s (%v); give it with \"zone set-id %s <name>\"",
+				zoneID, err, zoneID))
+		}
+		name = zone.Name
+	}
+
+	// Listing the zone's apex records confirms that the credentials can
+	// reach its records.
+	_, err = baseBackend(api).ListDNSRecords(commandCtx, zoneID, cloudflare.ListDNSRecordsParams{Type: "NS", Name: name})
+	if err != nil {
+		return zoneError(fmt.Errorf("unable to read the records of zone %s: %v", zoneID, err))
+	}
+
+	rememberZoneID(name, zoneID)
+	activeZoneIdentifier = cloudflare.ZoneIdentifier(zoneID)
+	activeZoneName = name
+	printf("Active zone set to %v.\n", name)
+	return nil
+}
@@ -0,0 +1,79 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestZoneSetID(t *testing.T) {
+	useMemoryBackend(t)
+	zoneID := activeZoneIdentifier.Identifier
+	if err := processCmd("ip4 www.example.com 10.0.0.1"); err != nil {
+		t.Fatal(err)
+	}
+
+	// The credentials cannot list zones.
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `{"success": false, "errors": [{"code": 10000, "message": "Authentication error"}]}`)
+	}))
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL), cloudflare.UsingRetryPolicy(0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+	t.Cleanup(func() {
+		knownZoneIDs.Range(func(name, _ any) bool {
+			knownZoneIDs.Delete(name)
+			return true
+		})
+	})
+
+	if err := processCmd("zones"); !errors.Is(err, errCannotListZones) {
+		t.Errorf("zones = %v", err)
+	}
+	if err := processCmd("search --all-zones www"); !errors.Is(err, errCannotListZones) {
+		t.Errorf("search --all-zones = %v", err)
+	}
+	if err := processCmd("zone example.org"); err == nil || !strings.Contains(err.Error(), "zone set-id <id> example.org") {
+		t.Errorf("zone example.org = %v", err)
+	}
+
+	for _, line := range []string{"zone set-id", "zone set-id 1234 example.com"} {
+		if err := processCmd(line); exitCode(err) != exitUsage {
+			t.Errorf("%s = %v", line, err)
+		}
+	}
+
+	// A zone selected by ID is active, found by name, and stands in for
+	// the zone list.
+	activeZoneIdentifier, activeZoneName = nil, ""
+	if err := processCmd("zone set-id " + strings.ToUpper(zoneID) + " Example.com."); err != nil {
+		t.Fatal(err)
+	}
+	if activeZoneName != "example.com" || activeZoneIdentifier.Identifier != zoneID {
+		t.Errorf("active zone = %s %v", activeZoneName, activeZoneIdentifier)
+	}
+	if id, err := baseBackend(api).ZoneIDByName("example.com"); err != nil || id != zoneID {
+		t.Errorf("ZoneIDByName = %q, %v", id, err)
+	}
+	got := captureOutput(t, "zones")
+	if !strings.Contains(got, "cannot list zones") || !strings.Contains(got, "example.com "+zoneID) {
+		t.Errorf("zones displayed:\n%s", got)
+	}
+	got = captureOutput(t, "search --all-zones www")
+	if !strings.Contains(got, "10.0.0.1") {
+		t.Errorf("search --all-zones displayed:\n%s", got)
+	}
+}
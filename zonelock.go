@@ -0,0 +1,363 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/user"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/beevik/cf/cflib"
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// With "zone_locks" set in the configuration file, cf takes an advisory
+// lock on each zone before changing its records in bulk, so that operators
+// sharing a zone do not interleave their changes. The lock is a TXT record
+// named _cf-lock.<zone>, holding its holder and expiry in the style of the
+// ownership markers: "heritage=cf,cf/lock=<holder>,cf/expires=<time>". A
+// lock left behind by a process that did not release it expires after
+// zoneLockLifetime, or is removed with "zone unlock"; a lock held by a
+// running batch is renewed until the batch ends.
+
+// zoneLockLifetime is how long a zone lock is honored unless renewed.
+const zoneLockLifetime = time.Hour
+
+// lockRequestTimeout limits each request releasing or renewing a lock,
+// which is made even once the command has been interrupted.
+const lockRequestTimeout = 10 * time.Second
+
+// zoneLockRenewal is how often the locks of a batch still running are
+// renewed, well within their lifetime.
+var zoneLockRenewal = zoneLockLifetime / 4
+
+// errZoneLocked is returned for changes not made because another operator
+// holds the lock of their zone.
+var errZoneLocked = errors.New("zone is locked")
+
+// A zoneLock is a lock held on a zone.
+type zoneLock struct {
+	zoneID, zone, recordID string
+}
+
+// lockMarkerKey marks the context of requests deleting lock markers.
+type lockMarkerKey struct{}
+
+// lockMarkerCtx returns the context of a request deleting lock markers,
+// with the function releasing it. Lock markers are not records of the
+// zone's own, so their deletion is not held back by the TOTP code of zones
+// requiring one; a command that deletes nothing would otherwise ask for a
+// code only to release its lock. The context is not canceled with the
+// command, so that an interrupted batch still releases its locks instead
+// of blocking other operators until they expire.
+func lockMarkerCtx() (context.Context, context.CancelFunc) {
+	ctx := context.WithValue(context.WithoutCancel(commandCtx), lockMarkerKey{}, true)
+	return context.WithTimeout(ctx, lockRequestTimeout)
+}
+
+// deleteLockMarker deletes a lock marker from a zone.
+func deleteLockMarker(b cflib.Backend, zoneID, id string) error {
+	ctx, cancel := lockMarkerCtx()
+	defer cancel()
+	return b.DeleteDNSRecord(ctx, zoneID, id)
+}
+
+// deletesLockMarker reports whether a DELETE request deletes a lock
+// marker.
+func deletesLockMarker(req *http.Request) bool {
+	return req.Context().Value(lockMarkerKey{}) != nil
+}
+
+// lockName returns the name of a zone's lock marker.
+func lockName(zone string) string {
+	return markerPrefix + "lock." + cflib.NormalizeName(zone)
+}
+
+// lockContent returns the content of a lock marker.
+func lockContent(holder string, expires time.Time) string {
+	return "heritage=cf,cf/lock=" + holder + ",cf/expires=" + expires.UTC().Format(time.RFC3339)
+}
+
+// parseLock returns the holder and expiry of a lock marker's content, or
+// false if the content is not that of a lock marker.
+func parseLock(content string) (holder string, expires time.Time, ok bool) {
+	content = strings.Trim(content, "\"")
+	fields := strings.Split(content, ",")
+	if len(fields) < 3 || fields[0] != "heritage=cf" {
+		return "", time.Time{}, false
+	}
+	for _, f := range fields[1:] {
+		if v, ok := strings.CutPrefix(f, "cf/lock="); ok {
+			holder = v
+		} else if v, ok := strings.CutPrefix(f, "cf/expires="); ok {
+			expires, _ = time.Parse(time.RFC3339, v)
+		}
+	}
+	return holder, expires, holder != "" && !expires.IsZero()
+}
+
+// lockHolder identifies this process in the locks it takes: the owner ID
+// if one is set, or else the user and host names.
+func lockHolder() string {
+	holder := ownerID
+	if holder == "" {
+		name := "unknown"
+		if u, err := user.Current(); err == nil {
+			name = u.Username
+		}
+		host, _ := os.Hostname()
+		holder = name + "@" + host
+	}
+	return strings.NewReplacer(",", "_", " ", "_", "\"", "_").Replace(holder)
+}
+
+// lockZones takes the locks of the zones changed by a batch of operations,
+// when zone locks are enabled, returning the locks taken and the errors of
+// the zones whose locks could not be taken, by zone ID. Dry runs and
+// offline sessions change nothing and take no locks.
+func lockZones(ops []operation) ([]*zoneLock, map[string]error) {
+	if !cfg.ZoneLocks || dryRun || offline || len(ops) == 0 {
+		return nil, nil
+	}
+	api, err := getAPI()
+	if err != nil {
+		return nil, nil
+	}
+
+	var locks []*zoneLock
+	errs := make(map[string]error)
+	for _, op := range ops {
+		zoneID := keyZone(op.key)
+		if _, seen := errs[zoneID]; seen {
+			continue
+		}
+		l, err := acquireZoneLock(api, zoneID)
+		errs[zoneID] = err
+		if l != nil {
+			locks = append(locks, l)
+		}
+	}
+	return locks, errs
+}
+
+// keyZone returns the zone ID an operation's key begins with.
+func keyZone(key string) string {
+	zoneID, _, _ := strings.Cut(key, "/")
+	return zoneID
+}
+
+// acquireZoneLock creates the lock marker of a zone, unless another
+// process holds an unexpired lock. Expired locks are removed. Should two
+// processes create their markers at the same time, both see the other's,
+// and the lock goes to the marker created first.
+func acquireZoneLock(api *cloudflare.API, zoneID string) (*zoneLock, error) {
+	zone, err := lookupZoneName(commandCtx, zoneID)
+	if err != nil {
+		return nil, err
+	}
+	b := baseBackend(api)
+	name := lockName(zone)
+
+	refreshResponses()
+	markers, err := liveLocks(api, zoneID, name)
+	if err != nil {
+		return nil, err
+	}
+	if len(markers) > 0 {
+		return nil, lockedError(zone, markers[0])
+	}
+
+	rec, err := b.CreateDNSRecord(commandCtx, zoneID, cloudflare.CreateDNSRecordParams{
+		Type:    "TXT",
+		Name:    name,
+		Content: lockContent(lockHolder(), time.Now().Add(zoneLockLifetime)),
+		TTL:     ttlAuto,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to lock zone %s: %v", zone, err)
+	}
+	l := &zoneLock{zoneID, zone, rec.ID}
+
+	refreshResponses()
+	markers, err = liveLocks(api, zoneID, name)
+	if err == nil && len(markers) > 0 && markers[0].ID != rec.ID {
+		err = lockedError(zone, markers[0])
+	}
+	if err != nil {
+		l.release(api)
+		return nil, err
+	}
+	return l, nil
+}
+
+// liveLocks returns the unexpired lock markers of a zone, oldest first,
+// deleting the expired ones.
+func liveLocks(api *cloudflare.API, zoneID, name string) ([]cloudflare.DNSRecord, error) {
+	b := baseBackend(api)
+	recs, err := b.ListDNSRecords(commandCtx, zoneID, cloudflare.ListDNSRecordsParams{Type: "TXT", Name: name})
+	if err != nil {
+		return nil, err
+	}
+	var live []cloudflare.DNSRecord
+	for _, r := range recs {
+		_, expires, ok := parseLock(r.Content)
+		switch {
+		case !ok:
+		case time.Now().Before(expires):
+			live = append(live, r)
+		default:
+			if err := deleteLockMarker(b, zoneID, r.ID); err != nil {
+				return nil, err
+			}
+		}
+	}
+	sort.Slice(live, func(i, j int) bool {
+		if !live[i].CreatedOn.Equal(live[j].CreatedOn) {
+			return live[i].CreatedOn.Before(live[j].CreatedOn)
+		}
+		return live[i].ID < live[j].ID
+	})
+	return live, nil
+}
+
+func lockedError(zone string, marker cloudflare.DNSRecord) error {
+	holder, expires, _ := parseLock(marker.Content)
+	return fmt.Errorf("%w by %s until %s; \"zone unlock\" removes a stale lock of zone %s",
+		errZoneLocked, holder, expires.Local().Format(time.DateTime), zone)
+}
+
+// release deletes a lock's marker.
+func (l *zoneLock) release(api *cloudflare.API) {
+	if err := deleteLockMarker(baseBackend(api), l.zoneID, l.recordID); err != nil {
+		printf("Warning: unable to unlock zone %s: %v\n", l.zone, err)
+	}
+}
+
+// keepLocks renews the locks taken by lockZones every zoneLockRenewal, so
+// that a batch running longer than zoneLockLifetime keeps them, until the
+// function it returns is called.
+func keepLocks(locks []*zoneLock) (stop func()) {
+	if len(locks) == 0 {
+		return func() {}
+	}
+	api, err := getAPI()
+	if err != nil {
+		return func() {}
+	}
+
+	// Renewals continue until the batch ends, even once the command is
+	// interrupted and its operations in flight are finishing.
+	ctx := context.WithoutCancel(commandCtx)
+	done, stopped := make(chan struct{}), make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(zoneLockRenewal)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				for _, l := range locks {
+					l.renew(ctx, api)
+				}
+			}
+		}
+	}()
+	return func() {
+		close(done)
+		<-stopped
+	}
+}
+
+// renew moves the expiry of a lock's marker zoneLockLifetime ahead.
+func (l *zoneLock) renew(ctx context.Context, api *cloudflare.API) {
+	ctx, cancel := context.WithTimeout(ctx, lockRequestTimeout)
+	defer cancel()
+	_, err := baseBackend(api).UpdateDNSRecord(ctx, l.zoneID, cloudflare.UpdateDNSRecordParams{
+		ID:      l.recordID,
+		Type:    "TXT",
+		Name:    lockName(l.zone),
+		Content: lockContent(lockHolder(), time.Now().Add(zoneLockLifetime)),
+		TTL:     ttlAuto,
+	})
+	if err != nil {
+		printf("Warning: unable to renew the lock of zone %s: %v\n", l.zone, err)
+	}
+}
+
+// unlockZones releases the locks taken by lockZones.
+func unlockZones(locks []*zoneLock) {
+	if len(locks) == 0 {
+		return
+	}
+	api, err := getAPI()
+	if err != nil {
+		return
+	}
+	for _, l := range locks {
+		l.release(api)
+	}
+}
+
+var zoneUnlockFlags = registerFlags("zone unlock", forceFlags)
+
+// cmdZoneUnlock removes the lock of the active zone, such as one left
+// behind by a process that was killed.
+func cmdZoneUnlock(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, zoneUnlockFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+	zoneID, err := getZoneIdentifier()
+	if err != nil {
+		return err
+	}
+
+	refreshResponses()
+	b := baseBackend(api)
+	recs, err := b.ListDNSRecords(commandCtx, zoneID.Identifier,
+		cloudflare.ListDNSRecordsParams{Type: "TXT", Name: lockName(activeZoneName)})
+	if err != nil {
+		return err
+	}
+	var markers []cloudflare.DNSRecord
+	for _, r := range recs {
+		if holder, expires, ok := parseLock(r.Content); ok {
+			printf("Zone %s is locked by %s until %s.\n", activeZoneName, holder, expires.Local().Format(time.DateTime))
+			markers = append(markers, r)
+		}
+	}
+	if len(markers) == 0 {
+		printf("Zone %s is not locked.\n", activeZoneName)
+		return nil
+	}
+	if !flags.force() && !confirm(sprintf("Remove the lock?")) {
+		printf("No changes applied.\n")
+		return nil
+	}
+	for _, r := range markers {
+		if err := deleteLockMarker(b, zoneID.Identifier, r.ID); err != nil {
+			return err
+		}
+	}
+	printf("Zone %s unlocked.\n", activeZoneName)
+	return nil
+}
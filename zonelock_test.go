@@ -0,0 +1,217 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+func TestZoneLocks(t *testing.T) {
+	b := useMemoryBackend(t)
+	saved := cfg
+	defer func() { cfg = saved }()
+	cfg = &config{ZoneLocks: true}
+	zoneID := activeZoneIdentifier.Identifier
+	name := lockName("example.com")
+
+	locks := func() int {
+		t.Helper()
+		recs, err := b.ListDNSRecords(context.Background(), zoneID, cloudflare.ListDNSRecordsParams{Type: "TXT", Name: name})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return len(recs)
+	}
+	lock := func(holder string, expires time.Time) {
+		t.Helper()
+		_, err := b.CreateDNSRecord(context.Background(), zoneID, cloudflare.CreateDNSRecordParams{
+			Type: "TXT", Name: name, Content: lockContent(holder, expires),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The lock is held while the operations run and released afterward.
+	held := 0
+	op := operation{key: recordKey(zoneID, "www"), fn: func() error {
+		held = locks()
+		return nil
+	}}
+	if errs := sched.run([]operation{op}); errs[0] != nil || held != 1 || locks() != 0 {
+		t.Errorf("run = %v with %d lock(s) held, %d left", errs[0], held, locks())
+	}
+
+	// Another operator's lock stops the operations; an expired one does
+	// not, and is removed.
+	lock("alice@host", time.Now().Add(time.Hour))
+	held = 0
+	if errs := sched.run([]operation{op}); !errors.Is(errs[0], errZoneLocked) || held != 0 {
+		t.Errorf("run with the zone locked = %v", errs[0])
+	}
+	if err := processCmd("zone unlock --force"); err != nil || locks() != 0 {
+		t.Fatalf("zone unlock: %v, %d lock(s) left", err, locks())
+	}
+	lock("alice@host", time.Now().Add(-time.Minute))
+	if errs := sched.run([]operation{op}); errs[0] != nil || held != 1 || locks() != 0 {
+		t.Errorf("run with an expired lock = %v with %d lock(s) held, %d left", errs[0], held, locks())
+	}
+
+	// The lock of a batch is renewed while it runs.
+	rec := &updateRecorder{MemoryBackend: b}
+	backend = rec
+	savedRenewal := zoneLockRenewal
+	zoneLockRenewal = 10 * time.Millisecond
+	slow := operation{key: op.key, fn: func() error {
+		time.Sleep(50 * time.Millisecond)
+		return nil
+	}}
+	errs := sched.run([]operation{slow})
+	backend, zoneLockRenewal = b, savedRenewal
+	if errs[0] != nil || len(rec.updates) == 0 || rec.updates[0].Name != name || locks() != 0 {
+		t.Errorf("slow run = %v with %d renewal(s), %d lock(s) left", errs[0], len(rec.updates), locks())
+	}
+
+	// Dry runs take no lock.
+	dryRun = true
+	defer func() { dryRun = false }()
+	if errs := sched.run([]operation{op}); errs[0] != nil || held != 0 {
+		t.Errorf("dry run = %v with %d lock(s) held", errs[0], held)
+	}
+}
+
+// fakeRecordAPI serves the record requests of a single zone, z1, keeping
+// the records in memory.
+type fakeRecordAPI struct {
+	mu      sync.Mutex
+	recs    []cloudflare.DNSRecord
+	nextID  int
+	deletes int
+}
+
+func (f *fakeRecordAPI) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	var result any
+	id, _ := strings.CutPrefix(r.URL.Path, "/zones/z1/dns_records/")
+	switch {
+	case r.Method == http.MethodGet && r.URL.Path == "/zones/z1/dns_records":
+		list := []cloudflare.DNSRecord{}
+		for _, rec := range f.recs {
+			q := r.URL.Query()
+			if (q.Get("type") == "" || q.Get("type") == rec.Type) && (q.Get("name") == "" || q.Get("name") == rec.Name) {
+				list = append(list, rec)
+			}
+		}
+		result = list
+	case r.Method == http.MethodPost && r.URL.Path == "/zones/z1/dns_records":
+		var rec cloudflare.DNSRecord
+		json.NewDecoder(r.Body).Decode(&rec)
+		f.nextID++
+		rec.ID = strings.Repeat("0", 31) + string(rune('0'+f.nextID))
+		rec.CreatedOn = time.Now()
+		f.recs = append(f.recs, rec)
+		result = rec
+	case r.Method == http.MethodDelete && id != "":
+		f.deletes++
+		for i, rec := range f.recs {
+			if rec.ID == id {
+				f.recs = append(f.recs[:i], f.recs[i+1:]...)
+				break
+			}
+		}
+		result = map[string]string{"id": id}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+		json.NewEncoder(w).Encode(map[string]any{"success": false, "errors": []any{map[string]any{"code": 7003, "message": "not found"}}})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]any{"success": true, "result": result})
+}
+
+func TestZoneLockTOTP(t *testing.T) {
+	t.Setenv("CLOUDFLARE_STATE_DIR", t.TempDir())
+	t.Setenv("CLOUDFLARE_TOTP_CODE", "")
+	savedCfg, savedLoad := cfg, loadTOTPSecret
+	defer func() {
+		cfg, loadTOTPSecret = savedCfg, savedLoad
+		activeAPI, activeZoneIdentifier, activeZoneName = nil, nil, ""
+	}()
+	cfg = &config{ZoneLocks: true, TOTPZones: []string{"example.com"}}
+	loadTOTPSecret = func(zone string) (string, error) { return "JBSWY3DPEHPK3PXP", nil }
+	activeZoneIdentifier, activeZoneName = cloudflare.ZoneIdentifier("z1"), "example.com"
+	interactive = false
+
+	f := &fakeRecordAPI{}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL), cloudflare.UsingRetryPolicy(0, 0, 0),
+		cloudflare.HTTPClient(&http.Client{Transport: &totpTransport{base: http.DefaultTransport}}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	// A batch deleting nothing in a zone requiring a TOTP code takes and
+	// releases its lock without a code.
+	startRun()
+	op := operation{key: recordKey("z1", "www"), fn: func() error { return nil }}
+	if errs := sched.run([]operation{op}); errs[0] != nil {
+		t.Fatalf("run = %v", errs[0])
+	}
+	if len(f.recs) != 0 || f.deletes != 1 {
+		t.Errorf("%d lock marker(s) left after %d delete(s)", len(f.recs), f.deletes)
+	}
+
+	// Deleting records still requires the code.
+	var denied *totpError
+	if err := baseBackend(api).DeleteDNSRecord(commandCtx, "z1", "r1"); !errors.As(err, &denied) {
+		t.Errorf("record delete without a code = %v", err)
+	}
+}
+
+func TestZoneLockInterrupted(t *testing.T) {
+	t.Setenv("CLOUDFLARE_STATE_DIR", t.TempDir())
+	savedCfg, savedCtx := cfg, commandCtx
+	defer func() {
+		cfg, commandCtx = savedCfg, savedCtx
+		activeAPI, activeZoneIdentifier, activeZoneName = nil, nil, ""
+	}()
+	cfg = &config{ZoneLocks: true}
+	activeZoneIdentifier, activeZoneName = cloudflare.ZoneIdentifier("z1"), "example.com"
+
+	f := &fakeRecordAPI{}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	api, err := cloudflare.NewWithAPIToken("token", cloudflare.BaseURL(srv.URL), cloudflare.UsingRetryPolicy(0, 0, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	activeAPI = api
+
+	// A batch interrupted while it runs still releases its lock.
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	commandCtx = ctx
+	op := operation{key: recordKey("z1", "www"), fn: func() error {
+		cancel()
+		return nil
+	}}
+	sched.run([]operation{op})
+	if len(f.recs) != 0 || f.deletes != 1 {
+		t.Errorf("%d lock marker(s) left after %d delete(s)", len(f.recs), f.deletes)
+	}
+}
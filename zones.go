@@ -0,0 +1,219 @@
+// Copyright 2018 Brett Vickers.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package main
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/beevik/cmd"
+	cloudflare "github.com/cloudflare/cloudflare-go"
+)
+
+// A zoneTarget identifies a zone a command operates on.
+type zoneTarget struct {
+	name string
+	id   *cloudflare.ResourceContainer
+}
+
+// zoneFlags are the flags accepted by commands that can operate on a zone
+// other than the active zone, or on every zone in the account.
+var zoneFlags = flagSpec{
+	"zone":      true,
+	"all-zones": false,
+}
+
+func cmdZones(c *cmd.Command, args []string) error {
+	if len(args) != 0 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zones, err := api.ListZones(commandCtx)
+	if cannotListZones(err) || (err == nil && len(zones) == 0) {
+		// Without the permission to list zones, the zones selected by ID
+		// are listed, without the plan and status only the zone list has.
+		known, err := zonesSelectedByID(err)
+		if err != nil {
+			return err
+		}
+		for _, z := range known {
+			zones = append(zones, cloudflare.Zone{ID: z.id.Identifier, Name: z.name})
+		}
+	} else if err != nil {
+		return err
+	}
+
+	widthName := 0
+	widthPlan := 0
+	for _, z := range zones {
+		if len(z.Name) > widthName {
+			widthName = len(z.Name)
+		}
+		if len(z.Plan.Name) > widthPlan {
+			widthPlan = len(z.Plan.Name)
+		}
+	}
+
+	for _, z := range zones {
+		fmt.Fprintf(output, "%-*s %s %-*s %s\n", widthName, z.Name, z.ID, widthPlan, z.Plan.Name, z.Status)
+	}
+
+	return nil
+}
+
+// selectZones returns the zones a command should operate on, as chosen by
+// the --zone and --all-zones flags. Without either flag, the active zone is
+// returned.
+func selectZones(api *cloudflare.API, flags flagValues) ([]zoneTarget, error) {
+	switch {
+	case flags.has("all-zones") && flags.has("zone"):
+		return nil, argError(errors.New("--zone and --all-zones cannot be used together"))
+
+	case flags.has("all-zones") && offline:
+		return replicaZones()
+
+	case flags.has("all-zones"):
+		return accessibleZones(api)
+
+	case flags.has("zone"):
+		name := flags.get("zone", "")
+		zoneID, err := recordBackend(api).ZoneIDByName(name)
+		if err != nil {
+			return nil, zoneError(err)
+		}
+		return []zoneTarget{{name, cloudflare.ZoneIdentifier(zoneID)}}, nil
+
+	default:
+		zoneID, err := getZoneIdentifier()
+		if err != nil {
+			return nil, err
+		}
+		return []zoneTarget{{activeZoneName, zoneID}}, nil
+	}
+}
+
+// withZone runs fn with another zone active, restoring the active zone
+// afterwards.
+func withZone(zone string, fn func() error) error {
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	zoneID, err := recordBackend(api).ZoneIDByName(zone)
+	if err != nil {
+		return zoneError(err)
+	}
+
+	savedID, savedName := activeZoneIdentifier, activeZoneName
+	activeZoneIdentifier, activeZoneName = cloudflare.ZoneIdentifier(zoneID), zone
+	defer func() { activeZoneIdentifier, activeZoneName = savedID, savedName }()
+	return fn()
+}
+
+var zoneCreateFlags = registerFlags("zone create", flagSpec{
+	"account":   true,
+	"jumpstart": false,
+})
+
+func cmdZoneCreate(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, zoneCreateFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	account, err := selectAccount(api, flags.get("account", ""))
+	if err != nil {
+		return err
+	}
+
+	zone, err := api.CreateZone(commandCtx, args[0], flags.has("jumpstart"), account, "full")
+	if err != nil {
+		return err
+	}
+
+	printf("Zone %s created (ID %s).\n", zone.Name, zone.ID)
+	printf("Set the following nameservers at your registrar:\n")
+	for _, ns := range zone.NameServers {
+		fmt.Fprintf(output, "    %s\n", ns)
+	}
+	return nil
+}
+
+var zoneDeleteFlags = registerFlags("zone delete", forceFlags)
+
+func cmdZoneDelete(c *cmd.Command, args []string) error {
+	flags, args, err := parseFlags(args, zoneDeleteFlags)
+	if err != nil {
+		return err
+	}
+	if len(args) != 1 {
+		return usageError(c)
+	}
+
+	api, err := getAPI()
+	if err != nil {
+		return err
+	}
+
+	name := args[0]
+	zoneID, err := api.ZoneIDByName(name)
+	if err != nil {
+		return zoneError(err)
+	}
+
+	if !flags.force() && !confirm(sprintf("Delete zone %s and all of its records?", name)) {
+		printf("Zone not deleted.\n")
+		return nil
+	}
+
+	if _, err := api.DeleteZone(commandCtx, zoneID); err != nil {
+		return err
+	}
+
+	if activeZoneIdentifier != nil && activeZoneIdentifier.Identifier == zoneID {
+		activeZoneIdentifier = nil
+		activeZoneName = ""
+	}
+
+	printf("Zone %s deleted.\n", name)
+	return nil
+}
+
+// selectAccount returns the account with the requested ID. If no ID is
+// provided, the account is chosen automatically when the credentials have
+// access to exactly one account.
+func selectAccount(api *cloudflare.API, id string) (cloudflare.Account, error) {
+	if id != "" {
+		return cloudflare.Account{ID: id}, nil
+	}
+
+	accounts, _, err := api.Accounts(commandCtx, cloudflare.AccountsListParams{})
+	if err != nil {
+		return cloudflare.Account{}, err
+	}
+
+	switch len(accounts) {
+	case 0:
+		return cloudflare.Account{}, fmt.Errorf("no accounts available")
+	case 1:
+		return accounts[0], nil
+	default:
+		return cloudflare.Account{}, fmt.Errorf("multiple accounts available; use --account to choose one")
+	}
+}